@@ -0,0 +1,170 @@
+// p2s-testnet generates and serves the pieces of a public P2S test network.
+//
+//	p2s-testnet init     -dir testnet -validators 4 -bootnodes 1
+//	p2s-testnet bootnode -key bootnode.key -ip 203.0.113.1 -port 30303
+//	p2s-testnet faucet   -key testnet/faucet/key -rpc http://127.0.0.1:8545 -apikeys k1,k2
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/p2stestnet"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "init":
+		err = runInit(os.Args[2:])
+	case "bootnode":
+		err = runBootnode(os.Args[2:])
+	case "faucet":
+		err = runFaucet(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: p2s-testnet <init|bootnode|faucet> [flags]")
+	os.Exit(2)
+}
+
+// runInit generates keys, genesis and bootnode list for a new test network
+func runInit(args []string) error {
+	defaults := p2stestnet.DefaultTestnetConfig()
+
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	dir := fs.String("dir", "testnet", "Output directory")
+	chainID := fs.Uint64("chainid", defaults.ChainID, "Chain ID")
+	validators := fs.Int("validators", defaults.Validators, "Number of validators")
+	bootnodes := fs.Int("bootnodes", defaults.Bootnodes, "Number of bootnodes")
+	ip := fs.String("ip", defaults.BootnodeIP.String(), "Bootnode advertised IP")
+	port := fs.Int("port", defaults.BootnodePort, "First bootnode port")
+	fs.Parse(args)
+
+	config := defaults
+	config.ChainID = *chainID
+	config.Validators = *validators
+	config.Bootnodes = *bootnodes
+	config.BootnodeIP = net.ParseIP(*ip)
+	config.BootnodePort = *port
+	if config.BootnodeIP == nil {
+		return fmt.Errorf("invalid bootnode IP %q", *ip)
+	}
+
+	testnet, err := p2stestnet.NewTestnet(config)
+	if err != nil {
+		return err
+	}
+	if err := testnet.WriteTo(*dir); err != nil {
+		return err
+	}
+
+	fmt.Println("Testnet written to", *dir)
+	for _, url := range testnet.BootnodeURLs() {
+		fmt.Println("Bootnode:", url)
+	}
+	for i, address := range testnet.ValidatorAddresses() {
+		fmt.Printf("Validator %d: %s (key %s)\n", i, address.Hex(), filepath.Join(*dir, fmt.Sprintf("validator%d", i), "key"))
+	}
+	fmt.Println("Faucet:", testnet.FaucetAddress().Hex())
+	return nil
+}
+
+// runBootnode generates or loads a bootnode key and prints its enode URL
+func runBootnode(args []string) error {
+	fs := flag.NewFlagSet("bootnode", flag.ExitOnError)
+	keyFile := fs.String("key", "bootnode.key", "Node key file, generated if missing")
+	ip := fs.String("ip", "127.0.0.1", "Advertised IP")
+	port := fs.Int("port", 30303, "Advertised port")
+	fs.Parse(args)
+
+	addr := net.ParseIP(*ip)
+	if addr == nil {
+		return fmt.Errorf("invalid IP %q", *ip)
+	}
+
+	var (
+		bootnode *p2stestnet.Bootnode
+		err      error
+	)
+	if _, statErr := os.Stat(*keyFile); statErr == nil {
+		bootnode, err = p2stestnet.LoadBootnode(*keyFile, addr, *port)
+	} else {
+		bootnode, err = p2stestnet.GenerateBootnode(addr, *port)
+		if err == nil {
+			err = bootnode.SaveKey(*keyFile)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(bootnode.URL())
+	return nil
+}
+
+// runFaucet serves the faucet over HTTP, funding accounts through a node's RPC endpoint
+func runFaucet(args []string) error {
+	defaults := p2stestnet.DefaultFaucetConfig()
+
+	fs := flag.NewFlagSet("faucet", flag.ExitOnError)
+	keyFile := fs.String("key", "testnet/faucet/key", "Faucet account key file")
+	rpc := fs.String("rpc", "http://127.0.0.1:8545", "Node RPC endpoint")
+	listen := fs.String("listen", ":8080", "HTTP listen address")
+	apiKeys := fs.String("apikeys", "", "Comma-separated API keys; required unless -open is set")
+	open := fs.Bool("open", false, "Serve without API key checks (local testing only)")
+	cooldown := fs.Duration("cooldown", defaults.Cooldown, "Minimum time between requests per address and IP")
+	fs.Parse(args)
+
+	var verifier p2stestnet.RequestVerifier
+	switch {
+	case *apiKeys != "":
+		verifier = &p2stestnet.APIKeyVerifier{Keys: strings.Split(*apiKeys, ",")}
+	case !*open:
+		return fmt.Errorf("either -apikeys or -open is required")
+	}
+
+	key, err := crypto.LoadECDSA(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := ethclient.DialContext(ctx, *rpc)
+	if err != nil {
+		return err
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := defaults
+	config.Cooldown = *cooldown
+	faucet := p2stestnet.NewFaucet(p2stestnet.NewRPCFaucetBackend(client, key, new(big.Int).Set(chainID)), verifier, config)
+
+	fmt.Printf("Faucet %s serving on %s\n", crypto.PubkeyToAddress(key.PublicKey).Hex(), *listen)
+	return http.ListenAndServe(*listen, faucet)
+}
@@ -0,0 +1,137 @@
+package p2s
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Admission rejection codes
+const (
+	RejectFeeBelowFloor    = "fee_below_floor"
+	RejectSenderCapReached = "sender_cap_reached"
+	RejectBondMissing      = "bond_missing"
+	RejectCallDataTooLarge = "calldata_too_large"
+	RejectInvalidPHT       = "invalid_pht"
+	RejectNonceTooLow      = "nonce_too_low"
+)
+
+// AdmissionRejection explains why a PHT would not be admitted
+type AdmissionRejection struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// AdmissionResult reports whether a PHT would be admitted to the pool
+type AdmissionResult struct {
+	Admitted   bool                 `json:"admitted"`
+	Rejections []AdmissionRejection `json:"rejections"`
+}
+
+// AdmissionState supplies pool and bond state to the admission policy
+type AdmissionState interface {
+	PendingPHTs(sender common.Address) int
+	Bond(sender common.Address) *big.Int
+}
+
+// AdmissionNonceState is implemented by admission states that know account
+// nonces, letting the policy reject PHTs whose nonce was already used
+type AdmissionNonceState interface {
+	AdmissionState
+	AccountNonce(sender common.Address) uint64
+}
+
+// AdmissionBondState is implemented by admission states holding sender bonds in
+// custody, letting penalties be charged to a sender's bond
+type AdmissionBondState interface {
+	AdmissionState
+	SlashBond(sender common.Address, amount *big.Int) *big.Int
+}
+
+// AdmissionPolicy decides whether PHTs are admitted to the pool
+type AdmissionPolicy struct {
+	config *P2SConfig
+	state  AdmissionState
+	mu     sync.RWMutex
+}
+
+// NewAdmissionPolicy creates an admission policy; without state, sender caps and bonds are not enforced
+func NewAdmissionPolicy(config *P2SConfig) *AdmissionPolicy {
+	return &AdmissionPolicy{
+		config: config,
+	}
+}
+
+// SetState sets the pool and bond state used by the policy
+func (a *AdmissionPolicy) SetState(state AdmissionState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.state = state
+}
+
+// SlashBond charges up to amount to a sender's bond and returns the amount slashed,
+// zero when the state does not hold bonds
+func (a *AdmissionPolicy) SlashBond(sender common.Address, amount *big.Int) *big.Int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	bonds, ok := a.state.(AdmissionBondState)
+	if !ok || amount == nil || amount.Sign() <= 0 {
+		return new(big.Int)
+	}
+	return bigOrZero(bonds.SlashBond(sender, amount))
+}
+
+// Check evaluates a PHT against all admission rules, reporting every failing rule
+func (a *AdmissionPolicy) Check(pht *PHTTransaction) *AdmissionResult {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := &AdmissionResult{Rejections: []AdmissionRejection{}}
+	reject := func(code string, format string, args ...interface{}) {
+		result.Rejections = append(result.Rejections, AdmissionRejection{
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if pht == nil || pht.GasPrice == nil || len(pht.Commitment) == 0 {
+		reject(RejectInvalidPHT, "PHT is missing gas price or commitment")
+		return result
+	}
+
+	if floor := a.config.MinPHTGasPrice; floor != nil && pht.GasPrice.Cmp(floor) < 0 {
+		reject(RejectFeeBelowFloor, "gas price %v below floor %v", pht.GasPrice, floor)
+	}
+
+	if limit := a.config.MaxPHTCallDataSize; limit > 0 && len(pht.CallData) > limit {
+		reject(RejectCallDataTooLarge, "call data exceeds limit %d", limit)
+	}
+
+	if a.state != nil {
+		if limit := a.config.MaxPendingPHTsPerSender; limit > 0 {
+			if pending := a.state.PendingPHTs(pht.Sender); pending >= limit {
+				reject(RejectSenderCapReached, "sender has %d pending PHTs, limit %d", pending, limit)
+			}
+		}
+
+		if required := a.config.PHTBond; required != nil && required.Sign() > 0 {
+			bond := a.state.Bond(pht.Sender)
+			if bond == nil || bond.Cmp(required) < 0 {
+				reject(RejectBondMissing, "sender bond below required %v", required)
+			}
+		}
+
+		if nonces, ok := a.state.(AdmissionNonceState); ok {
+			if next := nonces.AccountNonce(pht.Sender); pht.AccountNonce < next {
+				reject(RejectNonceTooLow, "account nonce %d below next nonce %d", pht.AccountNonce, next)
+			}
+		}
+	}
+
+	result.Admitted = len(result.Rejections) == 0
+	return result
+}
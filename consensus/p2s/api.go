@@ -0,0 +1,119 @@
+package p2s
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// API exposes P2S engine functionality over RPC in the "p2s" namespace
+type API struct {
+	p2s *P2SConsensus
+}
+
+// SimulateAdmission reports whether a candidate PHT would be admitted to the pool
+// right now and, if not, every rule it fails (p2s_simulateAdmission)
+func (api *API) SimulateAdmission(pht *PHTTransaction) *AdmissionResult {
+	return api.p2s.CheckAdmission(pht)
+}
+
+// SenderReputation returns the MEV reputation of a PHT sender, including the
+// cluster of addresses it is linked to (p2s_senderReputation)
+func (api *API) SenderReputation(sender common.Address) *SenderReputation {
+	return api.p2s.GetSenderReputation(sender)
+}
+
+// CrossDomainFindings returns the bridge calls in a B1 block that are correlated
+// with same-block swaps, for L2 and bridge operators (p2s_crossDomainFindings)
+func (api *API) CrossDomainFindings(hash common.Hash) ([]*CrossDomainFinding, error) {
+	return api.p2s.GetCrossDomainFindings(hash)
+}
+
+// EnterMaintenance takes a validator out of duty rotation for planned maintenance,
+// handing imminent proposal duties to fallback proposers (p2s_enterMaintenance)
+func (api *API) EnterMaintenance(validator common.Address, slot uint64) (*MaintenanceStatus, error) {
+	return api.p2s.EnterMaintenance(validator, slot)
+}
+
+// MaintenanceStatus reports whether in-flight reveals have drained and the node
+// is safe to shut down (p2s_maintenanceStatus)
+func (api *API) MaintenanceStatus() *MaintenanceStatus {
+	return api.p2s.GetMaintenanceStatus()
+}
+
+// ExitMaintenance returns the node to duty rotation (p2s_exitMaintenance)
+func (api *API) ExitMaintenance() {
+	api.p2s.ExitMaintenance()
+}
+
+// SubmitEvidence submits an evidence packet claiming an undetected MEV attack in a
+// past block; valid claims are rewarded from the treasury (p2s_submitEvidence)
+func (api *API) SubmitEvidence(packet *EvidencePacket) (*BountyClaim, error) {
+	return api.p2s.SubmitBountyEvidence(packet)
+}
+
+// BountyClaims returns all accepted bounty claims (p2s_bountyClaims)
+func (api *API) BountyClaims() []*BountyClaim {
+	return api.p2s.GetBountyClaims()
+}
+
+// RiskBands returns the MEV score cutoffs between risk levels (p2s_riskBands)
+func (api *API) RiskBands() RiskBands {
+	return api.p2s.GetRiskBands()
+}
+
+// SetRiskBands updates the MEV score cutoffs between risk levels (p2s_setRiskBands)
+func (api *API) SetRiskBands(bands RiskBands) error {
+	return api.p2s.SetRiskBands(bands)
+}
+
+// ExportTestVector generates a detection test vector from a B1 block (p2s_exportTestVector)
+func (api *API) ExportTestVector(hash common.Hash, name string) (*TestVector, error) {
+	return api.p2s.ExportTestVector(hash, name)
+}
+
+// SubmitHaltMessage submits a signed emergency halt or resume vote (p2s_submitHaltMessage)
+func (api *API) SubmitHaltMessage(msg *HaltMessage) (bool, error) {
+	return api.p2s.SubmitHaltMessage(msg)
+}
+
+// HaltStatus returns the emergency halt state of the node (p2s_haltStatus)
+func (api *API) HaltStatus() *HaltStatus {
+	return api.p2s.GetHaltStatus()
+}
+
+// RevealsByRecipient returns revealed MTs sent to an address in the last blocks
+// blocks, 0 for the whole index (p2s_revealsByRecipient)
+func (api *API) RevealsByRecipient(recipient common.Address, blocks uint64) []IndexedMT {
+	return api.p2s.GetRevealsByRecipient(recipient, blocks)
+}
+
+// RevealsBySelector returns revealed MTs calling a 4-byte selector in the last
+// blocks blocks, 0 for the whole index (p2s_revealsBySelector)
+func (api *API) RevealsBySelector(selector hexutil.Bytes, blocks uint64) ([]IndexedMT, error) {
+	return api.p2s.GetRevealsBySelector(selector, blocks)
+}
+
+// RevealsByToken returns revealed MTs touching an ERC-20 token in the last blocks
+// blocks, 0 for the whole index (p2s_revealsByToken)
+func (api *API) RevealsByToken(token common.Address, blocks uint64) []IndexedMT {
+	return api.p2s.GetRevealsByToken(token, blocks)
+}
+
+// PHTReceipt returns where a hidden transaction stands in the PHT lifecycle, nil
+// if the node has no record of it (p2s_phtReceipt)
+func (api *API) PHTReceipt(txHash common.Hash) *PHTReceipt {
+	receipt, _ := api.p2s.GetPHTReceipt(txHash)
+	return receipt
+}
+
+// MTInclusionProof returns a proof that a transaction was revealed in a B2 block,
+// verifiable against the block header alone (p2s_mtInclusionProof)
+func (api *API) MTInclusionProof(blockHash, txHash common.Hash) (*MTInclusionProof, error) {
+	return api.p2s.GetMTInclusionProof(blockHash, txHash)
+}
+
+// PHTReceiptsBySender returns the lifecycle receipts of a sender's recent PHTs,
+// oldest first (p2s_phtReceiptsBySender)
+func (api *API) PHTReceiptsBySender(sender common.Address) []*PHTReceipt {
+	return api.p2s.GetPHTReceiptsBySender(sender)
+}
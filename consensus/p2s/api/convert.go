@@ -0,0 +1,140 @@
+package api
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+)
+
+// FromPHT converts a PHT to its public view, dropping the hidden fields
+func FromPHT(pht *p2s.PHTTransaction) *PHT {
+	if pht == nil {
+		return nil
+	}
+	result := &PHT{
+		TxHash:     pht.TxHash,
+		Sender:     pht.Sender,
+		GasPrice:   copyBig(pht.GasPrice),
+		Commitment: common.CopyBytes(pht.Commitment),
+		Nonce:      common.CopyBytes(pht.Nonce),
+		NonceProof: common.CopyBytes(pht.NonceProof),
+		Timestamp:  pht.Timestamp,
+
+		AccountNonce: pht.AccountNonce,
+	}
+	// Networks may hide the sender and gas price until B2
+	if pht.HiddenSet.Has(p2s.FieldSender) {
+		result.Sender = common.Address{}
+	}
+	if pht.HiddenSet.Has(p2s.FieldGasPrice) {
+		result.GasPrice = nil
+	}
+	return result
+}
+
+// FromMT converts a revealed MT
+func FromMT(mt *p2s.MTTransaction) *MT {
+	if mt == nil {
+		return nil
+	}
+	return &MT{
+		TxHash:    mt.TxHash,
+		PHTHash:   mt.PHTHash,
+		Recipient: mt.Recipient,
+		Value:     copyBig(mt.Value),
+		CallData:  common.CopyBytes(mt.CallData),
+		TxType:    mt.TxType,
+		GasLimit:  mt.GasLimit,
+		Timestamp: mt.Timestamp,
+
+		IsContractCreation: mt.IsContractCreation,
+	}
+}
+
+// FromB1Block converts a B1 block, keeping only the visible PHT fields
+func FromB1Block(block *p2s.B1Block) *B1Block {
+	if block == nil {
+		return nil
+	}
+	result := &B1Block{
+		Hash:            block.BlockHash,
+		PHTs:            make([]*PHT, 0, len(block.PHTs)),
+		MEVScore:        block.MEVScore,
+		DetectedAttacks: append([]string{}, block.DetectedAttacks...),
+		Timestamp:       block.Timestamp,
+	}
+	if block.Header != nil && block.Header.Number != nil {
+		result.Number = block.Header.Number.Uint64()
+	}
+	for _, pht := range block.PHTs {
+		result.PHTs = append(result.PHTs, FromPHT(pht))
+	}
+	return result
+}
+
+// FromB2Block converts a B2 block
+func FromB2Block(block *p2s.B2Block) *B2Block {
+	if block == nil {
+		return nil
+	}
+	result := &B2Block{
+		Hash:      block.BlockHash,
+		B1Hash:    block.B1BlockHash,
+		MTs:       make([]*MT, 0, len(block.MTs)),
+		Timestamp: block.Timestamp,
+	}
+	if block.Header != nil && block.Header.Number != nil {
+		result.Number = block.Header.Number.Uint64()
+	}
+	for _, mt := range block.MTs {
+		result.MTs = append(result.MTs, FromMT(mt))
+	}
+	return result
+}
+
+// FromMEVAnalysis converts an MEV analysis result
+func FromMEVAnalysis(analysis *p2s.MEVAnalysis) *MEVAnalysis {
+	if analysis == nil {
+		return nil
+	}
+	result := &MEVAnalysis{
+		Score:           analysis.Score,
+		RiskLevel:       analysis.RiskLevel,
+		DetectedAttacks: append([]string{}, analysis.DetectedAttacks...),
+		Recommendations: append([]string{}, analysis.Recommendations...),
+		Factors:         make([]MEVFactor, 0, len(analysis.Factors)),
+		EstimatedProfit: copyBig(analysis.EstimatedProfit),
+	}
+	for _, factor := range analysis.Factors {
+		result.Factors = append(result.Factors, MEVFactor{
+			Rule:     factor.Rule,
+			Attack:   factor.Attack,
+			Penalty:  factor.Penalty,
+			Evidence: factor.Evidence,
+		})
+	}
+	return result
+}
+
+// FromValidator converts a validator
+func FromValidator(validator *p2s.Validator) *Validator {
+	if validator == nil {
+		return nil
+	}
+	return &Validator{
+		Address:    validator.Address,
+		Stake:      copyBig(validator.Stake),
+		Reputation: validator.Reputation,
+		Active:     validator.IsActive,
+		LastBlock:  validator.LastBlock,
+	}
+}
+
+// copyBig returns a copy of n, nil for nil
+func copyBig(n *big.Int) *big.Int {
+	if n == nil {
+		return nil
+	}
+	return new(big.Int).Set(n)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Engine is the subset of the consensus engine the stable API is built on. The
+// assertions below fail to compile when a refactor of the consensus package
+// changes any of these methods, instead of breaking embedders at runtime.
+type Engine interface {
+	GetB1Block(hash common.Hash) (*p2s.B1Block, bool)
+	GetB2Block(hash common.Hash) (*p2s.B2Block, bool)
+	GetMEVScore(block *types.Block) float64
+	GetDetectedAttacks(block *types.Block) []string
+	GetValidatorInfo(validator common.Address) *p2s.Validator
+}
+
+// Reader is the stable read-only view of a P2S engine
+type Reader interface {
+	// B1Block returns a B1 block by hash, nil if unknown
+	B1Block(hash common.Hash) *B1Block
+
+	// B2Block returns a B2 block by hash, nil if unknown
+	B2Block(hash common.Hash) *B2Block
+
+	// MEVScore returns the MEV protection score of a B1 or B2 block
+	MEVScore(block *types.Block) float64
+
+	// DetectedAttacks returns the MEV attacks detected in a B1 or B2 block
+	DetectedAttacks(block *types.Block) []string
+
+	// Validator returns a validator by address, nil if unknown
+	Validator(address common.Address) *Validator
+}
+
+// Compile-time guards on the engine and the interfaces embedders implement or consume
+var (
+	_ Engine                 = (*p2s.P2SConsensus)(nil)
+	_ Reader                 = (*engineReader)(nil)
+	_ p2s.CommitmentScheme   = (*p2s.PedersenCommitment)(nil)
+	_ p2s.CommitmentScheme   = (*p2s.KZGCommitment)(nil)
+	_ p2s.MEVScorer          = (*p2s.MEVDetector)(nil)
+	_ p2s.MEVScorer          = (*p2s.RemoteDetector)(nil)
+	_ p2s.ValidatorSelection = (*p2s.WeightedRandomSelection)(nil)
+	_ p2s.ValidatorSelection = (*p2s.RoundRobinSelection)(nil)
+	_ p2s.ValidatorSelection = (*p2s.PrioritySelection)(nil)
+)
+
+// engineReader adapts an engine to the stable Reader
+type engineReader struct {
+	engine Engine
+}
+
+// NewReader returns the stable read-only view of an engine
+func NewReader(engine Engine) Reader {
+	return &engineReader{engine: engine}
+}
+
+// B1Block returns a B1 block by hash with PHT hidden fields removed
+func (r *engineReader) B1Block(hash common.Hash) *B1Block {
+	block, ok := r.engine.GetB1Block(hash)
+	if !ok {
+		return nil
+	}
+	return FromB1Block(block)
+}
+
+// B2Block returns a B2 block by hash
+func (r *engineReader) B2Block(hash common.Hash) *B2Block {
+	block, ok := r.engine.GetB2Block(hash)
+	if !ok {
+		return nil
+	}
+	return FromB2Block(block)
+}
+
+// MEVScore returns the MEV protection score of a block
+func (r *engineReader) MEVScore(block *types.Block) float64 {
+	return r.engine.GetMEVScore(block)
+}
+
+// DetectedAttacks returns the MEV attacks detected in a block
+func (r *engineReader) DetectedAttacks(block *types.Block) []string {
+	return append([]string{}, r.engine.GetDetectedAttacks(block)...)
+}
+
+// Validator returns a validator by address
+func (r *engineReader) Validator(address common.Address) *Validator {
+	return FromValidator(r.engine.GetValidatorInfo(address))
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PHT is the public view of a partially hidden transaction. Hidden fields are not
+// part of the stable API; they become available through the matching MT.
+type PHT struct {
+	TxHash     common.Hash    `json:"txHash"`
+	Sender     common.Address `json:"sender"`   // Zero on networks hiding the sender
+	GasPrice   *big.Int       `json:"gasPrice"` // Nil on networks hiding the gas price
+	Commitment []byte         `json:"commitment"`
+	Nonce      []byte         `json:"nonce"`
+	NonceProof []byte         `json:"nonceProof,omitempty"`
+	Timestamp  uint64         `json:"timestamp"`
+
+	AccountNonce uint64 `json:"accountNonce"`
+}
+
+// MT is a revealed transaction matching a PHT
+type MT struct {
+	TxHash    common.Hash    `json:"txHash"`
+	PHTHash   common.Hash    `json:"phtHash"`
+	Recipient common.Address `json:"recipient"`
+	Value     *big.Int       `json:"value"`
+	CallData  []byte         `json:"callData"`
+	TxType    uint8          `json:"txType"`
+	GasLimit  uint64         `json:"gasLimit"`
+	Timestamp uint64         `json:"timestamp"`
+
+	IsContractCreation bool `json:"isContractCreation,omitempty"` // Recipient is zero
+}
+
+// B1Block is the public view of a B1 block
+type B1Block struct {
+	Hash            common.Hash `json:"hash"`
+	Number          uint64      `json:"number"`
+	PHTs            []*PHT      `json:"phts"`
+	MEVScore        float64     `json:"mevScore"`
+	DetectedAttacks []string    `json:"detectedAttacks"`
+	Timestamp       uint64      `json:"timestamp"`
+}
+
+// B2Block is the public view of a B2 block
+type B2Block struct {
+	Hash      common.Hash `json:"hash"`
+	Number    uint64      `json:"number"`
+	B1Hash    common.Hash `json:"b1Hash"`
+	MTs       []*MT       `json:"mts"`
+	Timestamp uint64      `json:"timestamp"`
+}
+
+// MEVFactor is a single rule contribution to an MEV score
+type MEVFactor struct {
+	Rule     string  `json:"rule"`
+	Attack   bool    `json:"attack"`
+	Penalty  float64 `json:"penalty"`
+	Evidence string  `json:"evidence"`
+}
+
+// MEVAnalysis is the result of analyzing a transaction for MEV risk
+type MEVAnalysis struct {
+	Score           float64     `json:"score"`
+	RiskLevel       string      `json:"riskLevel"`
+	DetectedAttacks []string    `json:"detectedAttacks"`
+	Recommendations []string    `json:"recommendations"`
+	Factors         []MEVFactor `json:"factors"`
+	EstimatedProfit *big.Int    `json:"estimatedProfit,omitempty"`
+}
+
+// Validator is a read-only view of a validator
+type Validator struct {
+	Address    common.Address `json:"address"`
+	Stake      *big.Int       `json:"stake"`
+	Reputation int64          `json:"reputation"`
+	Active     bool           `json:"active"`
+	LastBlock  uint64         `json:"lastBlock"`
+}
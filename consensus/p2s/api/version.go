@@ -0,0 +1,67 @@
+// Package api is the stable surface of the P2S consensus engine for embedders.
+//
+// The types in this package mirror the externally consumed P2S types and are
+// populated by conversion functions, so refactors of the consensus package do not
+// leak into embedding code. The package follows semantic versioning: fields and
+// methods are only added in minor versions and only removed or changed in major
+// versions. Embedders should guard on the version they were built against with
+// RequireVersion.
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version of the stable API
+const (
+	VersionMajor = 1
+	VersionMinor = 0
+	VersionPatch = 0
+)
+
+// Version is the semantic version of the stable API
+var Version = fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch)
+
+var (
+	// ErrIncompatibleVersion is returned when the API cannot satisfy a required version
+	ErrIncompatibleVersion = errors.New("incompatible P2S API version")
+
+	// ErrInvalidVersion is returned for malformed version strings
+	ErrInvalidVersion = errors.New("invalid P2S API version")
+)
+
+// RequireVersion checks that this API satisfies a required "major.minor[.patch]"
+// version: the major versions must match and this API must be at least as new.
+func RequireVersion(required string) error {
+	major, minor, patch, err := parseVersion(required)
+	if err != nil {
+		return err
+	}
+	if major != VersionMajor {
+		return fmt.Errorf("%w: have %s, require %s", ErrIncompatibleVersion, Version, required)
+	}
+	if minor > VersionMinor || (minor == VersionMinor && patch > VersionPatch) {
+		return fmt.Errorf("%w: have %s, require %s", ErrIncompatibleVersion, Version, required)
+	}
+	return nil
+}
+
+// parseVersion parses a "major.minor[.patch]" version, with an optional "v" prefix
+func parseVersion(version string) (major, minor, patch int, err error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, fmt.Errorf("%w: %q", ErrInvalidVersion, version)
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0, 0, 0, fmt.Errorf("%w: %q", ErrInvalidVersion, version)
+		}
+		numbers[i] = n
+	}
+	return numbers[0], numbers[1], numbers[2], nil
+}
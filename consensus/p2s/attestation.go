@@ -0,0 +1,245 @@
+package p2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// attestationPoolLimit bounds the B1 blocks attestations are collected for
+const attestationPoolLimit = 256
+
+var (
+	// ErrInvalidAttestation is returned for an attestation that is malformed, not
+	// signed by a committee member or about a different block or MEV score
+	ErrInvalidAttestation = errors.New("invalid attestation")
+
+	// ErrAttestationQuorum is returned when finalizing a B2 block whose B1 block
+	// lacks a quorum of committee attestations
+	ErrAttestationQuorum = errors.New("B1 block lacks attestation quorum")
+)
+
+// B1Attestation is a committee member's statement that a B1 block is valid and
+// carries the MEV score it claims
+type B1Attestation struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	B1Hash      common.Hash    `json:"b1Hash"`
+	MEVScore    float64        `json:"mevScore"`
+	Attester    common.Address `json:"attester"`
+	Signature   hexutil.Bytes  `json:"signature"`
+}
+
+// SigningHash returns the hash the attester signs
+func (a *B1Attestation) SigningHash() common.Hash {
+	var number, score [8]byte
+	binary.BigEndian.PutUint64(number[:], a.BlockNumber)
+	binary.BigEndian.PutUint64(score[:], math.Float64bits(a.MEVScore))
+	return crypto.Keccak256Hash([]byte("p2s-b1-attestation"), number[:], a.B1Hash.Bytes(), score[:])
+}
+
+// SignB1Attestation signs an attestation with a committee member's key
+func SignB1Attestation(attestation *B1Attestation, key []byte) error {
+	privateKey, err := crypto.ToECDSA(key)
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(attestation.SigningHash().Bytes(), privateKey)
+	if err != nil {
+		return err
+	}
+	attestation.Attester = crypto.PubkeyToAddress(privateKey.PublicKey)
+	attestation.Signature = sig
+	return nil
+}
+
+// AttestationCommittee returns the validators attesting to the B1 block of a
+// slot: the selectable validators ranked by the hash of the seed, the slot and
+// their address, so every node derives the same committee
+func (v *ValidatorManager) AttestationCommittee(slot uint64, seed common.Hash, size int) []common.Address {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], slot)
+
+	addresses := sortedActiveValidators(v.validators)
+	ranks := make(map[common.Address]common.Hash, len(addresses))
+	for _, address := range addresses {
+		ranks[address] = crypto.Keccak256Hash(seed.Bytes(), number[:], address.Bytes())
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return ranks[addresses[i]].Big().Cmp(ranks[addresses[j]].Big()) < 0
+	})
+	if size >= 0 && size < len(addresses) {
+		addresses = addresses[:size]
+	}
+	return addresses
+}
+
+// attestationRound collects the attestations to one B1 block
+type attestationRound struct {
+	number       uint64
+	mevScore     float64
+	members      map[common.Address]bool
+	attestations map[common.Address]*B1Attestation
+}
+
+// AttestationPool collects committee attestations to B1 blocks and tells whether
+// a block reached quorum
+type AttestationPool struct {
+	rounds map[common.Hash]*attestationRound
+	quorum func(committee int) int
+	mu     sync.Mutex
+}
+
+// NewAttestationPool creates a pool requiring quorum attestations per block, more
+// than two thirds of the committee for 0
+func NewAttestationPool(quorum int) *AttestationPool {
+	return &AttestationPool{
+		rounds: make(map[common.Hash]*attestationRound),
+		quorum: func(committee int) int {
+			return attestationQuorum(quorum, committee)
+		},
+	}
+}
+
+// attestationQuorum returns the attestations a committee must give, quorum or more
+// than two thirds of the committee for 0
+func attestationQuorum(quorum, committee int) int {
+	if quorum > 0 && quorum <= committee {
+		return quorum
+	}
+	return committee*2/3 + 1
+}
+
+// VerifyAttestations checks that the attestations a B2 block carries prove a
+// quorum of the committee of its B1 block attested to the block and its MEV score
+func VerifyAttestations(attestations []*B1Attestation, b1Hash common.Hash, b1Block *B1Block, committee []common.Address, quorum int) error {
+	members := make(map[common.Address]bool, len(committee))
+	for _, member := range committee {
+		members[member] = true
+	}
+	number := b1Block.Header.Number.Uint64()
+
+	attested := make(map[common.Address]bool, len(attestations))
+	for i, attestation := range attestations {
+		if attestation == nil || attestation.B1Hash != b1Hash || attestation.BlockNumber != number {
+			return fmt.Errorf("%w: attestation %d is not about B1 block %s", ErrInvalidAttestation, i, b1Hash.Hex())
+		}
+		if attestation.MEVScore != b1Block.MEVScore {
+			return fmt.Errorf("%w: MEV score %v, block claims %v", ErrInvalidAttestation, attestation.MEVScore, b1Block.MEVScore)
+		}
+		signer, err := recoverSealSigner(attestation.SigningHash(), attestation.Signature)
+		if err != nil || signer != attestation.Attester {
+			return fmt.Errorf("%w: not signed by %s", ErrInvalidAttestation, attestation.Attester.Hex())
+		}
+		if !members[signer] {
+			return fmt.Errorf("%w: %s not on the committee", ErrInvalidAttestation, signer.Hex())
+		}
+		attested[signer] = true
+	}
+	if need := attestationQuorum(quorum, len(committee)); len(attested) < need {
+		return fmt.Errorf("%w: have %d, need %d", ErrAttestationQuorum, len(attested), need)
+	}
+	return nil
+}
+
+// Open starts collecting attestations to a B1 block from its committee
+func (p *AttestationPool) Open(number uint64, b1Hash common.Hash, mevScore float64, committee []common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.rounds) >= attestationPoolLimit {
+		var oldest common.Hash
+		first := true
+		for hash, round := range p.rounds {
+			if first || round.number < p.rounds[oldest].number {
+				oldest, first = hash, false
+			}
+		}
+		delete(p.rounds, oldest)
+	}
+	round := &attestationRound{
+		number:       number,
+		mevScore:     mevScore,
+		members:      make(map[common.Address]bool, len(committee)),
+		attestations: make(map[common.Address]*B1Attestation),
+	}
+	for _, member := range committee {
+		round.members[member] = true
+	}
+	p.rounds[b1Hash] = round
+}
+
+// Add verifies an attestation and records it, reporting whether it brought the
+// block to quorum
+func (p *AttestationPool) Add(attestation *B1Attestation) (bool, error) {
+	if attestation == nil {
+		return false, fmt.Errorf("%w: missing", ErrInvalidAttestation)
+	}
+	signer, err := recoverSealSigner(attestation.SigningHash(), attestation.Signature)
+	if err != nil || signer != attestation.Attester {
+		return false, fmt.Errorf("%w: not signed by %s", ErrInvalidAttestation, attestation.Attester.Hex())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	round := p.rounds[attestation.B1Hash]
+	if round == nil || round.number != attestation.BlockNumber {
+		return false, fmt.Errorf("%w: unknown B1 block %s", ErrInvalidAttestation, attestation.B1Hash.Hex())
+	}
+	if !round.members[signer] {
+		return false, fmt.Errorf("%w: %s not on the committee", ErrInvalidAttestation, signer.Hex())
+	}
+	if attestation.MEVScore != round.mevScore {
+		return false, fmt.Errorf("%w: MEV score %v, block claims %v", ErrInvalidAttestation, attestation.MEVScore, round.mevScore)
+	}
+	before := len(round.attestations) >= p.quorum(len(round.members))
+	round.attestations[signer] = attestation
+	return !before && len(round.attestations) >= p.quorum(len(round.members)), nil
+}
+
+// Attested reports whether a B1 block reached quorum
+func (p *AttestationPool) Attested(b1Hash common.Hash) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	round := p.rounds[b1Hash]
+	return round != nil && len(round.attestations) >= p.quorum(len(round.members))
+}
+
+// Attestations returns the attestations collected for a B1 block, by attester
+func (p *AttestationPool) Attestations(b1Hash common.Hash) []*B1Attestation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	round := p.rounds[b1Hash]
+	if round == nil {
+		return nil
+	}
+	attestations := make([]*B1Attestation, 0, len(round.attestations))
+	for _, attestation := range round.attestations {
+		attestations = append(attestations, attestation)
+	}
+	sort.Slice(attestations, func(i, j int) bool {
+		return bytes.Compare(attestations[i].Attester[:], attestations[j].Attester[:]) < 0
+	})
+	return attestations
+}
+
+// Forget stops collecting attestations for a B1 block
+func (p *AttestationPool) Forget(b1Hash common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.rounds, b1Hash)
+}
@@ -0,0 +1,190 @@
+package p2s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrB2Incomplete is returned when taking the MTs of a B2 block before all PHTs were revealed
+	ErrB2Incomplete = errors.New("B2 block has unrevealed PHTs")
+
+	// ErrUnknownReveal is returned for an MT revealing no PHT of the B1 block
+	ErrUnknownReveal = errors.New("MT reveals no PHT of the B1 block")
+
+	// ErrDuplicateReveal is returned for an MT of a PHT already revealed
+	ErrDuplicateReveal = errors.New("PHT already revealed")
+)
+
+// MTAssemblyResult is the outcome of adding one MT to a B2 assembler
+type MTAssemblyResult struct {
+	MT  *MTTransaction
+	Err error // Rejection reason, nil if the MT was accepted
+}
+
+// Accepted reports whether the MT was accepted
+func (r *MTAssemblyResult) Accepted() bool {
+	return r.Err == nil
+}
+
+// B2Assembler collects the MTs of a B2 block as reveals arrive, verifying each
+// against its PHT in the B1 block on arrival, so the B2 block is ready as soon as
+// the last PHT is revealed rather than verified in full when it is built
+type B2Assembler struct {
+	manager   *MTManager
+	b1Block   *B1Block
+	positions map[common.Hash]int // Block position by PHT hash
+	workers   int
+
+	mu      sync.Mutex
+	mts     []*MTTransaction // Accepted MTs by position, nil until revealed
+	claimed []bool           // Positions with an MT accepted or being verified
+	missing int
+	ready   chan struct{} // Closed once every PHT is revealed
+}
+
+// NewB2Assembler creates an assembler for the B2 block following a B1 block,
+// verifying streamed MTs on a worker pool sized by the MTWorkers configuration
+func NewB2Assembler(manager *MTManager, b1Block *B1Block) *B2Assembler {
+	workers := runtime.NumCPU()
+	if manager.config != nil && manager.config.MTWorkers > 0 {
+		workers = manager.config.MTWorkers
+	}
+
+	a := &B2Assembler{
+		manager:   manager,
+		b1Block:   b1Block,
+		positions: make(map[common.Hash]int, len(b1Block.PHTs)),
+		workers:   workers,
+		mts:       make([]*MTTransaction, len(b1Block.PHTs)),
+		claimed:   make([]bool, len(b1Block.PHTs)),
+		missing:   len(b1Block.PHTs),
+		ready:     make(chan struct{}),
+	}
+	for i, pht := range b1Block.PHTs {
+		a.positions[pht.Hash()] = i
+	}
+	if a.missing == 0 {
+		close(a.ready)
+	}
+	return a
+}
+
+// Add verifies an MT against its PHT and accepts it into the B2 block. Unrevealed
+// placeholders are checked against their PHT.
+func (a *B2Assembler) Add(mt *MTTransaction) error {
+	i, ok := a.positions[mt.PHTHash]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownReveal, mt.PHTHash.Hex())
+	}
+
+	// Claim the position so concurrent reveals of the same PHT are not verified twice
+	a.mu.Lock()
+	if a.claimed[i] {
+		a.mu.Unlock()
+		return fmt.Errorf("%w: MT %d (%s)", ErrDuplicateReveal, i, mt.TxHash.Hex())
+	}
+	a.claimed[i] = true
+	a.mu.Unlock()
+
+	verify := a.manager.VerifyMT
+	if mt.Unrevealed {
+		verify = a.manager.VerifyPlaceholder
+	}
+	err := verify(mt, a.b1Block.PHTs[i])
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		a.claimed[i] = false
+		return fmt.Errorf("MT %d (%s): %w", i, mt.TxHash.Hex(), withMTIndex(err, i))
+	}
+	a.mts[i] = mt
+	if a.missing--; a.missing == 0 {
+		close(a.ready)
+	}
+	return nil
+}
+
+// Run adds the MTs received on in until it is closed, the context is cancelled or
+// every PHT is revealed. The returned channel yields one result per MT read, in
+// completion order, and is closed once all of them were emitted.
+func (a *B2Assembler) Run(ctx context.Context, in <-chan *MTTransaction) <-chan MTAssemblyResult {
+	out := make(chan MTAssemblyResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < a.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var mt *MTTransaction
+				select {
+				case <-ctx.Done():
+					return
+				case <-a.ready:
+					return
+				case next, ok := <-in:
+					if !ok {
+						return
+					}
+					mt = next
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- MTAssemblyResult{MT: mt, Err: a.Add(mt)}:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// Ready returns a channel closed once every PHT of the B1 block is revealed
+func (a *B2Assembler) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// Missing returns the hashes of the PHTs not revealed yet, in block order
+func (a *B2Assembler) Missing() []common.Hash {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	missing := make([]common.Hash, 0, a.missing)
+	for i, mt := range a.mts {
+		if mt == nil {
+			missing = append(missing, a.b1Block.PHTs[i].Hash())
+		}
+	}
+	return missing
+}
+
+// Revealed returns the accepted MTs by block position, nil for PHTs not revealed yet
+func (a *B2Assembler) Revealed() []*MTTransaction {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]*MTTransaction(nil), a.mts...)
+}
+
+// MTs returns the MTs of the B2 block once every PHT is revealed
+func (a *B2Assembler) MTs() ([]*MTTransaction, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.missing > 0 {
+		return nil, fmt.Errorf("%w: %d of %d missing", ErrB2Incomplete, a.missing, len(a.mts))
+	}
+	return append([]*MTTransaction(nil), a.mts...), nil
+}
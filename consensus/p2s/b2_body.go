@@ -0,0 +1,467 @@
+package p2s
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// B2 bodies encode the MTs of a B2 block for storage and network transfer. An
+// encoded body is the format version byte, followed by the address dictionary,
+// the selector dictionary and the MT frames, each a uvarint count followed by
+// its entries. Addresses are 20 bytes and selectors 4 bytes. Each frame is a
+// uvarint length followed by the RLP of an MT whose recipient and sender are
+// dictionary references, and whose call data selector is one when it repeats
+// within the body.
+const (
+	b2BodyVersion = 1
+
+	selectorLength = 4
+)
+
+var (
+	// ErrInvalidB2Body is returned for a B2 body that cannot be decoded
+	ErrInvalidB2Body = errors.New("invalid B2 body")
+
+	// b2BodyPrefix + B2 hash -> encoded B2Body
+	b2BodyPrefix = []byte("p2s-b2-body-")
+)
+
+// B2Body is the list of MTs of a B2 block, in block order
+type B2Body struct {
+	MTs []*MTTransaction
+}
+
+// b2FrameRLP is the RLP layout of an MT in a B2 body: the MT layout with the
+// recipient and sender replaced by address dictionary indices, and the selector
+// by its selector dictionary index plus one, zero for call data kept whole
+type b2FrameRLP struct {
+	Version              uint64
+	RecipientRef         uint64
+	SenderRef            uint64
+	SelectorRef          uint64
+	Value                *big.Int
+	CallData             []byte
+	TxType               uint8
+	GasLimit             uint64
+	IsContractCreation   bool
+	AccountNonce         uint64
+	PHTHash              common.Hash
+	Proof                []byte
+	Timestamp            uint64
+	Blinding             []byte
+	AccessList           types.AccessList
+	BlobHashes           []common.Hash
+	MaxFeePerBlobGas     *big.Int
+	HiddenSet            uint16
+	FieldEncoding        uint8
+	GasPrice             *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	ValueBlinding        []byte
+	FieldOpenings        []fieldOpeningRLP
+	TxHash               common.Hash
+	Unrevealed           bool
+	SignedTx             []byte
+	RevealSignature      []byte
+
+	Rest []rlp.RawValue `rlp:"tail"` // Fields of later versions
+}
+
+// b2Dictionaries holds the addresses and selectors shared by the MTs of a body
+type b2Dictionaries struct {
+	addresses []common.Address
+	selectors [][selectorLength]byte
+
+	addressRefs  map[common.Address]uint64
+	selectorRefs map[[selectorLength]byte]uint64
+}
+
+// newB2Dictionaries collects every address of the MTs and the selectors used by
+// more than one of them, in order of first use
+func newB2Dictionaries(mts []*MTTransaction) *b2Dictionaries {
+	d := &b2Dictionaries{
+		addressRefs:  make(map[common.Address]uint64),
+		selectorRefs: make(map[[selectorLength]byte]uint64),
+	}
+	var (
+		selectors [][selectorLength]byte
+		uses      = make(map[[selectorLength]byte]int)
+	)
+	for _, mt := range mts {
+		for _, addr := range []common.Address{mt.Recipient, mt.Sender} {
+			if _, ok := d.addressRefs[addr]; !ok {
+				d.addressRefs[addr] = uint64(len(d.addresses))
+				d.addresses = append(d.addresses, addr)
+			}
+		}
+		if len(mt.CallData) >= selectorLength {
+			var selector [selectorLength]byte
+			copy(selector[:], mt.CallData)
+			if uses[selector]++; uses[selector] == 1 {
+				selectors = append(selectors, selector)
+			}
+		}
+	}
+	for _, selector := range selectors {
+		if uses[selector] > 1 {
+			d.selectors = append(d.selectors, selector)
+			d.selectorRefs[selector] = uint64(len(d.selectors))
+		}
+	}
+	return d
+}
+
+// frame returns the frame of an MT referencing the dictionaries
+func (d *b2Dictionaries) frame(mt *MTTransaction) (*b2FrameRLP, error) {
+	enc, err := newMTRLP(mt)
+	if err != nil {
+		return nil, err
+	}
+	frame := &b2FrameRLP{
+		Version:              enc.Version,
+		RecipientRef:         d.addressRefs[enc.Recipient],
+		SenderRef:            d.addressRefs[enc.Sender],
+		Value:                enc.Value,
+		CallData:             enc.CallData,
+		TxType:               enc.TxType,
+		GasLimit:             enc.GasLimit,
+		IsContractCreation:   enc.IsContractCreation,
+		AccountNonce:         enc.AccountNonce,
+		PHTHash:              enc.PHTHash,
+		Proof:                enc.Proof,
+		Timestamp:            enc.Timestamp,
+		Blinding:             enc.Blinding,
+		AccessList:           enc.AccessList,
+		BlobHashes:           enc.BlobHashes,
+		MaxFeePerBlobGas:     enc.MaxFeePerBlobGas,
+		HiddenSet:            enc.HiddenSet,
+		FieldEncoding:        enc.FieldEncoding,
+		GasPrice:             enc.GasPrice,
+		MaxFeePerGas:         enc.MaxFeePerGas,
+		MaxPriorityFeePerGas: enc.MaxPriorityFeePerGas,
+		ValueBlinding:        enc.ValueBlinding,
+		FieldOpenings:        enc.FieldOpenings,
+		TxHash:               enc.TxHash,
+		Unrevealed:           enc.Unrevealed,
+		SignedTx:             enc.SignedTx,
+		RevealSignature:      enc.RevealSignature,
+	}
+	if len(enc.CallData) >= selectorLength {
+		var selector [selectorLength]byte
+		copy(selector[:], enc.CallData)
+		if ref, ok := d.selectorRefs[selector]; ok {
+			frame.SelectorRef = ref
+			frame.CallData = enc.CallData[selectorLength:]
+		}
+	}
+	return frame, nil
+}
+
+// mt resolves the dictionary references of a frame and decodes its MT
+func (d *b2Dictionaries) mt(frame *b2FrameRLP) (*MTTransaction, error) {
+	if frame.RecipientRef >= uint64(len(d.addresses)) || frame.SenderRef >= uint64(len(d.addresses)) {
+		return nil, fmt.Errorf("%w: address reference out of range", ErrInvalidB2Body)
+	}
+	if frame.SelectorRef > uint64(len(d.selectors)) {
+		return nil, fmt.Errorf("%w: selector reference out of range", ErrInvalidB2Body)
+	}
+	callData := frame.CallData
+	if frame.SelectorRef > 0 {
+		selector := d.selectors[frame.SelectorRef-1]
+		callData = append(selector[:], frame.CallData...)
+	}
+
+	dec := &mtRLP{
+		Version:              frame.Version,
+		Recipient:            d.addresses[frame.RecipientRef],
+		Value:                frame.Value,
+		CallData:             callData,
+		TxType:               frame.TxType,
+		GasLimit:             frame.GasLimit,
+		IsContractCreation:   frame.IsContractCreation,
+		AccountNonce:         frame.AccountNonce,
+		PHTHash:              frame.PHTHash,
+		Proof:                frame.Proof,
+		Timestamp:            frame.Timestamp,
+		Blinding:             frame.Blinding,
+		AccessList:           frame.AccessList,
+		BlobHashes:           frame.BlobHashes,
+		MaxFeePerBlobGas:     frame.MaxFeePerBlobGas,
+		HiddenSet:            frame.HiddenSet,
+		FieldEncoding:        frame.FieldEncoding,
+		Sender:               d.addresses[frame.SenderRef],
+		GasPrice:             frame.GasPrice,
+		MaxFeePerGas:         frame.MaxFeePerGas,
+		MaxPriorityFeePerGas: frame.MaxPriorityFeePerGas,
+		ValueBlinding:        frame.ValueBlinding,
+		FieldOpenings:        frame.FieldOpenings,
+		TxHash:               frame.TxHash,
+		Unrevealed:           frame.Unrevealed,
+		SignedTx:             frame.SignedTx,
+		RevealSignature:      frame.RevealSignature,
+	}
+	mt := new(MTTransaction)
+	if err := dec.decode(mt); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidB2Body, err)
+	}
+	return mt, nil
+}
+
+// EncodeTo writes the encoded body to w
+func (b *B2Body) EncodeTo(w io.Writer) error {
+	for i, mt := range b.MTs {
+		if mt == nil {
+			return fmt.Errorf("nil MT %d in B2 body", i)
+		}
+	}
+	dict := newB2Dictionaries(b.MTs)
+
+	buf := []byte{b2BodyVersion}
+	buf = binary.AppendUvarint(buf, uint64(len(dict.addresses)))
+	for _, addr := range dict.addresses {
+		buf = append(buf, addr.Bytes()...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(dict.selectors)))
+	for _, selector := range dict.selectors {
+		buf = append(buf, selector[:]...)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(b.MTs)))
+
+	for i, mt := range b.MTs {
+		frame, err := dict.frame(mt)
+		if err != nil {
+			return fmt.Errorf("MT %d: %w", i, err)
+		}
+		encoded, err := rlp.EncodeToBytes(frame)
+		if err != nil {
+			return fmt.Errorf("MT %d: %w", i, err)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(encoded)))
+		buf = append(buf, encoded...)
+		if len(buf) > maxMTBatchSize {
+			return fmt.Errorf("%w: over %d bytes", ErrMTBatchTooLarge, maxMTBatchSize)
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// MarshalBinary returns the encoded body
+func (b *B2Body) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := b.EncodeTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a body encoded by MarshalBinary, rejecting trailing data
+func (b *B2Body) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	body, err := DecodeB2Body(r)
+	if err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidB2Body, r.Len())
+	}
+	*b = *body
+	return nil
+}
+
+// DecodeB2Body reads a whole body from r
+func DecodeB2Body(r io.Reader) (*B2Body, error) {
+	dec, err := NewB2BodyDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	body := new(B2Body)
+	for {
+		mt, err := dec.Next()
+		if err == io.EOF {
+			return body, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		body.MTs = append(body.MTs, mt)
+	}
+}
+
+// byteReader is a reader uvarints can be read from
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r    byteReader
+	size uint64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.r.Read(buf)
+	c.size += uint64(n)
+	return n, err
+}
+
+// ReadByte implements io.ByteReader
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.size++
+	}
+	return b, err
+}
+
+// B2BodyDecoder decodes the MTs of a body one at a time as they are read, so
+// receivers can start verifying reveals before the whole body has arrived
+type B2BodyDecoder struct {
+	r         *countingReader
+	dict      *b2Dictionaries
+	remaining uint64
+	err       error // Sticky error of a failed read
+}
+
+// NewB2BodyDecoder reads the header and dictionaries of a body from r. Readers
+// without ReadByte are buffered, so the decoder may read past the body.
+func NewB2BodyDecoder(r io.Reader) (*B2BodyDecoder, error) {
+	br, ok := r.(byteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &B2BodyDecoder{r: &countingReader{r: br}, dict: new(b2Dictionaries)}
+
+	version, err := d.r.ReadByte()
+	if err != nil {
+		return nil, d.fail(err)
+	}
+	if version != b2BodyVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrInvalidB2Body, version)
+	}
+
+	count, err := d.readCount(common.AddressLength)
+	if err != nil {
+		return nil, err
+	}
+	d.dict.addresses = make([]common.Address, count)
+	for i := range d.dict.addresses {
+		if err := d.readFull(d.dict.addresses[i][:]); err != nil {
+			return nil, err
+		}
+	}
+	if count, err = d.readCount(selectorLength); err != nil {
+		return nil, err
+	}
+	d.dict.selectors = make([][selectorLength]byte, count)
+	for i := range d.dict.selectors {
+		if err := d.readFull(d.dict.selectors[i][:]); err != nil {
+			return nil, err
+		}
+	}
+	// Every frame is at least its length byte and a list header
+	if d.remaining, err = d.readCount(2); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Len returns the number of MTs left to decode
+func (d *B2BodyDecoder) Len() int {
+	return int(d.remaining)
+}
+
+// Next decodes the next MT of the body, returning io.EOF after the last one
+func (d *B2BodyDecoder) Next() (*MTTransaction, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.remaining == 0 {
+		return nil, io.EOF
+	}
+	length, err := d.readCount(1)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, length)
+	if err := d.readFull(encoded); err != nil {
+		return nil, err
+	}
+	var frame b2FrameRLP
+	if err := rlp.DecodeBytes(encoded, &frame); err != nil {
+		d.err = fmt.Errorf("%w: %v", ErrInvalidB2Body, err)
+		return nil, d.err
+	}
+	mt, err := d.dict.mt(&frame)
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	d.remaining--
+	return mt, nil
+}
+
+// readCount reads a uvarint count of items of the given size, rejecting counts
+// that cannot fit in the rest of a body of maxMTBatchSize bytes
+func (d *B2BodyDecoder) readCount(itemSize uint64) (uint64, error) {
+	count, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, d.fail(err)
+	}
+	if d.r.size > maxMTBatchSize || count > (maxMTBatchSize-d.r.size)/itemSize {
+		d.err = fmt.Errorf("%w: %d items of %d bytes", ErrMTBatchTooLarge, count, itemSize)
+		return 0, d.err
+	}
+	return count, nil
+}
+
+// readFull fills buf from the body
+func (d *B2BodyDecoder) readFull(buf []byte) error {
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return d.fail(err)
+	}
+	return nil
+}
+
+// fail records a read error, reporting a body ending early as truncated
+func (d *B2BodyDecoder) fail(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: truncated", ErrInvalidB2Body)
+	}
+	d.err = err
+	return err
+}
+
+// WriteB2Body stores the body of a B2 block
+func WriteB2Body(db ethdb.KeyValueWriter, b2Hash common.Hash, body *B2Body) error {
+	data, err := body.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return db.Put(append(common.CopyBytes(b2BodyPrefix), b2Hash.Bytes()...), data)
+}
+
+// ReadB2Body loads the body of a B2 block
+func ReadB2Body(db ethdb.KeyValueReader, b2Hash common.Hash) (*B2Body, error) {
+	data, err := db.Get(append(common.CopyBytes(b2BodyPrefix), b2Hash.Bytes()...))
+	if err != nil {
+		return nil, err
+	}
+	body := new(B2Body)
+	if err := body.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
@@ -16,6 +16,10 @@ type B1Block struct {
 	MEVScore        float64            `json:"mevScore"`        // MEV protection score
 	DetectedAttacks []string           `json:"detectedAttacks"` // Detected MEV attacks
 	ValidatorSig    []byte             `json:"validatorSig"`    // Validator signature
+	ProposerSig     []byte             `json:"proposerSig"`     // Producer's seal over the header
+	CommitteeSeal   *ThresholdSeal     `json:"committeeSeal,omitempty"` // Committee seal in committee sealing mode
+	Coverage        *DetectionCoverage `json:"coverage,omitempty"`      // Detection modules behind MEVScore
+	RevealEvidence  []*InvalidRevealEvidence `json:"revealEvidence,omitempty"` // Fraud evidence slashed by this block
 	Timestamp       uint64             `json:"timestamp"`
 	BlockHash       common.Hash        `json:"blockHash"`
 }
@@ -27,8 +31,12 @@ type B2Block struct {
 	BlockType       uint8              `json:"blockType"`       // 2 for B2
 	B1BlockHash     common.Hash        `json:"b1BlockHash"`     // Reference to B1 block
 	ValidatorSig    []byte             `json:"validatorSig"`    // Validator signature
+	ProposerSig     []byte             `json:"proposerSig"`     // Producer's seal over the header
+	Attestations    []*B1Attestation   `json:"attestations,omitempty"` // Committee quorum attesting to the B1 block
 	Timestamp       uint64             `json:"timestamp"`
 	BlockHash       common.Hash        `json:"blockHash"`
+	ProofsCompacted bool               `json:"proofsCompacted,omitempty"` // Per-MT proofs replaced by a pair attestation
+	BatchProof      []byte             `json:"batchProof,omitempty"`      // Aggregated inclusion proof of all MTs, checked in place of per-MT proofs
 }
 
 // P2SCache caches P2S-specific data
@@ -0,0 +1,435 @@
+package p2s
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	blsfr "github.com/consensys/gnark-crypto/ecc/bls12-381/fr"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BLS keys use the minimal public key size variant: public keys are compressed
+// G1 points and signatures compressed G2 points on BLS12-381
+const (
+	BLSPublicKeyLength = bls12381.SizeOfG1AffineCompressed
+	BLSSignatureLength = bls12381.SizeOfG2AffineCompressed
+
+	aggregateSignatureVersion = 1
+
+	// Heights whose BLS key set is kept for block signatures
+	blockKeySetLimit = 1024
+)
+
+// Domain separation tags of block signatures and proofs of possession
+var (
+	blsSignatureDST  = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+	blsPossessionDST = []byte("BLS_POP_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+)
+
+var (
+	// ErrInvalidBLSKey is returned for a malformed BLS public key or one without a
+	// valid proof of possession
+	ErrInvalidBLSKey = errors.New("invalid BLS public key")
+
+	// ErrInvalidAggregateSignature is returned for an aggregate block signature that
+	// is malformed, does not verify or lacks quorum
+	ErrInvalidAggregateSignature = errors.New("invalid aggregate signature")
+)
+
+// BLSSecretKey is a validator's BLS signing key
+type BLSSecretKey struct {
+	scalar *big.Int
+}
+
+// GenerateBLSKey creates a random BLS signing key
+func GenerateBLSKey() (*BLSSecretKey, error) {
+	var scalar blsfr.Element
+	for scalar.IsZero() {
+		if _, err := scalar.SetRandom(); err != nil {
+			return nil, err
+		}
+	}
+	return &BLSSecretKey{scalar: scalar.BigInt(new(big.Int))}, nil
+}
+
+// BLSSecretKeyFromBytes decodes a key encoded by Bytes, rejecting scalars outside
+// the field and zero
+func BLSSecretKeyFromBytes(data []byte) (*BLSSecretKey, error) {
+	if len(data) != blsfr.Bytes {
+		return nil, fmt.Errorf("BLS secret key length %d", len(data))
+	}
+	scalar := new(big.Int).SetBytes(data)
+	if scalar.Sign() == 0 || scalar.Cmp(blsfr.Modulus()) >= 0 {
+		return nil, errors.New("BLS secret key out of range")
+	}
+	return &BLSSecretKey{scalar: scalar}, nil
+}
+
+// Bytes returns the big-endian encoding of the key's scalar
+func (k *BLSSecretKey) Bytes() []byte {
+	return k.scalar.FillBytes(make([]byte, blsfr.Bytes))
+}
+
+// PublicKey returns the compressed public key
+func (k *BLSSecretKey) PublicKey() []byte {
+	_, _, g1, _ := bls12381.Generators()
+	var pub bls12381.G1Affine
+	pub.ScalarMultiplication(&g1, k.scalar)
+	encoded := pub.Bytes()
+	return encoded[:]
+}
+
+// sign signs a message under a domain separation tag
+func (k *BLSSecretKey) sign(msg, dst []byte) ([]byte, error) {
+	point, err := bls12381.HashToG2(msg, dst)
+	if err != nil {
+		return nil, err
+	}
+	var sig bls12381.G2Affine
+	sig.ScalarMultiplication(&point, k.scalar)
+	encoded := sig.Bytes()
+	return encoded[:], nil
+}
+
+// Sign signs a block signing hash
+func (k *BLSSecretKey) Sign(hash common.Hash) ([]byte, error) {
+	return k.sign(hash.Bytes(), blsSignatureDST)
+}
+
+// ProvePossession signs the key's own public key, proving to the registry that
+// the key is not derived from other validators' keys to forge aggregates
+func (k *BLSSecretKey) ProvePossession() ([]byte, error) {
+	return k.sign(k.PublicKey(), blsPossessionDST)
+}
+
+// decodeBLSPublicKey decodes a compressed public key, rejecting points outside the
+// subgroup and the identity
+func decodeBLSPublicKey(data []byte) (*bls12381.G1Affine, error) {
+	if len(data) != BLSPublicKeyLength {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidBLSKey, len(data))
+	}
+	pub := new(bls12381.G1Affine)
+	if _, err := pub.SetBytes(data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBLSKey, err)
+	}
+	if pub.IsInfinity() {
+		return nil, fmt.Errorf("%w: identity", ErrInvalidBLSKey)
+	}
+	return pub, nil
+}
+
+// decodeBLSSignature decodes a compressed signature, rejecting points outside the
+// subgroup
+func decodeBLSSignature(data []byte) (*bls12381.G2Affine, error) {
+	if len(data) != BLSSignatureLength {
+		return nil, fmt.Errorf("signature length %d", len(data))
+	}
+	sig := new(bls12381.G2Affine)
+	if _, err := sig.SetBytes(data); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// verifyBLS checks e(pub, H(msg)) == e(g1, sig)
+func verifyBLS(pub *bls12381.G1Affine, msg, dst []byte, sig *bls12381.G2Affine) (bool, error) {
+	point, err := bls12381.HashToG2(msg, dst)
+	if err != nil {
+		return false, err
+	}
+	_, _, g1, _ := bls12381.Generators()
+	var negG1 bls12381.G1Affine
+	negG1.Neg(&g1)
+	return bls12381.PairingCheck([]bls12381.G1Affine{*pub, negG1}, []bls12381.G2Affine{point, *sig})
+}
+
+// VerifyBLSPossession checks a public key's proof of possession
+func VerifyBLSPossession(publicKey, proof []byte) error {
+	pub, err := decodeBLSPublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := decodeBLSSignature(proof)
+	if err != nil {
+		return fmt.Errorf("%w: proof of possession: %v", ErrInvalidBLSKey, err)
+	}
+	if ok, err := verifyBLS(pub, publicKey, blsPossessionDST, sig); err != nil || !ok {
+		return fmt.Errorf("%w: proof of possession does not verify", ErrInvalidBLSKey)
+	}
+	return nil
+}
+
+// AggregateBLSSignatures adds up signatures of the same message into one
+func AggregateBLSSignatures(signatures [][]byte) ([]byte, error) {
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("%w: no signatures", ErrInvalidAggregateSignature)
+	}
+	var sum bls12381.G2Jac
+	for i, data := range signatures {
+		sig, err := decodeBLSSignature(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: signature %d: %v", ErrInvalidAggregateSignature, i, err)
+		}
+		sum.AddMixed(sig)
+	}
+	var aggregate bls12381.G2Affine
+	aggregate.FromJacobian(&sum)
+	encoded := aggregate.Bytes()
+	return encoded[:], nil
+}
+
+// BLSKey is a validator's registered BLS public key and the stake it signs with
+type BLSKey struct {
+	Validator common.Address `json:"validator"`
+	PublicKey hexutil.Bytes  `json:"publicKey"`
+	Stake     *big.Int       `json:"stake"`
+}
+
+// BLSKeySet is the set of keys aggregate signatures are checked against, in
+// validator address order; bit i of a participation bitmap stands for key i
+type BLSKeySet struct {
+	Keys []*BLSKey `json:"keys"`
+}
+
+// index returns the position of a validator's key, -1 if it has none
+func (s *BLSKeySet) index(validator common.Address) int {
+	i := sort.Search(len(s.Keys), func(i int) bool {
+		return bytes.Compare(s.Keys[i].Validator[:], validator[:]) >= 0
+	})
+	if i < len(s.Keys) && s.Keys[i].Validator == validator {
+		return i
+	}
+	return -1
+}
+
+// RegisterBLSKey registers a validator's BLS public key with its proof of
+// possession, replacing any key registered before. Keys cannot be shared.
+func (v *ValidatorManager) RegisterBLSKey(address common.Address, publicKey, proof []byte) error {
+	if err := VerifyBLSPossession(publicKey, proof); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, exists := v.validators[address]
+	if !exists {
+		return errors.New("validator not found")
+	}
+	for other, registered := range v.validators {
+		if other != address && bytes.Equal(registered.BLSPublicKey, publicKey) {
+			return fmt.Errorf("%w: registered by %s", ErrInvalidBLSKey, other.Hex())
+		}
+	}
+	validator.BLSPublicKey = common.CopyBytes(publicKey)
+	v.persist(address)
+	return nil
+}
+
+// BLSKeySet returns the registered keys of the selectable validators, weighted
+// by effective stake
+func (v *ValidatorManager) BLSKeySet() *BLSKeySet {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := &BLSKeySet{}
+	for _, address := range sortedActiveValidators(v.validators) {
+		validator := v.validators[address]
+		if len(validator.BLSPublicKey) == 0 {
+			continue
+		}
+		keys.Keys = append(keys.Keys, &BLSKey{
+			Validator: address,
+			PublicKey: common.CopyBytes(validator.BLSPublicKey),
+			Stake:     validator.EffectiveStake(),
+		})
+	}
+	return keys
+}
+
+// blockKeySet returns the BLS key set signing blocks at a height. It is taken
+// from the validator set when the height is first produced or validated and kept
+// from then on, so later key rotations and stake changes do not invalidate
+// signed blocks.
+func (p *P2SConsensus) blockKeySet(number uint64) *BLSKeySet {
+	if keys, ok := p.keySets.Get(number); ok {
+		return keys
+	}
+	keys := p.validatorMgr.BLSKeySet()
+	p.keySets.Add(number, keys)
+	return keys
+}
+
+// checkValidatorSig verifies a block's aggregate validator signature against the
+// key set of its height. Validators sign blocks after they are produced, so an
+// unsigned block is only rejected when block signatures are required.
+func (p *P2SConsensus) checkValidatorSig(number uint64, hash common.Hash, validatorSig []byte) error {
+	if len(validatorSig) == 0 && !p.config.RequireBlockSignatures {
+		return nil
+	}
+	return verifyValidatorSig(hash, validatorSig, p.blockKeySet(number))
+}
+
+// AggregateSignature is a block signature of several validators: the sum of their
+// signatures and a bitmap of who signed over a BLSKeySet
+type AggregateSignature struct {
+	Bitmap    hexutil.Bytes `json:"bitmap"`
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// Signed reports whether the validator at index i of the key set signed
+func (a *AggregateSignature) Signed(i int) bool {
+	return i >= 0 && i/8 < len(a.Bitmap) && a.Bitmap[i/8]&(1<<uint(i%8)) != 0
+}
+
+// NewAggregateSignature aggregates the signatures of validators in a key set
+// over the same signing hash
+func NewAggregateSignature(keys *BLSKeySet, signatures map[common.Address][]byte) (*AggregateSignature, error) {
+	aggregate := &AggregateSignature{Bitmap: make([]byte, (len(keys.Keys)+7)/8)}
+	signers := make([]common.Address, 0, len(signatures))
+	for signer := range signatures {
+		signers = append(signers, signer)
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i][:], signers[j][:]) < 0
+	})
+	ordered := make([][]byte, 0, len(signers))
+	for _, signer := range signers {
+		i := keys.index(signer)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: %s has no registered key", ErrInvalidAggregateSignature, signer.Hex())
+		}
+		aggregate.Bitmap[i/8] |= 1 << uint(i%8)
+		ordered = append(ordered, signatures[signer])
+	}
+	sig, err := AggregateBLSSignatures(ordered)
+	if err != nil {
+		return nil, err
+	}
+	aggregate.Signature = sig
+	return aggregate, nil
+}
+
+// Verify checks an aggregate signature of a signing hash against the key set.
+// The signers must hold more than two thirds of the key set's stake.
+func (s *BLSKeySet) Verify(hash common.Hash, aggregate *AggregateSignature) error {
+	if aggregate == nil {
+		return fmt.Errorf("%w: missing", ErrInvalidAggregateSignature)
+	}
+	if len(aggregate.Bitmap) != (len(s.Keys)+7)/8 {
+		return fmt.Errorf("%w: bitmap of %d bytes for %d keys", ErrInvalidAggregateSignature, len(aggregate.Bitmap), len(s.Keys))
+	}
+	if extra := len(s.Keys) % 8; extra != 0 && aggregate.Bitmap[len(aggregate.Bitmap)-1]>>uint(extra) != 0 {
+		return fmt.Errorf("%w: bits set past the key set", ErrInvalidAggregateSignature)
+	}
+	sig, err := decodeBLSSignature(aggregate.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAggregateSignature, err)
+	}
+
+	var sum bls12381.G1Jac
+	signed, total := new(big.Int), new(big.Int)
+	for i, key := range s.Keys {
+		total.Add(total, key.Stake)
+		if !aggregate.Signed(i) {
+			continue
+		}
+		pub, err := decodeBLSPublicKey(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		sum.AddMixed(pub)
+		signed.Add(signed, key.Stake)
+	}
+	if signed.Sign() == 0 || new(big.Int).Mul(signed, big.NewInt(3)).Cmp(new(big.Int).Mul(total, big.NewInt(2))) <= 0 {
+		return fmt.Errorf("%w: signers hold %v of %v stake", ErrInvalidAggregateSignature, signed, total)
+	}
+	var pub bls12381.G1Affine
+	pub.FromJacobian(&sum)
+	if ok, err := verifyBLS(&pub, hash.Bytes(), blsSignatureDST, sig); err != nil || !ok {
+		return fmt.Errorf("%w: pairing check failed", ErrInvalidAggregateSignature)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the aggregate as carried in a block's ValidatorSig: a
+// version byte, the bitmap length as a uvarint, the bitmap and the signature
+func (a *AggregateSignature) MarshalBinary() ([]byte, error) {
+	if len(a.Signature) != BLSSignatureLength {
+		return nil, fmt.Errorf("%w: signature length %d", ErrInvalidAggregateSignature, len(a.Signature))
+	}
+	length := make([]byte, binary.MaxVarintLen64)
+	data := append([]byte{aggregateSignatureVersion}, length[:binary.PutUvarint(length, uint64(len(a.Bitmap)))]...)
+	data = append(data, a.Bitmap...)
+	return append(data, a.Signature...), nil
+}
+
+// UnmarshalBinary decodes an aggregate encoded by MarshalBinary
+func (a *AggregateSignature) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	version, err := reader.ReadByte()
+	if err != nil || version != aggregateSignatureVersion {
+		return fmt.Errorf("%w: unknown encoding", ErrInvalidAggregateSignature)
+	}
+	length, err := binary.ReadUvarint(reader)
+	if err != nil || length != uint64(reader.Len()-BLSSignatureLength) {
+		return fmt.Errorf("%w: truncated", ErrInvalidAggregateSignature)
+	}
+	a.Bitmap = make([]byte, length)
+	a.Signature = make([]byte, BLSSignatureLength)
+	if _, err := io.ReadFull(reader, a.Bitmap); err != nil {
+		return fmt.Errorf("%w: truncated", ErrInvalidAggregateSignature)
+	}
+	if _, err := io.ReadFull(reader, a.Signature); err != nil {
+		return fmt.Errorf("%w: truncated", ErrInvalidAggregateSignature)
+	}
+	return nil
+}
+
+// blockSigningHash returns the hash validators sign for a block of a type
+func blockSigningHash(blockType uint8, header common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("p2s-block-sig"), []byte{blockType}, header.Bytes())
+}
+
+// SigningHash returns the hash validators sign for the B1 block
+func (b *B1Block) SigningHash() common.Hash {
+	return blockSigningHash(1, b.Header.Hash())
+}
+
+// SigningHash returns the hash validators sign for the B2 block
+func (b *B2Block) SigningHash() common.Hash {
+	return blockSigningHash(2, b.Header.Hash())
+}
+
+// verifyValidatorSig checks a block's ValidatorSig as an aggregate signature
+func verifyValidatorSig(hash common.Hash, validatorSig []byte, keys *BLSKeySet) error {
+	aggregate := new(AggregateSignature)
+	if err := aggregate.UnmarshalBinary(validatorSig); err != nil {
+		return err
+	}
+	return keys.Verify(hash, aggregate)
+}
+
+// ValidateSignature checks the B1 block's aggregate validator signature
+func (b *B1Block) ValidateSignature(keys *BLSKeySet) error {
+	if b.Header == nil {
+		return errors.New("missing header")
+	}
+	return verifyValidatorSig(b.SigningHash(), b.ValidatorSig, keys)
+}
+
+// ValidateSignature checks the B2 block's aggregate validator signature
+func (b *B2Block) ValidateSignature(keys *BLSKeySet) error {
+	if b.Header == nil {
+		return errors.New("missing header")
+	}
+	return verifyValidatorSig(b.SigningHash(), b.ValidatorSig, keys)
+}
@@ -0,0 +1,89 @@
+package p2s
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Commitment opening errors
+var (
+	ErrOpeningNotFound = errors.New("commitment opening not found")
+	ErrMissingOpening  = errors.New("MT carries no commitment opening")
+	ErrInvalidOpening  = errors.New("invalid commitment opening")
+)
+
+// CommitmentOpening is the message and randomness that open a commitment. The
+// sender keeps it until B2 and transmits the randomness in the MT; the message is
+// recomputed from the revealed fields.
+type CommitmentOpening struct {
+	Commitment []byte   `json:"commitment"`
+	Data       [][]byte `json:"data"`     // Committed message items
+	Blinding   []byte   `json:"blinding"` // Commitment randomness
+}
+
+// Verify checks that an opening opens its commitment under a scheme
+func (o *CommitmentOpening) Verify(scheme CommitmentScheme) error {
+	if o == nil || len(o.Blinding) == 0 {
+		return ErrMissingOpening
+	}
+	if !scheme.Verify(o.Commitment, o.Blinding, o.Data...) {
+		return ErrInvalidOpening
+	}
+	return nil
+}
+
+// OpeningStore keeps the openings of commitments a node created, keyed by
+// commitment, until their MTs are revealed
+type OpeningStore struct {
+	openings map[string]*CommitmentOpening
+	mu       sync.RWMutex
+}
+
+// NewOpeningStore creates an empty opening store
+func NewOpeningStore() *OpeningStore {
+	return &OpeningStore{
+		openings: make(map[string]*CommitmentOpening),
+	}
+}
+
+// Put stores an opening under its commitment
+func (s *OpeningStore) Put(opening *CommitmentOpening) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.openings[string(opening.Commitment)] = opening
+}
+
+// Open returns the opening of a commitment
+func (s *OpeningStore) Open(commitment []byte) (*CommitmentOpening, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	opening, ok := s.openings[string(commitment)]
+	if !ok {
+		return nil, ErrOpeningNotFound
+	}
+	return &CommitmentOpening{
+		Commitment: common.CopyBytes(opening.Commitment),
+		Data:       append([][]byte{}, opening.Data...),
+		Blinding:   common.CopyBytes(opening.Blinding),
+	}, nil
+}
+
+// Delete drops the opening of a revealed commitment
+func (s *OpeningStore) Delete(commitment []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.openings, string(commitment))
+}
+
+// Len returns the number of stored openings
+func (s *OpeningStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.openings)
+}
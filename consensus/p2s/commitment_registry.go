@@ -0,0 +1,86 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownCommitmentScheme is returned when no scheme is registered under the configured name
+	ErrUnknownCommitmentScheme = errors.New("unknown commitment scheme")
+
+	// ErrCommitmentSchemeRegistered is returned when a scheme name is registered twice
+	ErrCommitmentSchemeRegistered = errors.New("commitment scheme already registered")
+)
+
+// CommitmentSchemeConstructor creates a commitment scheme from the P2S configuration
+type CommitmentSchemeConstructor func(config *P2SConfig) (CommitmentScheme, error)
+
+var (
+	commitmentSchemes   = make(map[string]CommitmentSchemeConstructor)
+	commitmentSchemesMu sync.RWMutex
+)
+
+func init() {
+	RegisterCommitmentScheme(CommitmentSchemePedersen, func(*P2SConfig) (CommitmentScheme, error) {
+		return NewPedersenCommitment(), nil
+	})
+	RegisterCommitmentScheme(CommitmentSchemeKZG, func(*P2SConfig) (CommitmentScheme, error) {
+		return NewKZGCommitment(), nil
+	})
+	RegisterCommitmentScheme(CommitmentSchemeTimelock, func(config *P2SConfig) (CommitmentScheme, error) {
+		return NewTimelockCommitment(timelockSquarings(config)), nil
+	})
+	RegisterCommitmentScheme(CommitmentSchemeMiMC, func(*P2SConfig) (CommitmentScheme, error) {
+		return NewMiMCCommitment(), nil
+	})
+}
+
+// RegisterCommitmentScheme makes a commitment scheme selectable by name via
+// P2SConfig.CommitmentScheme. It is typically called from an init function.
+func RegisterCommitmentScheme(name string, constructor CommitmentSchemeConstructor) error {
+	if name == "" || constructor == nil {
+		return errors.New("commitment scheme needs a name and constructor")
+	}
+
+	commitmentSchemesMu.Lock()
+	defer commitmentSchemesMu.Unlock()
+
+	if _, exists := commitmentSchemes[name]; exists {
+		return fmt.Errorf("%w: %s", ErrCommitmentSchemeRegistered, name)
+	}
+	commitmentSchemes[name] = constructor
+	return nil
+}
+
+// CommitmentSchemes returns the names of all registered commitment schemes
+func CommitmentSchemes() []string {
+	commitmentSchemesMu.RLock()
+	defer commitmentSchemesMu.RUnlock()
+
+	names := make([]string, 0, len(commitmentSchemes))
+	for name := range commitmentSchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewCommitmentScheme creates the commitment scheme named by the configuration.
+// An empty name selects Pedersen.
+func NewCommitmentScheme(config *P2SConfig) (CommitmentScheme, error) {
+	name := CommitmentSchemePedersen
+	if config != nil && config.CommitmentScheme != "" {
+		name = config.CommitmentScheme
+	}
+
+	commitmentSchemesMu.RLock()
+	constructor, exists := commitmentSchemes[name]
+	commitmentSchemesMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCommitmentScheme, name)
+	}
+	return constructor(config)
+}
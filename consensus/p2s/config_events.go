@@ -0,0 +1,30 @@
+package p2s
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// ConfigChangeEvent is emitted whenever a configuration parameter is changed at runtime
+type ConfigChangeEvent struct {
+	Parameter string      `json:"parameter"`
+	Old       interface{} `json:"old"`
+	New       interface{} `json:"new"`
+	Time      time.Time   `json:"time"`
+}
+
+// SubscribeConfigChanges subscribes to runtime configuration changes
+func (p *P2SConsensus) SubscribeConfigChanges(ch chan<- ConfigChangeEvent) event.Subscription {
+	return p.configFeed.Subscribe(ch)
+}
+
+// emitConfigChange notifies subscribers of a configuration change
+func (p *P2SConsensus) emitConfigChange(parameter string, old, new interface{}) {
+	p.configFeed.Send(ConfigChangeEvent{
+		Parameter: parameter,
+		Old:       old,
+		New:       new,
+		Time:      time.Now(),
+	})
+}
@@ -1,15 +1,25 @@
 package p2s
 
 import (
+	"context"
+	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"math/big"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"google.golang.org/grpc"
 )
 
 // Consensus implements the P2S (Proposer in 2 Steps) consensus mechanism
@@ -22,6 +32,62 @@ type Consensus struct {
 	mtManager    *MTManager
 	validatorMgr *ValidatorManager
 	mevDetector  *MEVDetector
+	privacyGuard *PrivacyGuard
+	watchdog     *Watchdog // Never replaced, so usable without the lock
+	mevHistory   *MEVHistory
+	payments     *PaymentLedger
+	slashing     *SlashingManager
+	evidence     *EvidenceCollector
+	slots        *SlotTimer
+	admission    *AdmissionPolicy
+	governance   *GovernanceGuard
+	maintenance  *MaintenanceMode
+	corpus       *CalibrationCorpus
+	bounties     *BountyBoard
+	halt         *EmergencyHalt
+	revealIndex  *RevealIndex
+	receipts     *PHTReceiptStore
+	reveals      *RevealTracker
+	pipeline     *PHTPipeline
+	events       *EventBus
+	decryptor    *ThresholdDecryptor
+	attestations *AttestationPool
+	
+	// Staking contract watcher, nil when validators are managed through the API
+	staking *StakingWatcher
+	
+	// Signer of this node's validator, nil when the node does not sign
+	signer ValidatorSigner
+	
+	// Remote scoring service, nil to score PHTs in process
+	remoteDetector *RemoteDetector
+	
+	// Persistence
+	db ethdb.KeyValueStore
+	
+	// Runtime configuration change notifications
+	configFeed event.Feed
+	
+	// Chain state for state-aware MEV detection, optional
+	stateReader StateReader
+	
+	// PHTs left unrevealed by the last B2 block, carried into the next B1 block
+	// built on it, and how often each PHT was carried so far
+	carried     []*PHTTransaction
+	carriedFrom common.Hash
+	carries     map[common.Hash]int
+	
+	// Verified fraud evidence waiting for inclusion in the next B1 block
+	revealEvidence []*InvalidRevealEvidence
+	
+	// MTs streamed in for B2 blocks not built yet, by B1 block hash
+	assemblers map[common.Hash]*B2Assembler
+	
+	// BLS key sets blocks are signed with, by height
+	keySets *lru.Cache[uint64, *BLSKeySet]
+	
+	// Blocks whose slot was closed, so missed slots are recorded once per block
+	closedSlots *lru.Cache[common.Hash, bool]
 	
 	// Configuration
 	config *Config
@@ -35,6 +101,9 @@ type Consensus struct {
 
 // Config contains P2S-specific configuration
 type Config struct {
+	// Network the engine runs on; PHTs are bound to it. Nil accepts any chain ID.
+	ChainID *big.Int
+	
 	// Block time configuration
 	B1BlockTime time.Duration
 	B2BlockTime time.Duration
@@ -44,12 +113,137 @@ type Config struct {
 	MaxMEVScore float64
 	
 	// Validator configuration
-	MinStake      *big.Int
-	MaxValidators int
+	MinStake          *big.Int
+	MaxValidators     int
+	ProposerFallbacks int    // Backup proposers taking over a slot in turn, each after a further slot time
+	
+	// B2 blocks are proposed by an independently selected validator other than the
+	// B1 proposer, which hands it the PHT openings
+	SeparateB2Proposer bool
+	ProposerSelection string // "weighted", "round-robin" or "priority"
+	
+	// Reputation curve, in blocks; zero keeps reputation until the next update
+	ReputationDecayHalfLife    uint64 // Halves reputation above neutral
+	ReputationRecoveryHalfLife uint64 // Halves the deficit below neutral after penalties
+	
+	// Blocks between validator set snapshots in an attached database, 0 to only write changes through
+	ValidatorSnapshotInterval uint64
+	
+	// Jailing configuration; jailed validators are excluded from selection
+	JailMissedSlots uint64 // Consecutive missed slots that jail a validator, 0 to never jail for misses
+	JailDuration    uint64 // Blocks before a jailed validator may unjail
 	
 	// Cryptographic parameters
-	CommitmentScheme string
-	ProofSystem      string
+	CommitmentScheme string // Registered commitment scheme name, e.g. "pedersen", "kzg", "timelock" or "mimc"
+	ProofSystem      string // Registered MT proof system name, e.g. "merkle", "smt", "verkle" or "groth16"
+	ProofSystemHistory []string // Proof systems of earlier forks, still verified for historical blocks
+	
+	// SNARK proof system configuration; groth16 proofs open "mimc" commitments
+	SNARKSetupDir         string      // Directory holding the trusted setup artifacts
+	SNARKVerifyingKeyHash common.Hash // Expected Keccak256 hash of the verifying key, zero to accept any
+	
+	// Squaring rate of the fastest expected time-lock solver; puzzles take a B1 interval at this rate
+	TimelockSquaringsPerSecond uint64
+	
+	// MEV analysis configuration
+	MEVAnalysisWorkers int // Worker pool size for batch MEV analysis, 0 for NumCPU
+	
+	// PHT creation configuration
+	PHTWorkers int // Worker pool size for batch PHT creation and validation, 0 for NumCPU
+	
+	// MT verification configuration
+	MTWorkers int // Worker pool size for B2 block MT verification, 0 for NumCPU
+	ProofCacheSize int // MT proofs cached by PHT hash across building and validation, 0 for the default
+	RequireRevealSignatures bool // Reject MTs without a reveal signature of their PHT sender
+	RequireBlockSignatures  bool // Reject blocks without an aggregate validator signature
+	
+	// Remote scoring configuration
+	RemoteScoringTimeout   time.Duration // Per-call timeout before falling back to local scoring
+	RemoteScoringBatchSize int           // PHTs sent per remote scoring call
+	
+	// EVM simulation-based detection (expensive, disabled by default)
+	EnableMEVSimulation       bool
+	SimulationImpactThreshold float64 // Relative price impact above which a PHT is flagged
+	
+	// Mempool stress configuration
+	GasSpikeFactor      float64 // Gas price or volume multiple of baseline treated as a spike
+	MempoolStressWeight float64 // Block MEV score removed under full mempool stress
+	
+	// Watchdog configuration
+	WatchdogStallSlots uint64 // Slots without B1 or B2 before recovery is attempted
+	
+	// Maintenance configuration
+	MaintenanceHandoffSlots uint64 // Slots ahead whose proposal duties are handed off when entering maintenance
+	
+	// B1 sealing configuration
+	B1SealingMode     string // "single" or "committee"
+	SealCommitteeSize int
+	SealThreshold     int
+	
+	// B1 attestation configuration; B2 blocks need a quorum of committee attestations to their B1 block
+	AttestationCommitteeSize int // Attesters per slot, 0 to finalize B2 blocks without attestations
+	AttestationQuorum        int // Attestations required, 0 for more than two thirds of the committee
+	
+	// Pool admission rules
+	MinPHTGasPrice          *big.Int // Fee floor for PHTs
+	MaxPendingPHTsPerSender int      // Per-sender cap on pending PHTs, 0 for no cap
+	PHTBond                 *big.Int // Bond a sender must hold to submit PHTs, nil or 0 for none
+	MaxPHTCallDataSize      int      // Maximum call data size in bytes, 0 for no limit
+	MaxPHTValue             *big.Int // Cap on hidden values, enforced on B1 with range proofs; nil for none
+	PHTPriceBump            uint64   // Minimum gas price bump in percent for a PHT to replace an unrevealed one
+	RequireNonceProofs      bool     // Reject PHTs whose anti-MEV nonce comes without a VRF proof
+	SealHiddenFields        bool     // Encrypt PHT hidden fields to the threshold committee key at creation
+	
+	// Fields hidden until B2 beyond the recipient, value, call data, type and gas
+	// limit, e.g. "gasPrice" or "sender"
+	HiddenFields []string
+	
+	// Validator payment configuration
+	EpochLength uint64 // Blocks per payment epoch
+	
+	// Share of the total effective stake the validators of one operator are weighted
+	// with in selection, in basis points; 0 for no cap
+	MaxOperatorStakeBps uint64
+	
+	// Epochs removed stake stays slashable in the unbonding queue, 0 to release it at once
+	UnbondingEpochs uint64
+	
+	// Staking contract whose Deposit and Withdrawal events drive the validator set,
+	// zero to manage validators through the API
+	StakingContract common.Address
+	
+	// Slashing configuration, in basis points
+	SlashFractionBps uint64 // Share of an offender's own stake slashed per offense
+	SlashBurnBps     uint64 // Share of slashed stake burned, the rest going to the other validators
+	
+	// Proof compaction configuration
+	ProofChallengeWindow uint64 // Blocks after finality before per-MT proofs may be compacted
+	RetainFullProofs     bool   // Keep full proofs in cold storage (archive nodes)
+	
+	// Emergency halt configuration; without operators a validator stake supermajority is required
+	HaltOperators      []common.Address // Operators allowed to vote on permissioned networks
+	HaltOperatorQuorum int              // Operator votes needed to halt or resume
+	
+	// Reveal index configuration
+	RevealIndexWindow uint64 // Blocks of revealed MTs kept in the recipient, selector and token indexes
+	
+	// Partial reveal configuration
+	PartialReveals   bool // Fill in MTs that cannot be created with unrevealed placeholders instead of failing the B2 block
+	MaxRevealCarries int  // B1 blocks an unrevealed PHT is carried into again before it expires
+	
+	// Reveal deadline configuration; an MT is due within B2BlockTime plus the grace period
+	RevealGracePeriod     time.Duration
+	MissedRevealPenalty   *big.Int // Debited from the proposer due to produce the MT, nil or 0 for none
+	MissedRevealBondSlash *big.Int // Slashed from the sender's PHT bond, nil or 0 for none
+	
+	// Risk-level cutoffs applied to MEV scores
+	RiskBands RiskBands
+	
+	// Attack bounty configuration
+	BountyReward *big.Int // Paid from the treasury per verified undetected attack, nil or 0 for none
+	
+	// Database configuration
+	MigrationDryRun bool // Report pending schema migrations at startup without applying them
 }
 
 <<<<<<< HEAD:consensus/p2s/consensus.go
@@ -65,30 +259,125 @@ func DefaultP2SConfig() *P2SConfig {
 		B1BlockTime:      6 * time.Second,  // 6 seconds for B1 block
 		B2BlockTime:      6 * time.Second,  // 6 seconds for B2 block
 >>>>>>> aed06d37c647135302699a70ad914684e835e22d:consensus/p2s/p2s.go
+		ChainID:          nil,
 		MinMEVScore:      0.7,
 		MaxMEVScore:      1.0,
 		MinStake:         big.NewInt(1000000000000000000), // 1 ETH
 		MaxValidators:    100,
-		CommitmentScheme: "pedersen",
-		ProofSystem:      "merkle",
+		ProposerFallbacks: 3,
+		ProposerSelection: ProposerSelectionWeighted,
+		ReputationDecayHalfLife:    7200,  // About a day of 12 second blocks
+		ReputationRecoveryHalfLife: 21600, // Penalties fade three times slower
+		ValidatorSnapshotInterval:  1024,
+		JailMissedSlots:            8,
+		JailDuration:               1800, // About six hours of 12 second blocks
+		CommitmentScheme: CommitmentSchemePedersen,
+		ProofSystem:      ProofSystemMerkle,
+		TimelockSquaringsPerSecond: 1 << 22,
+		MEVAnalysisWorkers: 0,
+		PHTWorkers:         0,
+		MTWorkers:          0,
+		ProofCacheSize:     defaultProofCacheLimit,
+		RequireRevealSignatures: false,
+		RequireBlockSignatures:  false,
+		RemoteScoringTimeout:   500 * time.Millisecond,
+		RemoteScoringBatchSize: 256,
+		EnableMEVSimulation:       false,
+		SimulationImpactThreshold: 0.01,
+		GasSpikeFactor:      3,
+		MempoolStressWeight: 0.2,
+		WatchdogStallSlots: 3,
+		MaintenanceHandoffSlots: 8,
+		B1SealingMode:     SealingModeSingle,
+		SealCommitteeSize: 4,
+		SealThreshold:     3,
+		AttestationCommitteeSize: 0,
+		AttestationQuorum:        0,
+		MinPHTGasPrice:          big.NewInt(1000000000), // 1 gwei
+		MaxPendingPHTsPerSender: 16,
+		PHTBond:                 nil,
+		MaxPHTCallDataSize:      128 * 1024,
+		MaxPHTValue:             nil,
+		PHTPriceBump:            10,
+		RequireNonceProofs:      false,
+		SealHiddenFields:        false,
+		HiddenFields:            nil,
+		EpochLength:       32,
+		UnbondingEpochs:   7,
+		SlashFractionBps:  1000, // 10%
+		SlashBurnBps:      5000, // 50%
+		ProofChallengeWindow: 64,
+		RetainFullProofs:     false,
+		HaltOperators:      nil,
+		HaltOperatorQuorum: 0,
+		RevealIndexWindow: 10000,
+		PartialReveals:   false,
+		MaxRevealCarries: 1,
+		RevealGracePeriod:     2 * time.Second,
+		MissedRevealPenalty:   nil,
+		MissedRevealBondSlash: nil,
+		RiskBands:         DefaultRiskBands(),
+		BountyReward:      big.NewInt(100000000000000000), // 0.1 ETH
+		MigrationDryRun:   false,
 	}
 }
 
-// NewConsensus creates a new P2S consensus engine
-func NewConsensus(ethConsensus consensus.Engine, config *Config) *Consensus {
+// NewConsensus creates a new P2S consensus engine. It fails on a configuration
+// the engine cannot run with, such as an unknown commitment scheme.
+func NewConsensus(ethConsensus consensus.Engine, config *Config) (*Consensus, error) {
 	if config == nil {
 		config = DefaultConfig()
 	}
 	
-	return &Consensus{
+	phtManager, err := NewPHTManager(config)
+	if err != nil {
+		return nil, err
+	}
+	mtManager, err := NewMTManager(config)
+	if err != nil {
+		return nil, err
+	}
+	validatorMgr := NewValidatorManager(config)
+	mevDetector := newConfiguredMEVDetector(config)
+	corpus := NewCalibrationCorpus()
+	
+	engine := &Consensus{
 		ethConsensus: ethConsensus,
-		phtManager:   NewPHTManager(config),
-		mtManager:    NewMTManager(config),
-		validatorMgr: NewValidatorManager(config),
-		mevDetector:  NewMEVDetector(config),
+		phtManager:   phtManager,
+		mtManager:    mtManager,
+		validatorMgr: validatorMgr,
+		mevDetector:  mevDetector,
+		privacyGuard: NewPrivacyGuard(defaultPrivacyBudget, config.RevealIndexWindow),
+		watchdog:     NewWatchdog(config.WatchdogStallSlots, nil),
+		mevHistory:   NewMEVHistory(defaultMEVHistoryLimit),
+		payments:     NewPaymentLedger(config.EpochLength),
+		slashing:     NewSlashingManager(validatorMgr, mtManager, config.SlashFractionBps, config.SlashBurnBps),
+		evidence:     NewEvidenceCollector(validatorMgr),
+		slots:        NewSlotTimer(),
+		admission:    NewAdmissionPolicy(config),
+		governance:   NewGovernanceGuard(nil),
+		maintenance:  NewMaintenanceMode(validatorMgr, config.MaintenanceHandoffSlots, nil),
+		corpus:       corpus,
+		bounties:     NewBountyBoard(mevDetector, corpus, nil, config.BountyReward),
+		halt:         NewEmergencyHalt(validatorMgr, config.HaltOperators, config.HaltOperatorQuorum),
+		revealIndex:  NewRevealIndex(config.RevealIndexWindow),
+		receipts:     NewPHTReceiptStore(defaultPHTReceiptLimit),
+		reveals:      NewRevealTracker(config.B2BlockTime + config.RevealGracePeriod),
+		pipeline:     NewPHTPipeline(phtManager),
+		events:       NewEventBus(),
+		decryptor:    NewThresholdDecryptor(nil),
+		attestations: NewAttestationPool(config.AttestationQuorum),
+		carries:      make(map[common.Hash]int),
+		assemblers:   make(map[common.Hash]*B2Assembler),
+		keySets:      lru.NewCache[uint64, *BLSKeySet](blockKeySetLimit),
+		closedSlots:  lru.NewCache[common.Hash, bool](closedSlotLimit),
 		config:       config,
 		cache:       NewP2SCache(),
 	}
+	if config.StakingContract != (common.Address{}) {
+		engine.staking = NewStakingWatcher(validatorMgr, config.StakingContract)
+	}
+	return engine, nil
 }
 
 // Prepare implements consensus.Engine.Prepare for B1 block preparation
@@ -96,11 +385,33 @@ func (p *P2SConsensus) Prepare(chain consensus.ChainReader, header *types.Header
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	
+	// Nodes in maintenance take no new proposal duties
+	if !p.maintenance.AcceptingDuties() {
+		return ErrMaintenanceMode
+	}
+	
+	// No pairs are produced at or above an emergency halt height
+	if p.halt.Halted(header.Number.Uint64()) {
+		return ErrChainHalted
+	}
+	
+	// Settle reveals that came due since the last block
+	p.expireReveals(header.Number.Uint64(), time.Now())
+	
+	// Aggregate disclosures are budgeted per B1 slot
+	p.privacyGuard.ResetBudget()
+	
 	// Set block type to B1
 	header.Extra = append(header.Extra, byte(1)) // B1 block type
 	
 	// Prepare B1 block with PHTs
-	return p.prepareB1Block(chain, header)
+	if err := p.prepareB1Block(chain, header); err != nil {
+		p.watchdog.RecordError(err)
+		return err
+	}
+	
+	p.watchdog.RecordB1(header.Number.Uint64())
+	return nil
 }
 
 // Finalize implements consensus.Engine.Finalize for B2 block finalization
@@ -112,7 +423,49 @@ func (p *P2SConsensus) Finalize(chain consensus.ChainReader, header *types.Heade
 	header.Extra = append(header.Extra, byte(2)) // B2 block type
 	
 	// Finalize B2 block with MTs
-	return p.finalizeB2Block(chain, header, state, txs, receipts)
+	if err := p.finalizeB2Block(chain, header, state, txs, receipts); err != nil {
+		p.watchdog.RecordError(err)
+		return err
+	}
+	
+	p.watchdog.RecordB2(header.Number.Uint64())
+	p.validatorMgr.AdvanceHeight(header.Number.Uint64())
+	
+	// Deposits and withdrawals of the block update the validator set, building on
+	// the B2 block the B1 block of the pair follows
+	if p.staking != nil {
+		var parent common.Hash
+		if b1Block, ok := p.cache.GetB1Block(header.ParentHash); ok {
+			parent = b1Block.Header.ParentHash
+		}
+		for _, event := range p.staking.ProcessReceipts(header.Number.Uint64(), header.Hash(), parent, receipts) {
+			log.Info("Applied staking event", "kind", event.Kind, "validator", event.Validator, "amount", event.Amount, "stake", event.Stake)
+		}
+	}
+	
+	// Unbonded stake is released at epoch boundaries
+	if number := header.Number.Uint64(); p.config.EpochLength > 0 && number%p.config.EpochLength == 0 {
+		for _, entry := range p.validatorMgr.ProcessUnbonding(number / p.config.EpochLength) {
+			log.Info("Released unbonded stake", "validator", entry.Validator, "amount", entry.Amount, "epoch", entry.CompletionEpoch)
+		}
+	}
+	return nil
+}
+
+// newConfiguredMEVDetector creates an MEV detector using the configured concurrency
+func newConfiguredMEVDetector(config *P2SConfig) *MEVDetector {
+	detector := NewMEVDetector(config)
+	if config.MEVAnalysisWorkers > 0 {
+		detector.SetConcurrency(config.MEVAnalysisWorkers)
+	}
+	if config.GasSpikeFactor > 1 {
+		detector.stress = NewMempoolMonitor(config.GasSpikeFactor)
+	}
+	detector.SetStressWeight(config.MempoolStressWeight)
+	if err := detector.SetRiskBands(config.RiskBands); err != nil {
+		log.Warn("Ignoring configured risk bands", "err", err)
+	}
+	return detector
 }
 
 // prepareB1Block prepares a B1 block containing PHTs
@@ -120,14 +473,30 @@ func (p *P2SConsensus) prepareB1Block(chain consensus.ChainReader, header *types
 	// Get pending transactions from mempool
 	pendingTxs := p.getPendingTransactions()
 	
+	// Anti-MEV nonces of this block are derived over its parent
+	p.phtManager.SetNonceContext(header.ParentHash)
+	
+	// PHT creation and MEV detection are bounded by the B1 slot time
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.B1BlockTime)
+	defer cancel()
+	
 	// Convert transactions to PHTs
-	phts, err := p.convertToPHTs(pendingTxs)
+	phts, err := p.convertToPHTs(ctx, header.ParentHash, pendingTxs)
 	if err != nil {
 		return err
 	}
 	
 	// Detect MEV attacks
-	mevScore, attacks := p.mevDetector.DetectMEV(phts)
+	actx := NewAnalysisContext(chain, header, p.stateReader)
+	actx.Reveals = p.revealIndex
+	var scorer MEVScorer = p.mevDetector
+	if p.remoteDetector != nil {
+		scorer = p.remoteDetector
+	}
+	mevScore, attacks, err := scorer.DetectMEVParallelWithContext(ctx, actx, phts)
+	if err != nil {
+		return err
+	}
 	
 	// Check MEV protection threshold
 	if mevScore < p.config.MinMEVScore {
@@ -141,6 +510,8 @@ func (p *P2SConsensus) prepareB1Block(chain consensus.ChainReader, header *types
 		BlockType:    1,
 		MEVScore:     mevScore,
 		DetectedAttacks: attacks,
+		Coverage:     p.mevDetector.Coverage(phts),
+		RevealEvidence: p.takeRevealEvidence(),
 		Timestamp:    uint64(time.Now().Unix()),
 	}
 	
@@ -149,8 +520,46 @@ func (p *P2SConsensus) prepareB1Block(chain consensus.ChainReader, header *types
 		return err
 	}
 	
+	// Commit the header to the PHTs for light clients
+	setTxRoot(header, PHTRoot(b1Block.PHTs))
+	
 	// Cache B1 block
 	p.cache.SetB1Block(header.Hash(), b1Block)
+	p.blockKeySet(header.Number.Uint64())
+	p.receipts.Included(header.Number.Uint64(), header.Hash(), b1Block.PHTs)
+	p.applyBlockEvidence(header.Number.Uint64(), b1Block)
+	due, err := p.b2Proposer(header.Hash(), b1Block)
+	if err != nil {
+		log.Warn("No B2 proposer for B1 block", "number", header.Number, "err", err)
+	}
+	p.reveals.Track(header.Number.Uint64(), header.Hash(), due, b1Block.PHTs, time.Now())
+	
+	// Committee-sealed blocks are final once sealed
+	if p.config.B1SealingMode != SealingModeCommittee {
+		p.decryptor.Finalize(b1Block.PHTs)
+	}
+	
+	// The slot's committee attests to the block before its B2 block may follow
+	if size := p.config.AttestationCommitteeSize; size > 0 {
+		committee := p.validatorMgr.AttestationCommittee(header.Number.Uint64(), header.ParentHash, size)
+		p.attestations.Open(header.Number.Uint64(), header.Hash(), b1Block.MEVScore, committee)
+	}
+	
+	// Record per-transaction analysis for reporting
+	p.mevHistory.RecordBlock(b1Block, header.Number.Uint64(), header.Hash(), p.mevDetector.scoreTransaction)
+	p.mevDetector.RecordSenders(header.Number.Uint64(), b1Block.PHTs)
+	p.mevDetector.RecordTransfers(b1Block.PHTs)
+	
+	// Payment accounting must not block block production
+	if err := p.payments.RecordDuty(header.Number.Uint64(), header.Coinbase, DutyB1Proposal); err != nil {
+		log.Warn("Failed to record validator duty", "number", header.Number, "validator", header.Coinbase, "err", err)
+	}
+	p.validatorMgr.RecordB1Proposal(header.Coinbase, header.Number.Uint64(), b1Block.MEVScore)
+	parentTime := header.Time
+	if parent := parentHeader(chain, header); parent != nil {
+		parentTime = parent.Time
+	}
+	p.closeSlot(1, header.ParentHash, parentTime, header)
 	
 	return nil
 }
@@ -162,9 +571,17 @@ func (p *P2SConsensus) finalizeB2Block(chain consensus.ChainReader, header *type
 	if !exists {
 		return errors.New("B1 block not found")
 	}
+	if p.config.AttestationCommitteeSize > 0 && !p.attestations.Attested(header.ParentHash) {
+		return ErrAttestationQuorum
+	}
 	
-	// Convert PHTs to MTs
-	mts, err := p.convertPHTsToMTs(b1Block.PHTs)
+	// Convert PHTs to MTs, using any reveals streamed in ahead of the block
+	var revealed []*MTTransaction
+	if assembler, ok := p.assemblers[header.ParentHash]; ok {
+		revealed = assembler.Revealed()
+		delete(p.assemblers, header.ParentHash)
+	}
+	mts, err := p.convertPHTsToMTs(b1Block.PHTs, revealed)
 	if err != nil {
 		return err
 	}
@@ -178,40 +595,131 @@ func (p *P2SConsensus) finalizeB2Block(chain consensus.ChainReader, header *type
 		Timestamp:    uint64(time.Now().Unix()),
 	}
 	
+	// The attestation quorum travels with the block so every node can check it
+	if p.config.AttestationCommitteeSize > 0 {
+		b2Block.Attestations = p.attestations.Attestations(header.ParentHash)
+	}
+	
+	// Aggregate the MT inclusion proofs so validators check one per block
+	b2Block.BatchProof, err = p.mtManager.ProveBatch(b1Block.PHTs, mts)
+	if err != nil {
+		return err
+	}
+	
 	// Validate B2 block against B1 block
 	if err := b2Block.Validate(b1Block); err != nil {
 		return err
 	}
 	
+	// Commit the header to the MTs for light clients
+	setTxRoot(header, MTRoot(b2Block.MTs))
+	
 	// Cache B2 block
 	p.cache.SetB2Block(header.Hash(), b2Block)
+	p.blockKeySet(header.Number.Uint64())
+	p.receipts.Revealed(header.Number.Uint64(), header.Hash(), b2Block.MTs, receipts)
+	p.reveals.Revealed(b2Block.MTs)
+	
+	// PHTs left unrevealed are carried into the next B1 block or expire
+	var revealedPHTs, unrevealed []*PHTTransaction
+	for i, mt := range b2Block.MTs {
+		if mt.Unrevealed {
+			unrevealed = append(unrevealed, b1Block.PHTs[i])
+		} else {
+			revealedPHTs = append(revealedPHTs, b1Block.PHTs[i])
+		}
+	}
+	carried, expired := p.carryUnrevealed(header.Hash(), unrevealed)
+	p.publishPairEvents(header.Number.Uint64(), b1Block, b2Block, carried)
+	
+	// Revealed and expired PHTs need no replacement tracking
+	done := make([]common.Hash, 0, len(revealedPHTs)+len(expired))
+	for _, pht := range append(revealedPHTs, expired...) {
+		done = append(done, pht.TxHash)
+		delete(p.carries, pht.TxHash)
+		p.phtManager.ForgetOpening(pht.Commitment)
+	}
+	p.phtManager.ForgetPHTs(done)
+	
+	// Hidden fields may now be exported
+	for _, pht := range revealedPHTs {
+		p.privacyGuard.MarkRevealed(pht.TxHash, header.Number.Uint64())
+	}
+	p.revealIndex.IndexB2Block(header.Number.Uint64(), b2Block, b1Block)
+	p.attestations.Forget(header.ParentHash)
+	
+	// Payment accounting must not block block production
+	if err := p.payments.RecordDuty(header.Number.Uint64(), header.Coinbase, DutyB2Proposal); err != nil {
+		log.Warn("Failed to record validator duty", "number", header.Number, "validator", header.Coinbase, "err", err)
+	}
+	
+	// Reveal latency comes from header times so every node scores it alike
+	var latency time.Duration
+	if header.Time > b1Block.Header.Time {
+		latency = time.Duration(header.Time-b1Block.Header.Time) * time.Second
+	}
+	p.validatorMgr.RecordB2Proposal(header.Coinbase, header.Number.Uint64(), len(revealedPHTs), latency)
+	p.closeSlot(2, header.ParentHash, b1Block.Header.Time, header)
 	
 	return nil
 }
 
-// convertToPHTs converts regular transactions to PHTs
-func (p *P2SConsensus) convertToPHTs(txs []*types.Transaction) ([]*PHTTransaction, error) {
-	phts := make([]*PHTTransaction, 0, len(txs))
+// convertToPHTs converts regular transactions to PHTs of a B1 block built on parent
+func (p *P2SConsensus) convertToPHTs(ctx context.Context, parent common.Hash, txs []*types.Transaction) ([]*PHTTransaction, error) {
+	phts, err := p.phtManager.CreatePHTsWithContext(ctx, txs)
+	if err != nil {
+		return nil, err
+	}
 	
-	for _, tx := range txs {
-		pht, err := p.phtManager.CreatePHT(tx)
-		if err != nil {
-			return nil, err
+	p.receipts.Submitted(phts)
+	
+	// Superseded PHTs are left out; the rest can no longer be replaced
+	phts = p.phtManager.IncludePHTs(phts)
+	
+	// PHTs left unrevealed by the parent get another chance first; those carried
+	// from another head are dropped
+	if p.carriedFrom != parent {
+		p.resetCarried()
+	}
+	phts = append(p.carried, phts...)
+	p.carried = nil
+	p.carriedFrom = common.Hash{}
+	
+	// Each sender's PHTs must execute in account nonce order
+	phts, dropped := OrderPHTs(phts, p.stateReader)
+	if len(dropped) > 0 {
+		released := make([]common.Hash, 0, len(dropped))
+		for _, pht := range dropped {
+			released = append(released, pht.TxHash)
 		}
-		phts = append(phts, pht)
+		p.phtManager.ReleasePHTs(released)
+		log.Debug("Left out unexecutable PHTs", "count", len(dropped))
+	}
+	for _, pht := range phts {
+		p.mevDetector.ObservePHT(pht)
 	}
 	
 	return phts, nil
 }
 
-// convertPHTsToMTs converts PHTs to MTs
-func (p *P2SConsensus) convertPHTsToMTs(phts []*PHTTransaction) ([]*MTTransaction, error) {
+// convertPHTsToMTs converts PHTs to MTs. In partial reveal mode PHTs that cannot
+// be revealed get unrevealed placeholders instead of failing the block.
+func (p *P2SConsensus) convertPHTsToMTs(phts []*PHTTransaction, revealed []*MTTransaction) ([]*MTTransaction, error) {
 	mts := make([]*MTTransaction, 0, len(phts))
 	
-	for _, pht := range phts {
-		mt, err := p.mtManager.CreateMT(pht)
+	for i, pht := range phts {
+		// Streamed reveals were verified on arrival
+		if i < len(revealed) && revealed[i] != nil {
+			mts = append(mts, revealed[i])
+			continue
+		}
+		mt, err := p.revealPHT(pht)
 		if err != nil {
-			return nil, err
+			if !p.config.PartialReveals {
+				return nil, err
+			}
+			log.Warn("Leaving PHT unrevealed", "tx", pht.TxHash, "err", err)
+			mt = NewUnrevealedMT(pht)
 		}
 		mts = append(mts, mt)
 	}
@@ -219,6 +727,70 @@ func (p *P2SConsensus) convertPHTsToMTs(phts []*PHTTransaction) ([]*MTTransactio
 	return mts, nil
 }
 
+// revealPHT creates the MT of a PHT
+func (p *P2SConsensus) revealPHT(pht *PHTTransaction) (*MTTransaction, error) {
+	if err := p.phtManager.CheckReveal(pht.TxHash); err != nil {
+		return nil, err
+	}
+	mt, err := p.mtManager.CreateMT(pht)
+	if err != nil {
+		return nil, err
+	}
+	
+	// Sealed PHTs no longer carry their randomness; the sender's stored
+	// opening is transmitted in the MT instead
+	if len(mt.Blinding) == 0 {
+		opening, err := p.phtManager.Opening(pht.Commitment)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.mtManager.AttachOpening(mt, pht, opening); err != nil {
+			return nil, err
+		}
+	}
+	return mt, nil
+}
+
+// carryUnrevealed queues PHTs left unrevealed by the B2 block head for the next
+// B1 block built on it until they were carried MaxRevealCarries times, and
+// returns those that expire. PHTs still queued from an earlier head, such as
+// one an imported block replaced, are dropped.
+func (p *P2SConsensus) carryUnrevealed(head common.Hash, unrevealed []*PHTTransaction) (carried map[common.Hash]bool, expired []*PHTTransaction) {
+	p.resetCarried()
+	p.carriedFrom = head
+	
+	carried = make(map[common.Hash]bool)
+	for _, pht := range unrevealed {
+		if p.carries[pht.TxHash] >= p.config.MaxRevealCarries {
+			expired = append(expired, pht)
+			continue
+		}
+		p.carries[pht.TxHash]++
+		p.carried = append(p.carried, pht)
+		carried[pht.TxHash] = true
+	}
+	if len(expired) > 0 {
+		log.Warn("Unrevealed PHTs expired", "count", len(expired))
+	}
+	return carried, expired
+}
+
+// resetCarried drops the PHTs queued for the next B1 block, making them
+// replaceable again. The caller must hold the lock.
+func (p *P2SConsensus) resetCarried() {
+	if len(p.carried) > 0 {
+		released := make([]common.Hash, 0, len(p.carried))
+		for _, pht := range p.carried {
+			released = append(released, pht.TxHash)
+			delete(p.carries, pht.TxHash)
+		}
+		p.phtManager.ReleasePHTs(released)
+		log.Debug("Dropped carried PHTs", "count", len(released), "head", p.carriedFrom)
+	}
+	p.carried = nil
+	p.carriedFrom = common.Hash{}
+}
+
 // getPendingTransactions retrieves pending transactions from mempool
 func (p *P2SConsensus) getPendingTransactions() []*types.Transaction {
 	// This would typically interface with the mempool
@@ -244,6 +816,36 @@ func (p *P2SConsensus) ValidateBlock(chain consensus.ChainReader, block *types.B
 	}
 }
 
+// ImportBlock validates a B1 or B2 block received from another node and closes
+// its slot, recording the missed slots of the proposers its producer took over
+// from as the producer did. The token transfers of B1 blocks are recorded for wash
+// trading detection.
+func (p *P2SConsensus) ImportBlock(chain consensus.ChainReader, block *types.Block) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	header := block.Header()
+	switch p.getBlockType(header) {
+	case 1:
+		if err := p.validateB1Block(chain, block); err != nil {
+			return err
+		}
+		p.closeSlot(1, header.ParentHash, parentHeader(chain, header).Time, header)
+		b1Block, _ := p.cache.GetB1Block(block.Hash())
+		p.mevDetector.RecordTransfers(b1Block.PHTs)
+	case 2:
+		if err := p.validateB2Block(chain, block); err != nil {
+			return err
+		}
+		b2Block, _ := p.cache.GetB2Block(block.Hash())
+		b1Block, _ := p.cache.GetB1Block(b2Block.B1BlockHash)
+		p.closeSlot(2, b2Block.B1BlockHash, b1Block.Header.Time, header)
+	default:
+		return errors.New("invalid block type")
+	}
+	return nil
+}
+
 // validateB1Block validates a B1 block
 func (p *P2SConsensus) validateB1Block(chain consensus.ChainReader, block *types.Block) error {
 	// Get B1 block from cache
@@ -251,12 +853,21 @@ func (p *P2SConsensus) validateB1Block(chain consensus.ChainReader, block *types
 	if !exists {
 		return errors.New("B1 block not found in cache")
 	}
+	if err := checkTxRoot(block.Header(), PHTRoot(b1Block.PHTs)); err != nil {
+		return err
+	}
+	
+	// A carried validator signature must hold a quorum of the block's key set
+	if err := p.checkValidatorSig(block.NumberU64(), b1Block.SigningHash(), b1Block.ValidatorSig); err != nil {
+		return err
+	}
 	
 	// Validate PHTs
-	for _, pht := range b1Block.PHTs {
-		if err := p.phtManager.ValidatePHT(pht); err != nil {
-			return err
-		}
+	if err := p.pipeline.ValidatePHTs(context.Background(), b1Block.PHTs); err != nil {
+		return err
+	}
+	if err := CheckNonceOrder(b1Block.PHTs); err != nil {
+		return err
 	}
 	
 	// Validate MEV score
@@ -264,6 +875,29 @@ func (p *P2SConsensus) validateB1Block(chain consensus.ChainReader, block *types
 		return errors.New("insufficient MEV protection")
 	}
 	
+	// Each block type is produced by a validator scheduled for it, in its turn
+	parent := parentHeader(chain, block.Header())
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	if err := p.verifyProposer(1, block.ParentHash(), parent.Time, block.Header(), b1Block.ProposerSig); err != nil {
+		return err
+	}
+	
+	// Included fraud evidence must prove fraud in a known, sealed B2 block
+	for i, evidence := range b1Block.RevealEvidence {
+		if _, err := p.verifyRevealEvidence(evidence); err != nil {
+			return fmt.Errorf("reveal evidence %d: %w", i, err)
+		}
+	}
+	
+	// Validate committee seal
+	if p.config.B1SealingMode == SealingModeCommittee {
+		if err := p.verifyThresholdSeal(b1Block, block.NumberU64(), block.ParentHash()); err != nil {
+			return err
+		}
+	}
+	
 	return nil
 }
 
@@ -280,20 +914,49 @@ func (p *P2SConsensus) validateB2Block(chain consensus.ChainReader, block *types
 	if !exists {
 		return errors.New("corresponding B1 block not found")
 	}
+	if err := checkTxRoot(block.Header(), MTRoot(b2Block.MTs)); err != nil {
+		return err
+	}
+	if err := p.checkValidatorSig(block.NumberU64(), b2Block.SigningHash(), b2Block.ValidatorSig); err != nil {
+		return err
+	}
 	
-	// Validate MTs against PHTs
-	for i, mt := range b2Block.MTs {
-		if i >= len(b1Block.PHTs) {
-			return errors.New("MT count exceeds PHT count")
-		}
-		
-		pht := b1Block.PHTs[i]
-		if err := p.mtManager.VerifyMT(mt, pht); err != nil {
+	// The B1 block must have been attested by a quorum of its committee
+	if size := p.config.AttestationCommitteeSize; size > 0 {
+		committee := p.validatorMgr.AttestationCommittee(b1Block.Header.Number.Uint64(), b1Block.Header.ParentHash, size)
+		if err := VerifyAttestations(b2Block.Attestations, b2Block.B1BlockHash, b1Block, committee, p.config.AttestationQuorum); err != nil {
 			return err
 		}
 	}
+	if err := p.verifyProposer(2, b2Block.B1BlockHash, b1Block.Header.Time, block.Header(), b2Block.ProposerSig); err != nil {
+		return err
+	}
+	
+	// Unrevealed placeholders are only accepted in partial reveal mode
+	if !p.config.PartialReveals {
+		for i, mt := range b2Block.MTs {
+			if mt.Unrevealed {
+				return fmt.Errorf("%w: MT %d", ErrUnexpectedPlaceholder, i)
+			}
+		}
+	}
 	
-	return nil
+	// Blocks carrying an aggregated proof replace the per-MT inclusion proofs with
+	// it, while commitment openings are still checked per MT
+	if len(b2Block.BatchProof) > 0 {
+		return p.mtManager.VerifyBatch(b2Block.BatchProof, b1Block.PHTs, b2Block.MTs)
+	}
+	
+	// Validate MTs against PHTs in parallel, aborting on the first mismatch
+	return p.mtManager.VerifyMTs(context.Background(), b1Block.PHTs, b2Block.MTs)
+}
+
+// parentHeader returns the parent of a header from the chain, nil if unknown
+func parentHeader(chain consensus.ChainReader, header *types.Header) *types.Header {
+	if chain == nil || header.Number.Sign() == 0 {
+		return nil
+	}
+	return chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
 }
 
 // getBlockType extracts block type from header
@@ -366,12 +1029,918 @@ func (p *P2SConsensus) GetValidatorInfo(validator common.Address) *Validator {
 	return p.validatorMgr.GetValidator(validator)
 }
 
-// GetConfig returns P2S configuration
-func (p *P2SConsensus) GetConfig() *P2SConfig {
-	return p.config
+// GetValidatorPerformance returns the blocks a validator produced and missed, nil
+// if it is unknown
+func (p *P2SConsensus) GetValidatorPerformance(validator common.Address) *ValidatorPerformance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.validatorMgr.GetPerformance(validator)
+}
+
+// RecordMissedSlot records that the validator selected for a block did not
+// propose it, lowering its reputation and jailing it after repeated misses
+func (p *P2SConsensus) RecordMissedSlot(number uint64, validator common.Address) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.recordMissedSlot(number, validator)
+}
+
+// recordMissedSlot records a missed slot. The caller must hold the lock.
+func (p *P2SConsensus) recordMissedSlot(number uint64, validator common.Address) {
+	if err := p.payments.RecordDuty(number, validator, DutyMissed); err != nil {
+		log.Warn("Failed to record validator duty", "number", number, "validator", validator, "err", err)
+	}
+	p.validatorMgr.RecordMissedSlot(validator)
+}
+
+// GetUnbondingQueue returns the stake waiting to be released, by completion epoch
+func (p *P2SConsensus) GetUnbondingQueue() []*UnbondingEntry {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.validatorMgr.GetUnbondingQueue()
+}
+
+// SetValidatorOperator groups a validator under an operator identity whose
+// validators share the operator stake cap
+func (p *P2SConsensus) SetValidatorOperator(validator, operator common.Address) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	return p.validatorMgr.SetOperator(validator, operator)
+}
+
+// UnjailValidator returns a jailed validator to selection once its jail period is over
+func (p *P2SConsensus) UnjailValidator(validator common.Address) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	return p.validatorMgr.Unjail(validator)
+}
+
+// GetProposerSchedule returns the upcoming proposers of count B1 and B2 blocks
+// after the head, with their expected block times after the first scheduled B1
+// block. The first proposer is drawn from the head hash; proposers drawn from
+// parent hashes not known yet are left zero and unconfirmed.
+func (p *P2SConsensus) GetProposerSchedule(head *types.Header, count uint64) []*ProposerAssignment {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	schedule := p.validatorMgr.ProposerSchedule(head.Number.Uint64()+1, count, head.Hash())
+	for i, assignment := range schedule {
+		if p.config.SeparateB2Proposer {
+			assignment.B2Proposer = common.Address{}
+		}
+		assignment.B1Offset = time.Duration(i) * (p.config.B1BlockTime + p.config.B2BlockTime)
+		assignment.B2Offset = assignment.B1Offset + p.config.B2BlockTime
+	}
+	return schedule
+}
+
+// NewSealingCommittee selects the committee that jointly seals the B1 block at the
+// given height, led by the proposer selected with the parent block hash
+func (p *P2SConsensus) NewSealingCommittee(blockNumber uint64, parentHash common.Hash) (*SealingCommittee, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.sealingCommittee(blockNumber, parentHash)
+}
+
+// sealingCommittee selects the sealing committee of a B1 block. The caller must hold the lock.
+func (p *P2SConsensus) sealingCommittee(blockNumber uint64, parentHash common.Hash) (*SealingCommittee, error) {
+	leader, err := p.validatorMgr.SelectProposer(blockNumber, parentHash)
+	if err != nil {
+		return nil, err
+	}
+	
+	// The proposer leads, remaining members co-sign
+	members := []common.Address{leader}
+	for _, member := range p.validatorMgr.SelectValidators(p.config.SealCommitteeSize) {
+		if member != leader && len(members) < p.config.SealCommitteeSize {
+			members = append(members, member)
+		}
+	}
+	
+	threshold := p.config.SealThreshold
+	if threshold > len(members) {
+		threshold = len(members)
+	}
+	
+	return NewSealingCommittee(members, threshold)
+}
+
+// verifyThresholdSeal verifies the committee seal of a B1 block against the
+// committee selected for its height. The caller must hold the lock.
+func (p *P2SConsensus) verifyThresholdSeal(b1Block *B1Block, blockNumber uint64, parentHash common.Hash) error {
+	committee, err := p.sealingCommittee(blockNumber, parentHash)
+	if err != nil {
+		return err
+	}
+	return VerifyThresholdSeal(b1Block, committee, blockNumber)
+}
+
+// SealB1Block attaches a committee seal to a cached B1 block
+func (p *P2SConsensus) SealB1Block(hash common.Hash, seal *ThresholdSeal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	b1Block, exists := p.cache.GetB1Block(hash)
+	if !exists {
+		return errors.New("B1 block not found")
+	}
+	if b1Block.Header == nil {
+		return errors.New("B1 block has no header")
+	}
+	
+	b1Block.CommitteeSeal = seal
+	if err := p.verifyThresholdSeal(b1Block, b1Block.Header.Number.Uint64(), b1Block.Header.ParentHash); err != nil {
+		b1Block.CommitteeSeal = nil
+		return err
+	}
+	p.decryptor.Finalize(b1Block.PHTs)
+	
+	return nil
+}
+
+// GetAttestationCommittee returns the validators attesting to the B1 block at the
+// given height, derived from its parent block hash
+func (p *P2SConsensus) GetAttestationCommittee(blockNumber uint64, parentHash common.Hash) []common.Address {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	if p.config.AttestationCommitteeSize <= 0 {
+		return nil
+	}
+	return p.validatorMgr.AttestationCommittee(blockNumber, parentHash, p.config.AttestationCommitteeSize)
+}
+
+// SubmitAttestation records a committee member's attestation to a B1 block,
+// reporting whether it brought the block to quorum
+func (p *P2SConsensus) SubmitAttestation(attestation *B1Attestation) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	return p.attestations.Add(attestation)
+}
+
+// GetAttestations returns the attestations collected for a B1 block
+func (p *P2SConsensus) GetAttestations(b1Hash common.Hash) []*B1Attestation {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.attestations.Attestations(b1Hash)
+}
+
+// RegisterBLSKey registers a validator's BLS public key for aggregate block signatures
+func (p *P2SConsensus) RegisterBLSKey(validator common.Address, publicKey, proof []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	return p.validatorMgr.RegisterBLSKey(validator, publicKey, proof)
+}
+
+// SignBlock attaches an aggregate validator signature to a cached B1 or B2 block
+// after verifying it against the BLS key set of the block's height
+func (p *P2SConsensus) SignBlock(hash common.Hash, aggregate *AggregateSignature) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	sig, err := aggregate.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if b1Block, exists := p.cache.GetB1Block(hash); exists {
+		keys := p.blockKeySet(b1Block.Header.Number.Uint64())
+		if err := verifyValidatorSig(b1Block.SigningHash(), sig, keys); err != nil {
+			return err
+		}
+		b1Block.ValidatorSig = sig
+		return nil
+	}
+	if b2Block, exists := p.cache.GetB2Block(hash); exists {
+		keys := p.blockKeySet(b2Block.Header.Number.Uint64())
+		if err := verifyValidatorSig(b2Block.SigningHash(), sig, keys); err != nil {
+			return err
+		}
+		b2Block.ValidatorSig = sig
+		return nil
+	}
+	return errors.New("block not found")
+}
+
+// ExportB1Block returns a JSON export of a cached B1 block with unrevealed PHTs redacted
+func (p *P2SConsensus) ExportB1Block(hash common.Hash) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	b1Block, exists := p.cache.GetB1Block(hash)
+	if !exists {
+		return nil, errors.New("B1 block not found")
+	}
+	
+	return p.privacyGuard.ExportB1Block(b1Block)
+}
+
+// GetB1Block returns a cached B1 block. PHT hidden fields are included, so callers
+// must not expose the block before its pair is finalized.
+func (p *P2SConsensus) GetB1Block(hash common.Hash) (*B1Block, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.cache.GetB1Block(hash)
+}
+
+// GetB2Block returns a cached B2 block
+func (p *P2SConsensus) GetB2Block(hash common.Hash) (*B2Block, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	return p.cache.GetB2Block(hash)
+}
+
+// StartWatchdog starts monitoring slot progression with the given recovery hooks,
+// restarting the watchdog if it already runs. Blocks recorded so far are kept.
+func (p *P2SConsensus) StartWatchdog(hooks *WatchdogHooks, currentSlot func() uint64) {
+	p.watchdog.Stop()
+	p.watchdog.SetHooks(hooks)
+	p.watchdog.Start(p.config.B1BlockTime, currentSlot)
+}
+
+// GetStallReport returns the most recent watchdog stall report
+func (p *P2SConsensus) GetStallReport() *StallReport {
+	return p.watchdog.LastReport()
+}
+
+// SetStateReader sets the chain state consulted by state-aware MEV detection rules
+func (p *P2SConsensus) SetStateReader(reader StateReader) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.stateReader = reader
+}
+
+// SetMaintenanceHooks connects maintenance mode to the node's duty schedule and reveal pipeline
+func (p *P2SConsensus) SetMaintenanceHooks(hooks *MaintenanceHooks) {
+	p.maintenance.SetHooks(hooks)
+}
+
+// EnterMaintenance stops new proposal duties for a validator, hands imminent
+// duties to fallback proposers and starts draining in-flight reveals
+func (p *P2SConsensus) EnterMaintenance(validator common.Address, slot uint64) (*MaintenanceStatus, error) {
+	return p.maintenance.Enter(validator, slot)
+}
+
+// GetMaintenanceStatus reports maintenance progress and whether it is safe to shut down
+func (p *P2SConsensus) GetMaintenanceStatus() *MaintenanceStatus {
+	return p.maintenance.Status()
+}
+
+// ExitMaintenance returns the node to duty rotation
+func (p *P2SConsensus) ExitMaintenance() {
+	p.maintenance.Exit()
+}
+
+// ExportMEVReport generates a signed MEV report for blocks in [fromBlock, toBlock]
+func (p *P2SConsensus) ExportMEVReport(fromBlock, toBlock uint64, topN int, key *ecdsa.PrivateKey) (*MEVReport, error) {
+	return NewMEVReportExporter(p.mevHistory, key).Generate(fromBlock, toBlock, topN)
+}
+
+// SetBountyTreasury sets the treasury that pays verified bounty claims
+func (p *P2SConsensus) SetBountyTreasury(treasury BountyTreasury) {
+	p.bounties.SetTreasury(treasury)
+}
+
+// SubmitBountyEvidence verifies a claim of an undetected attack against the stored
+// reveals of its block and, if valid, rewards the reporter
+func (p *P2SConsensus) SubmitBountyEvidence(packet *EvidencePacket) (*BountyClaim, error) {
+	if packet == nil {
+		return nil, ErrInvalidEvidence
+	}
+	
+	p.mu.RLock()
+	b1Block, _ := p.cache.GetB1Block(packet.B1Hash)
+	var b2Block *B2Block
+	for _, block := range p.cache.b2Blocks {
+		if block.B1BlockHash == packet.B1Hash {
+			b2Block = block
+			break
+		}
+	}
+	p.mu.RUnlock()
+	
+	return p.bounties.Submit(packet, b1Block, b2Block, p.mtManager.VerifyMT)
+}
+
+// GetBountyClaims returns all accepted bounty claims
+func (p *P2SConsensus) GetBountyClaims() []*BountyClaim {
+	return p.bounties.Claims()
+}
+
+// CalibrateFromCorpus calibrates the detector against blocks labelled by verified bounty claims
+func (p *P2SConsensus) CalibrateFromCorpus() *CalibrationReport {
+	blocks, labels := p.corpus.Data()
+	return p.mevDetector.Calibrate(blocks, labels)
+}
+
+// HistoryReplicationHandler serves the MEV history replication log so read
+// replicas can answer explorer and analytics queries off the consensus node
+func (p *P2SConsensus) HistoryReplicationHandler() http.Handler {
+	return NewReplicationHandler(p.mevHistory)
+}
+
+// GetSenderReputation returns the MEV reputation of a PHT sender
+func (p *P2SConsensus) GetSenderReputation(sender common.Address) *SenderReputation {
+	return p.mevDetector.GetSenderReputation(sender)
+}
+
+// GetCrossDomainFindings returns the bridge and swap pairs behind cross-domain MEV in a B1 block
+func (p *P2SConsensus) GetCrossDomainFindings(hash common.Hash) ([]*CrossDomainFinding, error) {
+	p.mu.RLock()
+	b1Block, exists := p.cache.GetB1Block(hash)
+	p.mu.RUnlock()
+	if !exists {
+		return nil, errors.New("B1 block not found")
+	}
+	
+	return p.mevDetector.DetectCrossDomainMEV(b1Block.PHTs), nil
+}
+
+// SetRemoteScoring offloads PHT scoring to a scoring service over conn, keeping the
+// local detector as fallback. A nil connection restores in-process scoring.
+func (p *P2SConsensus) SetRemoteScoring(conn *grpc.ClientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if conn == nil {
+		p.remoteDetector = nil
+		return
+	}
+	p.remoteDetector = NewRemoteDetector(conn, p.mevDetector, p.config.RemoteScoringTimeout, p.config.RemoteScoringBatchSize)
+}
+
+// GetRevealsByRecipient returns revealed MTs sent to an address in the last blocks blocks
+func (p *P2SConsensus) GetRevealsByRecipient(recipient common.Address, blocks uint64) []IndexedMT {
+	return p.privacyGuard.ExportReveals(p.revealIndex.ByRecipient(recipient, blocks))
+}
+
+// GetRevealsBySelector returns revealed MTs calling a 4-byte selector in the last blocks blocks
+func (p *P2SConsensus) GetRevealsBySelector(selector []byte, blocks uint64) ([]IndexedMT, error) {
+	if len(selector) != 4 {
+		return nil, errors.New("selector must be 4 bytes")
+	}
+	var key [4]byte
+	copy(key[:], selector)
+	return p.privacyGuard.ExportReveals(p.revealIndex.BySelector(key, blocks)), nil
+}
+
+// GetRevealsByToken returns revealed MTs touching an ERC-20 token in the last blocks blocks
+func (p *P2SConsensus) GetRevealsByToken(token common.Address, blocks uint64) []IndexedMT {
+	return p.privacyGuard.ExportReveals(p.revealIndex.ByToken(token, blocks))
+}
+
+// publishPairEvents publishes the finalization of a pair and any PHTs it left
+// unrevealed, except those carried into the next B1 block
+func (p *P2SConsensus) publishPairEvents(number uint64, b1Block *B1Block, b2Block *B2Block, carried map[common.Hash]bool) {
+	revealed := make(map[common.Hash]bool, len(b2Block.MTs))
+	for _, mt := range b2Block.MTs {
+		if !mt.Unrevealed {
+			revealed[mt.TxHash] = true
+		}
+	}
+	for _, pht := range b1Block.PHTs {
+		if !revealed[pht.TxHash] && !carried[pht.TxHash] {
+			p.events.Publish(&BusEvent{
+				Block:         number,
+				Kind:          EventRevealExpired,
+				RevealExpired: &RevealExpired{B1Hash: b2Block.B1BlockHash, TxHash: pht.TxHash, Sender: pht.Sender},
+			})
+		}
+	}
+	p.events.Publish(&BusEvent{
+		Block:         number,
+		Kind:          EventPairFinalized,
+		PairFinalized: &PairFinalized{B1Hash: b2Block.B1BlockHash, B2Hash: b2Block.BlockHash, Reveals: len(revealed)},
+	})
+}
+
+// expireReveals defaults the PHTs whose reveal deadlines passed, publishes a
+// MissedReveal event for each and applies the configured penalties
+func (p *P2SConsensus) expireReveals(number uint64, now time.Time) []*MissedReveal {
+	missed := p.reveals.Expire(now)
+	if len(missed) == 0 {
+		return nil
+	}
+	p.receipts.Defaulted(missed)
+	
+	for _, reveal := range missed {
+		p.events.Publish(&BusEvent{
+			Block:        number,
+			Kind:         EventMissedReveal,
+			MissedReveal: reveal,
+		})
+		
+		// Penalty accounting must not block block production
+		if penalty := p.config.MissedRevealPenalty; penalty != nil && penalty.Sign() > 0 && reveal.Proposer != (common.Address{}) {
+			if err := p.payments.RecordPenalty(number, reveal.Proposer, penalty); err != nil {
+				log.Warn("Failed to record missed reveal penalty", "validator", reveal.Proposer, "tx", reveal.TxHash, "err", err)
+			}
+		}
+		if reveal.Proposer != (common.Address{}) {
+			p.validatorMgr.RecordMissedReveal(reveal.Proposer)
+		}
+		if reveal.Sender != (common.Address{}) {
+			if slashed := p.admission.SlashBond(reveal.Sender, p.config.MissedRevealBondSlash); slashed.Sign() > 0 {
+				log.Warn("Slashed sender bond for missed reveal", "sender", reveal.Sender, "tx", reveal.TxHash, "amount", slashed)
+			}
+		}
+	}
+	log.Warn("PHTs missed their reveal deadline", "count", len(missed), "number", number)
+	return missed
+}
+
+// CheckRevealDeadlines settles reveal deadlines that passed, for nodes that check
+// them between blocks, and returns the missed reveals
+func (p *P2SConsensus) CheckRevealDeadlines(number uint64) []*MissedReveal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	return p.expireReveals(number, time.Now())
+}
+
+// SlashValidator removes up to amount of a validator's stake at the given block
+// and publishes the slashing
+func (p *P2SConsensus) SlashValidator(number uint64, validator common.Address, amount *big.Int, reason string) (*big.Int, error) {
+	slashed, err := p.validatorMgr.Slash(validator, amount)
+	if err != nil {
+		return nil, err
+	}
+	p.events.Publish(&BusEvent{
+		Block:            number,
+		Kind:             EventValidatorSlashed,
+		ValidatorSlashed: &ValidatorSlashed{Validator: validator, Amount: new(big.Int).Set(slashed), Reason: reason},
+	})
+	log.Warn("Slashed validator", "validator", validator, "amount", slashed, "reason", reason)
+	return slashed, nil
+}
+
+// SubmitSlashingEvidence verifies evidence of a validator offense, slashes the
+// offender and credits the redistributed stake as rewards at the given block.
+// Fraudulent reveals are slashed through blocks, see SubmitRevealEvidence.
+func (p *P2SConsensus) SubmitSlashingEvidence(number uint64, evidence SlashingEvidence) (*SlashingRecord, error) {
+	if _, ok := evidence.(*InvalidRevealEvidence); ok {
+		return nil, fmt.Errorf("%w: reveal evidence is applied when included in a block", ErrInvalidEvidence)
+	}
+	return p.slash(number, evidence)
+}
+
+// slash slashes the offender proven by evidence and credits the redistributed
+// stake as rewards at the given block
+func (p *P2SConsensus) slash(number uint64, evidence SlashingEvidence) (*SlashingRecord, error) {
+	record, err := p.slashing.Submit(evidence)
+	if err != nil {
+		return nil, err
+	}
+	for _, validator := range record.sortedShares() {
+		if err := p.RecordValidatorReward(number, validator, record.Redistributed[validator]); err != nil {
+			log.Warn("Failed to credit slashed stake", "validator", validator, "err", err)
+		}
+	}
+	p.events.Publish(&BusEvent{
+		Block:            number,
+		Kind:             EventValidatorSlashed,
+		ValidatorSlashed: &ValidatorSlashed{Validator: record.Validator, Amount: new(big.Int).Set(record.Amount), Reason: string(record.Offense)},
+	})
+	log.Warn("Slashed validator", "validator", record.Validator, "amount", record.Amount, "offense", record.Offense, "height", record.Height)
+	return record, nil
+}
+
+// ObserveBlockSignature records a validator's signature of a block seen at the
+// given block and slashes the validator if it signed another block of the type at
+// the same height. Signatures of blocks well beyond the given one are rejected.
+func (p *P2SConsensus) ObserveBlockSignature(number uint64, signature *BlockSignature) (*SlashingRecord, error) {
+	evidence, err := p.evidence.Observe(number, signature)
+	if err != nil || evidence == nil {
+		return nil, err
+	}
+	log.Warn("Detected double sign", "validator", signature.Validator, "height", evidence.Height(), "type", signature.BlockType)
+	return p.SubmitSlashingEvidence(number, evidence)
+}
+
+// GetDoubleSignEvidence returns the double signs detected within the evidence window
+func (p *P2SConsensus) GetDoubleSignEvidence() []*DoubleSignEvidence {
+	return p.evidence.Evidence()
+}
+
+// GetPendingDeposit returns the staking contract deposits of an address that has
+// not reached the minimum stake yet
+func (p *P2SConsensus) GetPendingDeposit(address common.Address) *big.Int {
+	if p.staking == nil {
+		return new(big.Int)
+	}
+	return p.staking.PendingDeposit(address)
+}
+
+// GetSlashingHistory returns the slashings of a validator, oldest first
+func (p *P2SConsensus) GetSlashingHistory(validator common.Address) []*SlashingRecord {
+	return p.slashing.History(validator)
+}
+
+// SubscribeEvents subscribes to domain events, replaying retained events from fromBlock
+func (p *P2SConsensus) SubscribeEvents(consumer string, fromBlock uint64, kinds ...EventKind) (*EventSubscription, error) {
+	return p.events.Subscribe(consumer, fromBlock, kinds...)
+}
+
+// ResumeEvents subscribes to domain events after the consumer's last acknowledged event
+func (p *P2SConsensus) ResumeEvents(consumer string, kinds ...EventKind) (*EventSubscription, error) {
+	return p.events.Resume(consumer, kinds...)
+}
+
+// SetThresholdKey sets the committee key PHT hidden fields are encrypted to
+func (p *P2SConsensus) SetThresholdKey(key *ThresholdKey) {
+	p.decryptor.SetKey(key)
+	p.phtManager.SetThresholdKey(key)
+}
+
+// SubmitDecryptionShare records a committee member's decryption share for an
+// encrypted PHT of a final B1 block. Once a quorum of shares is in, the hidden
+// fields are restored on the PHT and true is returned.
+func (p *P2SConsensus) SubmitDecryptionShare(share *DecryptionShare) (bool, error) {
+	fields, err := p.decryptor.AddShare(share)
+	if err != nil || fields == nil {
+		return false, err
+	}
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	pht, exists := p.decryptor.PHT(share.TxHash)
+	if !exists || pht.EncryptedFields == nil {
+		return true, nil
+	}
+	if err := p.restoreHiddenFields(pht, fields); err != nil {
+		return false, err
+	}
+	pht.EncryptedFields = nil
+	
+	return true, nil
+}
+
+// restoreHiddenFields sets recovered hidden fields on a PHT after checking them
+// against its commitment; p.mu must be held
+func (p *P2SConsensus) restoreHiddenFields(pht *PHTTransaction, fields *HiddenFields) error {
+	if !p.phtManager.VerifyHiddenFields(pht, fields) {
+		return errors.New("recovered fields do not match PHT commitment")
+	}
+	pht.Blinding = fields.Blinding
+	pht.Recipient = fields.Recipient
+	pht.IsContractCreation = fields.IsContractCreation
+	pht.Value = fields.Value
+	pht.CallData = fields.CallData
+	pht.TxType = fields.TxType
+	pht.GasLimit = fields.GasLimit
+	pht.FieldSalts = fields.FieldSalts
+	pht.AccessList = fields.AccessList
+	pht.BlobHashes = fields.BlobHashes
+	pht.MaxFeePerBlobGas = fields.MaxFeePerBlobGas
+	pht.ValueBlinding = fields.ValueBlinding
+	pht.SignedTx = fields.SignedTx
+	pht.RevealSignature = fields.RevealSignature
+	if pht.HiddenSet.Has(FieldSender) {
+		pht.Sender = fields.Sender
+	}
+	if pht.HiddenSet.Has(FieldGasPrice) {
+		pht.GasPrice = fields.GasPrice
+		pht.MaxFeePerGas, pht.MaxPriorityFeePerGas = fields.MaxFeePerGas, fields.MaxPriorityFeePerGas
+	}
+	return nil
+}
+
+// ReplacePHT supersedes a pending PHT with a higher-priced replacement from the
+// same sender
+func (p *P2SConsensus) ReplacePHT(old, replacement *PHTTransaction) error {
+	if err := p.phtManager.ReplacePHT(old, replacement); err != nil {
+		return err
+	}
+	p.receipts.Superseded(old, replacement)
+	return nil
+}
+
+// ValidatePHTStream validates PHTs received on in concurrently, emitting a result
+// per PHT in input order. It is the validation path shared with PHT gossip.
+func (p *P2SConsensus) ValidatePHTStream(ctx context.Context, in <-chan *PHTTransaction) <-chan PHTValidationResult {
+	return p.pipeline.Run(ctx, in)
+}
+
+// GetPHTReceipt returns the lifecycle receipt of a PHT
+func (p *P2SConsensus) GetPHTReceipt(txHash common.Hash) (*PHTReceipt, bool) {
+	receipt, exists := p.receipts.Receipt(txHash)
+	return p.privacyGuard.ExportReceipt(receipt), exists
+}
+
+// GetMTInclusionProof returns a proof, checkable against the block header alone,
+// that the transaction txHash was revealed in the B2 block blockHash
+func (p *P2SConsensus) GetMTInclusionProof(blockHash, txHash common.Hash) (*MTInclusionProof, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	b2Block, exists := p.cache.GetB2Block(blockHash)
+	if !exists {
+		return nil, errors.New("B2 block not found")
+	}
+	return newMTInclusionProof(b2Block, txHash)
+}
+
+// AssembleB2 verifies MTs for the B2 block following the cached B1 block b1Hash
+// as they are received on in, ahead of building the block. Reveals accepted by
+// then are used as they are; finalizing the B2 block creates the missing ones.
+func (p *P2SConsensus) AssembleB2(ctx context.Context, b1Hash common.Hash, in <-chan *MTTransaction) (*B2Assembler, <-chan MTAssemblyResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	b1Block, exists := p.cache.GetB1Block(b1Hash)
+	if !exists {
+		return nil, nil, errors.New("B1 block not found")
+	}
+	assembler, ok := p.assemblers[b1Hash]
+	if !ok {
+		assembler = NewB2Assembler(p.mtManager, b1Block)
+		p.assemblers[b1Hash] = assembler
+	}
+	return assembler, assembler.Run(ctx, in), nil
+}
+
+// GetPHTReceiptsBySender returns the lifecycle receipts of a sender's recent PHTs
+func (p *P2SConsensus) GetPHTReceiptsBySender(sender common.Address) []*PHTReceipt {
+	receipts := p.receipts.BySender(sender)
+	for i, receipt := range receipts {
+		receipts[i] = p.privacyGuard.ExportReceipt(receipt)
+	}
+	return receipts
+}
+
+// ForceReveal opens the time-lock puzzle of a PHT in a cached B1 block whose
+// sender withheld the MT, restoring its hidden fields so the B2 block can include
+// it. Solving takes about a B1 interval; the context bounds it.
+func (p *P2SConsensus) ForceReveal(ctx context.Context, b1Hash common.Hash, txHash common.Hash) (*MTTransaction, error) {
+	p.mu.RLock()
+	var pht *PHTTransaction
+	if b1Block, exists := p.cache.GetB1Block(b1Hash); exists {
+		for _, candidate := range b1Block.PHTs {
+			if candidate.TxHash == txHash {
+				pht = candidate
+				break
+			}
+		}
+	}
+	var puzzle *TimelockPuzzle
+	if pht != nil {
+		puzzle = pht.TimelockPuzzle
+	}
+	p.mu.RUnlock()
+	
+	if pht == nil {
+		return nil, errors.New("PHT not found")
+	}
+	if err := p.phtManager.CheckReveal(txHash); err != nil {
+		return nil, err
+	}
+	if puzzle == nil {
+		return nil, errors.New("PHT has no time-lock puzzle")
+	}
+	
+	// Solve without holding the lock, this is slow by design
+	fields, err := SolveTimelockPuzzle(ctx, txHash, puzzle)
+	if err != nil {
+		return nil, err
+	}
+	
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if err := p.restoreHiddenFields(pht, fields); err != nil {
+		return nil, err
+	}
+	return p.mtManager.CreateMT(pht)
+}
+
+// SubmitHaltMessage counts a signed emergency halt or resume vote, returning true
+// once it reaches quorum and takes effect
+func (p *P2SConsensus) SubmitHaltMessage(msg *HaltMessage) (bool, error) {
+	return p.halt.Submit(msg)
+}
+
+// GetHaltStatus returns the emergency halt state of the node
+func (p *P2SConsensus) GetHaltStatus() *HaltStatus {
+	return p.halt.Status()
+}
+
+// ExportTestVector generates a detection test vector from the live detections of a B1 block
+func (p *P2SConsensus) ExportTestVector(hash common.Hash, name string) (*TestVector, error) {
+	p.mu.RLock()
+	b1Block, exists := p.cache.GetB1Block(hash)
+	p.mu.RUnlock()
+	if !exists {
+		return nil, errors.New("B1 block not found")
+	}
+	
+	// Vectors carry full PHTs, so only blocks whose PHTs are all revealed export
+	vector := VectorFromBlock(name, b1Block)
+	if _, err := p.privacyGuard.MarshalAudited(vector, b1Block.PHTs); err != nil {
+		return nil, err
+	}
+	return vector, nil
+}
+
+// RunTestVectors runs the test vector files in dir against the active rule set
+func (p *P2SConsensus) RunTestVectors(dir string) (*VectorReport, error) {
+	return p.mevDetector.RunVectors(dir)
+}
+
+// RecordValidatorReward credits a validator reward to the payment epoch of a block.
+// The delegators' shares of a validator's reward pass through to them, the
+// validator's statement recording the rest.
+func (p *P2SConsensus) RecordValidatorReward(blockNumber uint64, validator common.Address, amount *big.Int) error {
+	if p.validatorMgr.IsValidator(validator) {
+		own, err := p.validatorMgr.DistributeReward(validator, amount)
+		if err != nil {
+			return err
+		}
+		amount = own
+	}
+	return p.payments.RecordReward(blockNumber, validator, amount)
+}
+
+// Delegate bonds stake of a delegator to a validator
+func (p *P2SConsensus) Delegate(delegator, validator common.Address, amount *big.Int) error {
+	return p.validatorMgr.Delegate(delegator, validator, amount)
+}
+
+// Undelegate unbonds stake of a delegator from a validator
+func (p *P2SConsensus) Undelegate(delegator, validator common.Address, amount *big.Int) error {
+	return p.validatorMgr.Undelegate(delegator, validator, amount)
+}
+
+// SetDelegationHooks connects delegator reward pass-through to the node's accounts
+func (p *P2SConsensus) SetDelegationHooks(hooks *DelegationHooks) {
+	p.validatorMgr.SetDelegationHooks(hooks)
+}
+
+// RecordValidatorPenalty debits a validator penalty in the payment epoch of a block
+func (p *P2SConsensus) RecordValidatorPenalty(blockNumber uint64, validator common.Address, amount *big.Int) error {
+	return p.payments.RecordPenalty(blockNumber, validator, amount)
+}
+
+// SealPaymentEpoch closes a payment epoch and signs its statement root
+func (p *P2SConsensus) SealPaymentEpoch(epoch uint64, key *ecdsa.PrivateKey) (common.Hash, error) {
+	return p.payments.SealEpoch(epoch, key)
+}
+
+// GetPaymentStatement returns a validator's verifiable payment statement for a sealed epoch
+func (p *P2SConsensus) GetPaymentStatement(epoch uint64, validator common.Address) (*PaymentStatement, error) {
+	return p.payments.Statement(epoch, validator)
+}
+
+// SetAdmissionState sets the pool and bond state used for PHT admission
+func (p *P2SConsensus) SetAdmissionState(state AdmissionState) {
+	p.admission.SetState(state)
+}
+
+// CheckAdmission reports whether a PHT would currently be admitted to the pool
+func (p *P2SConsensus) CheckAdmission(pht *PHTTransaction) *AdmissionResult {
+	return p.privacyGuard.ExportAdmission(p.admission.Check(pht), pht)
+}
+
+// APIs returns the RPC APIs provided by the P2S engine
+func (p *P2SConsensus) APIs(chain consensus.ChainHeaderReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "p2s",
+		Service:   &API{p2s: p},
+	}}
+}
+
+// CompactProofs replaces per-MT proofs of finalized pairs past the challenge window
+// with signed pair attestations in the attached database, returning the number compacted
+func (p *P2SConsensus) CompactProofs(finalized uint64, key *ecdsa.PrivateKey) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if p.db == nil {
+		return 0, errors.New("no database attached")
+	}
+	
+	compactor := NewProofCompactor(p.db, p.config.ProofChallengeWindow, p.config.RetainFullProofs, key)
+	compacted := 0
+	for _, b2Block := range p.cache.b2Blocks {
+		if b2Block.ProofsCompacted {
+			continue
+		}
+		b1Block, exists := p.cache.GetB1Block(b2Block.B1BlockHash)
+		if !exists {
+			continue
+		}
+		if _, err := compactor.Compact(b1Block, b2Block, finalized); err != nil {
+			if errors.Is(err, ErrChallengeWindowOpen) {
+				continue
+			}
+			return compacted, err
+		}
+		compacted++
+	}
+	
+	return compacted, nil
+}
+
+// OpenDatabase migrates a database to the supported schema version and attaches it.
+// Databases written by a newer binary are refused.
+func (p *P2SConsensus) OpenDatabase(db ethdb.KeyValueStore, backup func(db ethdb.KeyValueStore, from uint64) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	config := &MigrationConfig{
+		DryRun: p.config.MigrationDryRun,
+		Backup: backup,
+	}
+	if _, err := MigrateDatabase(db, config); err != nil {
+		return err
+	}
+	if err := p.halt.SetDatabase(db); err != nil {
+		return err
+	}
+	if err := p.events.SetDatabase(db); err != nil {
+		return err
+	}
+	if err := p.validatorMgr.SetDatabase(db); err != nil {
+		return err
+	}
+	if p.staking != nil {
+		if err := p.staking.SetDatabase(db); err != nil {
+			return err
+		}
+	}
+	
+	p.db = db
+	return nil
+}
+
+// GetConfig returns P2S configuration
+func (p *P2SConsensus) GetConfig() *P2SConfig {
+	return p.config
+}
+
+// ProposeConfig validates a governance configuration proposal against the
+// parameter rate limits and applies it if accepted
+func (p *P2SConsensus) ProposeConfig(epoch uint64, proposed *P2SConfig) error {
+	if err := proposed.RiskBands.Validate(); err != nil {
+		return err
+	}
+	
+	p.mu.Lock()
+	if err := p.governance.Apply(epoch, p.config, proposed); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.config = proposed
+	p.mu.Unlock()
+	
+	p.applyRiskBands(proposed.RiskBands)
+	return nil
+}
+
+// SetRiskBands validates and applies new risk-level cutoffs at runtime
+func (p *P2SConsensus) SetRiskBands(bands RiskBands) error {
+	if err := bands.Validate(); err != nil {
+		return err
+	}
+	
+	p.mu.Lock()
+	p.config.RiskBands = bands
+	p.mu.Unlock()
+	
+	p.applyRiskBands(bands)
+	return nil
+}
+
+// GetRiskBands returns the risk-level cutoffs in use
+func (p *P2SConsensus) GetRiskBands() RiskBands {
+	return p.mevDetector.GetRiskBands()
+}
+
+// applyRiskBands hands validated cutoffs to the detector and emits a change event
+// if they differ from the ones in use. It must not be called with p.mu held, as
+// subscribers are notified synchronously.
+func (p *P2SConsensus) applyRiskBands(bands RiskBands) {
+	old := p.mevDetector.GetRiskBands()
+	if old == bands {
+		return
+	}
+	p.mevDetector.SetRiskBands(bands)
+	p.emitConfigChange("RiskBands", old, bands)
+	log.Info("Updated MEV risk bands", "low", bands.Low, "medium", bands.Medium, "high", bands.High)
 }
 
-// SetConfig updates P2S configuration
+// SetConfig updates P2S configuration without rate limits; governance changes go through ProposeConfig
 func (p *P2SConsensus) SetConfig(config *P2SConfig) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
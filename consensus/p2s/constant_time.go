@@ -0,0 +1,19 @@
+package p2s
+
+import (
+	"crypto/subtle"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// constantTimeEqual reports whether two commitments or proof nodes are equal
+// without leaking the position of the first differing byte through timing
+func constantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// constantTimeHashEqual is constantTimeEqual for fixed-size hashes such as
+// Merkle roots
+func constantTimeHashEqual(a, b common.Hash) bool {
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
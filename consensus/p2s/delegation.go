@@ -0,0 +1,239 @@
+package p2s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// ErrInvalidDelegation is returned for a delegation of no stake or to an unknown validator
+	ErrInvalidDelegation = errors.New("invalid delegation")
+
+	// ErrInsufficientDelegation is returned when undelegating more than a delegator bonded
+	ErrInsufficientDelegation = errors.New("insufficient delegated stake")
+)
+
+// Delegation is the stake a delegator bonded to a validator and the rewards
+// passed through to it
+type Delegation struct {
+	Delegator common.Address `json:"delegator"`
+	Validator common.Address `json:"validator"`
+	Amount    *big.Int       `json:"amount"`
+	Rewards   *big.Int       `json:"rewards"` // Accrued and not claimed yet
+}
+
+// copy returns a copy of the delegation
+func (d *Delegation) copy() *Delegation {
+	return &Delegation{
+		Delegator: d.Delegator,
+		Validator: d.Validator,
+		Amount:    new(big.Int).Set(d.Amount),
+		Rewards:   new(big.Int).Set(d.Rewards),
+	}
+}
+
+// DelegationHooks pass validator rewards through to the delegators' accounts
+type DelegationHooks struct {
+	// DelegatorReward is called for each delegator share of a validator reward
+	DelegatorReward func(validator, delegator common.Address, amount *big.Int)
+}
+
+// EffectiveStake returns the validator's own stake plus the stake delegated to it
+func (v *Validator) EffectiveStake() *big.Int {
+	return new(big.Int).Add(bigOrZero(v.Stake), bigOrZero(v.Delegated))
+}
+
+// SetDelegationHooks replaces the reward pass-through hooks
+func (v *ValidatorManager) SetDelegationHooks(hooks *DelegationHooks) {
+	if hooks == nil {
+		hooks = &DelegationHooks{}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.hooks = hooks
+}
+
+// Delegate bonds stake of a delegator to a validator, adding to its effective stake
+func (v *ValidatorManager) Delegate(delegator, validator common.Address, amount *big.Int) error {
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("%w: non-positive amount", ErrInvalidDelegation)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	target, exists := v.validators[validator]
+	if !exists {
+		return fmt.Errorf("%w: validator %s not found", ErrInvalidDelegation, validator.Hex())
+	}
+	delegations := v.delegations[validator]
+	if delegations == nil {
+		delegations = make(map[common.Address]*Delegation)
+		v.delegations[validator] = delegations
+	}
+	delegation := delegations[delegator]
+	if delegation == nil {
+		delegation = &Delegation{
+			Delegator: delegator,
+			Validator: validator,
+			Amount:    new(big.Int),
+			Rewards:   new(big.Int),
+		}
+		delegations[delegator] = delegation
+	}
+	delegation.Amount.Add(delegation.Amount, amount)
+	target.Delegated = new(big.Int).Add(bigOrZero(target.Delegated), amount)
+	v.persist(validator)
+	return nil
+}
+
+// Undelegate unbonds stake of a delegator from a validator. The delegation is
+// dropped once neither stake nor unclaimed rewards are left.
+func (v *ValidatorManager) Undelegate(delegator, validator common.Address, amount *big.Int) error {
+	if amount == nil || amount.Sign() <= 0 {
+		return fmt.Errorf("%w: non-positive amount", ErrInvalidDelegation)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delegation := v.delegations[validator][delegator]
+	if delegation == nil || delegation.Amount.Cmp(amount) < 0 {
+		return fmt.Errorf("%w: %s bonded to %s", ErrInsufficientDelegation, delegator.Hex(), validator.Hex())
+	}
+	delegation.Amount.Sub(delegation.Amount, amount)
+	if target := v.validators[validator]; target != nil {
+		target.Delegated = new(big.Int).Sub(bigOrZero(target.Delegated), amount)
+	}
+	v.pruneDelegation(delegation)
+	v.persist(validator)
+	return nil
+}
+
+// pruneDelegation drops a delegation without stake or unclaimed rewards
+func (v *ValidatorManager) pruneDelegation(delegation *Delegation) {
+	if delegation.Amount.Sign() != 0 || delegation.Rewards.Sign() != 0 {
+		return
+	}
+	delete(v.delegations[delegation.Validator], delegation.Delegator)
+	if len(v.delegations[delegation.Validator]) == 0 {
+		delete(v.delegations, delegation.Validator)
+	}
+}
+
+// GetDelegation returns a delegator's delegation to a validator, nil if none
+func (v *ValidatorManager) GetDelegation(delegator, validator common.Address) *Delegation {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if delegation := v.delegations[validator][delegator]; delegation != nil {
+		return delegation.copy()
+	}
+	return nil
+}
+
+// GetDelegations returns the delegations to a validator, by delegator address
+func (v *ValidatorManager) GetDelegations(validator common.Address) []*Delegation {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	delegations := make([]*Delegation, 0, len(v.delegations[validator]))
+	for _, delegation := range v.sortedDelegations(validator) {
+		delegations = append(delegations, delegation.copy())
+	}
+	return delegations
+}
+
+// GetDelegatorDelegations returns the delegations of a delegator, by validator address
+func (v *ValidatorManager) GetDelegatorDelegations(delegator common.Address) []*Delegation {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var delegations []*Delegation
+	for _, byDelegator := range v.delegations {
+		if delegation := byDelegator[delegator]; delegation != nil {
+			delegations = append(delegations, delegation.copy())
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		return bytes.Compare(delegations[i].Validator[:], delegations[j].Validator[:]) < 0
+	})
+	return delegations
+}
+
+// sortedDelegations returns the delegations to a validator by delegator address
+func (v *ValidatorManager) sortedDelegations(validator common.Address) []*Delegation {
+	delegations := make([]*Delegation, 0, len(v.delegations[validator]))
+	for _, delegation := range v.delegations[validator] {
+		delegations = append(delegations, delegation)
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		return bytes.Compare(delegations[i].Delegator[:], delegations[j].Delegator[:]) < 0
+	})
+	return delegations
+}
+
+// DistributeReward splits a validator reward by stake: each delegator is credited
+// its share of the effective stake, rounded down, and passed it through the
+// hooks. The validator keeps the rest, which is returned.
+func (v *ValidatorManager) DistributeReward(validator common.Address, amount *big.Int) (*big.Int, error) {
+	if amount == nil || amount.Sign() < 0 {
+		return nil, errors.New("invalid reward amount")
+	}
+
+	v.mu.Lock()
+	target, exists := v.validators[validator]
+	if !exists {
+		v.mu.Unlock()
+		return nil, errors.New("validator not found")
+	}
+	effective := target.EffectiveStake()
+	own := new(big.Int).Set(amount)
+	shares := make(map[common.Address]*big.Int)
+	delegations := v.sortedDelegations(validator)
+	for _, delegation := range delegations {
+		if effective.Sign() == 0 || delegation.Amount.Sign() == 0 {
+			continue
+		}
+		share := new(big.Int).Mul(amount, delegation.Amount)
+		share.Div(share, effective)
+		delegation.Rewards.Add(delegation.Rewards, share)
+		own.Sub(own, share)
+		shares[delegation.Delegator] = share
+	}
+	v.persist(validator)
+	hooks := v.hooks
+	v.mu.Unlock()
+
+	if hooks.DelegatorReward != nil {
+		for _, delegation := range delegations {
+			if share := shares[delegation.Delegator]; share != nil {
+				hooks.DelegatorReward(validator, delegation.Delegator, new(big.Int).Set(share))
+			}
+		}
+	}
+	return own, nil
+}
+
+// ClaimDelegatorRewards returns and resets the rewards a delegator accrued with a validator
+func (v *ValidatorManager) ClaimDelegatorRewards(delegator, validator common.Address) (*big.Int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delegation := v.delegations[validator][delegator]
+	if delegation == nil {
+		return nil, fmt.Errorf("%w: %s bonded to %s", ErrInsufficientDelegation, delegator.Hex(), validator.Hex())
+	}
+	rewards := new(big.Int).Set(delegation.Rewards)
+	delegation.Rewards.SetInt64(0)
+	v.pruneDelegation(delegation)
+	v.persist(validator)
+	return rewards, nil
+}
@@ -0,0 +1,222 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// evidenceWindow is the number of heights below the highest one seen that
+	// block signatures are kept for
+	evidenceWindow = 256
+
+	// evidenceFutureMargin is the number of heights beyond the head that block
+	// signatures are accepted for, so a validator cannot advance the window with
+	// a signature of a far-future block and prune the evidence against it
+	evidenceFutureMargin = 2
+)
+
+// ErrInvalidBlockSignature is returned for a block signature that is malformed or
+// not made with the validator's registered BLS key
+var ErrInvalidBlockSignature = errors.New("invalid block signature")
+
+// BlockSignature is a validator's BLS signature of a B1 or B2 block
+type BlockSignature struct {
+	Validator common.Address `json:"validator"`
+	BlockType uint8          `json:"blockType"` // 1 for B1 blocks, 2 for B2 blocks
+	Header    *types.Header  `json:"header"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// Height returns the number of the signed block
+func (s *BlockSignature) Height() uint64 {
+	if s.Header == nil || s.Header.Number == nil {
+		return 0
+	}
+	return s.Header.Number.Uint64()
+}
+
+// SigningHash returns the hash the validator signed
+func (s *BlockSignature) SigningHash() common.Hash {
+	return blockSigningHash(s.BlockType, s.Header.Hash())
+}
+
+// verify checks the signature against a validator's registered BLS key
+func (s *BlockSignature) verify(publicKey []byte) error {
+	if s.Header == nil || s.Header.Number == nil {
+		return fmt.Errorf("%w: missing header", ErrInvalidBlockSignature)
+	}
+	if s.BlockType != 1 && s.BlockType != 2 {
+		return fmt.Errorf("%w: block type %d", ErrInvalidBlockSignature, s.BlockType)
+	}
+	if len(publicKey) == 0 {
+		return fmt.Errorf("%w: %s has no BLS key", ErrInvalidBlockSignature, s.Validator.Hex())
+	}
+	pub, err := decodeBLSPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlockSignature, err)
+	}
+	sig, err := decodeBLSSignature(s.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBlockSignature, err)
+	}
+	hash := s.SigningHash()
+	if ok, err := verifyBLS(pub, hash.Bytes(), blsSignatureDST, sig); err != nil || !ok {
+		return fmt.Errorf("%w: not signed by %s", ErrInvalidBlockSignature, s.Validator.Hex())
+	}
+	return nil
+}
+
+// DoubleSignEvidence proves that a validator signed two different blocks of the
+// same type at one height
+type DoubleSignEvidence struct {
+	First  *BlockSignature `json:"first"`
+	Second *BlockSignature `json:"second"`
+}
+
+// Offense implements SlashingEvidence
+func (e *DoubleSignEvidence) Offense() SlashingOffense { return OffenseDoubleSign }
+
+// Height returns the height of the conflicting blocks
+func (e *DoubleSignEvidence) Height() uint64 {
+	if e.First == nil {
+		return 0
+	}
+	return e.First.Height()
+}
+
+// verify checks that the evidence proves a double sign with the offender's key
+func (e *DoubleSignEvidence) verify(publicKey []byte) error {
+	if e.First == nil || e.Second == nil {
+		return fmt.Errorf("%w: missing signature", ErrInvalidEvidence)
+	}
+	if e.First.Validator != e.Second.Validator {
+		return fmt.Errorf("%w: signatures of different validators", ErrInvalidEvidence)
+	}
+	if e.First.BlockType != e.Second.BlockType {
+		return fmt.Errorf("%w: signatures of B%d and B%d blocks", ErrInvalidEvidence, e.First.BlockType, e.Second.BlockType)
+	}
+	for i, signature := range []*BlockSignature{e.First, e.Second} {
+		if err := signature.verify(publicKey); err != nil {
+			return fmt.Errorf("%w: signature %d: %v", ErrInvalidEvidence, i+1, err)
+		}
+	}
+	if e.First.Height() != e.Second.Height() {
+		return fmt.Errorf("%w: blocks %d and %d", ErrInvalidEvidence, e.First.Height(), e.Second.Height())
+	}
+	if e.First.Header.Hash() == e.Second.Header.Hash() {
+		return fmt.Errorf("%w: signatures of the same block", ErrInvalidEvidence)
+	}
+	return nil
+}
+
+// signatureSlot identifies the block a validator may sign at a height
+type signatureSlot struct {
+	validator common.Address
+	height    uint64
+	blockType uint8
+}
+
+// EvidenceCollector records the block signatures it sees and produces evidence
+// when a validator signs two different blocks of a type at the same height
+type EvidenceCollector struct {
+	validators *ValidatorManager
+	signatures map[signatureSlot]*BlockSignature // First signature seen per slot
+	reported   map[signatureSlot]bool
+	evidence   []*DoubleSignEvidence
+	highest    uint64
+	mu         sync.Mutex
+}
+
+// NewEvidenceCollector creates an evidence collector checking signatures against
+// the validators' registered BLS keys
+func NewEvidenceCollector(validators *ValidatorManager) *EvidenceCollector {
+	return &EvidenceCollector{
+		validators: validators,
+		signatures: make(map[signatureSlot]*BlockSignature),
+		reported:   make(map[signatureSlot]bool),
+	}
+}
+
+// Observe verifies and records a block signature seen with the chain at the given
+// head. It returns evidence the first time the validator is seen signing a
+// different block in the same slot.
+func (c *EvidenceCollector) Observe(head uint64, signature *BlockSignature) (*DoubleSignEvidence, error) {
+	if signature == nil {
+		return nil, fmt.Errorf("%w: missing", ErrInvalidBlockSignature)
+	}
+	if height := signature.Height(); height > head+evidenceFutureMargin {
+		return nil, fmt.Errorf("%w: block %d beyond head %d", ErrInvalidBlockSignature, height, head)
+	}
+	validator := c.validators.GetValidator(signature.Validator)
+	if validator == nil {
+		return nil, fmt.Errorf("%w: %s is not a validator", ErrInvalidBlockSignature, signature.Validator.Hex())
+	}
+	if err := signature.verify(validator.BLSPublicKey); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	height := signature.Height()
+	if c.highest >= evidenceWindow && height <= c.highest-evidenceWindow {
+		return nil, nil
+	}
+	if height > c.highest {
+		c.highest = height
+		c.prune()
+	}
+	slot := signatureSlot{validator: signature.Validator, height: height, blockType: signature.BlockType}
+	first, seen := c.signatures[slot]
+	if !seen {
+		c.signatures[slot] = signature
+		return nil, nil
+	}
+	if c.reported[slot] || first.Header.Hash() == signature.Header.Hash() {
+		return nil, nil
+	}
+	evidence := &DoubleSignEvidence{First: first, Second: signature}
+	c.reported[slot] = true
+	c.evidence = append(c.evidence, evidence)
+	return evidence, nil
+}
+
+// prune drops the signatures and evidence below the evidence window. The caller
+// must hold the lock.
+func (c *EvidenceCollector) prune() {
+	if c.highest < evidenceWindow {
+		return
+	}
+	for slot := range c.signatures {
+		if slot.height <= c.highest-evidenceWindow {
+			delete(c.signatures, slot)
+			delete(c.reported, slot)
+		}
+	}
+	kept := c.evidence[:0]
+	for _, evidence := range c.evidence {
+		if evidence.Height() > c.highest-evidenceWindow {
+			kept = append(kept, evidence)
+		}
+	}
+	c.evidence = kept
+}
+
+// Evidence returns the double signs found within the evidence window, by height
+func (c *EvidenceCollector) Evidence() []*DoubleSignEvidence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	evidence := append([]*DoubleSignEvidence(nil), c.evidence...)
+	sort.SliceStable(evidence, func(i, j int) bool {
+		return evidence[i].Height() < evidence[j].Height()
+	})
+	return evidence
+}
@@ -0,0 +1,277 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Emergency halt actions
+const (
+	HaltActionHalt   = "halt"
+	HaltActionResume = "resume"
+)
+
+// haltRecordKey stores the active halt record of a P2S database
+var haltRecordKey = []byte("p2s-halt-record")
+
+var (
+	// ErrChainHalted is returned when a pair is prepared at or above an emergency halt height
+	ErrChainHalted = errors.New("chain halted by emergency procedure")
+
+	// ErrInvalidHaltMessage is returned for halt or resume messages that cannot be accepted
+	ErrInvalidHaltMessage = errors.New("invalid halt message")
+)
+
+// HaltMessage is a signed vote to halt pair production at a height, or to resume
+// after the halt at that height
+type HaltMessage struct {
+	Action    string         `json:"action"`
+	Height    uint64         `json:"height"`
+	Reason    string         `json:"reason"`
+	Signer    common.Address `json:"signer"`
+	Signature []byte         `json:"signature"`
+}
+
+// SigningHash returns the hash signed by the voter; votes for the same action,
+// height and reason are counted together
+func (h *HaltMessage) SigningHash() common.Hash {
+	height := make([]byte, 8)
+	binary.BigEndian.PutUint64(height, h.Height)
+	return crypto.Keccak256Hash([]byte("p2s-emergency-halt"), []byte(h.Action), height, []byte(h.Reason))
+}
+
+// HaltRecord is a halt that reached quorum. It is persisted so a restarted node
+// stays halted until a signed resume reaches quorum.
+type HaltRecord struct {
+	Height  uint64           `json:"height"`
+	Reason  string           `json:"reason"`
+	Signers []common.Address `json:"signers"`
+	Time    uint64           `json:"time"`
+}
+
+// HaltStatus reports the emergency halt state of a node
+type HaltStatus struct {
+	Halt    *HaltRecord    `json:"halt,omitempty"`
+	Pending map[string]int `json:"pending"` // Votes collected per action:height:reason without quorum
+	Quorum  string         `json:"quorum"`  // Description of the quorum rule in force
+}
+
+// EmergencyHalt collects signed halt and resume votes. On permissioned networks
+// with configured operators a fixed operator quorum decides; otherwise validators
+// holding more than two thirds of active stake must sign.
+type EmergencyHalt struct {
+	validators *ValidatorManager
+	operators  map[common.Address]bool
+	quorum     int
+	db         ethdb.KeyValueStore
+
+	votes  map[common.Hash]map[common.Address]*HaltMessage
+	record *HaltRecord
+	mu     sync.Mutex
+}
+
+// NewEmergencyHalt creates an emergency halt procedure. A non-empty operator set
+// with a positive quorum replaces the validator supermajority rule.
+func NewEmergencyHalt(validators *ValidatorManager, operators []common.Address, quorum int) *EmergencyHalt {
+	h := &EmergencyHalt{
+		validators: validators,
+		operators:  make(map[common.Address]bool, len(operators)),
+		votes:      make(map[common.Hash]map[common.Address]*HaltMessage),
+	}
+	for _, operator := range operators {
+		h.operators[operator] = true
+	}
+	if quorum > 0 && len(h.operators) > 0 {
+		h.quorum = quorum
+	}
+	return h
+}
+
+// SetDatabase attaches a database, restoring any persisted halt record
+func (h *EmergencyHalt) SetDatabase(db ethdb.KeyValueStore) error {
+	record, err := ReadHaltRecord(db)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.db = db
+	if record != nil {
+		h.record = record
+		log.Warn("Chain halted by persisted emergency halt", "height", record.Height, "reason", record.Reason)
+	}
+	return nil
+}
+
+// Submit verifies and counts a halt or resume vote. It returns true once the vote
+// brings its action to quorum and the action takes effect.
+func (h *EmergencyHalt) Submit(msg *HaltMessage) (bool, error) {
+	if msg == nil || (msg.Action != HaltActionHalt && msg.Action != HaltActionResume) {
+		return false, fmt.Errorf("%w: unknown action", ErrInvalidHaltMessage)
+	}
+	hash := msg.SigningHash()
+	pubKey, err := crypto.SigToPub(hash.Bytes(), msg.Signature)
+	if err != nil || crypto.PubkeyToAddress(*pubKey) != msg.Signer {
+		return false, fmt.Errorf("%w: bad signature", ErrInvalidHaltMessage)
+	}
+	if !h.eligible(msg.Signer) {
+		return false, fmt.Errorf("%w: %s may not vote", ErrInvalidHaltMessage, msg.Signer.Hex())
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch msg.Action {
+	case HaltActionHalt:
+		if h.record != nil {
+			return false, fmt.Errorf("%w: already halted at %d", ErrInvalidHaltMessage, h.record.Height)
+		}
+	case HaltActionResume:
+		if h.record == nil || h.record.Height != msg.Height {
+			return false, fmt.Errorf("%w: no halt at height %d", ErrInvalidHaltMessage, msg.Height)
+		}
+	}
+
+	if h.votes[hash] == nil {
+		h.votes[hash] = make(map[common.Address]*HaltMessage)
+	}
+	h.votes[hash][msg.Signer] = msg
+
+	signers := make([]common.Address, 0, len(h.votes[hash]))
+	for signer := range h.votes[hash] {
+		signers = append(signers, signer)
+	}
+	if !h.reached(signers) {
+		return false, nil
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return signers[i].Hex() < signers[j].Hex()
+	})
+
+	switch msg.Action {
+	case HaltActionHalt:
+		record := &HaltRecord{
+			Height:  msg.Height,
+			Reason:  msg.Reason,
+			Signers: signers,
+			Time:    uint64(time.Now().Unix()),
+		}
+		if h.db != nil {
+			if err := WriteHaltRecord(h.db, record); err != nil {
+				return false, err
+			}
+		}
+		h.record = record
+		log.Error("Emergency halt reached quorum", "height", msg.Height, "reason", msg.Reason, "signers", len(signers))
+	case HaltActionResume:
+		if h.db != nil {
+			if err := h.db.Delete(haltRecordKey); err != nil {
+				return false, err
+			}
+		}
+		h.record = nil
+		log.Warn("Emergency halt lifted", "height", msg.Height, "signers", len(signers))
+	}
+
+	// Votes for the completed action, or collected under the previous state, no longer apply
+	h.votes = make(map[common.Hash]map[common.Address]*HaltMessage)
+	return true, nil
+}
+
+// eligible reports whether an address may vote
+func (h *EmergencyHalt) eligible(signer common.Address) bool {
+	if h.quorum > 0 {
+		return h.operators[signer]
+	}
+	return h.validators.IsActiveValidator(signer)
+}
+
+// reached reports whether the signers form a quorum
+func (h *EmergencyHalt) reached(signers []common.Address) bool {
+	if h.quorum > 0 {
+		return len(signers) >= h.quorum
+	}
+
+	total := h.validators.GetTotalStake()
+	if total.Sign() == 0 {
+		return false
+	}
+	signed := new(big.Int)
+	for _, signer := range signers {
+		if validator := h.validators.GetValidator(signer); validator != nil && validator.IsActive {
+			signed.Add(signed, validator.EffectiveStake())
+		}
+	}
+	// Supermajority: signed stake * 3 > total stake * 2
+	return new(big.Int).Mul(signed, big.NewInt(3)).Cmp(new(big.Int).Mul(total, big.NewInt(2))) > 0
+}
+
+// Halted reports whether pairs at the given height must not be produced
+func (h *EmergencyHalt) Halted(number uint64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.record != nil && number >= h.record.Height
+}
+
+// Status returns the halt record in force and the votes collected so far
+func (h *EmergencyHalt) Status() *HaltStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	status := &HaltStatus{
+		Halt:    h.record,
+		Pending: make(map[string]int),
+		Quorum:  "validators holding more than 2/3 of active stake",
+	}
+	if h.quorum > 0 {
+		status.Quorum = fmt.Sprintf("%d of %d operators", h.quorum, len(h.operators))
+	}
+	for _, votes := range h.votes {
+		for _, msg := range votes {
+			status.Pending[fmt.Sprintf("%s:%d:%s", msg.Action, msg.Height, msg.Reason)] = len(votes)
+			break
+		}
+	}
+	return status
+}
+
+// ReadHaltRecord reads the persisted halt record, nil if the chain is not halted
+func ReadHaltRecord(db ethdb.KeyValueReader) (*HaltRecord, error) {
+	has, err := db.Has(haltRecordKey)
+	if err != nil || !has {
+		return nil, err
+	}
+	data, err := db.Get(haltRecordKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var record HaltRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("invalid halt record: %v", err)
+	}
+	return &record, nil
+}
+
+// WriteHaltRecord persists a halt record
+func WriteHaltRecord(db ethdb.KeyValueWriter, record *HaltRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return db.Put(haltRecordKey, data)
+}
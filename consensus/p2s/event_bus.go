@@ -0,0 +1,352 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// EventKind identifies the type of a domain event
+type EventKind string
+
+// Domain events published on the event bus
+const (
+	EventPairFinalized    EventKind = "pairFinalized"
+	EventRevealExpired    EventKind = "revealExpired"
+	EventValidatorSlashed EventKind = "validatorSlashed"
+	EventMissedReveal     EventKind = "missedReveal"
+)
+
+const (
+	// eventLogLimit is the number of events retained for replay
+	eventLogLimit = 65536
+
+	// eventBufferSize is the channel buffer of each subscription
+	eventBufferSize = 64
+)
+
+var (
+	// eventPrefix + big-endian sequence number -> JSON encoded BusEvent
+	eventPrefix = []byte("p2s-event-")
+
+	// eventCursorPrefix + consumer name -> big-endian sequence number of the last acknowledged event
+	eventCursorPrefix = []byte("p2s-cursor-event-")
+)
+
+// ErrEventsPruned is returned when a replay starts before the oldest retained event
+var ErrEventsPruned = errors.New("requested events are no longer retained")
+
+// PairFinalized is published when a B2 block completes a B1/B2 pair
+type PairFinalized struct {
+	B1Hash  common.Hash `json:"b1Hash"`
+	B2Hash  common.Hash `json:"b2Hash"`
+	Reveals int         `json:"reveals"`
+}
+
+// RevealExpired is published when a PHT of a finalized pair was never revealed
+type RevealExpired struct {
+	B1Hash common.Hash    `json:"b1Hash"`
+	TxHash common.Hash    `json:"txHash"`
+	Sender common.Address `json:"sender"`
+}
+
+// ValidatorSlashed is published when a validator loses stake
+type ValidatorSlashed struct {
+	Validator common.Address `json:"validator"`
+	Amount    *big.Int       `json:"amount"`
+	Reason    string         `json:"reason"`
+}
+
+// BusEvent is a domain event as delivered to subscribers. Exactly one of the
+// typed payloads is set, matching Kind.
+type BusEvent struct {
+	Seq   uint64    `json:"seq"`
+	Block uint64    `json:"block"`
+	Kind  EventKind `json:"kind"`
+	Time  uint64    `json:"time"`
+
+	PairFinalized    *PairFinalized    `json:"pairFinalized,omitempty"`
+	RevealExpired    *RevealExpired    `json:"revealExpired,omitempty"`
+	ValidatorSlashed *ValidatorSlashed `json:"validatorSlashed,omitempty"`
+	MissedReveal     *MissedReveal     `json:"missedReveal,omitempty"`
+}
+
+// EventBus distributes domain events to subscribers. Events are retained, and
+// persisted when a database is attached, so consumers can replay from a past block
+// or resume after the last event they acknowledged.
+type EventBus struct {
+	db     ethdb.KeyValueStore
+	events []*BusEvent // Retained events, oldest first
+	seq    uint64
+	subs   map[*EventSubscription]struct{}
+	mu     sync.Mutex
+}
+
+// NewEventBus creates an in-memory event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// SetDatabase attaches a database, restoring retained events from it
+func (b *EventBus) SetDatabase(db ethdb.KeyValueStore) error {
+	it := db.NewIterator(eventPrefix, nil)
+	defer it.Release()
+
+	var events []*BusEvent
+	for it.Next() {
+		event := new(BusEvent)
+		if err := json.Unmarshal(it.Value(), event); err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.db = db
+	if len(events) > 0 && events[len(events)-1].Seq > b.seq {
+		b.events = events
+		b.seq = events[len(events)-1].Seq
+	}
+	return nil
+}
+
+// Publish assigns an event its sequence number, retains it and delivers it to subscribers
+func (b *EventBus) Publish(event *BusEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	if event.Time == 0 {
+		event.Time = uint64(time.Now().Unix())
+	}
+
+	b.events = append(b.events, event)
+	var dropped *BusEvent
+	if len(b.events) > eventLogLimit {
+		dropped = b.events[0]
+		b.events = append([]*BusEvent(nil), b.events[1:]...)
+	}
+	if b.db != nil {
+		if err := b.persist(event, dropped); err != nil {
+			log.Error("Failed to persist event", "kind", event.Kind, "seq", event.Seq, "err", err)
+		}
+	}
+
+	for sub := range b.subs {
+		sub.enqueue(event)
+	}
+}
+
+// persist writes an event and deletes the one that left the retention window
+func (b *EventBus) persist(event, dropped *BusEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	batch := b.db.NewBatch()
+	if err := batch.Put(eventKey(event.Seq), data); err != nil {
+		return err
+	}
+	if dropped != nil {
+		if err := batch.Delete(eventKey(dropped.Seq)); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// Subscribe replays retained events from fromBlock onwards, all retained events for
+// 0, and then delivers live events. Only the given kinds are delivered, all kinds if
+// none are given.
+func (b *EventBus) Subscribe(consumer string, fromBlock uint64, kinds ...EventKind) (*EventSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if fromBlock > 0 && len(b.events) > 0 && b.events[0].Seq > 1 && b.events[0].Block > fromBlock {
+		return nil, ErrEventsPruned
+	}
+	start := len(b.events)
+	for i, event := range b.events {
+		if event.Block >= fromBlock {
+			start = i
+			break
+		}
+	}
+	return b.subscribe(consumer, b.events[start:], kinds), nil
+}
+
+// Resume delivers every event after the last one the consumer acknowledged
+func (b *EventBus) Resume(consumer string, kinds ...EventKind) (*EventSubscription, error) {
+	cursor, err := b.Cursor(consumer)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.events) > 0 && b.events[0].Seq > cursor+1 {
+		return nil, ErrEventsPruned
+	}
+	start := len(b.events)
+	for i, event := range b.events {
+		if event.Seq > cursor {
+			start = i
+			break
+		}
+	}
+	return b.subscribe(consumer, b.events[start:], kinds), nil
+}
+
+// subscribe registers a subscription with its replay backlog; b.mu must be held
+func (b *EventBus) subscribe(consumer string, backlog []*BusEvent, kinds []EventKind) *EventSubscription {
+	sub := &EventSubscription{
+		bus:      b,
+		consumer: consumer,
+		kinds:    make(map[EventKind]bool, len(kinds)),
+		ch:       make(chan *BusEvent, eventBufferSize),
+		notify:   make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+	}
+	for _, kind := range kinds {
+		sub.kinds[kind] = true
+	}
+	for _, event := range backlog {
+		sub.enqueue(event)
+	}
+	b.subs[sub] = struct{}{}
+
+	go sub.loop()
+	return sub
+}
+
+// Cursor returns the sequence number of the last event a consumer acknowledged
+func (b *EventBus) Cursor(consumer string) (uint64, error) {
+	b.mu.Lock()
+	db := b.db
+	b.mu.Unlock()
+	if db == nil {
+		return 0, nil
+	}
+
+	key := append(common.CopyBytes(eventCursorPrefix), consumer...)
+	has, err := db.Has(key)
+	if err != nil || !has {
+		return 0, err
+	}
+	data, err := db.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, errors.New("invalid event cursor encoding")
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// eventKey returns the database key of an event
+func eventKey(seq uint64) []byte {
+	key := make([]byte, len(eventPrefix)+8)
+	copy(key, eventPrefix)
+	binary.BigEndian.PutUint64(key[len(eventPrefix):], seq)
+	return key
+}
+
+// EventSubscription delivers bus events to a consumer in sequence order
+type EventSubscription struct {
+	bus      *EventBus
+	consumer string
+	kinds    map[EventKind]bool
+	ch       chan *BusEvent
+
+	queue  []*BusEvent
+	notify chan struct{}
+	quit   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+// Events returns the channel events are delivered on
+func (s *EventSubscription) Events() <-chan *BusEvent {
+	return s.ch
+}
+
+// Ack persists the consumer's cursor so Resume continues after seq
+func (s *EventSubscription) Ack(seq uint64) error {
+	s.bus.mu.Lock()
+	db := s.bus.db
+	s.bus.mu.Unlock()
+	if db == nil {
+		return errors.New("no database attached")
+	}
+
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, seq)
+	return db.Put(append(common.CopyBytes(eventCursorPrefix), s.consumer...), data)
+}
+
+// Unsubscribe stops delivery; the events channel is not closed
+func (s *EventSubscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.quit)
+	})
+}
+
+// enqueue queues an event for delivery if the subscription wants its kind
+func (s *EventSubscription) enqueue(event *BusEvent) {
+	if len(s.kinds) > 0 && !s.kinds[event.Kind] {
+		return
+	}
+
+	s.mu.Lock()
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// loop delivers queued events so publishers never block on slow consumers
+func (s *EventSubscription) loop() {
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.notify:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- event:
+		case <-s.quit:
+			return
+		}
+	}
+}
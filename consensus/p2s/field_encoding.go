@@ -0,0 +1,118 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FieldEncoding identifies how hidden fields are serialized for the PHT
+// commitment. It is carried in PHTs and MTs so both sides of a reveal encode the
+// fields the same way.
+type FieldEncoding uint8
+
+const (
+	// FieldEncodingLegacy writes numbers at their minimal width and the gas limit as
+	// its low byte only, so gas limits differing above 255 share a commitment. It is
+	// the zero value so PHTs created before encodings were versioned still verify.
+	FieldEncodingLegacy FieldEncoding = iota
+
+	// FieldEncodingFixedWidth writes every numeric field big-endian at a fixed
+	// width: 1 byte for the type, 8 for the gas limit and 32 for amounts. The
+	// recipient of a contract creation is empty, unlike a zero-address transfer.
+	FieldEncodingFixedWidth
+
+	// FieldEncodingNonceBound is FieldEncodingFixedWidth followed by the visible
+	// account nonce, so a reveal cannot be reordered against the sender's other PHTs
+	FieldEncodingNonceBound
+
+	numFieldEncodings
+)
+
+// CurrentFieldEncoding is the encoding of newly created PHTs
+const CurrentFieldEncoding = FieldEncodingNonceBound
+
+// ErrUnknownFieldEncoding is returned for field encodings this node does not know
+var ErrUnknownFieldEncoding = errors.New("unknown field encoding")
+
+// Validate checks that an encoding is known
+func (e FieldEncoding) Validate() error {
+	if e >= numFieldEncodings {
+		return fmt.Errorf("%w: %d", ErrUnknownFieldEncoding, e)
+	}
+	return nil
+}
+
+// encodeUint8 encodes a single-byte field
+func encodeUint8(v uint8) []byte {
+	return []byte{v}
+}
+
+// encodeUint64 encodes a 64-bit field as 8 big-endian bytes
+func encodeUint64(v uint64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, v)
+	return encoded
+}
+
+// encodeBig encodes an amount as 32 big-endian bytes, nil as zero
+func encodeBig(v *big.Int) []byte {
+	return common.LeftPadBytes(bigOrZero(v).Bytes(), 32)
+}
+
+// encodeFees encodes a gas price followed by the EIP-1559 fee caps when present
+func encodeFees(fields *HiddenFields) []byte {
+	encoded := encodeBig(fields.GasPrice)
+	if fields.MaxFeePerGas != nil || fields.MaxPriorityFeePerGas != nil {
+		encoded = append(encoded, encodeBig(fields.MaxFeePerGas)...)
+		encoded = append(encoded, encodeBig(fields.MaxPriorityFeePerGas)...)
+	}
+	return encoded
+}
+
+// fixedWidthEncoders encode each selectable field at fixed width
+var fixedWidthEncoders = [numSelectableFields]func(fields *HiddenFields) []byte{
+	FieldRecipient: func(fields *HiddenFields) []byte {
+		if fields.IsContractCreation {
+			return nil
+		}
+		return fields.Recipient.Bytes()
+	},
+	FieldValue:    func(fields *HiddenFields) []byte { return encodeBig(fields.Value) },
+	FieldCallData: func(fields *HiddenFields) []byte { return fields.CallData },
+	FieldTxType:   func(fields *HiddenFields) []byte { return encodeUint8(fields.TxType) },
+	FieldGasLimit: func(fields *HiddenFields) []byte { return encodeUint64(fields.GasLimit) },
+	FieldGasPrice: encodeFees,
+	FieldSender:   func(fields *HiddenFields) []byte { return fields.Sender.Bytes() },
+}
+
+// fieldEncoders encode each selectable field for the PHT commitment, per encoding
+var fieldEncoders = [numFieldEncodings][numSelectableFields]func(fields *HiddenFields) []byte{
+	FieldEncodingLegacy: {
+		FieldRecipient: func(fields *HiddenFields) []byte { return fields.Recipient.Bytes() },
+		FieldValue:     func(fields *HiddenFields) []byte { return bigOrZero(fields.Value).Bytes() },
+		FieldCallData:  func(fields *HiddenFields) []byte { return fields.CallData },
+		FieldTxType:    func(fields *HiddenFields) []byte { return encodeUint8(fields.TxType) },
+		FieldGasLimit:  func(fields *HiddenFields) []byte { return encodeUint8(byte(fields.GasLimit)) },
+		FieldGasPrice:  encodeFees,
+		FieldSender:    func(fields *HiddenFields) []byte { return fields.Sender.Bytes() },
+	},
+	FieldEncodingFixedWidth: fixedWidthEncoders,
+	FieldEncodingNonceBound: fixedWidthEncoders,
+}
+
+// encodeBlobFeeCap encodes the blob gas fee cap of a blob transaction
+func (e FieldEncoding) encodeBlobFeeCap(v *big.Int) []byte {
+	if e == FieldEncodingLegacy {
+		return bigOrZero(v).Bytes()
+	}
+	return encodeBig(v)
+}
+
+// bindsAccountNonce reports whether an encoding commits to the account nonce
+func (e FieldEncoding) bindsAccountNonce() bool {
+	return e >= FieldEncodingNonceBound
+}
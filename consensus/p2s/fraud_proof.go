@@ -0,0 +1,170 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrNoFraud is returned when asked to prove fraud for a valid PHT→MT pair
+	ErrNoFraud = errors.New("MT matches its PHT")
+
+	// ErrInvalidFraudProof is returned for a fraud proof that does not show fraud
+	ErrInvalidFraudProof = errors.New("invalid fraud proof")
+)
+
+// FraudReason names the check a fraudulent MT fails
+type FraudReason string
+
+// Reveal violations a fraud proof can show. Each is checkable from the visible
+// fields of the PHT and the published MT alone.
+const (
+	FraudPlaceholder     FraudReason = "placeholder"     // Placeholder not standing in for its PHT
+	FraudLayout          FraudReason = "layout"          // Hidden field set or encoding differs from the PHT's
+	FraudPHTHash         FraudReason = "phtHash"         // MT references another PHT
+	FraudAccountNonce    FraudReason = "accountNonce"    // Account nonce differs from the PHT's
+	FraudOpening         FraudReason = "opening"         // Revealed fields do not open the PHT commitment
+	FraudValueCommitment FraudReason = "valueCommitment" // Value does not open the PHT value commitment
+)
+
+// FraudProof shows that an MT published in a B2 block does not open its PHT's
+// commitment. It carries only the visible PHT fields and the MT without its
+// proofs. With its block context, the inclusion of both transactions at the same
+// position of a B1/B2 pair, it ties the fraud to the B2 proposer for slashing.
+type FraudProof struct {
+	PHT    *PHTTransaction `json:"pht"`
+	MT     *MTTransaction  `json:"mt"`
+	Reason FraudReason     `json:"reason"`
+
+	// Block context, empty for a proof about a bare PHT→MT pair
+	B1Header *types.Header `json:"b1Header,omitempty"`
+	B2Header *types.Header `json:"b2Header,omitempty"`
+	PHTProof hexutil.Bytes `json:"phtProof,omitempty"` // Merkle proof of the PHT hash under the PHT root
+	MTProof  hexutil.Bytes `json:"mtProof,omitempty"`  // Merkle proof of the MT leaf under the MT root
+}
+
+// Proposer returns the proposer of the B2 block the fraud was published in, if
+// the proof carries its block context
+func (f *FraudProof) Proposer() (common.Address, bool) {
+	if f.B2Header == nil {
+		return common.Address{}, false
+	}
+	return f.B2Header.Coinbase, true
+}
+
+// fraudEvidence returns a copy of an MT without the proofs its MT root leaf does
+// not commit to
+func fraudEvidence(mt *MTTransaction) *MTTransaction {
+	evidence := *mt
+	evidence.Proof, evidence.FieldOpenings = nil, nil
+	return &evidence
+}
+
+// publicViolation returns the first reveal violation of an MT that can be shown
+// from the visible fields of its PHT and the fields committed to by the MT leaf
+func (m *MTManager) publicViolation(mt *MTTransaction, pht *PHTTransaction) (FraudReason, error) {
+	mt = fraudEvidence(mt)
+	if mt.Unrevealed {
+		if err := m.VerifyPlaceholder(mt, pht); err != nil {
+			return FraudPlaceholder, err
+		}
+		return "", nil
+	}
+	if err := checkRevealLayout(mt, pht); err != nil {
+		return FraudLayout, err
+	}
+	if phtHash := pht.Hash(); mt.PHTHash != phtHash || mt.TxHash != pht.TxHash {
+		return FraudPHTHash, &ErrPHTHashMismatch{Expected: phtHash, Got: mt.PHTHash}
+	}
+	if mt.AccountNonce != pht.AccountNonce {
+		return FraudAccountNonce, &ErrAccountNonceMismatch{Expected: pht.AccountNonce, Got: mt.AccountNonce}
+	}
+	if err := m.VerifyOpening(mt, pht); err != nil {
+		return FraudOpening, err
+	}
+	if len(pht.ValueCommitment) > 0 && !VerifyValueCommitment(pht.ValueCommitment, mt.Value, mt.ValueBlinding) {
+		return FraudValueCommitment, ErrValueCommitmentOpening
+	}
+	return "", nil
+}
+
+// GenerateFraudProof creates a proof that an MT does not open its PHT, which any
+// node can check with VerifyFraudProof without the hidden fields of the PHT
+func (m *MTManager) GenerateFraudProof(mt *MTTransaction, pht *PHTTransaction) (*FraudProof, error) {
+	reason, err := m.publicViolation(mt, pht)
+	if err == nil {
+		return nil, ErrNoFraud
+	}
+	return &FraudProof{
+		PHT:    pht.WithoutHiddenFields(),
+		MT:     fraudEvidence(mt),
+		Reason: reason,
+	}, nil
+}
+
+// GenerateBlockFraudProof creates a fraud proof for the MT at index of a B2 block,
+// including the block context needed to slash its proposer
+func (m *MTManager) GenerateBlockFraudProof(b1Block *B1Block, b2Block *B2Block, index int) (*FraudProof, error) {
+	if index < 0 || index >= len(b2Block.MTs) || index >= len(b1Block.PHTs) {
+		return nil, fmt.Errorf("MT index %d out of range", index)
+	}
+	proof, err := m.GenerateFraudProof(b2Block.MTs[index], b1Block.PHTs[index])
+	if err != nil {
+		return nil, err
+	}
+
+	phtHashes := make([]common.Hash, len(b1Block.PHTs))
+	for i, pht := range b1Block.PHTs {
+		phtHashes[i] = pht.Hash()
+	}
+	merkle := NewMerkleProofSystem()
+	proof.B1Header = b1Block.Header
+	proof.B2Header = b2Block.Header
+	proof.PHTProof = merkle.generateMerkleProof(txTree(phtHashes), index)
+	proof.MTProof = merkle.generateMerkleProof(txTree(mtLeaves(b2Block.MTs)), index)
+	return proof, nil
+}
+
+// VerifyFraudProof checks that a fraud proof shows an MT failing the claimed
+// check against its PHT and, when present, that both were included at the same
+// position of a B1/B2 pair
+func (m *MTManager) VerifyFraudProof(proof *FraudProof) error {
+	if proof == nil || proof.PHT == nil || proof.MT == nil {
+		return fmt.Errorf("%w: incomplete", ErrInvalidFraudProof)
+	}
+	reason, err := m.publicViolation(proof.MT, proof.PHT)
+	if err == nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFraudProof, ErrNoFraud)
+	}
+	if reason != proof.Reason {
+		return fmt.Errorf("%w: claims %s, shows %s", ErrInvalidFraudProof, proof.Reason, reason)
+	}
+
+	if proof.B1Header == nil && proof.B2Header == nil {
+		return nil
+	}
+	if proof.B1Header == nil || proof.B2Header == nil {
+		return fmt.Errorf("%w: incomplete block context", ErrInvalidFraudProof)
+	}
+	if proof.B2Header.ParentHash != proof.B1Header.Hash() {
+		return fmt.Errorf("%w: B2 block does not follow B1 block", ErrInvalidFraudProof)
+	}
+	phtRoot, ok := TxRoot(proof.B1Header)
+	if !ok || !VerifyTxInclusion(phtRoot, proof.PHT.Hash(), proof.PHTProof) {
+		return fmt.Errorf("%w: PHT not in B1 block", ErrInvalidFraudProof)
+	}
+	mtRoot, ok := TxRoot(proof.B2Header)
+	if !ok || !VerifyTxInclusion(mtRoot, mtLeaf(proof.MT), proof.MTProof) {
+		return fmt.Errorf("%w: MT not in B2 block", ErrInvalidFraudProof)
+	}
+	// Pairs are matched by position
+	if binary.BigEndian.Uint32(proof.PHTProof[:4]) != binary.BigEndian.Uint32(proof.MTProof[:4]) {
+		return fmt.Errorf("%w: PHT and MT at different positions", ErrInvalidFraudProof)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+)
+
+// ErrParameterChangeTooFast is returned when a proposal moves a parameter faster than allowed
+var ErrParameterChangeTooFast = errors.New("parameter change exceeds rate limit")
+
+// ParameterLimit bounds how far a parameter may move per epoch. A zero bound is not enforced.
+type ParameterLimit struct {
+	MaxAbsDelta float64 // Maximum absolute change per epoch
+	MaxRelDelta float64 // Maximum change per epoch relative to the current value, e.g. 0.1 for 10%
+}
+
+// limitTolerance absorbs floating point error when comparing deltas to limits
+const limitTolerance = 1e-9
+
+// governedParameter reads a sensitive configuration parameter as a float
+type governedParameter struct {
+	name  string
+	value func(config *P2SConfig) float64
+}
+
+// governedParameters lists the configuration parameters subject to rate limits
+var governedParameters = []governedParameter{
+	{"MinMEVScore", func(c *P2SConfig) float64 { return c.MinMEVScore }},
+	{"MaxMEVScore", func(c *P2SConfig) float64 { return c.MaxMEVScore }},
+	{"B1BlockTime", func(c *P2SConfig) float64 { return c.B1BlockTime.Seconds() }},
+	{"B2BlockTime", func(c *P2SConfig) float64 { return c.B2BlockTime.Seconds() }}, // Reveal window
+	{"MinStake", func(c *P2SConfig) float64 { return bigToFloat(c.MinStake) }},
+	{"MaxValidators", func(c *P2SConfig) float64 { return float64(c.MaxValidators) }},
+	{"SealThreshold", func(c *P2SConfig) float64 { return float64(c.SealThreshold) }},
+	{"MinPHTGasPrice", func(c *P2SConfig) float64 { return bigToFloat(c.MinPHTGasPrice) }},
+}
+
+// DefaultParameterLimits returns the default per-epoch rate limits
+func DefaultParameterLimits() map[string]ParameterLimit {
+	return map[string]ParameterLimit{
+		"MinMEVScore":    {MaxAbsDelta: 0.05},
+		"MaxMEVScore":    {MaxAbsDelta: 0.05},
+		"B1BlockTime":    {MaxRelDelta: 0.1},
+		"B2BlockTime":    {MaxRelDelta: 0.1},
+		"MinStake":       {MaxRelDelta: 0.1},
+		"MaxValidators":  {MaxRelDelta: 0.1},
+		"SealThreshold":  {MaxAbsDelta: 1},
+		"MinPHTGasPrice": {MaxRelDelta: 0.25},
+	}
+}
+
+// GovernanceGuard validates configuration proposals against per-epoch rate limits
+type GovernanceGuard struct {
+	limits     map[string]ParameterLimit
+	lastChange map[string]uint64 // Epoch of the last accepted change per parameter
+	mu         sync.Mutex
+}
+
+// NewGovernanceGuard creates a guard with the given limits, or the defaults if nil
+func NewGovernanceGuard(limits map[string]ParameterLimit) *GovernanceGuard {
+	if limits == nil {
+		limits = DefaultParameterLimits()
+	}
+
+	return &GovernanceGuard{
+		limits:     limits,
+		lastChange: make(map[string]uint64),
+	}
+}
+
+// Validate checks that moving from current to proposed at epoch respects every limit.
+// Allowances accumulate over the epochs since a parameter last changed.
+func (g *GovernanceGuard) Validate(epoch uint64, current, proposed *P2SConfig) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, err := g.validate(epoch, current, proposed)
+	return err
+}
+
+// Apply validates a proposal and records the epoch of each changed parameter
+func (g *GovernanceGuard) Apply(epoch uint64, current, proposed *P2SConfig) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	changed, err := g.validate(epoch, current, proposed)
+	if err != nil {
+		return err
+	}
+	for _, name := range changed {
+		g.lastChange[name] = epoch
+	}
+	return nil
+}
+
+// validate returns the names of changed parameters, or an error if any moves too fast
+func (g *GovernanceGuard) validate(epoch uint64, current, proposed *P2SConfig) ([]string, error) {
+	var changed []string
+	for _, param := range governedParameters {
+		from, to := param.value(current), param.value(proposed)
+		if from == to {
+			continue
+		}
+		changed = append(changed, param.name)
+
+		limit, limited := g.limits[param.name]
+		if !limited {
+			continue
+		}
+
+		epochs := uint64(1)
+		if last, exists := g.lastChange[param.name]; exists {
+			if epoch <= last {
+				return nil, fmt.Errorf("%w: %s already changed in epoch %d", ErrParameterChangeTooFast, param.name, last)
+			}
+			epochs = epoch - last
+		}
+
+		delta := math.Abs(to - from)
+		if limit.MaxAbsDelta > 0 && delta > limit.MaxAbsDelta*float64(epochs)+limitTolerance {
+			return nil, fmt.Errorf("%w: %s moves by %g, at most %g over %d epochs",
+				ErrParameterChangeTooFast, param.name, delta, limit.MaxAbsDelta*float64(epochs), epochs)
+		}
+		if limit.MaxRelDelta > 0 && from != 0 && delta/math.Abs(from) > limit.MaxRelDelta*float64(epochs)+limitTolerance {
+			return nil, fmt.Errorf("%w: %s moves by %.1f%%, at most %.1f%% over %d epochs",
+				ErrParameterChangeTooFast, param.name, 100*delta/math.Abs(from), 100*limit.MaxRelDelta*float64(epochs), epochs)
+		}
+	}
+	return changed, nil
+}
+
+// bigToFloat converts a possibly nil big integer to a float
+func bigToFloat(value *big.Int) float64 {
+	if value == nil {
+		return 0
+	}
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
@@ -0,0 +1,143 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Fields a network may additionally hide until B2, following the vector
+// commitment positions
+const (
+	FieldGasPrice = numHiddenFields + iota // Gas price and EIP-1559 fee caps
+	FieldSender
+
+	numSelectableFields
+)
+
+// ErrInvalidHiddenFieldSet is returned for hidden field sets a network cannot use
+var ErrInvalidHiddenFieldSet = errors.New("invalid hidden field set")
+
+// HiddenFieldSet selects the fields a PHT commits to and its MT reveals, one bit
+// per field position. The zero set stands for DefaultHiddenFieldSet so PHTs
+// created before field sets were configurable keep their meaning.
+type HiddenFieldSet uint16
+
+// DefaultHiddenFieldSet hides the recipient, value, call data, type and gas limit
+const DefaultHiddenFieldSet = HiddenFieldSet(1<<FieldRecipient | 1<<FieldValue | 1<<FieldCallData | 1<<FieldTxType | 1<<FieldGasLimit)
+
+// selectableFieldNames maps field positions to their configuration names
+var selectableFieldNames = [numSelectableFields]string{
+	FieldRecipient: "recipient",
+	FieldValue:     "value",
+	FieldCallData:  "callData",
+	FieldTxType:    "txType",
+	FieldGasLimit:  "gasLimit",
+	FieldGasPrice:  "gasPrice",
+	FieldSender:    "sender",
+}
+
+// ParseHiddenFieldSet builds a field set from configuration names. The default
+// fields are always hidden, so names only need to list the additional ones; an
+// empty list selects the default set.
+func ParseHiddenFieldSet(names []string) (HiddenFieldSet, error) {
+	set := DefaultHiddenFieldSet
+	for _, name := range names {
+		index := -1
+		for i, candidate := range selectableFieldNames {
+			if candidate == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return 0, fmt.Errorf("%w: unknown field %q", ErrInvalidHiddenFieldSet, name)
+		}
+		set |= 1 << index
+	}
+	return set, nil
+}
+
+// normalize maps the zero set to the default set
+func (s HiddenFieldSet) normalize() HiddenFieldSet {
+	if s == 0 {
+		return DefaultHiddenFieldSet
+	}
+	return s
+}
+
+// Has reports whether a field position is hidden
+func (s HiddenFieldSet) Has(field int) bool {
+	return field >= 0 && field < numSelectableFields && s.normalize()&(1<<field) != 0
+}
+
+// Names returns the names of the hidden fields in commitment order
+func (s HiddenFieldSet) Names() []string {
+	names := make([]string, 0, numSelectableFields)
+	for field := 0; field < numSelectableFields; field++ {
+		if s.Has(field) {
+			names = append(names, selectableFieldNames[field])
+		}
+	}
+	return names
+}
+
+// Validate checks that a set keeps the default fields hidden and names no unknown field
+func (s HiddenFieldSet) Validate() error {
+	set := s.normalize()
+	if set&DefaultHiddenFieldSet != DefaultHiddenFieldSet {
+		return fmt.Errorf("%w: %v must stay hidden", ErrInvalidHiddenFieldSet, DefaultHiddenFieldSet.Names())
+	}
+	if set>>numSelectableFields != 0 {
+		return fmt.Errorf("%w: unknown fields %#x", ErrInvalidHiddenFieldSet, uint16(set))
+	}
+	return nil
+}
+
+// configuredHiddenFieldSet returns the hidden field set of a network, falling back
+// to the default set for invalid configurations
+func configuredHiddenFieldSet(config *P2SConfig) HiddenFieldSet {
+	set, err := ParseHiddenFieldSet(config.HiddenFields)
+	if err != nil {
+		log.Error("Falling back to default hidden fields", "err", err)
+		return DefaultHiddenFieldSet
+	}
+	return set
+}
+
+// hiddenCommitmentData encodes the fields of a set for the PHT commitment under a
+// field encoding. Blob and access list fields are always hidden and only committed
+// when present.
+func hiddenCommitmentData(set HiddenFieldSet, encoding FieldEncoding, fields *HiddenFields) ([][]byte, error) {
+	if err := encoding.Validate(); err != nil {
+		return nil, err
+	}
+	data := make([][]byte, 0, numSelectableFields+3)
+	for field, encode := range fieldEncoders[encoding] {
+		if set.Has(field) {
+			data = append(data, encode(fields))
+		}
+	}
+	if fields.TxType == types.BlobTxType {
+		hashes := make([]byte, 0, len(fields.BlobHashes)*common.HashLength)
+		for _, hash := range fields.BlobHashes {
+			hashes = append(hashes, hash.Bytes()...)
+		}
+		data = append(data, hashes, encoding.encodeBlobFeeCap(fields.MaxFeePerBlobGas))
+	}
+	if len(fields.AccessList) > 0 {
+		accessList, err := rlp.EncodeToBytes(fields.AccessList)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, accessList)
+	}
+	if encoding.bindsAccountNonce() {
+		data = append(data, encodeUint64(fields.AccountNonce))
+	}
+	return data, nil
+}
@@ -0,0 +1,184 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrTxRootMismatch is returned when a header does not commit to its block's transactions
+	ErrTxRootMismatch = errors.New("transaction root mismatch")
+
+	// ErrNotInBlock is returned when a transaction was not revealed in the requested block
+	ErrNotInBlock = errors.New("transaction not in block")
+)
+
+// P2S headers commit to the Merkle root of their PHT hashes (B1) or MT leaves (B2)
+// in the 32 bytes of extra data preceding the block type byte, so light clients
+// can check a single transaction against a header. The tree is the one of
+// MerkleProofSystem, with an empty block having the zero root.
+const txRootSize = common.HashLength
+
+// PHTRoot returns the transaction root of a B1 block's PHTs
+func PHTRoot(phts []*PHTTransaction) common.Hash {
+	hashes := make([]common.Hash, len(phts))
+	for i, pht := range phts {
+		hashes[i] = pht.Hash()
+	}
+	return txRoot(hashes)
+}
+
+// MTRoot returns the transaction root of a B2 block's MTs
+func MTRoot(mts []*MTTransaction) common.Hash {
+	return txRoot(mtLeaves(mts))
+}
+
+// mtLeaf is the MT root leaf of an MT: its hash together with its transaction
+// hash, layout and the blinding factors opening its PHT's commitments, so fraud
+// proofs can rely on the published openings. Proofs are left out as they are
+// compacted away.
+func mtLeaf(mt *MTTransaction) common.Hash {
+	layout := make([]byte, 3)
+	binary.BigEndian.PutUint16(layout, uint16(mt.HiddenSet))
+	layout[2] = uint8(mt.FieldEncoding)
+
+	return crypto.Keccak256Hash(mt.Hash().Bytes(), mt.TxHash.Bytes(), layout, crypto.Keccak256(mt.Blinding), crypto.Keccak256(mt.ValueBlinding))
+}
+
+// mtLeaves returns the MT root leaves of MTs in block order
+func mtLeaves(mts []*MTTransaction) []common.Hash {
+	leaves := make([]common.Hash, len(mts))
+	for i, mt := range mts {
+		leaves[i] = mtLeaf(mt)
+	}
+	return leaves
+}
+
+// txTree builds the Merkle tree over transaction hashes
+func txTree(hashes []common.Hash) [][][]byte {
+	leaves := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		leaves[i] = hash.Bytes()
+	}
+	return NewMerkleProofSystem().buildMerkleTree(leaves)
+}
+
+// txRoot returns the Merkle root over transaction hashes
+func txRoot(hashes []common.Hash) common.Hash {
+	if len(hashes) == 0 {
+		return common.Hash{}
+	}
+	tree := txTree(hashes)
+	return common.BytesToHash(tree[len(tree)-1][0])
+}
+
+// TxRoot returns the transaction root a P2S header commits to
+func TxRoot(header *types.Header) (common.Hash, bool) {
+	if len(header.Extra) < txRootSize+1 {
+		return common.Hash{}, false
+	}
+	end := len(header.Extra) - 1
+	return common.BytesToHash(header.Extra[end-txRootSize : end]), true
+}
+
+// setTxRoot commits a header to a transaction root, ahead of its block type byte
+func setTxRoot(header *types.Header, root common.Hash) {
+	end := len(header.Extra) - 1
+	blockType := header.Extra[end]
+	extra := append(header.Extra[:end:end], root.Bytes()...)
+	header.Extra = append(extra, blockType)
+}
+
+// checkTxRoot checks that a header commits to the given transaction root
+func checkTxRoot(header *types.Header, want common.Hash) error {
+	root, ok := TxRoot(header)
+	if !ok {
+		return fmt.Errorf("%w: header carries no root", ErrTxRootMismatch)
+	}
+	if root != want {
+		return fmt.Errorf("%w: have %s, want %s", ErrTxRootMismatch, root.Hex(), want.Hex())
+	}
+	return nil
+}
+
+// VerifyTxInclusion verifies a Merkle proof, as produced by MerkleProofSystem, that
+// leaf is a transaction leaf under root
+func VerifyTxInclusion(root, leaf common.Hash, proof []byte) bool {
+	if len(proof) < merkleProofHeaderSize || (len(proof)-merkleProofHeaderSize)%merkleNodeSize != 0 {
+		return false
+	}
+	depth := (len(proof) - merkleProofHeaderSize) / merkleNodeSize
+	if depth > 32 {
+		return false
+	}
+	leafIndex := uint64(binary.BigEndian.Uint32(proof[:4]))
+	directions := binary.BigEndian.Uint32(proof[4:merkleProofHeaderSize])
+	if leafIndex>>depth != 0 || uint64(directions) != leafIndex {
+		return false
+	}
+
+	current := merkleHash(merkleLeafPrefix, leaf.Bytes())
+	for level := 0; level < depth; level++ {
+		offset := merkleProofHeaderSize + level*merkleNodeSize
+		sibling := proof[offset : offset+merkleNodeSize]
+
+		if directions&(1<<level) != 0 {
+			current = merkleHash(merkleNodePrefix, sibling, current)
+		} else {
+			current = merkleHash(merkleNodePrefix, current, sibling)
+		}
+	}
+	return constantTimeEqual(current, root.Bytes())
+}
+
+// MTInclusionProof proves to a light client that an MT was revealed in a B2 block
+// without the block body
+type MTInclusionProof struct {
+	Header *types.Header  `json:"header"` // B2 block header committing to the MT root
+	MT     *MTTransaction `json:"mt"`
+	Proof  hexutil.Bytes  `json:"proof"` // Merkle proof of the MT leaf under the MT root
+}
+
+// Verify checks that the proof shows the transaction txHash revealed in the B2
+// block blockHash
+func (p *MTInclusionProof) Verify(blockHash, txHash common.Hash) error {
+	if p.Header == nil || p.MT == nil {
+		return errors.New("incomplete inclusion proof")
+	}
+	if p.Header.Hash() != blockHash {
+		return errors.New("header does not match block hash")
+	}
+	if p.MT.TxHash != txHash || p.MT.Unrevealed {
+		return ErrNotInBlock
+	}
+	root, ok := TxRoot(p.Header)
+	if !ok {
+		return fmt.Errorf("%w: header carries no root", ErrTxRootMismatch)
+	}
+	if !VerifyTxInclusion(root, mtLeaf(p.MT), p.Proof) {
+		return errors.New("invalid inclusion proof")
+	}
+	return nil
+}
+
+// newMTInclusionProof creates the inclusion proof of the MT of txHash in a B2 block
+func newMTInclusionProof(b2Block *B2Block, txHash common.Hash) (*MTInclusionProof, error) {
+	for i, mt := range b2Block.MTs {
+		if mt.TxHash != txHash || mt.Unrevealed {
+			continue
+		}
+		tree := txTree(mtLeaves(b2Block.MTs))
+		return &MTInclusionProof{
+			Header: b2Block.Header,
+			MT:     mt,
+			Proof:  NewMerkleProofSystem().generateMerkleProof(tree, i),
+		}, nil
+	}
+	return nil, ErrNotInBlock
+}
@@ -0,0 +1,308 @@
+package p2s
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+const (
+	// kzgElementPayload is the number of data bytes packed per blob field element;
+	// the leading byte stays zero so every element is below the BLS12-381 modulus
+	kzgElementPayload = 31
+
+	// kzgBlobElements is the number of field elements of a blob
+	kzgBlobElements = len(kzg4844.Blob{}) / 32
+
+	// kzgBlindingLength is the length of the random blinding element
+	kzgBlindingLength = kzgElementPayload
+)
+
+// kzgModulus is the order of the BLS12-381 scalar field blobs are made of
+var kzgModulus, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+var (
+	// kzgDomain holds the evaluation point of every blob element
+	kzgDomain     []kzg4844.Point
+	kzgDomainOnce sync.Once
+)
+
+// ErrKZGCapacity is returned when the committed data does not fit in a single blob
+var ErrKZGCapacity = errors.New("data exceeds KZG blob capacity")
+
+// KZGCommitment commits to data with a KZG polynomial commitment over an EIP-4844
+// blob, giving 48-byte commitments regardless of the size of the hidden fields.
+// KZG commitments are not hiding on their own, so a random field element is packed
+// into the blob ahead of the data and returned as the blinding factor. Single data
+// items open with a constant-size proof per blob element they occupy, without
+// revealing the other items.
+type KZGCommitment struct{}
+
+// KZGElementOpening proves the value of one blob element with a 48-byte proof
+type KZGElementOpening struct {
+	Index int           `json:"index"`
+	Value kzg4844.Claim `json:"value"`
+	Proof kzg4844.Proof `json:"proof"`
+}
+
+// KZGFieldOpening opens one committed data item, such as a single hidden field:
+// the header elements holding the item lengths followed by the item's elements
+type KZGFieldOpening struct {
+	Item     int                  `json:"item"`
+	Elements []*KZGElementOpening `json:"elements"`
+}
+
+// NewKZGCommitment creates a new KZG commitment scheme
+func NewKZGCommitment() *KZGCommitment {
+	return &KZGCommitment{}
+}
+
+// Commit commits to data under a fresh blinding element
+func (k *KZGCommitment) Commit(data ...[]byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("no data to commit")
+	}
+
+	blinding := make([]byte, kzgBlindingLength)
+	if _, err := rand.Read(blinding); err != nil {
+		return nil, nil, err
+	}
+	commitment, err := k.commit(blinding, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return commitment, blinding, nil
+}
+
+// Verify checks that a commitment opens to data with the given blinding element
+func (k *KZGCommitment) Verify(commitment []byte, blinding []byte, data ...[]byte) bool {
+	if len(data) == 0 || len(blinding) != kzgBlindingLength {
+		return false
+	}
+
+	expected, err := k.commit(blinding, data)
+	if err != nil {
+		return false
+	}
+	return constantTimeEqual(commitment, expected)
+}
+
+// OpenField opens a single data item of a commitment made with the blinding
+// element, proving each blob element it needs with kzg4844.ComputeProof
+func (k *KZGCommitment) OpenField(blinding []byte, item int, data ...[]byte) (*KZGFieldOpening, error) {
+	if item < 0 || item >= len(data) {
+		return nil, fmt.Errorf("no data item %d of %d", item, len(data))
+	}
+	if len(blinding) != kzgBlindingLength {
+		return nil, errors.New("invalid blinding element")
+	}
+	blob, err := kzgBlob(blinding, data)
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]int, len(data))
+	for i, d := range data {
+		lengths[i] = len(d)
+	}
+	starts, _ := kzgLayout(lengths)
+	indices := make([]int, 0)
+	for i := 1; i < 1+kzgElements(kzgHeaderLength(len(data))); i++ {
+		indices = append(indices, i)
+	}
+	for i := 0; i < kzgElements(lengths[item]); i++ {
+		indices = append(indices, starts[item]+i)
+	}
+
+	domain := kzgEvaluationDomain()
+	opening := &KZGFieldOpening{Item: item, Elements: make([]*KZGElementOpening, len(indices))}
+	for i, index := range indices {
+		proof, claim, err := kzg4844.ComputeProof(blob, domain[index])
+		if err != nil {
+			return nil, err
+		}
+		opening.Elements[i] = &KZGElementOpening{Index: index, Value: claim, Proof: proof}
+	}
+	return opening, nil
+}
+
+// VerifyField checks that an opening proves value to be its data item of the
+// commitment, verifying each element proof with kzg4844.VerifyProof
+func (k *KZGCommitment) VerifyField(commitment []byte, opening *KZGFieldOpening, value []byte) bool {
+	if len(commitment) != len(kzg4844.Commitment{}) || opening == nil || len(opening.Elements) == 0 {
+		return false
+	}
+	var c kzg4844.Commitment
+	copy(c[:], commitment)
+
+	domain := kzgEvaluationDomain()
+	elements := make(map[int][]byte, len(opening.Elements))
+	for _, element := range opening.Elements {
+		if element == nil || element.Index < 0 || element.Index >= kzgBlobElements || element.Value[0] != 0 {
+			return false
+		}
+		if kzg4844.VerifyProof(c, domain[element.Index], element.Value, element.Proof) != nil {
+			return false
+		}
+		elements[element.Index] = element.Value[1:]
+	}
+	if len(elements) != len(opening.Elements) {
+		return false
+	}
+
+	// The header fixes where every item starts, so an item cannot be opened
+	// from another item's elements
+	first, ok := elements[1]
+	if !ok {
+		return false
+	}
+	count := int(binary.BigEndian.Uint32(first))
+	if count > kzgBlobElements*kzgElementPayload/4 || opening.Item < 0 || opening.Item >= count {
+		return false
+	}
+	header, ok := kzgElementData(elements, 1, kzgHeaderLength(count))
+	if !ok {
+		return false
+	}
+	lengths := make([]int, count)
+	for i := range lengths {
+		lengths[i] = int(binary.BigEndian.Uint32(header[4+4*i:]))
+		if lengths[i] > kzgBlobElements*kzgElementPayload {
+			return false
+		}
+	}
+	starts, total := kzgLayout(lengths)
+	if total > kzgBlobElements || lengths[opening.Item] != len(value) {
+		return false
+	}
+	if len(opening.Elements) != kzgElements(len(header))+kzgElements(len(value)) {
+		return false
+	}
+	opened, ok := kzgElementData(elements, starts[opening.Item], len(value))
+	return ok && constantTimeEqual(opened, value)
+}
+
+// commit packs the blinding element and data into a blob and commits to it
+func (k *KZGCommitment) commit(blinding []byte, data [][]byte) ([]byte, error) {
+	blob, err := kzgBlob(blinding, data)
+	if err != nil {
+		return nil, err
+	}
+	commitment, err := kzg4844.BlobToCommitment(blob)
+	if err != nil {
+		return nil, err
+	}
+	return commitment[:], nil
+}
+
+// kzgElements returns the number of blob elements n data bytes occupy
+func kzgElements(n int) int {
+	return (n + kzgElementPayload - 1) / kzgElementPayload
+}
+
+// kzgHeaderLength returns the length of the header of count data items: the item
+// count followed by every item's length
+func kzgHeaderLength(count int) int {
+	return 4 + 4*count
+}
+
+// kzgLayout returns the first blob element of every data item and the number of
+// elements used: the blinding element, the header, then every item from an
+// element boundary
+func kzgLayout(lengths []int) ([]int, int) {
+	starts := make([]int, len(lengths))
+	next := 1 + kzgElements(kzgHeaderLength(len(lengths)))
+	for i, length := range lengths {
+		starts[i] = next
+		next += kzgElements(length)
+	}
+	return starts, next
+}
+
+// kzgBlob packs the blinding element, the header and the data items into a blob,
+// 31 bytes per field element
+func kzgBlob(blinding []byte, data [][]byte) (*kzg4844.Blob, error) {
+	lengths := make([]int, len(data))
+	header := make([]byte, kzgHeaderLength(len(data)))
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	for i, d := range data {
+		lengths[i] = len(d)
+		binary.BigEndian.PutUint32(header[4+4*i:], uint32(len(d)))
+	}
+	starts, total := kzgLayout(lengths)
+	if total > kzgBlobElements {
+		return nil, ErrKZGCapacity
+	}
+
+	blob := new(kzg4844.Blob)
+	putKZGElements(blob, 0, blinding)
+	putKZGElements(blob, 1, header)
+	for i, d := range data {
+		putKZGElements(blob, starts[i], d)
+	}
+	return blob, nil
+}
+
+// putKZGElements packs data into consecutive blob elements from the first one
+func putKZGElements(blob *kzg4844.Blob, first int, data []byte) {
+	for i := 0; i*kzgElementPayload < len(data); i++ {
+		end := (i + 1) * kzgElementPayload
+		if end > len(data) {
+			end = len(data)
+		}
+		copy(blob[(first+i)*32+1:], data[i*kzgElementPayload:end])
+	}
+}
+
+// kzgElementData reads n data bytes packed from the first element out of opened
+// elements, failing if one is missing or the padding is not zero
+func kzgElementData(elements map[int][]byte, first, n int) ([]byte, bool) {
+	data := make([]byte, 0, kzgElements(n)*kzgElementPayload)
+	for i := 0; i < kzgElements(n); i++ {
+		element, ok := elements[first+i]
+		if !ok {
+			return nil, false
+		}
+		data = append(data, element...)
+	}
+	for _, b := range data[n:] {
+		if b != 0 {
+			return nil, false
+		}
+	}
+	return data[:n], true
+}
+
+// kzgEvaluationDomain returns the evaluation point of every blob element: the
+// 4096th roots of unity of the scalar field in bit-reversed order, per EIP-4844
+func kzgEvaluationDomain() []kzg4844.Point {
+	kzgDomainOnce.Do(func() {
+		exponent := new(big.Int).Sub(kzgModulus, common.Big1)
+		exponent.Div(exponent, big.NewInt(int64(kzgBlobElements)))
+		root := new(big.Int).Exp(big.NewInt(7), exponent, kzgModulus)
+
+		bits := uint(0)
+		for 1<<bits < kzgBlobElements {
+			bits++
+		}
+		kzgDomain = make([]kzg4844.Point, kzgBlobElements)
+		power := big.NewInt(1)
+		for i := 0; i < kzgBlobElements; i++ {
+			reversed := 0
+			for b := uint(0); b < bits; b++ {
+				if i&(1<<b) != 0 {
+					reversed |= 1 << (bits - 1 - b)
+				}
+			}
+			power.FillBytes(kzgDomain[reversed][:])
+			power.Mul(power, root).Mod(power, kzgModulus)
+		}
+	})
+	return kzgDomain
+}
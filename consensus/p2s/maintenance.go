@@ -0,0 +1,204 @@
+package p2s
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Maintenance states
+const (
+	MaintenanceOff      = "off"
+	MaintenanceDraining = "draining" // No new duties, waiting for in-flight reveals
+	MaintenanceReady    = "ready"    // Safe to shut down
+)
+
+// maintenanceLadderSize is the number of fallback proposers tried per handed-off duty
+const maintenanceLadderSize = 3
+
+// ErrMaintenanceMode is returned when a duty is requested from a node in maintenance
+var ErrMaintenanceMode = errors.New("node is in maintenance mode")
+
+// MaintenanceHooks connect maintenance mode to the node's duty schedule and reveal pipeline
+type MaintenanceHooks struct {
+	// ProposalDuties returns the slots in [from, to] at which the validator is scheduled to propose
+	ProposalDuties func(validator common.Address, from, to uint64) []uint64
+
+	// HandOff asks a fallback proposer to take over the duty at slot
+	HandOff func(slot uint64, fallback common.Address) error
+
+	// InFlightReveals returns the number of MTs still to be revealed for B1 blocks this node proposed
+	InFlightReveals func() int
+}
+
+// MaintenanceStatus reports progress of a node towards a safe shutdown
+type MaintenanceStatus struct {
+	State           string                    `json:"state"`
+	Validator       common.Address            `json:"validator"`
+	EnteredSlot     uint64                    `json:"enteredSlot"`
+	HandedOff       map[uint64]common.Address `json:"handedOff"` // Slot to fallback proposer
+	Unhandled       map[uint64]string         `json:"unhandled"` // Slot to reason the duty may be missed
+	InFlightReveals int                       `json:"inFlightReveals"`
+	SafeToShutdown  bool                      `json:"safeToShutdown"`
+}
+
+// MaintenanceMode takes a validator out of duty rotation for planned maintenance.
+// Entering maintenance stops new proposal duties, hands imminent duties to the
+// fallback ladder and then waits for in-flight reveals to drain.
+type MaintenanceMode struct {
+	validators *ValidatorManager
+	hooks      *MaintenanceHooks
+	horizon    uint64 // Slots ahead whose duties are handed off
+
+	status   *MaintenanceStatus
+	handoffs bool // Duties are still being handed off
+	mu       sync.Mutex
+}
+
+// NewMaintenanceMode creates a maintenance controller that hands off duties up to horizon slots ahead
+func NewMaintenanceMode(validators *ValidatorManager, horizon uint64, hooks *MaintenanceHooks) *MaintenanceMode {
+	if hooks == nil {
+		hooks = &MaintenanceHooks{}
+	}
+
+	return &MaintenanceMode{
+		validators: validators,
+		hooks:      hooks,
+		horizon:    horizon,
+	}
+}
+
+// SetHooks replaces the duty schedule and reveal pipeline hooks
+func (m *MaintenanceMode) SetHooks(hooks *MaintenanceHooks) {
+	if hooks == nil {
+		hooks = &MaintenanceHooks{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = hooks
+}
+
+// Enter puts the validator into maintenance at the given slot and hands off its
+// proposal duties within the horizon
+func (m *MaintenanceMode) Enter(validator common.Address, slot uint64) (*MaintenanceStatus, error) {
+	m.mu.Lock()
+	if m.status != nil {
+		m.mu.Unlock()
+		return nil, errors.New("already in maintenance mode")
+	}
+	status := &MaintenanceStatus{
+		State:       MaintenanceDraining,
+		Validator:   validator,
+		EnteredSlot: slot,
+		HandedOff:   make(map[uint64]common.Address),
+		Unhandled:   make(map[uint64]string),
+	}
+	m.status = status
+	m.handoffs = true
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var duties []uint64
+	if hooks.ProposalDuties != nil {
+		duties = hooks.ProposalDuties(validator, slot, slot+m.horizon)
+	}
+
+	// Hand off each duty to the first fallback proposer that accepts it
+	handedOff := make(map[uint64]common.Address)
+	unhandled := make(map[uint64]string)
+	for _, duty := range duties {
+		if hooks.HandOff == nil {
+			unhandled[duty] = "no handoff hook"
+			continue
+		}
+		ladder := m.validators.FallbackLadder(duty, validator, maintenanceLadderSize)
+		if len(ladder) == 0 {
+			unhandled[duty] = "no fallback proposers"
+			continue
+		}
+		var err error
+		for _, fallback := range ladder {
+			if err = hooks.HandOff(duty, fallback); err == nil {
+				handedOff[duty] = fallback
+				break
+			}
+		}
+		if err != nil {
+			unhandled[duty] = "fallback ladder exhausted: " + err.Error()
+			log.Warn("Proposal duty may be missed during maintenance", "slot", duty, "err", err)
+		}
+	}
+
+	m.mu.Lock()
+	status.HandedOff = handedOff
+	status.Unhandled = unhandled
+	m.handoffs = false
+	m.mu.Unlock()
+
+	log.Info("Entered maintenance mode", "validator", validator, "slot", slot, "handedOff", len(handedOff), "unhandled", len(unhandled))
+	return m.Status(), nil
+}
+
+// AcceptingDuties reports whether the node may take on new proposal duties
+func (m *MaintenanceMode) AcceptingDuties() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.status == nil
+}
+
+// Status refreshes the in-flight reveal count and returns the maintenance status
+func (m *MaintenanceMode) Status() *MaintenanceStatus {
+	m.mu.Lock()
+	if m.status == nil {
+		m.mu.Unlock()
+		return &MaintenanceStatus{State: MaintenanceOff}
+	}
+	inFlight := m.hooks.InFlightReveals
+	m.mu.Unlock()
+
+	pending := 0
+	if inFlight != nil {
+		pending = inFlight()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.status == nil {
+		return &MaintenanceStatus{State: MaintenanceOff}
+	}
+	m.status.InFlightReveals = pending
+	m.status.SafeToShutdown = pending == 0 && !m.handoffs
+	if m.status.SafeToShutdown {
+		m.status.State = MaintenanceReady
+	} else {
+		m.status.State = MaintenanceDraining
+	}
+
+	status := *m.status
+	status.HandedOff = make(map[uint64]common.Address, len(m.status.HandedOff))
+	for slot, fallback := range m.status.HandedOff {
+		status.HandedOff[slot] = fallback
+	}
+	status.Unhandled = make(map[uint64]string, len(m.status.Unhandled))
+	for slot, reason := range m.status.Unhandled {
+		status.Unhandled[slot] = reason
+	}
+	return &status
+}
+
+// Exit returns the node to duty rotation
+func (m *MaintenanceMode) Exit() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.status != nil {
+		log.Info("Exited maintenance mode", "validator", m.status.Validator)
+	}
+	m.status = nil
+}
@@ -0,0 +1,228 @@
+package p2s
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// ErrInvalidEvidence is returned when an evidence packet does not hold up against stored reveals
+	ErrInvalidEvidence = errors.New("invalid bounty evidence")
+
+	// ErrAttackAlreadyDetected is returned when the claimed attack was flagged when the block was built
+	ErrAttackAlreadyDetected = errors.New("attack was already detected")
+
+	// ErrDuplicateClaim is returned when the same attack has already been claimed
+	ErrDuplicateClaim = errors.New("attack already claimed")
+)
+
+// BountyTreasury pays rewards to reporters of verified bounty claims
+type BountyTreasury interface {
+	PayBounty(reporter common.Address, amount *big.Int, claim common.Hash) error
+}
+
+// EvidencePacket claims an MEV attack in a past B1 block that the detector missed.
+// The claimed transactions must have been revealed in the paired B2 block.
+type EvidencePacket struct {
+	B1Hash       common.Hash    `json:"b1Hash"`
+	Attack       string         `json:"attack"`       // Attack pattern claimed, e.g. sandwich_attack
+	Transactions []common.Hash  `json:"transactions"` // PHT transaction hashes making up the attack
+	Description  string         `json:"description"`
+	Reporter     common.Address `json:"reporter"`
+	Signature    []byte         `json:"signature"` // Reporter signature over SigningHash
+}
+
+// ClaimID identifies the attack claimed by a packet regardless of who reports it
+func (e *EvidencePacket) ClaimID() common.Hash {
+	txs := append([]common.Hash(nil), e.Transactions...)
+	sort.Slice(txs, func(i, j int) bool {
+		return bytes.Compare(txs[i].Bytes(), txs[j].Bytes()) < 0
+	})
+
+	data := [][]byte{[]byte("p2s-bounty-claim"), e.B1Hash.Bytes(), []byte(e.Attack)}
+	for _, tx := range txs {
+		data = append(data, tx.Bytes())
+	}
+	return crypto.Keccak256Hash(data...)
+}
+
+// SigningHash returns the hash signed by the reporter
+func (e *EvidencePacket) SigningHash() common.Hash {
+	return crypto.Keccak256Hash([]byte("p2s-bounty-evidence"), e.ClaimID().Bytes(), e.Reporter.Bytes(), []byte(e.Description))
+}
+
+// Sign signs the packet with the reporter key
+func (e *EvidencePacket) Sign(key *ecdsa.PrivateKey) error {
+	e.Reporter = crypto.PubkeyToAddress(key.PublicKey)
+	signature, err := crypto.Sign(e.SigningHash().Bytes(), key)
+	if err != nil {
+		return err
+	}
+	e.Signature = signature
+	return nil
+}
+
+// BountyClaim is a verified claim of an undetected attack
+type BountyClaim struct {
+	ID          common.Hash     `json:"id"`
+	Packet      *EvidencePacket `json:"packet"`
+	BlockNumber uint64          `json:"blockNumber"`
+	Reward      *big.Int        `json:"reward"`
+	Paid        bool            `json:"paid"`
+	PayoutError string          `json:"payoutError,omitempty"` // Set when the treasury could not pay
+}
+
+// BountyBoard verifies evidence packets against stored reveals and rewards reporters
+// of attacks the detector missed. Verified cases are recorded as detector misses
+// and added to the calibration corpus.
+type BountyBoard struct {
+	detector *MEVDetector
+	corpus   *CalibrationCorpus
+	treasury BountyTreasury
+	reward   *big.Int
+
+	claims map[common.Hash]*BountyClaim
+	mu     sync.Mutex
+}
+
+// NewBountyBoard creates a bounty board paying reward per verified claim. A nil
+// treasury records claims without paying them.
+func NewBountyBoard(detector *MEVDetector, corpus *CalibrationCorpus, treasury BountyTreasury, reward *big.Int) *BountyBoard {
+	if reward == nil {
+		reward = new(big.Int)
+	}
+
+	return &BountyBoard{
+		detector: detector,
+		corpus:   corpus,
+		treasury: treasury,
+		reward:   new(big.Int).Set(reward),
+		claims:   make(map[common.Hash]*BountyClaim),
+	}
+}
+
+// SetTreasury sets the treasury bounties are paid from
+func (b *BountyBoard) SetTreasury(treasury BountyTreasury) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.treasury = treasury
+}
+
+// Submit verifies an evidence packet against a B1 block and its B2 reveals.
+// verify checks that a revealed MT opens its PHT commitment.
+func (b *BountyBoard) Submit(packet *EvidencePacket, b1Block *B1Block, b2Block *B2Block, verify func(mt *MTTransaction, pht *PHTTransaction) error) (*BountyClaim, error) {
+	if packet == nil || len(packet.Transactions) == 0 {
+		return nil, fmt.Errorf("%w: no transactions claimed", ErrInvalidEvidence)
+	}
+	pubKey, err := crypto.SigToPub(packet.SigningHash().Bytes(), packet.Signature)
+	if err != nil || crypto.PubkeyToAddress(*pubKey) != packet.Reporter {
+		return nil, fmt.Errorf("%w: bad reporter signature", ErrInvalidEvidence)
+	}
+	if b.detector.GetAttackPattern(packet.Attack) == nil {
+		return nil, fmt.Errorf("%w: unknown attack pattern %s", ErrInvalidEvidence, packet.Attack)
+	}
+	if b1Block == nil || b2Block == nil {
+		return nil, fmt.Errorf("%w: block %s has not been revealed", ErrInvalidEvidence, packet.B1Hash.Hex())
+	}
+	for _, attack := range b1Block.DetectedAttacks {
+		if attack == packet.Attack {
+			return nil, ErrAttackAlreadyDetected
+		}
+	}
+
+	// Every claimed transaction must be a PHT of the block with a verified reveal
+	phts := make(map[common.Hash]*PHTTransaction, len(b1Block.PHTs))
+	for _, pht := range b1Block.PHTs {
+		phts[pht.TxHash] = pht
+	}
+	mts := make(map[common.Hash]*MTTransaction, len(b2Block.MTs))
+	for _, mt := range b2Block.MTs {
+		mts[mt.PHTHash] = mt
+	}
+	labelled := make([]common.Hash, 0, len(packet.Transactions))
+	for _, tx := range packet.Transactions {
+		pht, ok := phts[tx]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not in block %s", ErrInvalidEvidence, tx.Hex(), packet.B1Hash.Hex())
+		}
+		mt, ok := mts[pht.Hash()]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s was not revealed", ErrInvalidEvidence, tx.Hex())
+		}
+		if err := verify(mt, pht); err != nil {
+			return nil, fmt.Errorf("%w: reveal of %s: %v", ErrInvalidEvidence, tx.Hex(), err)
+		}
+		labelled = append(labelled, tx)
+	}
+
+	b.mu.Lock()
+	id := packet.ClaimID()
+	if _, exists := b.claims[id]; exists {
+		b.mu.Unlock()
+		return nil, ErrDuplicateClaim
+	}
+	claim := &BountyClaim{
+		ID:     id,
+		Packet: packet,
+		Reward: new(big.Int).Set(b.reward),
+	}
+	if b1Block.Header != nil {
+		claim.BlockNumber = b1Block.Header.Number.Uint64()
+	}
+	b.claims[id] = claim
+	treasury := b.treasury
+	b.mu.Unlock()
+
+	b.detector.RecordMiss(packet.Attack)
+	if b.corpus != nil {
+		b.corpus.AddCase(packet.B1Hash, b1Block, labelled)
+	}
+
+	// A failed payout does not invalidate the claim
+	var payoutErr string
+	switch {
+	case claim.Reward.Sign() == 0:
+	case treasury == nil:
+		payoutErr = "no treasury configured"
+	default:
+		if err := treasury.PayBounty(packet.Reporter, claim.Reward, id); err != nil {
+			payoutErr = err.Error()
+			log.Warn("Failed to pay MEV bounty", "claim", id, "reporter", packet.Reporter, "err", err)
+		}
+	}
+	b.mu.Lock()
+	claim.Paid = claim.Reward.Sign() > 0 && payoutErr == ""
+	claim.PayoutError = payoutErr
+	b.mu.Unlock()
+
+	log.Info("Accepted MEV bounty claim", "claim", id, "attack", packet.Attack, "block", packet.B1Hash, "reporter", packet.Reporter)
+	return claim, nil
+}
+
+// Claims returns all accepted claims ordered by block number
+func (b *BountyBoard) Claims() []*BountyClaim {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	claims := make([]*BountyClaim, 0, len(b.claims))
+	for _, claim := range b.claims {
+		claims = append(claims, claim)
+	}
+	sort.Slice(claims, func(i, j int) bool {
+		if claims[i].BlockNumber != claims[j].BlockNumber {
+			return claims[i].BlockNumber < claims[j].BlockNumber
+		}
+		return bytes.Compare(claims[i].ID.Bytes(), claims[j].ID.Bytes()) < 0
+	})
+	return claims
+}
@@ -0,0 +1,257 @@
+package p2s
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// calibrationSteps is the number of thresholds swept per pattern
+const calibrationSteps = 20
+
+// ThresholdResult contains detection quality at a single threshold
+type ThresholdResult struct {
+	Threshold      float64 `json:"threshold"`
+	TruePositives  int     `json:"truePositives"`
+	FalsePositives int     `json:"falsePositives"`
+	FalseNegatives int     `json:"falseNegatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+}
+
+// PatternCalibration contains the threshold sweep for a single attack pattern
+type PatternCalibration struct {
+	Pattern       string            `json:"pattern"`
+	Sweep         []ThresholdResult `json:"sweep"`
+	BestThreshold float64           `json:"bestThreshold"`
+	Precision     float64           `json:"precision"`
+	Recall        float64           `json:"recall"`
+}
+
+// CalibrationReport contains the result of calibrating the detector against labelled blocks
+type CalibrationReport struct {
+	Samples   int                            `json:"samples"`
+	Positives int                            `json:"positives"`
+	Patterns  map[string]*PatternCalibration `json:"patterns"`
+	Threshold float64                        `json:"threshold"` // Tuned block-level detection threshold
+}
+
+// calibrationSample is a single analyzed and labelled PHT
+type calibrationSample struct {
+	score   float64
+	attacks map[string]bool
+	label   bool
+}
+
+// Calibrate sweeps thresholds over historical B1 blocks and reports precision and
+// recall for each attack pattern. Labels are keyed by PHT transaction hash and mark
+// transactions known to be part of an MEV attack; unlabelled PHTs are treated as benign.
+func (m *MEVDetector) Calibrate(blocks []*B1Block, labels map[common.Hash]bool) *CalibrationReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	samples := make([]calibrationSample, 0)
+	for _, block := range blocks {
+		if block == nil {
+			continue
+		}
+		for _, pht := range block.PHTs {
+			if pht == nil {
+				continue
+			}
+			// Score without metrics or calibration, every matched pattern counts
+			score, attacks, _ := m.explainWith(nil, pht, func(name string, matched bool, impact float64) bool { return matched })
+			matched := make(map[string]bool, len(attacks))
+			for _, attack := range attacks {
+				matched[attack] = true
+			}
+			samples = append(samples, calibrationSample{
+				score:   score,
+				attacks: matched,
+				label:   labels[pht.TxHash],
+			})
+		}
+	}
+
+	report := &CalibrationReport{
+		Samples:  len(samples),
+		Patterns: make(map[string]*PatternCalibration),
+	}
+	for _, sample := range samples {
+		if sample.label {
+			report.Positives++
+		}
+	}
+
+	// Sweep each pattern: a sample is flagged when the pattern matched and the
+	// transaction score falls at or below the threshold
+	for name := range m.attackPatterns {
+		calibration := &PatternCalibration{Pattern: name}
+		best := -1.0
+		for step := 0; step <= calibrationSteps; step++ {
+			threshold := float64(step) / calibrationSteps
+			result := evaluateThreshold(samples, threshold, func(s calibrationSample) bool {
+				return s.attacks[name]
+			})
+			calibration.Sweep = append(calibration.Sweep, result)
+			if result.F1 > best {
+				best = result.F1
+				calibration.BestThreshold = threshold
+				calibration.Precision = result.Precision
+				calibration.Recall = result.Recall
+			}
+		}
+		report.Patterns[name] = calibration
+	}
+
+	// Sweep the block-level threshold using any detection
+	best := -1.0
+	for step := 0; step <= calibrationSteps; step++ {
+		threshold := float64(step) / calibrationSteps
+		result := evaluateThreshold(samples, threshold, func(s calibrationSample) bool {
+			return true
+		})
+		if result.F1 > best {
+			best = result.F1
+			report.Threshold = threshold
+		}
+	}
+
+	return report
+}
+
+// evaluateThreshold computes precision and recall at a threshold for samples selected by match
+func evaluateThreshold(samples []calibrationSample, threshold float64, match func(calibrationSample) bool) ThresholdResult {
+	result := ThresholdResult{Threshold: threshold}
+
+	for _, sample := range samples {
+		flagged := match(sample) && sample.score <= threshold
+		switch {
+		case flagged && sample.label:
+			result.TruePositives++
+		case flagged && !sample.label:
+			result.FalsePositives++
+		case !flagged && sample.label:
+			result.FalseNegatives++
+		}
+	}
+
+	if tp := float64(result.TruePositives); tp > 0 {
+		result.Precision = tp / (tp + float64(result.FalsePositives))
+		result.Recall = tp / (tp + float64(result.FalseNegatives))
+		result.F1 = 2 * result.Precision * result.Recall / (result.Precision + result.Recall)
+	}
+
+	return result
+}
+
+// TunedThresholds returns the best threshold per pattern, sorted by pattern name
+func (r *CalibrationReport) TunedThresholds() map[string]float64 {
+	names := make([]string, 0, len(r.Patterns))
+	for name := range r.Patterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	thresholds := make(map[string]float64, len(names))
+	for _, name := range names {
+		thresholds[name] = r.Patterns[name].BestThreshold
+	}
+	return thresholds
+}
+
+// WriteArtifact writes the tuned configuration artifact to the given path
+func (r *CalibrationReport) WriteArtifact(path string) error {
+	artifact := struct {
+		Threshold  float64            `json:"threshold"`
+		Thresholds map[string]float64 `json:"thresholds"`
+		Samples    int                `json:"samples"`
+		Positives  int                `json:"positives"`
+	}{
+		Threshold:  r.Threshold,
+		Thresholds: r.TunedThresholds(),
+		Samples:    r.Samples,
+		Positives:  r.Positives,
+	}
+
+	data, err := json.MarshalIndent(artifact, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// ApplyCalibration updates pattern thresholds and the detection threshold from a
+// report. From then on a transaction's attacks are only reported when it scores at
+// or below the detection threshold and the threshold of their pattern.
+func (m *MEVDetector) ApplyCalibration(report *CalibrationReport) error {
+	if report == nil || report.Samples == 0 {
+		return errors.New("empty calibration report")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, calibration := range report.Patterns {
+		if pattern, exists := m.attackPatterns[name]; exists {
+			pattern.Threshold = calibration.BestThreshold
+		}
+	}
+	m.threshold = report.Threshold
+	m.calibrated = true
+
+	return nil
+}
+
+// CalibrationCorpus accumulates labelled blocks, such as verified bounty cases,
+// for later calibration runs
+type CalibrationCorpus struct {
+	blocks map[common.Hash]*B1Block
+	order  []common.Hash
+	labels map[common.Hash]bool
+	mu     sync.Mutex
+}
+
+// NewCalibrationCorpus creates an empty calibration corpus
+func NewCalibrationCorpus() *CalibrationCorpus {
+	return &CalibrationCorpus{
+		blocks: make(map[common.Hash]*B1Block),
+		labels: make(map[common.Hash]bool),
+	}
+}
+
+// AddCase adds a block and marks the given PHTs as part of an MEV attack
+func (c *CalibrationCorpus) AddCase(hash common.Hash, block *B1Block, attackTxs []common.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.blocks[hash]; !exists {
+		c.blocks[hash] = block
+		c.order = append(c.order, hash)
+	}
+	for _, tx := range attackTxs {
+		c.labels[tx] = true
+	}
+}
+
+// Data returns the corpus blocks in insertion order and their labels, ready for Calibrate
+func (c *CalibrationCorpus) Data() ([]*B1Block, map[common.Hash]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	blocks := make([]*B1Block, 0, len(c.order))
+	for _, hash := range c.order {
+		blocks = append(blocks, c.blocks[hash])
+	}
+	labels := make(map[common.Hash]bool, len(c.labels))
+	for tx, label := range c.labels {
+		labels[tx] = label
+	}
+	return blocks, labels
+}
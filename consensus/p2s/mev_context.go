@@ -0,0 +1,86 @@
+package p2s
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+// analysisRecentBlocks is the number of ancestor headers made available to detection rules
+const analysisRecentBlocks = 8
+
+// StateReader provides read-only access to chain state for state-aware detection.
+// *state.StateDB satisfies it.
+type StateReader interface {
+	GetBalance(address common.Address) *uint256.Int
+	GetNonce(address common.Address) uint64
+	GetCode(address common.Address) []byte
+}
+
+// AnalysisContext carries chain state into MEV detection so rules can judge a PHT
+// against current conditions. A nil context is valid and disables state-aware checks.
+type AnalysisContext struct {
+	BlockNumber  uint64          // Block the PHTs are being analyzed for
+	BaseFee      *big.Int        // Current base fee, nil before London
+	RecentBlocks []*types.Header // Ancestor headers, oldest first
+	State        StateReader     // Optional chain state
+	Reveals      *RevealIndex    // Optional index of recently revealed MTs
+}
+
+// NewAnalysisContext builds an analysis context for the block with the given header,
+// collecting up to analysisRecentBlocks ancestors from the chain
+func NewAnalysisContext(chain consensus.ChainHeaderReader, header *types.Header, state StateReader) *AnalysisContext {
+	ctx := &AnalysisContext{State: state}
+	if header == nil {
+		return ctx
+	}
+	ctx.BlockNumber = header.Number.Uint64()
+	if header.BaseFee != nil {
+		ctx.BaseFee = new(big.Int).Set(header.BaseFee)
+	}
+
+	if chain == nil {
+		return ctx
+	}
+	parent := chain.GetHeader(header.ParentHash, ctx.BlockNumber-1)
+	for parent != nil && len(ctx.RecentBlocks) < analysisRecentBlocks {
+		ctx.RecentBlocks = append([]*types.Header{parent}, ctx.RecentBlocks...)
+		if parent.Number.Sign() == 0 {
+			break
+		}
+		parent = chain.GetHeader(parent.ParentHash, parent.Number.Uint64()-1)
+	}
+	return ctx
+}
+
+// priorityFee returns the part of a gas price above the base fee, or the gas
+// price itself when the base fee is unknown
+func (c *AnalysisContext) priorityFee(gasPrice *big.Int) *big.Int {
+	if c == nil || c.BaseFee == nil {
+		return gasPrice
+	}
+	tip := new(big.Int).Sub(gasPrice, c.BaseFee)
+	if tip.Sign() < 0 {
+		tip.SetInt64(0)
+	}
+	return tip
+}
+
+// hasCode reports whether an address holds contract code; ok is false without state
+func (c *AnalysisContext) hasCode(address common.Address) (hasCode bool, ok bool) {
+	if c == nil || c.State == nil {
+		return false, false
+	}
+	return len(c.State.GetCode(address)) > 0, true
+}
+
+// baseFeeNote qualifies gas price evidence when the base fee was taken into account
+func baseFeeNote(actx *AnalysisContext) string {
+	if actx == nil || actx.BaseFee == nil {
+		return ""
+	}
+	return " over base fee " + actx.BaseFee.String()
+}
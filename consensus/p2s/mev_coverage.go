@@ -0,0 +1,91 @@
+package p2s
+
+import "sort"
+
+// Detection modules reported in coverage annotations
+const (
+	ModuleHeuristics     = "heuristics"
+	ModuleCompositeRules = "composite_rules"
+	ModuleWashTrading    = "wash_trading"
+	ModuleSimulation     = "simulation"
+	ModuleProfit         = "profit_estimation"
+	ModuleMempoolStress  = "mempool_stress"
+	ModuleCrossDomain    = "cross_domain"
+)
+
+// DetectionCoverage states which detection modules contributed to an MEV score
+// and how complete their inputs were, so consumers can judge how far to trust it
+type DetectionCoverage struct {
+	ModulesRun        []string          `json:"modulesRun"`
+	ModulesSkipped    map[string]string `json:"modulesSkipped"` // Module to reason
+	Transactions      int               `json:"transactions"`
+	CompleteInputs    int               `json:"completeInputs"`    // PHTs with all hidden fields available
+	InputCompleteness float64           `json:"inputCompleteness"` // CompleteInputs over Transactions, 1 when empty
+}
+
+// Coverage reports which detection modules run for a block of PHTs and why others are skipped
+func (m *MEVDetector) Coverage(phts []*PHTTransaction) *DetectionCoverage {
+	return m.coverage(phts, true)
+}
+
+// coverage builds a coverage annotation; mempool stress only applies to block-level scores
+func (m *MEVDetector) coverage(phts []*PHTTransaction, blockLevel bool) *DetectionCoverage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	coverage := &DetectionCoverage{
+		ModulesRun:     []string{ModuleHeuristics, ModuleWashTrading},
+		ModulesSkipped: make(map[string]string),
+		Transactions:   len(phts),
+	}
+
+	if len(m.compositeRules) > 0 {
+		coverage.ModulesRun = append(coverage.ModulesRun, ModuleCompositeRules)
+	} else {
+		coverage.ModulesSkipped[ModuleCompositeRules] = "no composite patterns registered"
+	}
+
+	switch {
+	case m.config == nil || !m.config.EnableMEVSimulation:
+		coverage.ModulesSkipped[ModuleSimulation] = "simulation disabled"
+	case m.simulator == nil:
+		coverage.ModulesSkipped[ModuleSimulation] = "no simulation backend (missing state)"
+	default:
+		coverage.ModulesRun = append(coverage.ModulesRun, ModuleSimulation)
+	}
+
+	if m.marketData != nil {
+		coverage.ModulesRun = append(coverage.ModulesRun, ModuleProfit)
+	} else {
+		coverage.ModulesSkipped[ModuleProfit] = "no market data (pool state)"
+	}
+
+	if blockLevel {
+		coverage.ModulesRun = append(coverage.ModulesRun, ModuleCrossDomain)
+	} else {
+		coverage.ModulesSkipped[ModuleCrossDomain] = "applies to block-level scores only"
+	}
+
+	if !blockLevel {
+		coverage.ModulesSkipped[ModuleMempoolStress] = "applies to block-level scores only"
+	} else if m.stressWeight <= 0 {
+		coverage.ModulesSkipped[ModuleMempoolStress] = "stress weight is zero"
+	} else if m.stress.warm() {
+		coverage.ModulesRun = append(coverage.ModulesRun, ModuleMempoolStress)
+	} else {
+		coverage.ModulesSkipped[ModuleMempoolStress] = "baseline warming up"
+	}
+	sort.Strings(coverage.ModulesRun)
+
+	for _, pht := range phts {
+		if pht.GasPrice != nil && pht.Value != nil {
+			coverage.CompleteInputs++
+		}
+	}
+	coverage.InputCompleteness = 1
+	if len(phts) > 0 {
+		coverage.InputCompleteness = float64(coverage.CompleteInputs) / float64(len(phts))
+	}
+
+	return coverage
+}
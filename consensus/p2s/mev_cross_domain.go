@@ -0,0 +1,169 @@
+package p2s
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Bridge operations recognized by the cross-domain decoder
+const (
+	BridgeDeposit  = "deposit"
+	BridgeWithdraw = "withdraw"
+)
+
+// crossDomainPenalty is the score removed for each PHT involved in a cross-domain finding
+const crossDomainPenalty = 0.2
+
+// bridgeEntry describes a bridge entry point
+type bridgeEntry struct {
+	protocol    string
+	operation   string
+	beneficiary int // ABI word holding the receiving address, -1 when funds go to the sender
+}
+
+// bridgeSelectors maps bridge function selectors to the operation they perform
+var bridgeSelectors = map[[4]byte]bridgeEntry{
+	{0xb1, 0xa1, 0xa8, 0x82}: {"optimism", BridgeDeposit, -1},  // depositETH(uint32,bytes)
+	{0x9a, 0x2a, 0xc6, 0xd5}: {"optimism", BridgeDeposit, 0},   // depositETHTo(address,uint32,bytes)
+	{0x58, 0xa9, 0x97, 0xf6}: {"optimism", BridgeDeposit, -1},  // depositERC20(address,address,uint256,uint32,bytes)
+	{0x83, 0x8b, 0x25, 0x20}: {"optimism", BridgeDeposit, 2},   // depositERC20To(address,address,address,uint256,uint32,bytes)
+	{0x8c, 0x31, 0x52, 0xe9}: {"optimism", BridgeWithdraw, -1}, // finalizeWithdrawalTransaction((uint256,address,address,uint256,uint256,bytes))
+	{0x32, 0xb7, 0x00, 0x6d}: {"optimism", BridgeWithdraw, -1}, // withdraw(address,uint256,uint32,bytes)
+	{0xa3, 0xa7, 0x95, 0x48}: {"optimism", BridgeWithdraw, 1},  // withdrawTo(address,address,uint256,uint32,bytes)
+	{0x43, 0x93, 0x70, 0xb1}: {"arbitrum", BridgeDeposit, -1},  // depositEth()
+	{0xd2, 0xce, 0x7d, 0x65}: {"arbitrum", BridgeDeposit, 1},   // outboundTransfer(address,address,uint256,uint256,uint256,bytes)
+	{0x08, 0x63, 0x5a, 0x95}: {"arbitrum", BridgeWithdraw, 3},  // executeTransaction(bytes32[],uint256,address,address,uint256,uint256,uint256,uint256,bytes)
+	{0x4f, 0xaa, 0x8a, 0x26}: {"polygon", BridgeDeposit, 0},    // depositEtherFor(address)
+	{0xe3, 0xde, 0xc8, 0xfb}: {"polygon", BridgeDeposit, 0},    // depositFor(address,address,bytes)
+	{0x38, 0x05, 0x55, 0x0f}: {"polygon", BridgeWithdraw, -1},  // exit(bytes)
+}
+
+// BridgeCall is a decoded bridge deposit or withdrawal
+type BridgeCall struct {
+	Protocol    string         `json:"protocol"`
+	Operation   string         `json:"operation"`   // deposit or withdraw
+	Bridge      common.Address `json:"bridge"`      // Contract the call is sent to
+	Beneficiary common.Address `json:"beneficiary"` // Address receiving the bridged funds
+}
+
+// CrossDomainFinding links a bridge call to a DEX swap in the same block that
+// benefits the same address, the footprint of MEV extracted across domains
+type CrossDomainFinding struct {
+	Attack   string         `json:"attack"`  // Always cross_domain_mev
+	Bridge   common.Hash    `json:"bridge"`  // PHT calling the bridge
+	Swap     common.Hash    `json:"swap"`    // PHT performing the swap
+	Account  common.Address `json:"account"` // Address linking the two legs
+	Call     *BridgeCall    `json:"call"`
+	Evidence string         `json:"evidence"`
+}
+
+// decodeBridgeCall extracts a bridge operation from a PHT's call data
+func decodeBridgeCall(pht *PHTTransaction) (*BridgeCall, bool) {
+	data := pht.CallData
+	if len(data) < 4 {
+		return nil, false
+	}
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	entry, ok := bridgeSelectors[selector]
+	if !ok {
+		return nil, false
+	}
+
+	call := &BridgeCall{
+		Protocol:    entry.protocol,
+		Operation:   entry.operation,
+		Bridge:      pht.Recipient,
+		Beneficiary: pht.Sender,
+	}
+	if args := data[4:]; entry.beneficiary >= 0 && len(args) >= (entry.beneficiary+1)*32 {
+		call.Beneficiary = abiAddress(args, entry.beneficiary)
+	}
+	return call, true
+}
+
+// isSwapCall reports whether call data invokes a Uniswap V2-style router swap
+func isSwapCall(callData []byte) bool {
+	switch selectorHex(callData) {
+	case "0x38ed1739", // swapExactTokensForTokens
+		"0x7ff36ab5", // swapExactETHForTokens
+		"0x18cbafe5", // swapExactTokensForETH
+		"0xfb3bdb41", // swapETHForExactTokens
+		"0x8803dbee", // swapTokensForExactTokens
+		"0x4a25d94a": // swapTokensForExactETH
+		return true
+	}
+	return false
+}
+
+// DetectCrossDomainMEV correlates bridge deposits and withdrawals with DEX swaps in
+// the same block of PHTs. A swap is linked to a bridge call when it is sent by the
+// bridge caller or by the address receiving the bridged funds.
+func (m *MEVDetector) DetectCrossDomainMEV(phts []*PHTTransaction) []*CrossDomainFinding {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.detectCrossDomain(phts)
+}
+
+// detectCrossDomain is DetectCrossDomainMEV without locking
+func (m *MEVDetector) detectCrossDomain(phts []*PHTTransaction) []*CrossDomainFinding {
+	swaps := make(map[common.Address][]*PHTTransaction)
+	for _, pht := range phts {
+		if isSwapCall(pht.CallData) {
+			swaps[pht.Sender] = append(swaps[pht.Sender], pht)
+		}
+	}
+	if len(swaps) == 0 {
+		return nil
+	}
+
+	var findings []*CrossDomainFinding
+	for _, pht := range phts {
+		call, ok := decodeBridgeCall(pht)
+		if !ok {
+			continue
+		}
+		accounts := []common.Address{pht.Sender}
+		if call.Beneficiary != pht.Sender {
+			accounts = append(accounts, call.Beneficiary)
+		}
+		for _, account := range accounts {
+			for _, swap := range swaps[account] {
+				findings = append(findings, &CrossDomainFinding{
+					Attack:  "cross_domain_mev",
+					Bridge:  pht.TxHash,
+					Swap:    swap.TxHash,
+					Account: account,
+					Call:    call,
+					Evidence: fmt.Sprintf("%s %s via %s and swap %s by %s in the same block",
+						call.Protocol, call.Operation, call.Bridge.Hex(), selectorHex(swap.CallData), account.Hex()),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+// applyCrossDomain lowers a block's total score for every PHT involved in a
+// cross-domain finding and reports the attack
+func (m *MEVDetector) applyCrossDomain(phts []*PHTTransaction, totalScore float64, attacks []string) (float64, []string) {
+	findings := m.detectCrossDomain(phts)
+	if len(findings) == 0 {
+		return totalScore, attacks
+	}
+
+	involved := make(map[common.Hash]bool)
+	for _, finding := range findings {
+		involved[finding.Bridge] = true
+		involved[finding.Swap] = true
+	}
+	totalScore -= crossDomainPenalty * float64(len(involved))
+	if totalScore < 0 {
+		totalScore = 0
+	}
+
+	return totalScore, append(attacks, "cross_domain_mev")
+}
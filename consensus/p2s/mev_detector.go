@@ -1,7 +1,9 @@
 package p2s
 
 import (
+	"context"
 	"math/big"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -13,7 +15,18 @@ import (
 type MEVDetector struct {
 	attackPatterns map[string]*AttackPattern
 	threshold      float64
+	workers        int
 	config        *P2SConfig
+	metrics       *detectionMetrics
+	simulator     SimulationBackend
+	marketData    MarketData
+	compositeRules map[string]ruleExpr
+	transfers      *transferGraph
+	stress         *MempoolMonitor
+	stressWeight   float64
+	calibrated     bool // Attacks are reported only at or below the calibrated thresholds
+	reputation     *reputationTracker
+	riskBands      RiskBands
 	mu            sync.RWMutex
 }
 
@@ -23,14 +36,20 @@ type AttackPattern struct {
 	Threshold   float64 `json:"threshold"`
 	Description string  `json:"description"`
 	Severity    string  `json:"severity"` // low, medium, high, critical
+	Rule        string  `json:"rule,omitempty"`    // Composite rule over other patterns, e.g. "front_running AND high_value"
+	Penalty     float64 `json:"penalty,omitempty"` // Score removed when a composite rule matches
 }
 
 // MEVAnalysis contains the result of MEV analysis
 type MEVAnalysis struct {
-	Score           float64  `json:"score"`
-	DetectedAttacks []string `json:"detectedAttacks"`
-	RiskLevel       string   `json:"riskLevel"`
-	Recommendations []string `json:"recommendations"`
+	Score           float64     `json:"score"`
+	DetectedAttacks []string    `json:"detectedAttacks"`
+	RiskLevel       string      `json:"riskLevel"`
+	Recommendations []string    `json:"recommendations"`
+	Factors         []MEVFactor `json:"factors"` // Per-rule breakdown of the score
+	EstimatedProfit *big.Int    `json:"estimatedProfit"` // Approximate extractable value in wei
+	Coverage        *DetectionCoverage `json:"coverage"` // Detection modules behind the score
+	Liquidation     *LiquidationCall   `json:"liquidation,omitempty"` // Position being liquidated, if decoded
 }
 
 // NewMEVDetector creates a new MEV detector
@@ -38,7 +57,15 @@ func NewMEVDetector(config *P2SConfig) *MEVDetector {
 	detector := &MEVDetector{
 		attackPatterns: make(map[string]*AttackPattern),
 		threshold:      0.7,
+		workers:        runtime.NumCPU(),
 		config:        config,
+		metrics:       newDetectionMetrics(),
+		compositeRules: make(map[string]ruleExpr),
+		transfers:      newTransferGraph(),
+		stress:         NewMempoolMonitor(3),
+		stressWeight:   0.2,
+		reputation:     newReputationTracker(),
+		riskBands:      DefaultRiskBands(),
 	}
 	
 	// Initialize attack patterns
@@ -90,10 +117,36 @@ func (m *MEVDetector) initializeAttackPatterns() {
 		Description: "DAI price arbitrage between MakerDAO and exchanges",
 		Severity:    "low",
 	}
+	
+	m.attackPatterns["simulated_price_impact"] = &AttackPattern{
+		Name:        "Simulated Price Impact",
+		Threshold:   0.6,
+		Description: "EVM simulation shows price impact large enough to be sandwiched",
+		Severity:    "high",
+	}
+	
+	m.attackPatterns["wash_trading"] = &AttackPattern{
+		Name:        "Wash Trading",
+		Threshold:   0.5,
+		Description: "Token transfers flow in a circle between related addresses",
+		Severity:    "medium",
+	}
+	
+	m.attackPatterns["cross_domain_mev"] = &AttackPattern{
+		Name:        "Cross-Domain MEV",
+		Threshold:   0.5,
+		Description: "Bridge deposit or withdrawal correlated with a same-block swap by the same account",
+		Severity:    "high",
+	}
 }
 
 // DetectMEV detects MEV attacks in a set of PHTs
 func (m *MEVDetector) DetectMEV(phts []*PHTTransaction) (float64, []string) {
+	return m.DetectMEVWithContext(nil, phts)
+}
+
+// DetectMEVWithContext detects MEV attacks in a set of PHTs, letting rules consult chain state
+func (m *MEVDetector) DetectMEVWithContext(actx *AnalysisContext, phts []*PHTTransaction) (float64, []string) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
@@ -105,13 +158,16 @@ func (m *MEVDetector) DetectMEV(phts []*PHTTransaction) (float64, []string) {
 	var detectedAttacks []string
 	
 	for _, pht := range phts {
-		score, attacks := m.analyzeTransaction(pht)
+		score, attacks := m.analyzeTransaction(actx, pht)
 		totalScore += score
 		detectedAttacks = append(detectedAttacks, attacks...)
 	}
 	
-	// Normalize score
-	avgScore := totalScore / float64(len(phts))
+	// Bridge activity is correlated across the whole block
+	totalScore, detectedAttacks = m.applyCrossDomain(phts, totalScore, detectedAttacks)
+	
+	// Normalize score and account for mempool stress
+	avgScore := m.applyMempoolStress(totalScore / float64(len(phts)))
 	
 	// Remove duplicates from attacks
 	uniqueAttacks := m.removeDuplicateAttacks(detectedAttacks)
@@ -119,43 +175,189 @@ func (m *MEVDetector) DetectMEV(phts []*PHTTransaction) (float64, []string) {
 	return avgScore, uniqueAttacks
 }
 
-// analyzeTransaction analyzes a single transaction for MEV patterns
-func (m *MEVDetector) analyzeTransaction(pht *PHTTransaction) (float64, []string) {
-	var score float64 = 1.0
-	var attacks []string
+// DetectMEVParallel detects MEV attacks in a set of PHTs using a worker pool.
+// Results are identical to DetectMEV; analysis stops early if ctx is cancelled.
+func (m *MEVDetector) DetectMEVParallel(ctx context.Context, phts []*PHTTransaction) (float64, []string, error) {
+	return m.DetectMEVParallelWithContext(ctx, nil, phts)
+}
+
+// DetectMEVParallelWithContext is DetectMEVParallel with chain state available to rules
+func (m *MEVDetector) DetectMEVParallelWithContext(ctx context.Context, actx *AnalysisContext, phts []*PHTTransaction) (float64, []string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	
-	// Check for sandwich attack patterns
-	if m.isSandwichPattern(pht) {
-		score -= 0.3
-		attacks = append(attacks, "sandwich_attack")
+	if err := ctx.Err(); err != nil {
+		return 0, nil, err
 	}
 	
-	// Check for front-running patterns
-	if m.isFrontRunPattern(pht) {
-		score -= 0.2
-		attacks = append(attacks, "front_running")
+	if len(phts) == 0 {
+		return 1.0, []string{}, nil
 	}
 	
-	// Check for arbitrage patterns
-	if m.isArbitragePattern(pht) {
-		score -= 0.1
-		attacks = append(attacks, "arbitrage")
+	workers := m.workers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(phts) {
+		workers = len(phts)
 	}
 	
-	// Check for liquidation patterns
-	if m.isLiquidationPattern(pht) {
-		score -= 0.25
-		attacks = append(attacks, "liquidation")
+	// Results are stored by index so attack order matches sequential analysis
+	scores := make([]float64, len(phts))
+	attacks := make([][]string, len(phts))
+	
+	jobs := make(chan int)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := range jobs {
+				scores[i], attacks[i] = m.analyzeTransaction(actx, phts[i])
+			}
+		}()
 	}
 	
-	// Check for high-value transactions
-	if m.isHighValuePattern(pht) {
-		score -= 0.15
+	var err error
+feed:
+	for i := range phts {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+	if err != nil {
+		return 0, nil, err
 	}
 	
-	// Check for contract interactions
-	if m.isContractInteractionPattern(pht) {
-		score -= 0.1
+	var totalScore float64
+	var detectedAttacks []string
+	for i := range phts {
+		totalScore += scores[i]
+		detectedAttacks = append(detectedAttacks, attacks[i]...)
+	}
+	totalScore, detectedAttacks = m.applyCrossDomain(phts, totalScore, detectedAttacks)
+	
+	return m.applyMempoolStress(totalScore / float64(len(phts))), m.removeDuplicateAttacks(detectedAttacks), nil
+}
+
+// SetConcurrency sets the number of workers used by DetectMEVParallel
+func (m *MEVDetector) SetConcurrency(workers int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	m.workers = workers
+}
+
+// MEVFactor explains the contribution of a single detection rule to an MEV score
+type MEVFactor struct {
+	Rule     string  `json:"rule"`     // Rule that fired
+	Attack   bool    `json:"attack"`   // Whether the rule reports an attack pattern
+	Penalty  float64 `json:"penalty"`  // Score removed by the rule
+	Evidence string  `json:"evidence"` // Observed data that triggered the rule
+}
+
+// detectionRule is a single heuristic applied to each PHT
+type detectionRule struct {
+	name    string
+	penalty float64
+	attack  bool
+	check   func(actx *AnalysisContext, pht *PHTTransaction) (bool, string)
+}
+
+// detectionRules returns the heuristics applied by analyzeTransaction, in evaluation order
+func (m *MEVDetector) detectionRules() []detectionRule {
+	return []detectionRule{
+		{name: "sandwich_attack", penalty: 0.3, attack: true, check: m.isSandwichPattern},
+		{name: "front_running", penalty: 0.2, attack: true, check: m.isFrontRunPattern},
+		{name: "arbitrage", penalty: 0.1, attack: true, check: m.isArbitragePattern},
+		{name: "liquidation", penalty: 0.25, attack: true, check: m.isLiquidationPattern},
+		{name: "high_value", penalty: 0.15, check: m.isHighValuePattern},
+		{name: "contract_interaction", penalty: 0.1, check: m.isContractInteractionPattern},
+		{name: "simulated_price_impact", penalty: 0.2, attack: true, check: m.isSimulatedPriceImpactPattern},
+		{name: "wash_trading", penalty: 0.2, attack: true, check: m.isWashTradingPattern},
+		{name: "repeat_offender", penalty: 0.15, check: m.isRepeatOffenderPattern},
+	}
+}
+
+// analyzeTransaction analyzes a single transaction for MEV patterns
+func (m *MEVDetector) analyzeTransaction(actx *AnalysisContext, pht *PHTTransaction) (float64, []string) {
+	score, attacks, _ := m.explainTransaction(actx, pht)
+	return score, attacks
+}
+
+// scoreTransaction scores a single transaction without recording detection metrics
+func (m *MEVDetector) scoreTransaction(pht *PHTTransaction) (float64, []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	score, attacks, _ := m.explainWith(nil, pht, func(name string, matched bool, impact float64) bool { return matched })
+	return score, m.calibratedAttacks(score, attacks)
+}
+
+// explainTransaction analyzes a single transaction and returns the factors behind its score
+func (m *MEVDetector) explainTransaction(actx *AnalysisContext, pht *PHTTransaction) (float64, []string, []MEVFactor) {
+	score, attacks, factors := m.explainWith(actx, pht, m.evaluate)
+	return score, m.calibratedAttacks(score, attacks), factors
+}
+
+// calibratedAttacks drops the attacks of a transaction scoring above the
+// calibrated detection threshold or above the calibrated threshold of their
+// pattern. Uncalibrated detectors report every matched attack. The caller must
+// hold the lock.
+func (m *MEVDetector) calibratedAttacks(score float64, attacks []string) []string {
+	if !m.calibrated || len(attacks) == 0 {
+		return attacks
+	}
+	if score > m.threshold {
+		return nil
+	}
+	kept := make([]string, 0, len(attacks))
+	for _, attack := range attacks {
+		if pattern, exists := m.attackPatterns[attack]; exists && score > pattern.Threshold {
+			continue
+		}
+		kept = append(kept, attack)
+	}
+	return kept
+}
+
+// explainWith analyzes a single transaction, reporting each rule outcome to evaluate
+func (m *MEVDetector) explainWith(actx *AnalysisContext, pht *PHTTransaction, evaluate func(name string, matched bool, impact float64) bool) (float64, []string, []MEVFactor) {
+	var score float64 = 1.0
+	var attacks []string
+	var factors []MEVFactor
+	matchedRules := make(map[string]bool)
+	
+	for _, rule := range m.detectionRules() {
+		matched, evidence := rule.check(actx, pht)
+		if !evaluate(rule.name, matched, rule.penalty) {
+			continue
+		}
+		matchedRules[rule.name] = true
+		
+		score -= rule.penalty
+		if rule.attack {
+			attacks = append(attacks, rule.name)
+		}
+		factors = append(factors, MEVFactor{
+			Rule:     rule.name,
+			Attack:   rule.attack,
+			Penalty:  rule.penalty,
+			Evidence: evidence,
+		})
+	}
+	
+	// Composite patterns are declared over the rules above
+	for _, factor := range m.evaluateCompositeRules(matchedRules, evaluate) {
+		score -= factor.Penalty
+		attacks = append(attacks, factor.Rule)
+		factors = append(factors, factor)
 	}
 	
 	// Ensure score is between 0 and 1
@@ -166,96 +368,116 @@ func (m *MEVDetector) analyzeTransaction(pht *PHTTransaction) (float64, []string
 		score = 1
 	}
 	
-	return score, attacks
+	return score, attacks, factors
 }
 
 // isSandwichPattern checks for sandwich attack patterns
-func (m *MEVDetector) isSandwichPattern(pht *PHTTransaction) bool {
-	// High gas price indicates potential sandwich attack
-	if pht.GasPrice.Cmp(big.NewInt(10000000000)) > 0 { // > 10 gwei
-		return true
+func (m *MEVDetector) isSandwichPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
+	// High gas price indicates potential sandwich attack; only the tip above the
+	// base fee counts when it is known
+	if tip := actx.priorityFee(pht.GasPrice); tip.Cmp(big.NewInt(10000000000)) > 0 { // > 10 gwei
+		return true, "gas price " + pht.GasPrice.String() + " wei above 10 gwei" + baseFeeNote(actx)
 	}
 	
 	// Large value transactions are more susceptible
 	if pht.Value.Cmp(big.NewInt(1000000000000000000)) > 0 { // > 1 ETH
-		return true
+		return true, "value " + pht.Value.String() + " wei above 1 ETH"
 	}
 	
 	// Contract interactions with specific patterns
 	if len(pht.CallData) > 0 {
 		// Check for common DEX function signatures
 		if m.hasDEXFunctionSignature(pht.CallData) {
-			return true
+			return true, "DEX selector " + selectorHex(pht.CallData)
 		}
 	}
 	
-	return false
+	return false, ""
 }
 
 // isFrontRunPattern checks for front-running patterns
-func (m *MEVDetector) isFrontRunPattern(pht *PHTTransaction) bool {
+func (m *MEVDetector) isFrontRunPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
 	// Very high gas price indicates front-running
-	if pht.GasPrice.Cmp(big.NewInt(50000000000)) > 0 { // > 50 gwei
-		return true
+	if tip := actx.priorityFee(pht.GasPrice); tip.Cmp(big.NewInt(50000000000)) > 0 { // > 50 gwei
+		return true, "gas price " + pht.GasPrice.String() + " wei above 50 gwei" + baseFeeNote(actx)
 	}
 	
 	// Transactions with specific call data patterns
 	if len(pht.CallData) > 0 {
 		// Check for common front-running patterns
 		if m.hasFrontRunPattern(pht.CallData) {
-			return true
+			return true, "front-running selector " + selectorHex(pht.CallData)
 		}
 	}
 	
-	return false
+	return false, ""
 }
 
 // isArbitragePattern checks for arbitrage patterns
-func (m *MEVDetector) isArbitragePattern(pht *PHTTransaction) bool {
+func (m *MEVDetector) isArbitragePattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
 	// Check for arbitrage-specific call data
 	if len(pht.CallData) > 0 {
 		// Look for arbitrage function signatures
 		if m.hasArbitrageFunctionSignature(pht.CallData) {
-			return true
+			return true, "arbitrage selector " + selectorHex(pht.CallData)
 		}
 	}
 	
 	// Check for specific recipient addresses (known arbitrage contracts)
 	if m.isKnownArbitrageContract(pht.Recipient) {
-		return true
+		return true, "known arbitrage contract " + pht.Recipient.Hex()
 	}
 	
-	return false
+	return false, ""
 }
 
 // isLiquidationPattern checks for liquidation patterns
-func (m *MEVDetector) isLiquidationPattern(pht *PHTTransaction) bool {
-	// Check for liquidation-specific call data
-	if len(pht.CallData) > 0 {
-		// Look for liquidation function signatures
-		if m.hasLiquidationFunctionSignature(pht.CallData) {
-			return true
-		}
+func (m *MEVDetector) isLiquidationPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
+	// Decode protocol-specific liquidation calls
+	if liquidation, ok := decodeLiquidation(pht); ok {
+		return true, liquidation.String()
 	}
 	
 	// Check for specific recipient addresses (known liquidation contracts)
 	if m.isKnownLiquidationContract(pht.Recipient) {
-		return true
+		return true, "known liquidation contract " + pht.Recipient.Hex()
 	}
 	
-	return false
+	return false, ""
 }
 
 // isHighValuePattern checks for high-value transaction patterns
-func (m *MEVDetector) isHighValuePattern(pht *PHTTransaction) bool {
+func (m *MEVDetector) isHighValuePattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
 	// Very large value transactions
-	return pht.Value.Cmp(big.NewInt(10000000000000000000)) > 0 // > 10 ETH
+	if pht.Value.Cmp(big.NewInt(10000000000000000000)) > 0 { // > 10 ETH
+		return true, "value " + pht.Value.String() + " wei above 10 ETH"
+	}
+	return false, ""
 }
 
 // isContractInteractionPattern checks for contract interaction patterns
-func (m *MEVDetector) isContractInteractionPattern(pht *PHTTransaction) bool {
+func (m *MEVDetector) isContractInteractionPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
 	// Non-zero call data indicates contract interaction
-	return len(pht.CallData) > 0
+	if len(pht.CallData) == 0 {
+		return false, ""
+	}
+	
+	// With chain state, call data sent to an account without code runs nothing
+	if hasCode, ok := actx.hasCode(pht.Recipient); ok {
+		if !hasCode {
+			return false, ""
+		}
+		return true, "call data sent to contract " + pht.Recipient.Hex()
+	}
+	return true, "call data present"
+}
+
+// selectorHex returns the 4-byte function selector of call data as hex
+func selectorHex(callData []byte) string {
+	if len(callData) < 4 {
+		return "0x" + common.Bytes2Hex(callData)
+	}
+	return "0x" + common.Bytes2Hex(callData[:4])
 }
 
 // hasDEXFunctionSignature checks for DEX function signatures
@@ -333,30 +555,6 @@ func (m *MEVDetector) hasArbitrageFunctionSignature(callData []byte) bool {
 	return false
 }
 
-// hasLiquidationFunctionSignature checks for liquidation function signatures
-func (m *MEVDetector) hasLiquidationFunctionSignature(callData []byte) bool {
-	if len(callData) < 4 {
-		return false
-	}
-	
-	// Common liquidation function signatures
-	liquidationSignatures := []string{
-		"0x42842e0e", // safeTransferFrom
-		"0xb88d4fde", // safeTransferFrom
-		"0x23b872dd", // transferFrom
-		"0xa9059cbb", // transfer
-	}
-	
-	signature := common.Bytes2Hex(callData[:4])
-	for _, liqSig := range liquidationSignatures {
-		if signature == liqSig {
-			return true
-		}
-	}
-	
-	return false
-}
-
 // isKnownArbitrageContract checks if address is a known arbitrage contract
 func (m *MEVDetector) isKnownArbitrageContract(address common.Address) bool {
 	// Known arbitrage contract addresses (example)
@@ -410,10 +608,17 @@ func (m *MEVDetector) removeDuplicateAttacks(attacks []string) []string {
 
 // AnalyzeMEVRisk analyzes MEV risk for a transaction
 func (m *MEVDetector) AnalyzeMEVRisk(pht *PHTTransaction) *MEVAnalysis {
+	return m.AnalyzeMEVRiskWithContext(nil, pht)
+}
+
+// AnalyzeMEVRiskWithContext analyzes MEV risk for a transaction against the given chain state
+func (m *MEVDetector) AnalyzeMEVRiskWithContext(actx *AnalysisContext, pht *PHTTransaction) *MEVAnalysis {
+	coverage := m.coverage([]*PHTTransaction{pht}, false)
+	
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
-	score, attacks := m.analyzeTransaction(pht)
+	score, attacks, factors := m.explainTransaction(actx, pht)
 	
 	// Determine risk level
 	riskLevel := m.determineRiskLevel(score)
@@ -421,25 +626,24 @@ func (m *MEVDetector) AnalyzeMEVRisk(pht *PHTTransaction) *MEVAnalysis {
 	// Generate recommendations
 	recommendations := m.generateRecommendations(attacks, score)
 	
-	return &MEVAnalysis{
+	analysis := &MEVAnalysis{
 		Score:           score,
 		DetectedAttacks: attacks,
 		RiskLevel:       riskLevel,
 		Recommendations: recommendations,
+		Factors:         factors,
+		EstimatedProfit: m.estimateTransactionProfit(pht, attacks),
+		Coverage:        coverage,
 	}
+	if liquidation, ok := decodeLiquidation(pht); ok {
+		analysis.Liquidation = liquidation
+	}
+	return analysis
 }
 
-// determineRiskLevel determines the risk level based on score
+// determineRiskLevel determines the risk level based on score using the configured bands
 func (m *MEVDetector) determineRiskLevel(score float64) string {
-	if score >= 0.8 {
-		return "low"
-	} else if score >= 0.6 {
-		return "medium"
-	} else if score >= 0.4 {
-		return "high"
-	} else {
-		return "critical"
-	}
+	return m.riskBands.level(score)
 }
 
 // generateRecommendations generates recommendations based on detected attacks
@@ -460,6 +664,8 @@ func (m *MEVDetector) generateRecommendations(attacks []string, score float64) [
 			recommendations = append(recommendations, "Monitor price differences across exchanges")
 		case "liquidation":
 			recommendations = append(recommendations, "Ensure sufficient collateralization ratio")
+		case "cross_domain_mev":
+			recommendations = append(recommendations, "Delay bridge finalization or route swaps through private order flow")
 		}
 	}
 	
@@ -481,12 +687,8 @@ func (m *MEVDetector) GetAllAttackPatterns() map[string]*AttackPattern {
 	
 	patterns := make(map[string]*AttackPattern)
 	for name, pattern := range m.attackPatterns {
-		patterns[name] = &AttackPattern{
-			Name:        pattern.Name,
-			Threshold:   pattern.Threshold,
-			Description: pattern.Description,
-			Severity:    pattern.Severity,
-		}
+		copied := *pattern
+		patterns[name] = &copied
 	}
 	
 	return patterns
@@ -540,6 +742,7 @@ func (m *MEVDetector) GetMEVStats() map[string]interface{} {
 		severityCount[pattern.Severity]++
 	}
 	stats["severity_distribution"] = severityCount
+	stats["detection_metrics"] = m.metrics.snapshot()
 	
 	return stats
 }
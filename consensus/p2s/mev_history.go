@@ -0,0 +1,139 @@
+package p2s
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// defaultMEVHistoryLimit is the number of per-transaction records retained
+const defaultMEVHistoryLimit = 100000
+
+// MEVRecord is the MEV analysis of a single PHT included in a B1 block
+type MEVRecord struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+	TxHash      common.Hash    `json:"txHash"`
+	Sender      common.Address `json:"sender"`
+	Score       float64        `json:"score"`
+	Attacks     []string       `json:"attacks"`
+	Timestamp   uint64         `json:"timestamp"`
+}
+
+// MEVHistorySource provides historical MEV records for analytics and reporting
+type MEVHistorySource interface {
+	Records(fromBlock, toBlock uint64) []MEVRecord
+	Coverage(fromBlock, toBlock uint64) map[uint64]*DetectionCoverage
+}
+
+// MEVHistory is an in-memory store of recent per-transaction MEV analyses.
+// Every recorded block is also appended to a bounded replication log that
+// read replicas follow.
+type MEVHistory struct {
+	records  []MEVRecord
+	coverage map[uint64]*DetectionCoverage // Detection coverage by block number
+	limit    int
+	entries  []HistoryEntry // Recent entries for replication, oldest first
+	seq      uint64         // Sequence number of the last applied entry
+	mu       sync.RWMutex
+}
+
+// NewMEVHistory creates a history retaining at most limit records
+func NewMEVHistory(limit int) *MEVHistory {
+	if limit <= 0 {
+		limit = defaultMEVHistoryLimit
+	}
+
+	return &MEVHistory{
+		records:  make([]MEVRecord, 0),
+		coverage: make(map[uint64]*DetectionCoverage),
+		limit:    limit,
+	}
+}
+
+// RecordBlock records the analysis of every PHT in a B1 block
+func (h *MEVHistory) RecordBlock(b1Block *B1Block, number uint64, hash common.Hash, score func(pht *PHTTransaction) (float64, []string)) {
+	records := make([]MEVRecord, 0, len(b1Block.PHTs))
+	for _, pht := range b1Block.PHTs {
+		txScore, attacks := score(pht)
+		records = append(records, MEVRecord{
+			BlockNumber: number,
+			BlockHash:   hash,
+			TxHash:      pht.TxHash,
+			Sender:      pht.Sender,
+			Score:       txScore,
+			Attacks:     attacks,
+			Timestamp:   b1Block.Timestamp,
+		})
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry := HistoryEntry{
+		Seq:         h.seq + 1,
+		BlockNumber: number,
+		Records:     records,
+		Coverage:    b1Block.Coverage,
+	}
+	h.apply(entry)
+	h.entries = append(h.entries, entry)
+	if overflow := len(h.entries) - replicationLogLimit; overflow > 0 {
+		h.entries = append([]HistoryEntry(nil), h.entries[overflow:]...)
+	}
+}
+
+// apply adds a block's records to the history, evicting the oldest beyond the limit
+func (h *MEVHistory) apply(entry HistoryEntry) {
+	h.seq = entry.Seq
+	number, records := entry.BlockNumber, entry.Records
+
+	h.records = append(h.records, records...)
+	if overflow := len(h.records) - h.limit; overflow > 0 {
+		h.records = append([]MEVRecord(nil), h.records[overflow:]...)
+	}
+
+	if entry.Coverage != nil {
+		h.coverage[number] = entry.Coverage
+	}
+	if len(h.records) > 0 {
+		for block := range h.coverage {
+			if block < h.records[0].BlockNumber {
+				delete(h.coverage, block)
+			}
+		}
+	}
+}
+
+// Coverage returns the detection coverage of blocks in [fromBlock, toBlock]
+func (h *MEVHistory) Coverage(fromBlock, toBlock uint64) map[uint64]*DetectionCoverage {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[uint64]*DetectionCoverage)
+	for block, coverage := range h.coverage {
+		if block >= fromBlock && block <= toBlock {
+			result[block] = coverage
+		}
+	}
+	return result
+}
+
+// Records returns all records with block numbers in [fromBlock, toBlock], ordered by block
+func (h *MEVHistory) Records(fromBlock, toBlock uint64) []MEVRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make([]MEVRecord, 0)
+	for _, record := range h.records {
+		if record.BlockNumber >= fromBlock && record.BlockNumber <= toBlock {
+			result = append(result, record)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].BlockNumber < result[j].BlockNumber
+	})
+
+	return result
+}
@@ -0,0 +1,131 @@
+package p2s
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Lending protocols recognized by the liquidation decoder
+const (
+	ProtocolAave     = "aave"
+	ProtocolCompound = "compound"
+	ProtocolMaker    = "maker"
+)
+
+var (
+	// aaveLiquidationSelector is the Aave v2/v3 pool liquidationCall(address,address,address,uint256,bool) selector
+	aaveLiquidationSelector = []byte{0x00, 0xa7, 0x18, 0xa9}
+
+	// compoundLiquidateSelector is the Compound CErc20 liquidateBorrow(address,uint256,address) selector
+	compoundLiquidateSelector = []byte{0xf5, 0xe3, 0xc4, 0x62}
+
+	// compoundLiquidateEtherSelector is the Compound CEther liquidateBorrow(address,address) selector
+	compoundLiquidateEtherSelector = []byte{0xaa, 0xe4, 0x0a, 0x2a}
+
+	// makerBarkSelector is the Maker Dog bark(bytes32,address,address) selector
+	makerBarkSelector = []byte{0xed, 0x99, 0x89, 0x08}
+
+	// makerBiteSelector is the Maker Cat bite(bytes32,address) selector
+	makerBiteSelector = []byte{0x45, 0xcf, 0x22, 0x30}
+)
+
+// LiquidationCall is a decoded lending protocol liquidation
+type LiquidationCall struct {
+	Protocol        string         `json:"protocol"`
+	Market          common.Address `json:"market"`          // Contract the liquidation is sent to
+	Borrower        common.Address `json:"borrower"`        // Owner of the position being liquidated
+	CollateralAsset common.Address `json:"collateralAsset"` // Collateral token or cToken, zero for Maker
+	DebtAsset       common.Address `json:"debtAsset"`       // Debt token or cToken, zero for Maker
+	Ilk             string         `json:"ilk,omitempty"`   // Maker collateral type, e.g. ETH-A
+	RepayAmount     *big.Int       `json:"repayAmount"`     // Debt repaid, nil when the whole position is seized
+}
+
+// String describes the liquidated position for rule evidence
+func (l *LiquidationCall) String() string {
+	collateral := l.CollateralAsset.Hex()
+	if l.Ilk != "" {
+		collateral = l.Ilk
+	}
+	repay := "entire position"
+	if l.RepayAmount != nil {
+		repay = l.RepayAmount.String()
+	}
+	return fmt.Sprintf("%s liquidation of %s (collateral %s, repay %s)", l.Protocol, l.Borrower.Hex(), collateral, repay)
+}
+
+// decodeLiquidation extracts the liquidated position from a PHT's call data
+func decodeLiquidation(pht *PHTTransaction) (*LiquidationCall, bool) {
+	data := pht.CallData
+	if len(data) < 4 {
+		return nil, false
+	}
+	selector, args := data[:4], data[4:]
+
+	switch {
+	case bytes.Equal(selector, aaveLiquidationSelector) && len(args) >= 5*32:
+		return &LiquidationCall{
+			Protocol:        ProtocolAave,
+			Market:          pht.Recipient,
+			CollateralAsset: abiAddress(args, 0),
+			DebtAsset:       abiAddress(args, 1),
+			Borrower:        abiAddress(args, 2),
+			RepayAmount:     abiUint(args, 3),
+		}, true
+
+	case bytes.Equal(selector, compoundLiquidateSelector) && len(args) >= 3*32:
+		return &LiquidationCall{
+			Protocol:        ProtocolCompound,
+			Market:          pht.Recipient,
+			Borrower:        abiAddress(args, 0),
+			RepayAmount:     abiUint(args, 1),
+			CollateralAsset: abiAddress(args, 2),
+			DebtAsset:       pht.Recipient,
+		}, true
+
+	case bytes.Equal(selector, compoundLiquidateEtherSelector) && len(args) >= 2*32:
+		// CEther repays with the attached value
+		repay := new(big.Int)
+		if pht.Value != nil {
+			repay.Set(pht.Value)
+		}
+		return &LiquidationCall{
+			Protocol:        ProtocolCompound,
+			Market:          pht.Recipient,
+			Borrower:        abiAddress(args, 0),
+			CollateralAsset: abiAddress(args, 1),
+			DebtAsset:       pht.Recipient,
+			RepayAmount:     repay,
+		}, true
+
+	case bytes.Equal(selector, makerBarkSelector) && len(args) >= 3*32,
+		bytes.Equal(selector, makerBiteSelector) && len(args) >= 2*32:
+		// Maker seizes the whole vault, so there is no repay amount
+		return &LiquidationCall{
+			Protocol: ProtocolMaker,
+			Market:   pht.Recipient,
+			Ilk:      decodeIlk(args[:32]),
+			Borrower: abiAddress(args, 1),
+		}, true
+	}
+
+	return nil, false
+}
+
+// abiAddress decodes the address in the i-th ABI word
+func abiAddress(args []byte, i int) common.Address {
+	return common.BytesToAddress(args[i*32+12 : (i+1)*32])
+}
+
+// abiUint decodes the unsigned integer in the i-th ABI word
+func abiUint(args []byte, i int) *big.Int {
+	return new(big.Int).SetBytes(args[i*32 : (i+1)*32])
+}
+
+// decodeIlk converts a right-padded bytes32 Maker collateral type to a string
+func decodeIlk(word []byte) string {
+	return strings.TrimRight(string(word), "\x00")
+}
@@ -0,0 +1,107 @@
+package p2s
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// PatternMetrics contains detection counters for a single attack pattern
+type PatternMetrics struct {
+	Evaluations uint64  `json:"evaluations"` // Number of transactions the pattern was checked against
+	Matches     uint64  `json:"matches"`     // Number of transactions the pattern matched
+	ScoreImpact float64 `json:"scoreImpact"` // Total score removed by the pattern
+	Misses      uint64  `json:"misses"`      // Attacks of this pattern confirmed by bounty claims but not detected
+}
+
+// detectionMetrics tracks per-pattern counters and mirrors them into the geth metrics registry
+type detectionMetrics struct {
+	patterns map[string]*PatternMetrics
+	mu       sync.Mutex
+}
+
+// newDetectionMetrics creates an empty detection metrics tracker
+func newDetectionMetrics() *detectionMetrics {
+	return &detectionMetrics{
+		patterns: make(map[string]*PatternMetrics),
+	}
+}
+
+// record records a single evaluation of a pattern
+func (d *detectionMetrics) record(name string, matched bool, impact float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pm, exists := d.patterns[name]
+	if !exists {
+		pm = &PatternMetrics{}
+		d.patterns[name] = pm
+	}
+
+	pm.Evaluations++
+	metrics.GetOrRegisterCounter("p2s/mev/"+name+"/evaluations", nil).Inc(1)
+
+	if matched {
+		pm.Matches++
+		pm.ScoreImpact += impact
+		metrics.GetOrRegisterCounter("p2s/mev/"+name+"/matches", nil).Inc(1)
+		metrics.GetOrRegisterGaugeFloat64("p2s/mev/"+name+"/impact", nil).Update(pm.ScoreImpact)
+	}
+}
+
+// recordMiss records an attack of a pattern that detection failed to flag
+func (d *detectionMetrics) recordMiss(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pm, exists := d.patterns[name]
+	if !exists {
+		pm = &PatternMetrics{}
+		d.patterns[name] = pm
+	}
+
+	pm.Misses++
+	metrics.GetOrRegisterCounter("p2s/mev/"+name+"/misses", nil).Inc(1)
+}
+
+// snapshot returns a copy of all pattern counters
+func (d *detectionMetrics) snapshot() map[string]PatternMetrics {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make(map[string]PatternMetrics, len(d.patterns))
+	for name, pm := range d.patterns {
+		result[name] = *pm
+	}
+
+	return result
+}
+
+// reset clears all pattern counters
+func (d *detectionMetrics) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.patterns = make(map[string]*PatternMetrics)
+}
+
+// evaluate records the outcome of a pattern check and returns whether it matched
+func (m *MEVDetector) evaluate(name string, matched bool, impact float64) bool {
+	m.metrics.record(name, matched, impact)
+	return matched
+}
+
+// RecordMiss records a confirmed attack of a pattern that the detector did not flag
+func (m *MEVDetector) RecordMiss(name string) {
+	m.metrics.recordMiss(name)
+}
+
+// GetDetectionMetrics returns per-pattern evaluation, match and score impact counters
+func (m *MEVDetector) GetDetectionMetrics() map[string]PatternMetrics {
+	return m.metrics.snapshot()
+}
+
+// ResetDetectionMetrics clears all per-pattern counters
+func (m *MEVDetector) ResetDetectionMetrics() {
+	m.metrics.reset()
+}
@@ -0,0 +1,176 @@
+package p2s
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PatternSchemaVersion is the attack pattern schema written by this detector.
+//
+//	0: unversioned sets, free-form severity
+//	1: severity normalized to low, medium, high or critical
+//	2: composite rules using AND/OR/NOT keywords
+const PatternSchemaVersion = 2
+
+// PatternSet is the persisted form of a detector's attack patterns
+type PatternSet struct {
+	Version  int                       `json:"version"`
+	Patterns map[string]*AttackPattern `json:"patterns"`
+}
+
+// PatternCompatibilityReport describes how a persisted pattern set was loaded
+type PatternCompatibilityReport struct {
+	FromVersion int               `json:"fromVersion"`
+	ToVersion   int               `json:"toVersion"`
+	Loaded      []string          `json:"loaded"`
+	Migrated    map[string]string `json:"migrated"` // Pattern to description of changes
+	Skipped     map[string]string `json:"skipped"`  // Pattern to reason
+}
+
+// patternMigrations upgrade a pattern from version i to i+1, returning a description of any change
+var patternMigrations = []func(pattern *AttackPattern) string{
+	// 0 -> 1: normalize severity
+	func(pattern *AttackPattern) string {
+		severity := strings.ToLower(strings.TrimSpace(pattern.Severity))
+		switch severity {
+		case "low", "medium", "high", "critical":
+		case "info", "minor":
+			severity = "low"
+		case "major", "severe":
+			severity = "high"
+		default:
+			severity = "medium"
+		}
+		if severity == pattern.Severity {
+			return ""
+		}
+		change := fmt.Sprintf("severity %q -> %q", pattern.Severity, severity)
+		pattern.Severity = severity
+		return change
+	},
+	// 1 -> 2: composite rules switch from C-style operators to keywords
+	func(pattern *AttackPattern) string {
+		if pattern.Rule == "" {
+			return ""
+		}
+		rule := strings.NewReplacer("&&", " AND ", "||", " OR ", "!", " NOT ").Replace(pattern.Rule)
+		rule = strings.Join(strings.Fields(rule), " ")
+		if rule == pattern.Rule {
+			return ""
+		}
+		change := fmt.Sprintf("rule %q -> %q", pattern.Rule, rule)
+		pattern.Rule = rule
+		return change
+	},
+}
+
+// ExportPatterns returns the detector's attack patterns as a versioned pattern set
+func (m *MEVDetector) ExportPatterns() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := &PatternSet{
+		Version:  PatternSchemaVersion,
+		Patterns: m.attackPatterns,
+	}
+	return json.MarshalIndent(set, "", "  ")
+}
+
+// LoadPatterns migrates a persisted pattern set to the current schema and applies it.
+// Built-in patterns have their metadata updated; composite patterns are registered.
+// Patterns that cannot be loaded are skipped and listed in the report.
+func (m *MEVDetector) LoadPatterns(data []byte) (*PatternCompatibilityReport, error) {
+	var set PatternSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	if set.Version > PatternSchemaVersion {
+		return nil, fmt.Errorf("pattern set version %d is newer than supported version %d", set.Version, PatternSchemaVersion)
+	}
+
+	report := &PatternCompatibilityReport{
+		FromVersion: set.Version,
+		ToVersion:   PatternSchemaVersion,
+		Loaded:      []string{},
+		Migrated:    make(map[string]string),
+		Skipped:     make(map[string]string),
+	}
+
+	ids := make([]string, 0, len(set.Patterns))
+	for id, pattern := range set.Patterns {
+		if pattern == nil {
+			report.Skipped[id] = "empty pattern"
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		var changes []string
+		for version := set.Version; version < PatternSchemaVersion; version++ {
+			if change := patternMigrations[version](set.Patterns[id]); change != "" {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) > 0 {
+			report.Migrated[id] = strings.Join(changes, "; ")
+		}
+	}
+
+	// Composite rules may reference each other, so register them until no progress is made
+	pending := []string{}
+	for _, id := range ids {
+		pattern := set.Patterns[id]
+		if pattern.Rule != "" {
+			pending = append(pending, id)
+			continue
+		}
+		if err := m.updateBuiltinPattern(id, pattern); err != nil {
+			report.Skipped[id] = err.Error()
+			continue
+		}
+		report.Loaded = append(report.Loaded, id)
+	}
+	for len(pending) > 0 {
+		var retry []string
+		errs := make(map[string]error)
+		for _, id := range pending {
+			if err := m.AddCompositePattern(id, set.Patterns[id]); err != nil {
+				errs[id] = err
+				retry = append(retry, id)
+				continue
+			}
+			report.Loaded = append(report.Loaded, id)
+		}
+		if len(retry) == len(pending) {
+			for id, err := range errs {
+				report.Skipped[id] = err.Error()
+			}
+			break
+		}
+		pending = retry
+	}
+	sort.Strings(report.Loaded)
+
+	return report, nil
+}
+
+// updateBuiltinPattern updates the metadata of a built-in attack pattern
+func (m *MEVDetector) updateBuiltinPattern(id string, pattern *AttackPattern) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, exists := m.attackPatterns[id]
+	if !exists || existing.Rule != "" {
+		return fmt.Errorf("unknown built-in pattern %s", id)
+	}
+
+	existing.Name = pattern.Name
+	existing.Threshold = pattern.Threshold
+	existing.Description = pattern.Description
+	existing.Severity = pattern.Severity
+	return nil
+}
@@ -0,0 +1,236 @@
+package p2s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// scoringServiceName is the gRPC service name of the remote scoring service
+	scoringServiceName = "p2s.ScoringService"
+
+	// scoringCodecName is the content subtype of scoring messages, which are JSON encoded
+	scoringCodecName = "p2s-json"
+
+	// defaultRemoteScoringTimeout bounds a single remote scoring call
+	defaultRemoteScoringTimeout = 500 * time.Millisecond
+
+	// defaultRemoteScoringBatchSize is the number of PHTs sent per remote scoring call
+	defaultRemoteScoringBatchSize = 256
+)
+
+func init() {
+	encoding.RegisterCodec(scoringCodec{})
+}
+
+// MEVScorer scores the PHTs of a B1 block. *MEVDetector and *RemoteDetector implement it.
+type MEVScorer interface {
+	DetectMEVParallelWithContext(ctx context.Context, actx *AnalysisContext, phts []*PHTTransaction) (float64, []string, error)
+}
+
+// ScoringRequest asks a scoring service to score a batch of PHTs
+type ScoringRequest struct {
+	BlockNumber  uint64            `json:"blockNumber"`
+	BaseFee      *big.Int          `json:"baseFee,omitempty"`
+	RecentBlocks []*types.Header   `json:"recentBlocks,omitempty"`
+	PHTs         []*PHTTransaction `json:"phts"`
+}
+
+// ScoringResponse is the score of a batch of PHTs
+type ScoringResponse struct {
+	Score   float64  `json:"score"`
+	Attacks []string `json:"attacks"`
+}
+
+// ScoringService is the gRPC scoring service run by a standalone detector process.
+// Requests carry hidden PHT fields, so the service must only be reachable by its
+// consensus node over an authenticated channel.
+type ScoringService interface {
+	Score(ctx context.Context, req *ScoringRequest) (*ScoringResponse, error)
+}
+
+// scoringCodec encodes scoring messages as JSON so the service needs no generated stubs
+type scoringCodec struct{}
+
+func (scoringCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (scoringCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (scoringCodec) Name() string                               { return scoringCodecName }
+
+// scoringServiceDesc describes the scoring service to gRPC
+var scoringServiceDesc = grpc.ServiceDesc{
+	ServiceName: scoringServiceName,
+	HandlerType: (*ScoringService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Score",
+			Handler:    scoreHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// scoreHandler dispatches a Score call to the registered service
+func scoreHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(ScoringRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScoringService).Score(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + scoringServiceName + "/Score",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScoringService).Score(ctx, req.(*ScoringRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// RegisterScoringService registers a scoring service with a gRPC server
+func RegisterScoringService(server *grpc.Server, service ScoringService) {
+	server.RegisterService(&scoringServiceDesc, service)
+}
+
+// detectorScoringService serves scoring requests from a local detector
+type detectorScoringService struct {
+	detector *MEVDetector
+}
+
+// NewDetectorScoringService serves a detector as a scoring service
+func NewDetectorScoringService(detector *MEVDetector) ScoringService {
+	return &detectorScoringService{detector: detector}
+}
+
+// Score scores a batch of PHTs with the wrapped detector
+func (s *detectorScoringService) Score(ctx context.Context, req *ScoringRequest) (*ScoringResponse, error) {
+	actx := &AnalysisContext{
+		BlockNumber:  req.BlockNumber,
+		BaseFee:      req.BaseFee,
+		RecentBlocks: req.RecentBlocks,
+	}
+	score, attacks, err := s.detector.DetectMEVParallelWithContext(ctx, actx, req.PHTs)
+	if err != nil {
+		return nil, err
+	}
+	return &ScoringResponse{Score: score, Attacks: attacks}, nil
+}
+
+// RemoteDetector scores PHTs on a remote scoring service in batches, falling back
+// to the local detector when the service fails or exceeds its timeout. Block-level
+// correlation such as cross-domain detection only spans a single batch.
+type RemoteDetector struct {
+	conn      *grpc.ClientConn
+	local     *MEVDetector
+	timeout   time.Duration
+	batchSize int
+
+	calls     uint64
+	fallbacks uint64
+}
+
+// NewRemoteDetector creates a remote detector over an established connection.
+// Zero timeout or batch size select the defaults.
+func NewRemoteDetector(conn *grpc.ClientConn, local *MEVDetector, timeout time.Duration, batchSize int) *RemoteDetector {
+	if timeout <= 0 {
+		timeout = defaultRemoteScoringTimeout
+	}
+	if batchSize <= 0 {
+		batchSize = defaultRemoteScoringBatchSize
+	}
+
+	return &RemoteDetector{
+		conn:      conn,
+		local:     local,
+		timeout:   timeout,
+		batchSize: batchSize,
+	}
+}
+
+// DetectMEVParallelWithContext scores PHTs remotely, returning the PHT-weighted
+// average score of all batches and the union of their attacks
+func (r *RemoteDetector) DetectMEVParallelWithContext(ctx context.Context, actx *AnalysisContext, phts []*PHTTransaction) (float64, []string, error) {
+	if len(phts) == 0 {
+		return 1.0, []string{}, nil
+	}
+	atomic.AddUint64(&r.calls, 1)
+
+	score, attacks, err := r.score(ctx, actx, phts)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return 0, nil, ctxErr
+		}
+		atomic.AddUint64(&r.fallbacks, 1)
+		log.Warn("Remote MEV scoring failed, falling back to local detector", "phts", len(phts), "err", err)
+		return r.local.DetectMEVParallelWithContext(ctx, actx, phts)
+	}
+	return score, attacks, nil
+}
+
+// score sends all batches to the scoring service
+func (r *RemoteDetector) score(ctx context.Context, actx *AnalysisContext, phts []*PHTTransaction) (float64, []string, error) {
+	var (
+		total   float64
+		attacks []string
+		seen    = make(map[string]bool)
+	)
+	for start := 0; start < len(phts); start += r.batchSize {
+		end := start + r.batchSize
+		if end > len(phts) {
+			end = len(phts)
+		}
+		req := &ScoringRequest{PHTs: phts[start:end]}
+		if actx != nil {
+			req.BlockNumber = actx.BlockNumber
+			req.BaseFee = actx.BaseFee
+			req.RecentBlocks = actx.RecentBlocks
+		}
+
+		resp, err := r.invoke(ctx, req)
+		if err != nil {
+			return 0, nil, err
+		}
+		if resp.Score < 0 || resp.Score > 1 {
+			return 0, nil, errors.New("remote score out of range")
+		}
+		total += resp.Score * float64(end-start)
+		for _, attack := range resp.Attacks {
+			if !seen[attack] {
+				seen[attack] = true
+				attacks = append(attacks, attack)
+			}
+		}
+	}
+	if attacks == nil {
+		attacks = []string{}
+	}
+	return total / float64(len(phts)), attacks, nil
+}
+
+// invoke performs a single Score call bounded by the remote timeout
+func (r *RemoteDetector) invoke(ctx context.Context, req *ScoringRequest) (*ScoringResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	resp := new(ScoringResponse)
+	err := r.conn.Invoke(ctx, "/"+scoringServiceName+"/Score", req, resp, grpc.CallContentSubtype(scoringCodecName))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Stats returns the number of scoring calls and how many fell back to the local detector
+func (r *RemoteDetector) Stats() (calls uint64, fallbacks uint64) {
+	return atomic.LoadUint64(&r.calls), atomic.LoadUint64(&r.fallbacks)
+}
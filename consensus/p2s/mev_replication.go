@@ -0,0 +1,353 @@
+package p2s
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// replicationLogLimit is the number of block entries a leader retains for replicas
+	replicationLogLimit = 1024
+
+	// replicationBatchSize is the maximum number of entries shipped per request
+	replicationBatchSize = 256
+)
+
+// ErrReplicationGap is returned when a replica asks for entries the leader no
+// longer retains; the replica must restore from a snapshot
+var ErrReplicationGap = errors.New("replication log no longer retains requested entries")
+
+// HistoryEntry is one recorded block in the replication log
+type HistoryEntry struct {
+	Seq         uint64             `json:"seq"`
+	BlockNumber uint64             `json:"blockNumber"`
+	Records     []MEVRecord        `json:"records"`
+	Coverage    *DetectionCoverage `json:"coverage,omitempty"`
+}
+
+// HistorySnapshot is the full contents of an MEV history at a sequence number
+type HistorySnapshot struct {
+	Seq      uint64                        `json:"seq"`
+	Records  []MEVRecord                   `json:"records"`
+	Coverage map[uint64]*DetectionCoverage `json:"coverage"`
+}
+
+// ReplicationSource ships history entries from a leader to its replicas
+type ReplicationSource interface {
+	// Entries returns up to max entries with sequence numbers after since
+	Entries(since uint64, max int) ([]HistoryEntry, error)
+
+	// Snapshot returns the leader's full history
+	Snapshot() (*HistorySnapshot, error)
+}
+
+// Entries returns up to max logged entries after since, or ErrReplicationGap if
+// entries after since have already been dropped from the log
+func (h *MEVHistory) Entries(since uint64, max int) ([]HistoryEntry, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if since >= h.seq {
+		return []HistoryEntry{}, nil
+	}
+	if len(h.entries) == 0 || h.entries[0].Seq > since+1 {
+		return nil, ErrReplicationGap
+	}
+
+	start := int(since + 1 - h.entries[0].Seq)
+	end := len(h.entries)
+	if max > 0 && start+max < end {
+		end = start + max
+	}
+	return append([]HistoryEntry(nil), h.entries[start:end]...), nil
+}
+
+// Snapshot returns a copy of the full history
+func (h *MEVHistory) Snapshot() (*HistorySnapshot, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshot := &HistorySnapshot{
+		Seq:      h.seq,
+		Records:  append([]MEVRecord(nil), h.records...),
+		Coverage: make(map[uint64]*DetectionCoverage, len(h.coverage)),
+	}
+	for block, coverage := range h.coverage {
+		snapshot.Coverage[block] = coverage
+	}
+	return snapshot, nil
+}
+
+// restore replaces the history with a snapshot
+func (h *MEVHistory) restore(snapshot *HistorySnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq = snapshot.Seq
+	h.records = append([]MEVRecord(nil), snapshot.Records...)
+	if overflow := len(h.records) - h.limit; overflow > 0 {
+		h.records = h.records[overflow:]
+	}
+	h.coverage = make(map[uint64]*DetectionCoverage, len(snapshot.Coverage))
+	for block, coverage := range snapshot.Coverage {
+		h.coverage[block] = coverage
+	}
+}
+
+// applyEntries applies shipped entries in order, skipping any already applied
+func (h *MEVHistory) applyEntries(entries []HistoryEntry) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	applied := 0
+	for _, entry := range entries {
+		if entry.Seq <= h.seq {
+			continue
+		}
+		if entry.Seq != h.seq+1 {
+			return applied, fmt.Errorf("replication entry %d out of order, expected %d", entry.Seq, h.seq+1)
+		}
+		h.apply(entry)
+		applied++
+	}
+	return applied, nil
+}
+
+// MEVHistoryReplica is a read-only follower of a leader's MEV history. It serves
+// analytics and report queries so explorer traffic never reaches the consensus node.
+type MEVHistoryReplica struct {
+	history  *MEVHistory
+	source   ReplicationSource
+	lastSync time.Time
+	lastErr  error
+
+	quit chan struct{}
+	mu   sync.Mutex
+}
+
+// NewMEVHistoryReplica creates a replica following source, retaining at most limit records
+func NewMEVHistoryReplica(source ReplicationSource, limit int) *MEVHistoryReplica {
+	return &MEVHistoryReplica{
+		history: NewMEVHistory(limit),
+		source:  source,
+	}
+}
+
+// Sync pulls all new entries from the leader, restoring from a snapshot if the
+// replica has fallen behind the leader's log. It returns the number of entries applied.
+func (r *MEVHistoryReplica) Sync() (int, error) {
+	applied := 0
+	for {
+		entries, err := r.source.Entries(r.history.Seq(), replicationBatchSize)
+		if errors.Is(err, ErrReplicationGap) {
+			snapshot, err := r.source.Snapshot()
+			if err != nil {
+				return applied, r.recordSync(err)
+			}
+			r.history.restore(snapshot)
+			log.Info("Restored MEV history replica from snapshot", "seq", snapshot.Seq)
+			continue
+		}
+		if err != nil {
+			return applied, r.recordSync(err)
+		}
+		if len(entries) == 0 {
+			return applied, r.recordSync(nil)
+		}
+
+		n, err := r.history.applyEntries(entries)
+		applied += n
+		if err != nil {
+			return applied, r.recordSync(err)
+		}
+	}
+}
+
+// recordSync records the outcome of a sync
+func (r *MEVHistoryReplica) recordSync(err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastErr = err
+	if err == nil {
+		r.lastSync = time.Now()
+	}
+	return err
+}
+
+// Seq returns the sequence number of the last entry applied by the replica
+func (r *MEVHistoryReplica) Seq() uint64 {
+	return r.history.Seq()
+}
+
+// LastSync returns the time of the last successful sync and the last sync error
+func (r *MEVHistoryReplica) LastSync() (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastSync, r.lastErr
+}
+
+// Records returns replicated records with block numbers in [fromBlock, toBlock]
+func (r *MEVHistoryReplica) Records(fromBlock, toBlock uint64) []MEVRecord {
+	return r.history.Records(fromBlock, toBlock)
+}
+
+// Coverage returns replicated detection coverage of blocks in [fromBlock, toBlock]
+func (r *MEVHistoryReplica) Coverage(fromBlock, toBlock uint64) map[uint64]*DetectionCoverage {
+	return r.history.Coverage(fromBlock, toBlock)
+}
+
+// Start syncs the replica in the background at the given interval
+func (r *MEVHistoryReplica) Start(interval time.Duration) {
+	r.mu.Lock()
+	if r.quit != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.quit = make(chan struct{})
+	quit := r.quit
+	r.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := r.Sync(); err != nil {
+					log.Warn("MEV history replica sync failed", "seq", r.Seq(), "err", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops background syncing
+func (r *MEVHistoryReplica) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.quit != nil {
+		close(r.quit)
+		r.quit = nil
+	}
+}
+
+// Seq returns the sequence number of the last recorded or applied entry
+func (h *MEVHistory) Seq() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.seq
+}
+
+// NewReplicationHandler serves a history's replication log over HTTP for replicas
+// in other regions:
+//
+//	GET /entries?since=N&max=M  entries after N as JSON, 410 Gone on a log gap
+//	GET /snapshot               the full history as JSON
+func NewReplicationHandler(source ReplicationSource) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/entries", func(w http.ResponseWriter, r *http.Request) {
+		since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		max, err := strconv.Atoi(r.URL.Query().Get("max"))
+		if err != nil || max <= 0 || max > replicationBatchSize {
+			max = replicationBatchSize
+		}
+
+		entries, err := source.Entries(since, max)
+		if errors.Is(err, ErrReplicationGap) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeReplicationJSON(w, entries)
+	})
+	mux.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := source.Snapshot()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeReplicationJSON(w, snapshot)
+	})
+	return mux
+}
+
+// writeReplicationJSON writes a JSON replication response
+func writeReplicationJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debug("Failed to write replication response", "err", err)
+	}
+}
+
+// HTTPReplicationSource follows a leader served by NewReplicationHandler
+type HTTPReplicationSource struct {
+	Endpoint string       // Base URL of the leader's replication handler
+	Client   *http.Client // Defaults to http.DefaultClient
+}
+
+// Entries fetches entries after since from the leader
+func (s *HTTPReplicationSource) Entries(since uint64, max int) ([]HistoryEntry, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatUint(since, 10))
+	query.Set("max", strconv.Itoa(max))
+
+	var entries []HistoryEntry
+	if err := s.get("/entries?"+query.Encode(), &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Snapshot fetches the leader's full history
+func (s *HTTPReplicationSource) Snapshot() (*HistorySnapshot, error) {
+	var snapshot HistorySnapshot
+	if err := s.get("/snapshot", &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// get decodes a JSON response from the leader
+func (s *HTTPReplicationSource) get(path string, v interface{}) error {
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(s.Endpoint + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return json.NewDecoder(resp.Body).Decode(v)
+	case http.StatusGone:
+		return ErrReplicationGap
+	default:
+		return fmt.Errorf("replication leader returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,254 @@
+package p2s
+
+import (
+	"crypto/ecdsa"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// scoreBuckets is the number of buckets in an MEV report score distribution
+const scoreBuckets = 10
+
+// ErrInvalidReportSignature is returned when an MEV report signature does not verify
+var ErrInvalidReportSignature = errors.New("invalid MEV report signature")
+
+// SenderOffense summarizes detected attacks for a single sender
+type SenderOffense struct {
+	Sender       common.Address `json:"sender"`
+	Attacks      int            `json:"attacks"`
+	Transactions int            `json:"transactions"`
+	LowestScore  float64        `json:"lowestScore"`
+}
+
+// BlockCoverage is the detection coverage of a single block in a report
+type BlockCoverage struct {
+	BlockNumber uint64             `json:"blockNumber"`
+	Coverage    *DetectionCoverage `json:"coverage"`
+}
+
+// MEVReport summarizes MEV analysis over a block range
+type MEVReport struct {
+	FromBlock         uint64          `json:"fromBlock"`
+	ToBlock           uint64          `json:"toBlock"`
+	GeneratedAt       uint64          `json:"generatedAt"`
+	Blocks            int             `json:"blocks"`
+	Transactions      int             `json:"transactions"`
+	MeanScore         float64         `json:"meanScore"`
+	ScoreDistribution []int           `json:"scoreDistribution"` // Transaction counts per 0.1 score bucket
+	AttackCounts      map[string]int  `json:"attackCounts"`
+	TopSenders        []SenderOffense `json:"topSenders"`
+	Coverage          []BlockCoverage `json:"coverage"`       // Per-block detection coverage
+	SkippedModules    map[string]int  `json:"skippedModules"` // Module to number of blocks it was skipped in
+
+	Signer    common.Address `json:"signer"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// SigningHash returns the hash signed by the report exporter
+func (r *MEVReport) SigningHash() (common.Hash, error) {
+	unsigned := *r
+	unsigned.Signature = nil
+
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte("p2s-mev-report"), data), nil
+}
+
+// MEVReportExporter produces signed MEV reports from a history source
+type MEVReportExporter struct {
+	source MEVHistorySource
+	key    *ecdsa.PrivateKey
+}
+
+// NewMEVReportExporter creates an exporter signing reports with key
+func NewMEVReportExporter(source MEVHistorySource, key *ecdsa.PrivateKey) *MEVReportExporter {
+	return &MEVReportExporter{
+		source: source,
+		key:    key,
+	}
+}
+
+// Generate builds and signs a report for blocks in [fromBlock, toBlock], listing up to topN senders
+func (e *MEVReportExporter) Generate(fromBlock, toBlock uint64, topN int) (*MEVReport, error) {
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("invalid block range %d-%d", fromBlock, toBlock)
+	}
+
+	records := e.source.Records(fromBlock, toBlock)
+	report := &MEVReport{
+		FromBlock:         fromBlock,
+		ToBlock:           toBlock,
+		GeneratedAt:       uint64(time.Now().Unix()),
+		Transactions:      len(records),
+		ScoreDistribution: make([]int, scoreBuckets),
+		AttackCounts:      make(map[string]int),
+		TopSenders:        []SenderOffense{},
+		Coverage:          []BlockCoverage{},
+		SkippedModules:    make(map[string]int),
+		Signer:            crypto.PubkeyToAddress(e.key.PublicKey),
+	}
+
+	blocks := make(map[uint64]bool)
+	senders := make(map[common.Address]*SenderOffense)
+	var totalScore float64
+	for _, record := range records {
+		blocks[record.BlockNumber] = true
+		totalScore += record.Score
+
+		bucket := int(record.Score * scoreBuckets)
+		if bucket >= scoreBuckets {
+			bucket = scoreBuckets - 1
+		}
+		if bucket < 0 {
+			bucket = 0
+		}
+		report.ScoreDistribution[bucket]++
+
+		offense, ok := senders[record.Sender]
+		if !ok {
+			offense = &SenderOffense{Sender: record.Sender, LowestScore: record.Score}
+			senders[record.Sender] = offense
+		}
+		offense.Transactions++
+		offense.Attacks += len(record.Attacks)
+		if record.Score < offense.LowestScore {
+			offense.LowestScore = record.Score
+		}
+
+		for _, attack := range record.Attacks {
+			report.AttackCounts[attack]++
+		}
+	}
+	report.Blocks = len(blocks)
+	if len(records) > 0 {
+		report.MeanScore = totalScore / float64(len(records))
+	}
+
+	for _, offense := range senders {
+		if offense.Attacks > 0 {
+			report.TopSenders = append(report.TopSenders, *offense)
+		}
+	}
+	sort.Slice(report.TopSenders, func(i, j int) bool {
+		a, b := report.TopSenders[i], report.TopSenders[j]
+		if a.Attacks != b.Attacks {
+			return a.Attacks > b.Attacks
+		}
+		return a.Sender.Hex() < b.Sender.Hex()
+	})
+	if topN >= 0 && len(report.TopSenders) > topN {
+		report.TopSenders = report.TopSenders[:topN]
+	}
+
+	for number, coverage := range e.source.Coverage(fromBlock, toBlock) {
+		report.Coverage = append(report.Coverage, BlockCoverage{BlockNumber: number, Coverage: coverage})
+		for module := range coverage.ModulesSkipped {
+			report.SkippedModules[module]++
+		}
+	}
+	sort.Slice(report.Coverage, func(i, j int) bool {
+		return report.Coverage[i].BlockNumber < report.Coverage[j].BlockNumber
+	})
+
+	hash, err := report.SigningHash()
+	if err != nil {
+		return nil, err
+	}
+	report.Signature, err = crypto.Sign(hash.Bytes(), e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// VerifyMEVReport checks that a report is signed by its declared signer
+func VerifyMEVReport(report *MEVReport) error {
+	hash, err := report.SigningHash()
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := crypto.SigToPub(hash.Bytes(), report.Signature)
+	if err != nil {
+		return ErrInvalidReportSignature
+	}
+	if crypto.PubkeyToAddress(*pubKey) != report.Signer {
+		return ErrInvalidReportSignature
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *MEVReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes the report as section,key,value rows. The signature row covers
+// the report's JSON signing hash, so CSV consumers verify against the JSON export.
+func (r *MEVReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		{"section", "key", "value"},
+		{"range", "fromBlock", strconv.FormatUint(r.FromBlock, 10)},
+		{"range", "toBlock", strconv.FormatUint(r.ToBlock, 10)},
+		{"range", "generatedAt", strconv.FormatUint(r.GeneratedAt, 10)},
+		{"summary", "blocks", strconv.Itoa(r.Blocks)},
+		{"summary", "transactions", strconv.Itoa(r.Transactions)},
+		{"summary", "meanScore", strconv.FormatFloat(r.MeanScore, 'f', 6, 64)},
+	}
+	for i, count := range r.ScoreDistribution {
+		bucket := fmt.Sprintf("%.1f-%.1f", float64(i)/scoreBuckets, float64(i+1)/scoreBuckets)
+		rows = append(rows, []string{"score_distribution", bucket, strconv.Itoa(count)})
+	}
+
+	attacks := make([]string, 0, len(r.AttackCounts))
+	for attack := range r.AttackCounts {
+		attacks = append(attacks, attack)
+	}
+	sort.Strings(attacks)
+	for _, attack := range attacks {
+		rows = append(rows, []string{"attacks", attack, strconv.Itoa(r.AttackCounts[attack])})
+	}
+
+	for _, offense := range r.TopSenders {
+		rows = append(rows, []string{"top_senders", offense.Sender.Hex(), strconv.Itoa(offense.Attacks)})
+	}
+
+	for _, block := range r.Coverage {
+		skipped := make([]string, 0, len(block.Coverage.ModulesSkipped))
+		for module := range block.Coverage.ModulesSkipped {
+			skipped = append(skipped, module)
+		}
+		sort.Strings(skipped)
+		rows = append(rows, []string{"coverage", strconv.FormatUint(block.BlockNumber, 10), fmt.Sprintf("run=%s skipped=%s completeness=%.2f",
+			strings.Join(block.Coverage.ModulesRun, "|"), strings.Join(skipped, "|"), block.Coverage.InputCompleteness)})
+	}
+
+	rows = append(rows,
+		[]string{"signature", "signer", r.Signer.Hex()},
+		[]string{"signature", "signature", r.Signature.String()},
+	)
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,235 @@
+package p2s
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// reputationHalfLife is the number of blocks after which an offense counts half
+	reputationHalfLife = 7200
+
+	// repeatOffenderThreshold is the decayed offense count at which a sender's
+	// cluster is treated as a repeat offender
+	repeatOffenderThreshold = 3.0
+
+	// maxTrackedSenders bounds the number of senders with a reputation
+	maxTrackedSenders = 65536
+)
+
+// SenderReputation is the MEV track record of a sender and the cluster it belongs to
+type SenderReputation struct {
+	Sender         common.Address   `json:"sender"`
+	Cluster        []common.Address `json:"cluster"`        // Senders linked to this one by direct funding
+	Detections     int              `json:"detections"`     // Attacks detected for this sender
+	Offenses       float64          `json:"offenses"`       // Decayed attack count of the whole cluster
+	LastDetection  uint64           `json:"lastDetection"`  // Block of this sender's most recent detection
+	RepeatOffender bool             `json:"repeatOffender"` // Whether scores of this sender are escalated
+}
+
+// senderRecord is the offense history of a single sender
+type senderRecord struct {
+	offenses      float64 // Decayed as of updated
+	updated       uint64
+	detections    int
+	lastDetection uint64
+}
+
+// clusterRecord is the aggregate offense history of a sender cluster, kept at its root
+type clusterRecord struct {
+	offenses float64 // Decayed as of updated, summed over the members
+	updated  uint64
+	members  []common.Address
+}
+
+// reputationTracker keeps decayed per-sender offense counts and clusters senders
+// that fund each other, so rotating addresses does not reset a reputation
+type reputationTracker struct {
+	senders  map[common.Address]*senderRecord
+	parent   map[common.Address]common.Address // Union-find forest of sender clusters
+	clusters map[common.Address]*clusterRecord // Cluster aggregates by root
+	head     uint64                            // Most recent block recorded
+	mu       sync.Mutex
+}
+
+// newReputationTracker creates an empty reputation tracker
+func newReputationTracker() *reputationTracker {
+	return &reputationTracker{
+		senders:  make(map[common.Address]*senderRecord),
+		parent:   make(map[common.Address]common.Address),
+		clusters: make(map[common.Address]*clusterRecord),
+	}
+}
+
+// decay returns an offense count recorded at block from, as seen at block to
+func decay(offenses float64, from, to uint64) float64 {
+	if to <= from {
+		return offenses
+	}
+	return offenses * math.Pow(0.5, float64(to-from)/reputationHalfLife)
+}
+
+// root returns the cluster representative of a sender
+func (r *reputationTracker) root(sender common.Address) common.Address {
+	for {
+		parent, ok := r.parent[sender]
+		if !ok || parent == sender {
+			return sender
+		}
+		// Path halving keeps lookups short
+		if grandparent, ok := r.parent[parent]; ok {
+			r.parent[sender] = grandparent
+		}
+		sender = parent
+	}
+}
+
+// track starts the record of a sender in a cluster of its own
+func (r *reputationTracker) track(sender common.Address, number uint64) *senderRecord {
+	record := &senderRecord{updated: number}
+	r.senders[sender] = record
+	r.clusters[sender] = &clusterRecord{updated: number, members: []common.Address{sender}}
+	return record
+}
+
+// link merges the clusters of two tracked senders and their aggregates
+func (r *reputationTracker) link(a, b common.Address) {
+	rootA, rootB := r.root(a), r.root(b)
+	if rootA == rootB {
+		return
+	}
+	// Lower address becomes the root so clustering is independent of link order
+	if bytes.Compare(rootB.Bytes(), rootA.Bytes()) < 0 {
+		rootA, rootB = rootB, rootA
+	}
+	r.parent[rootB] = rootA
+
+	kept, merged := r.clusters[rootA], r.clusters[rootB]
+	updated := kept.updated
+	if merged.updated > updated {
+		updated = merged.updated
+	}
+	kept.offenses = decay(kept.offenses, kept.updated, updated) + decay(merged.offenses, merged.updated, updated)
+	kept.updated = updated
+	if len(kept.members) < len(merged.members) {
+		kept.members, merged.members = merged.members, kept.members
+	}
+	kept.members = append(kept.members, merged.members...)
+	delete(r.clusters, rootB)
+}
+
+// record adds the attacks detected in a PHT included at block number
+func (r *reputationTracker) record(number uint64, pht *PHTTransaction, attacks int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if number > r.head {
+		r.head = number
+	}
+
+	record, tracked := r.senders[pht.Sender]
+	if !tracked {
+		if attacks == 0 || len(r.senders) >= maxTrackedSenders {
+			return
+		}
+		record = r.track(pht.Sender, number)
+	}
+
+	if attacks > 0 {
+		record.offenses = decay(record.offenses, record.updated, number) + float64(attacks)
+		record.updated = number
+		record.detections += attacks
+		record.lastDetection = number
+
+		cluster := r.clusters[r.root(pht.Sender)]
+		cluster.offenses = decay(cluster.offenses, cluster.updated, number) + float64(attacks)
+		if number > cluster.updated {
+			cluster.updated = number
+		}
+	}
+
+	// Accounts funded by a tracked sender join its cluster, so fresh addresses
+	// inherit the reputation of the sender that bankrolled them
+	if funded, ok := fundedAccount(pht); ok && funded != pht.Sender {
+		if _, known := r.senders[funded]; !known {
+			if len(r.senders) >= maxTrackedSenders {
+				return
+			}
+			r.track(funded, number)
+		}
+		r.link(pht.Sender, funded)
+	}
+}
+
+// fundedAccount returns the account receiving ETH or ERC-20 tokens from a PHT
+func fundedAccount(pht *PHTTransaction) (common.Address, bool) {
+	if edge, ok := decodeTransfer(pht); ok && edge.from == pht.Sender {
+		return edge.to, true
+	}
+	if len(pht.CallData) == 0 && pht.Value != nil && pht.Value.Sign() > 0 {
+		return pht.Recipient, true
+	}
+	return common.Address{}, false
+}
+
+// clusterOffenses returns the decayed offenses of a tracked sender's cluster and
+// its members
+func (r *reputationTracker) clusterOffenses(sender common.Address) (float64, []common.Address) {
+	cluster := r.clusters[r.root(sender)]
+	members := make([]common.Address, len(cluster.members))
+	copy(members, cluster.members)
+	return decay(cluster.offenses, cluster.updated, r.head), members
+}
+
+// reputation returns the reputation of a sender
+func (r *reputationTracker) reputation(sender common.Address) *SenderReputation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reputation := &SenderReputation{
+		Sender:  sender,
+		Cluster: []common.Address{},
+	}
+	record, tracked := r.senders[sender]
+	if !tracked {
+		return reputation
+	}
+
+	reputation.Offenses, reputation.Cluster = r.clusterOffenses(sender)
+	reputation.Detections = record.detections
+	reputation.LastDetection = record.lastDetection
+	reputation.RepeatOffender = reputation.Offenses >= repeatOffenderThreshold
+	sort.Slice(reputation.Cluster, func(i, j int) bool {
+		return bytes.Compare(reputation.Cluster[i].Bytes(), reputation.Cluster[j].Bytes()) < 0
+	})
+
+	return reputation
+}
+
+// RecordSenders updates sender reputations with the attacks detected in a block of PHTs
+func (m *MEVDetector) RecordSenders(number uint64, phts []*PHTTransaction) {
+	for _, pht := range phts {
+		_, attacks := m.scoreTransaction(pht)
+		m.reputation.record(number, pht, len(attacks))
+	}
+}
+
+// GetSenderReputation returns the MEV reputation of a sender
+func (m *MEVDetector) GetSenderReputation(sender common.Address) *SenderReputation {
+	return m.reputation.reputation(sender)
+}
+
+// isRepeatOffenderPattern checks whether a PHT's sender cluster has repeated detections
+func (m *MEVDetector) isRepeatOffenderPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
+	reputation := m.reputation.reputation(pht.Sender)
+	if !reputation.RepeatOffender {
+		return false, ""
+	}
+	return true, strconv.FormatFloat(reputation.Offenses, 'f', 2, 64) + " recent offenses across " +
+		strconv.Itoa(len(reputation.Cluster)) + " linked senders, last in block " + strconv.FormatUint(reputation.LastDetection, 10)
+}
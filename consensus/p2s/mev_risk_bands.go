@@ -0,0 +1,75 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRiskBands is returned when risk-level cutoffs are out of range or out of order
+var ErrInvalidRiskBands = errors.New("invalid risk bands")
+
+// RiskBands holds the MEV score cutoffs between risk levels. Scores at or above
+// Low are low risk, at or above Medium medium risk, at or above High high risk,
+// and anything lower is critical.
+type RiskBands struct {
+	Low    float64 `json:"low"`
+	Medium float64 `json:"medium"`
+	High   float64 `json:"high"`
+}
+
+// DefaultRiskBands returns the default risk-level cutoffs
+func DefaultRiskBands() RiskBands {
+	return RiskBands{
+		Low:    0.8,
+		Medium: 0.6,
+		High:   0.4,
+	}
+}
+
+// Validate checks that the cutoffs lie in [0, 1] and strictly decrease from Low to High
+func (b RiskBands) Validate() error {
+	for _, cutoff := range []float64{b.Low, b.Medium, b.High} {
+		if cutoff < 0 || cutoff > 1 {
+			return fmt.Errorf("%w: cutoff %f outside [0, 1]", ErrInvalidRiskBands, cutoff)
+		}
+	}
+	if b.Low <= b.Medium || b.Medium <= b.High {
+		return fmt.Errorf("%w: cutoffs must satisfy low > medium > high, got %f, %f, %f", ErrInvalidRiskBands, b.Low, b.Medium, b.High)
+	}
+	return nil
+}
+
+// level returns the risk level of a score
+func (b RiskBands) level(score float64) string {
+	switch {
+	case score >= b.Low:
+		return "low"
+	case score >= b.Medium:
+		return "medium"
+	case score >= b.High:
+		return "high"
+	default:
+		return "critical"
+	}
+}
+
+// SetRiskBands sets the risk-level cutoffs used by AnalyzeMEVRisk
+func (m *MEVDetector) SetRiskBands(bands RiskBands) error {
+	if err := bands.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.riskBands = bands
+	return nil
+}
+
+// GetRiskBands returns the risk-level cutoffs in use
+func (m *MEVDetector) GetRiskBands() RiskBands {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.riskBands
+}
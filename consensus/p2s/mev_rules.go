@@ -0,0 +1,251 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ruleExpr is a node of a composite pattern expression
+type ruleExpr interface {
+	eval(matched func(name string) bool) bool
+	refs() []string
+}
+
+type ruleRef string
+
+type ruleNot struct{ operand ruleExpr }
+
+type ruleAnd struct{ left, right ruleExpr }
+
+type ruleOr struct{ left, right ruleExpr }
+
+func (r ruleRef) eval(matched func(string) bool) bool { return matched(string(r)) }
+func (r ruleRef) refs() []string                      { return []string{string(r)} }
+
+func (r ruleNot) eval(matched func(string) bool) bool { return !r.operand.eval(matched) }
+func (r ruleNot) refs() []string                      { return r.operand.refs() }
+
+func (r ruleAnd) eval(matched func(string) bool) bool {
+	return r.left.eval(matched) && r.right.eval(matched)
+}
+func (r ruleAnd) refs() []string { return append(r.left.refs(), r.right.refs()...) }
+
+func (r ruleOr) eval(matched func(string) bool) bool {
+	return r.left.eval(matched) || r.right.eval(matched)
+}
+func (r ruleOr) refs() []string { return append(r.left.refs(), r.right.refs()...) }
+
+// parseRule parses a composite pattern expression such as
+// "front_running AND (high_value OR NOT contract_interaction)".
+// NOT binds tighter than AND, which binds tighter than OR.
+func parseRule(rule string) (ruleExpr, error) {
+	p := &ruleParser{tokens: tokenizeRule(rule)}
+	if len(p.tokens) == 0 {
+		return nil, errors.New("empty rule")
+	}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q", p.tokens[p.pos])
+	}
+
+	return expr, nil
+}
+
+// tokenizeRule splits a rule into identifiers, operators and parentheses
+func tokenizeRule(rule string) []string {
+	rule = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(rule)
+	return strings.Fields(rule)
+}
+
+// ruleParser is a recursive descent parser for composite pattern expressions
+type ruleParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "AND") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *ruleParser) parseUnary() (ruleExpr, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of rule")
+	case strings.EqualFold(token, "NOT"):
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return ruleNot{operand}, nil
+	case token == "(":
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return expr, nil
+	case token == ")" || strings.EqualFold(token, "AND") || strings.EqualFold(token, "OR"):
+		return nil, fmt.Errorf("unexpected token %q", token)
+	}
+	p.pos++
+	return ruleRef(token), nil
+}
+
+// AddCompositePattern registers an attack pattern declared as a rule over other
+// patterns, e.g. "front_running AND back_running". The rule may reference base
+// detection rules and other composite patterns but must not be cyclic.
+func (m *MEVDetector) AddCompositePattern(id string, pattern *AttackPattern) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if pattern == nil || pattern.Rule == "" {
+		return errors.New("composite pattern requires a rule")
+	}
+	if m.isBaseRule(id) {
+		return fmt.Errorf("pattern %s is a built-in detection rule", id)
+	}
+
+	expr, err := parseRule(pattern.Rule)
+	if err != nil {
+		return fmt.Errorf("invalid rule for %s: %v", id, err)
+	}
+	for _, ref := range expr.refs() {
+		if !m.isBaseRule(ref) && m.compositeRules[ref] == nil && ref != id {
+			return fmt.Errorf("rule for %s references unknown pattern %s", id, ref)
+		}
+	}
+
+	previous := m.compositeRules[id]
+	m.compositeRules[id] = expr
+	if m.hasRuleCycle(id, map[string]bool{}) {
+		if previous != nil {
+			m.compositeRules[id] = previous
+		} else {
+			delete(m.compositeRules, id)
+		}
+		return fmt.Errorf("rule for %s is cyclic", id)
+	}
+
+	m.attackPatterns[id] = pattern
+	return nil
+}
+
+// isBaseRule reports whether name is a hardcoded detection rule
+func (m *MEVDetector) isBaseRule(name string) bool {
+	for _, rule := range m.detectionRules() {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRuleCycle reports whether a composite rule transitively references itself
+func (m *MEVDetector) hasRuleCycle(id string, visiting map[string]bool) bool {
+	if visiting[id] {
+		return true
+	}
+	expr := m.compositeRules[id]
+	if expr == nil {
+		return false
+	}
+
+	visiting[id] = true
+	defer delete(visiting, id)
+	for _, ref := range expr.refs() {
+		if m.hasRuleCycle(ref, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCompositeRules evaluates all composite patterns given the base rules
+// that matched, returning the factors of those that fired in name order
+func (m *MEVDetector) evaluateCompositeRules(baseMatched map[string]bool, evaluate func(name string, matched bool, impact float64) bool) []MEVFactor {
+	if len(m.compositeRules) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(m.compositeRules))
+	for id := range m.compositeRules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make(map[string]bool)
+	var matched func(name string) bool
+	matched = func(name string) bool {
+		if expr, ok := m.compositeRules[name]; ok {
+			if result, done := results[name]; done {
+				return result
+			}
+			results[name] = expr.eval(matched)
+			return results[name]
+		}
+		return baseMatched[name]
+	}
+
+	var factors []MEVFactor
+	for _, id := range ids {
+		pattern := m.attackPatterns[id]
+		if !evaluate(id, matched(id), pattern.Penalty) {
+			continue
+		}
+		factors = append(factors, MEVFactor{
+			Rule:     id,
+			Attack:   true,
+			Penalty:  pattern.Penalty,
+			Evidence: "matched " + pattern.Rule,
+		})
+	}
+
+	return factors
+}
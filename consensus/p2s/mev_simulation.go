@@ -0,0 +1,218 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// simulationReadGas bounds each call reading a pool's price
+const simulationReadGas = 100000
+
+// Selectors of the view functions pool prices are read through
+var (
+	getReservesSelector = []byte{0x09, 0x02, 0xf1, 0xac} // getReserves() of Uniswap V2 style pairs
+	slot0Selector       = []byte{0x38, 0x50, 0xc7, 0xbd} // slot0() of Uniswap V3 style pools
+	balanceOfSelector   = []byte{0x70, 0xa0, 0x82, 0x31} // balanceOf(address) of ERC-20 tokens
+)
+
+// SimulationResult contains the measured effects of executing a revealed transaction
+type SimulationResult struct {
+	GasUsed          uint64                     `json:"gasUsed"`
+	Failed           bool                       `json:"failed"`
+	PriceImpacts     map[common.Address]float64 `json:"priceImpacts"`     // Relative price change of each moved pool
+	PriceImpact      float64                    `json:"priceImpact"`      // Largest relative price change of a watched pool
+	ExtractableValue *big.Int                   `json:"extractableValue"` // Approximate value a sandwich could extract
+}
+
+// WatchedPool is a liquidity pool whose price the simulator measures. The price
+// is read from the pool's getReserves, or failing that its slot0. Pools exposing
+// neither are priced by the balanceOf their tokens report for them: the ratio of
+// two balances, or a single balance.
+type WatchedPool struct {
+	Address common.Address
+	Tokens  []common.Address
+}
+
+// SimulationBackend executes a revealed MT against a copy of chain state
+type SimulationBackend interface {
+	Simulate(sender common.Address, mt *MTTransaction) (*SimulationResult, error)
+}
+
+// EVMSimulator simulates MTs with core/vm against a copy of the given state
+type EVMSimulator struct {
+	statedb     *state.StateDB
+	header      *types.Header
+	chainConfig *params.ChainConfig
+	pools       []WatchedPool // Liquidity pools whose prices are watched for price impact
+}
+
+// NewEVMSimulator creates a new EVM simulation backend
+func NewEVMSimulator(statedb *state.StateDB, header *types.Header, chainConfig *params.ChainConfig, pools []WatchedPool) *EVMSimulator {
+	return &EVMSimulator{
+		statedb:     statedb,
+		header:      header,
+		chainConfig: chainConfig,
+		pools:       pools,
+	}
+}
+
+// Simulate executes the MT against a state copy and measures pool price changes
+func (s *EVMSimulator) Simulate(sender common.Address, mt *MTTransaction) (*SimulationResult, error) {
+	if s.statedb == nil || s.header == nil {
+		return nil, errors.New("simulator has no state")
+	}
+
+	stateCopy := s.statedb.Copy()
+
+	blockCtx := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		Coinbase:    s.header.Coinbase,
+		BlockNumber: new(big.Int).Set(s.header.Number),
+		Time:        s.header.Time,
+		Difficulty:  new(big.Int),
+		GasLimit:    s.header.GasLimit,
+		BaseFee:     s.header.BaseFee,
+	}
+	txCtx := vm.TxContext{
+		Origin:   sender,
+		GasPrice: new(big.Int),
+	}
+	evm := vm.NewEVM(blockCtx, txCtx, stateCopy, s.chainConfig, vm.Config{NoBaseFee: true})
+
+	// Snapshot watched prices
+	before := make([]*big.Float, len(s.pools))
+	for i, pool := range s.pools {
+		before[i] = s.poolPrice(evm, pool)
+	}
+
+	value, overflow := uint256.FromBig(mt.Value)
+	if overflow {
+		return nil, fmt.Errorf("value overflows uint256: %v", mt.Value)
+	}
+
+	_, leftOver, err := evm.Call(vm.AccountRef(sender), mt.Recipient, mt.CallData, mt.GasLimit, value)
+
+	result := &SimulationResult{
+		GasUsed:          mt.GasLimit - leftOver,
+		Failed:           err != nil,
+		PriceImpacts:     make(map[common.Address]float64),
+		ExtractableValue: new(big.Int),
+	}
+
+	// Measure price impact as the largest relative price change
+	for i, pool := range s.pools {
+		if before[i] == nil {
+			continue
+		}
+		after := s.poolPrice(evm, pool)
+		if after == nil {
+			continue
+		}
+		ratio, _ := new(big.Float).Quo(after, before[i]).Float64()
+		impact := math.Abs(ratio - 1)
+		if impact == 0 {
+			continue
+		}
+		result.PriceImpacts[pool.Address] = impact
+		if impact > result.PriceImpact {
+			result.PriceImpact = impact
+		}
+	}
+
+	// A sandwich around a constant-product swap extracts roughly half the
+	// price impact applied to the trade size
+	if result.PriceImpact > 0 && mt.Value.Sign() > 0 {
+		extractable, _ := new(big.Float).Mul(new(big.Float).SetInt(mt.Value), big.NewFloat(result.PriceImpact/2)).Int(nil)
+		result.ExtractableValue = extractable
+	}
+
+	return result, nil
+}
+
+// poolPrice reads the price of a pool in the simulated state, or nil if the pool
+// exposes none
+func (s *EVMSimulator) poolPrice(evm *vm.EVM, pool WatchedPool) *big.Float {
+	// Reserves of a V2 pair price token0 in token1
+	if ret, err := readPool(evm, pool.Address, getReservesSelector); err == nil && len(ret) >= 64 {
+		reserve0 := new(big.Int).SetBytes(ret[:32])
+		reserve1 := new(big.Int).SetBytes(ret[32:64])
+		if reserve0.Sign() == 0 {
+			return nil
+		}
+		return new(big.Float).Quo(new(big.Float).SetInt(reserve1), new(big.Float).SetInt(reserve0))
+	}
+	// A V3 pool keeps the square root of its price
+	if ret, err := readPool(evm, pool.Address, slot0Selector); err == nil && len(ret) >= 32 {
+		sqrtPrice := new(big.Float).SetInt(new(big.Int).SetBytes(ret[:32]))
+		if sqrtPrice.Sign() == 0 {
+			return nil
+		}
+		return new(big.Float).Mul(sqrtPrice, sqrtPrice)
+	}
+	// Otherwise the tokens the pool holds
+	balances := make([]*big.Float, 0, len(pool.Tokens))
+	for _, token := range pool.Tokens {
+		ret, err := readPool(evm, token, append(common.CopyBytes(balanceOfSelector), common.LeftPadBytes(pool.Address.Bytes(), 32)...))
+		if err != nil || len(ret) < 32 {
+			return nil
+		}
+		balances = append(balances, new(big.Float).SetInt(new(big.Int).SetBytes(ret[:32])))
+	}
+	switch {
+	case len(balances) == 1 && balances[0].Sign() > 0:
+		return balances[0]
+	case len(balances) == 2 && balances[0].Sign() > 0:
+		return new(big.Float).Quo(balances[1], balances[0])
+	}
+	return nil
+}
+
+// readPool calls a view function in the simulated state
+func readPool(evm *vm.EVM, contract common.Address, input []byte) ([]byte, error) {
+	ret, _, err := evm.StaticCall(vm.AccountRef(common.Address{}), contract, input, simulationReadGas)
+	return ret, err
+}
+
+// SetSimulationBackend sets the backend used for simulation-based detection
+func (m *MEVDetector) SetSimulationBackend(backend SimulationBackend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.simulator = backend
+}
+
+// isSimulatedPriceImpactPattern simulates the PHT's hidden call and checks the resulting price impact
+func (m *MEVDetector) isSimulatedPriceImpactPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
+	if m.simulator == nil || m.config == nil || !m.config.EnableMEVSimulation {
+		return false, ""
+	}
+
+	result, err := m.simulator.Simulate(pht.Sender, &MTTransaction{
+		Recipient: pht.Recipient,
+		Value:     pht.Value,
+		CallData:  pht.CallData,
+		TxType:    pht.TxType,
+		GasLimit:  pht.GasLimit,
+	})
+	if err != nil || result.Failed {
+		return false, ""
+	}
+
+	if result.PriceImpact > m.config.SimulationImpactThreshold {
+		return true, fmt.Sprintf("simulated price impact %.4f, extractable value %s wei", result.PriceImpact, result.ExtractableValue)
+	}
+
+	return false, ""
+}
@@ -0,0 +1,195 @@
+package p2s
+
+import (
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	// stressBucket is the width of a mempool time-series bucket
+	stressBucket = time.Second
+
+	// stressSmoothing is the EWMA weight of the newest bucket in the baseline
+	stressSmoothing = 0.1
+
+	// stressWarmupBuckets is the number of buckets observed before stress is reported
+	stressWarmupBuckets = 10
+
+	// maxIdleBuckets caps how many empty buckets are folded into the baseline after a gap
+	maxIdleBuckets = 60
+)
+
+// MempoolStress describes how far current PHT flow deviates from its baseline
+type MempoolStress struct {
+	Stress        float64 `json:"stress"`        // 0 (normal) to 1 (spike at or above the spike factor)
+	Anomaly       bool    `json:"anomaly"`       // Whether a gas price or volume spike is in progress
+	GasRatio      float64 `json:"gasRatio"`      // Current mean gas price over baseline
+	VolumeRatio   float64 `json:"volumeRatio"`   // Current PHT count over baseline
+	BaselineGas   float64 `json:"baselineGas"`   // Baseline mean gas price in wei
+	BaselineCount float64 `json:"baselineCount"` // Baseline PHTs per bucket
+}
+
+// MempoolMonitor tracks incoming PHT gas prices and volumes as a time series and
+// flags sudden spikes that suggest bot activity
+type MempoolMonitor struct {
+	spikeFactor float64
+
+	bucketStart time.Time
+	count       int
+	gasSum      *big.Int
+
+	baselineGas   float64
+	baselineCount float64
+	buckets       int
+
+	mu sync.Mutex
+}
+
+// NewMempoolMonitor creates a monitor that reports an anomaly when gas prices or
+// volumes reach spikeFactor times their baseline
+func NewMempoolMonitor(spikeFactor float64) *MempoolMonitor {
+	if spikeFactor <= 1 {
+		spikeFactor = 3
+	}
+
+	return &MempoolMonitor{
+		spikeFactor: spikeFactor,
+		gasSum:      new(big.Int),
+	}
+}
+
+// Observe records an incoming PHT at the given time
+func (m *MempoolMonitor) Observe(gasPrice *big.Int, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.advance(at)
+	m.count++
+	if gasPrice != nil {
+		m.gasSum.Add(m.gasSum, gasPrice)
+	}
+}
+
+// advance closes elapsed buckets, folding them into the baseline
+func (m *MempoolMonitor) advance(at time.Time) {
+	if m.bucketStart.IsZero() {
+		m.bucketStart = at.Truncate(stressBucket)
+		return
+	}
+
+	elapsed := int(at.Sub(m.bucketStart) / stressBucket)
+	if elapsed <= 0 {
+		return
+	}
+
+	m.fold(m.count, m.gasSum)
+	idle := elapsed - 1
+	if idle > maxIdleBuckets {
+		idle = maxIdleBuckets
+	}
+	for i := 0; i < idle; i++ {
+		m.fold(0, nil)
+	}
+
+	m.bucketStart = at.Truncate(stressBucket)
+	m.count = 0
+	m.gasSum = new(big.Int)
+}
+
+// fold adds a closed bucket to the EWMA baselines
+func (m *MempoolMonitor) fold(count int, gasSum *big.Int) {
+	if m.buckets == 0 {
+		m.baselineCount = float64(count)
+	} else {
+		m.baselineCount += stressSmoothing * (float64(count) - m.baselineCount)
+	}
+
+	// Empty buckets carry no price information
+	if count > 0 {
+		mean, _ := new(big.Float).Quo(new(big.Float).SetInt(gasSum), big.NewFloat(float64(count))).Float64()
+		if m.baselineGas == 0 {
+			m.baselineGas = mean
+		} else {
+			m.baselineGas += stressSmoothing * (mean - m.baselineGas)
+		}
+	}
+
+	m.buckets++
+}
+
+// Stress returns the current mempool stress relative to the baseline
+func (m *MempoolMonitor) Stress(now time.Time) MempoolStress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.bucketStart.IsZero() {
+		m.advance(now)
+	}
+
+	result := MempoolStress{
+		BaselineGas:   m.baselineGas,
+		BaselineCount: m.baselineCount,
+	}
+	if m.buckets < stressWarmupBuckets || m.count == 0 {
+		return result
+	}
+
+	if m.baselineGas > 0 {
+		mean, _ := new(big.Float).Quo(new(big.Float).SetInt(m.gasSum), big.NewFloat(float64(m.count))).Float64()
+		result.GasRatio = mean / m.baselineGas
+	}
+	if m.baselineCount > 0 {
+		result.VolumeRatio = float64(m.count) / m.baselineCount
+	}
+
+	ratio := result.GasRatio
+	if result.VolumeRatio > ratio {
+		ratio = result.VolumeRatio
+	}
+	result.Stress = (ratio - 1) / (m.spikeFactor - 1)
+	if result.Stress < 0 {
+		result.Stress = 0
+	}
+	if result.Stress > 1 {
+		result.Stress = 1
+	}
+	result.Anomaly = ratio >= m.spikeFactor
+
+	return result
+}
+
+// warm reports whether enough buckets have been observed to report stress
+func (m *MempoolMonitor) warm() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.buckets >= stressWarmupBuckets
+}
+
+// ObservePHT records an incoming PHT in the mempool time series
+func (m *MEVDetector) ObservePHT(pht *PHTTransaction) {
+	m.stress.Observe(pht.GasPrice, time.Now())
+}
+
+// GetMempoolStress returns the current mempool stress
+func (m *MEVDetector) GetMempoolStress() MempoolStress {
+	return m.stress.Stress(time.Now())
+}
+
+// SetStressWeight sets how much of the block-level MEV score full mempool stress removes
+func (m *MEVDetector) SetStressWeight(weight float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stressWeight = weight
+}
+
+// applyMempoolStress lowers a block-level score in proportion to mempool stress
+func (m *MEVDetector) applyMempoolStress(score float64) float64 {
+	score -= m.stressWeight * m.stress.Stress(time.Now()).Stress
+	if score < 0 {
+		return 0
+	}
+	return score
+}
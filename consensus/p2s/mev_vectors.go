@@ -0,0 +1,246 @@
+package p2s
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TestVectorVersion is the test vector file format written by this detector
+const TestVectorVersion = 1
+
+// VectorFile is a file of MEV detection test vectors
+type VectorFile struct {
+	Version int           `json:"version"`
+	Vectors []*TestVector `json:"vectors"`
+}
+
+// TestVector is a set of PHT fixtures with the detections expected for them
+type TestVector struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	BlockNumber uint64            `json:"blockNumber,omitempty"`
+	BaseFee     *big.Int          `json:"baseFee,omitempty"` // Analysis base fee, nil to analyze without one
+	PHTs        []*PHTTransaction `json:"phts"`
+	Expect      VectorExpectation `json:"expect"`
+}
+
+// VectorExpectation describes the detections a vector must produce
+type VectorExpectation struct {
+	Attacks  []string `json:"attacks"`            // Attacks that must be detected
+	Absent   []string `json:"absent,omitempty"`   // Attacks that must not be detected
+	Exact    bool     `json:"exact,omitempty"`    // No attacks beyond Attacks may be detected
+	MinScore *float64 `json:"minScore,omitempty"` // Lowest acceptable block score
+	MaxScore *float64 `json:"maxScore,omitempty"` // Highest acceptable block score
+}
+
+// VectorResult is the outcome of running a single test vector
+type VectorResult struct {
+	File     string   `json:"file"`
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Score    float64  `json:"score"`
+	Detected []string `json:"detected"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// VectorReport summarizes a test vector run
+type VectorReport struct {
+	Total   int             `json:"total"`
+	Passed  int             `json:"passed"`
+	Failed  int             `json:"failed"`
+	Results []*VectorResult `json:"results"`
+}
+
+// OK reports whether every vector passed
+func (r *VectorReport) OK() bool {
+	return r.Failed == 0
+}
+
+// LoadVectorFile reads a test vector file
+func LoadVectorFile(path string) (*VectorFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file VectorFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid test vector file %s: %v", path, err)
+	}
+	if file.Version > TestVectorVersion {
+		return nil, fmt.Errorf("test vector file %s version %d is newer than supported version %d", path, file.Version, TestVectorVersion)
+	}
+	for i, vector := range file.Vectors {
+		if vector == nil || vector.Name == "" {
+			return nil, fmt.Errorf("test vector %d in %s has no name", i, path)
+		}
+		for _, pht := range vector.PHTs {
+			if pht == nil {
+				return nil, fmt.Errorf("test vector %s in %s has an empty PHT", vector.Name, path)
+			}
+			if pht.GasPrice == nil {
+				pht.GasPrice = new(big.Int)
+			}
+			if pht.Value == nil {
+				pht.Value = new(big.Int)
+			}
+		}
+	}
+	return &file, nil
+}
+
+// WriteVectorFile writes test vectors to path in the current format
+func WriteVectorFile(path string, vectors ...*TestVector) error {
+	data, err := json.MarshalIndent(&VectorFile{Version: TestVectorVersion, Vectors: vectors}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// RunVectors runs every test vector file (*.json) in dir against the detector's
+// current rule set. Each vector is analyzed by a fresh detector carrying the same
+// patterns, composite rules, threshold and risk bands, so results do not depend on
+// sender history, mempool stress or the order vectors run in. Simulation and market
+// data backends are not used.
+func (m *MEVDetector) RunVectors(dir string) (*VectorReport, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	report := &VectorReport{Results: []*VectorResult{}}
+	for _, path := range paths {
+		file, err := LoadVectorFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, vector := range file.Vectors {
+			result, err := m.runVector(vector)
+			if err != nil {
+				return nil, fmt.Errorf("test vector %s in %s: %v", vector.Name, path, err)
+			}
+			result.File = filepath.Base(path)
+
+			report.Total++
+			if result.Passed {
+				report.Passed++
+			} else {
+				report.Failed++
+			}
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// runVector analyzes a single vector and checks it against its expectation
+func (m *MEVDetector) runVector(vector *TestVector) (*VectorResult, error) {
+	sandbox, err := m.sandbox()
+	if err != nil {
+		return nil, err
+	}
+	actx := &AnalysisContext{BlockNumber: vector.BlockNumber, BaseFee: vector.BaseFee}
+	score, detected := sandbox.DetectMEVWithContext(actx, vector.PHTs)
+	sort.Strings(detected)
+
+	result := &VectorResult{
+		Name:     vector.Name,
+		Score:    score,
+		Detected: detected,
+		Failures: vector.Expect.check(score, detected),
+	}
+	result.Passed = len(result.Failures) == 0
+	return result, nil
+}
+
+// check returns a description of every way the detections miss the expectation
+func (e VectorExpectation) check(score float64, detected []string) []string {
+	found := make(map[string]bool, len(detected))
+	for _, attack := range detected {
+		found[attack] = true
+	}
+
+	var failures []string
+	expected := make(map[string]bool, len(e.Attacks))
+	for _, attack := range e.Attacks {
+		expected[attack] = true
+		if !found[attack] {
+			failures = append(failures, "expected "+attack+" to be detected")
+		}
+	}
+	for _, attack := range e.Absent {
+		if found[attack] {
+			failures = append(failures, "expected "+attack+" not to be detected")
+		}
+	}
+	if e.Exact {
+		var unexpected []string
+		for _, attack := range detected {
+			if !expected[attack] {
+				unexpected = append(unexpected, attack)
+			}
+		}
+		if len(unexpected) > 0 {
+			failures = append(failures, "unexpected detections "+strings.Join(unexpected, ", "))
+		}
+	}
+	if e.MinScore != nil && score < *e.MinScore {
+		failures = append(failures, fmt.Sprintf("score %f below %f", score, *e.MinScore))
+	}
+	if e.MaxScore != nil && score > *e.MaxScore {
+		failures = append(failures, fmt.Sprintf("score %f above %f", score, *e.MaxScore))
+	}
+	return failures
+}
+
+// sandbox returns a stateless copy of the detector's rule set
+func (m *MEVDetector) sandbox() (*MEVDetector, error) {
+	patterns, err := m.ExportPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	sandbox := NewMEVDetector(m.config)
+	if _, err := sandbox.LoadPatterns(patterns); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	sandbox.threshold = m.threshold
+	sandbox.calibrated = m.calibrated
+	sandbox.riskBands = m.riskBands
+	m.mu.RUnlock()
+	sandbox.stressWeight = 0
+	return sandbox, nil
+}
+
+// VectorFromBlock generates a test vector from the live detections of a B1 block,
+// expecting exactly the attacks detected when the block was built. Detections that
+// depended on sender history or simulation may not reproduce in RunVectors and
+// should be reviewed before the vector is added to a corpus.
+func VectorFromBlock(name string, block *B1Block) *TestVector {
+	vector := &TestVector{
+		Name:        name,
+		Description: fmt.Sprintf("generated from B1 block %s", block.BlockHash.Hex()),
+		PHTs:        block.PHTs,
+		Expect: VectorExpectation{
+			Attacks: append([]string{}, block.DetectedAttacks...),
+			Exact:   true,
+		},
+	}
+	sort.Strings(vector.Expect.Attacks)
+	if block.Header != nil {
+		vector.BlockNumber = block.Header.Number.Uint64()
+		if block.Header.BaseFee != nil {
+			vector.BaseFee = new(big.Int).Set(block.Header.BaseFee)
+		}
+	}
+	return vector
+}
@@ -0,0 +1,195 @@
+package p2s
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// maxTransferEdges is the number of recent transfers retained in the graph
+	maxTransferEdges = 4096
+
+	// maxWashCycleLength is the longest circular flow searched for, in transfers
+	maxWashCycleLength = 4
+)
+
+var (
+	// transferSelector is the ERC-20 transfer(address,uint256) selector
+	transferSelector = []byte{0xa9, 0x05, 0x9c, 0xbb}
+
+	// transferFromSelector is the ERC-20 transferFrom(address,address,uint256) selector
+	transferFromSelector = []byte{0x23, 0xb8, 0x72, 0xdd}
+)
+
+// transferEdge is a token transfer between two addresses
+type transferEdge struct {
+	token common.Address
+	from  common.Address
+	to    common.Address
+}
+
+// transferNode scopes an account to a token so flows of different tokens are not linked
+type transferNode struct {
+	token   common.Address
+	account common.Address
+}
+
+// transferGraph is a bounded in-memory graph of recent ERC-20 transfers
+type transferGraph struct {
+	edges map[common.Hash]transferEdge                             // Transfers by PHT hash
+	order []common.Hash                                            // Insertion order for eviction
+	out   map[transferNode]map[common.Address]map[common.Hash]bool // Sender -> receiver -> PHT hashes
+	mu    sync.RWMutex
+}
+
+// newTransferGraph creates an empty transfer graph
+func newTransferGraph() *transferGraph {
+	return &transferGraph{
+		edges: make(map[common.Hash]transferEdge),
+		out:   make(map[transferNode]map[common.Address]map[common.Hash]bool),
+	}
+}
+
+// decodeTransfer extracts an ERC-20 transfer from a PHT's call data
+func decodeTransfer(pht *PHTTransaction) (transferEdge, bool) {
+	data := pht.CallData
+	switch {
+	case len(data) >= 4+2*32 && bytes.Equal(data[:4], transferSelector):
+		return transferEdge{
+			token: pht.Recipient,
+			from:  pht.Sender,
+			to:    common.BytesToAddress(data[4+12 : 4+32]),
+		}, true
+	case len(data) >= 4+3*32 && bytes.Equal(data[:4], transferFromSelector):
+		return transferEdge{
+			token: pht.Recipient,
+			from:  common.BytesToAddress(data[4+12 : 4+32]),
+			to:    common.BytesToAddress(data[4+32+12 : 4+64]),
+		}, true
+	}
+	return transferEdge{}, false
+}
+
+// add records a transfer, ignoring PHTs that were already recorded
+func (g *transferGraph) add(hash common.Hash, edge transferEdge) {
+	if _, exists := g.edges[hash]; exists {
+		return
+	}
+
+	if len(g.order) >= maxTransferEdges {
+		g.remove(g.order[0])
+		g.order = g.order[1:]
+	}
+
+	g.edges[hash] = edge
+	g.order = append(g.order, hash)
+
+	from := transferNode{edge.token, edge.from}
+	if g.out[from] == nil {
+		g.out[from] = make(map[common.Address]map[common.Hash]bool)
+	}
+	if g.out[from][edge.to] == nil {
+		g.out[from][edge.to] = make(map[common.Hash]bool)
+	}
+	g.out[from][edge.to][hash] = true
+}
+
+// remove evicts a transfer from the graph
+func (g *transferGraph) remove(hash common.Hash) {
+	edge, exists := g.edges[hash]
+	if !exists {
+		return
+	}
+	delete(g.edges, hash)
+
+	from := transferNode{edge.token, edge.from}
+	delete(g.out[from][edge.to], hash)
+	if len(g.out[from][edge.to]) == 0 {
+		delete(g.out[from], edge.to)
+	}
+	if len(g.out[from]) == 0 {
+		delete(g.out, from)
+	}
+}
+
+// cycleLength returns the length of the shortest circular flow closed by edge, or 0 if none
+func (g *transferGraph) cycleLength(hash common.Hash, edge transferEdge) int {
+	if edge.from == edge.to {
+		return 1
+	}
+
+	// Breadth-first search from the receiver back to the sender over other transfers
+	visited := map[common.Address]bool{edge.to: true}
+	frontier := []common.Address{edge.to}
+	for depth := 1; depth < maxWashCycleLength && len(frontier) > 0; depth++ {
+		var next []common.Address
+		for _, account := range frontier {
+			for to, hashes := range g.out[transferNode{edge.token, account}] {
+				if len(hashes) == 1 && hashes[hash] {
+					continue
+				}
+				if to == edge.from {
+					return depth + 1
+				}
+				if !visited[to] {
+					visited[to] = true
+					next = append(next, to)
+				}
+			}
+		}
+		frontier = next
+	}
+	return 0
+}
+
+// record adds the transfer of a PHT included in a block, ignoring PHTs without
+// a transaction hash to key it by
+func (g *transferGraph) record(pht *PHTTransaction) {
+	if pht.TxHash == (common.Hash{}) {
+		return
+	}
+	edge, ok := decodeTransfer(pht)
+	if !ok {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.add(pht.TxHash, edge)
+}
+
+// closes returns the length of any circular flow a PHT's transfer closes over the
+// recorded transfers, without recording it
+func (g *transferGraph) closes(pht *PHTTransaction) (int, transferEdge, bool) {
+	edge, ok := decodeTransfer(pht)
+	if !ok {
+		return 0, transferEdge{}, false
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.cycleLength(pht.TxHash, edge), edge, true
+}
+
+// RecordTransfers adds the token transfers of a block of PHTs to the transfer
+// graph wash trading is detected against
+func (m *MEVDetector) RecordTransfers(phts []*PHTTransaction) {
+	for _, pht := range phts {
+		m.transfers.record(pht)
+	}
+}
+
+// isWashTradingPattern checks whether a PHT's token transfer closes a circular
+// flow through the transfers of recorded blocks
+func (m *MEVDetector) isWashTradingPattern(actx *AnalysisContext, pht *PHTTransaction) (bool, string) {
+	length, edge, ok := m.transfers.closes(pht)
+	if !ok || length == 0 {
+		return false, ""
+	}
+	return true, "circular flow of " + strconv.Itoa(length) + " transfers of token " + edge.token.Hex() + " back to " + edge.from.Hex()
+}
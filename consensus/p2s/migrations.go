@@ -0,0 +1,179 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SchemaVersion is the newest on-disk schema version this binary supports
+const SchemaVersion uint64 = 2
+
+// schemaVersionKey stores the schema version of a P2S database
+var schemaVersionKey = []byte("p2s-schema-version")
+
+// ErrDatabaseTooNew is returned when a database was written by a newer binary
+var ErrDatabaseTooNew = errors.New("p2s database schema is newer than supported")
+
+// Migration upgrades a database by one schema version
+type Migration struct {
+	Version     uint64 // Schema version after the migration is applied
+	Description string
+	Apply       func(db ethdb.KeyValueStore) error
+}
+
+// schemaMigrations lists all forward migrations, one per schema version
+var schemaMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "record schema version",
+		Apply:       func(db ethdb.KeyValueStore) error { return nil },
+	},
+	{
+		// Earlier databases hold no validator set; the version keeps binaries
+		// unaware of it from opening a database whose validator set they would ignore
+		Version:     2,
+		Description: "persist validator set",
+		Apply:       func(db ethdb.KeyValueStore) error { return nil },
+	},
+}
+
+// MigrationConfig controls how migrations are run
+type MigrationConfig struct {
+	DryRun bool                                            // Report pending migrations without applying them
+	Backup func(db ethdb.KeyValueStore, from uint64) error // Called once before the first migration is applied
+}
+
+// Migrator applies schema migrations to a P2S database
+type Migrator struct {
+	migrations []Migration
+	target     uint64
+	config     *MigrationConfig
+}
+
+// NewMigrator creates a migrator for the given migrations
+func NewMigrator(migrations []Migration, config *MigrationConfig) *Migrator {
+	if config == nil {
+		config = &MigrationConfig{}
+	}
+
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	target := uint64(0)
+	if len(sorted) > 0 {
+		target = sorted[len(sorted)-1].Version
+	}
+
+	return &Migrator{
+		migrations: sorted,
+		target:     target,
+		config:     config,
+	}
+}
+
+// Plan returns the migrations needed to bring a database up to date
+func (m *Migrator) Plan(db ethdb.KeyValueReader) ([]Migration, error) {
+	current, err := ReadSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > m.target {
+		return nil, fmt.Errorf("%w: database version %d, supported %d", ErrDatabaseTooNew, current, m.target)
+	}
+
+	pending := []Migration{}
+	for _, migration := range m.migrations {
+		if migration.Version > current {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Run applies all pending migrations, returning the resulting schema version
+func (m *Migrator) Run(db ethdb.KeyValueStore) (uint64, error) {
+	current, err := ReadSchemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending, err := m.Plan(db)
+	if err != nil {
+		return current, err
+	}
+	if len(pending) == 0 {
+		return current, nil
+	}
+
+	if m.config.DryRun {
+		for _, migration := range pending {
+			log.Info("Pending P2S schema migration", "version", migration.Version, "description", migration.Description)
+		}
+		return current, nil
+	}
+
+	if m.config.Backup != nil {
+		if err := m.config.Backup(db, current); err != nil {
+			return current, fmt.Errorf("backup before migration failed: %v", err)
+		}
+	}
+
+	for _, migration := range pending {
+		if migration.Version != current+1 {
+			return current, fmt.Errorf("missing migration to schema version %d", current+1)
+		}
+
+		log.Info("Applying P2S schema migration", "version", migration.Version, "description", migration.Description)
+		if err := migration.Apply(db); err != nil {
+			return current, fmt.Errorf("migration to schema version %d failed: %v", migration.Version, err)
+		}
+		if err := WriteSchemaVersion(db, migration.Version); err != nil {
+			return current, err
+		}
+		current = migration.Version
+	}
+
+	return current, nil
+}
+
+// ReadSchemaVersion reads the schema version of a database, 0 if unversioned
+func ReadSchemaVersion(db ethdb.KeyValueReader) (uint64, error) {
+	has, err := db.Has(schemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if !has {
+		return 0, nil
+	}
+
+	data, err := db.Get(schemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid schema version encoding of length %d", len(data))
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// WriteSchemaVersion writes the schema version of a database
+func WriteSchemaVersion(db ethdb.KeyValueWriter, version uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, version)
+	return db.Put(schemaVersionKey, data)
+}
+
+// MigrateDatabase brings a database up to the schema version supported by this binary
+func MigrateDatabase(db ethdb.KeyValueStore, config *MigrationConfig) (uint64, error) {
+	return NewMigrator(schemaMigrations, config).Run(db)
+}
@@ -0,0 +1,111 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	nativemimc "github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// mimcMaxItems is the number of data items a MiMC commitment takes, the
+	// capacity of the SNARK opening circuit. It covers every selectable hidden
+	// field plus the blob, access list and account nonce encodings with room to
+	// spare.
+	mimcMaxItems = 16
+
+	// mimcElementLength is the length of an encoded BN254 scalar field element
+	mimcElementLength = fr.Bytes
+)
+
+// ErrMiMCCapacity is returned for more data items than a MiMC commitment takes
+var ErrMiMCCapacity = errors.New("too many items for MiMC commitment")
+
+// MiMCCommitment commits to data with the MiMC hash over the BN254 scalar field
+// of a random blinding element, the item count and the Keccak256 hash of each
+// item. It hides the data through the blinding element and binds it through the
+// collision resistance of MiMC, and opening it is cheap inside a SNARK circuit,
+// which is what the groth16 proof system proves.
+type MiMCCommitment struct{}
+
+// NewMiMCCommitment creates a new MiMC commitment scheme
+func NewMiMCCommitment() *MiMCCommitment {
+	return &MiMCCommitment{}
+}
+
+// Commit commits to data under a fresh blinding element
+func (m *MiMCCommitment) Commit(data ...[]byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("no data to commit")
+	}
+	items, err := mimcItems(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var blinding fr.Element
+	if _, err := blinding.SetRandom(); err != nil {
+		return nil, nil, err
+	}
+	encoded := blinding.Bytes()
+	return mimcCommit(&blinding, items), encoded[:], nil
+}
+
+// Verify checks that a commitment opens to data with the given blinding element
+func (m *MiMCCommitment) Verify(commitment []byte, blinding []byte, data ...[]byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	element, ok := mimcElement(blinding)
+	if !ok {
+		return false
+	}
+	items, err := mimcItems(data)
+	if err != nil {
+		return false
+	}
+	return constantTimeEqual(commitment, mimcCommit(element, items))
+}
+
+// mimcItems maps data to the committed field elements: the item count followed
+// by the Keccak256 hash of each item reduced into the field, zero padded to the
+// capacity
+func mimcItems(data [][]byte) ([]fr.Element, error) {
+	if len(data) > mimcMaxItems {
+		return nil, fmt.Errorf("%w: %d", ErrMiMCCapacity, len(data))
+	}
+	items := make([]fr.Element, mimcMaxItems+1)
+	items[0].SetUint64(uint64(len(data)))
+	for i, d := range data {
+		items[i+1].SetBytes(crypto.Keccak256(d))
+	}
+	return items, nil
+}
+
+// mimcCommit hashes the blinding element and items the way the SNARK opening
+// circuit does
+func mimcCommit(blinding *fr.Element, items []fr.Element) []byte {
+	hasher := nativemimc.NewMiMC()
+	encoded := blinding.Bytes()
+	hasher.Write(encoded[:])
+	for i := range items {
+		encoded := items[i].Bytes()
+		hasher.Write(encoded[:])
+	}
+	return hasher.Sum(nil)
+}
+
+// mimcElement decodes a canonically encoded field element
+func mimcElement(encoded []byte) (*fr.Element, bool) {
+	if len(encoded) != mimcElementLength {
+		return nil, false
+	}
+	value := new(big.Int).SetBytes(encoded)
+	if value.Cmp(fr.Modulus()) >= 0 {
+		return nil, false
+	}
+	return new(fr.Element).SetBigInt(value), true
+}
@@ -0,0 +1,86 @@
+package p2s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidBatchProof is returned when an aggregated B2 proof does not cover its MTs
+var ErrInvalidBatchProof = errors.New("invalid batch proof")
+
+// batchStatement reduces the PHT→MT matches of a block to a single statement for
+// the proof system: a commitment over every PHT commitment and a digest over each
+// pair's PHT hash, commitment and committed field encodings
+func batchStatement(phts []*PHTTransaction, mts []*MTTransaction) ([]byte, [][]byte, error) {
+	if len(mts) == 0 {
+		return nil, nil, errors.New("no MTs to prove")
+	}
+	if len(mts) != len(phts) {
+		return nil, nil, fmt.Errorf("MT count %d does not match PHT count %d", len(mts), len(phts))
+	}
+
+	commitments := make([][]byte, 0, len(phts))
+	pairLeaves := make([][]byte, 0, len(mts))
+	for i, mt := range mts {
+		pht := phts[i]
+		commitments = append(commitments, pht.Commitment)
+		if mt.Unrevealed {
+			pairLeaves = append(pairLeaves, crypto.Keccak256(mt.PHTHash.Bytes(), []byte("unrevealed")))
+			continue
+		}
+		if err := checkRevealLayout(mt, pht); err != nil {
+			return nil, nil, fmt.Errorf("MT %d: %w", i, withMTIndex(err, i))
+		}
+		hiddenData, err := mt.commitmentData()
+		if err != nil {
+			return nil, nil, fmt.Errorf("MT %d: %w", i, err)
+		}
+
+		parts := make([][]byte, 0, len(hiddenData)+2)
+		parts = append(parts, mt.PHTHash.Bytes(), crypto.Keccak256(pht.Commitment))
+		for _, item := range hiddenData {
+			parts = append(parts, crypto.Keccak256(item))
+		}
+		pairLeaves = append(pairLeaves, crypto.Keccak256(parts...))
+	}
+
+	commitment := crypto.Keccak256(commitments...)
+	return commitment, proofLeaves(commitment, [][]byte{crypto.Keccak256(pairLeaves...)}), nil
+}
+
+// ProveBatch creates one proof with the configured proof system covering every
+// PHT→MT match of a B2 block, replacing the per-MT inclusion proofs. It does not
+// replace the commitment openings, which validators still check per MT. PHTs and
+// MTs are matched by position. Proof systems proving commitment openings cannot
+// aggregate, so their blocks keep the per-MT proofs and no batch proof is made.
+func (m *MTManager) ProveBatch(phts []*PHTTransaction, mts []*MTTransaction) ([]byte, error) {
+	commitment, data, err := batchStatement(phts, mts)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := m.proofSystem.Prove(commitment, data...)
+	if errors.Is(err, ErrOpeningRequired) {
+		return nil, nil
+	}
+	return proof, err
+}
+
+// VerifyBatch verifies the MTs of a B2 block against their PHTs with a single
+// aggregated proof from ProveBatch in place of the per-MT inclusion proofs, which
+// are not checked. Verification stays linear in the number of MTs: revealed
+// fields are still checked against each PHT and its commitment opening, and
+// unrevealed placeholders against their PHT.
+func (m *MTManager) VerifyBatch(proof []byte, phts []*PHTTransaction, mts []*MTTransaction) error {
+	commitment, data, err := batchStatement(phts, mts)
+	if err != nil {
+		return err
+	}
+	if !m.proofSystem.Verify(proof, commitment, data...) {
+		return ErrInvalidBatchProof
+	}
+
+	return m.verifyParallel(context.Background(), phts, mts, m.verifyMatch)
+}
@@ -0,0 +1,115 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// MTCompression selects the codec compressing MT batches for network transfer
+type MTCompression uint8
+
+// MT batch codecs. Compressed batches start with the codec byte, followed by the
+// compressed B2Body encoding of the MTs.
+const (
+	MTCompressionNone   MTCompression = iota // Uncompressed
+	MTCompressionSnappy                      // Fast, for block propagation
+	MTCompressionZstd                        // Smaller, for sync and archival transfer
+)
+
+// maxMTBatchSize bounds the decompressed size of an MT batch, well above the
+// block size limit, so a small payload cannot expand without bound
+const maxMTBatchSize = 16 * 1024 * 1024
+
+var (
+	// ErrUnknownMTCompression is returned for a batch compressed with an unknown codec
+	ErrUnknownMTCompression = errors.New("unknown MT compression")
+
+	// ErrMTBatchTooLarge is returned for a batch decompressing beyond maxMTBatchSize
+	ErrMTBatchTooLarge = errors.New("MT batch too large")
+)
+
+// Zstd coders are safe for concurrent EncodeAll and DecodeAll calls
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxMTBatchSize))
+)
+
+// String returns the codec name
+func (c MTCompression) String() string {
+	switch c {
+	case MTCompressionNone:
+		return "none"
+	case MTCompressionSnappy:
+		return "snappy"
+	case MTCompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(c))
+	}
+}
+
+// CompressMTs encodes a batch of MTs as a B2 body and compresses it with the codec
+func CompressMTs(mts []*MTTransaction, codec MTCompression) ([]byte, error) {
+	encoded, err := (&B2Body{MTs: mts}).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	switch codec {
+	case MTCompressionNone:
+		return append([]byte{byte(codec)}, encoded...), nil
+	case MTCompressionSnappy:
+		return append([]byte{byte(codec)}, snappy.Encode(nil, encoded)...), nil
+	case MTCompressionZstd:
+		return zstdEncoder.EncodeAll(encoded, []byte{byte(codec)}), nil
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownMTCompression, codec)
+	}
+}
+
+// DecompressMTs decodes a batch of MTs compressed by CompressMTs
+func DecompressMTs(data []byte) ([]*MTTransaction, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty MT batch")
+	}
+	codec, payload := MTCompression(data[0]), data[1:]
+
+	var encoded []byte
+	switch codec {
+	case MTCompressionNone:
+		encoded = payload
+	case MTCompressionSnappy:
+		size, err := snappy.DecodedLen(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size > maxMTBatchSize {
+			return nil, fmt.Errorf("%w: %d bytes", ErrMTBatchTooLarge, size)
+		}
+		if encoded, err = snappy.Decode(nil, payload); err != nil {
+			return nil, err
+		}
+	case MTCompressionZstd:
+		var err error
+		if encoded, err = zstdDecoder.DecodeAll(payload, nil); err != nil {
+			if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+				return nil, fmt.Errorf("%w: %v", ErrMTBatchTooLarge, err)
+			}
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnknownMTCompression, codec)
+	}
+	if len(encoded) > maxMTBatchSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMTBatchTooLarge, len(encoded))
+	}
+
+	var body B2Body
+	if err := body.UnmarshalBinary(encoded); err != nil {
+		return nil, err
+	}
+	return body.MTs, nil
+}
@@ -0,0 +1,253 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrInvalidProof is returned when an MT's proof does not show it matches its PHT
+var ErrInvalidProof = errors.New("invalid proof")
+
+// ErrValueCommitmentOpening is returned when a revealed value does not open its PHT's value commitment
+var ErrValueCommitmentOpening = errors.New("value does not open the value commitment")
+
+// The errors below report which field of an MT diverges from its PHT, so fraud
+// proofs and slashing can act on the field rather than an error string. Index
+// is the position of the MT in its B2 block when verified as part of one, zero
+// otherwise. Expected holds the PHT's value and Got the MT's.
+
+// mtIndexedError is implemented by MT errors recording the MT's block position
+type mtIndexedError interface {
+	setIndex(index int)
+}
+
+// withMTIndex records the block position of the MT an error is about
+func withMTIndex(err error, index int) error {
+	var indexed mtIndexedError
+	if errors.As(err, &indexed) {
+		indexed.setIndex(index)
+	}
+	return err
+}
+
+// ErrHiddenSetMismatch reports an MT revealing another hidden field set than its PHT committed to
+type ErrHiddenSetMismatch struct {
+	Index         int
+	Expected, Got HiddenFieldSet
+}
+
+func (e *ErrHiddenSetMismatch) Error() string {
+	return fmt.Sprintf("hidden field set mismatch: have %#x, want %#x", uint16(e.Got), uint16(e.Expected))
+}
+
+func (e *ErrHiddenSetMismatch) setIndex(index int) { e.Index = index }
+
+// ErrFieldEncodingMismatch reports an MT encoded differently than its PHT commitment
+type ErrFieldEncodingMismatch struct {
+	Index         int
+	Expected, Got FieldEncoding
+}
+
+func (e *ErrFieldEncodingMismatch) Error() string {
+	return fmt.Sprintf("field encoding mismatch: have %d, want %d", e.Got, e.Expected)
+}
+
+func (e *ErrFieldEncodingMismatch) setIndex(index int) { e.Index = index }
+
+// ErrPHTHashMismatch reports an MT referencing another PHT
+type ErrPHTHashMismatch struct {
+	Index         int
+	Expected, Got common.Hash
+}
+
+func (e *ErrPHTHashMismatch) Error() string {
+	return fmt.Sprintf("PHT hash mismatch: have %s, want %s", e.Got.Hex(), e.Expected.Hex())
+}
+
+func (e *ErrPHTHashMismatch) setIndex(index int) { e.Index = index }
+
+// ErrRecipientMismatch reports a diverging recipient, nil for contract creations
+type ErrRecipientMismatch struct {
+	Index         int
+	Expected, Got *common.Address
+}
+
+func (e *ErrRecipientMismatch) Error() string {
+	return fmt.Sprintf("recipient mismatch: have %s, want %s", recipientString(e.Got), recipientString(e.Expected))
+}
+
+func (e *ErrRecipientMismatch) setIndex(index int) { e.Index = index }
+
+// recipientString formats a recipient, nil for contract creations
+func recipientString(to *common.Address) string {
+	if to == nil {
+		return "contract creation"
+	}
+	return to.Hex()
+}
+
+// ErrValueMismatch reports a diverging value
+type ErrValueMismatch struct {
+	Index         int
+	Expected, Got *big.Int
+}
+
+func (e *ErrValueMismatch) Error() string {
+	return fmt.Sprintf("value mismatch: have %v, want %v", e.Got, e.Expected)
+}
+
+func (e *ErrValueMismatch) setIndex(index int) { e.Index = index }
+
+// ErrCallDataMismatch reports diverging call data
+type ErrCallDataMismatch struct {
+	Index         int
+	Expected, Got []byte
+}
+
+func (e *ErrCallDataMismatch) Error() string {
+	return fmt.Sprintf("call data mismatch: have %d bytes, want %d bytes", len(e.Got), len(e.Expected))
+}
+
+func (e *ErrCallDataMismatch) setIndex(index int) { e.Index = index }
+
+// ErrTxTypeMismatch reports a diverging transaction type
+type ErrTxTypeMismatch struct {
+	Index         int
+	Expected, Got uint8
+}
+
+func (e *ErrTxTypeMismatch) Error() string {
+	return fmt.Sprintf("transaction type mismatch: have %d, want %d", e.Got, e.Expected)
+}
+
+func (e *ErrTxTypeMismatch) setIndex(index int) { e.Index = index }
+
+// ErrGasLimitMismatch reports a diverging gas limit
+type ErrGasLimitMismatch struct {
+	Index         int
+	Expected, Got uint64
+}
+
+func (e *ErrGasLimitMismatch) Error() string {
+	return fmt.Sprintf("gas limit mismatch: have %d, want %d", e.Got, e.Expected)
+}
+
+func (e *ErrGasLimitMismatch) setIndex(index int) { e.Index = index }
+
+// ErrAccountNonceMismatch reports a diverging account nonce
+type ErrAccountNonceMismatch struct {
+	Index         int
+	Expected, Got uint64
+}
+
+func (e *ErrAccountNonceMismatch) Error() string {
+	return fmt.Sprintf("account nonce mismatch: have %d, want %d", e.Got, e.Expected)
+}
+
+func (e *ErrAccountNonceMismatch) setIndex(index int) { e.Index = index }
+
+// ErrAccessListMismatch reports a diverging access list
+type ErrAccessListMismatch struct {
+	Index         int
+	Expected, Got types.AccessList
+}
+
+func (e *ErrAccessListMismatch) Error() string {
+	return fmt.Sprintf("access list mismatch: have %d entries, want %d entries", len(e.Got), len(e.Expected))
+}
+
+func (e *ErrAccessListMismatch) setIndex(index int) { e.Index = index }
+
+// ErrBlobHashesMismatch reports diverging blob versioned hashes
+type ErrBlobHashesMismatch struct {
+	Index         int
+	Expected, Got []common.Hash
+}
+
+func (e *ErrBlobHashesMismatch) Error() string {
+	return fmt.Sprintf("blob hash mismatch: have %d hashes, want %d hashes", len(e.Got), len(e.Expected))
+}
+
+func (e *ErrBlobHashesMismatch) setIndex(index int) { e.Index = index }
+
+// ErrBlobFeeCapMismatch reports a diverging blob fee cap
+type ErrBlobFeeCapMismatch struct {
+	Index         int
+	Expected, Got *big.Int
+}
+
+func (e *ErrBlobFeeCapMismatch) Error() string {
+	return fmt.Sprintf("blob fee cap mismatch: have %v, want %v", e.Got, e.Expected)
+}
+
+func (e *ErrBlobFeeCapMismatch) setIndex(index int) { e.Index = index }
+
+// ErrSenderMismatch reports a diverging sender on networks hiding it
+type ErrSenderMismatch struct {
+	Index         int
+	Expected, Got common.Address
+}
+
+func (e *ErrSenderMismatch) Error() string {
+	return fmt.Sprintf("sender mismatch: have %s, want %s", e.Got.Hex(), e.Expected.Hex())
+}
+
+func (e *ErrSenderMismatch) setIndex(index int) { e.Index = index }
+
+// ErrGasPriceMismatch reports a diverging gas price or fee cap on networks hiding
+// them. Field names the diverging value: "gasPrice", "maxFeePerGas" or
+// "maxPriorityFeePerGas".
+type ErrGasPriceMismatch struct {
+	Index         int
+	Field         string
+	Expected, Got *big.Int
+}
+
+func (e *ErrGasPriceMismatch) Error() string {
+	return fmt.Sprintf("gas price mismatch: %s have %v, want %v", e.Field, e.Got, e.Expected)
+}
+
+func (e *ErrGasPriceMismatch) setIndex(index int) { e.Index = index }
+
+// ErrFieldOpeningMismatch reports a vector commitment opening of a hidden field,
+// by field index, that differs from the field's revealed value
+type ErrFieldOpeningMismatch struct {
+	Index         int
+	Field         int
+	Expected, Got []byte // Opened and revealed encodings
+}
+
+func (e *ErrFieldOpeningMismatch) Error() string {
+	return fmt.Sprintf("opened %s does not match revealed value", HiddenFieldName(e.Field))
+}
+
+func (e *ErrFieldOpeningMismatch) setIndex(index int) { e.Index = index }
+
+// ErrMissingField reports a required MT field left empty
+type ErrMissingField struct {
+	Index int
+	Field string
+}
+
+func (e *ErrMissingField) Error() string {
+	return "missing " + e.Field
+}
+
+func (e *ErrMissingField) setIndex(index int) { e.Index = index }
+
+// ErrInvalidField reports an MT field holding a value no transaction can have
+type ErrInvalidField struct {
+	Index  int
+	Field  string
+	Reason string
+}
+
+func (e *ErrInvalidField) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+func (e *ErrInvalidField) setIndex(index int) { e.Index = index }
@@ -2,18 +2,25 @@ package p2s
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // MTManager manages Matching Transactions
 type MTManager struct {
 	commitmentScheme CommitmentScheme
+	vectorCommitment *VectorCommitment
 	proofSystem      ProofSystem
+	proofs           *proofCache
 	config          *P2SConfig
 }
 
@@ -25,22 +32,95 @@ type MTTransaction struct {
 	CallData  []byte        `json:"callData"`
 	TxType    uint8         `json:"txType"`
 	GasLimit  uint64        `json:"gasLimit"`
+	IsContractCreation bool `json:"isContractCreation,omitempty"` // Nil To, Recipient is zero
+	AccountNonce       uint64 `json:"accountNonce"`
 	
 	// Proof fields
 	PHTHash   common.Hash `json:"phtHash"`
 	Proof     []byte      `json:"proof"`
 	Timestamp uint64      `json:"timestamp"`
 	
+	// Blinding factor opening the PHT Pedersen commitment
+	Blinding []byte `json:"blinding"`
+	
+	// Revealed EIP-2930 access list, empty for legacy transactions
+	AccessList types.AccessList `json:"accessList,omitempty"`
+	
+	// Revealed EIP-4844 blob fields, empty for other transaction types
+	BlobHashes       []common.Hash `json:"blobHashes,omitempty"`
+	MaxFeePerBlobGas *big.Int      `json:"maxFeePerBlobGas,omitempty"`
+	
+	// Fields of the PHT's hidden field set beyond the defaults, revealed only when
+	// the set hides them
+	HiddenSet            HiddenFieldSet `json:"hiddenSet,omitempty"`
+	FieldEncoding        FieldEncoding  `json:"fieldEncoding,omitempty"` // Encoding of the PHT commitment
+	Sender               common.Address `json:"sender"`
+	GasPrice             *big.Int       `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *big.Int       `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *big.Int       `json:"maxPriorityFeePerGas,omitempty"`
+	
+	// Blinding factor opening the PHT value commitment, nil without a value cap
+	ValueBlinding []byte `json:"valueBlinding,omitempty"`
+	
+	// Per-field openings of the PHT vector commitment
+	FieldOpenings []*FieldOpening `json:"fieldOpenings,omitempty"`
+	
+	// Canonical encoding of the transaction as signed by the sender, hashing to
+	// TxHash. Nil for MTs of PHTs created before signed payloads were carried.
+	SignedTx []byte `json:"signedTx,omitempty"`
+	
+	// Signature of the PHT sender over the PHT hash and revealed fields. Nil for
+	// MTs of PHTs created without the sender key.
+	RevealSignature []byte `json:"revealSignature,omitempty"`
+	
+	// Set on placeholders for PHTs that could not be revealed, which carry only
+	// the PHT and transaction hashes
+	Unrevealed bool `json:"unrevealed,omitempty"`
+	
 	// Transaction hash
 	TxHash common.Hash `json:"txHash"`
 }
 
+// Partial reveal errors
+var (
+	// ErrUnrevealed is returned when verifying an unrevealed placeholder as an MT
+	ErrUnrevealed = errors.New("MT is an unrevealed placeholder")
+
+	// ErrUnexpectedPlaceholder is returned for placeholders in B2 blocks of
+	// networks not in partial reveal mode
+	ErrUnexpectedPlaceholder = errors.New("unrevealed placeholder outside partial reveal mode")
+)
+
 // ProofSystem interface for cryptographic proofs
 type ProofSystem interface {
 	Prove(commitment []byte, data ...[]byte) ([]byte, error)
 	Verify(proof []byte, commitment []byte, data ...[]byte) bool
 }
 
+// Built-in proof systems selectable via P2SConfig.ProofSystem; others can be
+// added with RegisterProofSystem
+const (
+	ProofSystemMerkle  = "merkle"
+	ProofSystemSMT     = "smt"
+	ProofSystemVerkle  = "verkle"
+	ProofSystemGroth16 = "groth16"
+)
+
+// Merkle proofs encode the proven leaf index and a bitmap of sibling directions,
+// each as 4 big-endian bytes, followed by the 32-byte sibling hashes from the leaf
+// level up. Bit i of the bitmap is set when the sibling at level i is the left
+// child, which must agree with bit i of the leaf index.
+const (
+	merkleProofHeaderSize = 8
+	merkleNodeSize        = sha256.Size
+)
+
+// Domain separation prefixes keep leaves from being passed off as internal nodes
+var (
+	merkleLeafPrefix = []byte{0x00}
+	merkleNodePrefix = []byte{0x01}
+)
+
 // MerkleProofSystem implements Merkle tree-based proofs
 type MerkleProofSystem struct {
 	treeHeight int
@@ -53,30 +133,29 @@ func NewMerkleProofSystem() *MerkleProofSystem {
 	}
 }
 
-// Prove creates a proof for the given commitment and data
+// Prove creates a proof that the commitment is one of the data leaves
 func (m *MerkleProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
 	if len(data) == 0 {
 		return nil, errors.New("no data to prove")
 	}
+	if uint64(len(data)) > uint64(1)<<m.treeHeight {
+		return nil, fmt.Errorf("too many leaves: %d", len(data))
+	}
 	
-	// Create Merkle tree from data
-	tree := m.buildMerkleTree(data)
-	
-	// Find the commitment in the tree
-	leafIndex := m.findLeafIndex(tree, commitment)
+	// Find the commitment among the leaves
+	leafIndex := m.findLeafIndex(data, commitment)
 	if leafIndex == -1 {
 		return nil, errors.New("commitment not found in tree")
 	}
 	
-	// Generate Merkle proof
-	proof := m.generateMerkleProof(tree, leafIndex)
-	
-	return proof, nil
+	// Create Merkle tree from data and generate the proof
+	tree := m.buildMerkleTree(data)
+	return m.generateMerkleProof(tree, leafIndex), nil
 }
 
-// Verify verifies a proof against commitment and data
+// Verify verifies a proof that the commitment is one of the data leaves
 func (m *MerkleProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
-	if len(data) == 0 {
+	if len(data) == 0 || uint64(len(data)) > uint64(1)<<m.treeHeight {
 		return false
 	}
 	
@@ -84,46 +163,37 @@ func (m *MerkleProofSystem) Verify(proof []byte, commitment []byte, data ...[]by
 	tree := m.buildMerkleTree(data)
 	
 	// Verify the proof
-	return m.verifyMerkleProof(proof, commitment, tree)
+	return m.verifyMerkleProof(proof, commitment, data, tree)
 }
 
-// buildMerkleTree builds a Merkle tree from data
-func (m *MerkleProofSystem) buildMerkleTree(data [][]byte) [][]byte {
-	if len(data) == 0 {
-		return nil
-	}
-	
-	// Pad data to power of 2
-	paddedData := m.padToPowerOfTwo(data)
-	
-	// Build tree bottom-up
-	tree := make([][]byte, len(paddedData)*2-1)
-	
-	// Copy leaves
-	for i, d := range paddedData {
-		tree[i] = d
-	}
-	
-	// Build internal nodes
-	for i := len(paddedData); i < len(tree); i++ {
-		leftChild := tree[2*i-len(paddedData)]
-		rightChild := tree[2*i-len(paddedData)+1]
-		
-		// Hash children
-		hasher := sha256.New()
-		hasher.Write(leftChild)
-		hasher.Write(rightChild)
-		tree[i] = hasher.Sum(nil)
+// buildMerkleTree builds a Merkle tree from data, returning its levels from the
+// leaf hashes up to the root
+func (m *MerkleProofSystem) buildMerkleTree(data [][]byte) [][][]byte {
+	// Hash leaves and pad to power of 2
+	leaves := make([][]byte, len(data))
+	for i, d := range data {
+		leaves[i] = merkleHash(merkleLeafPrefix, d)
+	}
+	tree := [][][]byte{m.padToPowerOfTwo(leaves)}
+	
+	// Build internal levels bottom-up
+	for level := tree[0]; len(level) > 1; {
+		parents := make([][]byte, len(level)/2)
+		for i := range parents {
+			parents[i] = merkleHash(merkleNodePrefix, level[2*i], level[2*i+1])
+		}
+		tree = append(tree, parents)
+		level = parents
 	}
 	
 	return tree
 }
 
-// padToPowerOfTwo pads data to the next power of 2
-func (m *MerkleProofSystem) padToPowerOfTwo(data [][]byte) [][]byte {
-	n := len(data)
+// padToPowerOfTwo pads leaf hashes to the next power of 2
+func (m *MerkleProofSystem) padToPowerOfTwo(leaves [][]byte) [][]byte {
+	n := len(leaves)
 	if n == 0 {
-		return data
+		return leaves
 	}
 	
 	// Find next power of 2
@@ -132,78 +202,124 @@ func (m *MerkleProofSystem) padToPowerOfTwo(data [][]byte) [][]byte {
 		nextPower <<= 1
 	}
 	
-	// Pad with empty bytes
+	// Pad with empty hashes
 	padded := make([][]byte, nextPower)
-	copy(padded, data)
+	copy(padded, leaves)
 	
 	for i := n; i < nextPower; i++ {
-		padded[i] = make([]byte, 32) // Empty hash
+		padded[i] = make([]byte, merkleNodeSize) // Empty hash
 	}
 	
 	return padded
 }
 
-// findLeafIndex finds the index of a leaf in the tree
-func (m *MerkleProofSystem) findLeafIndex(tree [][]byte, commitment []byte) int {
-	for i, leaf := range tree {
-		if string(leaf) == string(commitment) {
-			return i
+// findLeafIndex finds the index of the commitment among the leaves
+func (m *MerkleProofSystem) findLeafIndex(data [][]byte, commitment []byte) int {
+	// Scan every leaf so the lookup time does not reveal the match position
+	index := -1
+	for i, leaf := range data {
+		if constantTimeEqual(leaf, commitment) && index < 0 {
+			index = i
 		}
 	}
-	return -1
+	return index
 }
 
 // generateMerkleProof generates a Merkle proof for a leaf
-func (m *MerkleProofSystem) generateMerkleProof(tree [][]byte, leafIndex int) []byte {
-	proof := make([]byte, 0)
+func (m *MerkleProofSystem) generateMerkleProof(tree [][][]byte, leafIndex int) []byte {
+	depth := len(tree) - 1
+	proof := make([]byte, merkleProofHeaderSize, merkleProofHeaderSize+depth*merkleNodeSize)
 	
+	var directions uint32
 	currentIndex := leafIndex
-	for currentIndex < len(tree)-1 {
-		// Add sibling to proof
+	for level := 0; level < depth; level++ {
+		// Add sibling to proof, noting whether it is the left child
 		siblingIndex := currentIndex ^ 1
-		proof = append(proof, tree[siblingIndex]...)
+		if siblingIndex < currentIndex {
+			directions |= 1 << level
+		}
+		proof = append(proof, tree[level][siblingIndex]...)
 		
 		// Move to parent
-		currentIndex = (currentIndex + len(tree)) / 2
+		currentIndex /= 2
 	}
 	
+	binary.BigEndian.PutUint32(proof[:4], uint32(leafIndex))
+	binary.BigEndian.PutUint32(proof[4:merkleProofHeaderSize], directions)
 	return proof
 }
 
 // verifyMerkleProof verifies a Merkle proof
-func (m *MerkleProofSystem) verifyMerkleProof(proof []byte, commitment []byte, tree [][]byte) bool {
-	if len(proof) == 0 {
+func (m *MerkleProofSystem) verifyMerkleProof(proof []byte, commitment []byte, data [][]byte, tree [][][]byte) bool {
+	depth := len(tree) - 1
+	if len(proof) != merkleProofHeaderSize+depth*merkleNodeSize {
 		return false
 	}
+	leafIndex := binary.BigEndian.Uint32(proof[:4])
+	directions := binary.BigEndian.Uint32(proof[4:merkleProofHeaderSize])
 	
-	// Reconstruct root from proof
-	current := commitment
-	proofIndex := 0
+	// The proof must be for a data leaf holding the commitment, not padding, and
+	// its directions must follow the path of that leaf
+	if uint64(leafIndex) >= uint64(len(data)) || !constantTimeEqual(data[leafIndex], commitment) {
+		return false
+	}
+	if directions != leafIndex {
+		return false
+	}
 	
-	for proofIndex < len(proof) {
-		// Get sibling from proof
-		sibling := proof[proofIndex : proofIndex+32]
-		proofIndex += 32
+	// Reconstruct root from proof
+	current := merkleHash(merkleLeafPrefix, commitment)
+	for level := 0; level < depth; level++ {
+		offset := merkleProofHeaderSize + level*merkleNodeSize
+		sibling := proof[offset : offset+merkleNodeSize]
 		
-		// Hash current and sibling
-		hasher := sha256.New()
-		hasher.Write(current)
-		hasher.Write(sibling)
-		current = hasher.Sum(nil)
+		if directions&(1<<level) != 0 {
+			current = merkleHash(merkleNodePrefix, sibling, current)
+		} else {
+			current = merkleHash(merkleNodePrefix, current, sibling)
+		}
 	}
 	
 	// Compare with root
-	root := tree[len(tree)-1]
-	return string(current) == string(root)
+	root := tree[depth][0]
+	return constantTimeEqual(current, root)
 }
 
-// NewMTManager creates a new MT manager
-func NewMTManager(config *P2SConfig) *MTManager {
+// merkleHash hashes a domain prefix and the given parts
+func merkleHash(prefix []byte, parts ...[]byte) []byte {
+	hasher := sha256.New()
+	hasher.Write(prefix)
+	for _, part := range parts {
+		hasher.Write(part)
+	}
+	return hasher.Sum(nil)
+}
+
+// proofLeaves returns the Merkle leaves binding a PHT commitment to the encoded
+// revealed fields, the commitment first
+func proofLeaves(commitment []byte, hiddenData [][]byte) [][]byte {
+	return append([][]byte{commitment}, hiddenData...)
+}
+
+// NewMTManager creates a new MT manager. It fails if the configured commitment
+// scheme cannot be created.
+func NewMTManager(config *P2SConfig) (*MTManager, error) {
+	scheme, err := NewCommitmentScheme(config)
+	if err != nil {
+		return nil, err
+	}
+	cacheLimit := defaultProofCacheLimit
+	if config != nil && config.ProofCacheSize > 0 {
+		cacheLimit = config.ProofCacheSize
+	}
+
 	return &MTManager{
-		commitmentScheme: NewPedersenCommitment(),
-		proofSystem:      NewMerkleProofSystem(),
+		commitmentScheme: scheme,
+		vectorCommitment: NewVectorCommitment(),
+		proofSystem:      newMTProofSystem(config),
+		proofs:           newProofCache(cacheLimit),
 		config:          config,
-	}
+	}, nil
 }
 
 // CreateMT creates an MT from a PHT
@@ -212,13 +328,11 @@ func (m *MTManager) CreateMT(pht *PHTTransaction) (*MTTransaction, error) {
 	recipient, value, callData, txType, gasLimit := pht.Recipient, pht.Value, pht.CallData, pht.TxType, pht.GasLimit
 	
 	// Create proof that MT matches PHT
-	proof, err := m.proofSystem.Prove(pht.Commitment, 
-		recipient.Bytes(),
-		value.Bytes(),
-		callData,
-		{txType},
-		{byte(gasLimit)},
-	)
+	hiddenData, err := hiddenCommitmentData(pht.HiddenSet, pht.FieldEncoding, pht.hiddenFields())
+	if err != nil {
+		return nil, err
+	}
+	proof, err := m.proveCached(pht.Hash(), pht.Commitment, pht.Blinding, proofLeaves(pht.Commitment, hiddenData))
 	if err != nil {
 		return nil, err
 	}
@@ -226,58 +340,300 @@ func (m *MTManager) CreateMT(pht *PHTTransaction) (*MTTransaction, error) {
 	// Create MT
 	mt := &MTTransaction{
 		Recipient:  recipient,
+		IsContractCreation: pht.IsContractCreation,
+		AccountNonce:       pht.AccountNonce,
 		Value:      value,
 		CallData:   callData,
 		TxType:     txType,
 		GasLimit:   gasLimit,
 		PHTHash:    pht.Hash(),
 		Proof:      proof,
+		Blinding:   pht.Blinding,
+		AccessList:       pht.AccessList,
+		BlobHashes:       pht.BlobHashes,
+		MaxFeePerBlobGas: pht.MaxFeePerBlobGas,
 		Timestamp:  uint64(time.Now().Unix()),
 		TxHash:     pht.TxHash, // Same as original transaction
+		HiddenSet:  pht.HiddenSet,
+		FieldEncoding: pht.FieldEncoding,
+		ValueBlinding: pht.ValueBlinding,
+		SignedTx:      pht.SignedTx,
+		RevealSignature: pht.RevealSignature,
+	}
+	if pht.HiddenSet.Has(FieldSender) {
+		mt.Sender = pht.Sender
+	}
+	if pht.HiddenSet.Has(FieldGasPrice) {
+		mt.GasPrice, mt.MaxFeePerGas, mt.MaxPriorityFeePerGas = pht.GasPrice, pht.MaxFeePerGas, pht.MaxPriorityFeePerGas
 	}
 	
 	return mt, nil
 }
 
+// commitmentData returns the committed encoding of an MT's revealed fields
+func (mt *MTTransaction) commitmentData() ([][]byte, error) {
+	return hiddenCommitmentData(mt.HiddenSet, mt.FieldEncoding, &HiddenFields{
+		Recipient:        mt.Recipient,
+		IsContractCreation: mt.IsContractCreation,
+		AccountNonce:       mt.AccountNonce,
+		Value:            mt.Value,
+		CallData:         mt.CallData,
+		TxType:           mt.TxType,
+		GasLimit:         mt.GasLimit,
+		AccessList:       mt.AccessList,
+		BlobHashes:       mt.BlobHashes,
+		MaxFeePerBlobGas: mt.MaxFeePerBlobGas,
+		Sender:               mt.Sender,
+		GasPrice:             mt.GasPrice,
+		MaxFeePerGas:         mt.MaxFeePerGas,
+		MaxPriorityFeePerGas: mt.MaxPriorityFeePerGas,
+	})
+}
+
+// VerifyOpening verifies that the revealed fields and blinding factor of an MT
+// open the Pedersen commitment of its PHT
+func (m *MTManager) VerifyOpening(mt *MTTransaction, pht *PHTTransaction) error {
+	hiddenData, err := mt.commitmentData()
+	if err != nil {
+		return err
+	}
+	if len(mt.Blinding) == 0 {
+		return ErrMissingOpening
+	}
+	if !m.commitmentScheme.Verify(pht.Commitment, mt.Blinding, hiddenData...) {
+		return ErrInvalidOpening
+	}
+	return nil
+}
+
+// AttachOpening transmits the sender's opening of a PHT commitment in its MT,
+// for PHTs whose randomness was not kept in the PHT itself
+func (m *MTManager) AttachOpening(mt *MTTransaction, pht *PHTTransaction, opening *CommitmentOpening) error {
+	if opening == nil {
+		return ErrMissingOpening
+	}
+	if !constantTimeEqual(opening.Commitment, pht.Commitment) {
+		return fmt.Errorf("%w: opening is for another commitment", ErrInvalidOpening)
+	}
+	mt.Blinding = common.CopyBytes(opening.Blinding)
+	return m.VerifyOpening(mt, pht)
+}
+
+// NewUnrevealedMT creates the placeholder standing in for the MT of a PHT that
+// could not be revealed in its B2 block
+func NewUnrevealedMT(pht *PHTTransaction) *MTTransaction {
+	return &MTTransaction{
+		Value:      new(big.Int),
+		PHTHash:    pht.Hash(),
+		Timestamp:  uint64(time.Now().Unix()),
+		Unrevealed: true,
+		TxHash:     pht.TxHash,
+	}
+}
+
+// VerifyPlaceholder verifies an unrevealed placeholder against its PHT
+func (m *MTManager) VerifyPlaceholder(mt *MTTransaction, pht *PHTTransaction) error {
+	if !mt.Unrevealed {
+		return errors.New("MT is not a placeholder")
+	}
+	if mt.PHTHash != pht.Hash() || mt.TxHash != pht.TxHash {
+		return errors.New("placeholder does not match its PHT")
+	}
+	if len(mt.Proof) > 0 || len(mt.CallData) > 0 || len(mt.Blinding) > 0 || len(mt.FieldOpenings) > 0 || len(mt.SignedTx) > 0 || len(mt.RevealSignature) > 0 || bigOrZero(mt.Value).Sign() != 0 {
+		return errors.New("placeholder reveals fields")
+	}
+	return nil
+}
+
 // VerifyMT verifies an MT against its corresponding PHT
 func (m *MTManager) VerifyMT(mt *MTTransaction, pht *PHTTransaction) error {
+	if mt.Unrevealed {
+		return ErrUnrevealed
+	}
+	if err := checkRevealLayout(mt, pht); err != nil {
+		return err
+	}
+	
+	// Diverging fields are reported as such rather than as the proof failure
+	// they would cause
+	if err := checkRevealedFields(mt, pht); err != nil {
+		return err
+	}
+	
 	// Verify proof matches commitment
-	valid := m.proofSystem.Verify(mt.Proof, pht.Commitment,
-		mt.Recipient.Bytes(),
-		mt.Value.Bytes(),
-		mt.CallData,
-		{mt.TxType},
-		{byte(mt.GasLimit)},
-	)
+	hiddenData, err := mt.commitmentData()
+	if err != nil {
+		return err
+	}
+	valid := m.verifyCached(pht.Hash(), mt.Proof, pht.Commitment, proofLeaves(pht.Commitment, hiddenData))
 	
 	if !valid {
-		return errors.New("invalid proof")
+		return ErrInvalidProof
 	}
 	
+	return m.verifyOpenings(mt, pht)
+}
+
+// checkRevealLayout checks that an MT reveals exactly the fields its PHT
+// committed to, in the same encoding
+func checkRevealLayout(mt *MTTransaction, pht *PHTTransaction) error {
+	if mt.HiddenSet.normalize() != pht.HiddenSet.normalize() {
+		return &ErrHiddenSetMismatch{Expected: pht.HiddenSet.normalize(), Got: mt.HiddenSet.normalize()}
+	}
+	if mt.FieldEncoding != pht.FieldEncoding {
+		return &ErrFieldEncodingMismatch{Expected: pht.FieldEncoding, Got: mt.FieldEncoding}
+	}
+	return nil
+}
+
+// verifyMatch verifies an MT against its corresponding PHT apart from its proof
+func (m *MTManager) verifyMatch(mt *MTTransaction, pht *PHTTransaction) error {
+	if err := checkRevealedFields(mt, pht); err != nil {
+		return err
+	}
+	return m.verifyOpenings(mt, pht)
+}
+
+// checkRevealedFields checks that the revealed fields of an MT are those of its
+// PHT, reporting the first diverging field
+func checkRevealedFields(mt *MTTransaction, pht *PHTTransaction) error {
 	// Verify PHT hash matches
-	if mt.PHTHash != pht.Hash() {
-		return errors.New("PHT hash mismatch")
+	if phtHash := pht.Hash(); mt.PHTHash != phtHash {
+		return &ErrPHTHashMismatch{Expected: phtHash, Got: mt.PHTHash}
 	}
 	
 	// Verify revealed data matches committed data
-	if mt.Recipient != pht.Recipient {
-		return errors.New("recipient mismatch")
+	if mt.Recipient != pht.Recipient || mt.IsContractCreation != pht.IsContractCreation {
+		return &ErrRecipientMismatch{Expected: pht.To(), Got: mt.To()}
 	}
 	
-	if mt.Value.Cmp(pht.Value) != 0 {
-		return errors.New("value mismatch")
+	if bigOrZero(mt.Value).Cmp(bigOrZero(pht.Value)) != 0 {
+		return &ErrValueMismatch{Expected: pht.Value, Got: mt.Value}
 	}
 	
-	if string(mt.CallData) != string(pht.CallData) {
-		return errors.New("call data mismatch")
+	if !constantTimeEqual(mt.CallData, pht.CallData) {
+		return &ErrCallDataMismatch{Expected: pht.CallData, Got: mt.CallData}
 	}
 	
 	if mt.TxType != pht.TxType {
-		return errors.New("transaction type mismatch")
+		return &ErrTxTypeMismatch{Expected: pht.TxType, Got: mt.TxType}
 	}
 	
 	if mt.GasLimit != pht.GasLimit {
-		return errors.New("gas limit mismatch")
+		return &ErrGasLimitMismatch{Expected: pht.GasLimit, Got: mt.GasLimit}
+	}
+	
+	if mt.AccountNonce != pht.AccountNonce {
+		return &ErrAccountNonceMismatch{Expected: pht.AccountNonce, Got: mt.AccountNonce}
+	}
+	
+	mtAccess, _ := rlp.EncodeToBytes(mt.AccessList)
+	phtAccess, _ := rlp.EncodeToBytes(pht.AccessList)
+	if !constantTimeEqual(mtAccess, phtAccess) {
+		return &ErrAccessListMismatch{Expected: pht.AccessList, Got: mt.AccessList}
+	}
+	
+	if len(mt.BlobHashes) != len(pht.BlobHashes) {
+		return &ErrBlobHashesMismatch{Expected: pht.BlobHashes, Got: mt.BlobHashes}
+	}
+	for i, hash := range mt.BlobHashes {
+		if hash != pht.BlobHashes[i] {
+			return &ErrBlobHashesMismatch{Expected: pht.BlobHashes, Got: mt.BlobHashes}
+		}
+	}
+	if bigOrZero(mt.MaxFeePerBlobGas).Cmp(bigOrZero(pht.MaxFeePerBlobGas)) != 0 {
+		return &ErrBlobFeeCapMismatch{Expected: pht.MaxFeePerBlobGas, Got: mt.MaxFeePerBlobGas}
+	}
+	
+	if pht.HiddenSet.Has(FieldSender) && mt.Sender != pht.Sender {
+		return &ErrSenderMismatch{Expected: pht.Sender, Got: mt.Sender}
+	}
+	if pht.HiddenSet.Has(FieldGasPrice) {
+		for _, fee := range []struct {
+			field         string
+			expected, got *big.Int
+		}{
+			{"gasPrice", pht.GasPrice, mt.GasPrice},
+			{"maxFeePerGas", pht.MaxFeePerGas, mt.MaxFeePerGas},
+			{"maxPriorityFeePerGas", pht.MaxPriorityFeePerGas, mt.MaxPriorityFeePerGas},
+		} {
+			if bigOrZero(fee.got).Cmp(bigOrZero(fee.expected)) != 0 {
+				return &ErrGasPriceMismatch{Field: fee.field, Expected: fee.expected, Got: fee.got}
+			}
+		}
+	}
+	
+	return nil
+}
+
+// verifyOpenings verifies that the revealed fields of an MT open the commitments
+// of its PHT
+func (m *MTManager) verifyOpenings(mt *MTTransaction, pht *PHTTransaction) error {
+	// Verify the revealed fields open the PHT commitment
+	if err := m.VerifyOpening(mt, pht); err != nil {
+		return err
+	}
+	
+	if len(pht.ValueCommitment) > 0 && !VerifyValueCommitment(pht.ValueCommitment, mt.Value, mt.ValueBlinding) {
+		return ErrValueCommitmentOpening
+	}
+	
+	// Verify a carried signed transaction is the one the PHT committed to
+	if len(mt.SignedTx) > 0 {
+		if err := checkSignedTx(mt, pht); err != nil {
+			return err
+		}
+	}
+	
+	// Verify the reveal is the sender's
+	if err := m.checkRevealSignature(mt, pht); err != nil {
+		return err
+	}
+	
+	// Verify any per-field openings match the revealed fields
+	if len(mt.FieldOpenings) > 0 {
+		revealed := hiddenFieldVector(mt.To(), mt.Value, mt.CallData, mt.TxType, mt.GasLimit)
+		for _, opening := range mt.FieldOpenings {
+			if err := m.VerifyFieldOpening(pht, opening); err != nil {
+				return err
+			}
+			if !constantTimeEqual(opening.Value, revealed[opening.Index]) {
+				return &ErrFieldOpeningMismatch{Field: opening.Index, Expected: opening.Value, Got: revealed[opening.Index]}
+			}
+		}
+	}
+	
+	return nil
+}
+
+// RevealFields creates openings for selected hidden fields of a PHT, allowing
+// e.g. the recipient to be revealed early while call data stays hidden until B2
+func (m *MTManager) RevealFields(pht *PHTTransaction, indices ...int) ([]*FieldOpening, error) {
+	if len(pht.FieldSalts) == 0 {
+		return nil, errors.New("PHT has no field commitment salts")
+	}
+	
+	fields := hiddenFieldVector(pht.To(), pht.Value, pht.CallData, pht.TxType, pht.GasLimit)
+	openings := make([]*FieldOpening, 0, len(indices))
+	for _, index := range indices {
+		opening, err := m.vectorCommitment.OpenField(fields, pht.FieldSalts, index)
+		if err != nil {
+			return nil, err
+		}
+		openings = append(openings, opening)
+	}
+	
+	return openings, nil
+}
+
+// VerifyFieldOpening verifies a single field opening against a PHT's field commitment
+func (m *MTManager) VerifyFieldOpening(pht *PHTTransaction, opening *FieldOpening) error {
+	if opening == nil || opening.Index < 0 || opening.Index >= numHiddenFields {
+		return errors.New("invalid field opening")
+	}
+	
+	if !m.vectorCommitment.VerifyField(pht.FieldCommitment, opening) {
+		return errors.New("invalid opening for field " + HiddenFieldName(opening.Index))
 	}
 	
 	return nil
@@ -285,34 +641,45 @@ func (m *MTManager) VerifyMT(mt *MTTransaction, pht *PHTTransaction) error {
 
 // ValidateMT validates an MT
 func (m *MTManager) ValidateMT(mt *MTTransaction) error {
+	// Placeholders only reference their PHT
+	if mt.Unrevealed {
+		if mt.PHTHash == (common.Hash{}) {
+			return &ErrMissingField{Field: "PHT hash"}
+		}
+		if mt.TxHash == (common.Hash{}) {
+			return &ErrMissingField{Field: "transaction hash"}
+		}
+		return nil
+	}
+	
 	// Validate proof
 	if len(mt.Proof) == 0 {
-		return errors.New("missing proof")
+		return &ErrMissingField{Field: "proof"}
 	}
 	
 	// Validate timestamp
 	if mt.Timestamp == 0 {
-		return errors.New("missing timestamp")
+		return &ErrMissingField{Field: "timestamp"}
 	}
 	
 	// Validate PHT hash
 	if mt.PHTHash == (common.Hash{}) {
-		return errors.New("missing PHT hash")
+		return &ErrMissingField{Field: "PHT hash"}
 	}
 	
 	// Validate transaction hash
 	if mt.TxHash == (common.Hash{}) {
-		return errors.New("missing transaction hash")
+		return &ErrMissingField{Field: "transaction hash"}
 	}
 	
 	// Validate value
 	if mt.Value.Cmp(big.NewInt(0)) < 0 {
-		return errors.New("negative value")
+		return &ErrInvalidField{Field: "value", Reason: "negative"}
 	}
 	
 	// Validate gas limit
 	if mt.GasLimit == 0 {
-		return errors.New("zero gas limit")
+		return &ErrInvalidField{Field: "gas limit", Reason: "zero"}
 	}
 	
 	return nil
@@ -334,6 +701,48 @@ func (mt *MTTransaction) Hash() common.Hash {
 	}
 	hasher.Write(gasLimitBytes)
 	
+	// The creation flag, account nonce and placeholder marker are only hashed
+	// when set so other MT hashes are unchanged
+	if mt.IsContractCreation {
+		hasher.Write([]byte("create"))
+	}
+	if mt.AccountNonce != 0 {
+		hasher.Write(encodeUint64(mt.AccountNonce))
+	}
+	if mt.Unrevealed {
+		hasher.Write([]byte("unrevealed"))
+	}
+	if len(mt.SignedTx) > 0 {
+		hasher.Write(mt.SignedTx)
+	}
+	if len(mt.RevealSignature) > 0 {
+		hasher.Write(mt.RevealSignature)
+	}
+	
+	// The access list is only hashed when present so other MT hashes are unchanged
+	if len(mt.AccessList) > 0 {
+		accessList, _ := rlp.EncodeToBytes(mt.AccessList)
+		hasher.Write(accessList)
+	}
+	
+	// Blob fields are only hashed for blob transactions so other MT hashes are unchanged
+	if mt.TxType == types.BlobTxType {
+		for _, hash := range mt.BlobHashes {
+			hasher.Write(hash.Bytes())
+		}
+		hasher.Write(common.LeftPadBytes(bigOrZero(mt.MaxFeePerBlobGas).Bytes(), 32))
+	}
+	
+	// Sender and gas price are only hashed when revealed here rather than in B1
+	if mt.HiddenSet.Has(FieldSender) {
+		hasher.Write(mt.Sender.Bytes())
+	}
+	if mt.HiddenSet.Has(FieldGasPrice) {
+		hasher.Write(common.LeftPadBytes(bigOrZero(mt.GasPrice).Bytes(), 32))
+		hasher.Write(common.LeftPadBytes(bigOrZero(mt.MaxFeePerGas).Bytes(), 32))
+		hasher.Write(common.LeftPadBytes(bigOrZero(mt.MaxPriorityFeePerGas).Bytes(), 32))
+	}
+	
 	// Add PHT hash
 	hasher.Write(mt.PHTHash.Bytes())
 	
@@ -348,133 +757,221 @@ func (mt *MTTransaction) Hash() common.Hash {
 	return common.BytesToHash(hash)
 }
 
-// ToTransaction converts an MT back to a regular transaction
+// To returns the recipient of an MT, nil for contract creations
+func (mt *MTTransaction) To() *common.Address {
+	if mt.IsContractCreation {
+		return nil
+	}
+	recipient := mt.Recipient
+	return &recipient
+}
+
+// ToTransaction converts an MT back to a regular transaction: the transaction the
+// sender signed when the MT carries it, otherwise an unsigned one rebuilt from
+// the revealed fields
 func (mt *MTTransaction) ToTransaction() *types.Transaction {
+	if tx, err := decodeSignedTx(mt.SignedTx); err == nil {
+		return tx
+	}
+	
 	// Create transaction with revealed fields
 	var tx *types.Transaction
 	
 	if mt.TxType == types.LegacyTxType {
-		tx = types.NewTransaction(0, mt.Recipient, mt.Value, mt.GasLimit, big.NewInt(0), mt.CallData)
+		tx = newLegacyTransaction(mt.AccountNonce, mt.To(), mt.Value, mt.GasLimit, big.NewInt(0), mt.CallData)
 	} else {
 		// Handle other transaction types
-		tx = types.NewTransaction(0, mt.Recipient, mt.Value, mt.GasLimit, big.NewInt(0), mt.CallData)
+		tx = newLegacyTransaction(mt.AccountNonce, mt.To(), mt.Value, mt.GasLimit, big.NewInt(0), mt.CallData)
 	}
 	
 	return tx
 }
 
-// Serialize serializes an MT to bytes
-func (mt *MTTransaction) Serialize() ([]byte, error) {
-	// Simple serialization - in production, use proper encoding
-	data := make([]byte, 0)
-	
-	// Add recipient
-	data = append(data, mt.Recipient.Bytes()...)
-	
-	// Add value
-	data = append(data, mt.Value.Bytes()...)
-	
-	// Add call data length and data
-	callDataLen := make([]byte, 4)
-	for i := 0; i < 4; i++ {
-		callDataLen[i] = byte(len(mt.CallData) >> (8 * i))
-	}
-	data = append(data, callDataLen...)
-	data = append(data, mt.CallData...)
-	
-	// Add transaction type
-	data = append(data, mt.TxType)
-	
-	// Add gas limit
-	gasLimitBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		gasLimitBytes[i] = byte(mt.GasLimit >> (8 * i))
-	}
-	data = append(data, gasLimitBytes...)
-	
-	// Add PHT hash
-	data = append(data, mt.PHTHash.Bytes()...)
-	
-	// Add proof length and proof
-	proofLen := make([]byte, 4)
-	for i := 0; i < 4; i++ {
-		proofLen[i] = byte(len(mt.Proof) >> (8 * i))
-	}
-	data = append(data, proofLen...)
-	data = append(data, mt.Proof...)
-	
-	// Add timestamp
-	timestampBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		timestampBytes[i] = byte(mt.Timestamp >> (8 * i))
-	}
-	data = append(data, timestampBytes...)
-	
-	return data, nil
+// MTEncodingVersion is the version written in RLP-encoded MTs. Later versions only
+// append fields, so decoders skip trailing fields they do not know.
+const MTEncodingVersion = 4
+
+// mtRLP is the RLP layout of an MT
+type mtRLP struct {
+	Version              uint64
+	Recipient            common.Address
+	Value                *big.Int
+	CallData             []byte
+	TxType               uint8
+	GasLimit             uint64
+	IsContractCreation   bool
+	AccountNonce         uint64
+	PHTHash              common.Hash
+	Proof                []byte
+	Timestamp            uint64
+	Blinding             []byte
+	AccessList           types.AccessList
+	BlobHashes           []common.Hash
+	MaxFeePerBlobGas     *big.Int
+	HiddenSet            uint16
+	FieldEncoding        uint8
+	Sender               common.Address
+	GasPrice             *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	ValueBlinding        []byte
+	FieldOpenings        []fieldOpeningRLP
+	TxHash               common.Hash
+	
+	// Version 2
+	Unrevealed bool `rlp:"optional"`
+	
+	// Version 3
+	SignedTx []byte `rlp:"optional"`
+	
+	// Version 4
+	RevealSignature []byte `rlp:"optional"`
+	
+	Rest []rlp.RawValue `rlp:"tail"` // Fields of later versions
 }
 
-// Deserialize deserializes bytes to an MT
-func (mt *MTTransaction) Deserialize(data []byte) error {
-	if len(data) < 20+32+4+1+8+32+4+8 { // Minimum required bytes
-		return errors.New("insufficient data")
+// fieldOpeningRLP is the RLP layout of a field opening
+type fieldOpeningRLP struct {
+	Index uint64
+	Value []byte
+	Salt  []byte
+	Path  [][]byte
+}
+
+// EncodeRLP implements rlp.Encoder
+func (mt *MTTransaction) EncodeRLP(w io.Writer) error {
+	enc, err := newMTRLP(mt)
+	if err != nil {
+		return err
 	}
-	
-	offset := 0
-	
-	// Deserialize recipient
-	mt.Recipient = common.BytesToAddress(data[offset : offset+20])
-	offset += 20
-	
-	// Deserialize value
-	mt.Value = new(big.Int).SetBytes(data[offset : offset+32])
-	offset += 32
-	
-	// Deserialize call data length
-	callDataLen := 0
-	for i := 0; i < 4; i++ {
-		callDataLen |= int(data[offset+i]) << (8 * i)
+	return rlp.Encode(w, enc)
+}
+
+// newMTRLP returns the RLP layout of an MT
+func newMTRLP(mt *MTTransaction) (*mtRLP, error) {
+	openings := make([]fieldOpeningRLP, 0, len(mt.FieldOpenings))
+	for _, opening := range mt.FieldOpenings {
+		if opening == nil || opening.Index < 0 {
+			return nil, errors.New("invalid field opening")
+		}
+		openings = append(openings, fieldOpeningRLP{
+			Index: uint64(opening.Index),
+			Value: opening.Value,
+			Salt:  opening.Salt,
+			Path:  opening.Path,
+		})
+	}
+	return &mtRLP{
+		Version:              MTEncodingVersion,
+		Recipient:            mt.Recipient,
+		Value:                mt.Value,
+		CallData:             mt.CallData,
+		TxType:               mt.TxType,
+		GasLimit:             mt.GasLimit,
+		IsContractCreation:   mt.IsContractCreation,
+		AccountNonce:         mt.AccountNonce,
+		PHTHash:              mt.PHTHash,
+		Proof:                mt.Proof,
+		Timestamp:            mt.Timestamp,
+		Blinding:             mt.Blinding,
+		AccessList:           mt.AccessList,
+		BlobHashes:           mt.BlobHashes,
+		MaxFeePerBlobGas:     mt.MaxFeePerBlobGas,
+		HiddenSet:            uint16(mt.HiddenSet),
+		FieldEncoding:        uint8(mt.FieldEncoding),
+		Sender:               mt.Sender,
+		GasPrice:             mt.GasPrice,
+		MaxFeePerGas:         mt.MaxFeePerGas,
+		MaxPriorityFeePerGas: mt.MaxPriorityFeePerGas,
+		ValueBlinding:        mt.ValueBlinding,
+		FieldOpenings:        openings,
+		TxHash:               mt.TxHash,
+		Unrevealed:           mt.Unrevealed,
+		SignedTx:             mt.SignedTx,
+		RevealSignature:      mt.RevealSignature,
+	}, nil
+}
+
+// DecodeRLP implements rlp.Decoder
+func (mt *MTTransaction) DecodeRLP(s *rlp.Stream) error {
+	var dec mtRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
 	}
-	offset += 4
-	
-	// Deserialize call data
-	mt.CallData = make([]byte, callDataLen)
-	copy(mt.CallData, data[offset:offset+callDataLen])
-	offset += callDataLen
-	
-	// Deserialize transaction type
-	mt.TxType = data[offset]
-	offset += 1
-	
-	// Deserialize gas limit
-	mt.GasLimit = 0
-	for i := 0; i < 8; i++ {
-		mt.GasLimit |= uint64(data[offset+i]) << (8 * i)
+	return dec.decode(mt)
+}
+
+// decode sets an MT from its RLP layout
+func (dec *mtRLP) decode(mt *MTTransaction) error {
+	if dec.Version == 0 {
+		return errors.New("invalid MT encoding version")
+	}
+	
+	*mt = MTTransaction{
+		Recipient:          dec.Recipient,
+		Value:              dec.Value,
+		CallData:           dec.CallData,
+		TxType:             dec.TxType,
+		GasLimit:           dec.GasLimit,
+		IsContractCreation: dec.IsContractCreation,
+		AccountNonce:       dec.AccountNonce,
+		PHTHash:            dec.PHTHash,
+		Proof:              dec.Proof,
+		Timestamp:          dec.Timestamp,
+		Blinding:           dec.Blinding,
+		HiddenSet:          HiddenFieldSet(dec.HiddenSet),
+		FieldEncoding:      FieldEncoding(dec.FieldEncoding),
+		Sender:             dec.Sender,
+		ValueBlinding:      dec.ValueBlinding,
+		Unrevealed:         dec.Unrevealed,
+		TxHash:             dec.TxHash,
+	}
+	// Absent optional fields decode as empty or zero, keep them nil
+	if len(dec.AccessList) > 0 {
+		mt.AccessList = dec.AccessList
+	}
+	if len(dec.BlobHashes) > 0 {
+		mt.BlobHashes = dec.BlobHashes
+		mt.MaxFeePerBlobGas = dec.MaxFeePerBlobGas
+	}
+	if len(dec.SignedTx) > 0 {
+		mt.SignedTx = dec.SignedTx
+	}
+	if len(dec.RevealSignature) > 0 {
+		mt.RevealSignature = dec.RevealSignature
+	}
+	if mt.HiddenSet.Has(FieldGasPrice) {
+		mt.GasPrice = dec.GasPrice
+		if dec.MaxFeePerGas != nil && dec.MaxFeePerGas.Sign() > 0 {
+			mt.MaxFeePerGas = dec.MaxFeePerGas
+			mt.MaxPriorityFeePerGas = dec.MaxPriorityFeePerGas
+		}
 	}
-	offset += 8
-	
-	// Deserialize PHT hash
-	mt.PHTHash = common.BytesToHash(data[offset : offset+32])
-	offset += 32
-	
-	// Deserialize proof length
-	proofLen := 0
-	for i := 0; i < 4; i++ {
-		proofLen |= int(data[offset+i]) << (8 * i)
+	for _, opening := range dec.FieldOpenings {
+		if opening.Index >= numHiddenFields {
+			return fmt.Errorf("invalid field opening index %d", opening.Index)
+		}
+		mt.FieldOpenings = append(mt.FieldOpenings, &FieldOpening{
+			Index: int(opening.Index),
+			Value: opening.Value,
+			Salt:  opening.Salt,
+			Path:  opening.Path,
+		})
 	}
-	offset += 4
-	
-	// Deserialize proof
-	mt.Proof = make([]byte, proofLen)
-	copy(mt.Proof, data[offset:offset+proofLen])
-	offset += proofLen
-	
-	// Deserialize timestamp
-	mt.Timestamp = 0
-	for i := 0; i < 8; i++ {
-		mt.Timestamp |= uint64(data[offset+i]) << (8 * i)
+	if err := mt.HiddenSet.Validate(); err != nil {
+		return err
 	}
-	
-	return nil
+	return mt.FieldEncoding.Validate()
+}
+
+// Serialize encodes an MT with RLP
+func (mt *MTTransaction) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(mt)
+}
+
+// Deserialize decodes an RLP-encoded MT
+func (mt *MTTransaction) Deserialize(data []byte) error {
+	return rlp.DecodeBytes(data, mt)
 }
 
 // GetRevealedFields returns the revealed fields of an MT
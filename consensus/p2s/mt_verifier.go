@@ -0,0 +1,78 @@
+package p2s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// VerifyMTs verifies the MTs of a B2 block against their PHTs across a worker
+// pool sized by the MTWorkers configuration. PHTs and MTs are matched by position
+// and unrevealed placeholders are checked against their PHT. The first failure
+// aborts the remaining verifications and is reported in block order.
+func (m *MTManager) VerifyMTs(ctx context.Context, phts []*PHTTransaction, mts []*MTTransaction) error {
+	if len(mts) > len(phts) {
+		return errors.New("MT count exceeds PHT count")
+	}
+	return m.verifyParallel(ctx, phts, mts, m.VerifyMT)
+}
+
+// verifyParallel runs verify on each PHT→MT pair, or VerifyPlaceholder for
+// unrevealed MTs, on a worker pool, cancelling outstanding work on the first error
+func (m *MTManager) verifyParallel(ctx context.Context, phts []*PHTTransaction, mts []*MTTransaction, verify func(*MTTransaction, *PHTTransaction) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	workers := runtime.NumCPU()
+	if m.config != nil && m.config.MTWorkers > 0 {
+		workers = m.config.MTWorkers
+	}
+	if workers > len(mts) {
+		workers = len(mts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(mts))
+
+	jobs := make(chan int)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := range jobs {
+				check := verify
+				if mts[i].Unrevealed {
+					check = m.VerifyPlaceholder
+				}
+				if errs[i] = check(mts[i], phts[i]); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range mts {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+
+	// Report the first failing MT before any cancellation it caused
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("MT %d (%s): %w", i, mts[i].TxHash.Hex(), withMTIndex(err, i))
+		}
+	}
+	return ctx.Err()
+}
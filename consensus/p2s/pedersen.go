@@ -0,0 +1,149 @@
+package p2s
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// pedersenDomain seeds the derivation of the second generator H
+const pedersenDomain = "p2s-pedersen-generator-h"
+
+var (
+	// Coordinates of the second generator H, with no known discrete log relative to G
+	pedersenHX, pedersenHY *big.Int
+	pedersenHOnce          sync.Once
+)
+
+// PedersenCommitment implements Pedersen commitments C = m·G + r·H over secp256k1,
+// where m is a hash of the committed data and r a random blinding factor. Commitments
+// are hiding because r is uniform and binding under the discrete log assumption,
+// since nobody knows log_G(H).
+type PedersenCommitment struct {
+	curve  elliptic.Curve
+	hx, hy *big.Int
+}
+
+// NewPedersenCommitment creates a new Pedersen commitment scheme
+func NewPedersenCommitment() *PedersenCommitment {
+	curve := crypto.S256()
+	pedersenHOnce.Do(func() {
+		pedersenHX, pedersenHY = hashToCurve(curve, []byte(pedersenDomain))
+	})
+
+	return &PedersenCommitment{
+		curve: curve,
+		hx:    pedersenHX,
+		hy:    pedersenHY,
+	}
+}
+
+// Commit commits to data under a fresh blinding factor, returning the compressed
+// commitment point and the blinding factor needed to open it
+func (p *PedersenCommitment) Commit(data ...[]byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errors.New("no data to commit")
+	}
+
+	n := p.curve.Params().N
+	r, err := rand.Int(rand.Reader, new(big.Int).Sub(n, common.Big1))
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Add(r, common.Big1)
+
+	blinding := common.LeftPadBytes(r.Bytes(), 32)
+	commitment, err := p.commit(blinding, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return commitment, blinding, nil
+}
+
+// Verify checks that a commitment opens to data with the given blinding factor
+func (p *PedersenCommitment) Verify(commitment []byte, blinding []byte, data ...[]byte) bool {
+	if len(data) == 0 || len(blinding) != 32 {
+		return false
+	}
+	r := new(big.Int).SetBytes(blinding)
+	if r.Sign() == 0 || r.Cmp(p.curve.Params().N) >= 0 {
+		return false
+	}
+
+	expected, err := p.commit(blinding, data)
+	if err != nil {
+		return false
+	}
+	return constantTimeEqual(commitment, expected)
+}
+
+// commit computes m·G + r·H for the data hash m and blinding factor r
+func (p *PedersenCommitment) commit(blinding []byte, data [][]byte) ([]byte, error) {
+	m := pedersenMessage(p.curve.Params().N, data)
+
+	gx, gy := p.curve.ScalarBaseMult(m)
+	hx, hy := p.curve.ScalarMult(p.hx, p.hy, blinding)
+	cx, cy := p.curve.Add(gx, gy, hx, hy)
+	if cx.Sign() == 0 && cy.Sign() == 0 {
+		return nil, errors.New("commitment is the point at infinity")
+	}
+	return compressPoint(cx, cy), nil
+}
+
+// pedersenMessage maps data to a scalar. Every item is length prefixed so
+// different splits of the same bytes commit to different values.
+func pedersenMessage(n *big.Int, data [][]byte) []byte {
+	items := make([][]byte, 0, 2*len(data)+1)
+	items = append(items, []byte("p2s-pedersen-message"))
+	for _, d := range data {
+		length := make([]byte, 8)
+		binary.BigEndian.PutUint64(length, uint64(len(d)))
+		items = append(items, length, d)
+	}
+	m := new(big.Int).SetBytes(crypto.Keccak256(items...))
+	m.Mod(m, n)
+	return common.LeftPadBytes(m.Bytes(), 32)
+}
+
+// hashToCurve derives a curve point with unknown discrete log by try-and-increment
+// on x = keccak256(seed || counter)
+func hashToCurve(curve elliptic.Curve, seed []byte) (*big.Int, *big.Int) {
+	params := curve.Params()
+	// secp256k1 has p = 3 mod 4, so square roots are rhs^((p+1)/4)
+	exp := new(big.Int).Rsh(new(big.Int).Add(params.P, common.Big1), 2)
+
+	counter := make([]byte, 4)
+	for i := uint32(0); ; i++ {
+		binary.BigEndian.PutUint32(counter, i)
+		x := new(big.Int).SetBytes(crypto.Keccak256(seed, counter))
+		x.Mod(x, params.P)
+
+		// y^2 = x^3 + 7
+		rhs := new(big.Int).Exp(x, big.NewInt(3), params.P)
+		rhs.Add(rhs, params.B)
+		rhs.Mod(rhs, params.P)
+
+		y := new(big.Int).Exp(rhs, exp, params.P)
+		if new(big.Int).Exp(y, common.Big2, params.P).Cmp(rhs) != 0 {
+			continue
+		}
+		if y.Bit(0) == 1 {
+			y.Sub(params.P, y)
+		}
+		return x, y
+	}
+}
+
+// compressPoint encodes a curve point in 33-byte SEC1 compressed form
+func compressPoint(x, y *big.Int) []byte {
+	encoded := make([]byte, 33)
+	encoded[0] = 0x02 | byte(y.Bit(0))
+	x.FillBytes(encoded[1:])
+	return encoded
+}
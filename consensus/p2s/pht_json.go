@@ -0,0 +1,304 @@
+package p2s
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// phtJSON is the JSON layout of a PHT, hex-encoding quantities and byte strings
+// like other Ethereum RPC objects
+type phtJSON struct {
+	ChainID              *hexutil.Big     `json:"chainId"`
+	Sender               common.Address   `json:"sender"`
+	AccountNonce         hexutil.Uint64   `json:"accountNonce"`
+	GasPrice             *hexutil.Big     `json:"gasPrice"`
+	Commitment           hexutil.Bytes    `json:"commitment"`
+	Nonce                hexutil.Bytes    `json:"nonce"`
+	Timestamp            hexutil.Uint64   `json:"timestamp"`
+	HiddenSet            hexutil.Uint64   `json:"hiddenSet,omitempty"`
+	FieldEncoding        hexutil.Uint64   `json:"fieldEncoding,omitempty"`
+	NonceContext         common.Hash      `json:"nonceContext"`
+	NonceProof           hexutil.Bytes    `json:"nonceProof,omitempty"`
+	MaxFeePerGas         *hexutil.Big     `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big     `json:"maxPriorityFeePerGas,omitempty"`
+	FieldCommitment      hexutil.Bytes    `json:"fieldCommitment"`
+	Recipient            common.Address   `json:"recipient"`
+	Value                *hexutil.Big     `json:"value"`
+	CallData             hexutil.Bytes    `json:"callData"`
+	TxType               hexutil.Uint64   `json:"txType"`
+	GasLimit             hexutil.Uint64   `json:"gasLimit"`
+	IsContractCreation   bool             `json:"isContractCreation,omitempty"`
+	FieldSalts           []hexutil.Bytes  `json:"fieldSalts"`
+	Blinding             hexutil.Bytes    `json:"blinding"`
+	AccessList           types.AccessList `json:"accessList,omitempty"`
+	BlobHashes           []common.Hash    `json:"blobHashes,omitempty"`
+	MaxFeePerBlobGas     *hexutil.Big     `json:"maxFeePerBlobGas,omitempty"`
+	ValueCommitment      hexutil.Bytes    `json:"valueCommitment,omitempty"`
+	ValueRangeProof      *RangeProof      `json:"valueRangeProof,omitempty"`
+	ValueBlinding        hexutil.Bytes    `json:"valueBlinding,omitempty"`
+	EncryptedFields      *EncryptedFields `json:"encryptedFields,omitempty"`
+	TimelockPuzzle       *TimelockPuzzle  `json:"timelockPuzzle,omitempty"`
+	SignedTx             hexutil.Bytes    `json:"signedTx,omitempty"`
+	RevealSignature      hexutil.Bytes    `json:"revealSignature,omitempty"`
+	TxHash               common.Hash      `json:"txHash"`
+}
+
+// legacyPHTJSON decodes PHTs marshalled before hex encoding, with decimal numbers
+// and base64 byte strings, so existing fixtures and exports stay readable
+type legacyPHTJSON PHTTransaction
+
+// MarshalJSON implements json.Marshaler
+func (pht *PHTTransaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&phtJSON{
+		ChainID:              (*hexutil.Big)(pht.ChainID),
+		Sender:               pht.Sender,
+		AccountNonce:         hexutil.Uint64(pht.AccountNonce),
+		GasPrice:             (*hexutil.Big)(pht.GasPrice),
+		Commitment:           pht.Commitment,
+		Nonce:                pht.Nonce,
+		Timestamp:            hexutil.Uint64(pht.Timestamp),
+		HiddenSet:            hexutil.Uint64(pht.HiddenSet),
+		FieldEncoding:        hexutil.Uint64(pht.FieldEncoding),
+		NonceContext:         pht.NonceContext,
+		NonceProof:           pht.NonceProof,
+		MaxFeePerGas:         (*hexutil.Big)(pht.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(pht.MaxPriorityFeePerGas),
+		FieldCommitment:      pht.FieldCommitment,
+		Recipient:            pht.Recipient,
+		Value:                (*hexutil.Big)(pht.Value),
+		CallData:             pht.CallData,
+		TxType:               hexutil.Uint64(pht.TxType),
+		GasLimit:             hexutil.Uint64(pht.GasLimit),
+		IsContractCreation:   pht.IsContractCreation,
+		FieldSalts:           toHexBytes(pht.FieldSalts),
+		Blinding:             pht.Blinding,
+		AccessList:           pht.AccessList,
+		BlobHashes:           pht.BlobHashes,
+		MaxFeePerBlobGas:     (*hexutil.Big)(pht.MaxFeePerBlobGas),
+		ValueCommitment:      pht.ValueCommitment,
+		ValueRangeProof:      pht.ValueRangeProof,
+		ValueBlinding:        pht.ValueBlinding,
+		EncryptedFields:      pht.EncryptedFields,
+		TimelockPuzzle:       pht.TimelockPuzzle,
+		SignedTx:             pht.SignedTx,
+		RevealSignature:      pht.RevealSignature,
+		TxHash:               pht.TxHash,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, also accepting the legacy encoding
+func (pht *PHTTransaction) UnmarshalJSON(input []byte) error {
+	var dec phtJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		var legacy legacyPHTJSON
+		if json.Unmarshal(input, &legacy) != nil {
+			return err
+		}
+		*pht = PHTTransaction(legacy)
+		return validateJSONFields(pht.HiddenSet, pht.FieldEncoding)
+	}
+	if err := checkJSONWidths(dec.HiddenSet, dec.FieldEncoding, dec.TxType); err != nil {
+		return err
+	}
+
+	*pht = PHTTransaction{
+		ChainID:              (*big.Int)(dec.ChainID),
+		Sender:               dec.Sender,
+		AccountNonce:         uint64(dec.AccountNonce),
+		GasPrice:             (*big.Int)(dec.GasPrice),
+		Commitment:           dec.Commitment,
+		Nonce:                dec.Nonce,
+		Timestamp:            uint64(dec.Timestamp),
+		HiddenSet:            HiddenFieldSet(dec.HiddenSet),
+		FieldEncoding:        FieldEncoding(dec.FieldEncoding),
+		NonceContext:         dec.NonceContext,
+		NonceProof:           dec.NonceProof,
+		MaxFeePerGas:         (*big.Int)(dec.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*big.Int)(dec.MaxPriorityFeePerGas),
+		FieldCommitment:      dec.FieldCommitment,
+		Recipient:            dec.Recipient,
+		Value:                (*big.Int)(dec.Value),
+		CallData:             dec.CallData,
+		TxType:               uint8(dec.TxType),
+		GasLimit:             uint64(dec.GasLimit),
+		IsContractCreation:   dec.IsContractCreation,
+		FieldSalts:           fromHexBytes(dec.FieldSalts),
+		Blinding:             dec.Blinding,
+		AccessList:           dec.AccessList,
+		BlobHashes:           dec.BlobHashes,
+		MaxFeePerBlobGas:     (*big.Int)(dec.MaxFeePerBlobGas),
+		ValueCommitment:      dec.ValueCommitment,
+		ValueRangeProof:      dec.ValueRangeProof,
+		ValueBlinding:        dec.ValueBlinding,
+		EncryptedFields:      dec.EncryptedFields,
+		TimelockPuzzle:       dec.TimelockPuzzle,
+		SignedTx:             dec.SignedTx,
+		RevealSignature:      dec.RevealSignature,
+		TxHash:               dec.TxHash,
+	}
+	return validateJSONFields(pht.HiddenSet, pht.FieldEncoding)
+}
+
+// mtJSON is the JSON layout of an MT, hex-encoding quantities and byte strings
+// like other Ethereum RPC objects
+type mtJSON struct {
+	Recipient            common.Address   `json:"recipient"`
+	Value                *hexutil.Big     `json:"value"`
+	CallData             hexutil.Bytes    `json:"callData"`
+	TxType               hexutil.Uint64   `json:"txType"`
+	GasLimit             hexutil.Uint64   `json:"gasLimit"`
+	IsContractCreation   bool             `json:"isContractCreation,omitempty"`
+	AccountNonce         hexutil.Uint64   `json:"accountNonce"`
+	PHTHash              common.Hash      `json:"phtHash"`
+	Proof                hexutil.Bytes    `json:"proof"`
+	Timestamp            hexutil.Uint64   `json:"timestamp"`
+	Blinding             hexutil.Bytes    `json:"blinding"`
+	AccessList           types.AccessList `json:"accessList,omitempty"`
+	BlobHashes           []common.Hash    `json:"blobHashes,omitempty"`
+	MaxFeePerBlobGas     *hexutil.Big     `json:"maxFeePerBlobGas,omitempty"`
+	HiddenSet            hexutil.Uint64   `json:"hiddenSet,omitempty"`
+	FieldEncoding        hexutil.Uint64   `json:"fieldEncoding,omitempty"`
+	Sender               common.Address   `json:"sender"`
+	GasPrice             *hexutil.Big     `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *hexutil.Big     `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *hexutil.Big     `json:"maxPriorityFeePerGas,omitempty"`
+	ValueBlinding        hexutil.Bytes    `json:"valueBlinding,omitempty"`
+	FieldOpenings        []*FieldOpening  `json:"fieldOpenings,omitempty"`
+	Unrevealed           bool             `json:"unrevealed,omitempty"`
+	SignedTx             hexutil.Bytes    `json:"signedTx,omitempty"`
+	RevealSignature      hexutil.Bytes    `json:"revealSignature,omitempty"`
+	TxHash               common.Hash      `json:"txHash"`
+}
+
+// legacyMTJSON decodes MTs marshalled before hex encoding
+type legacyMTJSON MTTransaction
+
+// MarshalJSON implements json.Marshaler
+func (mt *MTTransaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&mtJSON{
+		Recipient:            mt.Recipient,
+		Value:                (*hexutil.Big)(mt.Value),
+		CallData:             mt.CallData,
+		TxType:               hexutil.Uint64(mt.TxType),
+		GasLimit:             hexutil.Uint64(mt.GasLimit),
+		IsContractCreation:   mt.IsContractCreation,
+		AccountNonce:         hexutil.Uint64(mt.AccountNonce),
+		PHTHash:              mt.PHTHash,
+		Proof:                mt.Proof,
+		Timestamp:            hexutil.Uint64(mt.Timestamp),
+		Blinding:             mt.Blinding,
+		AccessList:           mt.AccessList,
+		BlobHashes:           mt.BlobHashes,
+		MaxFeePerBlobGas:     (*hexutil.Big)(mt.MaxFeePerBlobGas),
+		HiddenSet:            hexutil.Uint64(mt.HiddenSet),
+		FieldEncoding:        hexutil.Uint64(mt.FieldEncoding),
+		Sender:               mt.Sender,
+		GasPrice:             (*hexutil.Big)(mt.GasPrice),
+		MaxFeePerGas:         (*hexutil.Big)(mt.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(mt.MaxPriorityFeePerGas),
+		ValueBlinding:        mt.ValueBlinding,
+		FieldOpenings:        mt.FieldOpenings,
+		Unrevealed:           mt.Unrevealed,
+		SignedTx:             mt.SignedTx,
+		RevealSignature:      mt.RevealSignature,
+		TxHash:               mt.TxHash,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, also accepting the legacy encoding
+func (mt *MTTransaction) UnmarshalJSON(input []byte) error {
+	var dec mtJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		var legacy legacyMTJSON
+		if json.Unmarshal(input, &legacy) != nil {
+			return err
+		}
+		*mt = MTTransaction(legacy)
+		return validateJSONFields(mt.HiddenSet, mt.FieldEncoding)
+	}
+	if err := checkJSONWidths(dec.HiddenSet, dec.FieldEncoding, dec.TxType); err != nil {
+		return err
+	}
+
+	*mt = MTTransaction{
+		Recipient:            dec.Recipient,
+		Value:                (*big.Int)(dec.Value),
+		CallData:             dec.CallData,
+		TxType:               uint8(dec.TxType),
+		GasLimit:             uint64(dec.GasLimit),
+		IsContractCreation:   dec.IsContractCreation,
+		AccountNonce:         uint64(dec.AccountNonce),
+		PHTHash:              dec.PHTHash,
+		Proof:                dec.Proof,
+		Timestamp:            uint64(dec.Timestamp),
+		Blinding:             dec.Blinding,
+		AccessList:           dec.AccessList,
+		BlobHashes:           dec.BlobHashes,
+		MaxFeePerBlobGas:     (*big.Int)(dec.MaxFeePerBlobGas),
+		HiddenSet:            HiddenFieldSet(dec.HiddenSet),
+		FieldEncoding:        FieldEncoding(dec.FieldEncoding),
+		Sender:               dec.Sender,
+		GasPrice:             (*big.Int)(dec.GasPrice),
+		MaxFeePerGas:         (*big.Int)(dec.MaxFeePerGas),
+		MaxPriorityFeePerGas: (*big.Int)(dec.MaxPriorityFeePerGas),
+		ValueBlinding:        dec.ValueBlinding,
+		FieldOpenings:        dec.FieldOpenings,
+		Unrevealed:           dec.Unrevealed,
+		SignedTx:             dec.SignedTx,
+		RevealSignature:      dec.RevealSignature,
+		TxHash:               dec.TxHash,
+	}
+	return validateJSONFields(mt.HiddenSet, mt.FieldEncoding)
+}
+
+// checkJSONWidths rejects hex quantities too wide for their fields
+func checkJSONWidths(hiddenSet, encoding, txType hexutil.Uint64) error {
+	if hiddenSet > math.MaxUint16 {
+		return fmt.Errorf("%w: %#x", ErrInvalidHiddenFieldSet, uint64(hiddenSet))
+	}
+	if encoding > math.MaxUint8 {
+		return fmt.Errorf("%w: %#x", ErrUnknownFieldEncoding, uint64(encoding))
+	}
+	if txType > math.MaxUint8 {
+		return fmt.Errorf("invalid transaction type %#x", uint64(txType))
+	}
+	return nil
+}
+
+// validateJSONFields checks the decoded hidden field set and field encoding
+func validateJSONFields(hiddenSet HiddenFieldSet, encoding FieldEncoding) error {
+	if err := hiddenSet.Validate(); err != nil {
+		return err
+	}
+	return encoding.Validate()
+}
+
+// toHexBytes converts byte strings for hex encoding
+func toHexBytes(items [][]byte) []hexutil.Bytes {
+	if items == nil {
+		return nil
+	}
+	result := make([]hexutil.Bytes, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
+
+// fromHexBytes converts hex-decoded byte strings
+func fromHexBytes(items []hexutil.Bytes) [][]byte {
+	if items == nil {
+		return nil
+	}
+	result := make([][]byte, len(items))
+	for i, item := range items {
+		result[i] = item
+	}
+	return result
+}
@@ -1,38 +1,108 @@
 package p2s
 
 import (
-	"crypto/sha256"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"io"
 	"math/big"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
 )
 
 // PHTManager manages Partially Hidden Transactions
 type PHTManager struct {
 	commitmentScheme CommitmentScheme
+	vectorCommitment *VectorCommitment
 	antiMEVNonce     *AntiMEVNonce
+	replacements     *phtReplacements
+	openings         *OpeningStore
+	hiddenSet        HiddenFieldSet
+	thresholdKey     *ThresholdKey // Committee key hidden fields are sealed to
+	keyMu            sync.RWMutex
 	config          *P2SConfig
 }
 
 // PHTTransaction represents a Partially Hidden Transaction
 type PHTTransaction struct {
 	// Visible fields (included in B1 block)
+	ChainID    *big.Int       `json:"chainId"` // Network the PHT is bound to
 	Sender     common.Address `json:"sender"`
+	AccountNonce uint64      `json:"accountNonce"` // Orders the sender's PHTs
 	GasPrice   *big.Int      `json:"gasPrice"`
 	Commitment []byte        `json:"commitment"`
 	Nonce      []byte        `json:"nonce"`
 	Timestamp  uint64        `json:"timestamp"`
 	
+	// Fields committed and revealed in B2, zero for DefaultHiddenFieldSet. Sender
+	// and gas price are only visible when not in the set.
+	HiddenSet HiddenFieldSet `json:"hiddenSet,omitempty"`
+	
+	// Serialization of the hidden fields under the commitment, zero for PHTs
+	// created before encodings were versioned
+	FieldEncoding FieldEncoding `json:"fieldEncoding,omitempty"`
+	
+	// VRF proof of the anti-MEV nonce under the sender key over the block context,
+	// empty for PHTs converted without the sender key
+	NonceContext common.Hash `json:"nonceContext"`
+	NonceProof   []byte      `json:"nonceProof,omitempty"`
+	
+	// EIP-1559 fee caps, nil for legacy and access list transactions. GasPrice
+	// holds the fee cap for dynamic-fee transactions.
+	MaxFeePerGas         *big.Int `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *big.Int `json:"maxPriorityFeePerGas,omitempty"`
+	
+	// Vector commitment with one position per hidden field for selective reveal
+	FieldCommitment []byte `json:"fieldCommitment"`
+	
 	// Hidden fields (committed but not revealed until B2)
 	Recipient common.Address `json:"recipient"`
 	Value     *big.Int      `json:"value"`
 	CallData  []byte        `json:"callData"`
 	TxType    uint8         `json:"txType"`
 	GasLimit  uint64        `json:"gasLimit"`
+	IsContractCreation bool `json:"isContractCreation,omitempty"` // Nil To, Recipient is zero
+	FieldSalts [][]byte     `json:"fieldSalts"` // Blinding salts for the vector commitment
+	Blinding   []byte       `json:"blinding"`   // Blinding factor of the Pedersen commitment
+	
+	// Hidden EIP-2930 access list, empty for legacy transactions
+	AccessList types.AccessList `json:"accessList,omitempty"`
+	
+	// Hidden EIP-4844 blob fields, empty for other transaction types
+	BlobHashes       []common.Hash `json:"blobHashes,omitempty"`
+	MaxFeePerBlobGas *big.Int      `json:"maxFeePerBlobGas,omitempty"`
+	
+	// Commitment to the hidden value with a proof that it is below the network's
+	// MaxPHTValue, nil without a cap. ValueBlinding opens it and stays hidden.
+	ValueCommitment []byte      `json:"valueCommitment,omitempty"`
+	ValueRangeProof *RangeProof `json:"valueRangeProof,omitempty"`
+	ValueBlinding   []byte      `json:"valueBlinding,omitempty"`
+	
+	// Hidden fields encrypted to the decryption committee, nil when sent in the clear
+	EncryptedFields *EncryptedFields `json:"encryptedFields,omitempty"`
+	
+	// Time-lock puzzle opening the hidden fields after the B1 interval, nil unless
+	// the timelock commitment scheme is configured
+	TimelockPuzzle *TimelockPuzzle `json:"timelockPuzzle,omitempty"`
+	
+	// Hidden canonical encoding of the transaction as signed by the sender, so
+	// the revealed transaction is the one the sender signed. Nil for PHTs created
+	// before signed payloads were carried.
+	SignedTx []byte `json:"signedTx,omitempty"`
+	
+	// Hidden signature of the sender over the PHT hash and revealed fields, so
+	// only the sender can produce a valid reveal. Nil without the sender key.
+	RevealSignature []byte `json:"revealSignature,omitempty"`
 	
 	// Transaction hash
 	TxHash common.Hash `json:"txHash"`
@@ -40,164 +110,444 @@ type PHTTransaction struct {
 
 // CommitmentScheme interface for cryptographic commitments
 type CommitmentScheme interface {
-	Commit(data ...[]byte) ([]byte, error)
-	Verify(commitment []byte, data ...[]byte) bool
-	Open(commitment []byte) ([]byte, error)
+	// Commit commits to data, returning the commitment and the blinding factor that opens it
+	Commit(data ...[]byte) (commitment []byte, blinding []byte, err error)
+	
+	// Verify checks that a commitment opens to data with the given blinding factor
+	Verify(commitment []byte, blinding []byte, data ...[]byte) bool
 }
 
-// PedersenCommitment implements Pedersen commitment scheme
-type PedersenCommitment struct {
-	generator *big.Int
-	modulus   *big.Int
+// Built-in commitment schemes selectable via P2SConfig.CommitmentScheme; others
+// can be added with RegisterCommitmentScheme
+const (
+	CommitmentSchemePedersen = "pedersen"
+	CommitmentSchemeKZG      = "kzg"
+	CommitmentSchemeTimelock = "timelock"
+	CommitmentSchemeMiMC     = "mimc"
+)
+
+// ErrChainIDMismatch is returned for PHTs bound to another network
+var ErrChainIDMismatch = errors.New("PHT chain ID mismatch")
+
+// Anti-MEV nonce errors
+var (
+	ErrMissingNonceProof = errors.New("missing anti-MEV nonce proof")
+	ErrInvalidNonce      = errors.New("invalid anti-MEV nonce")
+	ErrNonceKeyMismatch  = errors.New("nonce key does not belong to transaction sender")
+)
+
+// AntiMEVNonce derives anti-MEV nonces from a verifiable random function of the
+// sender key over the block context. Neither the proposer nor other senders can
+// predict a nonce, and the sender cannot grind it, yet validators verify it from
+// the proof alone.
+type AntiMEVNonce struct {
+	context common.Hash // Block context of new nonces, the parent block hash
+	mu      sync.RWMutex
 }
 
-// NewPedersenCommitment creates a new Pedersen commitment scheme
-func NewPedersenCommitment() *PedersenCommitment {
-	// Use secp256k1 parameters for compatibility with Ethereum
-	return &PedersenCommitment{
-		generator: big.NewInt(2),
-		modulus:   crypto.S256().P,
-	}
+// NewAntiMEVNonce creates a new anti-MEV nonce generator
+func NewAntiMEVNonce() *AntiMEVNonce {
+	return &AntiMEVNonce{}
 }
 
-// Commit creates a commitment for the given data
-func (p *PedersenCommitment) Commit(data ...[]byte) ([]byte, error) {
-	if len(data) == 0 {
-		return nil, errors.New("no data to commit")
-	}
-	
-	// Hash all data together
-	hasher := sha256.New()
-	for _, d := range data {
-		hasher.Write(d)
-	}
-	hash := hasher.Sum(nil)
-	
-	// Convert to big.Int
-	hashInt := new(big.Int).SetBytes(hash)
-	
-	// Create commitment: g^hash mod p
-	commitment := new(big.Int).Exp(p.generator, hashInt, p.modulus)
-	
-	return commitment.Bytes(), nil
+// SetContext sets the block context new nonces are derived over
+func (a *AntiMEVNonce) SetContext(blockContext common.Hash) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.context = blockContext
 }
 
-// Verify verifies a commitment against data
-func (p *PedersenCommitment) Verify(commitment []byte, data ...[]byte) bool {
-	// Recreate commitment from data
-	newCommitment, err := p.Commit(data...)
-	if err != nil {
-		return false
-	}
-	
-	// Compare commitments
-	return string(commitment) == string(newCommitment)
+// Context returns the block context new nonces are derived over
+func (a *AntiMEVNonce) Context() common.Hash {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.context
 }
 
-// Open opens a commitment (for verification purposes)
-func (p *PedersenCommitment) Open(commitment []byte) ([]byte, error) {
-	// In a real implementation, this would require the opening key
-	// For now, return the commitment itself
-	return commitment, nil
+// Generate derives the nonce of a transaction from the sender key, returning the
+// nonce, its VRF proof and the block context it was derived over
+func (a *AntiMEVNonce) Generate(key *ecdsa.PrivateKey, txHash common.Hash) ([]byte, []byte, common.Hash, error) {
+	blockContext := a.Context()
+	nonce, proof, err := VRFProve(key, antiMEVNonceInput(blockContext, txHash))
+	if err != nil {
+		return nil, nil, common.Hash{}, err
+	}
+	return nonce, proof, blockContext, nil
 }
 
-// AntiMEVNonce generates anti-MEV nonces
-type AntiMEVNonce struct {
-	randomSource func() []byte
+// GenerateUnproven returns a random nonce for transactions converted without the
+// sender key. It cannot be verified by validators.
+func (a *AntiMEVNonce) GenerateUnproven() ([]byte, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
 }
 
-// NewAntiMEVNonce creates a new anti-MEV nonce generator
-func NewAntiMEVNonce() *AntiMEVNonce {
-	return &AntiMEVNonce{
-		randomSource: func() []byte {
-			// Generate random bytes
-			return crypto.Keccak256([]byte(time.Now().String()))
-		},
+// Verify checks that a nonce is the VRF output of the sender key for a transaction
+// in the given block context
+func (a *AntiMEVNonce) Verify(sender common.Address, blockContext common.Hash, txHash common.Hash, nonce, proof []byte) error {
+	if len(proof) == 0 {
+		return ErrMissingNonceProof
+	}
+	output, key, err := VRFVerify(proof, antiMEVNonceInput(blockContext, txHash))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNonce, err)
 	}
+	if crypto.PubkeyToAddress(*key) != sender {
+		return fmt.Errorf("%w: proof not made by sender %s", ErrInvalidNonce, sender.Hex())
+	}
+	if !constantTimeEqual(output, nonce) {
+		return fmt.Errorf("%w: nonce does not match proof", ErrInvalidNonce)
+	}
+	return nil
 }
 
-// Generate generates a new anti-MEV nonce
-func (a *AntiMEVNonce) Generate() []byte {
-	return a.randomSource()
+// antiMEVNonceInput is the VRF input of the nonce of a transaction
+func antiMEVNonceInput(blockContext common.Hash, txHash common.Hash) []byte {
+	return crypto.Keccak256([]byte("p2s-anti-mev-nonce"), blockContext.Bytes(), txHash.Bytes())
 }
 
-// NewPHTManager creates a new PHT manager
-func NewPHTManager(config *P2SConfig) *PHTManager {
+// NewPHTManager creates a new PHT manager. It fails if the configured commitment
+// scheme cannot be created.
+func NewPHTManager(config *P2SConfig) (*PHTManager, error) {
+	scheme, err := NewCommitmentScheme(config)
+	if err != nil {
+		return nil, err
+	}
 	return &PHTManager{
-		commitmentScheme: NewPedersenCommitment(),
+		commitmentScheme: scheme,
+		vectorCommitment: NewVectorCommitment(),
 		antiMEVNonce:     NewAntiMEVNonce(),
+		replacements:     newPHTReplacements(),
+		openings:         NewOpeningStore(),
+		hiddenSet:        configuredHiddenFieldSet(config),
 		config:          config,
-	}
+	}, nil
 }
 
-// CreatePHT creates a PHT from a regular transaction
+// SetThresholdKey sets the committee key PHT hidden fields are sealed to when
+// SealHiddenFields is configured
+func (p *PHTManager) SetThresholdKey(key *ThresholdKey) {
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+
+	p.thresholdKey = key
+}
+
+// SetNonceContext sets the block context anti-MEV nonces are derived over
+func (p *PHTManager) SetNonceContext(blockContext common.Hash) {
+	p.antiMEVNonce.SetContext(blockContext)
+}
+
+// CreatePHT creates a PHT from a regular transaction. Without the sender key the
+// anti-MEV nonce is random and carries no proof; see CreatePHTWithKey.
 func (p *PHTManager) CreatePHT(tx *types.Transaction) (*PHTTransaction, error) {
+	return p.CreatePHTWithKey(tx, nil)
+}
+
+// CreatePHTWithKey creates a PHT from a transaction of the owner of key, with an
+// anti-MEV nonce validators can verify
+func (p *PHTManager) CreatePHTWithKey(tx *types.Transaction, key *ecdsa.PrivateKey) (*PHTTransaction, error) {
 	// Extract transaction fields
-	sender, err := types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
 	if err != nil {
 		return nil, err
 	}
+	if key != nil && crypto.PubkeyToAddress(key.PublicKey) != sender {
+		return nil, ErrNonceKeyMismatch
+	}
 	
-	recipient := tx.To()
-	if recipient == nil {
-		// Contract creation transaction
-		recipient = common.Address{}
+	// Contract creations keep a zero recipient and are marked explicitly
+	var recipient common.Address
+	if tx.To() != nil {
+		recipient = *tx.To()
 	}
 	
 	// Create commitment for hidden fields
-	hiddenData := [][]byte{
-		recipient.Bytes(),
-		tx.Value().Bytes(),
-		tx.Data(),
-		{tx.Type()},
-		{byte(tx.Gas())},
+	hidden := &HiddenFields{
+		Recipient:  recipient,
+		IsContractCreation: tx.To() == nil,
+		AccountNonce:       tx.Nonce(),
+		Value:      tx.Value(),
+		CallData:   tx.Data(),
+		TxType:     tx.Type(),
+		GasLimit:   tx.Gas(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.BlobTxType {
+		hidden.BlobHashes, hidden.MaxFeePerBlobGas = tx.BlobHashes(), tx.BlobGasFeeCap()
+	}
+	if p.hiddenSet.Has(FieldSender) {
+		hidden.Sender = sender
+	}
+	if p.hiddenSet.Has(FieldGasPrice) {
+		hidden.GasPrice = tx.GasPrice()
+		if tx.Type() >= types.DynamicFeeTxType {
+			hidden.MaxFeePerGas, hidden.MaxPriorityFeePerGas = tx.GasFeeCap(), tx.GasTipCap()
+		}
+	}
+	hiddenData, err := hiddenCommitmentData(p.hiddenSet, CurrentFieldEncoding, hidden)
+	if err != nil {
+		return nil, err
 	}
 	
-	commitment, err := p.commitmentScheme.Commit(hiddenData...)
+	commitment, blinding, err := p.commitmentScheme.Commit(hiddenData...)
+	if err != nil {
+		return nil, err
+	}
+	p.openings.Put(&CommitmentOpening{Commitment: commitment, Data: hiddenData, Blinding: blinding})
+	
+	// Keep the signed payload, without any blob sidecar, for the reveal
+	signedTx, err := tx.WithoutBlobTxSidecar().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	
+	// Create per-field vector commitment for selective reveal
+	fieldCommitment, fieldSalts, err := p.vectorCommitment.CommitVector(
+		hiddenFieldVector(tx.To(), tx.Value(), tx.Data(), tx.Type(), tx.Gas()),
+	)
 	if err != nil {
 		return nil, err
 	}
 	
 	// Generate anti-MEV nonce
-	nonce := p.antiMEVNonce.Generate()
+	var (
+		nonce        []byte
+		nonceProof   []byte
+		nonceContext common.Hash
+	)
+	switch {
+	case key != nil:
+		if nonce, nonceProof, nonceContext, err = p.antiMEVNonce.Generate(key, tx.Hash()); err != nil {
+			return nil, err
+		}
+	case p.config != nil && p.config.RequireNonceProofs:
+		return nil, fmt.Errorf("%w: no sender key to prove the nonce of %s", ErrMissingNonceProof, tx.Hash().Hex())
+	default:
+		if nonce, err = p.antiMEVNonce.GenerateUnproven(); err != nil {
+			return nil, err
+		}
+	}
 	
 	// Create PHT
 	pht := &PHTTransaction{
 		Sender:     sender,
+		AccountNonce: tx.Nonce(),
 		GasPrice:   tx.GasPrice(),
 		Commitment: commitment,
 		Nonce:      nonce,
 		Timestamp:  uint64(time.Now().Unix()),
-		Recipient:  *recipient,
+		ChainID:    p.chainID(tx),
+		NonceContext: nonceContext,
+		NonceProof:   nonceProof,
+		FieldEncoding: CurrentFieldEncoding,
+		FieldCommitment: fieldCommitment,
+		Recipient:  recipient,
+		IsContractCreation: hidden.IsContractCreation,
 		Value:      tx.Value(),
 		CallData:   tx.Data(),
 		TxType:     tx.Type(),
 		GasLimit:   tx.Gas(),
+		FieldSalts: fieldSalts,
+		Blinding:   blinding,
+		AccessList:       hidden.AccessList,
+		BlobHashes:       hidden.BlobHashes,
+		MaxFeePerBlobGas: hidden.MaxFeePerBlobGas,
+		SignedTx:   signedTx,
 		TxHash:     tx.Hash(),
 	}
+	if tx.Type() >= types.DynamicFeeTxType {
+		pht.MaxFeePerGas = tx.GasFeeCap()
+		pht.MaxPriorityFeePerGas = tx.GasTipCap()
+	}
+	if p.hiddenSet != DefaultHiddenFieldSet {
+		pht.HiddenSet = p.hiddenSet
+	}
+	
+	// Prove the hidden value is below the cap so validators can enforce it on B1
+	if limit := p.config.MaxPHTValue; limit != nil && limit.Sign() > 0 {
+		if pht.ValueCommitment, pht.ValueBlinding, err = NewValueCommitment(tx.Value()); err != nil {
+			return nil, err
+		}
+		if pht.ValueRangeProof, err = ProveValueRange(tx.Value(), pht.ValueBlinding, limit); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Bind the reveal to the sender so a proposer cannot substitute another one
+	if key != nil {
+		if pht.RevealSignature, err = signReveal(key, pht.Hash(), hiddenData); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Guarantee the reveal even if the sender withholds the MT
+	if timelock, ok := p.commitmentScheme.(*TimelockCommitment); ok {
+		pht.TimelockPuzzle, err = timelock.Lock(pht.TxHash, pht.hiddenFields())
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	// Keep the hidden fields from the proposer until a decryption quorum
+	if p.config.SealHiddenFields {
+		p.keyMu.RLock()
+		thresholdKey := p.thresholdKey
+		p.keyMu.RUnlock()
+		if thresholdKey == nil {
+			return nil, fmt.Errorf("%w: cannot seal PHT %s", ErrMissingThresholdKey, pht.TxHash.Hex())
+		}
+		if err := SealHiddenFields(thresholdKey, pht); err != nil {
+			return nil, err
+		}
+	}
 	
 	return pht, nil
 }
 
+// chainID returns the chain ID a PHT of tx is bound to: the configured network's,
+// or the transaction's own if none is configured
+func (p *PHTManager) chainID(tx *types.Transaction) *big.Int {
+	if p.config.ChainID != nil {
+		return new(big.Int).Set(p.config.ChainID)
+	}
+	return tx.ChainId()
+}
+
+// CreatePHTs creates PHTs from a batch of transactions in parallel
+func (p *PHTManager) CreatePHTs(txs []*types.Transaction) ([]*PHTTransaction, error) {
+	return p.CreatePHTsWithContext(context.Background(), txs)
+}
+
+// CreatePHTsWithContext creates PHTs from a batch of transactions across a worker
+// pool, since commitment computation is the hot path of B1 preparation. PHTs are
+// returned in transaction order. Transactions whose hidden fields do not fit the
+// KZG commitment are left out; any other error or cancellation aborts the batch.
+func (p *PHTManager) CreatePHTsWithContext(ctx context.Context, txs []*types.Transaction) ([]*PHTTransaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	
+	workers := runtime.NumCPU()
+	if p.config != nil && p.config.PHTWorkers > 0 {
+		workers = p.config.PHTWorkers
+	}
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	phts := make([]*PHTTransaction, len(txs))
+	errs := make([]error, len(txs))
+	
+	jobs := make(chan int)
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := range jobs {
+				if phts[i], errs[i] = p.CreatePHT(txs[i]); errs[i] != nil && !errors.Is(errs[i], ErrKZGCapacity) {
+					cancel()
+				}
+			}
+		}()
+	}
+	
+feed:
+	for i := range txs {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+	
+	// Report the first failing transaction before any cancellation it caused
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, ErrKZGCapacity) {
+			return nil, fmt.Errorf("transaction %d (%s): %w", i, txs[i].Hash().Hex(), err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	created := phts[:0]
+	for i, pht := range phts {
+		if errs[i] != nil {
+			log.Debug("Leaving out transaction exceeding the KZG blob", "tx", txs[i].Hash(), "err", errs[i])
+			continue
+		}
+		created = append(created, pht)
+	}
+	return created, nil
+}
+
 // ValidatePHT validates a PHT
 func (p *PHTManager) ValidatePHT(pht *PHTTransaction) error {
 	// Validate commitment
-	hiddenData := [][]byte{
-		pht.Recipient.Bytes(),
-		pht.Value.Bytes(),
-		pht.CallData,
-		{pht.TxType},
-		{byte(pht.GasLimit)},
+	if !p.VerifyHiddenFields(pht, pht.hiddenFields()) {
+		return errors.New("invalid commitment")
 	}
 	
-	if !p.commitmentScheme.Verify(pht.Commitment, hiddenData...) {
-		return errors.New("invalid commitment")
+	// Validate field commitment when the salts are known
+	if len(pht.FieldSalts) > 0 {
+		fields := hiddenFieldVector(pht.To(), pht.Value, pht.CallData, pht.TxType, pht.GasLimit)
+		root, err := p.vectorCommitment.Root(fields, pht.FieldSalts)
+		if err != nil || !constantTimeEqual(root, pht.FieldCommitment) {
+			return errors.New("invalid field commitment")
+		}
+	}
+	
+	// Validate the PHT is bound to this network
+	if p.config.ChainID != nil && bigOrZero(pht.ChainID).Cmp(p.config.ChainID) != 0 {
+		return fmt.Errorf("%w: PHT for chain %v, network is %v", ErrChainIDMismatch, pht.ChainID, p.config.ChainID)
+	}
+	
+	// Validate the field encoding is known; legacy PHTs remain valid
+	if err := pht.FieldEncoding.Validate(); err != nil {
+		return err
+	}
+	
+	// Validate the hidden field set of the network
+	if pht.HiddenSet.normalize() != p.hiddenSet {
+		return fmt.Errorf("%w: PHT hides %v, network hides %v", ErrInvalidHiddenFieldSet, pht.HiddenSet.Names(), p.hiddenSet.Names())
+	}
+	
+	// Validate the value cap without the hidden value
+	if limit := p.config.MaxPHTValue; limit != nil && limit.Sign() > 0 {
+		if err := VerifyValueRange(pht.ValueCommitment, pht.ValueRangeProof, limit); err != nil {
+			return err
+		}
+	}
+	if len(pht.ValueBlinding) > 0 && !VerifyValueCommitment(pht.ValueCommitment, pht.Value, pht.ValueBlinding) {
+		return errors.New("invalid value commitment")
+	}
+	
+	// Validate the time-lock puzzle is sized to the network's B1 interval
+	if timelock, ok := p.commitmentScheme.(*TimelockCommitment); ok {
+		if err := timelock.Verify(pht.TimelockPuzzle); err != nil {
+			return err
+		}
 	}
 	
 	// Validate nonce
 	if len(pht.Nonce) == 0 {
 		return errors.New("missing anti-MEV nonce")
 	}
+	if len(pht.NonceProof) > 0 || (p.config != nil && p.config.RequireNonceProofs) {
+		if err := p.VerifyNonce(pht); err != nil {
+			return err
+		}
+	}
 	
 	// Validate timestamp
 	if pht.Timestamp == 0 {
@@ -212,17 +562,135 @@ func (p *PHTManager) ValidatePHT(pht *PHTTransaction) error {
 	return nil
 }
 
-// VerifyCommitment verifies a commitment against revealed data
+// Opening returns the opening of a commitment created by this manager, for
+// transmission in the MT
+func (p *PHTManager) Opening(commitment []byte) (*CommitmentOpening, error) {
+	return p.openings.Open(commitment)
+}
+
+// ForgetOpening drops the opening of a revealed commitment
+func (p *PHTManager) ForgetOpening(commitment []byte) {
+	p.openings.Delete(commitment)
+}
+
+// ImportOpening stores an opening received from another node, such as the B1
+// proposer handing its PHTs to the B2 proposer, once it opens its commitment
+func (p *PHTManager) ImportOpening(opening *CommitmentOpening) error {
+	if err := opening.Verify(p.commitmentScheme); err != nil {
+		return err
+	}
+	p.openings.Put(opening)
+	return nil
+}
+
+// VerifyNonce checks that the anti-MEV nonce of a PHT is the VRF output of its
+// sender's key over the PHT's block context
+func (p *PHTManager) VerifyNonce(pht *PHTTransaction) error {
+	return p.antiMEVNonce.Verify(pht.Sender, pht.NonceContext, pht.TxHash, pht.Nonce, pht.NonceProof)
+}
+
+// VerifyCommitment verifies a commitment against revealed data of a transaction
+// without access list or blob fields
 func (p *PHTManager) VerifyCommitment(pht *PHTTransaction, recipient common.Address, value *big.Int, callData []byte, txType uint8, gasLimit uint64) bool {
-	hiddenData := [][]byte{
-		recipient.Bytes(),
-		value.Bytes(),
-		callData,
-		{txType},
-		{byte(gasLimit)},
+	fields := pht.hiddenFields()
+	fields.Recipient, fields.Value, fields.CallData, fields.TxType, fields.GasLimit = recipient, value, callData, txType, gasLimit
+	fields.FieldSalts, fields.AccessList, fields.BlobHashes, fields.MaxFeePerBlobGas = nil, nil, nil, nil
+	return p.VerifyHiddenFields(pht, fields)
+}
+
+// VerifyHiddenFields verifies a commitment against a full set of revealed hidden
+// fields, including blob fields, opened with the fields' blinding factor. The
+// visible account nonce is taken from the PHT.
+func (p *PHTManager) VerifyHiddenFields(pht *PHTTransaction, fields *HiddenFields) bool {
+	bound := *fields
+	bound.AccountNonce = pht.AccountNonce
+	hiddenData, err := hiddenCommitmentData(pht.HiddenSet, pht.FieldEncoding, &bound)
+	if err != nil {
+		return false
+	}
+	return p.commitmentScheme.Verify(pht.Commitment, fields.Blinding, hiddenData...)
+}
+
+// hiddenFields returns the hidden fields of a PHT
+func (pht *PHTTransaction) hiddenFields() *HiddenFields {
+	return &HiddenFields{
+		Recipient:        pht.Recipient,
+		IsContractCreation: pht.IsContractCreation,
+		AccountNonce:       pht.AccountNonce,
+		Value:            pht.Value,
+		CallData:         pht.CallData,
+		TxType:           pht.TxType,
+		GasLimit:         pht.GasLimit,
+		FieldSalts:       pht.FieldSalts,
+		Blinding:         pht.Blinding,
+		AccessList:       pht.AccessList,
+		BlobHashes:       pht.BlobHashes,
+		MaxFeePerBlobGas: pht.MaxFeePerBlobGas,
+		Sender:               pht.Sender,
+		GasPrice:             pht.GasPrice,
+		MaxFeePerGas:         pht.MaxFeePerGas,
+		MaxPriorityFeePerGas: pht.MaxPriorityFeePerGas,
+		ValueBlinding:        pht.ValueBlinding,
+		SignedTx:             pht.SignedTx,
+		RevealSignature:      pht.RevealSignature,
+	}
+}
+
+// WithoutHiddenFields returns a copy of a PHT carrying only its visible fields,
+// for submission by clients that must not disclose the hidden ones to the node
+func (pht *PHTTransaction) WithoutHiddenFields() *PHTTransaction {
+	public := *pht
+	public.stripHiddenFields()
+	return &public
+}
+
+// stripHiddenFields clears the hidden fields of a PHT, including the sender and
+// gas price on networks hiding them
+func (pht *PHTTransaction) stripHiddenFields() {
+	pht.Recipient = common.Address{}
+	pht.IsContractCreation = false
+	pht.Value = new(big.Int)
+	pht.CallData = nil
+	pht.TxType = 0
+	pht.GasLimit = 0
+	pht.FieldSalts = nil
+	pht.Blinding = nil
+	pht.AccessList = nil
+	pht.BlobHashes = nil
+	pht.MaxFeePerBlobGas = nil
+	pht.ValueBlinding = nil
+	pht.SignedTx = nil
+	pht.RevealSignature = nil
+	if pht.HiddenSet.Has(FieldSender) {
+		pht.Sender = common.Address{}
+	}
+	if pht.HiddenSet.Has(FieldGasPrice) {
+		pht.GasPrice = new(big.Int)
+		pht.MaxFeePerGas, pht.MaxPriorityFeePerGas = nil, nil
+	}
+}
+
+// OpenField creates an opening for a single hidden field of a PHT
+func (p *PHTManager) OpenField(pht *PHTTransaction, index int) (*FieldOpening, error) {
+	if len(pht.FieldSalts) == 0 {
+		return nil, errors.New("PHT has no field commitment salts")
 	}
 	
-	return p.commitmentScheme.Verify(pht.Commitment, hiddenData...)
+	fields := hiddenFieldVector(pht.To(), pht.Value, pht.CallData, pht.TxType, pht.GasLimit)
+	return p.vectorCommitment.OpenField(fields, pht.FieldSalts, index)
+}
+
+// VerifyFieldOpening verifies a single hidden field opening against a PHT's field commitment
+func (p *PHTManager) VerifyFieldOpening(pht *PHTTransaction, opening *FieldOpening) error {
+	if len(pht.FieldCommitment) == 0 {
+		return errors.New("PHT has no field commitment")
+	}
+	
+	if !p.vectorCommitment.VerifyField(pht.FieldCommitment, opening) {
+		return errors.New("invalid opening for field " + HiddenFieldName(opening.Index))
+	}
+	
+	return nil
 }
 
 // GetHiddenFields returns the hidden fields of a PHT
@@ -230,101 +698,354 @@ func (p *PHTManager) GetHiddenFields(pht *PHTTransaction) (common.Address, *big.
 	return pht.Recipient, pht.Value, pht.CallData, pht.TxType, pht.GasLimit
 }
 
-// Hash returns the hash of a PHT
+// phtHashDomain tags PHT hashes so they cannot collide with hashes of other objects
+const phtHashDomain = "P2S-PHT-v1"
+
+// phtHashRLP is the canonical hashed form of a PHT. It covers the visible fields
+// only, with hidden sender and gas price fields left zero, and binds the chain ID
+// so a PHT cannot be replayed on another network. The creation timestamp is local
+// to the creating node and not part of the identity of a PHT.
+type phtHashRLP struct {
+	Domain               string
+	ChainID              *big.Int
+	Sender               common.Address
+	GasPrice             *big.Int
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	Commitment           []byte
+	FieldCommitment      []byte
+	ValueCommitment      []byte
+	Nonce                []byte
+	HiddenSet            uint16
+	FieldEncoding        uint8  `rlp:"optional"` // Omitted for legacy PHTs so their hashes are unchanged
+	AccountNonce         uint64 `rlp:"optional"`
+}
+
+// Hash returns the canonical hash of a PHT: keccak256 over its domain-tagged,
+// RLP-encoded visible fields
 func (pht *PHTTransaction) Hash() common.Hash {
-	// Hash visible fields only
-	hasher := sha256.New()
-	hasher.Write(pht.Sender.Bytes())
-	hasher.Write(pht.GasPrice.Bytes())
-	hasher.Write(pht.Commitment)
-	hasher.Write(pht.Nonce)
-	
-	// Convert timestamp to bytes
-	timestampBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		timestampBytes[i] = byte(pht.Timestamp >> (8 * i))
+	enc := &phtHashRLP{
+		Domain:               phtHashDomain,
+		ChainID:              bigOrZero(pht.ChainID),
+		Sender:               pht.Sender,
+		GasPrice:             bigOrZero(pht.GasPrice),
+		MaxFeePerGas:         bigOrZero(pht.MaxFeePerGas),
+		MaxPriorityFeePerGas: bigOrZero(pht.MaxPriorityFeePerGas),
+		Commitment:           pht.Commitment,
+		FieldCommitment:      pht.FieldCommitment,
+		ValueCommitment:      pht.ValueCommitment,
+		Nonce:                pht.Nonce,
+		HiddenSet:            uint16(pht.HiddenSet.normalize()),
+		FieldEncoding:        uint8(pht.FieldEncoding),
+		AccountNonce:         pht.AccountNonce,
 	}
-	hasher.Write(timestampBytes)
-	
-	hash := hasher.Sum(nil)
-	return common.BytesToHash(hash)
+	if pht.HiddenSet.Has(FieldSender) {
+		enc.Sender = common.Address{}
+	}
+	if pht.HiddenSet.Has(FieldGasPrice) {
+		enc.GasPrice, enc.MaxFeePerGas, enc.MaxPriorityFeePerGas = new(big.Int), new(big.Int), new(big.Int)
+	}
+	data, err := rlp.EncodeToBytes(enc)
+	if err != nil {
+		// Encoding only fails for negative big integers, which no valid PHT has
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// To returns the recipient of a PHT, nil for contract creations
+func (pht *PHTTransaction) To() *common.Address {
+	if pht.IsContractCreation {
+		return nil
+	}
+	recipient := pht.Recipient
+	return &recipient
 }
 
-// ToTransaction converts a PHT back to a regular transaction
+// ToTransaction converts a PHT back to a regular transaction: the transaction the
+// sender signed when the PHT carries it, otherwise an unsigned one rebuilt from
+// its fields
 func (pht *PHTTransaction) ToTransaction() *types.Transaction {
+	if tx, err := decodeSignedTx(pht.SignedTx); err == nil {
+		return tx
+	}
+	
 	// Create transaction with revealed fields
 	var tx *types.Transaction
 	
 	if pht.TxType == types.LegacyTxType {
-		tx = types.NewTransaction(0, pht.Recipient, pht.Value, pht.GasLimit, pht.GasPrice, pht.CallData)
+		tx = newLegacyTransaction(pht.AccountNonce, pht.To(), pht.Value, pht.GasLimit, pht.GasPrice, pht.CallData)
+	} else if pht.TxType == types.BlobTxType {
+		tx = types.NewTx(&types.BlobTx{
+			Nonce:      pht.AccountNonce,
+			GasTipCap:  uint256.MustFromBig(bigOrZero(pht.MaxPriorityFeePerGas)),
+			GasFeeCap:  uint256.MustFromBig(bigOrZero(pht.MaxFeePerGas)),
+			Gas:        pht.GasLimit,
+			To:         pht.Recipient,
+			Value:      uint256.MustFromBig(bigOrZero(pht.Value)),
+			Data:       pht.CallData,
+			BlobFeeCap: uint256.MustFromBig(bigOrZero(pht.MaxFeePerBlobGas)),
+			AccessList: pht.AccessList,
+			BlobHashes: pht.BlobHashes,
+		})
+	} else if pht.MaxFeePerGas != nil {
+		tx = types.NewTx(&types.DynamicFeeTx{
+			Nonce:      pht.AccountNonce,
+			GasTipCap:  pht.MaxPriorityFeePerGas,
+			GasFeeCap:  pht.MaxFeePerGas,
+			Gas:        pht.GasLimit,
+			To:         pht.To(),
+			Value:      pht.Value,
+			Data:       pht.CallData,
+			AccessList: pht.AccessList,
+		})
+	} else if pht.TxType == types.AccessListTxType {
+		tx = types.NewTx(&types.AccessListTx{
+			Nonce:      pht.AccountNonce,
+			GasPrice:   pht.GasPrice,
+			Gas:        pht.GasLimit,
+			To:         pht.To(),
+			Value:      pht.Value,
+			Data:       pht.CallData,
+			AccessList: pht.AccessList,
+		})
 	} else {
 		// Handle other transaction types
-		tx = types.NewTransaction(0, pht.Recipient, pht.Value, pht.GasLimit, pht.GasPrice, pht.CallData)
+		tx = newLegacyTransaction(pht.AccountNonce, pht.To(), pht.Value, pht.GasLimit, pht.GasPrice, pht.CallData)
 	}
 	
 	return tx
 }
 
-// Serialize serializes a PHT to bytes
-func (pht *PHTTransaction) Serialize() ([]byte, error) {
-	// Simple serialization - in production, use proper encoding
-	data := make([]byte, 0)
+// newLegacyTransaction creates a legacy transaction, a contract creation for a nil recipient
+func newLegacyTransaction(nonce uint64, to *common.Address, value *big.Int, gasLimit uint64, gasPrice *big.Int, data []byte) *types.Transaction {
+	if to == nil {
+		return types.NewContractCreation(nonce, value, gasLimit, gasPrice, data)
+	}
+	return types.NewTransaction(nonce, *to, value, gasLimit, gasPrice, data)
+}
+
+// EffectiveGasTip returns the tip per gas a PHT pays over the given base fee, the
+// gas price above the base fee for legacy PHTs
+func (pht *PHTTransaction) EffectiveGasTip(baseFee *big.Int) *big.Int {
+	feeCap := pht.GasPrice
+	if pht.MaxFeePerGas != nil {
+		feeCap = pht.MaxFeePerGas
+	}
+	tip := new(big.Int).Set(bigOrZero(feeCap))
+	if baseFee != nil {
+		tip.Sub(tip, baseFee)
+	}
+	if pht.MaxPriorityFeePerGas != nil && tip.Cmp(pht.MaxPriorityFeePerGas) > 0 {
+		tip.Set(pht.MaxPriorityFeePerGas)
+	}
+	return tip
+}
+
+// bigOrZero returns n, or zero for nil
+func bigOrZero(n *big.Int) *big.Int {
+	if n == nil {
+		return new(big.Int)
+	}
+	return n
+}
+
+// PHTEncodingVersion is the version written in RLP-encoded PHTs. Later versions only
+// append fields, so decoders skip trailing fields they do not know.
+const PHTEncodingVersion = 13
+
+// phtRLP is the RLP layout of a PHT
+type phtRLP struct {
+	Version         uint64
+	Sender          common.Address
+	GasPrice        *big.Int
+	Commitment      []byte
+	Nonce           []byte
+	Timestamp       uint64
+	FieldCommitment []byte
+	Recipient       common.Address
+	Value           *big.Int
+	CallData        []byte
+	TxType          uint8
+	GasLimit        uint64
+	FieldSalts      [][]byte
+	Blinding        []byte
+	TxHash          common.Hash
+	EncryptedFields *EncryptedFields `rlp:"nil"`
+	TimelockPuzzle  *TimelockPuzzle  `rlp:"nil"`
 	
-	// Add sender
-	data = append(data, pht.Sender.Bytes()...)
+	// Version 2
+	MaxFeePerGas         *big.Int       `rlp:"optional"`
+	MaxPriorityFeePerGas *big.Int       `rlp:"optional"`
 	
-	// Add gas price
-	data = append(data, pht.GasPrice.Bytes()...)
+	// Version 3
+	BlobHashes       []common.Hash `rlp:"optional"`
+	MaxFeePerBlobGas *big.Int      `rlp:"optional"`
 	
-	// Add commitment
-	data = append(data, pht.Commitment...)
+	// Version 4
+	AccessList types.AccessList `rlp:"optional"`
 	
-	// Add nonce
-	data = append(data, pht.Nonce...)
+	// Version 5
+	NonceContext common.Hash `rlp:"optional"`
+	NonceProof   []byte      `rlp:"optional"`
 	
-	// Add timestamp
-	timestampBytes := make([]byte, 8)
-	for i := 0; i < 8; i++ {
-		timestampBytes[i] = byte(pht.Timestamp >> (8 * i))
-	}
-	data = append(data, timestampBytes...)
+	// Version 6
+	HiddenSet uint16 `rlp:"optional"`
 	
-	return data, nil
-}
-
-// Deserialize deserializes bytes to a PHT
-func (pht *PHTTransaction) Deserialize(data []byte) error {
-	if len(data) < 20+32+32+32+8 { // Minimum required bytes
-		return errors.New("insufficient data")
-	}
+	// Version 7, the range proof RLP-encoded so it can be omitted
+	ValueCommitment []byte `rlp:"optional"`
+	ValueBlinding   []byte `rlp:"optional"`
+	ValueRangeProof []byte `rlp:"optional"`
+	
+	// Version 8
+	ChainID *big.Int `rlp:"optional"`
 	
-	offset := 0
+	// Version 9
+	FieldEncoding uint8 `rlp:"optional"`
 	
-	// Deserialize sender
-	pht.Sender = common.BytesToAddress(data[offset : offset+20])
-	offset += 20
+	// Version 10
+	IsContractCreation bool `rlp:"optional"`
 	
-	// Deserialize gas price
-	pht.GasPrice = new(big.Int).SetBytes(data[offset : offset+32])
-	offset += 32
+	// Version 11
+	AccountNonce uint64 `rlp:"optional"`
 	
-	// Deserialize commitment
-	pht.Commitment = make([]byte, 32)
-	copy(pht.Commitment, data[offset:offset+32])
-	offset += 32
+	// Version 12
+	SignedTx []byte `rlp:"optional"`
 	
-	// Deserialize nonce
-	pht.Nonce = make([]byte, 32)
-	copy(pht.Nonce, data[offset:offset+32])
-	offset += 32
+	// Version 13
+	RevealSignature []byte `rlp:"optional"`
 	
-	// Deserialize timestamp
-	pht.Timestamp = 0
-	for i := 0; i < 8; i++ {
-		pht.Timestamp |= uint64(data[offset+i]) << (8 * i)
+	Rest []rlp.RawValue `rlp:"tail"` // Fields of later versions
+}
+
+// EncodeRLP implements rlp.Encoder, encoding every PHT field including the hidden ones
+func (pht *PHTTransaction) EncodeRLP(w io.Writer) error {
+	var rangeProof []byte
+	if pht.ValueRangeProof != nil {
+		var err error
+		if rangeProof, err = rlp.EncodeToBytes(pht.ValueRangeProof); err != nil {
+			return err
+		}
+	}
+	return rlp.Encode(w, &phtRLP{
+		Version:         PHTEncodingVersion,
+		Sender:          pht.Sender,
+		GasPrice:        pht.GasPrice,
+		Commitment:      pht.Commitment,
+		Nonce:           pht.Nonce,
+		Timestamp:       pht.Timestamp,
+		FieldCommitment: pht.FieldCommitment,
+		Recipient:       pht.Recipient,
+		Value:           pht.Value,
+		CallData:        pht.CallData,
+		TxType:          pht.TxType,
+		GasLimit:        pht.GasLimit,
+		FieldSalts:      pht.FieldSalts,
+		Blinding:        pht.Blinding,
+		TxHash:          pht.TxHash,
+		EncryptedFields: pht.EncryptedFields,
+		TimelockPuzzle:  pht.TimelockPuzzle,
+		MaxFeePerGas:         pht.MaxFeePerGas,
+		MaxPriorityFeePerGas: pht.MaxPriorityFeePerGas,
+		BlobHashes:           pht.BlobHashes,
+		MaxFeePerBlobGas:     pht.MaxFeePerBlobGas,
+		AccessList:           pht.AccessList,
+		NonceContext:         pht.NonceContext,
+		NonceProof:           pht.NonceProof,
+		HiddenSet:            uint16(pht.HiddenSet),
+		ValueCommitment:      pht.ValueCommitment,
+		ValueBlinding:        pht.ValueBlinding,
+		ValueRangeProof:      rangeProof,
+		ChainID:              pht.ChainID,
+		FieldEncoding:        uint8(pht.FieldEncoding),
+		IsContractCreation:   pht.IsContractCreation,
+		AccountNonce:         pht.AccountNonce,
+		SignedTx:             pht.SignedTx,
+		RevealSignature:      pht.RevealSignature,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder
+func (pht *PHTTransaction) DecodeRLP(s *rlp.Stream) error {
+	var dec phtRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	if dec.Version == 0 {
+		return errors.New("invalid PHT encoding version")
 	}
 	
-	return nil
+	*pht = PHTTransaction{
+		Sender:          dec.Sender,
+		GasPrice:        dec.GasPrice,
+		Commitment:      dec.Commitment,
+		Nonce:           dec.Nonce,
+		Timestamp:       dec.Timestamp,
+		FieldCommitment: dec.FieldCommitment,
+		Recipient:       dec.Recipient,
+		Value:           dec.Value,
+		CallData:        dec.CallData,
+		TxType:          dec.TxType,
+		GasLimit:        dec.GasLimit,
+		FieldSalts:      dec.FieldSalts,
+		Blinding:        dec.Blinding,
+		EncryptedFields: dec.EncryptedFields,
+		TimelockPuzzle:  dec.TimelockPuzzle,
+		TxHash:          dec.TxHash,
+		NonceContext:    dec.NonceContext,
+		HiddenSet:       HiddenFieldSet(dec.HiddenSet),
+		FieldEncoding:   FieldEncoding(dec.FieldEncoding),
+		IsContractCreation: dec.IsContractCreation,
+		AccountNonce:    dec.AccountNonce,
+	}
+	if dec.ChainID != nil && dec.ChainID.Sign() > 0 {
+		pht.ChainID = dec.ChainID
+	}
+	if len(dec.ValueCommitment) > 0 {
+		pht.ValueCommitment = dec.ValueCommitment
+		pht.ValueBlinding = dec.ValueBlinding
+	}
+	if len(dec.ValueRangeProof) > 0 {
+		pht.ValueRangeProof = new(RangeProof)
+		if err := rlp.DecodeBytes(dec.ValueRangeProof, pht.ValueRangeProof); err != nil {
+			return err
+		}
+	}
+	// Absent fee caps decode as zero, legacy PHTs keep them nil
+	if dec.MaxFeePerGas != nil && dec.MaxFeePerGas.Sign() > 0 {
+		pht.MaxFeePerGas = dec.MaxFeePerGas
+		pht.MaxPriorityFeePerGas = dec.MaxPriorityFeePerGas
+	}
+	if len(dec.BlobHashes) > 0 {
+		pht.BlobHashes = dec.BlobHashes
+		pht.MaxFeePerBlobGas = dec.MaxFeePerBlobGas
+	}
+	if len(dec.AccessList) > 0 {
+		pht.AccessList = dec.AccessList
+	}
+	if len(dec.NonceProof) > 0 {
+		pht.NonceProof = dec.NonceProof
+	}
+	if len(dec.SignedTx) > 0 {
+		pht.SignedTx = dec.SignedTx
+	}
+	if len(dec.RevealSignature) > 0 {
+		pht.RevealSignature = dec.RevealSignature
+	}
+	if err := pht.HiddenSet.Validate(); err != nil {
+		return err
+	}
+	return pht.FieldEncoding.Validate()
+}
+
+// Serialize encodes a PHT with RLP
+func (pht *PHTTransaction) Serialize() ([]byte, error) {
+	return rlp.EncodeToBytes(pht)
+}
+
+// Deserialize decodes an RLP-encoded PHT
+func (pht *PHTTransaction) Deserialize(data []byte) error {
+	return rlp.DecodeBytes(data, pht)
 }
 
 // GetMEVScore calculates MEV score for a PHT
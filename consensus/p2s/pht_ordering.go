@@ -0,0 +1,82 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrNonceOrder is returned for B1 blocks whose PHTs of one sender do not run in
+// consecutive account nonce order
+var ErrNonceOrder = errors.New("PHTs out of account nonce order")
+
+// OrderPHTs arranges PHTs for a B1 block so each sender's PHTs run in consecutive
+// ascending account nonce order. Senders keep the positions their PHTs held, so
+// the relative priority of senders is unchanged. Of PHTs sharing a nonce the one
+// with the highest gas price is kept. PHTs below the sender's account nonce in
+// state, or after a gap, cannot execute and are returned as dropped; without
+// state each sender's run starts at its lowest nonce. PHTs whose sender is
+// hidden keep their position.
+func OrderPHTs(phts []*PHTTransaction, state StateReader) (ordered, dropped []*PHTTransaction) {
+	slots := make([]*PHTTransaction, len(phts))
+	positions := make(map[common.Address][]int)
+	bySender := make(map[common.Address][]*PHTTransaction)
+	for i, pht := range phts {
+		if pht.Sender == (common.Address{}) {
+			slots[i] = pht
+			continue
+		}
+		positions[pht.Sender] = append(positions[pht.Sender], i)
+		bySender[pht.Sender] = append(bySender[pht.Sender], pht)
+	}
+
+	for sender, senderPHTs := range bySender {
+		sort.SliceStable(senderPHTs, func(i, j int) bool {
+			if senderPHTs[i].AccountNonce != senderPHTs[j].AccountNonce {
+				return senderPHTs[i].AccountNonce < senderPHTs[j].AccountNonce
+			}
+			return bigOrZero(senderPHTs[i].GasPrice).Cmp(bigOrZero(senderPHTs[j].GasPrice)) > 0
+		})
+
+		next := senderPHTs[0].AccountNonce
+		if state != nil {
+			next = state.GetNonce(sender)
+		}
+		kept := 0
+		for _, pht := range senderPHTs {
+			if pht.AccountNonce != next {
+				dropped = append(dropped, pht)
+				continue
+			}
+			slots[positions[sender][kept]] = pht
+			kept++
+			next++
+		}
+	}
+
+	ordered = make([]*PHTTransaction, 0, len(phts)-len(dropped))
+	for _, pht := range slots {
+		if pht != nil {
+			ordered = append(ordered, pht)
+		}
+	}
+	return ordered, dropped
+}
+
+// CheckNonceOrder checks that each sender's PHTs in a B1 block run in consecutive
+// ascending account nonce order. PHTs whose sender is hidden are not checked.
+func CheckNonceOrder(phts []*PHTTransaction) error {
+	last := make(map[common.Address]uint64)
+	for i, pht := range phts {
+		if pht.Sender == (common.Address{}) {
+			continue
+		}
+		if prev, seen := last[pht.Sender]; seen && pht.AccountNonce != prev+1 {
+			return fmt.Errorf("%w: PHT %d of %s has nonce %d after %d", ErrNonceOrder, i, pht.Sender.Hex(), pht.AccountNonce, prev)
+		}
+		last[pht.Sender] = pht.AccountNonce
+	}
+	return nil
+}
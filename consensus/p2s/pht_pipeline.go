@@ -0,0 +1,140 @@
+package p2s
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// PHTValidationResult is the outcome of validating one PHT in a pipeline
+type PHTValidationResult struct {
+	PHT *PHTTransaction
+	Err error // Rejection reason, nil if the PHT was accepted
+}
+
+// Accepted reports whether the PHT passed validation
+func (r *PHTValidationResult) Accepted() bool {
+	return r.Err == nil
+}
+
+// PHTPipeline validates streams of PHTs on a worker pool. Each PHT is checked with
+// ValidatePHT, covering its commitments, value range proof and the VRF nonce proof
+// binding it to its sender's key. Results are emitted in input order, and at most
+// one PHT per worker is in flight, so a slow consumer stalls the producer instead
+// of growing a backlog.
+type PHTPipeline struct {
+	manager *PHTManager
+	workers int
+}
+
+// NewPHTPipeline creates a validation pipeline over a PHT manager, sized by the
+// PHTWorkers configuration
+func NewPHTPipeline(manager *PHTManager) *PHTPipeline {
+	workers := runtime.NumCPU()
+	if manager.config != nil && manager.config.PHTWorkers > 0 {
+		workers = manager.config.PHTWorkers
+	}
+
+	return &PHTPipeline{
+		manager: manager,
+		workers: workers,
+	}
+}
+
+// Run validates the PHTs received on in until it is closed or the context is
+// cancelled. The returned channel yields one result per PHT read, in order, and
+// is closed once all of them were emitted or the context was cancelled.
+func (v *PHTPipeline) Run(ctx context.Context, in <-chan *PHTTransaction) <-chan PHTValidationResult {
+	out := make(chan PHTValidationResult)
+	jobs := make(chan func())
+
+	// Pending results in input order; its capacity bounds the PHTs in flight
+	pending := make(chan chan PHTValidationResult, v.workers)
+
+	for w := 0; w < v.workers; w++ {
+		go func() {
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for {
+			var pht *PHTTransaction
+			select {
+			case <-ctx.Done():
+				return
+			case next, ok := <-in:
+				if !ok {
+					return
+				}
+				pht = next
+			}
+
+			result := make(chan PHTValidationResult, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case pending <- result:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- func() { result <- PHTValidationResult{PHT: pht, Err: v.manager.ValidatePHT(pht)} }:
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		for result := range pending {
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-result:
+				select {
+				case <-ctx.Done():
+					return
+				case out <- r:
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// ValidatePHTs validates a batch of PHTs through a pipeline, returning the first
+// rejection in batch order
+func (v *PHTPipeline) ValidatePHTs(ctx context.Context, phts []*PHTTransaction) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan *PHTTransaction)
+	go func() {
+		defer close(in)
+		for _, pht := range phts {
+			select {
+			case <-ctx.Done():
+				return
+			case in <- pht:
+			}
+		}
+	}()
+
+	validated := 0
+	for result := range v.Run(ctx, in) {
+		if !result.Accepted() {
+			return fmt.Errorf("PHT %d (%s): %w", validated, result.PHT.TxHash.Hex(), result.Err)
+		}
+		validated++
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,255 @@
+package p2s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// defaultPHTReceiptLimit is the number of PHT receipts retained
+const defaultPHTReceiptLimit = 100000
+
+// PHTStatus is a stage in the lifecycle of a PHT
+type PHTStatus string
+
+// PHT lifecycle stages. A PHT moves forward through submitted, included and
+// revealed to executed or failed; a pending PHT may instead be superseded by a
+// replacement, and an included PHT defaults if it is not revealed in time.
+const (
+	PHTStatusSubmitted  PHTStatus = "submitted"
+	PHTStatusSuperseded PHTStatus = "superseded"
+	PHTStatusIncluded   PHTStatus = "included"
+	PHTStatusRevealed   PHTStatus = "revealed"
+	PHTStatusExecuted   PHTStatus = "executed"
+	PHTStatusFailed     PHTStatus = "failed"
+	PHTStatusDefaulted  PHTStatus = "defaulted"
+)
+
+// phtStatusRank orders statuses so late updates cannot move a receipt backwards
+var phtStatusRank = map[PHTStatus]int{
+	PHTStatusSubmitted:  0,
+	PHTStatusSuperseded: 1,
+	PHTStatusIncluded:   1,
+	PHTStatusRevealed:   2,
+	PHTStatusExecuted:   3,
+	PHTStatusFailed:     3,
+	PHTStatusDefaulted:  2,
+}
+
+// PHTReceipt reports where a hidden transaction stands, for wallets
+type PHTReceipt struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Sender       common.Address `json:"sender"`
+	Status       PHTStatus      `json:"status"`
+	SupersededBy *common.Hash   `json:"supersededBy,omitempty"`
+	B1Number     uint64         `json:"b1Number,omitempty"`
+	B1Hash       common.Hash    `json:"b1Hash,omitempty"`
+	B2Number     uint64         `json:"b2Number,omitempty"`
+	B2Hash       common.Hash    `json:"b2Hash,omitempty"`
+	GasUsed      uint64         `json:"gasUsed,omitempty"`
+
+	// Address of the contract a contract creation deployed, once executed
+	ContractAddress *common.Address `json:"contractAddress,omitempty"`
+	SubmittedAt     uint64          `json:"submittedAt"`
+	UpdatedAt       uint64          `json:"updatedAt"`
+}
+
+// PHTReceiptStore keeps the lifecycle receipts of recent PHTs, indexed by
+// transaction hash and by sender
+type PHTReceiptStore struct {
+	receipts map[common.Hash]*PHTReceipt
+	bySender map[common.Address][]common.Hash
+	order    []common.Hash // Transaction hashes in submission order, oldest first
+	limit    int
+	mu       sync.RWMutex
+}
+
+// NewPHTReceiptStore creates a store retaining at most limit receipts
+func NewPHTReceiptStore(limit int) *PHTReceiptStore {
+	if limit <= 0 {
+		limit = defaultPHTReceiptLimit
+	}
+
+	return &PHTReceiptStore{
+		receipts: make(map[common.Hash]*PHTReceipt),
+		bySender: make(map[common.Address][]common.Hash),
+		limit:    limit,
+	}
+}
+
+// Submitted records PHTs entering the pool
+func (s *PHTReceiptStore) Submitted(phts []*PHTTransaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pht := range phts {
+		s.receipt(pht)
+	}
+}
+
+// Superseded records a pending PHT replaced by a higher-priced one
+func (s *PHTReceiptStore) Superseded(old, replacement *PHTTransaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.receipt(replacement)
+	receipt := s.receipt(old)
+	if s.advance(receipt, PHTStatusSuperseded) {
+		by := replacement.TxHash
+		receipt.SupersededBy = &by
+	}
+}
+
+// Included records the PHTs of a B1 block
+func (s *PHTReceiptStore) Included(number uint64, hash common.Hash, phts []*PHTTransaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pht := range phts {
+		receipt := s.receipt(pht)
+		if s.advance(receipt, PHTStatusIncluded) {
+			receipt.B1Number, receipt.B1Hash = number, hash
+		}
+	}
+}
+
+// Revealed records the MTs of a B2 block and the execution outcome of any
+// transaction with a receipt
+func (s *PHTReceiptStore) Revealed(number uint64, hash common.Hash, mts []*MTTransaction, receipts []*types.Receipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creations := make(map[common.Hash]bool)
+	for _, mt := range mts {
+		if mt.Unrevealed {
+			continue
+		}
+		if mt.IsContractCreation {
+			creations[mt.TxHash] = true
+		}
+		receipt, exists := s.receipts[mt.TxHash]
+		if !exists {
+			continue
+		}
+		if s.advance(receipt, PHTStatusRevealed) {
+			receipt.B2Number, receipt.B2Hash = number, hash
+		}
+	}
+	for _, executed := range receipts {
+		receipt, exists := s.receipts[executed.TxHash]
+		if !exists {
+			continue
+		}
+		status := PHTStatusExecuted
+		if executed.Status == types.ReceiptStatusFailed {
+			status = PHTStatusFailed
+		}
+		if s.advance(receipt, status) {
+			receipt.GasUsed = executed.GasUsed
+			// The state processor derives it from the sender and account nonce
+			if creations[executed.TxHash] && status == PHTStatusExecuted {
+				address := executed.ContractAddress
+				receipt.ContractAddress = &address
+			}
+		}
+	}
+}
+
+// Defaulted records PHTs that missed their reveal deadline
+func (s *PHTReceiptStore) Defaulted(missed []*MissedReveal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, reveal := range missed {
+		if receipt, exists := s.receipts[reveal.TxHash]; exists {
+			s.advance(receipt, PHTStatusDefaulted)
+		}
+	}
+}
+
+// Receipt returns the receipt of a PHT
+func (s *PHTReceiptStore) Receipt(txHash common.Hash) (*PHTReceipt, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	receipt, exists := s.receipts[txHash]
+	if !exists {
+		return nil, false
+	}
+	result := *receipt
+	return &result, true
+}
+
+// BySender returns the receipts of a sender's PHTs, oldest first
+func (s *PHTReceiptStore) BySender(sender common.Address) []*PHTReceipt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*PHTReceipt, 0, len(s.bySender[sender]))
+	for _, txHash := range s.bySender[sender] {
+		receipt := *s.receipts[txHash]
+		result = append(result, &receipt)
+	}
+	return result
+}
+
+// receipt returns the receipt of a PHT, creating a submitted one if untracked.
+// The caller must hold the lock.
+func (s *PHTReceiptStore) receipt(pht *PHTTransaction) *PHTReceipt {
+	if receipt, exists := s.receipts[pht.TxHash]; exists {
+		return receipt
+	}
+	now := uint64(time.Now().Unix())
+	receipt := &PHTReceipt{
+		TxHash:      pht.TxHash,
+		Sender:      pht.Sender,
+		Status:      PHTStatusSubmitted,
+		SubmittedAt: now,
+		UpdatedAt:   now,
+	}
+	s.receipts[pht.TxHash] = receipt
+	s.bySender[pht.Sender] = append(s.bySender[pht.Sender], pht.TxHash)
+	s.order = append(s.order, pht.TxHash)
+	s.prune()
+	return receipt
+}
+
+// advance moves a receipt forward to status, reporting whether it moved. The
+// caller must hold the lock.
+func (s *PHTReceiptStore) advance(receipt *PHTReceipt, status PHTStatus) bool {
+	if phtStatusRank[status] <= phtStatusRank[receipt.Status] {
+		return false
+	}
+	receipt.Status = status
+	receipt.UpdatedAt = uint64(time.Now().Unix())
+	return true
+}
+
+// prune drops the oldest receipts beyond the limit. The caller must hold the lock.
+func (s *PHTReceiptStore) prune() {
+	for len(s.order) > s.limit {
+		txHash := s.order[0]
+		s.order = s.order[1:]
+
+		receipt, exists := s.receipts[txHash]
+		if !exists {
+			continue
+		}
+		delete(s.receipts, txHash)
+
+		hashes := s.bySender[receipt.Sender]
+		for i, hash := range hashes {
+			if hash == txHash {
+				hashes = append(hashes[:i], hashes[i+1:]...)
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			delete(s.bySender, receipt.Sender)
+		} else {
+			s.bySender[receipt.Sender] = hashes
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PHT replacement errors
+var (
+	ErrReplacementUnderpriced = errors.New("replacement PHT underpriced")
+	ErrReplacementSender      = errors.New("replacement PHT from a different sender")
+	ErrReplacementNonce       = errors.New("replacement PHT with a different account nonce")
+	ErrAlreadySuperseded      = errors.New("PHT already superseded")
+	ErrPHTIncluded            = errors.New("PHT already included in a B1 block")
+	ErrStaleReveal            = errors.New("reveal of a superseded PHT")
+)
+
+// phtReplacements tracks which pending PHTs were superseded by a replacement with
+// a higher gas price, and which were included in a B1 block. Included PHTs await
+// their reveal and can no longer be replaced.
+type phtReplacements struct {
+	supersededBy map[common.Hash]common.Hash // Superseded PHT to its replacement
+	replaced     map[common.Hash]common.Hash // Replacement to the PHT it superseded
+	included     map[common.Hash]struct{}
+	mu           sync.RWMutex
+}
+
+// newPHTReplacements creates an empty replacement tracker
+func newPHTReplacements() *phtReplacements {
+	return &phtReplacements{
+		supersededBy: make(map[common.Hash]common.Hash),
+		replaced:     make(map[common.Hash]common.Hash),
+		included:     make(map[common.Hash]struct{}),
+	}
+}
+
+// ReplacePHT supersedes a pending PHT with a replacement from the same sender and
+// account nonce. The replacement must raise the gas price, and the tip for dynamic-fee PHTs, by
+// at least the configured PHTPriceBump percentage. Reveals of the superseded PHT
+// are rejected from then on.
+func (p *PHTManager) ReplacePHT(old, replacement *PHTTransaction) error {
+	if old.Sender != replacement.Sender {
+		return ErrReplacementSender
+	}
+	if old.AccountNonce != replacement.AccountNonce {
+		return fmt.Errorf("%w: %d, replacing %d", ErrReplacementNonce, replacement.AccountNonce, old.AccountNonce)
+	}
+	if old.TxHash == replacement.TxHash {
+		return errors.New("PHT cannot replace itself")
+	}
+	if !priceBumped(old.GasPrice, replacement.GasPrice, p.config.PHTPriceBump) {
+		return fmt.Errorf("%w: gas price %v, need %d%% over %v", ErrReplacementUnderpriced, replacement.GasPrice, p.config.PHTPriceBump, old.GasPrice)
+	}
+	if old.MaxPriorityFeePerGas != nil && !priceBumped(old.MaxPriorityFeePerGas, tipCap(replacement), p.config.PHTPriceBump) {
+		return fmt.Errorf("%w: tip %v, need %d%% over %v", ErrReplacementUnderpriced, tipCap(replacement), p.config.PHTPriceBump, old.MaxPriorityFeePerGas)
+	}
+
+	r := p.replacements
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hash := range []common.Hash{old.TxHash, replacement.TxHash} {
+		if _, included := r.included[hash]; included {
+			return fmt.Errorf("%w: %s", ErrPHTIncluded, hash.Hex())
+		}
+		if by, superseded := r.supersededBy[hash]; superseded {
+			return fmt.Errorf("%w: %s by %s", ErrAlreadySuperseded, hash.Hex(), by.Hex())
+		}
+	}
+	r.supersededBy[old.TxHash] = replacement.TxHash
+	r.replaced[replacement.TxHash] = old.TxHash
+	return nil
+}
+
+// SupersededBy returns the replacement of a superseded PHT
+func (p *PHTManager) SupersededBy(txHash common.Hash) (common.Hash, bool) {
+	p.replacements.mu.RLock()
+	defer p.replacements.mu.RUnlock()
+
+	by, superseded := p.replacements.supersededBy[txHash]
+	return by, superseded
+}
+
+// IncludePHTs drops superseded PHTs from a B1 block candidate and records the
+// rest as included, so they can no longer be replaced before their reveal
+func (p *PHTManager) IncludePHTs(phts []*PHTTransaction) []*PHTTransaction {
+	r := p.replacements
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	included := phts[:0:0]
+	for _, pht := range phts {
+		if _, superseded := r.supersededBy[pht.TxHash]; superseded {
+			continue
+		}
+		r.included[pht.TxHash] = struct{}{}
+		included = append(included, pht)
+	}
+	return included
+}
+
+// ReleasePHTs makes PHTs left out of a B1 block after inclusion replaceable again
+func (p *PHTManager) ReleasePHTs(txHashes []common.Hash) {
+	r := p.replacements
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hash := range txHashes {
+		delete(r.included, hash)
+	}
+}
+
+// CheckReveal returns ErrStaleReveal if the PHT was superseded
+func (p *PHTManager) CheckReveal(txHash common.Hash) error {
+	if by, superseded := p.SupersededBy(txHash); superseded {
+		return fmt.Errorf("%w: %s replaced by %s", ErrStaleReveal, txHash.Hex(), by.Hex())
+	}
+	return nil
+}
+
+// ForgetPHTs drops replacement state of revealed PHTs together with the chains
+// of PHTs they superseded
+func (p *PHTManager) ForgetPHTs(txHashes []common.Hash) {
+	r := p.replacements
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hash := range txHashes {
+		delete(r.included, hash)
+		for {
+			old, ok := r.replaced[hash]
+			if !ok {
+				break
+			}
+			delete(r.replaced, hash)
+			delete(r.supersededBy, old)
+			hash = old
+		}
+	}
+}
+
+// priceBumped reports whether next is at least bump percent above prev
+func priceBumped(prev, next *big.Int, bump uint64) bool {
+	if next == nil {
+		return false
+	}
+	threshold := new(big.Int).Mul(bigOrZero(prev), new(big.Int).SetUint64(100+bump))
+	return new(big.Int).Mul(next, big.NewInt(100)).Cmp(threshold) >= 0
+}
+
+// tipCap returns the priority fee a PHT pays at most, its gas price for legacy PHTs
+func tipCap(pht *PHTTransaction) *big.Int {
+	if pht.MaxPriorityFeePerGas != nil {
+		return pht.MaxPriorityFeePerGas
+	}
+	return pht.GasPrice
+}
@@ -0,0 +1,342 @@
+package p2s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// defaultPrivacyBudget is the number of aggregate disclosures allowed per B1 slot
+const defaultPrivacyBudget = 16
+
+// ErrHiddenFieldLeak is returned when an export path would expose a hidden field before reveal
+var ErrHiddenFieldLeak = errors.New("hidden PHT field exposed before reveal")
+
+// quantityToken matches the decimal and 0x-prefixed hex scalars of a payload
+var quantityToken = regexp.MustCompile(`\b(?:0[xX][0-9a-fA-F]+|[0-9]+(?:\.[0-9]+)?)\b`)
+
+// RedactedPHT is the view of an unrevealed PHT that may leave the proposer
+type RedactedPHT struct {
+	TxHash     common.Hash    `json:"txHash"`
+	Sender     common.Address `json:"sender"`
+	Commitment []byte         `json:"commitment"`
+	Timestamp  uint64         `json:"timestamp"`
+}
+
+// B1BlockExport is the exportable view of a B1 block
+type B1BlockExport struct {
+	BlockHash       common.Hash   `json:"blockHash"`
+	Timestamp       uint64        `json:"timestamp"`
+	MEVScore        float64       `json:"mevScore"`
+	DetectedAttacks []string      `json:"detectedAttacks"`
+	PHTs            []interface{} `json:"phts"`
+}
+
+// PendingPHTSummary contains aggregate information about unrevealed PHTs
+type PendingPHTSummary struct {
+	Count   int `json:"count"`
+	Senders int `json:"senders"`
+}
+
+// PrivacyGuard limits what the proposer's logs and APIs expose about unrevealed PHTs.
+// Every export path for PHT data must go through the guard, which redacts hidden
+// fields and per-PHT fee and calldata size until the PHT is revealed in B2.
+type PrivacyGuard struct {
+	revealed map[common.Hash]uint64 // Revealed PHTs to the number of the revealing block
+	order    []revealedPHT          // Revealed PHTs in reveal order, oldest first
+	window   uint64
+	head     uint64
+
+	// Aggregate disclosure budget per B1 slot
+	budget    int
+	disclosed int
+
+	mu sync.RWMutex
+}
+
+// revealedPHT is a PHT revealed in the B2 block number
+type revealedPHT struct {
+	hash   common.Hash
+	number uint64
+}
+
+// NewPrivacyGuard creates a privacy guard allowing budget aggregate disclosures per
+// slot and remembering reveals of the last window blocks, 0 for the default
+func NewPrivacyGuard(budget int, window uint64) *PrivacyGuard {
+	if window == 0 {
+		window = defaultRevealIndexWindow
+	}
+
+	return &PrivacyGuard{
+		revealed: make(map[common.Hash]uint64),
+		window:   window,
+		budget:   budget,
+	}
+}
+
+// MarkRevealed marks a PHT as revealed in the B2 block number. Reveals older
+// than the retention window are forgotten.
+func (g *PrivacyGuard) MarkRevealed(hash common.Hash, number uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, exists := g.revealed[hash]; !exists {
+		g.order = append(g.order, revealedPHT{hash: hash, number: number})
+	}
+	g.revealed[hash] = number
+	if number > g.head {
+		g.head = number
+	}
+	g.prune()
+}
+
+// prune forgets reveals that have left the retention window. The caller must hold the lock.
+func (g *PrivacyGuard) prune() {
+	if g.head < g.window {
+		return
+	}
+	oldest := g.head - g.window + 1
+
+	drop := 0
+	for drop < len(g.order) && g.order[drop].number < oldest {
+		delete(g.revealed, g.order[drop].hash)
+		drop++
+	}
+	if drop > 0 {
+		g.order = append(g.order[:0:0], g.order[drop:]...)
+	}
+}
+
+// IsRevealed checks if a PHT has been revealed
+func (g *PrivacyGuard) IsRevealed(hash common.Hash) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	_, revealed := g.revealed[hash]
+	return revealed
+}
+
+// ResetBudget resets the aggregate disclosure budget at the start of a slot
+func (g *PrivacyGuard) ResetBudget() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.disclosed = 0
+}
+
+// ExportPHT returns the representation of a PHT that may be exported
+func (g *PrivacyGuard) ExportPHT(pht *PHTTransaction) interface{} {
+	if g.IsRevealed(pht.TxHash) {
+		return pht
+	}
+
+	return &RedactedPHT{
+		TxHash:     pht.TxHash,
+		Sender:     pht.Sender,
+		Commitment: pht.Commitment,
+		Timestamp:  pht.Timestamp,
+	}
+}
+
+// ExportReceipt returns the view of a PHT receipt that may be exported. Execution
+// details are withheld until the PHT is revealed.
+func (g *PrivacyGuard) ExportReceipt(receipt *PHTReceipt) *PHTReceipt {
+	if receipt == nil || g.IsRevealed(receipt.TxHash) {
+		return receipt
+	}
+
+	redacted := *receipt
+	redacted.GasUsed = 0
+	redacted.ContractAddress = nil
+	return &redacted
+}
+
+// ExportReveals drops indexed MTs the guard does not know to be revealed
+func (g *PrivacyGuard) ExportReveals(entries []IndexedMT) []IndexedMT {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	result := make([]IndexedMT, 0, len(entries))
+	for _, entry := range entries {
+		if _, revealed := g.revealed[entry.TxHash]; revealed {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// ExportAdmission returns an audited admission result for a PHT, withholding the
+// rejection messages when they would expose a hidden field
+func (g *PrivacyGuard) ExportAdmission(result *AdmissionResult, pht *PHTTransaction) *AdmissionResult {
+	if pht == nil {
+		return result
+	}
+	if _, err := g.MarshalAudited(result, []*PHTTransaction{pht}); err == nil {
+		return result
+	}
+
+	redacted := *result
+	redacted.Rejections = make([]AdmissionRejection, len(result.Rejections))
+	for i, rejection := range result.Rejections {
+		redacted.Rejections[i] = AdmissionRejection{Code: rejection.Code}
+	}
+	return &redacted
+}
+
+// LogContext returns log key/value pairs for a PHT that are safe to emit
+func (g *PrivacyGuard) LogContext(pht *PHTTransaction) []interface{} {
+	ctx := []interface{}{"hash", pht.TxHash, "sender", pht.Sender}
+
+	if g.IsRevealed(pht.TxHash) {
+		ctx = append(ctx, "recipient", pht.Recipient, "value", pht.Value, "gasPrice", pht.GasPrice, "calldata", len(pht.CallData))
+	}
+
+	return ctx
+}
+
+// Summarize returns aggregate information about unrevealed PHTs, consuming disclosure budget
+func (g *PrivacyGuard) Summarize(phts []*PHTTransaction) (*PendingPHTSummary, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.disclosed >= g.budget {
+		return nil, errors.New("privacy budget exhausted")
+	}
+	g.disclosed++
+
+	summary := &PendingPHTSummary{}
+	senders := make(map[common.Address]bool)
+	for _, pht := range phts {
+		if _, revealed := g.revealed[pht.TxHash]; revealed {
+			continue
+		}
+		summary.Count++
+		senders[pht.Sender] = true
+	}
+	summary.Senders = len(senders)
+
+	return summary, nil
+}
+
+// Audit checks that an exported payload does not contain hidden fields or the
+// per-PHT value, gas limit and gas price of any unrevealed PHT
+func (g *PrivacyGuard) Audit(payload []byte, phts []*PHTTransaction) error {
+	var tokens map[string]bool
+	for _, pht := range phts {
+		if g.IsRevealed(pht.TxHash) {
+			continue
+		}
+
+		for name, field := range hiddenFieldEncodings(pht) {
+			if len(field) > 0 && bytes.Contains(payload, field) {
+				return fmt.Errorf("%w: %s of %s", ErrHiddenFieldLeak, name, pht.TxHash.Hex())
+			}
+		}
+
+		// Quantities are short, so only whole scalars are matched
+		if tokens == nil {
+			tokens = make(map[string]bool)
+			for _, token := range quantityToken.FindAll(payload, -1) {
+				tokens[strings.ToLower(string(token))] = true
+			}
+		}
+		for name, encodings := range hiddenQuantities(pht) {
+			for _, encoding := range encodings {
+				if tokens[encoding] {
+					return fmt.Errorf("%w: %s of %s", ErrHiddenFieldLeak, name, pht.TxHash.Hex())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// MarshalAudited marshals a value to JSON and audits it against the given PHTs
+func (g *PrivacyGuard) MarshalAudited(v interface{}, phts []*PHTTransaction) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.Audit(payload, phts); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// ExportB1Block returns an audited JSON export of a B1 block with unrevealed PHTs redacted
+func (g *PrivacyGuard) ExportB1Block(block *B1Block) ([]byte, error) {
+	export := &B1BlockExport{
+		BlockHash:       block.BlockHash,
+		Timestamp:       block.Timestamp,
+		MEVScore:        block.MEVScore,
+		DetectedAttacks: block.DetectedAttacks,
+		PHTs:            make([]interface{}, 0, len(block.PHTs)),
+	}
+	for _, pht := range block.PHTs {
+		export.PHTs = append(export.PHTs, g.ExportPHT(pht))
+	}
+
+	return g.MarshalAudited(export, block.PHTs)
+}
+
+// hiddenFieldEncodings returns the textual encodings of hidden fields that must not leak
+func hiddenFieldEncodings(pht *PHTTransaction) map[string][]byte {
+	fields := make(map[string][]byte)
+
+	// Check hex, base64 and raw encodings since exports may use any of them
+	if pht.Recipient != (common.Address{}) {
+		fields["recipient"] = []byte(common.Bytes2Hex(pht.Recipient.Bytes()))
+		fields["recipient (raw)"] = pht.Recipient.Bytes()
+	}
+	addSecret(fields, "callData", pht.CallData)
+
+	// Commitment openings reveal the hidden fields to anyone holding the commitment
+	addSecret(fields, "blinding", pht.Blinding)
+	addSecret(fields, "valueBlinding", pht.ValueBlinding)
+	for i, salt := range pht.FieldSalts {
+		addSecret(fields, fmt.Sprintf("fieldSalts[%d]", i), salt)
+	}
+
+	return fields
+}
+
+// addSecret adds the encodings of a hidden byte field. Fields shorter than a
+// selector are skipped, as their encodings match unrelated payload data.
+func addSecret(fields map[string][]byte, name string, field []byte) {
+	if len(field) < 4 {
+		return
+	}
+	fields[name] = []byte(common.Bytes2Hex(field))
+	fields[name+" (base64)"] = []byte(base64.StdEncoding.EncodeToString(field))
+	fields[name+" (raw)"] = field
+}
+
+// hiddenQuantities returns the decimal and hex encodings of the hidden quantities
+// of a PHT. Zero quantities carry no information and are skipped.
+func hiddenQuantities(pht *PHTTransaction) map[string][]string {
+	quantities := make(map[string][]string)
+
+	encode := func(name string, value *big.Int) {
+		if value == nil || value.Sign() <= 0 {
+			return
+		}
+		quantities[name] = []string{value.String(), hexutil.EncodeBig(value)}
+	}
+	encode("value", pht.Value)
+	encode("gasPrice", pht.GasPrice)
+	encode("gasLimit", new(big.Int).SetUint64(pht.GasLimit))
+
+	return quantities
+}
@@ -0,0 +1,107 @@
+package p2s
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// defaultProofCacheLimit is the number of MT proofs cached by PHT hash
+const defaultProofCacheLimit = 4096
+
+// cachedProof is a proof known to be valid for a statement
+type cachedProof struct {
+	statement common.Hash // Hash of the commitment and data the proof is for
+	proof     []byte
+}
+
+// proofCache keeps recently generated or verified MT proofs keyed by PHT hash,
+// so a proof created while building a block is reused when the block is validated,
+// and proofs received from the network are verified once across fork-choice
+// re-evaluations
+type proofCache struct {
+	proofs *lru.Cache[common.Hash, cachedProof]
+}
+
+// newProofCache creates a proof cache holding at most limit proofs
+func newProofCache(limit int) *proofCache {
+	if limit <= 0 {
+		limit = defaultProofCacheLimit
+	}
+	return &proofCache{proofs: lru.NewCache[common.Hash, cachedProof](limit)}
+}
+
+// proofStatement hashes the commitment and data a proof is made for. Items are
+// length prefixed so different splits of the same bytes hash differently.
+func proofStatement(commitment []byte, data [][]byte) common.Hash {
+	hasher := crypto.NewKeccakState()
+	var length [4]byte
+	for _, item := range append([][]byte{commitment}, data...) {
+		binary.BigEndian.PutUint32(length[:], uint32(len(item)))
+		hasher.Write(length[:])
+		hasher.Write(item)
+	}
+	var statement common.Hash
+	hasher.Read(statement[:])
+	return statement
+}
+
+// proof returns the cached proof of a PHT if it was made for statement
+func (c *proofCache) proof(phtHash, statement common.Hash) ([]byte, bool) {
+	cached, ok := c.proofs.Get(phtHash)
+	if !ok || cached.statement != statement {
+		metrics.GetOrRegisterCounter("p2s/mt/proofcache/misses", nil).Inc(1)
+		return nil, false
+	}
+	metrics.GetOrRegisterCounter("p2s/mt/proofcache/hits", nil).Inc(1)
+	return common.CopyBytes(cached.proof), true
+}
+
+// verified reports whether proof is the cached valid proof of a PHT for statement
+func (c *proofCache) verified(phtHash, statement common.Hash, proof []byte) bool {
+	cached, ok := c.proofs.Get(phtHash)
+	if !ok || cached.statement != statement || !constantTimeEqual(cached.proof, proof) {
+		metrics.GetOrRegisterCounter("p2s/mt/proofcache/misses", nil).Inc(1)
+		return false
+	}
+	metrics.GetOrRegisterCounter("p2s/mt/proofcache/hits", nil).Inc(1)
+	return true
+}
+
+// add caches a valid proof of a PHT for statement
+func (c *proofCache) add(phtHash, statement common.Hash, proof []byte) {
+	c.proofs.Add(phtHash, cachedProof{statement: statement, proof: common.CopyBytes(proof)})
+}
+
+// proveCached returns the cached proof of a PHT for the commitment and data, or
+// creates and caches one with the configured proof system. The blinding factor
+// is only used by proof systems that prove the commitment opening.
+func (m *MTManager) proveCached(phtHash common.Hash, commitment, blinding []byte, data [][]byte) ([]byte, error) {
+	statement := proofStatement(commitment, data)
+	if proof, ok := m.proofs.proof(phtHash, statement); ok {
+		return proof, nil
+	}
+	proof, err := proveOpening(m.proofSystem, commitment, blinding, data)
+	if err != nil {
+		return nil, err
+	}
+	m.proofs.add(phtHash, statement, proof)
+	return proof, nil
+}
+
+// verifyCached verifies the proof of a PHT for the commitment and data, skipping
+// the proof system for proofs already known to be valid
+func (m *MTManager) verifyCached(phtHash common.Hash, proof []byte, commitment []byte, data [][]byte) bool {
+	statement := proofStatement(commitment, data)
+	if m.proofs.verified(phtHash, statement, proof) {
+		return true
+	}
+	if !m.proofSystem.Verify(proof, commitment, data...) {
+		return false
+	}
+	m.proofs.add(phtHash, statement, proof)
+	return true
+}
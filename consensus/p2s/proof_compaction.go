@@ -0,0 +1,203 @@
+package p2s
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// pairAttestationPrefix + B2 hash -> JSON encoded PairAttestation
+	pairAttestationPrefix = []byte("p2s-pair-attestation-")
+
+	// mtProofsPrefix + B2 hash -> JSON encoded full MT proofs, kept on archive nodes
+	mtProofsPrefix = []byte("p2s-mt-proofs-")
+
+	// ErrChallengeWindowOpen is returned when compacting a pair still open to challenges
+	ErrChallengeWindowOpen = errors.New("challenge window still open")
+
+	// ErrInvalidPairAttestation is returned when an attestation does not match its pair
+	ErrInvalidPairAttestation = errors.New("invalid pair attestation")
+)
+
+// PairAttestation replaces the per-MT proofs of a finalized B1/B2 pair with a
+// single signed commitment to the pair contents
+type PairAttestation struct {
+	B1Hash      common.Hash    `json:"b1Hash"`
+	B2Hash      common.Hash    `json:"b2Hash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	MTCount     int            `json:"mtCount"`
+	PairRoot    common.Hash    `json:"pairRoot"`   // Commitment to each PHT hash and its revealed fields
+	ProofsRoot  common.Hash    `json:"proofsRoot"` // Commitment to the discarded per-MT proofs
+	Attester    common.Address `json:"attester"`
+	Signature   []byte         `json:"signature"`
+}
+
+// SigningHash returns the hash signed by the attester
+func (a *PairAttestation) SigningHash() common.Hash {
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, a.BlockNumber)
+	count := make([]byte, 8)
+	binary.BigEndian.PutUint64(count, uint64(a.MTCount))
+
+	return crypto.Keccak256Hash([]byte("p2s-pair-attestation"), a.B1Hash.Bytes(), a.B2Hash.Bytes(),
+		number, count, a.PairRoot.Bytes(), a.ProofsRoot.Bytes())
+}
+
+// mtPairLeaf commits to an MT's PHT link and revealed fields, excluding its proofs
+func mtPairLeaf(mt *MTTransaction) common.Hash {
+	gas := make([]byte, 8)
+	binary.BigEndian.PutUint64(gas, mt.GasLimit)
+
+	return crypto.Keccak256Hash(mt.PHTHash.Bytes(), mt.Recipient.Bytes(),
+		common.LeftPadBytes(mt.Value.Bytes(), 32), mt.CallData, []byte{mt.TxType}, gas)
+}
+
+// pairRoots computes the pair and proofs roots of a B2 block
+func pairRoots(b2Block *B2Block) (common.Hash, common.Hash, error) {
+	pairLeaves := make([][]byte, 0, len(b2Block.MTs))
+	proofLeaves := make([][]byte, 0, len(b2Block.MTs))
+	for _, mt := range b2Block.MTs {
+		pairLeaves = append(pairLeaves, mtPairLeaf(mt).Bytes())
+
+		openings, err := json.Marshal(mt.FieldOpenings)
+		if err != nil {
+			return common.Hash{}, common.Hash{}, err
+		}
+		proofLeaves = append(proofLeaves, crypto.Keccak256(mt.Proof, openings))
+	}
+
+	return crypto.Keccak256Hash(pairLeaves...), crypto.Keccak256Hash(proofLeaves...), nil
+}
+
+// mtProofs holds the full proofs of an MT retained on archive nodes
+type mtProofs struct {
+	PHTHash       common.Hash     `json:"phtHash"`
+	Proof         []byte          `json:"proof"`
+	FieldOpenings []*FieldOpening `json:"fieldOpenings,omitempty"`
+}
+
+// ProofCompactor replaces per-MT proofs of finalized pairs with pair attestations in cold storage
+type ProofCompactor struct {
+	store            ethdb.KeyValueStore
+	challengeWindow  uint64
+	retainFullProofs bool
+	key              *ecdsa.PrivateKey
+}
+
+// NewProofCompactor creates a compactor; archive nodes set retainFullProofs to keep the full proofs
+func NewProofCompactor(store ethdb.KeyValueStore, challengeWindow uint64, retainFullProofs bool, key *ecdsa.PrivateKey) *ProofCompactor {
+	return &ProofCompactor{
+		store:            store,
+		challengeWindow:  challengeWindow,
+		retainFullProofs: retainFullProofs,
+		key:              key,
+	}
+}
+
+// Compact attests a finalized pair past its challenge window, writes the attestation
+// (and full proofs on archive nodes) to cold storage and strips the per-MT proofs
+func (c *ProofCompactor) Compact(b1Block *B1Block, b2Block *B2Block, finalized uint64) (*PairAttestation, error) {
+	number := b2Block.Header.Number.Uint64()
+	if number > finalized || finalized-number < c.challengeWindow {
+		return nil, ErrChallengeWindowOpen
+	}
+	if b2Block.ProofsCompacted {
+		return ReadPairAttestation(c.store, b2Block.BlockHash)
+	}
+
+	pairRoot, proofsRoot, err := pairRoots(b2Block)
+	if err != nil {
+		return nil, err
+	}
+	attestation := &PairAttestation{
+		B1Hash:      b1Block.BlockHash,
+		B2Hash:      b2Block.BlockHash,
+		BlockNumber: number,
+		MTCount:     len(b2Block.MTs),
+		PairRoot:    pairRoot,
+		ProofsRoot:  proofsRoot,
+		Attester:    crypto.PubkeyToAddress(c.key.PublicKey),
+	}
+	attestation.Signature, err = crypto.Sign(attestation.SigningHash().Bytes(), c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := c.store.NewBatch()
+	data, err := json.Marshal(attestation)
+	if err != nil {
+		return nil, err
+	}
+	if err := batch.Put(append(common.CopyBytes(pairAttestationPrefix), b2Block.BlockHash.Bytes()...), data); err != nil {
+		return nil, err
+	}
+	if c.retainFullProofs {
+		proofs := make([]mtProofs, len(b2Block.MTs))
+		for i, mt := range b2Block.MTs {
+			proofs[i] = mtProofs{PHTHash: mt.PHTHash, Proof: mt.Proof, FieldOpenings: mt.FieldOpenings}
+		}
+		data, err := json.Marshal(proofs)
+		if err != nil {
+			return nil, err
+		}
+		if err := batch.Put(append(common.CopyBytes(mtProofsPrefix), b2Block.BlockHash.Bytes()...), data); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return nil, err
+	}
+
+	// Only drop proofs once the attestation is durable
+	for _, mt := range b2Block.MTs {
+		mt.Proof = nil
+		mt.FieldOpenings = nil
+	}
+	b2Block.ProofsCompacted = true
+
+	log.Debug("Compacted MT proofs", "number", number, "b2", b2Block.BlockHash, "mts", attestation.MTCount, "archive", c.retainFullProofs)
+	return attestation, nil
+}
+
+// ReadPairAttestation reads the attestation of a compacted B2 block from cold storage
+func ReadPairAttestation(db ethdb.KeyValueReader, b2Hash common.Hash) (*PairAttestation, error) {
+	data, err := db.Get(append(common.CopyBytes(pairAttestationPrefix), b2Hash.Bytes()...))
+	if err != nil {
+		return nil, fmt.Errorf("pair attestation for %s not found: %v", b2Hash.Hex(), err)
+	}
+
+	var attestation PairAttestation
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		return nil, err
+	}
+	return &attestation, nil
+}
+
+// VerifyPairAttestation checks an attestation against a (possibly compacted) B2 block
+func VerifyPairAttestation(attestation *PairAttestation, b2Block *B2Block) error {
+	if attestation.B2Hash != b2Block.BlockHash || attestation.MTCount != len(b2Block.MTs) {
+		return ErrInvalidPairAttestation
+	}
+
+	leaves := make([][]byte, len(b2Block.MTs))
+	for i, mt := range b2Block.MTs {
+		leaves[i] = mtPairLeaf(mt).Bytes()
+	}
+	if !constantTimeHashEqual(crypto.Keccak256Hash(leaves...), attestation.PairRoot) {
+		return ErrInvalidPairAttestation
+	}
+
+	pubKey, err := crypto.SigToPub(attestation.SigningHash().Bytes(), attestation.Signature)
+	if err != nil || crypto.PubkeyToAddress(*pubKey) != attestation.Attester {
+		return ErrInvalidPairAttestation
+	}
+	return nil
+}
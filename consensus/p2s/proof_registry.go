@@ -0,0 +1,106 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// ErrUnknownProofSystem is returned when no proof system is registered under the configured name
+	ErrUnknownProofSystem = errors.New("unknown proof system")
+
+	// ErrProofSystemRegistered is returned when a proof system name is registered twice
+	ErrProofSystemRegistered = errors.New("proof system already registered")
+)
+
+// ProofSystemConstructor creates a proof system from the P2S configuration
+type ProofSystemConstructor func(config *P2SConfig) (ProofSystem, error)
+
+var (
+	proofSystems   = make(map[string]ProofSystemConstructor)
+	proofSystemsMu sync.RWMutex
+)
+
+func init() {
+	RegisterProofSystem(ProofSystemMerkle, func(*P2SConfig) (ProofSystem, error) {
+		return NewMerkleProofSystem(), nil
+	})
+	RegisterProofSystem(ProofSystemSMT, func(*P2SConfig) (ProofSystem, error) {
+		return NewSMTProofSystem(), nil
+	})
+	RegisterProofSystem(ProofSystemVerkle, func(*P2SConfig) (ProofSystem, error) {
+		return NewVerkleProofSystem(), nil
+	})
+	RegisterProofSystem(ProofSystemGroth16, func(config *P2SConfig) (ProofSystem, error) {
+		if config == nil {
+			return nil, ErrMissingSNARKSetup
+		}
+		if config.CommitmentScheme != CommitmentSchemeMiMC {
+			return nil, fmt.Errorf("%w, not %q", ErrSNARKCommitmentScheme, config.CommitmentScheme)
+		}
+		return NewSNARKProofSystem(config.SNARKSetupDir, config.SNARKVerifyingKeyHash)
+	})
+}
+
+// RegisterProofSystem makes a proof system selectable by name via
+// P2SConfig.ProofSystem. It is typically called from an init function.
+func RegisterProofSystem(name string, constructor ProofSystemConstructor) error {
+	if name == "" || constructor == nil {
+		return errors.New("proof system needs a name and constructor")
+	}
+
+	proofSystemsMu.Lock()
+	defer proofSystemsMu.Unlock()
+
+	if _, exists := proofSystems[name]; exists {
+		return fmt.Errorf("%w: %s", ErrProofSystemRegistered, name)
+	}
+	proofSystems[name] = constructor
+	return nil
+}
+
+// ProofSystems returns the names of all registered proof systems
+func ProofSystems() []string {
+	proofSystemsMu.RLock()
+	defer proofSystemsMu.RUnlock()
+
+	names := make([]string, 0, len(proofSystems))
+	for name := range proofSystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProofSystem creates the proof system named by the configuration. An empty
+// name selects Merkle proofs.
+func NewProofSystem(config *P2SConfig) (ProofSystem, error) {
+	name := ProofSystemMerkle
+	if config != nil && config.ProofSystem != "" {
+		name = config.ProofSystem
+	}
+
+	proofSystemsMu.RLock()
+	constructor, exists := proofSystems[name]
+	proofSystemsMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProofSystem, name)
+	}
+	return constructor(config)
+}
+
+// newConfiguredProofSystem creates the configured proof system for the MT manager,
+// falling back to Merkle proofs if it cannot be created. Callers that must not
+// fall back should check the configuration with NewProofSystem.
+func newConfiguredProofSystem(config *P2SConfig) ProofSystem {
+	proofSystem, err := NewProofSystem(config)
+	if err != nil {
+		log.Error("Falling back to Merkle proofs", "err", err)
+		return NewMerkleProofSystem()
+	}
+	return proofSystem
+}
@@ -0,0 +1,141 @@
+package p2s
+
+import (
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Proofs of proof systems with an identifier start with an envelope naming the
+// system and its proof format version: proofEnvelopeTag, the identifier and the
+// version, one byte each. Merkle proofs, the original format, and proofs of
+// systems without an identifier carry no envelope. Merkle proofs start with the
+// high byte of their leaf index, which is zero for the few leaves of an MT.
+const (
+	proofEnvelopeTag  = 0xff
+	proofEnvelopeSize = 3
+)
+
+// Identifiers of the built-in proof systems embedded in their proofs. Zero is
+// left for proofs without an envelope.
+const (
+	ProofSystemIDSMT     uint8 = 1
+	ProofSystemIDGroth16 uint8 = 2
+	ProofSystemIDVerkle  uint8 = 3
+)
+
+// VersionedProofSystem is a proof system whose proofs carry its identifier and
+// proof format version, so nodes can keep verifying its proofs after the network
+// moves to another proof system
+type VersionedProofSystem interface {
+	ProofSystem
+	ProofSystemID() uint8
+	ProofVersion() uint8
+}
+
+// proofFormat is the identifier and version of a proof format, zero for proofs
+// without an envelope
+type proofFormat struct {
+	id, version uint8
+}
+
+// formatOf returns the proof format of a proof system
+func formatOf(system ProofSystem) proofFormat {
+	versioned, ok := system.(VersionedProofSystem)
+	if !ok {
+		return proofFormat{}
+	}
+	return proofFormat{id: versioned.ProofSystemID(), version: versioned.ProofVersion()}
+}
+
+// ProofVersionOf returns the proof system identifier and proof format version a
+// proof was made with, zero for proofs without an envelope
+func ProofVersionOf(proof []byte) (id uint8, version uint8) {
+	format, _ := splitProofEnvelope(proof)
+	return format.id, format.version
+}
+
+// splitProofEnvelope splits a proof into its format and the proof of its system
+func splitProofEnvelope(proof []byte) (proofFormat, []byte) {
+	if len(proof) < proofEnvelopeSize || proof[0] != proofEnvelopeTag {
+		return proofFormat{}, proof
+	}
+	return proofFormat{id: proof[1], version: proof[2]}, proof[proofEnvelopeSize:]
+}
+
+// multiProofSystem proves with the current proof system of the network and
+// verifies proofs of it and of the proof systems of earlier forks, each with the
+// backend of its format
+type multiProofSystem struct {
+	prover    ProofSystem
+	format    proofFormat
+	verifiers map[proofFormat]ProofSystem
+}
+
+// newMultiProofSystem creates a proof system proving with current and verifying
+// with it and the historical proof systems. The current one takes precedence
+// for a format served by several.
+func newMultiProofSystem(current ProofSystem, historical ...ProofSystem) *multiProofSystem {
+	s := &multiProofSystem{
+		prover:    current,
+		format:    formatOf(current),
+		verifiers: make(map[proofFormat]ProofSystem),
+	}
+	for _, system := range append([]ProofSystem{current}, historical...) {
+		if _, exists := s.verifiers[formatOf(system)]; !exists {
+			s.verifiers[formatOf(system)] = system
+		}
+	}
+	return s
+}
+
+// Prove creates a proof with the current proof system, wrapped in its envelope
+func (s *multiProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
+	proof, err := s.prover.Prove(commitment, data...)
+	if err != nil || s.format == (proofFormat{}) {
+		return proof, err
+	}
+	return append([]byte{proofEnvelopeTag, s.format.id, s.format.version}, proof...), nil
+}
+
+// ProveOpening creates a proof with the current proof system from a commitment
+// opening, wrapped in its envelope
+func (s *multiProofSystem) ProveOpening(commitment, blinding []byte, data ...[]byte) ([]byte, error) {
+	proof, err := proveOpening(s.prover, commitment, blinding, data)
+	if err != nil || s.format == (proofFormat{}) {
+		return proof, err
+	}
+	return append([]byte{proofEnvelopeTag, s.format.id, s.format.version}, proof...), nil
+}
+
+// Verify verifies a proof with the backend of its format, failing for formats
+// the node does not accept
+func (s *multiProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
+	format, proof := splitProofEnvelope(proof)
+	verifier, ok := s.verifiers[format]
+	if !ok {
+		return false
+	}
+	return verifier.Verify(proof, commitment, data...)
+}
+
+// newMTProofSystem creates the proof system of the MT manager: the configured one,
+// also verifying proofs of the historical proof systems. Historical systems that
+// cannot be created are left out, failing verification of their proofs.
+func newMTProofSystem(config *P2SConfig) ProofSystem {
+	current := newConfiguredProofSystem(config)
+	if config == nil {
+		return newMultiProofSystem(current)
+	}
+
+	historical := make([]ProofSystem, 0, len(config.ProofSystemHistory))
+	for _, name := range config.ProofSystemHistory {
+		forkConfig := *config
+		forkConfig.ProofSystem = name
+		system, err := NewProofSystem(&forkConfig)
+		if err != nil {
+			log.Error("Historical proof system unavailable", "name", name, "err", err)
+			continue
+		}
+		historical = append(historical, system)
+	}
+	return newMultiProofSystem(current, historical...)
+}
@@ -0,0 +1,236 @@
+package p2s
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// slotTimerWindow is the number of heights below the newest started slot
+	// whose start times are kept
+	slotTimerWindow = 64
+
+	// closedSlotLimit is the number of blocks remembered as having closed their slot
+	closedSlotLimit = 1024
+)
+
+// slotID identifies the B1 or B2 slot at a height
+type slotID struct {
+	number    uint64
+	blockType uint8
+}
+
+// SlotTimer tracks when proposer slots started, so the proposer whose turn it is
+// follows from the slot time: the selected proposer has the first slot time,
+// each backup proposer in order the next one.
+type SlotTimer struct {
+	starts  map[slotID]time.Time
+	highest uint64
+	mu      sync.Mutex
+}
+
+// NewSlotTimer creates an empty slot timer
+func NewSlotTimer() *SlotTimer {
+	return &SlotTimer{starts: make(map[slotID]time.Time)}
+}
+
+// Start records the start of a slot, keeping an earlier start
+func (t *SlotTimer) Start(number uint64, blockType uint8, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := slotID{number: number, blockType: blockType}
+	if _, started := t.starts[id]; started {
+		return
+	}
+	t.starts[id] = now
+	if number > t.highest {
+		t.highest = number
+		for id := range t.starts {
+			if id.number+slotTimerWindow < t.highest {
+				delete(t.starts, id)
+			}
+		}
+	}
+}
+
+// Elapsed returns the time since a slot started, zero if it has not
+func (t *SlotTimer) Elapsed(number uint64, blockType uint8, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	start, started := t.starts[slotID{number: number, blockType: blockType}]
+	if !started || now.Before(start) {
+		return 0
+	}
+	return now.Sub(start)
+}
+
+// Done stops tracking a slot once its block was produced
+func (t *SlotTimer) Done(number uint64, blockType uint8) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.starts, slotID{number: number, blockType: blockType})
+}
+
+// ProposerOrder returns the proposer of a slot followed by its backup proposers
+// from the fallback ladder, at most size in all
+func (v *ValidatorManager) ProposerOrder(proposer common.Address, slot uint64, size int) []common.Address {
+	order := []common.Address{proposer}
+	if size > 1 {
+		order = append(order, v.FallbackLadder(slot, proposer, size-1)...)
+	}
+	return order
+}
+
+// proposerRank returns the rank of the producer in a slot's order, -1 if it is
+// not in it
+func proposerRank(order []common.Address, producer common.Address) int {
+	for i, proposer := range order {
+		if proposer == producer {
+			return i
+		}
+	}
+	return -1
+}
+
+// missedProposers returns the proposers ahead of the producer in a slot's order,
+// none if the producer is not in it or took over before its turn came
+func missedProposers(order []common.Address, producer common.Address, allowed int) []common.Address {
+	rank := proposerRank(order, producer)
+	if rank < 0 || rank > allowed {
+		return nil
+	}
+	return order[:rank]
+}
+
+// proposerOrder returns the proposer order of a slot: for B1 blocks the selected
+// proposer, for B2 blocks the validator due to produce it, each followed by the
+// backup proposers. With separate roles the B1 proposer is never in the order of
+// its B2 block. The caller must hold the lock.
+func (p *P2SConsensus) proposerOrder(number uint64, blockType uint8, parentHash common.Hash) ([]common.Address, error) {
+	var proposer common.Address
+	if blockType == 2 {
+		b1Block, exists := p.cache.GetB1Block(parentHash)
+		if !exists {
+			return nil, errors.New("B1 block not found")
+		}
+		b2Proposer, err := p.b2Proposer(parentHash, b1Block)
+		if err != nil {
+			return nil, err
+		}
+		if p.config.SeparateB2Proposer {
+			order := make([]common.Address, 0, p.config.ProposerFallbacks+1)
+			for _, member := range p.validatorMgr.ProposerOrder(b2Proposer, number, p.config.ProposerFallbacks+2) {
+				if member != b1Block.Header.Coinbase && len(order) < p.config.ProposerFallbacks+1 {
+					order = append(order, member)
+				}
+			}
+			return order, nil
+		}
+		proposer = b2Proposer
+	} else {
+		selected, err := p.validatorMgr.SelectProposer(number, parentHash)
+		if err != nil {
+			return nil, err
+		}
+		proposer = selected
+	}
+	return p.validatorMgr.ProposerOrder(proposer, number, p.config.ProposerFallbacks+1), nil
+}
+
+// slotTime returns the time each proposer of a block type has before the next
+// one takes over
+func (p *P2SConsensus) slotTime(blockType uint8) time.Duration {
+	if blockType == 2 {
+		return p.config.B2BlockTime
+	}
+	return p.config.B1BlockTime
+}
+
+// allowedRank returns the highest rank in the proposer order that may produce a
+// block, from the time between the block and its parent: the selected proposer
+// for the first slot time, each backup for a further one
+func (p *P2SConsensus) allowedRank(blockType uint8, parentTime, blockTime uint64) int {
+	slotTime := p.slotTime(blockType)
+	if slotTime <= 0 {
+		return math.MaxInt32
+	}
+	if blockTime <= parentTime {
+		return 0
+	}
+	elapsed := time.Duration(blockTime-parentTime) * time.Second
+	if rank := elapsed / slotTime; rank < math.MaxInt32 {
+		return int(rank)
+	}
+	return math.MaxInt32
+}
+
+// closeSlot stops tracking the slot of a produced or imported block and records
+// a missed slot for every proposer its producer, the coinbase, took over from.
+// Only proposers whose turn had passed by the block time are penalized, parentTime
+// being the time of the parent the slot started from, so every node records the
+// same missed slots from the header. Each block is accounted for once. The
+// caller must hold the lock.
+func (p *P2SConsensus) closeSlot(blockType uint8, parentHash common.Hash, parentTime uint64, header *types.Header) {
+	number := header.Number.Uint64()
+	p.slots.Done(number, blockType)
+	if p.closedSlots.Contains(header.Hash()) {
+		return
+	}
+	p.closedSlots.Add(header.Hash(), true)
+
+	order, err := p.proposerOrder(number, blockType, parentHash)
+	if err != nil {
+		return
+	}
+	allowed := p.allowedRank(blockType, parentTime, header.Time)
+	for _, missed := range missedProposers(order, header.Coinbase, allowed) {
+		log.Warn("Backup proposer took over slot", "number", number, "type", blockType, "missed", missed, "producer", header.Coinbase)
+		p.recordMissedSlot(number, missed)
+	}
+}
+
+// StartSlot starts the slot time of the B1 or B2 block at a height
+func (p *P2SConsensus) StartSlot(number uint64, blockType uint8) {
+	p.slots.Start(number, blockType, time.Now())
+}
+
+// GetFallbackProposers returns the proposer of the B1 or B2 block at a height
+// followed by its backup proposers, in the order they take over. B2 blocks are
+// identified by the hash of the B1 block they reveal, B1 blocks by their parent.
+func (p *P2SConsensus) GetFallbackProposers(number uint64, blockType uint8, parentHash common.Hash) ([]common.Address, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.proposerOrder(number, blockType, parentHash)
+}
+
+// GetSlotProposer returns the proposer whose turn the B1 or B2 block at a height
+// is and its rank in the proposer order: the selected proposer until its slot
+// time passes since the slot started, then each backup proposer for another slot
+// time, the last one until the block is produced.
+func (p *P2SConsensus) GetSlotProposer(number uint64, blockType uint8, parentHash common.Hash) (common.Address, int, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	order, err := p.proposerOrder(number, blockType, parentHash)
+	if err != nil {
+		return common.Address{}, 0, err
+	}
+	rank := 0
+	if slotTime := p.slotTime(blockType); slotTime > 0 {
+		rank = int(p.slots.Elapsed(number, blockType, time.Now()) / slotTime)
+	}
+	if rank >= len(order) {
+		rank = len(order) - 1
+	}
+	return order[rank], rank, nil
+}
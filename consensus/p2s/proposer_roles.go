@@ -0,0 +1,282 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrNoB2Proposer is returned when no validator other than the B1 proposer can
+	// propose the B2 block
+	ErrNoB2Proposer = errors.New("no B2 proposer besides the B1 proposer")
+
+	// ErrWrongProposer is returned for a block not produced by a validator scheduled
+	// for its block type
+	ErrWrongProposer = errors.New("block not produced by a scheduled proposer")
+
+	// ErrInvalidHandoff is returned for a PHT handoff that is not signed by the B1
+	// proposer, not addressed to this node as B2 proposer, arrives after the B2
+	// block or carries foreign openings
+	ErrInvalidHandoff = errors.New("invalid PHT handoff")
+)
+
+// SelectB2Proposer selects the proposer of the B2 block revealing a B1 block,
+// independently of the B1 proposer: the draw is seeded by the B1 block hash, and
+// a draw of the B1 proposer falls to the first validator of the fallback ladder
+// without it
+func (v *ValidatorManager) SelectB2Proposer(number uint64, b1Hash common.Hash, b1Proposer common.Address) (common.Address, error) {
+	seed := crypto.Keccak256Hash([]byte("p2s-b2-proposer"), b1Hash.Bytes())
+	proposer, err := v.SelectProposer(number, seed)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if proposer != b1Proposer {
+		return proposer, nil
+	}
+	ladder := v.FallbackLadder(number, b1Proposer, 1)
+	if len(ladder) == 0 {
+		return common.Address{}, ErrNoB2Proposer
+	}
+	return ladder[0], nil
+}
+
+// PHTHandoff carries the commitment openings of a B1 block's PHTs from the B1
+// proposer to the B2 proposer, which needs them to produce the MTs
+type PHTHandoff struct {
+	BlockNumber uint64               `json:"blockNumber"`
+	B1Hash      common.Hash          `json:"b1Hash"`
+	From        common.Address       `json:"from"` // B1 proposer
+	To          common.Address       `json:"to"`   // B2 proposer
+	Openings    []*CommitmentOpening `json:"openings"`
+	Signature   hexutil.Bytes        `json:"signature"`
+}
+
+// SigningHash returns the hash the B1 proposer signs
+func (h *PHTHandoff) SigningHash() common.Hash {
+	hasher := crypto.NewKeccakState()
+	write := func(data []byte) {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+		hasher.Write(size[:])
+		hasher.Write(data)
+	}
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], h.BlockNumber)
+	hasher.Write([]byte("p2s-pht-handoff"))
+	hasher.Write(number[:])
+	hasher.Write(h.B1Hash.Bytes())
+	hasher.Write(h.From.Bytes())
+	hasher.Write(h.To.Bytes())
+	for _, opening := range h.Openings {
+		write(opening.Commitment)
+		write(opening.Blinding)
+		var items [8]byte
+		binary.BigEndian.PutUint64(items[:], uint64(len(opening.Data)))
+		hasher.Write(items[:])
+		for _, item := range opening.Data {
+			write(item)
+		}
+	}
+	var hash common.Hash
+	hasher.Read(hash[:])
+	return hash
+}
+
+// b2Proposer returns the validator due to produce the B2 block revealing a B1
+// block: the B1 proposer, or with separate roles the independently selected B2
+// proposer. The caller must hold the lock.
+func (p *P2SConsensus) b2Proposer(b1Hash common.Hash, b1Block *B1Block) (common.Address, error) {
+	if !p.config.SeparateB2Proposer {
+		return b1Block.Header.Coinbase, nil
+	}
+	return p.validatorMgr.SelectB2Proposer(b1Block.Header.Number.Uint64(), b1Hash, b1Block.Header.Coinbase)
+}
+
+// proposerSigningHash returns the hash the producer of a block signs to seal it
+func proposerSigningHash(blockType uint8, header common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte("p2s-proposer-seal"), []byte{blockType}, header.Bytes())
+}
+
+// recoverProposer recovers the producer of a block from its proposer seal. The
+// seal must be made by the header's coinbase, which duties and rewards credit.
+func recoverProposer(blockType uint8, header *types.Header, sig []byte) (common.Address, error) {
+	if len(sig) == 0 {
+		return common.Address{}, fmt.Errorf("%w: B%d block %d carries no proposer seal", ErrWrongProposer, blockType, header.Number.Uint64())
+	}
+	producer, err := recoverSealSigner(proposerSigningHash(blockType, header.Hash()), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrWrongProposer, err)
+	}
+	if producer != header.Coinbase {
+		return common.Address{}, fmt.Errorf("%w: B%d block %d sealed by %s for coinbase %s", ErrWrongProposer, blockType, header.Number.Uint64(), producer.Hex(), header.Coinbase.Hex())
+	}
+	return producer, nil
+}
+
+// verifyProposer checks that a block is sealed by a validator in the proposer
+// order of its slot whose turn had come by the block time, parentTime being the
+// time of the parent the slot started from. The caller must hold the lock.
+func (p *P2SConsensus) verifyProposer(blockType uint8, parentHash common.Hash, parentTime uint64, header *types.Header, sig []byte) error {
+	producer, err := recoverProposer(blockType, header, sig)
+	if err != nil {
+		return err
+	}
+	number := header.Number.Uint64()
+	order, err := p.proposerOrder(number, blockType, parentHash)
+	if err != nil {
+		return err
+	}
+	rank := proposerRank(order, producer)
+	if rank < 0 {
+		return fmt.Errorf("%w: B%d block %d by %s", ErrWrongProposer, blockType, number, producer.Hex())
+	}
+	if allowed := p.allowedRank(blockType, parentTime, header.Time); rank > allowed {
+		return fmt.Errorf("%w: B%d block %d by backup %s of rank %d before its turn, rank %d allowed", ErrWrongProposer, blockType, number, producer.Hex(), rank, allowed)
+	}
+	return nil
+}
+
+// SignProposal seals a cached B1 or B2 block produced by this node with its
+// validator key. The node's validator must be the block's coinbase.
+func (p *P2SConsensus) SignProposal(hash common.Hash) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.signer == nil {
+		return ErrNoSigner
+	}
+	var (
+		blockType uint8
+		header    *types.Header
+		seal      *[]byte
+	)
+	if b1Block, exists := p.cache.GetB1Block(hash); exists {
+		blockType, header, seal = 1, b1Block.Header, &b1Block.ProposerSig
+	} else if b2Block, exists := p.cache.GetB2Block(hash); exists {
+		blockType, header, seal = 2, b2Block.Header, &b2Block.ProposerSig
+	} else {
+		return errors.New("block not found")
+	}
+	if p.signer.Address() != header.Coinbase {
+		return fmt.Errorf("%w: block produced by %s", ErrSignerMismatch, header.Coinbase.Hex())
+	}
+	sig, err := p.signer.SignProposerSeal(blockType, header)
+	if err != nil {
+		return err
+	}
+	*seal = sig
+	return nil
+}
+
+// CreatePHTHandoff hands the openings of a cached B1 block's PHTs that this node
+// holds to the B2 proposer, signed by the node's validator signer. The node's
+// validator must be the B1 block's coinbase.
+func (p *P2SConsensus) CreatePHTHandoff(b1Hash common.Hash) (*PHTHandoff, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.signer == nil {
+		return nil, ErrNoSigner
+	}
+	b1Block, exists := p.cache.GetB1Block(b1Hash)
+	if !exists {
+		return nil, errors.New("B1 block not found")
+	}
+	from := p.signer.Address()
+	if from != b1Block.Header.Coinbase {
+		return nil, fmt.Errorf("%w: B1 block proposed by %s", ErrSignerMismatch, b1Block.Header.Coinbase.Hex())
+	}
+	to, err := p.b2Proposer(b1Hash, b1Block)
+	if err != nil {
+		return nil, err
+	}
+
+	handoff := &PHTHandoff{
+		BlockNumber: b1Block.Header.Number.Uint64(),
+		B1Hash:      b1Hash,
+		From:        from,
+		To:          to,
+	}
+	for _, pht := range b1Block.PHTs {
+		opening, err := p.phtManager.Opening(pht.Commitment)
+		if errors.Is(err, ErrOpeningNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		handoff.Openings = append(handoff.Openings, opening)
+	}
+	handoff.Signature, err = p.signer.SignPHTHandoff(handoff)
+	if err != nil {
+		return nil, err
+	}
+	return handoff, nil
+}
+
+// AcceptPHTHandoff verifies a handoff from the proposer of a cached B1 block to
+// this node as its B2 proposer and stores its openings, so this node can reveal
+// the PHTs. Handoffs arriving once a B2 block revealing the B1 block is cached
+// are refused.
+func (p *P2SConsensus) AcceptPHTHandoff(handoff *PHTHandoff) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.signer == nil {
+		return ErrNoSigner
+	}
+	if handoff == nil {
+		return fmt.Errorf("%w: missing", ErrInvalidHandoff)
+	}
+	if handoff.To != p.signer.Address() {
+		return fmt.Errorf("%w: addressed to %s, not this node", ErrInvalidHandoff, handoff.To.Hex())
+	}
+	b1Block, exists := p.cache.GetB1Block(handoff.B1Hash)
+	if !exists {
+		return errors.New("B1 block not found")
+	}
+	if handoff.BlockNumber != b1Block.Header.Number.Uint64() || handoff.From != b1Block.Header.Coinbase {
+		return fmt.Errorf("%w: not from the proposer of B1 block %d", ErrInvalidHandoff, b1Block.Header.Number)
+	}
+	signer, err := recoverSealSigner(handoff.SigningHash(), handoff.Signature)
+	if err != nil || signer != handoff.From {
+		return fmt.Errorf("%w: not signed by %s", ErrInvalidHandoff, handoff.From.Hex())
+	}
+	to, err := p.b2Proposer(handoff.B1Hash, b1Block)
+	if err != nil {
+		return err
+	}
+	if handoff.To != to {
+		return fmt.Errorf("%w: addressed to %s, B2 proposer is %s", ErrInvalidHandoff, handoff.To.Hex(), to.Hex())
+	}
+	for _, b2Block := range p.cache.b2Blocks {
+		if b2Block.B1BlockHash == handoff.B1Hash {
+			return fmt.Errorf("%w: B1 block %d already revealed", ErrInvalidHandoff, handoff.BlockNumber)
+		}
+	}
+
+	commitments := make(map[string]bool, len(b1Block.PHTs))
+	for _, pht := range b1Block.PHTs {
+		commitments[string(pht.Commitment)] = true
+	}
+	for i, opening := range handoff.Openings {
+		if opening == nil || !commitments[string(opening.Commitment)] {
+			return fmt.Errorf("%w: opening %d is not for a PHT of the block", ErrInvalidHandoff, i)
+		}
+		if err := opening.Verify(p.phtManager.commitmentScheme); err != nil {
+			return fmt.Errorf("%w: opening %d: %v", ErrInvalidHandoff, i, err)
+		}
+	}
+	for _, opening := range handoff.Openings {
+		if err := p.phtManager.ImportOpening(opening); err != nil {
+			return err
+		}
+	}
+	return nil
+}
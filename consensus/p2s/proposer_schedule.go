@@ -0,0 +1,77 @@
+package p2s
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// maxProposerLookahead bounds the blocks a proposer schedule covers
+const maxProposerLookahead = 1024
+
+// proposerPreview is implemented by rotating selections, whose proposers do not
+// depend on block hashes and can be scheduled ahead
+type proposerPreview interface {
+	PreviewProposers(validators map[common.Address]*Validator, from, count uint64) []common.Address
+}
+
+// ProposerAssignment is the proposer scheduled for the B1 block at a height and
+// for the B2 block revealing it. The B1 proposer is due to produce the B2 block
+// unless B2 proposers are selected separately, from the B1 block hash, in which
+// case the B2 proposer is left zero.
+type ProposerAssignment struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	B1Proposer  common.Address `json:"b1Proposer"`
+	B2Proposer  common.Address `json:"b2Proposer"`
+	B1Offset    time.Duration  `json:"b1Offset"`  // Expected B1 block time after the first scheduled block
+	B2Offset    time.Duration  `json:"b2Offset"`  // Expected B2 block time after the first scheduled block
+	Confirmed   bool           `json:"confirmed"` // False, with the proposers left zero, when they depend on a parent block hash not known yet
+}
+
+// ProposerSchedule returns the proposers of count blocks from a height with the
+// current validator set, the block before it being parent. Rotating selections
+// do not depend on block hashes and are confirmed throughout; weighted selection
+// draws from the parent block hash, so only its first assignment is known and
+// later ones only carry their block numbers. The schedule ends early when no
+// proposer can be selected.
+func (v *ValidatorManager) ProposerSchedule(from, count uint64, parent common.Hash) []*ProposerAssignment {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if count > maxProposerLookahead {
+		count = maxProposerLookahead
+	}
+	_, seeded := v.selection.(*WeightedRandomSelection)
+	validators := v.weightedValidators()
+
+	schedule := make([]*ProposerAssignment, 0, count)
+	if preview, ok := v.selection.(proposerPreview); ok {
+		for i, proposer := range preview.PreviewProposers(validators, from, count) {
+			schedule = append(schedule, &ProposerAssignment{
+				BlockNumber: from + uint64(i),
+				B1Proposer:  proposer,
+				B2Proposer:  proposer,
+				Confirmed:   true,
+			})
+		}
+		return schedule
+	}
+	for i := uint64(0); i < count; i++ {
+		number := from + i
+		if seeded && i > 0 {
+			schedule = append(schedule, &ProposerAssignment{BlockNumber: number})
+			continue
+		}
+		proposer, err := v.selection.SelectProposer(validators, number, parent)
+		if err != nil {
+			break
+		}
+		schedule = append(schedule, &ProposerAssignment{
+			BlockNumber: number,
+			B1Proposer:  proposer,
+			B2Proposer:  proposer,
+			Confirmed:   true,
+		})
+	}
+	return schedule
+}
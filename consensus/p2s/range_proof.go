@@ -0,0 +1,339 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Range proof errors
+var (
+	ErrValueAboveCap     = errors.New("value not below cap")
+	ErrMissingRangeProof = errors.New("missing value range proof")
+	ErrInvalidRangeProof = errors.New("invalid value range proof")
+)
+
+// BitProof commits to a single bit and proves in zero knowledge that it is 0 or 1,
+// with a disjunctive Schnorr proof of knowledge of the blinding factor of either
+// C or C - G relative to H
+type BitProof struct {
+	Commitment []byte `json:"commitment"` // C = b·G + r·H
+	E0         []byte `json:"e0"`         // Challenge shares of the b = 0 and b = 1 branches
+	E1         []byte `json:"e1"`
+	S0         []byte `json:"s0"`
+	S1         []byte `json:"s1"`
+}
+
+// RangeProof proves that the value v of a Pedersen value commitment V = v·G + r·H
+// is below Cap without revealing it. Like Bulletproofs it decomposes v into bits,
+// and proves v and Cap - 1 - v both fit in the bit length of Cap; each bit carries
+// its own OR proof, so proofs grow linearly rather than logarithmically with it.
+type RangeProof struct {
+	Cap   *big.Int    `json:"cap"`
+	Value []*BitProof `json:"value"` // Bits of v
+	Slack []*BitProof `json:"slack"` // Bits of Cap - 1 - v
+}
+
+// ecPoint is a secp256k1 point, with a nil x for the point at infinity
+type ecPoint struct {
+	x, y *big.Int
+}
+
+// NewValueCommitment commits to a value with a fresh blinding factor, returning
+// the compressed commitment and the blinding factor
+func NewValueCommitment(value *big.Int) ([]byte, []byte, error) {
+	r, err := randomScalar(crypto.S256().Params().N)
+	if err != nil {
+		return nil, nil, err
+	}
+	return valueCommitment(value, r).encode(), common.LeftPadBytes(r.Bytes(), 32), nil
+}
+
+// VerifyValueCommitment checks that a value commitment opens to value
+func VerifyValueCommitment(commitment []byte, value *big.Int, blinding []byte) bool {
+	if len(blinding) != 32 || value == nil || value.Sign() < 0 {
+		return false
+	}
+	return constantTimeEqual(commitment, valueCommitment(value, new(big.Int).SetBytes(blinding)).encode())
+}
+
+// ProveValueRange proves that the value of a value commitment is below limit
+func ProveValueRange(value *big.Int, blinding []byte, limit *big.Int) (*RangeProof, error) {
+	if limit == nil || limit.Cmp(common.Big1) <= 0 || limit.BitLen() >= 255 {
+		return nil, errors.New("invalid value cap")
+	}
+	if value.Sign() < 0 || value.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("%w: %v, cap %v", ErrValueAboveCap, value, limit)
+	}
+	n := crypto.S256().Params().N
+	r := new(big.Int).SetBytes(blinding)
+	commitment := valueCommitment(value, r).encode()
+	bits := new(big.Int).Sub(limit, common.Big1).BitLen()
+	if bits == 0 {
+		bits = 1
+	}
+
+	valueBits, err := proveBits(value, r, bits, rangeProofContext(commitment, limit, "value"))
+	if err != nil {
+		return nil, err
+	}
+	// Cap - 1 - v is committed by (Cap - 1)·G - V under blinding -r
+	slack := new(big.Int).Sub(limit, common.Big1)
+	slack.Sub(slack, value)
+	slackBits, err := proveBits(slack, new(big.Int).Sub(n, r), bits, rangeProofContext(commitment, limit, "slack"))
+	if err != nil {
+		return nil, err
+	}
+	return &RangeProof{
+		Cap:   new(big.Int).Set(limit),
+		Value: valueBits,
+		Slack: slackBits,
+	}, nil
+}
+
+// VerifyValueRange checks a range proof against a value commitment and the
+// expected cap
+func VerifyValueRange(commitment []byte, proof *RangeProof, limit *big.Int) error {
+	if proof == nil {
+		return ErrMissingRangeProof
+	}
+	if proof.Cap == nil || proof.Cap.Cmp(limit) != 0 {
+		return fmt.Errorf("%w: proven cap %v, required %v", ErrInvalidRangeProof, proof.Cap, limit)
+	}
+	if limit.Cmp(common.Big1) <= 0 || limit.BitLen() >= 255 {
+		return fmt.Errorf("%w: invalid cap", ErrInvalidRangeProof)
+	}
+	v, err := decodePoint(commitment)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRangeProof, err)
+	}
+	bits := new(big.Int).Sub(limit, common.Big1).BitLen()
+	if bits == 0 {
+		bits = 1
+	}
+	if err := verifyBits(v, proof.Value, bits, rangeProofContext(commitment, limit, "value")); err != nil {
+		return err
+	}
+	slack := pointAdd(pointMul(generatorG(), new(big.Int).Sub(limit, common.Big1)), pointNeg(v))
+	return verifyBits(slack, proof.Slack, bits, rangeProofContext(commitment, limit, "slack"))
+}
+
+// proveBits proves that w, committed as w·G + r·H, is below 2^bits
+func proveBits(w, r *big.Int, bits int, context []byte) ([]*BitProof, error) {
+	n := crypto.S256().Params().N
+
+	// Bit blinding factors sum to r with weights 2^i
+	blindings := make([]*big.Int, bits)
+	weighted := new(big.Int)
+	for i := 0; i < bits-1; i++ {
+		ri, err := randomScalar(n)
+		if err != nil {
+			return nil, err
+		}
+		blindings[i] = ri
+		weighted.Add(weighted, new(big.Int).Lsh(ri, uint(i)))
+	}
+	last := new(big.Int).Sub(r, weighted)
+	last.Mul(last, new(big.Int).ModInverse(new(big.Int).Lsh(common.Big1, uint(bits-1)), n))
+	blindings[bits-1] = last.Mod(last, n)
+
+	proofs := make([]*BitProof, bits)
+	for i := 0; i < bits; i++ {
+		proof, err := proveBit(w.Bit(i), blindings[i], bitContext(context, i))
+		if err != nil {
+			return nil, err
+		}
+		proofs[i] = proof
+	}
+	return proofs, nil
+}
+
+// verifyBits checks bit proofs whose weighted commitments sum to w
+func verifyBits(w ecPoint, proofs []*BitProof, bits int, context []byte) error {
+	if len(proofs) != bits {
+		return fmt.Errorf("%w: %d bit proofs, need %d", ErrInvalidRangeProof, len(proofs), bits)
+	}
+	sum := ecPoint{}
+	for i, proof := range proofs {
+		c, err := verifyBit(proof, bitContext(context, i))
+		if err != nil {
+			return fmt.Errorf("%w: bit %d: %v", ErrInvalidRangeProof, i, err)
+		}
+		sum = pointAdd(sum, pointMul(c, new(big.Int).Lsh(common.Big1, uint(i))))
+	}
+	if !sum.equal(w) {
+		return fmt.Errorf("%w: bit commitments do not sum to the commitment", ErrInvalidRangeProof)
+	}
+	return nil
+}
+
+// proveBit commits to bit b under blinding r and proves that b is 0 or 1
+func proveBit(b uint, r *big.Int, context []byte) (*BitProof, error) {
+	n := crypto.S256().Params().N
+	h := generatorH()
+	c := pointAdd(pointMul(generatorG(), big.NewInt(int64(b))), pointMul(h, r))
+
+	// Statement j claims C - j·G = r·H
+	statements := [2]ecPoint{c, pointAdd(c, pointNeg(generatorG()))}
+
+	// Simulate the false branch, then answer the real one
+	fake := 1 - b
+	eFake, err := randomScalar(n)
+	if err != nil {
+		return nil, err
+	}
+	sFake, err := randomScalar(n)
+	if err != nil {
+		return nil, err
+	}
+	k, err := randomScalar(n)
+	if err != nil {
+		return nil, err
+	}
+	var commitments [2]ecPoint
+	commitments[b] = pointMul(h, k)
+	commitments[fake] = pointAdd(pointMul(h, sFake), pointMul(statements[fake], eFake))
+
+	e := bitChallenge(context, c, commitments)
+	eReal := new(big.Int).Sub(e, eFake)
+	eReal.Mod(eReal, n)
+	sReal := new(big.Int).Mul(eReal, r)
+	sReal.Sub(k, sReal)
+	sReal.Mod(sReal, n)
+
+	var es, ss [2]*big.Int
+	es[b], es[fake] = eReal, eFake
+	ss[b], ss[fake] = sReal, sFake
+	return &BitProof{
+		Commitment: c.encode(),
+		E0:         common.LeftPadBytes(es[0].Bytes(), 32),
+		E1:         common.LeftPadBytes(es[1].Bytes(), 32),
+		S0:         common.LeftPadBytes(ss[0].Bytes(), 32),
+		S1:         common.LeftPadBytes(ss[1].Bytes(), 32),
+	}, nil
+}
+
+// verifyBit checks a bit proof and returns its commitment
+func verifyBit(proof *BitProof, context []byte) (ecPoint, error) {
+	if proof == nil {
+		return ecPoint{}, errors.New("missing bit proof")
+	}
+	n := crypto.S256().Params().N
+	c, err := decodePoint(proof.Commitment)
+	if err != nil {
+		return ecPoint{}, err
+	}
+	e0, e1 := new(big.Int).SetBytes(proof.E0), new(big.Int).SetBytes(proof.E1)
+	s0, s1 := new(big.Int).SetBytes(proof.S0), new(big.Int).SetBytes(proof.S1)
+	for _, scalar := range []*big.Int{e0, e1, s0, s1} {
+		if scalar.Cmp(n) >= 0 {
+			return ecPoint{}, errors.New("scalar out of range")
+		}
+	}
+
+	// A_j = s_j·H + e_j·(C - j·G) reproduces the prover's commitments, and the
+	// challenge shares must sum to the challenge over them
+	h := generatorH()
+	statements := [2]ecPoint{c, pointAdd(c, pointNeg(generatorG()))}
+	commitments := [2]ecPoint{
+		pointAdd(pointMul(h, s0), pointMul(statements[0], e0)),
+		pointAdd(pointMul(h, s1), pointMul(statements[1], e1)),
+	}
+	e := bitChallenge(context, c, commitments)
+	if new(big.Int).Mod(new(big.Int).Add(e0, e1), n).Cmp(e) != 0 {
+		return ecPoint{}, errors.New("challenge mismatch")
+	}
+	return c, nil
+}
+
+// valueCommitment computes v·G + r·H
+func valueCommitment(value, r *big.Int) ecPoint {
+	return pointAdd(pointMul(generatorG(), value), pointMul(generatorH(), r))
+}
+
+// rangeProofContext binds the bit proofs of one half of a range proof to the
+// value commitment and cap
+func rangeProofContext(commitment []byte, limit *big.Int, half string) []byte {
+	return crypto.Keccak256([]byte("p2s-range-proof"), commitment, limit.Bytes(), []byte(half))
+}
+
+// bitContext derives the context of the proof of bit i
+func bitContext(context []byte, i int) []byte {
+	return crypto.Keccak256(context, big.NewInt(int64(i)).Bytes())
+}
+
+// bitChallenge derives the Fiat-Shamir challenge of a bit proof
+func bitChallenge(context []byte, c ecPoint, commitments [2]ecPoint) *big.Int {
+	e := new(big.Int).SetBytes(crypto.Keccak256(context, c.encode(), commitments[0].encode(), commitments[1].encode()))
+	return e.Mod(e, crypto.S256().Params().N)
+}
+
+// generatorG returns the secp256k1 base point
+func generatorG() ecPoint {
+	params := crypto.S256().Params()
+	return ecPoint{params.Gx, params.Gy}
+}
+
+// generatorH returns the second Pedersen generator
+func generatorH() ecPoint {
+	pedersen := NewPedersenCommitment()
+	return ecPoint{pedersen.hx, pedersen.hy}
+}
+
+// pointMul returns k·P
+func pointMul(p ecPoint, k *big.Int) ecPoint {
+	k = new(big.Int).Mod(k, crypto.S256().Params().N)
+	if p.x == nil || k.Sign() == 0 {
+		return ecPoint{}
+	}
+	x, y := crypto.S256().ScalarMult(p.x, p.y, common.LeftPadBytes(k.Bytes(), 32))
+	return ecPoint{x, y}
+}
+
+// pointAdd returns A + B
+func pointAdd(a, b ecPoint) ecPoint {
+	if a.x == nil {
+		return b
+	}
+	if b.x == nil {
+		return a
+	}
+	x, y := addPoints(a.x, a.y, b.x, b.y)
+	return ecPoint{x, y}
+}
+
+// pointNeg returns -P
+func pointNeg(p ecPoint) ecPoint {
+	if p.x == nil {
+		return p
+	}
+	return ecPoint{p.x, new(big.Int).Sub(crypto.S256().Params().P, p.y)}
+}
+
+// equal reports whether two points are the same
+func (p ecPoint) equal(q ecPoint) bool {
+	if p.x == nil || q.x == nil {
+		return p.x == nil && q.x == nil
+	}
+	return p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) == 0
+}
+
+// encode returns the compressed point, 33 zero bytes for the point at infinity
+func (p ecPoint) encode() []byte {
+	if p.x == nil {
+		return make([]byte, 33)
+	}
+	return compressPoint(p.x, p.y)
+}
+
+// decodePoint decodes a compressed point
+func decodePoint(data []byte) (ecPoint, error) {
+	x, y, err := decompressPoint(data)
+	if err != nil {
+		return ecPoint{}, err
+	}
+	return ecPoint{x, y}, nil
+}
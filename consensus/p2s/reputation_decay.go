@@ -0,0 +1,71 @@
+package p2s
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Validator reputation bounds. Validators start at the neutral reputation, to
+// which reputation drifts back over time.
+const (
+	neutralReputation = 100
+	maxReputation     = 1000
+	minReputation     = -1000
+)
+
+// ReputationCurve moves reputation back towards neutral as blocks pass, so old
+// behaviour does not decide selection forever. The distance from neutral halves
+// every half-life, interpolated linearly within one. Reputation above neutral
+// decays with DecayHalfLife and reputation below it, after penalties, recovers
+// with RecoveryHalfLife, both in blocks; zero keeps that side unchanged.
+type ReputationCurve struct {
+	DecayHalfLife    uint64
+	RecoveryHalfLife uint64
+}
+
+// At returns the reputation elapsed blocks after it was set to base
+func (c ReputationCurve) At(base int64, elapsed uint64) int64 {
+	distance := base - neutralReputation
+	halfLife := c.DecayHalfLife
+	if distance < 0 {
+		halfLife = c.RecoveryHalfLife
+	}
+	if distance == 0 || halfLife == 0 {
+		return base
+	}
+
+	halvings := elapsed / halfLife
+	if halvings >= 63 {
+		return neutralReputation
+	}
+	distance /= int64(1) << halvings
+
+	// Linear between this half-life and the next: distance * (1 - rem/(2*halfLife))
+	rem := new(big.Int).SetUint64(elapsed % halfLife)
+	shrink := new(big.Int).Mul(big.NewInt(distance), rem)
+	shrink.Quo(shrink, new(big.Int).Lsh(new(big.Int).SetUint64(halfLife), 1))
+	return neutralReputation + distance - shrink.Int64()
+}
+
+// AdvanceHeight moves the validator manager to a block height, applying the
+// reputation curve to every validator since its last reputation update, and
+// snapshots the validator set every snapshot interval. Heights at or below the
+// current one are ignored.
+func (v *ValidatorManager) AdvanceHeight(number uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if number <= v.height {
+		return
+	}
+	v.height = number
+	for _, validator := range v.validators {
+		validator.Reputation = v.curve.At(validator.reputationBase, number-validator.reputationAnchor)
+	}
+	if v.db != nil && v.snapshotInterval > 0 && number%v.snapshotInterval == 0 {
+		if err := v.snapshot(); err != nil {
+			log.Error("Failed to snapshot validator set", "number", number, "err", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package p2s
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MissedReveal describes a PHT whose MT was not produced within its reveal window
+type MissedReveal struct {
+	B1Hash   common.Hash    `json:"b1Hash"`
+	B1Number uint64         `json:"b1Number"`
+	TxHash   common.Hash    `json:"txHash"`
+	Sender   common.Address `json:"sender"`
+	Proposer common.Address `json:"proposer"` // Proposer of the B1 block, due to produce the B2 block
+	Deadline uint64         `json:"deadline"` // Unix time the MT was due by
+}
+
+// RevealTracker tracks the reveal window of every PHT included in a B1 block: its
+// MT must be produced within the B2 block time plus a grace period
+type RevealTracker struct {
+	window  time.Duration
+	pending map[common.Hash]*MissedReveal
+	mu      sync.Mutex
+}
+
+// NewRevealTracker creates a tracker giving each PHT window to be revealed
+func NewRevealTracker(window time.Duration) *RevealTracker {
+	return &RevealTracker{
+		window:  window,
+		pending: make(map[common.Hash]*MissedReveal),
+	}
+}
+
+// Track starts the reveal windows of the PHTs of a B1 block included at now
+func (r *RevealTracker) Track(number uint64, b1Hash common.Hash, proposer common.Address, phts []*PHTTransaction, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadline := uint64(now.Add(r.window).Unix())
+	for _, pht := range phts {
+		r.pending[pht.TxHash] = &MissedReveal{
+			B1Hash:   b1Hash,
+			B1Number: number,
+			TxHash:   pht.TxHash,
+			Sender:   pht.Sender,
+			Proposer: proposer,
+			Deadline: deadline,
+		}
+	}
+}
+
+// Revealed closes the reveal windows of PHTs whose MTs were produced; windows of
+// PHTs with unrevealed placeholders stay open
+func (r *RevealTracker) Revealed(mts []*MTTransaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, mt := range mts {
+		if !mt.Unrevealed {
+			delete(r.pending, mt.TxHash)
+		}
+	}
+}
+
+// Expire returns the PHTs whose reveal windows closed before now without an MT,
+// ordered by B1 block, and stops tracking them
+func (r *RevealTracker) Expire(now time.Time) []*MissedReveal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var missed []*MissedReveal
+	for txHash, reveal := range r.pending {
+		if uint64(now.Unix()) > reveal.Deadline {
+			missed = append(missed, reveal)
+			delete(r.pending, txHash)
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool {
+		if missed[i].B1Number != missed[j].B1Number {
+			return missed[i].B1Number < missed[j].B1Number
+		}
+		return missed[i].TxHash.Cmp(missed[j].TxHash) < 0
+	})
+	return missed
+}
+
+// Pending returns the number of PHTs awaiting their reveal
+func (r *RevealTracker) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.pending)
+}
@@ -0,0 +1,234 @@
+package p2s
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// defaultRevealIndexWindow is the number of blocks of revealed MTs kept indexed
+	defaultRevealIndexWindow = 10000
+
+	// revealIndexPruneInterval is the number of indexed blocks between full prunes
+	revealIndexPruneInterval = 64
+)
+
+// approveSelector is the ERC-20 approve(address,uint256) selector
+var approveSelector = []byte{0x09, 0x5e, 0xa7, 0xb3}
+
+// IndexedMT is a revealed MT as recorded in the reveal index
+type IndexedMT struct {
+	BlockNumber uint64          `json:"blockNumber"`
+	B2Hash      common.Hash     `json:"b2Hash"`
+	TxHash      common.Hash     `json:"txHash"`
+	Sender      common.Address  `json:"sender"`
+	Recipient   common.Address  `json:"recipient"`
+	Selector    string          `json:"selector,omitempty"` // 4-byte selector as hex, empty for plain transfers
+	Token       *common.Address `json:"token,omitempty"`    // ERC-20 token touched, if any
+}
+
+// RevealIndex maintains secondary indexes over revealed MTs by recipient, by 4-byte
+// selector and by ERC-20 token touched. Blocks are indexed incrementally as B2
+// blocks are imported; blocks older than the retention window are pruned.
+type RevealIndex struct {
+	byRecipient map[common.Address][]*IndexedMT
+	bySelector  map[[4]byte][]*IndexedMT
+	byToken     map[common.Address][]*IndexedMT
+	indexed     map[common.Hash]uint64 // B2 hash to block number
+	window      uint64
+	head        uint64
+	sincePrune  int
+	mu          sync.RWMutex
+}
+
+// NewRevealIndex creates an index retaining window blocks, 0 for the default
+func NewRevealIndex(window uint64) *RevealIndex {
+	if window == 0 {
+		window = defaultRevealIndexWindow
+	}
+
+	return &RevealIndex{
+		byRecipient: make(map[common.Address][]*IndexedMT),
+		bySelector:  make(map[[4]byte][]*IndexedMT),
+		byToken:     make(map[common.Address][]*IndexedMT),
+		indexed:     make(map[common.Hash]uint64),
+		window:      window,
+	}
+}
+
+// IndexB2Block indexes the MTs revealed by a B2 block. Senders are taken from the
+// paired B1 block; blocks already indexed are ignored.
+func (r *RevealIndex) IndexB2Block(number uint64, b2Block *B2Block, b1Block *B1Block) {
+	senders := make(map[common.Hash]common.Address)
+	if b1Block != nil {
+		for _, pht := range b1Block.PHTs {
+			senders[pht.TxHash] = pht.Sender
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.indexed[b2Block.BlockHash]; exists {
+		return
+	}
+	if r.head >= r.window && number <= r.head-r.window {
+		return
+	}
+	r.indexed[b2Block.BlockHash] = number
+
+	for _, mt := range b2Block.MTs {
+		if mt.Unrevealed {
+			continue
+		}
+		entry := &IndexedMT{
+			BlockNumber: number,
+			B2Hash:      b2Block.BlockHash,
+			TxHash:      mt.TxHash,
+			Sender:      senders[mt.TxHash],
+			Recipient:   mt.Recipient,
+		}
+		r.byRecipient[mt.Recipient] = insertIndexed(r.byRecipient[mt.Recipient], entry)
+
+		if len(mt.CallData) >= 4 {
+			var selector [4]byte
+			copy(selector[:], mt.CallData[:4])
+			entry.Selector = selectorHex(mt.CallData)
+			r.bySelector[selector] = insertIndexed(r.bySelector[selector], entry)
+
+			if isTokenCall(mt.CallData) {
+				token := mt.Recipient
+				entry.Token = &token
+				r.byToken[token] = insertIndexed(r.byToken[token], entry)
+			}
+		}
+	}
+
+	if number > r.head {
+		r.head = number
+	}
+	r.sincePrune++
+	if r.sincePrune >= revealIndexPruneInterval {
+		r.prune()
+		r.sincePrune = 0
+	}
+}
+
+// isTokenCall reports whether call data is an ERC-20 transfer, transferFrom or approve
+func isTokenCall(callData []byte) bool {
+	selector := callData[:4]
+	return bytes.Equal(selector, transferSelector) || bytes.Equal(selector, transferFromSelector) || bytes.Equal(selector, approveSelector)
+}
+
+// insertIndexed adds an entry keeping the list ordered by block number
+func insertIndexed(entries []*IndexedMT, entry *IndexedMT) []*IndexedMT {
+	i := len(entries)
+	for i > 0 && entries[i-1].BlockNumber > entry.BlockNumber {
+		i--
+	}
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	return entries
+}
+
+// prune drops entries that have left the retention window
+func (r *RevealIndex) prune() {
+	if r.head < r.window {
+		return
+	}
+	oldest := r.head - r.window + 1
+
+	for hash, number := range r.indexed {
+		if number < oldest {
+			delete(r.indexed, hash)
+		}
+	}
+	for key, entries := range r.byRecipient {
+		if entries = pruneIndexed(entries, oldest); len(entries) == 0 {
+			delete(r.byRecipient, key)
+		} else {
+			r.byRecipient[key] = entries
+		}
+	}
+	for key, entries := range r.bySelector {
+		if entries = pruneIndexed(entries, oldest); len(entries) == 0 {
+			delete(r.bySelector, key)
+		} else {
+			r.bySelector[key] = entries
+		}
+	}
+	for key, entries := range r.byToken {
+		if entries = pruneIndexed(entries, oldest); len(entries) == 0 {
+			delete(r.byToken, key)
+		} else {
+			r.byToken[key] = entries
+		}
+	}
+}
+
+// pruneIndexed drops entries below the oldest retained block
+func pruneIndexed(entries []*IndexedMT, oldest uint64) []*IndexedMT {
+	start := sort.Search(len(entries), func(i int) bool {
+		return entries[i].BlockNumber >= oldest
+	})
+	if start == 0 {
+		return entries
+	}
+	return append([]*IndexedMT(nil), entries[start:]...)
+}
+
+// query returns copies of entries within the last blocks blocks, 0 for the whole window
+func (r *RevealIndex) query(entries []*IndexedMT, blocks uint64) []IndexedMT {
+	oldest := uint64(0)
+	if blocks == 0 || blocks > r.window {
+		blocks = r.window
+	}
+	if r.head >= blocks {
+		oldest = r.head - blocks + 1
+	}
+
+	start := sort.Search(len(entries), func(i int) bool {
+		return entries[i].BlockNumber >= oldest
+	})
+	result := make([]IndexedMT, 0, len(entries)-start)
+	for _, entry := range entries[start:] {
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// ByRecipient returns MTs sent to an address in the last blocks blocks
+func (r *RevealIndex) ByRecipient(recipient common.Address, blocks uint64) []IndexedMT {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.query(r.byRecipient[recipient], blocks)
+}
+
+// BySelector returns MTs calling a 4-byte selector in the last blocks blocks
+func (r *RevealIndex) BySelector(selector [4]byte, blocks uint64) []IndexedMT {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.query(r.bySelector[selector], blocks)
+}
+
+// ByToken returns MTs touching an ERC-20 token in the last blocks blocks
+func (r *RevealIndex) ByToken(token common.Address, blocks uint64) []IndexedMT {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.query(r.byToken[token], blocks)
+}
+
+// Head returns the highest indexed block number
+func (r *RevealIndex) Head() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.head
+}
@@ -0,0 +1,86 @@
+package p2s
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// revealSignatureDomain separates reveal signatures from other signatures of the sender
+const revealSignatureDomain = "p2s-reveal-v1"
+
+var (
+	// ErrMissingRevealSignature is returned for an MT without reveal signature on
+	// networks requiring them
+	ErrMissingRevealSignature = errors.New("missing reveal signature")
+
+	// ErrInvalidRevealSignature is returned when an MT's reveal signature is not
+	// the PHT sender's over its revealed fields
+	ErrInvalidRevealSignature = errors.New("invalid reveal signature")
+)
+
+// RevealDigest returns the digest a sender signs to bind the reveal of a PHT:
+// the PHT hash and the encoded revealed fields, each length prefixed
+func RevealDigest(phtHash common.Hash, revealed [][]byte) common.Hash {
+	hasher := crypto.NewKeccakState()
+	hasher.Write([]byte(revealSignatureDomain))
+	hasher.Write(phtHash.Bytes())
+
+	var length [4]byte
+	for _, item := range revealed {
+		binary.BigEndian.PutUint32(length[:], uint32(len(item)))
+		hasher.Write(length[:])
+		hasher.Write(item)
+	}
+	var digest common.Hash
+	hasher.Read(digest[:])
+	return digest
+}
+
+// signReveal signs the reveal of a PHT with the sender key
+func signReveal(key *ecdsa.PrivateKey, phtHash common.Hash, revealed [][]byte) ([]byte, error) {
+	return crypto.Sign(RevealDigest(phtHash, revealed).Bytes(), key)
+}
+
+// checkRevealSignature verifies that an MT's reveal signature was made by its PHT
+// sender over the PHT hash and revealed fields. The revealed fields must already
+// open the PHT commitment, so a hidden sender is the MT's.
+func (m *MTManager) checkRevealSignature(mt *MTTransaction, pht *PHTTransaction) error {
+	if len(mt.RevealSignature) == 0 {
+		if m.config != nil && m.config.RequireRevealSignatures {
+			return ErrMissingRevealSignature
+		}
+		return nil
+	}
+	sig := mt.RevealSignature
+	if len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("%w: %d bytes", ErrInvalidRevealSignature, len(sig))
+	}
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+	if !crypto.ValidateSignatureValues(sig[64], r, s, true) {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidRevealSignature)
+	}
+
+	revealed, err := mt.commitmentData()
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.SigToPub(RevealDigest(mt.PHTHash, revealed).Bytes(), sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRevealSignature, err)
+	}
+
+	sender := pht.Sender
+	if pht.HiddenSet.Has(FieldSender) {
+		sender = mt.Sender
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != sender {
+		return fmt.Errorf("%w: signed by %s, not sender %s", ErrInvalidRevealSignature, signer.Hex(), sender.Hex())
+	}
+	return nil
+}
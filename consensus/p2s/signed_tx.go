@@ -0,0 +1,87 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ErrSignedTxMismatch is returned when the signed transaction carried by an MT is
+// not the transaction its PHT committed to
+var ErrSignedTxMismatch = errors.New("signed transaction mismatch")
+
+// decodeSignedTx decodes the canonical encoding of a signed transaction
+func decodeSignedTx(data []byte) (*types.Transaction, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no signed transaction")
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// checkSignedTx verifies that the signed transaction carried by an MT hashes to
+// the PHT's transaction hash, is signed by the PHT's sender and carries exactly
+// the fields the PHT committed to. The PHT's hidden fields must already have been
+// matched against the MT's revealed ones.
+func checkSignedTx(mt *MTTransaction, pht *PHTTransaction) error {
+	tx, err := decodeSignedTx(mt.SignedTx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignedTxMismatch, err)
+	}
+	if tx.Hash() != pht.TxHash || tx.Hash() != mt.TxHash {
+		return fmt.Errorf("%w: transaction hash %s", ErrSignedTxMismatch, tx.Hash().Hex())
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignedTxMismatch, err)
+	}
+	if sender != pht.Sender {
+		return fmt.Errorf("%w: signed by %s", ErrSignedTxMismatch, sender.Hex())
+	}
+
+	switch {
+	case tx.Type() != pht.TxType:
+		return fmt.Errorf("%w: transaction type", ErrSignedTxMismatch)
+	case tx.Nonce() != pht.AccountNonce:
+		return fmt.Errorf("%w: account nonce", ErrSignedTxMismatch)
+	case (tx.To() == nil) != pht.IsContractCreation || (tx.To() != nil && *tx.To() != pht.Recipient):
+		return fmt.Errorf("%w: recipient", ErrSignedTxMismatch)
+	case tx.Value().Cmp(bigOrZero(pht.Value)) != 0:
+		return fmt.Errorf("%w: value", ErrSignedTxMismatch)
+	case !constantTimeEqual(tx.Data(), pht.CallData):
+		return fmt.Errorf("%w: call data", ErrSignedTxMismatch)
+	case tx.Gas() != pht.GasLimit:
+		return fmt.Errorf("%w: gas limit", ErrSignedTxMismatch)
+	case tx.GasPrice().Cmp(bigOrZero(pht.GasPrice)) != 0:
+		return fmt.Errorf("%w: gas price", ErrSignedTxMismatch)
+	}
+	if pht.MaxFeePerGas != nil && (tx.GasFeeCap().Cmp(pht.MaxFeePerGas) != 0 || tx.GasTipCap().Cmp(bigOrZero(pht.MaxPriorityFeePerGas)) != 0) {
+		return fmt.Errorf("%w: fee caps", ErrSignedTxMismatch)
+	}
+	if pht.ChainID != nil && tx.Protected() && tx.ChainId().Cmp(pht.ChainID) != 0 {
+		return fmt.Errorf("%w: chain ID", ErrSignedTxMismatch)
+	}
+
+	txAccess, _ := rlp.EncodeToBytes(tx.AccessList())
+	phtAccess, _ := rlp.EncodeToBytes(pht.AccessList)
+	if !constantTimeEqual(txAccess, phtAccess) {
+		return fmt.Errorf("%w: access list", ErrSignedTxMismatch)
+	}
+	if tx.Type() == types.BlobTxType {
+		blobHashes := tx.BlobHashes()
+		if len(blobHashes) != len(pht.BlobHashes) || tx.BlobGasFeeCap().Cmp(bigOrZero(pht.MaxFeePerBlobGas)) != 0 {
+			return fmt.Errorf("%w: blob fields", ErrSignedTxMismatch)
+		}
+		for i, hash := range blobHashes {
+			if hash != pht.BlobHashes[i] {
+				return fmt.Errorf("%w: blob hash %d", ErrSignedTxMismatch, i)
+			}
+		}
+	}
+	return nil
+}
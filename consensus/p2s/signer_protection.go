@@ -0,0 +1,94 @@
+package p2s
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Payload kinds slashing protection tracks separately
+const (
+	signingKindAttestation  = "attestation"
+	signingKindSealProposal = "seal-proposal"
+	signingKindPHTHandoff   = "pht-handoff"
+	signingKindProposerSeal = "proposer-seal-b" // Followed by the block type
+	signingKindBlock        = "block-b"         // Followed by the block type
+)
+
+// signingRecordPrefix + kind -> JSON encoded signingRecord of the highest payload signed
+var signingRecordPrefix = []byte("p2s-signing-record-")
+
+// ErrSlashableSigning is returned when a signer refuses to sign a payload that
+// conflicts with one it signed before
+var ErrSlashableSigning = errors.New("refusing to sign a slashable payload")
+
+// signingRecord is the highest payload of a kind a signer has signed
+type signingRecord struct {
+	Height uint64      `json:"height"`
+	Hash   common.Hash `json:"hash"` // Signing hash of the payload
+}
+
+// SlashingProtection keeps the highest height a signer has signed each kind of
+// payload at. It refuses payloads below that height and other payloads at it,
+// while signing the same payload again is allowed. Records are written through
+// to the database, if any, before the signature is released.
+type SlashingProtection struct {
+	db      ethdb.KeyValueStore
+	records map[string]signingRecord
+	mu      sync.Mutex
+}
+
+// NewSlashingProtection creates slashing protection persisted in db, loading the
+// records already in it; a nil db keeps them in memory only
+func NewSlashingProtection(db ethdb.KeyValueStore) (*SlashingProtection, error) {
+	protection := &SlashingProtection{db: db, records: make(map[string]signingRecord)}
+	if db == nil {
+		return protection, nil
+	}
+	it := db.NewIterator(signingRecordPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		var record signingRecord
+		if err := json.Unmarshal(it.Value(), &record); err != nil {
+			return nil, err
+		}
+		protection.records[string(it.Key()[len(signingRecordPrefix):])] = record
+	}
+	return protection, it.Error()
+}
+
+// Check records a payload about to be signed, failing if it conflicts with one
+// signed before
+func (s *SlashingProtection) Check(kind string, height uint64, hash common.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[kind]
+	if exists {
+		if height < record.Height {
+			return fmt.Errorf("%w: %s at height %d below signed height %d", ErrSlashableSigning, kind, height, record.Height)
+		}
+		if height == record.Height {
+			if hash != record.Hash {
+				return fmt.Errorf("%w: another %s at height %d", ErrSlashableSigning, kind, height)
+			}
+			return nil
+		}
+	}
+	record = signingRecord{Height: height, Hash: hash}
+	if s.db != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := s.db.Put(append(common.CopyBytes(signingRecordPrefix), kind...), data); err != nil {
+			return err
+		}
+	}
+	s.records[kind] = record
+	return nil
+}
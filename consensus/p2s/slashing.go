@@ -0,0 +1,408 @@
+package p2s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SlashingOffense names a slashable validator misbehaviour
+type SlashingOffense string
+
+// Slashable offenses
+const (
+	OffenseInvalidReveal  SlashingOffense = "invalidReveal"  // B2 proposer published an MT not opening its PHT
+	OffenseDoubleProposal SlashingOffense = "doubleProposal" // Leader proposed two PHT orderings at one height
+	OffenseEquivocation   SlashingOffense = "equivocation"   // Committee member co-signed two proposals at one height
+	OffenseDoubleSign     SlashingOffense = "doubleSign"     // Validator signed two B1 or two B2 blocks at one height
+)
+
+// basisPoints is the denominator of slashing fractions
+const basisPoints = 10000
+
+// maxPendingRevealEvidence bounds the fraud evidence waiting for block inclusion
+const maxPendingRevealEvidence = 64
+
+var (
+	// ErrInvalidEvidence is returned for slashing evidence that does not prove an offense
+	ErrInvalidEvidence = errors.New("invalid slashing evidence")
+
+	// ErrDuplicateEvidence is returned for an offense that was already slashed
+	ErrDuplicateEvidence = errors.New("offense already slashed")
+)
+
+// SlashingEvidence proves a validator offense at a block height
+type SlashingEvidence interface {
+	Offense() SlashingOffense
+	Height() uint64
+}
+
+// InvalidRevealEvidence proves that a B2 proposer published a fraudulent MT. The
+// fraud proof must carry its block context.
+type InvalidRevealEvidence struct {
+	Proof *FraudProof `json:"proof"`
+}
+
+// Offense implements SlashingEvidence
+func (e *InvalidRevealEvidence) Offense() SlashingOffense { return OffenseInvalidReveal }
+
+// Height returns the number of the B2 block the fraudulent MT was published in
+func (e *InvalidRevealEvidence) Height() uint64 {
+	if e.Proof == nil || e.Proof.B2Header == nil || e.Proof.B2Header.Number == nil {
+		return 0
+	}
+	return e.Proof.B2Header.Number.Uint64()
+}
+
+// DoubleProposalEvidence proves that a leader signed two different PHT orderings
+// for the same B1 block
+type DoubleProposalEvidence struct {
+	First           *SealProposal `json:"first"`
+	Second          *SealProposal `json:"second"`
+	FirstSignature  hexutil.Bytes `json:"firstSignature"`
+	SecondSignature hexutil.Bytes `json:"secondSignature"`
+}
+
+// Offense implements SlashingEvidence
+func (e *DoubleProposalEvidence) Offense() SlashingOffense { return OffenseDoubleProposal }
+
+// Height returns the number of the B1 block proposed twice
+func (e *DoubleProposalEvidence) Height() uint64 {
+	if e.First == nil {
+		return 0
+	}
+	return e.First.BlockNumber
+}
+
+// EquivocationEvidence proves that a committee member co-signed two conflicting
+// seal proposals for the same B1 block
+type EquivocationEvidence struct {
+	Signer          common.Address `json:"signer"`
+	First           *SealProposal  `json:"first"`
+	Second          *SealProposal  `json:"second"`
+	FirstSignature  hexutil.Bytes  `json:"firstSignature"`
+	SecondSignature hexutil.Bytes  `json:"secondSignature"`
+}
+
+// Offense implements SlashingEvidence
+func (e *EquivocationEvidence) Offense() SlashingOffense { return OffenseEquivocation }
+
+// Height returns the number of the B1 block the conflicting proposals are for
+func (e *EquivocationEvidence) Height() uint64 {
+	if e.First == nil {
+		return 0
+	}
+	return e.First.BlockNumber
+}
+
+// SlashingRecord is the outcome of slashing a validator for an offense
+type SlashingRecord struct {
+	Validator     common.Address              `json:"validator"`
+	Offense       SlashingOffense             `json:"offense"`
+	Height        uint64                      `json:"height"`
+	Amount        *big.Int                    `json:"amount"`        // Stake removed from the offender
+	Burned        *big.Int                    `json:"burned"`        // Part of the amount destroyed
+	Redistributed map[common.Address]*big.Int `json:"redistributed"` // Rest of the amount, by receiving validator
+}
+
+// slashingKey identifies an offense, so it is slashed once whatever evidence proves it
+type slashingKey struct {
+	validator common.Address
+	offense   SlashingOffense
+	height    uint64
+}
+
+// SlashingManager verifies evidence of validator offenses and slashes the
+// offenders. A fraction of the offender's own stake, unbonding stake included, is
+// removed, part of it burned and the rest redistributed to the other active
+// validators by effective stake.
+type SlashingManager struct {
+	validators  *ValidatorManager
+	mtManager   *MTManager
+	fractionBps uint64 // Share of the offender's stake slashed
+	burnBps     uint64 // Share of the slashed stake burned
+	history     map[common.Address][]*SlashingRecord
+	slashed     map[slashingKey]bool
+	mu          sync.Mutex
+}
+
+// NewSlashingManager creates a slashing manager removing fractionBps basis points
+// of an offender's stake per offense and burning burnBps basis points of it
+func NewSlashingManager(validators *ValidatorManager, mtManager *MTManager, fractionBps, burnBps uint64) *SlashingManager {
+	if fractionBps > basisPoints {
+		fractionBps = basisPoints
+	}
+	if burnBps > basisPoints {
+		burnBps = basisPoints
+	}
+	return &SlashingManager{
+		validators:  validators,
+		mtManager:   mtManager,
+		fractionBps: fractionBps,
+		burnBps:     burnBps,
+		history:     make(map[common.Address][]*SlashingRecord),
+		slashed:     make(map[slashingKey]bool),
+	}
+}
+
+// Verify checks that evidence proves an offense and returns the offender
+func (s *SlashingManager) Verify(evidence SlashingEvidence) (common.Address, error) {
+	switch evidence := evidence.(type) {
+	case *InvalidRevealEvidence:
+		if evidence.Proof == nil {
+			return common.Address{}, fmt.Errorf("%w: missing fraud proof", ErrInvalidEvidence)
+		}
+		proposer, ok := evidence.Proof.Proposer()
+		if !ok {
+			return common.Address{}, fmt.Errorf("%w: fraud proof without block context", ErrInvalidEvidence)
+		}
+		if err := s.mtManager.VerifyFraudProof(evidence.Proof); err != nil {
+			return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+		}
+		return proposer, nil
+
+	case *DoubleProposalEvidence:
+		if evidence.First == nil || evidence.Second == nil || evidence.First.Leader != evidence.Second.Leader {
+			return common.Address{}, fmt.Errorf("%w: proposals of different leaders", ErrInvalidEvidence)
+		}
+		leader := evidence.First.Leader
+		if err := verifyConflictingProposals(leader, evidence.First, evidence.Second, evidence.FirstSignature, evidence.SecondSignature); err != nil {
+			return common.Address{}, err
+		}
+		return leader, nil
+
+	case *EquivocationEvidence:
+		if evidence.First == nil || evidence.Second == nil {
+			return common.Address{}, fmt.Errorf("%w: missing proposal", ErrInvalidEvidence)
+		}
+		if evidence.First.Leader == evidence.Signer && evidence.Second.Leader == evidence.Signer {
+			return common.Address{}, fmt.Errorf("%w: proposals of the signer are a double proposal", ErrInvalidEvidence)
+		}
+		if err := verifyConflictingProposals(evidence.Signer, evidence.First, evidence.Second, evidence.FirstSignature, evidence.SecondSignature); err != nil {
+			return common.Address{}, err
+		}
+		return evidence.Signer, nil
+
+	case *DoubleSignEvidence:
+		if evidence.First == nil {
+			return common.Address{}, fmt.Errorf("%w: missing signature", ErrInvalidEvidence)
+		}
+		validator := s.validators.GetValidator(evidence.First.Validator)
+		if validator == nil {
+			return common.Address{}, fmt.Errorf("%w: signer %s is not a validator", ErrInvalidEvidence, evidence.First.Validator.Hex())
+		}
+		if err := evidence.verify(validator.BLSPublicKey); err != nil {
+			return common.Address{}, err
+		}
+		return validator.Address, nil
+
+	default:
+		return common.Address{}, fmt.Errorf("%w: unknown evidence %T", ErrInvalidEvidence, evidence)
+	}
+}
+
+// verifyConflictingProposals checks that two different seal proposals for the
+// same block were both signed by signer
+func verifyConflictingProposals(signer common.Address, first, second *SealProposal, firstSig, secondSig []byte) error {
+	if first.BlockNumber != second.BlockNumber {
+		return fmt.Errorf("%w: proposals for blocks %d and %d", ErrInvalidEvidence, first.BlockNumber, second.BlockNumber)
+	}
+	firstHash, secondHash := first.SigningHash(), second.SigningHash()
+	if firstHash == secondHash {
+		return fmt.Errorf("%w: proposals do not conflict", ErrInvalidEvidence)
+	}
+	for i, signed := range []struct {
+		hash common.Hash
+		sig  []byte
+	}{{firstHash, firstSig}, {secondHash, secondSig}} {
+		recovered, err := recoverSealSigner(signed.hash, signed.sig)
+		if err != nil || recovered != signer {
+			return fmt.Errorf("%w: proposal %d not signed by %s", ErrInvalidEvidence, i+1, signer.Hex())
+		}
+	}
+	return nil
+}
+
+// Submit verifies evidence and slashes the offender, once per offense and height
+func (s *SlashingManager) Submit(evidence SlashingEvidence) (*SlashingRecord, error) {
+	offender, err := s.Verify(evidence)
+	if err != nil {
+		return nil, err
+	}
+	if !s.validators.IsValidator(offender) {
+		return nil, fmt.Errorf("%w: offender %s is not a validator", ErrInvalidEvidence, offender.Hex())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := slashingKey{validator: offender, offense: evidence.Offense(), height: evidence.Height()}
+	if s.slashed[key] {
+		return nil, fmt.Errorf("%w: %s by %s at %d", ErrDuplicateEvidence, key.offense, offender.Hex(), key.height)
+	}
+
+	amount := new(big.Int).Mul(s.validators.SlashableStake(offender), new(big.Int).SetUint64(s.fractionBps))
+	amount.Div(amount, big.NewInt(basisPoints))
+	if amount.Sign() > 0 {
+		if amount, err = s.validators.Slash(offender, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	record := &SlashingRecord{
+		Validator:     offender,
+		Offense:       key.offense,
+		Height:        key.height,
+		Amount:        amount,
+		Redistributed: s.redistribute(offender, amount),
+	}
+	record.Burned = new(big.Int).Set(amount)
+	for _, share := range record.Redistributed {
+		record.Burned.Sub(record.Burned, share)
+	}
+
+	s.slashed[key] = true
+	s.history[offender] = append(s.history[offender], record)
+	return record, nil
+}
+
+// redistribute splits the unburned part of a slashed amount among the other
+// active validators by effective stake, rounding down
+func (s *SlashingManager) redistribute(offender common.Address, amount *big.Int) map[common.Address]*big.Int {
+	pool := new(big.Int).Mul(amount, new(big.Int).SetUint64(basisPoints-s.burnBps))
+	pool.Div(pool, big.NewInt(basisPoints))
+
+	shares := make(map[common.Address]*big.Int)
+	if pool.Sign() == 0 {
+		return shares
+	}
+	validators := s.validators.GetActiveValidators()
+	delete(validators, offender)
+
+	total := new(big.Int)
+	for _, validator := range validators {
+		total.Add(total, validator.EffectiveStake())
+	}
+	if total.Sign() == 0 {
+		return shares
+	}
+	for address, validator := range validators {
+		share := new(big.Int).Mul(pool, validator.EffectiveStake())
+		if share.Div(share, total); share.Sign() > 0 {
+			shares[address] = share
+		}
+	}
+	return shares
+}
+
+// History returns the slashings of a validator, oldest first
+func (s *SlashingManager) History(validator common.Address) []*SlashingRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*SlashingRecord(nil), s.history[validator]...)
+}
+
+// sortedShares returns the receivers of redistributed stake in address order
+func (r *SlashingRecord) sortedShares() []common.Address {
+	receivers := make([]common.Address, 0, len(r.Redistributed))
+	for address := range r.Redistributed {
+		receivers = append(receivers, address)
+	}
+	sort.Slice(receivers, func(i, j int) bool {
+		return bytes.Compare(receivers[i][:], receivers[j][:]) < 0
+	})
+	return receivers
+}
+
+// verifyRevealEvidence checks fraud evidence against the B1/B2 pair this node
+// holds: both headers must be of known blocks, the B2 block sealed by the
+// proposer being slashed. Returns the offender. The caller must hold the lock.
+func (p *P2SConsensus) verifyRevealEvidence(evidence *InvalidRevealEvidence) (common.Address, error) {
+	if evidence == nil || evidence.Proof == nil || evidence.Proof.B1Header == nil || evidence.Proof.B2Header == nil {
+		return common.Address{}, fmt.Errorf("%w: fraud proof without block context", ErrInvalidEvidence)
+	}
+	b1Hash, b2Hash := evidence.Proof.B1Header.Hash(), evidence.Proof.B2Header.Hash()
+	if _, exists := p.cache.GetB1Block(b1Hash); !exists {
+		return common.Address{}, fmt.Errorf("%w: unknown B1 block %s", ErrInvalidEvidence, b1Hash.Hex())
+	}
+	b2Block, exists := p.cache.GetB2Block(b2Hash)
+	if !exists {
+		return common.Address{}, fmt.Errorf("%w: unknown B2 block %s", ErrInvalidEvidence, b2Hash.Hex())
+	}
+	if b2Block.B1BlockHash != b1Hash {
+		return common.Address{}, fmt.Errorf("%w: B2 block %s does not reveal B1 block %s", ErrInvalidEvidence, b2Hash.Hex(), b1Hash.Hex())
+	}
+	proposer, err := recoverProposer(2, b2Block.Header, b2Block.ProposerSig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidEvidence, err)
+	}
+	offender, err := p.slashing.Verify(evidence)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if offender != proposer {
+		return common.Address{}, fmt.Errorf("%w: B2 block sealed by %s, not %s", ErrInvalidEvidence, proposer.Hex(), offender.Hex())
+	}
+	return offender, nil
+}
+
+// SubmitRevealEvidence verifies evidence of a fraudulent reveal and queues it for
+// inclusion in the next B1 block this node produces. The proposer is slashed
+// when the block including the evidence is processed.
+func (p *P2SConsensus) SubmitRevealEvidence(evidence *InvalidRevealEvidence) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.verifyRevealEvidence(evidence); err != nil {
+		return err
+	}
+	hash := evidence.Proof.B2Header.Hash()
+	for _, pending := range p.revealEvidence {
+		if pending.Proof.B2Header.Hash() == hash && pending.Proof.MT.TxHash == evidence.Proof.MT.TxHash {
+			return fmt.Errorf("%w: evidence already pending", ErrDuplicateEvidence)
+		}
+	}
+	if len(p.revealEvidence) >= maxPendingRevealEvidence {
+		return errors.New("too much pending reveal evidence")
+	}
+	p.revealEvidence = append(p.revealEvidence, evidence)
+	return nil
+}
+
+// takeRevealEvidence returns the pending fraud evidence for inclusion in a B1
+// block, dropping evidence no longer valid. The caller must hold the lock.
+func (p *P2SConsensus) takeRevealEvidence() []*InvalidRevealEvidence {
+	var included []*InvalidRevealEvidence
+	for _, evidence := range p.revealEvidence {
+		if _, err := p.verifyRevealEvidence(evidence); err != nil {
+			log.Debug("Dropped stale reveal evidence", "err", err)
+			continue
+		}
+		included = append(included, evidence)
+	}
+	p.revealEvidence = nil
+	return included
+}
+
+// applyBlockEvidence slashes the offenders proven by the fraud evidence a B1
+// block includes, at the block's height. The caller must hold the lock.
+func (p *P2SConsensus) applyBlockEvidence(number uint64, b1Block *B1Block) []*SlashingRecord {
+	var records []*SlashingRecord
+	for _, evidence := range b1Block.RevealEvidence {
+		record, err := p.slash(number, evidence)
+		if err != nil {
+			log.Warn("Failed to apply reveal evidence", "number", number, "err", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,317 @@
+package p2s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Trusted setup artifact file names within P2SConfig.SNARKSetupDir
+const (
+	snarkProvingKeyFile   = "mt_opening.pk"
+	snarkVerifyingKeyFile = "mt_opening.vk"
+)
+
+var (
+	// ErrMissingSNARKSetup is returned when no trusted setup directory is configured
+	ErrMissingSNARKSetup = errors.New("SNARK setup directory not configured")
+
+	// ErrSNARKVerifyingKey is returned when the loaded verifying key is not the expected one
+	ErrSNARKVerifyingKey = errors.New("SNARK verifying key hash mismatch")
+
+	// ErrMissingProvingKey is returned when proving on a node whose setup has no proving key
+	ErrMissingProvingKey = errors.New("SNARK proving key not available")
+
+	// ErrSNARKCommitmentScheme is returned when SNARK proofs are configured with a
+	// commitment scheme the opening circuit cannot open
+	ErrSNARKCommitmentScheme = errors.New("SNARK proofs need MiMC commitments")
+
+	// ErrOpeningRequired is returned when a proof system that proves commitment
+	// openings is asked for a proof without the blinding factor
+	ErrOpeningRequired = errors.New("proof system proves commitment openings only")
+)
+
+// OpeningProver is implemented by proof systems that prove the opening of a
+// commitment itself and need its blinding factor to do so. Like Prove, data are
+// the proof leaves: the commitment followed by the committed items.
+type OpeningProver interface {
+	ProveOpening(commitment, blinding []byte, data ...[]byte) ([]byte, error)
+}
+
+// proveOpening proves with a proof system, passing the blinding factor to proof
+// systems that prove openings
+func proveOpening(system ProofSystem, commitment, blinding []byte, data [][]byte) ([]byte, error) {
+	if prover, ok := system.(OpeningProver); ok {
+		return prover.ProveOpening(commitment, blinding, data...)
+	}
+	return system.Prove(commitment, data...)
+}
+
+// mtOpeningCircuit proves knowledge of the blinding element opening a MiMC
+// commitment to the revealed fields of an MT: the MiMC hash of the blinding
+// element followed by the item count and the Keccak256 hashes of the fields, as
+// MiMCCommitment computes it, equals the public commitment. Verifiers map
+// the MT's fields to the items instead of rebuilding a Merkle tree.
+type mtOpeningCircuit struct {
+	Commitment frontend.Variable                   `gnark:",public"`
+	Items      [mimcMaxItems + 1]frontend.Variable `gnark:",public"`
+	Blinding   frontend.Variable
+}
+
+// Define declares the circuit constraints
+func (c *mtOpeningCircuit) Define(api frontend.API) error {
+	hasher, err := mimc.NewMiMC(api)
+	if err != nil {
+		return err
+	}
+	hasher.Write(c.Blinding)
+	hasher.Write(c.Items[:]...)
+	api.AssertIsEqual(hasher.Sum(), c.Commitment)
+	return nil
+}
+
+// snarkStatement splits proof leaves into the commitment and its items as field
+// elements. The first leaf must be the commitment.
+func snarkStatement(commitment []byte, data [][]byte) (*big.Int, []*big.Int, error) {
+	if len(data) < 2 || !bytes.Equal(data[0], commitment) {
+		return nil, nil, errors.New("proof leaves must start with the commitment")
+	}
+	element, ok := mimcElement(commitment)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: not a MiMC commitment", ErrSNARKCommitmentScheme)
+	}
+	items, err := mimcItems(data[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]*big.Int, len(items))
+	for i := range items {
+		values[i] = items[i].BigInt(new(big.Int))
+	}
+	return element.BigInt(new(big.Int)), values, nil
+}
+
+// snarkSetup holds the compiled opening circuit and the keys of a trusted setup
+type snarkSetup struct {
+	ccs    constraint.ConstraintSystem
+	pk     groth16.ProvingKey // Nil on nodes that only verify
+	vk     groth16.VerifyingKey
+	vkHash common.Hash
+}
+
+var (
+	// Compiled opening circuit, shared by all setups
+	openingCircuit     constraint.ConstraintSystem
+	openingCircuitErr  error
+	openingCircuitOnce sync.Once
+
+	// Loaded setups by directory, so verifying keys are parsed once per process
+	snarkSetups   = make(map[string]*snarkSetup)
+	snarkSetupsMu sync.Mutex
+)
+
+// compileOpeningCircuit compiles the opening circuit to R1CS once
+func compileOpeningCircuit() (constraint.ConstraintSystem, error) {
+	openingCircuitOnce.Do(func() {
+		openingCircuit, openingCircuitErr = frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, new(mtOpeningCircuit))
+	})
+	return openingCircuit, openingCircuitErr
+}
+
+// loadSNARKSetup loads the trusted setup artifacts in dir, or returns them from
+// the cache. The proving key is optional; without it the setup only verifies.
+func loadSNARKSetup(dir string) (*snarkSetup, error) {
+	dir = filepath.Clean(dir)
+
+	snarkSetupsMu.Lock()
+	defer snarkSetupsMu.Unlock()
+
+	if setup, exists := snarkSetups[dir]; exists {
+		return setup, nil
+	}
+	ccs, err := compileOpeningCircuit()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := os.ReadFile(filepath.Join(dir, snarkVerifyingKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	vk := groth16.NewVerifyingKey(ecc.BN254)
+	if _, err := vk.ReadFrom(bytes.NewReader(encoded)); err != nil {
+		return nil, fmt.Errorf("invalid verifying key: %w", err)
+	}
+	setup := &snarkSetup{ccs: ccs, vk: vk, vkHash: crypto.Keccak256Hash(encoded)}
+
+	encoded, err = os.ReadFile(filepath.Join(dir, snarkProvingKeyFile))
+	switch {
+	case err == nil:
+		setup.pk = groth16.NewProvingKey(ecc.BN254)
+		if _, err := setup.pk.ReadFrom(bytes.NewReader(encoded)); err != nil {
+			return nil, fmt.Errorf("invalid proving key: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	snarkSetups[dir] = setup
+	return setup, nil
+}
+
+// GenerateSNARKSetup runs a single-party Groth16 setup of the opening circuit,
+// writes its artifacts to dir and returns the verifying key hash. Whoever runs it
+// can forge proofs, so it is meant for development networks only; production
+// networks load the artifacts of a multi-party ceremony.
+func GenerateSNARKSetup(dir string) (common.Hash, error) {
+	ccs, err := compileOpeningCircuit()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	var pkBuf, vkBuf bytes.Buffer
+	if _, err := pk.WriteTo(&pkBuf); err != nil {
+		return common.Hash{}, err
+	}
+	if _, err := vk.WriteTo(&vkBuf); err != nil {
+		return common.Hash{}, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return common.Hash{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snarkProvingKeyFile), pkBuf.Bytes(), 0o644); err != nil {
+		return common.Hash{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, snarkVerifyingKeyFile), vkBuf.Bytes(), 0o644); err != nil {
+		return common.Hash{}, err
+	}
+
+	// Drop any setup previously loaded from the directory
+	snarkSetupsMu.Lock()
+	delete(snarkSetups, filepath.Clean(dir))
+	snarkSetupsMu.Unlock()
+
+	return crypto.Keccak256Hash(vkBuf.Bytes()), nil
+}
+
+// SNARKProofSystem proves with Groth16 over BN254 that an MT's revealed fields
+// open the MiMC commitment of its PHT, without revealing the blinding element
+type SNARKProofSystem struct {
+	setup *snarkSetup
+}
+
+// NewSNARKProofSystem creates a SNARK proof system from the trusted setup in
+// setupDir. A non-zero vkHash pins the verifying key so nodes cannot be pointed
+// at the artifacts of another setup.
+func NewSNARKProofSystem(setupDir string, vkHash common.Hash) (*SNARKProofSystem, error) {
+	if setupDir == "" {
+		return nil, ErrMissingSNARKSetup
+	}
+	setup, err := loadSNARKSetup(setupDir)
+	if err != nil {
+		return nil, err
+	}
+	if vkHash != (common.Hash{}) && setup.vkHash != vkHash {
+		return nil, fmt.Errorf("%w: have %s, want %s", ErrSNARKVerifyingKey, setup.vkHash.Hex(), vkHash.Hex())
+	}
+	return &SNARKProofSystem{setup: setup}, nil
+}
+
+// VerifyingKeyHash returns the hash of the loaded verifying key
+func (s *SNARKProofSystem) VerifyingKeyHash() common.Hash {
+	return s.setup.vkHash
+}
+
+// ProofSystemID returns the identifier embedded in SNARK proofs
+func (s *SNARKProofSystem) ProofSystemID() uint8 {
+	return ProofSystemIDGroth16
+}
+
+// ProofVersion returns the version of the opening circuit proofs are made for
+func (s *SNARKProofSystem) ProofVersion() uint8 {
+	return 1
+}
+
+// Prove fails: opening proofs need the blinding factor, see ProveOpening
+func (s *SNARKProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
+	return nil, ErrOpeningRequired
+}
+
+// ProveOpening proves that the blinding element opens the commitment to data
+func (s *SNARKProofSystem) ProveOpening(commitment, blinding []byte, data ...[]byte) ([]byte, error) {
+	if s.setup.pk == nil {
+		return nil, ErrMissingProvingKey
+	}
+	element, ok := mimcElement(blinding)
+	if !ok {
+		return nil, ErrMissingOpening
+	}
+	public, items, err := snarkStatement(commitment, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The prover fails on an unsatisfied circuit, but checking first gives a
+	// clearer error
+	if !NewMiMCCommitment().Verify(commitment, blinding, data[1:]...) {
+		return nil, ErrInvalidOpening
+	}
+	assignment := &mtOpeningCircuit{Commitment: public, Blinding: element.BigInt(new(big.Int))}
+	for i, item := range items {
+		assignment.Items[i] = item
+	}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	proof, err := groth16.Prove(s.setup.ccs, s.setup.pk, witness)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	if _, err := proof.WriteTo(&encoded); err != nil {
+		return nil, err
+	}
+	return encoded.Bytes(), nil
+}
+
+// Verify verifies a proof that data opens the commitment
+func (s *SNARKProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
+	public, items, err := snarkStatement(commitment, data)
+	if err != nil {
+		return false
+	}
+
+	decoded := groth16.NewProof(ecc.BN254)
+	n, err := decoded.ReadFrom(bytes.NewReader(proof))
+	if err != nil || n != int64(len(proof)) {
+		return false
+	}
+	assignment := &mtOpeningCircuit{Commitment: public, Blinding: 0}
+	for i, item := range items {
+		assignment.Items[i] = item
+	}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return false
+	}
+	return groth16.Verify(decoded, s.setup.vk, witness) == nil
+}
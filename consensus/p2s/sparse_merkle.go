@@ -0,0 +1,232 @@
+package p2s
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Sparse Merkle trees have a leaf for every 256-bit key, empty unless set. Empty
+// subtrees hash to zero at every height so only the paths to set keys are hashed.
+// Proofs start with a 32-byte bitmap whose bit i is set when the sibling at level i,
+// counted from the leaf level up, is not empty, followed by the 32-byte non-empty
+// siblings from the leaf level up. The same proof shows a key set to a value or,
+// against an empty leaf, not set at all.
+const (
+	smtDepth      = 256
+	smtBitmapSize = smtDepth / 8
+)
+
+// smtEmpty is the hash of an empty subtree
+var smtEmpty = make([]byte, merkleNodeSize)
+
+// SparseMerkleTree is a sparse Merkle tree keyed by 32-byte hashes, supporting
+// proofs of inclusion and non-inclusion
+type SparseMerkleTree struct {
+	leaves map[common.Hash][]byte // Leaf hashes of set keys
+}
+
+// NewSparseMerkleTree creates an empty sparse Merkle tree
+func NewSparseMerkleTree() *SparseMerkleTree {
+	return &SparseMerkleTree{leaves: make(map[common.Hash][]byte)}
+}
+
+// Update sets a key to a value
+func (t *SparseMerkleTree) Update(key common.Hash, value []byte) {
+	t.leaves[key] = smtLeaf(key, value)
+}
+
+// Delete clears a key
+func (t *SparseMerkleTree) Delete(key common.Hash) {
+	delete(t.leaves, key)
+}
+
+// Has reports whether a key is set
+func (t *SparseMerkleTree) Has(key common.Hash) bool {
+	_, ok := t.leaves[key]
+	return ok
+}
+
+// Root returns the root hash of the tree, zero when empty
+func (t *SparseMerkleTree) Root() common.Hash {
+	return common.BytesToHash(t.subtree(t.sortedKeys(), 0))
+}
+
+// Prove creates a proof for a key: of its value if set, of its absence otherwise
+func (t *SparseMerkleTree) Prove(key common.Hash) []byte {
+	siblings := make([][]byte, smtDepth)
+	keys := t.sortedKeys()
+	for depth := 0; depth < smtDepth; depth++ {
+		split := smtSplit(keys, depth)
+		if smtBit(key, depth) == 0 {
+			siblings[depth] = t.subtree(keys[split:], depth+1)
+			keys = keys[:split]
+		} else {
+			siblings[depth] = t.subtree(keys[:split], depth+1)
+			keys = keys[split:]
+		}
+	}
+
+	proof := make([]byte, smtBitmapSize)
+	for level := 0; level < smtDepth; level++ {
+		sibling := siblings[smtDepth-1-level]
+		if constantTimeEqual(sibling, smtEmpty) {
+			continue
+		}
+		proof[smtBitmapSize-1-level/8] |= 1 << (level % 8)
+		proof = append(proof, sibling...)
+	}
+	return proof
+}
+
+// sortedKeys returns the set keys in ascending order, the order of their leaves
+func (t *SparseMerkleTree) sortedKeys() []common.Hash {
+	keys := make([]common.Hash, 0, len(t.leaves))
+	for key := range t.leaves {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Cmp(keys[j]) < 0 })
+	return keys
+}
+
+// subtree hashes the subtree at depth holding the sorted keys, which share the
+// subtree's path
+func (t *SparseMerkleTree) subtree(keys []common.Hash, depth int) []byte {
+	if len(keys) == 0 {
+		return smtEmpty
+	}
+	if depth == smtDepth {
+		return t.leaves[keys[0]]
+	}
+	split := smtSplit(keys, depth)
+	return smtNode(t.subtree(keys[:split], depth+1), t.subtree(keys[split:], depth+1))
+}
+
+// smtSplit returns the index of the first sorted key going right at depth
+func smtSplit(keys []common.Hash, depth int) int {
+	return sort.Search(len(keys), func(i int) bool { return smtBit(keys[i], depth) == 1 })
+}
+
+// smtBit returns the bit of a key choosing the child at depth, from the root down
+func smtBit(key common.Hash, depth int) byte {
+	return key[depth/8] >> (7 - depth%8) & 1
+}
+
+// smtLeaf hashes a set key and its value
+func smtLeaf(key common.Hash, value []byte) []byte {
+	return merkleHash(merkleLeafPrefix, key.Bytes(), crypto.Keccak256(value))
+}
+
+// smtNode hashes two children, keeping empty subtrees empty
+func smtNode(left, right []byte) []byte {
+	if constantTimeEqual(left, smtEmpty) && constantTimeEqual(right, smtEmpty) {
+		return smtEmpty
+	}
+	return merkleHash(merkleNodePrefix, left, right)
+}
+
+// smtRoot computes the root a proof leads to from the leaf hash of a key
+func smtRoot(key common.Hash, leaf []byte, proof []byte) ([]byte, bool) {
+	if len(proof) < smtBitmapSize {
+		return nil, false
+	}
+	bitmap, siblings := proof[:smtBitmapSize], proof[smtBitmapSize:]
+
+	current := leaf
+	for level := 0; level < smtDepth; level++ {
+		sibling := smtEmpty
+		if bitmap[smtBitmapSize-1-level/8]>>(level%8)&1 == 1 {
+			if len(siblings) < merkleNodeSize {
+				return nil, false
+			}
+			sibling, siblings = siblings[:merkleNodeSize], siblings[merkleNodeSize:]
+
+			// Empty siblings are left out, keeping proofs canonical
+			if constantTimeEqual(sibling, smtEmpty) {
+				return nil, false
+			}
+		}
+		if smtBit(key, smtDepth-1-level) == 1 {
+			current = smtNode(sibling, current)
+		} else {
+			current = smtNode(current, sibling)
+		}
+	}
+	return current, len(siblings) == 0
+}
+
+// VerifySMTInclusion verifies a proof that key is set to value in the sparse
+// Merkle tree with the given root
+func VerifySMTInclusion(root, key common.Hash, value []byte, proof []byte) bool {
+	computed, ok := smtRoot(key, smtLeaf(key, value), proof)
+	return ok && constantTimeEqual(computed, root.Bytes())
+}
+
+// VerifySMTNonInclusion verifies a proof that key is not set in the sparse Merkle
+// tree with the given root
+func VerifySMTNonInclusion(root, key common.Hash, proof []byte) bool {
+	computed, ok := smtRoot(key, smtEmpty, proof)
+	return ok && constantTimeEqual(computed, root.Bytes())
+}
+
+// MTTree returns the sparse Merkle tree of a B2 block's MTs keyed by their PHT
+// hash, with their MT root leaves as values. A non-inclusion proof against it
+// shows a PHT left out of the B2 block altogether.
+func MTTree(mts []*MTTransaction) *SparseMerkleTree {
+	tree := NewSparseMerkleTree()
+	for _, mt := range mts {
+		tree.Update(mt.PHTHash, mtLeaf(mt).Bytes())
+	}
+	return tree
+}
+
+// SMTProofSystem implements proofs over a sparse Merkle tree of the data leaves,
+// each keyed by its hash
+type SMTProofSystem struct{}
+
+// NewSMTProofSystem creates a new sparse Merkle tree proof system
+func NewSMTProofSystem() *SMTProofSystem {
+	return &SMTProofSystem{}
+}
+
+// ProofSystemID returns the identifier embedded in SMT proofs
+func (s *SMTProofSystem) ProofSystemID() uint8 {
+	return ProofSystemIDSMT
+}
+
+// ProofVersion returns the version of the SMT proof format
+func (s *SMTProofSystem) ProofVersion() uint8 {
+	return 1
+}
+
+// Prove creates a proof that the commitment is one of the data leaves
+func (s *SMTProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no data to prove")
+	}
+	tree := s.tree(data)
+	key := crypto.Keccak256Hash(commitment)
+	if !tree.Has(key) {
+		return nil, errors.New("commitment not found in tree")
+	}
+	return tree.Prove(key), nil
+}
+
+// Verify verifies a proof that the commitment is one of the data leaves
+func (s *SMTProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	return VerifySMTInclusion(s.tree(data).Root(), crypto.Keccak256Hash(commitment), commitment, proof)
+}
+
+// tree builds the sparse Merkle tree of data leaves keyed by their hash
+func (s *SMTProofSystem) tree(data [][]byte) *SparseMerkleTree {
+	tree := NewSparseMerkleTree()
+	for _, d := range data {
+		tree.Update(crypto.Keccak256Hash(d), d)
+	}
+	return tree
+}
@@ -0,0 +1,382 @@
+package p2s
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Events of the staking contract, each with the validator as its only indexed
+// argument and the amount as its data
+var (
+	stakingDepositTopic    = crypto.Keccak256Hash([]byte("Deposit(address,uint256)"))
+	stakingWithdrawalTopic = crypto.Keccak256Hash([]byte("Withdrawal(address,uint256)"))
+)
+
+// stakingStateKey -> JSON encoded stakingState of the staking contract watcher
+var stakingStateKey = []byte("p2s-staking-state")
+
+// Kinds of staking contract events
+const (
+	StakingDeposit    = "deposit"
+	StakingWithdrawal = "withdrawal"
+)
+
+// StakingEvent is a deposit to or withdrawal from the staking contract applied to
+// the validator set
+type StakingEvent struct {
+	Kind      string         `json:"kind"`
+	Validator common.Address `json:"validator"`
+	Amount    *big.Int       `json:"amount"`
+	Stake     *big.Int       `json:"stake"` // Deposited stake of the validator after the event
+	Block     uint64         `json:"block"`
+	TxHash    common.Hash    `json:"txHash"`
+}
+
+// stakingReorgDepth is how many blocks below the newest processed one the
+// watcher keeps the undo data of, so reorganisations up to that depth revert
+const stakingReorgDepth = 128
+
+// stakingUndo is the state of an address before a block first changed it
+type stakingUndo struct {
+	Address   common.Address  `json:"address"`
+	Validator json.RawMessage `json:"validator"`         // Checkpoint of the validator set entry
+	Pending   *big.Int        `json:"pending,omitempty"` // Pending deposit, nil if none
+}
+
+// stakingBlock is a processed block, keyed by its hash. Blocks of every branch
+// are kept, so switching back to a branch replays its events.
+type stakingBlock struct {
+	Number uint64          `json:"number"`
+	Hash   common.Hash     `json:"hash"`
+	Parent common.Hash     `json:"parent"` // Block the staking state of this one builds on
+	Events []*StakingEvent `json:"events"`
+	Undo   []*stakingUndo  `json:"undo,omitempty"` // Set while the block is applied
+}
+
+// stakingState is the persisted state of the staking contract watcher
+type stakingState struct {
+	LastBlock uint64                      `json:"lastBlock"`
+	Head      common.Hash                 `json:"head"`
+	Pending   map[common.Address]*big.Int `json:"pending"`
+	Blocks    []*stakingBlock             `json:"blocks,omitempty"`
+}
+
+// StakingWatcher derives the validator set from the events of a staking contract.
+// Deposits raise a validator's stake, adding it once its deposits reach the
+// minimum stake; withdrawals lower it, removing the validator once nothing is
+// left. Withdrawn stake unbonds like any other stake decrease.
+//
+// Processed blocks are kept by hash with the state they changed, so a block
+// building on another branch first reverts the current one down to the common
+// ancestor and replays the new branch.
+type StakingWatcher struct {
+	validators *ValidatorManager
+	contract   common.Address
+	lastBlock  uint64
+	head       common.Hash // Last applied block, zero before the first
+	blocks     map[common.Hash]*stakingBlock
+	pending    map[common.Address]*big.Int // Deposits of addresses that are not validators yet
+	db         ethdb.KeyValueStore
+	mu         sync.Mutex
+}
+
+// NewStakingWatcher creates a watcher applying the events of a staking contract
+// to the validator set
+func NewStakingWatcher(validators *ValidatorManager, contract common.Address) *StakingWatcher {
+	return &StakingWatcher{
+		validators: validators,
+		contract:   contract,
+		blocks:     make(map[common.Hash]*stakingBlock),
+		pending:    make(map[common.Address]*big.Int),
+	}
+}
+
+// SetDatabase attaches a database, restoring the persisted watcher state
+func (w *StakingWatcher) SetDatabase(db ethdb.KeyValueStore) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.db = db
+	has, err := db.Has(stakingStateKey)
+	if err != nil || !has {
+		return err
+	}
+	data, err := db.Get(stakingStateKey)
+	if err != nil {
+		return err
+	}
+	state := new(stakingState)
+	if err := json.Unmarshal(data, state); err != nil {
+		return err
+	}
+	w.lastBlock = state.LastBlock
+	w.head = state.Head
+	w.pending = make(map[common.Address]*big.Int)
+	for address, amount := range state.Pending {
+		w.pending[address] = bigOrZero(amount)
+	}
+	w.blocks = make(map[common.Hash]*stakingBlock)
+	for _, block := range state.Blocks {
+		w.blocks[block.Hash] = block
+	}
+	return nil
+}
+
+// ProcessReceipts applies the staking contract events in the receipts of a block
+// and returns them in log order. The parent is the block the staking state of
+// this one builds on; if it is not the last applied block, the watcher first
+// switches to it, reverting blocks of the abandoned branch. Blocks processed
+// before are only switched to.
+func (w *StakingWatcher) ProcessReceipts(number uint64, hash, parent common.Hash, receipts []*types.Receipt) []*StakingEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, known := w.blocks[hash]; known {
+		w.setHead(hash, number)
+		w.persist()
+		return nil
+	}
+	w.setHead(parent, number)
+
+	block := &stakingBlock{Number: number, Hash: hash, Parent: parent, Events: w.parse(number, receipts)}
+	events := w.applyBlock(block)
+	w.blocks[hash] = block
+	w.head = hash
+	w.lastBlock = number
+	w.prune()
+	w.persist()
+	return events
+}
+
+// parse returns the staking contract events in the receipts of a block. The
+// caller must hold the lock.
+func (w *StakingWatcher) parse(number uint64, receipts []*types.Receipt) []*StakingEvent {
+	var events []*StakingEvent
+	for _, receipt := range receipts {
+		for _, entry := range receipt.Logs {
+			if entry.Address != w.contract || entry.Removed || len(entry.Topics) == 0 {
+				continue
+			}
+			var kind string
+			switch entry.Topics[0] {
+			case stakingDepositTopic:
+				kind = StakingDeposit
+			case stakingWithdrawalTopic:
+				kind = StakingWithdrawal
+			default:
+				continue
+			}
+			if len(entry.Topics) != 2 || len(entry.Data) != common.HashLength {
+				log.Warn("Ignoring malformed staking event", "kind", kind, "block", number, "tx", entry.TxHash)
+				continue
+			}
+			events = append(events, &StakingEvent{
+				Kind:      kind,
+				Validator: common.BytesToAddress(entry.Topics[1].Bytes()),
+				Amount:    new(big.Int).SetBytes(entry.Data),
+				Block:     number,
+				TxHash:    entry.TxHash,
+			})
+		}
+	}
+	return events
+}
+
+// applyBlock applies the events of a block, recording the state they change for
+// a revert, and returns the applied events. The caller must hold the lock.
+func (w *StakingWatcher) applyBlock(block *stakingBlock) []*StakingEvent {
+	block.Undo = nil
+	touched := make(map[common.Address]bool)
+	var applied []*StakingEvent
+	for _, event := range block.Events {
+		if !touched[event.Validator] {
+			touched[event.Validator] = true
+			undo := &stakingUndo{Address: event.Validator, Validator: w.validators.checkpoint(event.Validator)}
+			if pending := w.pending[event.Validator]; pending != nil {
+				undo.Pending = new(big.Int).Set(pending)
+			}
+			block.Undo = append(block.Undo, undo)
+		}
+		if err := w.apply(event); err != nil {
+			log.Warn("Failed to apply staking event", "kind", event.Kind, "validator", event.Validator, "amount", event.Amount, "err", err)
+			continue
+		}
+		applied = append(applied, event)
+	}
+	return applied
+}
+
+// revertBlock returns the addresses a block changed to their state before it.
+// The caller must hold the lock.
+func (w *StakingWatcher) revertBlock(block *stakingBlock) {
+	for i := len(block.Undo) - 1; i >= 0; i-- {
+		undo := block.Undo[i]
+		if err := w.validators.rollback(undo.Validator); err != nil {
+			log.Error("Failed to revert staking event", "validator", undo.Address, "block", block.Number, "err", err)
+		}
+		if undo.Pending != nil {
+			w.pending[undo.Address] = new(big.Int).Set(undo.Pending)
+		} else {
+			delete(w.pending, undo.Address)
+		}
+	}
+	block.Undo = nil
+}
+
+// setHead switches the applied branch to end at a block, reverting the current
+// branch down to their common ancestor and replaying the blocks above it. For a
+// block the watcher does not know, the applied blocks at or above the height
+// of the block building on it are reverted. The caller must hold the lock.
+func (w *StakingWatcher) setHead(target common.Hash, number uint64) {
+	if target == w.head {
+		return
+	}
+	// Mark the applied branch down to the block below its oldest known one,
+	// then find where the target branch joins it
+	applied := make(map[common.Hash]bool)
+	for hash := w.head; ; {
+		applied[hash] = true
+		block, ok := w.blocks[hash]
+		if !ok {
+			break
+		}
+		hash = block.Parent
+	}
+	var replay []*stakingBlock
+	ancestor := target
+	for !applied[ancestor] {
+		block, ok := w.blocks[ancestor]
+		if !ok {
+			break
+		}
+		replay = append(replay, block)
+		ancestor = block.Parent
+	}
+	if !applied[ancestor] {
+		// The target branch is unknown, so only blocks that cannot be its
+		// ancestors are reverted
+		lowest := number
+		for {
+			block, ok := w.blocks[w.head]
+			if !ok {
+				if lowest > number {
+					log.Warn("Staking watcher reverted past its oldest block", "number", number, "parent", target)
+				}
+				break
+			}
+			if block.Number < number {
+				break
+			}
+			w.revertBlock(block)
+			w.head, lowest = block.Parent, block.Number
+		}
+		return
+	}
+	for w.head != ancestor {
+		block := w.blocks[w.head]
+		w.revertBlock(block)
+		w.head = block.Parent
+	}
+	for i := len(replay) - 1; i >= 0; i-- {
+		w.applyBlock(replay[i])
+		w.head = replay[i].Hash
+	}
+	if len(replay) > 0 {
+		log.Info("Switched staking watcher branch", "ancestor", ancestor, "head", w.head, "replayed", len(replay))
+	}
+}
+
+// prune drops the blocks too far below the last processed one to be reverted.
+// The caller must hold the lock.
+func (w *StakingWatcher) prune() {
+	if w.lastBlock <= stakingReorgDepth {
+		return
+	}
+	for hash, block := range w.blocks {
+		if block.Number < w.lastBlock-stakingReorgDepth {
+			delete(w.blocks, hash)
+		}
+	}
+}
+
+// apply updates the validator set for an event and sets the resulting stake. The
+// caller must hold the lock.
+func (w *StakingWatcher) apply(event *StakingEvent) error {
+	validator := w.validators.GetValidator(event.Validator)
+	stake := new(big.Int)
+	if validator != nil {
+		stake.Set(validator.Stake)
+	} else if pending := w.pending[event.Validator]; pending != nil {
+		stake.Set(pending)
+	}
+	if event.Kind == StakingDeposit {
+		stake.Add(stake, event.Amount)
+	} else if stake.Sub(stake, event.Amount); stake.Sign() < 0 {
+		stake.SetUint64(0)
+	}
+	event.Stake = stake
+
+	switch {
+	case validator != nil && stake.Sign() == 0:
+		if err := w.validators.UpdateStake(event.Validator, stake); err != nil {
+			return err
+		}
+		return w.validators.RemoveValidator(event.Validator)
+	case validator != nil:
+		return w.validators.UpdateStake(event.Validator, stake)
+	case stake.Cmp(w.validators.config.MinStake) >= 0:
+		if err := w.validators.AddValidator(event.Validator, stake); err != nil {
+			// Kept pending, so a later deposit retries once there is room
+			w.pending[event.Validator] = stake
+			return err
+		}
+		delete(w.pending, event.Validator)
+	case stake.Sign() > 0:
+		w.pending[event.Validator] = stake
+	default:
+		delete(w.pending, event.Validator)
+	}
+	return nil
+}
+
+// persist writes the watcher state to the attached database. The caller must
+// hold the lock.
+func (w *StakingWatcher) persist() {
+	if w.db == nil {
+		return
+	}
+	state := &stakingState{LastBlock: w.lastBlock, Head: w.head, Pending: w.pending}
+	for _, block := range w.blocks {
+		state.Blocks = append(state.Blocks, block)
+	}
+	sort.Slice(state.Blocks, func(i, j int) bool {
+		if state.Blocks[i].Number != state.Blocks[j].Number {
+			return state.Blocks[i].Number < state.Blocks[j].Number
+		}
+		return bytes.Compare(state.Blocks[i].Hash[:], state.Blocks[j].Hash[:]) < 0
+	})
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = w.db.Put(stakingStateKey, data)
+	}
+	if err != nil {
+		log.Error("Failed to persist staking watcher state", "err", err)
+	}
+}
+
+// PendingDeposit returns the deposits of an address that is not a validator yet
+func (w *StakingWatcher) PendingDeposit(address common.Address) *big.Int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return new(big.Int).Set(bigOrZero(w.pending[address]))
+}
@@ -0,0 +1,520 @@
+package p2s
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrNotFinalized is returned for decryption shares of PHTs whose B1 block is not final
+	ErrNotFinalized = errors.New("B1 block not finalized")
+
+	// ErrInvalidDecryptionShare is returned for shares that fail their correctness proof
+	ErrInvalidDecryptionShare = errors.New("invalid decryption share")
+
+	// ErrInsufficientShares is returned when fewer shares than the threshold are combined
+	ErrInsufficientShares = errors.New("insufficient decryption shares")
+
+	// ErrMissingThresholdKey is returned when hidden fields must be sealed or
+	// decrypted without a committee key
+	ErrMissingThresholdKey = errors.New("no threshold key configured")
+)
+
+// defaultDecryptorLimit is the number of encrypted PHTs, and of their recovered
+// hidden fields, the threshold decryptor retains
+const defaultDecryptorLimit = 4096
+
+// ThresholdKey is the public committee key hidden fields are encrypted to. Any
+// Threshold committee members can jointly decrypt; fewer learn nothing.
+type ThresholdKey struct {
+	Committee        []common.Address `json:"committee"`
+	Threshold        int              `json:"threshold"`
+	PublicKey        []byte           `json:"publicKey"`        // Compressed s·G
+	VerificationKeys [][]byte         `json:"verificationKeys"` // Compressed s_i·G per committee member
+}
+
+// ThresholdKeyShare is a committee member's secret share of the committee key
+type ThresholdKeyShare struct {
+	Index     int            `json:"index"` // 1-based position in the committee
+	Validator common.Address `json:"validator"`
+	Secret    []byte         `json:"secret"`
+}
+
+// HiddenFields are the PHT fields kept secret until B1 finalization
+type HiddenFields struct {
+	Recipient  common.Address `json:"recipient"`
+	Value      *big.Int       `json:"value"`
+	CallData   []byte         `json:"callData"`
+	TxType     uint8          `json:"txType"`
+	GasLimit   uint64         `json:"gasLimit"`
+	FieldSalts [][]byte       `json:"fieldSalts"`
+	Blinding   []byte         `json:"blinding"`
+
+	// Set for contract creations, whose recipient is left zero
+	IsContractCreation bool `json:"isContractCreation,omitempty"`
+
+	// Visible account nonce, bound into the commitment by FieldEncodingNonceBound
+	AccountNonce uint64 `json:"accountNonce,omitempty"`
+
+	AccessList       types.AccessList `json:"accessList,omitempty"`
+	BlobHashes       []common.Hash    `json:"blobHashes,omitempty"`
+	MaxFeePerBlobGas *big.Int         `json:"maxFeePerBlobGas,omitempty"`
+
+	// Committed only on networks that hide them, see HiddenFieldSet
+	Sender               common.Address `json:"sender"`
+	GasPrice             *big.Int       `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *big.Int       `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *big.Int       `json:"maxPriorityFeePerGas,omitempty"`
+
+	// Blinding factor of the value commitment, nil without a value cap
+	ValueBlinding []byte `json:"valueBlinding,omitempty"`
+
+	// Canonical encoding of the transaction as signed by the sender, bound by
+	// its hash rather than the commitment
+	SignedTx []byte `json:"signedTx,omitempty"`
+
+	// Signature of the sender over the reveal
+	RevealSignature []byte `json:"revealSignature,omitempty"`
+}
+
+// EncryptedFields are hidden fields encrypted to a threshold key with hashed
+// ElGamal: the AES-GCM key is derived from r·P for the ephemeral point U = r·G
+type EncryptedFields struct {
+	Ephemeral  []byte `json:"ephemeral"` // Compressed U
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// DecryptionShare is a committee member's share s_i·U of the decryption key of a
+// PHT, with a Chaum-Pedersen proof that it uses the same secret as its verification key
+type DecryptionShare struct {
+	TxHash    common.Hash    `json:"txHash"`
+	Index     int            `json:"index"`
+	Validator common.Address `json:"validator"`
+	Share     []byte         `json:"share"` // Compressed s_i·U
+	Proof     []byte         `json:"proof"` // Challenge and response, 32 bytes each
+}
+
+// GenerateThresholdKey deals a fresh committee key with Shamir shares of its secret.
+// The dealer learns the secret and must discard it; a distributed key generation can
+// produce the same key format without one.
+func GenerateThresholdKey(committee []common.Address, threshold int) (*ThresholdKey, []*ThresholdKeyShare, error) {
+	if len(committee) == 0 {
+		return nil, nil, errors.New("empty decryption committee")
+	}
+	if threshold <= 0 || threshold > len(committee) {
+		return nil, nil, errors.New("invalid decryption threshold")
+	}
+
+	curve := crypto.S256()
+	n := curve.Params().N
+	coefficients := make([]*big.Int, threshold)
+	for i := range coefficients {
+		c, err := randomScalar(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefficients[i] = c
+	}
+
+	key := &ThresholdKey{
+		Committee:        append([]common.Address(nil), committee...),
+		Threshold:        threshold,
+		PublicKey:        scalarBasePoint(coefficients[0]),
+		VerificationKeys: make([][]byte, len(committee)),
+	}
+	shares := make([]*ThresholdKeyShare, len(committee))
+	for i, member := range committee {
+		// s_i = f(i) for f(x) = a_0 + a_1·x + ... + a_{t-1}·x^{t-1}
+		x := big.NewInt(int64(i + 1))
+		secret := new(big.Int)
+		for k := len(coefficients) - 1; k >= 0; k-- {
+			secret.Mul(secret, x)
+			secret.Add(secret, coefficients[k])
+			secret.Mod(secret, n)
+		}
+		key.VerificationKeys[i] = scalarBasePoint(secret)
+		shares[i] = &ThresholdKeyShare{
+			Index:     i + 1,
+			Validator: member,
+			Secret:    common.LeftPadBytes(secret.Bytes(), 32),
+		}
+	}
+	return key, shares, nil
+}
+
+// EncryptHiddenFields encrypts hidden fields to the committee key, bound to the PHT hash
+func EncryptHiddenFields(key *ThresholdKey, txHash common.Hash, fields *HiddenFields) (*EncryptedFields, error) {
+	px, py, err := decompressPoint(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := crypto.S256()
+	r, err := randomScalar(curve.Params().N)
+	if err != nil {
+		return nil, err
+	}
+	kx, ky := curve.ScalarMult(px, py, common.LeftPadBytes(r.Bytes(), 32))
+
+	aead, err := thresholdAEAD(compressPoint(kx, ky))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &EncryptedFields{
+		Ephemeral:  scalarBasePoint(r),
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, txHash.Bytes()),
+	}, nil
+}
+
+// SealHiddenFields encrypts a PHT's hidden fields to the committee key and strips
+// them from the PHT, so the proposer only sees them after a decryption quorum
+func SealHiddenFields(key *ThresholdKey, pht *PHTTransaction) error {
+	encrypted, err := EncryptHiddenFields(key, pht.TxHash, pht.hiddenFields())
+	if err != nil {
+		return err
+	}
+
+	pht.EncryptedFields = encrypted
+	pht.stripHiddenFields()
+	return nil
+}
+
+// CreateDecryptionShare computes a member's decryption share for encrypted fields
+func CreateDecryptionShare(share *ThresholdKeyShare, txHash common.Hash, encrypted *EncryptedFields) (*DecryptionShare, error) {
+	ux, uy, err := decompressPoint(encrypted.Ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	curve := crypto.S256()
+	n := curve.Params().N
+	secret := new(big.Int).SetBytes(share.Secret)
+	dx, dy := curve.ScalarMult(ux, uy, common.LeftPadBytes(secret.Bytes(), 32))
+	verification := scalarBasePoint(secret)
+	decryption := compressPoint(dx, dy)
+
+	// Chaum-Pedersen proof that log_G(s_i·G) = log_U(s_i·U)
+	w, err := randomScalar(n)
+	if err != nil {
+		return nil, err
+	}
+	a1 := scalarBasePoint(w)
+	a2x, a2y := curve.ScalarMult(ux, uy, common.LeftPadBytes(w.Bytes(), 32))
+	c := dleqChallenge(n, verification, encrypted.Ephemeral, decryption, a1, compressPoint(a2x, a2y))
+	z := new(big.Int).Mul(c, secret)
+	z.Add(z, w)
+	z.Mod(z, n)
+
+	return &DecryptionShare{
+		TxHash:    txHash,
+		Index:     share.Index,
+		Validator: share.Validator,
+		Share:     decryption,
+		Proof:     append(common.LeftPadBytes(c.Bytes(), 32), common.LeftPadBytes(z.Bytes(), 32)...),
+	}, nil
+}
+
+// VerifyDecryptionShare checks a decryption share against the member's verification key
+func VerifyDecryptionShare(key *ThresholdKey, encrypted *EncryptedFields, share *DecryptionShare) error {
+	if share.Index < 1 || share.Index > len(key.Committee) || key.Committee[share.Index-1] != share.Validator {
+		return fmt.Errorf("%w: %s is not committee member %d", ErrInvalidDecryptionShare, share.Validator.Hex(), share.Index)
+	}
+	if len(share.Proof) != 64 {
+		return fmt.Errorf("%w: malformed proof", ErrInvalidDecryptionShare)
+	}
+	ux, uy, err := decompressPoint(encrypted.Ephemeral)
+	if err != nil {
+		return err
+	}
+	vx, vy, err := decompressPoint(key.VerificationKeys[share.Index-1])
+	if err != nil {
+		return err
+	}
+	dx, dy, err := decompressPoint(share.Share)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDecryptionShare, err)
+	}
+
+	curve := crypto.S256()
+	n := curve.Params().N
+	c := new(big.Int).SetBytes(share.Proof[:32])
+	z := new(big.Int).SetBytes(share.Proof[32:])
+	if c.Cmp(n) >= 0 || z.Cmp(n) >= 0 {
+		return fmt.Errorf("%w: malformed proof", ErrInvalidDecryptionShare)
+	}
+
+	// A1 = z·G - c·V and A2 = z·U - c·D must hash back to the challenge
+	zgx, zgy := curve.ScalarBaseMult(share.Proof[32:])
+	cvx, cvy := curve.ScalarMult(vx, vy, share.Proof[:32])
+	zux, zuy := curve.ScalarMult(ux, uy, share.Proof[32:])
+	cdx, cdy := curve.ScalarMult(dx, dy, share.Proof[:32])
+	a1x, a1y := subtractPoints(zgx, zgy, cvx, cvy)
+	a2x, a2y := subtractPoints(zux, zuy, cdx, cdy)
+	if a1x == nil || a2x == nil {
+		return fmt.Errorf("%w: degenerate proof", ErrInvalidDecryptionShare)
+	}
+	expected := dleqChallenge(n, key.VerificationKeys[share.Index-1], encrypted.Ephemeral, share.Share, compressPoint(a1x, a1y), compressPoint(a2x, a2y))
+	if expected.Cmp(c) != 0 {
+		return ErrInvalidDecryptionShare
+	}
+	return nil
+}
+
+// CombineDecryptionShares recovers hidden fields from at least Threshold verified
+// shares by Lagrange interpolation of s·U in the exponent
+func CombineDecryptionShares(key *ThresholdKey, txHash common.Hash, encrypted *EncryptedFields, shares []*DecryptionShare) (*HiddenFields, error) {
+	unique := make(map[int]*DecryptionShare)
+	for _, share := range shares {
+		unique[share.Index] = share
+	}
+	if len(unique) < key.Threshold {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientShares, len(unique), key.Threshold)
+	}
+
+	// Any Threshold shares determine the key; use the lowest indices
+	indices := make([]int, 0, len(unique))
+	for index := 1; index <= len(key.Committee) && len(indices) < key.Threshold; index++ {
+		if _, exists := unique[index]; exists {
+			indices = append(indices, index)
+		}
+	}
+
+	curve := crypto.S256()
+	n := curve.Params().N
+	var kx, ky *big.Int
+	for _, i := range indices {
+		dx, dy, err := decompressPoint(unique[i].Share)
+		if err != nil {
+			return nil, err
+		}
+		lambda := lagrangeAtZero(n, i, indices)
+		tx, ty := curve.ScalarMult(dx, dy, common.LeftPadBytes(lambda.Bytes(), 32))
+		if kx == nil {
+			kx, ky = tx, ty
+		} else {
+			kx, ky = curve.Add(kx, ky, tx, ty)
+		}
+	}
+
+	aead, err := thresholdAEAD(compressPoint(kx, ky))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, txHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fields := new(HiddenFields)
+	if err := json.Unmarshal(plaintext, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// lagrangeAtZero returns the Lagrange coefficient of index i over indices at x = 0
+func lagrangeAtZero(n *big.Int, i int, indices []int) *big.Int {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, j := range indices {
+		if j == i {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(j)))
+		num.Mod(num, n)
+		den.Mul(den, big.NewInt(int64(j-i)))
+		den.Mod(den, n)
+	}
+	return num.Mul(num, den.ModInverse(den, n)).Mod(num, n)
+}
+
+// dleqChallenge hashes the Chaum-Pedersen transcript to a scalar
+func dleqChallenge(n *big.Int, verification, ephemeral, share, a1, a2 []byte) *big.Int {
+	c := new(big.Int).SetBytes(crypto.Keccak256([]byte("p2s-threshold-dleq"), verification, ephemeral, share, a1, a2))
+	return c.Mod(c, n)
+}
+
+// thresholdAEAD derives the AES-GCM cipher for a shared point
+func thresholdAEAD(point []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(crypto.Keccak256([]byte("p2s-threshold-key"), point))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// randomScalar returns a uniform scalar in [1, n-1]
+func randomScalar(n *big.Int) (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(n, common.Big1))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, common.Big1), nil
+}
+
+// scalarBasePoint returns k·G in compressed form
+func scalarBasePoint(k *big.Int) []byte {
+	return compressPoint(crypto.S256().ScalarBaseMult(common.LeftPadBytes(k.Bytes(), 32)))
+}
+
+// decompressPoint decodes a compressed secp256k1 point
+func decompressPoint(data []byte) (*big.Int, *big.Int, error) {
+	pub, err := crypto.DecompressPubkey(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pub.X, pub.Y, nil
+}
+
+// subtractPoints returns A - B, or nils when the result is the point at infinity
+func subtractPoints(ax, ay, bx, by *big.Int) (*big.Int, *big.Int) {
+	if ax.Cmp(bx) == 0 && ay.Cmp(by) == 0 {
+		return nil, nil
+	}
+	curve := crypto.S256()
+	x, y := curve.Add(ax, ay, bx, new(big.Int).Sub(curve.Params().P, by))
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, nil
+	}
+	return x, y
+}
+
+// ThresholdDecryptor collects decryption shares for the encrypted PHTs of final B1
+// blocks and restores their hidden fields once a quorum of shares is available.
+// The least recently used PHTs, shares and recovered fields are evicted beyond
+// the retention limit.
+type ThresholdDecryptor struct {
+	key       *ThresholdKey
+	limit     int
+	phts      *lru.Cache[common.Hash, *PHTTransaction] // Encrypted PHTs of final B1 blocks
+	shares    *lru.Cache[common.Hash, map[int]*DecryptionShare]
+	decrypted *lru.Cache[common.Hash, *HiddenFields]
+	mu        sync.Mutex
+}
+
+// NewThresholdDecryptor creates a decryptor for the given committee key, nil to
+// disable threshold decryption
+func NewThresholdDecryptor(key *ThresholdKey) *ThresholdDecryptor {
+	return newThresholdDecryptor(key, defaultDecryptorLimit)
+}
+
+// newThresholdDecryptor creates a decryptor retaining at most limit PHTs
+func newThresholdDecryptor(key *ThresholdKey, limit int) *ThresholdDecryptor {
+	if limit <= 0 {
+		limit = defaultDecryptorLimit
+	}
+	return &ThresholdDecryptor{
+		key:       key,
+		limit:     limit,
+		phts:      lru.NewCache[common.Hash, *PHTTransaction](limit),
+		shares:    lru.NewCache[common.Hash, map[int]*DecryptionShare](limit),
+		decrypted: lru.NewCache[common.Hash, *HiddenFields](limit),
+	}
+}
+
+// SetKey replaces the committee key; shares collected under the old key are dropped
+func (d *ThresholdDecryptor) SetKey(key *ThresholdKey) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.key = key
+	d.shares = lru.NewCache[common.Hash, map[int]*DecryptionShare](d.limit)
+}
+
+// Key returns the committee key, nil if none is set
+func (d *ThresholdDecryptor) Key() *ThresholdKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.key
+}
+
+// Finalize opens the encrypted PHTs of a final B1 block for decryption shares
+func (d *ThresholdDecryptor) Finalize(phts []*PHTTransaction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, pht := range phts {
+		if pht.EncryptedFields != nil {
+			d.phts.Add(pht.TxHash, pht)
+		}
+	}
+}
+
+// AddShare verifies and records a decryption share. Once Threshold shares are
+// collected the hidden fields are recovered and returned; before that it returns nil.
+func (d *ThresholdDecryptor) AddShare(share *DecryptionShare) (*HiddenFields, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if fields, exists := d.decrypted.Get(share.TxHash); exists {
+		return fields, nil
+	}
+	if d.key == nil {
+		return nil, ErrMissingThresholdKey
+	}
+	pht, exists := d.phts.Get(share.TxHash)
+	if !exists {
+		return nil, fmt.Errorf("%w: PHT %s", ErrNotFinalized, share.TxHash.Hex())
+	}
+	if err := VerifyDecryptionShare(d.key, pht.EncryptedFields, share); err != nil {
+		return nil, err
+	}
+
+	shares, _ := d.shares.Get(share.TxHash)
+	if shares == nil {
+		shares = make(map[int]*DecryptionShare)
+		d.shares.Add(share.TxHash, shares)
+	}
+	shares[share.Index] = share
+	if len(shares) < d.key.Threshold {
+		return nil, nil
+	}
+
+	collected := make([]*DecryptionShare, 0, len(shares))
+	for _, s := range shares {
+		collected = append(collected, s)
+	}
+	fields, err := CombineDecryptionShares(d.key, share.TxHash, pht.EncryptedFields, collected)
+	if err != nil {
+		return nil, err
+	}
+	d.decrypted.Add(share.TxHash, fields)
+	d.shares.Remove(share.TxHash)
+	return fields, nil
+}
+
+// Decrypted returns the recovered hidden fields of a PHT, if available
+func (d *ThresholdDecryptor) Decrypted(txHash common.Hash) (*HiddenFields, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.decrypted.Get(txHash)
+}
+
+// PHT returns a PHT opened for decryption
+func (d *ThresholdDecryptor) PHT(txHash common.Hash) (*PHTTransaction, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.phts.Get(txHash)
+}
@@ -0,0 +1,237 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// B1 sealing modes
+const (
+	SealingModeSingle    = "single"
+	SealingModeCommittee = "committee"
+)
+
+// SealProposal is the PHT ordering proposed by the committee leader for a B1 block
+type SealProposal struct {
+	BlockNumber uint64         `json:"blockNumber"`
+	Leader      common.Address `json:"leader"`
+	PHTHashes   []common.Hash  `json:"phtHashes"`
+}
+
+// ThresholdSeal contains the committee co-signatures sealing a B1 block
+type ThresholdSeal struct {
+	Proposal   *SealProposal    `json:"proposal"`
+	Committee  []common.Address `json:"committee"`
+	Threshold  int              `json:"threshold"`
+	Signatures [][]byte         `json:"signatures"`
+}
+
+// OrderingHash returns the hash of an ordered PHT set
+func OrderingHash(phts []*PHTTransaction) common.Hash {
+	hashes := make([]common.Hash, len(phts))
+	for i, pht := range phts {
+		hashes[i] = pht.TxHash
+	}
+	return orderingHash(hashes)
+}
+
+// orderingHash returns the hash of an ordered list of PHT hashes
+func orderingHash(hashes []common.Hash) common.Hash {
+	data := make([]byte, 0, len(hashes)*common.HashLength)
+	for _, hash := range hashes {
+		data = append(data, hash.Bytes()...)
+	}
+	return crypto.Keccak256Hash(data)
+}
+
+// SigningHash returns the hash committee members sign to co-seal the proposal
+func (sp *SealProposal) SigningHash() common.Hash {
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, sp.BlockNumber)
+
+	ordering := orderingHash(sp.PHTHashes)
+	return crypto.Keccak256Hash([]byte("p2s-b1-seal"), number, sp.Leader.Bytes(), ordering.Bytes())
+}
+
+// SealingCommittee coordinates the leader-propose, committee-co-sign flow for B1 blocks
+type SealingCommittee struct {
+	members   map[common.Address]bool
+	ordered   []common.Address
+	threshold int
+
+	proposal   *SealProposal
+	signatures map[common.Address][]byte
+
+	mu sync.Mutex
+}
+
+// NewSealingCommittee creates a new sealing committee; the first member is the leader
+func NewSealingCommittee(members []common.Address, threshold int) (*SealingCommittee, error) {
+	if len(members) == 0 {
+		return nil, errors.New("empty sealing committee")
+	}
+	if threshold <= 0 || threshold > len(members) {
+		return nil, errors.New("invalid sealing threshold")
+	}
+
+	set := make(map[common.Address]bool, len(members))
+	for _, member := range members {
+		set[member] = true
+	}
+
+	return &SealingCommittee{
+		members:    set,
+		ordered:    append([]common.Address(nil), members...),
+		threshold:  threshold,
+		signatures: make(map[common.Address][]byte),
+	}, nil
+}
+
+// Leader returns the committee leader
+func (c *SealingCommittee) Leader() common.Address {
+	return c.ordered[0]
+}
+
+// Propose records the leader's PHT ordering for the given block
+func (c *SealingCommittee) Propose(blockNumber uint64, phts []*PHTTransaction) *SealProposal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hashes := make([]common.Hash, len(phts))
+	for i, pht := range phts {
+		hashes[i] = pht.TxHash
+	}
+
+	c.proposal = &SealProposal{
+		BlockNumber: blockNumber,
+		Leader:      c.ordered[0],
+		PHTHashes:   hashes,
+	}
+	c.signatures = make(map[common.Address][]byte)
+
+	return c.proposal
+}
+
+// AddSignature adds a member's co-signature over the current proposal
+func (c *SealingCommittee) AddSignature(sig []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.proposal == nil {
+		return errors.New("no active seal proposal")
+	}
+
+	signer, err := recoverSealSigner(c.proposal.SigningHash(), sig)
+	if err != nil {
+		return err
+	}
+	if !c.members[signer] {
+		return fmt.Errorf("signer %s is not a committee member", signer.Hex())
+	}
+
+	c.signatures[signer] = sig
+	return nil
+}
+
+// Seal returns the threshold seal once enough co-signatures have been collected
+func (c *SealingCommittee) Seal() (*ThresholdSeal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.proposal == nil {
+		return nil, errors.New("no active seal proposal")
+	}
+	if len(c.signatures) < c.threshold {
+		return nil, fmt.Errorf("insufficient co-signatures: have %d, need %d", len(c.signatures), c.threshold)
+	}
+
+	// Keep signatures in committee order so seals are deterministic
+	signatures := make([][]byte, 0, len(c.signatures))
+	for _, member := range c.ordered {
+		if sig, exists := c.signatures[member]; exists {
+			signatures = append(signatures, sig)
+		}
+	}
+
+	return &ThresholdSeal{
+		Proposal:   c.proposal,
+		Committee:  append([]common.Address(nil), c.ordered...),
+		Threshold:  c.threshold,
+		Signatures: signatures,
+	}, nil
+}
+
+// SignSealProposal signs a seal proposal with a committee member's key
+func SignSealProposal(proposal *SealProposal, key []byte) ([]byte, error) {
+	privateKey, err := crypto.ToECDSA(key)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(proposal.SigningHash().Bytes(), privateKey)
+}
+
+// VerifyThresholdSeal verifies a B1 block's committee seal against its PHT ordering.
+// The committee and threshold are those expected for the block at blockNumber, not
+// the ones the seal claims, so a proposer cannot seal with a committee of its own.
+func VerifyThresholdSeal(block *B1Block, committee *SealingCommittee, blockNumber uint64) error {
+	seal := block.CommitteeSeal
+	if seal == nil || seal.Proposal == nil {
+		return errors.New("missing committee seal")
+	}
+
+	if len(seal.Committee) != len(committee.ordered) {
+		return errors.New("seal committee does not match expected committee")
+	}
+	for i, member := range seal.Committee {
+		if member != committee.ordered[i] {
+			return errors.New("seal committee does not match expected committee")
+		}
+	}
+	if seal.Threshold != committee.threshold {
+		return fmt.Errorf("seal threshold %d does not match expected %d", seal.Threshold, committee.threshold)
+	}
+
+	if seal.Proposal.BlockNumber != blockNumber {
+		return fmt.Errorf("seal proposal for block %d, expected %d", seal.Proposal.BlockNumber, blockNumber)
+	}
+	if seal.Proposal.Leader != committee.Leader() {
+		return errors.New("seal proposal not made by committee leader")
+	}
+
+	if orderingHash(seal.Proposal.PHTHashes) != OrderingHash(block.PHTs) {
+		return errors.New("sealed PHT ordering does not match block")
+	}
+
+	signingHash := seal.Proposal.SigningHash()
+	signed := make(map[common.Address]bool)
+	for _, sig := range seal.Signatures {
+		signer, err := recoverSealSigner(signingHash, sig)
+		if err != nil {
+			return err
+		}
+		if !committee.members[signer] {
+			return fmt.Errorf("seal signed by non-member %s", signer.Hex())
+		}
+		signed[signer] = true
+	}
+
+	if len(signed) < committee.threshold {
+		return fmt.Errorf("insufficient seal signatures: have %d, need %d", len(signed), committee.threshold)
+	}
+
+	return nil
+}
+
+// recoverSealSigner recovers the address that signed a seal proposal
+func recoverSealSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	pub, err := crypto.SigToPub(hash.Bytes(), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
@@ -0,0 +1,272 @@
+package p2s
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// timelockModulusBits is the size of the RSA modulus of time-lock puzzles
+	timelockModulusBits = 2048
+
+	// defaultTimelockSquaringsPerSecond is a conservative estimate of the squaring
+	// rate of the fastest expected solver, which bounds how early a puzzle opens
+	defaultTimelockSquaringsPerSecond = 1 << 22
+
+	// timelockCheckInterval is the number of squarings between cancellation checks
+	timelockCheckInterval = 1 << 14
+
+	// timelockModulusUses is the number of puzzles locked under one modulus
+	// before a fresh one is generated. Every puzzle has its own base, so
+	// solving one opens no other puzzle under the same modulus.
+	timelockModulusUses = 1024
+)
+
+// ErrInvalidTimelockPuzzle is returned when a PHT's time-lock puzzle does not
+// match the network's puzzle parameters
+var ErrInvalidTimelockPuzzle = errors.New("invalid time-lock puzzle")
+
+// TimelockPuzzle is a Rivest-Shamir-Wagner time-lock puzzle over a PHT's hidden
+// fields. Opening it takes Squarings sequential modular squarings of 2, which
+// cannot be parallelized without the factorization of Modulus; the sender, who
+// knows it, creates the puzzle in a single exponentiation.
+type TimelockPuzzle struct {
+	Modulus    []byte `json:"modulus"`
+	Squarings  uint64 `json:"squarings"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`                    // Hidden fields under a key derived from Base^(2^Squarings) mod Modulus
+	Base       []byte `json:"base,omitempty" rlp:"optional"` // Starting value of the squarings, 2 if empty
+}
+
+// timelockTrapdoor is an RSA modulus with its factorization, reused for up to
+// timelockModulusUses puzzles
+type timelockTrapdoor struct {
+	n    *big.Int
+	phi  *big.Int
+	uses int
+}
+
+// newTimelockTrapdoor generates a fresh modulus of timelockModulusBits
+func newTimelockTrapdoor() (*timelockTrapdoor, error) {
+	p, err := rand.Prime(rand.Reader, timelockModulusBits/2)
+	if err != nil {
+		return nil, err
+	}
+	q, err := rand.Prime(rand.Reader, timelockModulusBits/2)
+	if err != nil {
+		return nil, err
+	}
+	return &timelockTrapdoor{
+		n:   new(big.Int).Mul(p, q),
+		phi: new(big.Int).Mul(new(big.Int).Sub(p, common.Big1), new(big.Int).Sub(q, common.Big1)),
+	}, nil
+}
+
+// TimelockCommitment commits with Pedersen commitments and additionally locks the
+// hidden fields in a time-lock puzzle sized to the B1 block interval. If the sender
+// withholds the MT, anyone can open the puzzle once the interval has elapsed.
+// Moduli are generated on the sender side and reused across puzzles, so locking
+// costs one exponentiation rather than two prime generations per PHT.
+type TimelockCommitment struct {
+	*PedersenCommitment
+	squarings uint64
+	trapdoor  *timelockTrapdoor
+	mu        sync.Mutex
+}
+
+// NewTimelockCommitment creates a timelock commitment scheme whose puzzles take
+// the given number of squarings to open
+func NewTimelockCommitment(squarings uint64) *TimelockCommitment {
+	if squarings == 0 {
+		squarings = 1
+	}
+	return &TimelockCommitment{
+		PedersenCommitment: NewPedersenCommitment(),
+		squarings:          squarings,
+	}
+}
+
+// timelockSquarings sizes puzzles so the fastest expected solver needs a full B1 interval
+func timelockSquarings(config *P2SConfig) uint64 {
+	rate := config.TimelockSquaringsPerSecond
+	if rate == 0 {
+		rate = defaultTimelockSquaringsPerSecond
+	}
+	return uint64(config.B1BlockTime.Seconds() * float64(rate))
+}
+
+// Squarings returns the number of squarings needed to open puzzles of this scheme
+func (t *TimelockCommitment) Squarings() uint64 {
+	return t.squarings
+}
+
+// Lock locks hidden fields of a PHT in a fresh time-lock puzzle
+func (t *TimelockCommitment) Lock(txHash common.Hash, fields *HiddenFields) (*TimelockPuzzle, error) {
+	trapdoor, err := t.nextTrapdoor()
+	if err != nil {
+		return nil, err
+	}
+	return lockTimelockPuzzle(trapdoor, txHash, fields, t.squarings)
+}
+
+// Verify checks that a puzzle has the squarings and modulus size of this scheme
+func (t *TimelockCommitment) Verify(puzzle *TimelockPuzzle) error {
+	if puzzle == nil {
+		return fmt.Errorf("%w: missing", ErrInvalidTimelockPuzzle)
+	}
+	if puzzle.Squarings != t.squarings {
+		return fmt.Errorf("%w: %d squarings, network requires %d", ErrInvalidTimelockPuzzle, puzzle.Squarings, t.squarings)
+	}
+	n := new(big.Int).SetBytes(puzzle.Modulus)
+	if n.BitLen() != timelockModulusBits || n.Bit(0) == 0 {
+		return fmt.Errorf("%w: %d-bit modulus, network requires %d", ErrInvalidTimelockPuzzle, n.BitLen(), timelockModulusBits)
+	}
+	if len(puzzle.Base) > 0 {
+		if base := new(big.Int).SetBytes(puzzle.Base); base.Cmp(common.Big1) <= 0 || base.Cmp(n) >= 0 {
+			return fmt.Errorf("%w: base out of range", ErrInvalidTimelockPuzzle)
+		}
+	}
+	return nil
+}
+
+// nextTrapdoor returns the modulus to lock the next puzzle under, generating a
+// fresh one once the current one has been used timelockModulusUses times
+func (t *TimelockCommitment) nextTrapdoor() (*timelockTrapdoor, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.trapdoor == nil || t.trapdoor.uses >= timelockModulusUses {
+		trapdoor, err := newTimelockTrapdoor()
+		if err != nil {
+			return nil, err
+		}
+		t.trapdoor = trapdoor
+	}
+	t.trapdoor.uses++
+	return t.trapdoor, nil
+}
+
+// NewTimelockPuzzle locks hidden fields behind the given number of squarings
+// under a fresh modulus
+func NewTimelockPuzzle(txHash common.Hash, fields *HiddenFields, squarings uint64) (*TimelockPuzzle, error) {
+	trapdoor, err := newTimelockTrapdoor()
+	if err != nil {
+		return nil, err
+	}
+	return lockTimelockPuzzle(trapdoor, txHash, fields, squarings)
+}
+
+// lockTimelockPuzzle locks hidden fields under a known modulus with a random base
+func lockTimelockPuzzle(trapdoor *timelockTrapdoor, txHash common.Hash, fields *HiddenFields, squarings uint64) (*TimelockPuzzle, error) {
+	n := trapdoor.n
+	base, err := rand.Int(rand.Reader, new(big.Int).Sub(n, common.Big3))
+	if err != nil {
+		return nil, err
+	}
+	base.Add(base, common.Big2)
+
+	// With the trapdoor, x^(2^T) mod n = x^(2^T mod phi) mod n
+	exponent := new(big.Int).Exp(common.Big2, new(big.Int).SetUint64(squarings), trapdoor.phi)
+	solution := new(big.Int).Exp(base, exponent, n)
+
+	plaintext, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := timelockAEAD(n, solution)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &TimelockPuzzle{
+		Modulus:    n.Bytes(),
+		Squarings:  squarings,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, txHash.Bytes()),
+		Base:       base.Bytes(),
+	}, nil
+}
+
+// SolveTimelockPuzzle opens a puzzle by sequential squaring. It is slow by design
+// and stops early if the context is cancelled.
+func SolveTimelockPuzzle(ctx context.Context, txHash common.Hash, puzzle *TimelockPuzzle) (*HiddenFields, error) {
+	n := new(big.Int).SetBytes(puzzle.Modulus)
+	if n.BitLen() < timelockModulusBits/2 {
+		return nil, errors.New("time-lock modulus too small")
+	}
+
+	x := big.NewInt(2)
+	if len(puzzle.Base) > 0 {
+		x.SetBytes(puzzle.Base)
+	}
+	for i := uint64(0); i < puzzle.Squarings; i++ {
+		if i%timelockCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		x.Mul(x, x)
+		x.Mod(x, n)
+	}
+
+	aead, err := timelockAEAD(n, x)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, puzzle.Nonce, puzzle.Ciphertext, txHash.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fields := new(HiddenFields)
+	if err := json.Unmarshal(plaintext, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// CalibrateTimelock measures the local squaring rate over the given duration, for
+// setting TimelockSquaringsPerSecond from the fastest hardware expected to solve puzzles
+func CalibrateTimelock(duration time.Duration) uint64 {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(common.Big1, timelockModulusBits))
+	if err != nil {
+		return 0
+	}
+	n.SetBit(n, timelockModulusBits-1, 1)
+
+	x := big.NewInt(2)
+	start := time.Now()
+	var squarings uint64
+	for time.Since(start) < duration {
+		for i := 0; i < 1024; i++ {
+			x.Mul(x, x)
+			x.Mod(x, n)
+		}
+		squarings += 1024
+	}
+	return uint64(float64(squarings) / time.Since(start).Seconds())
+}
+
+// timelockAEAD derives the AES-GCM cipher from a puzzle solution
+func timelockAEAD(n, solution *big.Int) (cipher.AEAD, error) {
+	encoded := make([]byte, (n.BitLen()+7)/8)
+	solution.FillBytes(encoded)
+	block, err := aes.NewCipher(crypto.Keccak256([]byte("p2s-timelock-key"), encoded))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
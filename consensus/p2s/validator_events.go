@@ -0,0 +1,87 @@
+package p2s
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// ValidatorEventKind names a validator set change
+type ValidatorEventKind string
+
+// Validator set changes
+const (
+	ValidatorEventAdded       ValidatorEventKind = "added"
+	ValidatorEventRemoved     ValidatorEventKind = "removed"
+	ValidatorEventActivated   ValidatorEventKind = "activated"   // Stake reached the minimum again
+	ValidatorEventDeactivated ValidatorEventKind = "deactivated" // Stake fell below the minimum
+	ValidatorEventSlashed     ValidatorEventKind = "slashed"
+	ValidatorEventJailed      ValidatorEventKind = "jailed"
+	ValidatorEventUnjailed    ValidatorEventKind = "unjailed"
+)
+
+// ValidatorEvent is emitted whenever the validator set changes
+type ValidatorEvent struct {
+	Kind        ValidatorEventKind `json:"kind"`
+	Validator   common.Address     `json:"validator"`
+	Height      uint64             `json:"height"`
+	Stake       *big.Int           `json:"stake"`                 // Own stake after the change
+	Amount      *big.Int           `json:"amount,omitempty"`      // Stake removed by a slashing
+	JailedUntil uint64             `json:"jailedUntil,omitempty"` // Height a jailing ends at
+	Reason      string             `json:"reason,omitempty"`      // Why the validator was jailed
+}
+
+// SubscribeEvents subscribes to validator set changes. Events are sent once the
+// change is applied, in order; subscribers must keep receiving.
+func (v *ValidatorManager) SubscribeEvents(ch chan<- ValidatorEvent) event.Subscription {
+	return v.feed.Subscribe(ch)
+}
+
+// emit queues an event about a validator for the next flush. The caller must
+// hold the lock.
+func (v *ValidatorManager) emit(kind ValidatorEventKind, validator *Validator) *ValidatorEvent {
+	v.queued = append(v.queued, ValidatorEvent{
+		Kind:      kind,
+		Validator: validator.Address,
+		Height:    v.height,
+		Stake:     new(big.Int).Set(validator.Stake),
+	})
+	return &v.queued[len(v.queued)-1]
+}
+
+// setActive activates or deactivates a validator, emitting the change. The
+// caller must hold the lock.
+func (v *ValidatorManager) setActive(validator *Validator, active bool) {
+	if validator.IsActive == active {
+		return
+	}
+	validator.IsActive = active
+	if active {
+		v.emit(ValidatorEventActivated, validator)
+	} else {
+		v.emit(ValidatorEventDeactivated, validator)
+	}
+}
+
+// flushEvents sends the queued events to subscribers. It must be called without
+// the lock, so subscribers may query the validator set.
+func (v *ValidatorManager) flushEvents() {
+	v.sendMu.Lock()
+	defer v.sendMu.Unlock()
+
+	v.mu.Lock()
+	events := v.queued
+	v.queued = nil
+	v.mu.Unlock()
+
+	for _, ev := range events {
+		v.feed.Send(ev)
+	}
+}
+
+// SubscribeValidatorEvents subscribes to validator set changes: validators added,
+// removed, activated, deactivated, slashed, jailed and unjailed
+func (p *P2SConsensus) SubscribeValidatorEvents(ch chan<- ValidatorEvent) event.Subscription {
+	return p.validatorMgr.SubscribeEvents(ch)
+}
@@ -0,0 +1,92 @@
+package p2s
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// ErrNotJailed is returned when unjailing a validator that is not jailed
+	ErrNotJailed = errors.New("validator not jailed")
+
+	// ErrJailPeriod is returned when unjailing a validator before its jail period ended
+	ErrJailPeriod = errors.New("jail period not over")
+
+	// ErrUnjailStake is returned when unjailing a validator whose stake is below the minimum
+	ErrUnjailStake = errors.New("stake below minimum for unjailing")
+)
+
+// selectable reports whether a validator may be selected to propose or seal:
+// active and not jailed
+func (v *Validator) selectable() bool {
+	return v.IsActive && !v.Jailed
+}
+
+// jail excludes a validator from selection for the configured jail duration,
+// extending any jail period it is serving. The caller must hold the lock.
+func (v *ValidatorManager) jail(validator *Validator, reason string) {
+	var duration uint64
+	if v.config != nil {
+		duration = v.config.JailDuration
+	}
+	until := v.height + duration
+	if validator.Jailed && validator.JailedUntil > until {
+		until = validator.JailedUntil
+	}
+	validator.Jailed = true
+	validator.JailedUntil = until
+	validator.UpdatedAt = uint64(time.Now().Unix())
+	jailed := v.emit(ValidatorEventJailed, validator)
+	jailed.JailedUntil, jailed.Reason = until, reason
+	log.Warn("Jailed validator", "validator", validator.Address, "until", until, "reason", reason)
+}
+
+// Jail excludes a validator from selection for the configured jail duration
+func (v *ValidatorManager) Jail(address common.Address, reason string) error {
+	defer v.flushEvents()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, exists := v.validators[address]
+	if !exists {
+		return errors.New("validator not found")
+	}
+	v.jail(validator, reason)
+	v.persist(address)
+	return nil
+}
+
+// Unjail returns a jailed validator to selection once its jail period is over,
+// provided its own stake still meets the minimum
+func (v *ValidatorManager) Unjail(address common.Address) error {
+	defer v.flushEvents()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, exists := v.validators[address]
+	if !exists {
+		return errors.New("validator not found")
+	}
+	if !validator.Jailed {
+		return fmt.Errorf("%w: %s", ErrNotJailed, address.Hex())
+	}
+	if v.height < validator.JailedUntil {
+		return fmt.Errorf("%w: %s jailed until %d, now %d", ErrJailPeriod, address.Hex(), validator.JailedUntil, v.height)
+	}
+	if v.config != nil && validator.Stake.Cmp(v.config.MinStake) < 0 {
+		return fmt.Errorf("%w: %s has %v", ErrUnjailStake, address.Hex(), validator.Stake)
+	}
+	validator.Jailed = false
+	validator.JailedUntil = 0
+	validator.UpdatedAt = uint64(time.Now().Unix())
+	v.emit(ValidatorEventUnjailed, validator)
+	if performance := v.performance[address]; performance != nil {
+		performance.ConsecutiveMissedSlots = 0
+	}
+	v.persist(address)
+	return nil
+}
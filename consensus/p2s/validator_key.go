@@ -0,0 +1,187 @@
+package p2s
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// Files a validator key is saved to in its key directory
+const (
+	validatorKeyFile    = "validator-key.json"
+	validatorBLSKeyFile = "validator-bls-key.json"
+)
+
+// validatorKeyMetadata is recorded next to the encrypted ECDSA key, pairing it
+// with the BLS key saved alongside
+type validatorKeyMetadata struct {
+	BLSPublicKey hexutil.Bytes `json:"blsPublicKey"`
+}
+
+// ValidatorKey is a validator's key pair: the ECDSA key its address derives from,
+// which signs attestations, and the BLS key it signs blocks with
+type ValidatorKey struct {
+	key    *ecdsa.PrivateKey
+	blsKey *BLSSecretKey
+}
+
+// GenerateValidatorKey creates a validator key with fresh random keys
+func GenerateValidatorKey() (*ValidatorKey, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	blsKey, err := GenerateBLSKey()
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatorKey{key: key, blsKey: blsKey}, nil
+}
+
+// LoadValidatorKey loads a validator key saved to a directory by Save. A BLS key
+// other than the one the ECDSA key file was saved with, as left by an interrupted
+// save, is rejected with ErrSignerMismatch.
+func LoadValidatorKey(dir, passphrase string) (*ValidatorKey, error) {
+	keyJSON, err := os.ReadFile(filepath.Join(dir, validatorKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	blsKeyJSON, err := os.ReadFile(filepath.Join(dir, validatorBLSKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	blsKey, err := DecryptBLSKey(blsKeyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var metadata validatorKeyMetadata
+	if err := json.Unmarshal(keyJSON, &metadata); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(metadata.BLSPublicKey, blsKey.PublicKey()) {
+		return nil, fmt.Errorf("%w: validator key saved with BLS public key %x, found %x", ErrSignerMismatch, []byte(metadata.BLSPublicKey), blsKey.PublicKey())
+	}
+	return &ValidatorKey{key: key.PrivateKey, blsKey: blsKey}, nil
+}
+
+// Save encrypts the key with a passphrase into a directory, the ECDSA key as a
+// go-ethereum keystore file recording the BLS public key and the BLS key as a
+// BLS keystore file. Each file is replaced atomically and synced, the BLS key
+// first: the ECDSA key file commits the pair, so LoadValidatorKey detects a save
+// interrupted in between.
+func (k *ValidatorKey) Save(dir, passphrase string, scryptN, scryptP int) error {
+	keyJSON, err := keystore.EncryptKey(&keystore.Key{
+		Id:         uuid.New(),
+		Address:    k.Address(),
+		PrivateKey: k.key,
+	}, passphrase, scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+	if keyJSON, err = withValidatorKeyMetadata(keyJSON, &validatorKeyMetadata{BLSPublicKey: k.BLSPublicKey()}); err != nil {
+		return err
+	}
+	blsKeyJSON, err := EncryptBLSKey(k.blsKey, passphrase, scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := writeKeyFile(filepath.Join(dir, validatorBLSKeyFile), blsKeyJSON); err != nil {
+		return err
+	}
+	return writeKeyFile(filepath.Join(dir, validatorKeyFile), keyJSON)
+}
+
+// withValidatorKeyMetadata adds the metadata fields to a keystore file, which
+// go-ethereum ignores when decrypting it
+func withValidatorKeyMetadata(keyJSON []byte, metadata *validatorKeyMetadata) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(keyJSON, &fields); err != nil {
+		return nil, err
+	}
+	blsPublicKey, err := json.Marshal(metadata.BLSPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	fields["blsPublicKey"] = blsPublicKey
+	return json.Marshal(fields)
+}
+
+// writeKeyFile writes a key file readable only by its owner, through a synced
+// temporary file renamed into place so a crash never leaves a partial key behind
+func writeKeyFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	// The rename is only durable once the directory is synced
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Address returns the validator address, that of the ECDSA key
+func (k *ValidatorKey) Address() common.Address {
+	return crypto.PubkeyToAddress(k.key.PublicKey)
+}
+
+// BLSPublicKey returns the compressed BLS public key
+func (k *ValidatorKey) BLSPublicKey() []byte {
+	return k.blsKey.PublicKey()
+}
+
+// Sign signs a hash with the ECDSA key, recoverably
+func (k *ValidatorKey) Sign(hash common.Hash) ([]byte, error) {
+	return crypto.Sign(hash.Bytes(), k.key)
+}
+
+// RotateBLSKey returns the key with a fresh BLS key. Setting its signer on the
+// engine registers the new BLS key for the validator.
+func (k *ValidatorKey) RotateBLSKey() (*ValidatorKey, error) {
+	blsKey, err := GenerateBLSKey()
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatorKey{key: k.key, blsKey: blsKey}, nil
+}
+
+// Signer returns a signer signing with the key
+func (k *ValidatorKey) Signer() *LocalSigner {
+	return NewLocalSigner(k.key, k.blsKey)
+}
@@ -1,6 +1,8 @@
 package p2s
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"math/big"
 	"math/rand"
@@ -9,35 +11,71 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 // ValidatorManager manages validators and their selection
 type ValidatorManager struct {
-	validators map[common.Address]*Validator
-	selection  ValidatorSelection
-	config     *P2SConfig
-	mu         sync.RWMutex
+	validators  map[common.Address]*Validator
+	delegations map[common.Address]map[common.Address]*Delegation // By validator, then delegator
+	hooks       *DelegationHooks
+	performance map[common.Address]*ValidatorPerformance
+	unbonding   map[common.Address][]*UnbondingEntry // Oldest first
+	selection   ValidatorSelection
+	curve       ReputationCurve
+	height      uint64 // Block height reputations are decayed to
+	config      *P2SConfig
+	
+	// Persistence, nil to keep the validator set in memory only
+	db               ethdb.KeyValueStore
+	snapshotInterval uint64 // Blocks between validator set snapshots, 0 for none
+	mu               sync.RWMutex
+	
+	// Validator set changes, queued under the lock and sent once it is released
+	feed   event.Feed
+	queued []ValidatorEvent
+	sendMu sync.Mutex
 }
 
 // Validator represents a validator in the P2S network
 type Validator struct {
-	Address    common.Address `json:"address"`
-	Stake      *big.Int      `json:"stake"`
-	Reputation int64         `json:"reputation"`
-	IsActive   bool          `json:"isActive"`
-	LastBlock  uint64        `json:"lastBlock"`
-	CreatedAt  uint64        `json:"createdAt"`
-	UpdatedAt  uint64        `json:"updatedAt"`
+	Address     common.Address `json:"address"`
+	Stake       *big.Int       `json:"stake"`     // Own stake, deciding whether the validator is active
+	Delegated   *big.Int       `json:"delegated"` // Stake bonded to the validator by delegators
+	Reputation  int64          `json:"reputation"`
+	IsActive    bool           `json:"isActive"`
+	Jailed      bool           `json:"jailed"`      // Excluded from selection until unjailed
+	JailedUntil uint64         `json:"jailedUntil"` // Height from which the validator may unjail
+	LastBlock   uint64         `json:"lastBlock"`
+	CreatedAt   uint64         `json:"createdAt"`
+	UpdatedAt   uint64         `json:"updatedAt"`
+	
+	// BLS public key for aggregate block signatures, nil until registered
+	BLSPublicKey hexutil.Bytes `json:"blsPublicKey,omitempty"`
+	
+	// Operator identity whose validators share a stake cap, zero if the validator operates itself
+	Operator common.Address `json:"operator"`
+	
+	// Reputation at the last explicit update and the height it was made at, from
+	// which the reputation curve derives the current reputation
+	reputationBase   int64
+	reputationAnchor uint64
 }
 
-// ValidatorSelection interface for validator selection algorithms
+// ValidatorSelection interface for validator selection algorithms. Proposer
+// selection must be a pure function of its arguments, whatever was selected
+// before, so every node agrees on the proposer of a height, restarted and
+// syncing nodes included.
 type ValidatorSelection interface {
-	SelectProposer(validators map[common.Address]*Validator, blockNumber uint64) (common.Address, error)
+	SelectProposer(validators map[common.Address]*Validator, blockNumber uint64, seed common.Hash) (common.Address, error)
 	SelectValidators(validators map[common.Address]*Validator, count int) []common.Address
 }
 
-// WeightedRandomSelection implements weighted random selection
+// WeightedRandomSelection implements stake and reputation weighted selection
 type WeightedRandomSelection struct {
 	randomSource func() float64
 }
@@ -49,51 +87,56 @@ func NewWeightedRandomSelection() *WeightedRandomSelection {
 	}
 }
 
-// SelectProposer selects a proposer using weighted random selection
-func (w *WeightedRandomSelection) SelectProposer(validators map[common.Address]*Validator, blockNumber uint64) (common.Address, error) {
+// validatorWeight returns the selection weight of a validator: its effective stake scaled
+// by its reputation, zero for inactive validators
+func validatorWeight(validator *Validator) *big.Int {
+	if !validator.selectable() || validator.Reputation <= -100 {
+		return new(big.Int)
+	}
+	reputationFactor := big.NewInt(validator.Reputation + 100) // +100 to avoid negative
+	return new(big.Int).Mul(validator.EffectiveStake(), reputationFactor)
+}
+
+// SelectProposer selects the proposer of a block by weight. The draw is derived
+// from the seed, the parent block hash, and the block number, and validators are
+// visited in address order, so all nodes with the same validator set select the
+// same proposer.
+func (w *WeightedRandomSelection) SelectProposer(validators map[common.Address]*Validator, blockNumber uint64, seed common.Hash) (common.Address, error) {
 	if len(validators) == 0 {
 		return common.Address{}, errors.New("no validators available")
 	}
 	
-	// Calculate total weight
-	totalWeight := big.NewInt(0)
-	for _, validator := range validators {
-		if validator.IsActive {
-			// Weight = stake * reputation factor
-			reputationFactor := big.NewInt(validator.Reputation + 100) // +100 to avoid negative
-			weight := new(big.Int).Mul(validator.Stake, reputationFactor)
-			totalWeight.Add(totalWeight, weight)
+	addresses := make([]common.Address, 0, len(validators))
+	weights := make(map[common.Address]*big.Int, len(validators))
+	totalWeight := new(big.Int)
+	for address, validator := range validators {
+		weight := validatorWeight(validator)
+		if weight.Sign() <= 0 {
+			continue
 		}
+		addresses = append(addresses, address)
+		weights[address] = weight
+		totalWeight.Add(totalWeight, weight)
 	}
-	
-	if totalWeight.Cmp(big.NewInt(0)) == 0 {
+	if totalWeight.Sign() == 0 {
 		return common.Address{}, errors.New("no active validators")
 	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
 	
-	// Select random proposer
-	randomWeight := new(big.Int).Rand(rand.New(rand.NewSource(time.Now().UnixNano())), totalWeight)
-	
-	currentWeight := big.NewInt(0)
-	for address, validator := range validators {
-		if validator.IsActive {
-			reputationFactor := big.NewInt(validator.Reputation + 100)
-			weight := new(big.Int).Mul(validator.Stake, reputationFactor)
-			currentWeight.Add(currentWeight, weight)
-			
-			if currentWeight.Cmp(randomWeight) >= 0 {
-				return address, nil
-			}
-		}
-	}
+	var number [8]byte
+	binary.BigEndian.PutUint64(number[:], blockNumber)
+	draw := crypto.Keccak256Hash(seed.Bytes(), number[:]).Big()
+	draw.Mod(draw, totalWeight)
 	
-	// Fallback to first active validator
-	for address, validator := range validators {
-		if validator.IsActive {
+	cumulative := new(big.Int)
+	for _, address := range addresses {
+		if cumulative.Add(cumulative, weights[address]).Cmp(draw) > 0 {
 			return address, nil
 		}
 	}
-	
-	return common.Address{}, errors.New("no active validators found")
+	return addresses[len(addresses)-1], nil
 }
 
 // SelectValidators selects multiple validators
@@ -105,7 +148,7 @@ func (w *WeightedRandomSelection) SelectValidators(validators map[common.Address
 	// Get active validators
 	activeValidators := make([]common.Address, 0)
 	for address, validator := range validators {
-		if validator.IsActive {
+		if validator.selectable() {
 			activeValidators = append(activeValidators, address)
 		}
 	}
@@ -137,17 +180,36 @@ func (w *WeightedRandomSelection) SelectValidators(validators map[common.Address
 	return selected
 }
 
-// NewValidatorManager creates a new validator manager
+// NewValidatorManager creates a new validator manager selecting proposers with
+// the configured algorithm
 func NewValidatorManager(config *P2SConfig) *ValidatorManager {
-	return &ValidatorManager{
-		validators: make(map[common.Address]*Validator),
-		selection:  NewWeightedRandomSelection(),
-		config:     config,
+	selection, err := NewValidatorSelection(config)
+	if err != nil {
+		log.Warn("Falling back to weighted proposer selection", "err", err)
+		selection = NewWeightedRandomSelection()
 	}
+	manager := &ValidatorManager{
+		validators:  make(map[common.Address]*Validator),
+		delegations: make(map[common.Address]map[common.Address]*Delegation),
+		hooks:       &DelegationHooks{},
+		performance: make(map[common.Address]*ValidatorPerformance),
+		unbonding:   make(map[common.Address][]*UnbondingEntry),
+		selection:   selection,
+		config:      config,
+	}
+	if config != nil {
+		manager.curve = ReputationCurve{
+			DecayHalfLife:    config.ReputationDecayHalfLife,
+			RecoveryHalfLife: config.ReputationRecoveryHalfLife,
+		}
+		manager.snapshotInterval = config.ValidatorSnapshotInterval
+	}
+	return manager
 }
 
 // AddValidator adds a new validator
 func (v *ValidatorManager) AddValidator(address common.Address, stake *big.Int) error {
+	defer v.flushEvents()
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
@@ -162,32 +224,46 @@ func (v *ValidatorManager) AddValidator(address common.Address, stake *big.Int)
 	validator := &Validator{
 		Address:    address,
 		Stake:      new(big.Int).Set(stake),
-		Reputation: 100, // Start with neutral reputation
+		Delegated:  new(big.Int),
+		Reputation: neutralReputation, // Start with neutral reputation
 		IsActive:   true,
 		LastBlock:  0,
 		CreatedAt:  uint64(time.Now().Unix()),
 		UpdatedAt:  uint64(time.Now().Unix()),
+		
+		reputationBase:   neutralReputation,
+		reputationAnchor: v.height,
 	}
 	
 	v.validators[address] = validator
+	v.emit(ValidatorEventAdded, validator)
+	v.persist(address)
 	return nil
 }
 
-// RemoveValidator removes a validator
+// RemoveValidator removes a validator, releasing the stake delegated to it
 func (v *ValidatorManager) RemoveValidator(address common.Address) error {
+	defer v.flushEvents()
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
-	if _, exists := v.validators[address]; !exists {
+	validator, exists := v.validators[address]
+	if !exists {
 		return errors.New("validator not found")
 	}
 	
 	delete(v.validators, address)
+	delete(v.delegations, address)
+	delete(v.performance, address)
+	v.emit(ValidatorEventRemoved, validator)
+	v.persist(address)
 	return nil
 }
 
-// UpdateStake updates a validator's stake
+// UpdateStake updates a validator's stake. Removed stake enters the unbonding
+// queue, where it stays slashable until released.
 func (v *ValidatorManager) UpdateStake(address common.Address, stake *big.Int) error {
+	defer v.flushEvents()
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
@@ -196,44 +272,89 @@ func (v *ValidatorManager) UpdateStake(address common.Address, stake *big.Int) e
 		return errors.New("validator not found")
 	}
 	
-	if stake.Cmp(v.config.MinStake) < 0 {
-		validator.IsActive = false
-	} else {
-		validator.IsActive = true
+	if decrease := new(big.Int).Sub(validator.Stake, stake); decrease.Sign() > 0 {
+		v.unbond(address, decrease)
 	}
-	
 	validator.Stake = new(big.Int).Set(stake)
+	v.setActive(validator, stake.Cmp(v.config.MinStake) >= 0)
 	validator.UpdatedAt = uint64(time.Now().Unix())
+	v.persist(address)
 	
 	return nil
 }
 
-// UpdateReputation updates a validator's reputation
+// Slash removes up to amount from a validator's stake, then from its unbonding
+// stake, and jails it, deactivating it if the remaining stake falls below the
+// minimum. It returns the amount removed.
+func (v *ValidatorManager) Slash(address common.Address, amount *big.Int) (*big.Int, error) {
+	defer v.flushEvents()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	
+	validator, exists := v.validators[address]
+	if !exists {
+		return nil, errors.New("validator not found")
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, errors.New("invalid slashing amount")
+	}
+	
+	slashed := new(big.Int).Set(amount)
+	if slashed.Cmp(validator.Stake) > 0 {
+		slashed.Set(validator.Stake)
+	}
+	validator.Stake = new(big.Int).Sub(validator.Stake, slashed)
+	if slashed.Cmp(amount) < 0 {
+		slashed.Add(slashed, v.slashUnbonding(address, new(big.Int).Sub(amount, slashed)))
+	}
+	v.emit(ValidatorEventSlashed, validator).Amount = new(big.Int).Set(slashed)
+	if validator.Stake.Cmp(v.config.MinStake) < 0 {
+		v.setActive(validator, false)
+	}
+	v.jail(validator, "slashed")
+	validator.UpdatedAt = uint64(time.Now().Unix())
+	v.persist(address)
+	
+	return slashed, nil
+}
+
+// UpdateReputation updates a validator's reputation, restarting its reputation
+// curve from the new value
 func (v *ValidatorManager) UpdateReputation(address common.Address, score int64) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	
 	if validator, exists := v.validators[address]; exists {
-		validator.Reputation += score
-		
-		// Cap reputation to prevent gaming
-		if validator.Reputation > 1000 {
-			validator.Reputation = 1000
-		}
-		if validator.Reputation < -1000 {
-			validator.Reputation = -1000
-		}
-		
-		validator.UpdatedAt = uint64(time.Now().Unix())
+		v.adjustReputation(validator, score)
 	}
 }
 
-// SelectProposer selects a proposer for the given block number
-func (v *ValidatorManager) SelectProposer(blockNumber uint64) (common.Address, error) {
+// adjustReputation adds score to a validator's reputation within the bounds and
+// restarts its reputation curve. The caller must hold the lock.
+func (v *ValidatorManager) adjustReputation(validator *Validator, score int64) {
+	validator.Reputation += score
+	
+	// Cap reputation to prevent gaming
+	if validator.Reputation > maxReputation {
+		validator.Reputation = maxReputation
+	}
+	if validator.Reputation < minReputation {
+		validator.Reputation = minReputation
+	}
+	validator.reputationBase = validator.Reputation
+	validator.reputationAnchor = v.height
+	
+	validator.UpdatedAt = uint64(time.Now().Unix())
+	v.persist(validator.Address)
+}
+
+// SelectProposer selects the proposer of a block from the current validator set,
+// seeded by the hash of its parent block
+func (v *ValidatorManager) SelectProposer(blockNumber uint64, parentHash common.Hash) (common.Address, error) {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 	
-	return v.selection.SelectProposer(v.validators, blockNumber)
+	return v.selection.SelectProposer(v.weightedValidators(), blockNumber, parentHash)
 }
 
 // SelectValidators selects multiple validators
@@ -241,7 +362,7 @@ func (v *ValidatorManager) SelectValidators(count int) []common.Address {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 	
-	return v.selection.SelectValidators(v.validators, count)
+	return v.selection.SelectValidators(v.weightedValidators(), count)
 }
 
 // GetValidator returns a validator by address
@@ -252,13 +373,19 @@ func (v *ValidatorManager) GetValidator(address common.Address) *Validator {
 	if validator, exists := v.validators[address]; exists {
 		// Return a copy to prevent race conditions
 		return &Validator{
-			Address:    validator.Address,
-			Stake:      new(big.Int).Set(validator.Stake),
-			Reputation: validator.Reputation,
-			IsActive:   validator.IsActive,
-			LastBlock:  validator.LastBlock,
-			CreatedAt:  validator.CreatedAt,
-			UpdatedAt:  validator.UpdatedAt,
+			Address:     validator.Address,
+			Stake:       new(big.Int).Set(validator.Stake),
+			Delegated:   new(big.Int).Set(bigOrZero(validator.Delegated)),
+			Reputation:  validator.Reputation,
+			IsActive:    validator.IsActive,
+			Jailed:      validator.Jailed,
+			JailedUntil: validator.JailedUntil,
+			LastBlock:   validator.LastBlock,
+			CreatedAt:   validator.CreatedAt,
+			UpdatedAt:   validator.UpdatedAt,
+			
+			BLSPublicKey: common.CopyBytes(validator.BLSPublicKey),
+			Operator:     validator.Operator,
 		}
 	}
 	
@@ -273,13 +400,19 @@ func (v *ValidatorManager) GetAllValidators() map[common.Address]*Validator {
 	validators := make(map[common.Address]*Validator)
 	for address, validator := range v.validators {
 		validators[address] = &Validator{
-			Address:    validator.Address,
-			Stake:      new(big.Int).Set(validator.Stake),
-			Reputation: validator.Reputation,
-			IsActive:   validator.IsActive,
-			LastBlock:  validator.LastBlock,
-			CreatedAt:  validator.CreatedAt,
-			UpdatedAt:  validator.UpdatedAt,
+			Address:     validator.Address,
+			Stake:       new(big.Int).Set(validator.Stake),
+			Delegated:   new(big.Int).Set(bigOrZero(validator.Delegated)),
+			Reputation:  validator.Reputation,
+			IsActive:    validator.IsActive,
+			Jailed:      validator.Jailed,
+			JailedUntil: validator.JailedUntil,
+			LastBlock:   validator.LastBlock,
+			CreatedAt:   validator.CreatedAt,
+			UpdatedAt:   validator.UpdatedAt,
+			
+			BLSPublicKey: common.CopyBytes(validator.BLSPublicKey),
+			Operator:     validator.Operator,
 		}
 	}
 	
@@ -295,13 +428,19 @@ func (v *ValidatorManager) GetActiveValidators() map[common.Address]*Validator {
 	for address, validator := range v.validators {
 		if validator.IsActive {
 			activeValidators[address] = &Validator{
-				Address:    validator.Address,
-				Stake:      new(big.Int).Set(validator.Stake),
-				Reputation: validator.Reputation,
-				IsActive:   validator.IsActive,
-				LastBlock:  validator.LastBlock,
-				CreatedAt:  validator.CreatedAt,
-				UpdatedAt:  validator.UpdatedAt,
+				Address:     validator.Address,
+				Stake:       new(big.Int).Set(validator.Stake),
+				Delegated:   new(big.Int).Set(bigOrZero(validator.Delegated)),
+				Reputation:  validator.Reputation,
+				IsActive:    validator.IsActive,
+				Jailed:      validator.Jailed,
+				JailedUntil: validator.JailedUntil,
+				LastBlock:   validator.LastBlock,
+				CreatedAt:   validator.CreatedAt,
+				UpdatedAt:   validator.UpdatedAt,
+				
+				BLSPublicKey: common.CopyBytes(validator.BLSPublicKey),
+				Operator:     validator.Operator,
 			}
 		}
 	}
@@ -332,7 +471,7 @@ func (v *ValidatorManager) GetActiveValidatorCount() int {
 	return count
 }
 
-// GetTotalStake returns the total stake of all validators
+// GetTotalStake returns the total effective stake of all active validators
 func (v *ValidatorManager) GetTotalStake() *big.Int {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
@@ -340,14 +479,14 @@ func (v *ValidatorManager) GetTotalStake() *big.Int {
 	totalStake := big.NewInt(0)
 	for _, validator := range v.validators {
 		if validator.IsActive {
-			totalStake.Add(totalStake, validator.Stake)
+			totalStake.Add(totalStake, validator.EffectiveStake())
 		}
 	}
 	
 	return totalStake
 }
 
-// GetTopValidators returns the top validators by stake
+// GetTopValidators returns the top validators by effective stake
 func (v *ValidatorManager) GetTopValidators(count int) []*Validator {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
@@ -361,7 +500,7 @@ func (v *ValidatorManager) GetTopValidators(count int) []*Validator {
 	
 	// Sort by stake (descending)
 	sort.Slice(validators, func(i, j int) bool {
-		return validators[i].Stake.Cmp(validators[j].Stake) > 0
+		return validators[i].EffectiveStake().Cmp(validators[j].EffectiveStake()) > 0
 	})
 	
 	// Return top N
@@ -372,6 +511,33 @@ func (v *ValidatorManager) GetTopValidators(count int) []*Validator {
 	return validators[:count]
 }
 
+// FallbackLadder returns the ordered fallback proposers for a slot, excluding the
+// given validator. The order is derived from the slot and validator addresses so
+// every node computes the same ladder; the first rung takes over a handed-off duty.
+func (v *ValidatorManager) FallbackLadder(slot uint64, exclude common.Address, size int) []common.Address {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	
+	ranks := make(map[common.Address]common.Hash)
+	ladder := make([]common.Address, 0, len(v.validators))
+	for address, validator := range v.validators {
+		if !validator.selectable() || address == exclude {
+			continue
+		}
+		ranks[address] = crypto.Keccak256Hash(new(big.Int).SetUint64(slot).Bytes(), address.Bytes())
+		ladder = append(ladder, address)
+	}
+	
+	sort.Slice(ladder, func(i, j int) bool {
+		return ranks[ladder[i]].Big().Cmp(ranks[ladder[j]].Big()) < 0
+	})
+	
+	if size > 0 && size < len(ladder) {
+		ladder = ladder[:size]
+	}
+	return ladder
+}
+
 // IsValidator checks if an address is a validator
 func (v *ValidatorManager) IsValidator(address common.Address) bool {
 	v.mu.RLock()
@@ -401,6 +567,7 @@ func (v *ValidatorManager) UpdateLastBlock(address common.Address, blockNumber u
 	if validator, exists := v.validators[address]; exists {
 		validator.LastBlock = blockNumber
 		validator.UpdatedAt = uint64(time.Now().Unix())
+		v.persist(address)
 	}
 }
 
@@ -419,7 +586,7 @@ func (v *ValidatorManager) GetValidatorStats() map[string]interface{} {
 	for _, validator := range v.validators {
 		if validator.IsActive {
 			activeCount++
-			totalStake.Add(totalStake, validator.Stake)
+			totalStake.Add(totalStake, validator.EffectiveStake())
 		}
 		avgReputation += validator.Reputation
 	}
@@ -438,20 +605,6 @@ func (v *ValidatorManager) GetValidatorStats() map[string]interface{} {
 	return stats
 }
 
-// GenerateValidatorAddress generates a new validator address
-func GenerateValidatorAddress() common.Address {
-	// Generate random private key
-	privateKey, _ := crypto.GenerateKey()
-	
-	// Get public key
-	publicKey := privateKey.Public()
-	
-	// Get address
-	address := crypto.PubkeyToAddress(publicKey.(crypto.PublicKey))
-	
-	return address
-}
-
 // ValidateValidatorAddress validates a validator address
 func ValidateValidatorAddress(address common.Address) bool {
 	// Check if address is not zero
@@ -0,0 +1,163 @@
+package p2s
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// operator returns the operator identity the validator is grouped under, the
+// validator itself when it has none
+func (v *Validator) operator() common.Address {
+	if v.Operator == (common.Address{}) {
+		return v.Address
+	}
+	return v.Operator
+}
+
+// SetOperator groups a validator under an operator identity, the zero address
+// to make it its own operator
+func (v *ValidatorManager) SetOperator(address, operator common.Address) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, exists := v.validators[address]
+	if !exists {
+		return errors.New("validator not found")
+	}
+	if operator == address {
+		operator = common.Address{}
+	}
+	validator.Operator = operator
+	validator.UpdatedAt = uint64(time.Now().Unix())
+	v.persist(address)
+	return nil
+}
+
+// OperatorValidators returns the validators grouped under an operator, in
+// address order
+func (v *ValidatorManager) OperatorValidators(operator common.Address) []common.Address {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var addresses []common.Address
+	for address, validator := range v.validators {
+		if validator.operator() == operator {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	return addresses
+}
+
+// WeightedStake returns the effective stake a validator is weighted with in
+// selection, its share of its operator's capped stake
+func (v *ValidatorManager) WeightedStake(address common.Address) *big.Int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if validator, exists := v.weightedValidators()[address]; exists {
+		return validator.EffectiveStake()
+	}
+	return new(big.Int)
+}
+
+// weightedValidators returns the validator set as selection weighs it. With an
+// operator cap configured, the selectable validators of an operator holding more
+// than the capped share of the capped total selectable effective stake have their
+// stake scaled down to the cap, ignoring the excess. The caller must hold the lock.
+func (v *ValidatorManager) weightedValidators() map[common.Address]*Validator {
+	if v.config == nil || v.config.MaxOperatorStakeBps == 0 || v.config.MaxOperatorStakeBps >= basisPoints {
+		return v.validators
+	}
+	operators := make(map[common.Address]*big.Int)
+	for _, validator := range v.validators {
+		if !validator.selectable() {
+			continue
+		}
+		operator := validator.operator()
+		if operators[operator] == nil {
+			operators[operator] = new(big.Int)
+		}
+		operators[operator].Add(operators[operator], validator.EffectiveStake())
+	}
+	limit := operatorStakeLimit(operators, v.config.MaxOperatorStakeBps)
+	if limit == nil {
+		return v.validators
+	}
+
+	var weighted map[common.Address]*Validator
+	for address, validator := range v.validators {
+		stake := operators[validator.operator()]
+		if !validator.selectable() || stake.Cmp(limit) <= 0 {
+			continue
+		}
+		if weighted == nil {
+			weighted = make(map[common.Address]*Validator, len(v.validators))
+			for address, validator := range v.validators {
+				weighted[address] = validator
+			}
+		}
+		capped := *validator
+		capped.Stake = new(big.Int).Mul(bigOrZero(validator.Stake), limit)
+		capped.Stake.Div(capped.Stake, stake)
+		capped.Delegated = new(big.Int).Mul(bigOrZero(validator.Delegated), limit)
+		capped.Delegated.Div(capped.Delegated, stake)
+		weighted[address] = &capped
+	}
+	if weighted == nil {
+		return v.validators
+	}
+	return weighted
+}
+
+// operatorStakeLimit returns the stake operators are capped at so that none holds
+// more than bps of the capped total, nil if no operator exceeds it. With the k
+// largest operators capped and the others holding the rest R, the cap c solves
+// c = bps·(R + k·c), so c = bps·R/(1 - k·bps); k grows while the next operator
+// still exceeds c. When the cap cannot hold, with too few operators to share the
+// stake, the capped operators are levelled to the largest uncapped one.
+func operatorStakeLimit(operators map[common.Address]*big.Int, bps uint64) *big.Int {
+	stakes := make([]*big.Int, 0, len(operators))
+	total := new(big.Int)
+	for _, stake := range operators {
+		stakes = append(stakes, stake)
+		total.Add(total, stake)
+	}
+	if len(stakes) == 0 {
+		return nil
+	}
+	sort.Slice(stakes, func(i, j int) bool {
+		return stakes[i].Cmp(stakes[j]) > 0
+	})
+
+	// The largest operator within its share of the uncapped total needs no cap
+	largest := new(big.Int).Mul(stakes[0], big.NewInt(basisPoints))
+	if largest.Cmp(new(big.Int).Mul(total, new(big.Int).SetUint64(bps))) <= 0 {
+		return nil
+	}
+	rest := new(big.Int).Set(total)
+	for k := 1; k <= len(stakes); k++ {
+		rest.Sub(rest, stakes[k-1])
+		capped := uint64(k) * bps
+		if k == len(stakes) || capped >= basisPoints {
+			level := stakes[len(stakes)-1]
+			if k < len(stakes) {
+				level = stakes[k]
+			}
+			return new(big.Int).Set(level)
+		}
+		limit := new(big.Int).Mul(rest, new(big.Int).SetUint64(bps))
+		limit.Div(limit, new(big.Int).SetUint64(basisPoints-capped))
+		if stakes[k].Cmp(limit) <= 0 {
+			return limit
+		}
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package p2s
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Validator duties tracked for payment statements
+const (
+	DutyB1Proposal = "b1_proposal"
+	DutyB2Proposal = "b2_proposal"
+	DutySeal       = "seal_signature"
+	DutyMissed     = "missed_slot"
+)
+
+var (
+	// ErrEpochNotSealed is returned when a statement is requested before its epoch is sealed
+	ErrEpochNotSealed = errors.New("payment epoch not sealed")
+
+	// ErrEpochSealed is returned when recording into an epoch that is already sealed
+	ErrEpochSealed = errors.New("payment epoch already sealed")
+
+	// ErrInvalidPaymentProof is returned when a statement does not verify against its epoch root
+	ErrInvalidPaymentProof = errors.New("invalid payment statement proof")
+)
+
+// PaymentStatement is a verifiable record of a validator's duties and earnings in an epoch
+type PaymentStatement struct {
+	Epoch     uint64            `json:"epoch"`
+	Validator common.Address    `json:"validator"`
+	Duties    map[string]uint64 `json:"duties"`
+	Rewards   *big.Int          `json:"rewards"`
+	Penalties *big.Int          `json:"penalties"`
+
+	// Inclusion proof against the signed epoch root
+	Index     int            `json:"index"`
+	Proof     []common.Hash  `json:"proof"`
+	EpochRoot common.Hash    `json:"epochRoot"`
+	Signer    common.Address `json:"signer"`
+	Signature []byte         `json:"signature"` // Signature over the epoch root
+}
+
+// Net returns rewards minus penalties
+func (s *PaymentStatement) Net() *big.Int {
+	return new(big.Int).Sub(s.Rewards, s.Penalties)
+}
+
+// Leaf returns the Merkle leaf committing to the statement contents
+func (s *PaymentStatement) Leaf() common.Hash {
+	duties := make([]string, 0, len(s.Duties))
+	for duty := range s.Duties {
+		duties = append(duties, duty)
+	}
+	sort.Strings(duties)
+
+	var buf bytes.Buffer
+	buf.WriteString("p2s-payment")
+	binary.Write(&buf, binary.BigEndian, s.Epoch)
+	buf.Write(s.Validator.Bytes())
+	for _, duty := range duties {
+		buf.WriteString(duty)
+		binary.Write(&buf, binary.BigEndian, s.Duties[duty])
+	}
+	buf.Write(common.LeftPadBytes(s.Rewards.Bytes(), 32))
+	buf.Write(common.LeftPadBytes(s.Penalties.Bytes(), 32))
+
+	return crypto.Keccak256Hash(buf.Bytes())
+}
+
+// paymentEpoch accumulates validator activity for a single epoch
+type paymentEpoch struct {
+	statements map[common.Address]*PaymentStatement
+	sealed     []*PaymentStatement // Sorted by validator once sealed
+	root       common.Hash
+	signer     common.Address
+	signature  []byte
+}
+
+// PaymentLedger records per-epoch validator duties, rewards and penalties and
+// produces signed statements that can be verified against the epoch root
+type PaymentLedger struct {
+	epochLength uint64
+	epochs      map[uint64]*paymentEpoch
+	mu          sync.RWMutex
+}
+
+// NewPaymentLedger creates a ledger grouping blocks into epochs of epochLength blocks
+func NewPaymentLedger(epochLength uint64) *PaymentLedger {
+	if epochLength == 0 {
+		epochLength = 1
+	}
+
+	return &PaymentLedger{
+		epochLength: epochLength,
+		epochs:      make(map[uint64]*paymentEpoch),
+	}
+}
+
+// EpochOf returns the epoch containing a block
+func (l *PaymentLedger) EpochOf(blockNumber uint64) uint64 {
+	return blockNumber / l.epochLength
+}
+
+// RecordDuty records a duty performed (or missed) by a validator at a block
+func (l *PaymentLedger) RecordDuty(blockNumber uint64, validator common.Address, duty string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statement, err := l.statement(l.EpochOf(blockNumber), validator)
+	if err != nil {
+		return err
+	}
+	statement.Duties[duty]++
+	return nil
+}
+
+// RecordReward records a reward earned by a validator at a block
+func (l *PaymentLedger) RecordReward(blockNumber uint64, validator common.Address, amount *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statement, err := l.statement(l.EpochOf(blockNumber), validator)
+	if err != nil {
+		return err
+	}
+	statement.Rewards.Add(statement.Rewards, amount)
+	return nil
+}
+
+// RecordPenalty records a penalty applied to a validator at a block
+func (l *PaymentLedger) RecordPenalty(blockNumber uint64, validator common.Address, amount *big.Int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statement, err := l.statement(l.EpochOf(blockNumber), validator)
+	if err != nil {
+		return err
+	}
+	statement.Penalties.Add(statement.Penalties, amount)
+	return nil
+}
+
+// statement returns the open statement for a validator, creating it if needed
+func (l *PaymentLedger) statement(epoch uint64, validator common.Address) (*PaymentStatement, error) {
+	record, exists := l.epochs[epoch]
+	if !exists {
+		record = &paymentEpoch{statements: make(map[common.Address]*PaymentStatement)}
+		l.epochs[epoch] = record
+	}
+	if record.sealed != nil {
+		return nil, ErrEpochSealed
+	}
+
+	statement, exists := record.statements[validator]
+	if !exists {
+		statement = &PaymentStatement{
+			Epoch:     epoch,
+			Validator: validator,
+			Duties:    make(map[string]uint64),
+			Rewards:   new(big.Int),
+			Penalties: new(big.Int),
+		}
+		record.statements[validator] = statement
+	}
+	return statement, nil
+}
+
+// SealEpoch closes an epoch, computes the Merkle root over all statements and signs it
+func (l *PaymentLedger) SealEpoch(epoch uint64, key *ecdsa.PrivateKey) (common.Hash, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, exists := l.epochs[epoch]
+	if !exists {
+		record = &paymentEpoch{statements: make(map[common.Address]*PaymentStatement)}
+		l.epochs[epoch] = record
+	}
+	if record.sealed != nil {
+		return common.Hash{}, ErrEpochSealed
+	}
+
+	sealed := make([]*PaymentStatement, 0, len(record.statements))
+	for _, statement := range record.statements {
+		sealed = append(sealed, statement)
+	}
+	sort.Slice(sealed, func(i, j int) bool {
+		return bytes.Compare(sealed[i].Validator.Bytes(), sealed[j].Validator.Bytes()) < 0
+	})
+
+	leaves := make([]common.Hash, len(sealed))
+	for i, statement := range sealed {
+		leaves[i] = statement.Leaf()
+	}
+	root := paymentRoot(leaves)
+
+	signature, err := crypto.Sign(paymentRootSigningHash(epoch, root).Bytes(), key)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	record.sealed = sealed
+	record.root = root
+	record.signer = crypto.PubkeyToAddress(key.PublicKey)
+	record.signature = signature
+
+	return root, nil
+}
+
+// EpochRoot returns the sealed root of an epoch
+func (l *PaymentLedger) EpochRoot(epoch uint64) (common.Hash, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	record, exists := l.epochs[epoch]
+	if !exists || record.sealed == nil {
+		return common.Hash{}, ErrEpochNotSealed
+	}
+	return record.root, nil
+}
+
+// Statement returns a validator's signed statement with inclusion proof for a sealed epoch
+func (l *PaymentLedger) Statement(epoch uint64, validator common.Address) (*PaymentStatement, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	record, exists := l.epochs[epoch]
+	if !exists || record.sealed == nil {
+		return nil, ErrEpochNotSealed
+	}
+
+	leaves := make([]common.Hash, len(record.sealed))
+	index := -1
+	for i, statement := range record.sealed {
+		leaves[i] = statement.Leaf()
+		if statement.Validator == validator {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("no payment statement for %s in epoch %d", validator.Hex(), epoch)
+	}
+
+	source := record.sealed[index]
+	duties := make(map[string]uint64, len(source.Duties))
+	for duty, count := range source.Duties {
+		duties[duty] = count
+	}
+
+	return &PaymentStatement{
+		Epoch:     epoch,
+		Validator: validator,
+		Duties:    duties,
+		Rewards:   new(big.Int).Set(source.Rewards),
+		Penalties: new(big.Int).Set(source.Penalties),
+		Index:     index,
+		Proof:     paymentProof(leaves, index),
+		EpochRoot: record.root,
+		Signer:    record.signer,
+		Signature: common.CopyBytes(record.signature),
+	}, nil
+}
+
+// VerifyPaymentStatement verifies a statement's inclusion in its epoch root and the root signature
+func VerifyPaymentStatement(statement *PaymentStatement) error {
+	current := statement.Leaf()
+	position := statement.Index
+	for _, sibling := range statement.Proof {
+		if position%2 == 0 {
+			current = crypto.Keccak256Hash(current.Bytes(), sibling.Bytes())
+		} else {
+			current = crypto.Keccak256Hash(sibling.Bytes(), current.Bytes())
+		}
+		position /= 2
+	}
+	if !constantTimeHashEqual(current, statement.EpochRoot) {
+		return ErrInvalidPaymentProof
+	}
+
+	pubKey, err := crypto.SigToPub(paymentRootSigningHash(statement.Epoch, statement.EpochRoot).Bytes(), statement.Signature)
+	if err != nil || crypto.PubkeyToAddress(*pubKey) != statement.Signer {
+		return ErrInvalidPaymentProof
+	}
+
+	return nil
+}
+
+// paymentRootSigningHash returns the hash signed when sealing an epoch
+func paymentRootSigningHash(epoch uint64, root common.Hash) common.Hash {
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, epoch)
+	return crypto.Keccak256Hash([]byte("p2s-payment-root"), number, root.Bytes())
+}
+
+// paymentLevels builds the Merkle tree levels over leaves, duplicating the last node on odd levels
+func paymentLevels(leaves []common.Hash) [][]common.Hash {
+	if len(leaves) == 0 {
+		return [][]common.Hash{{{}}}
+	}
+
+	levels := [][]common.Hash{leaves}
+	for len(levels[len(levels)-1]) > 1 {
+		prev := levels[len(levels)-1]
+		next := make([]common.Hash, (len(prev)+1)/2)
+		for i := range next {
+			left := prev[2*i]
+			right := left
+			if 2*i+1 < len(prev) {
+				right = prev[2*i+1]
+			}
+			next[i] = crypto.Keccak256Hash(left.Bytes(), right.Bytes())
+		}
+		levels = append(levels, next)
+	}
+	return levels
+}
+
+// paymentRoot returns the Merkle root over statement leaves
+func paymentRoot(leaves []common.Hash) common.Hash {
+	levels := paymentLevels(leaves)
+	return levels[len(levels)-1][0]
+}
+
+// paymentProof returns the sibling path for a leaf
+func paymentProof(leaves []common.Hash, index int) []common.Hash {
+	levels := paymentLevels(leaves)
+	proof := make([]common.Hash, 0, len(levels)-1)
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling >= len(level) {
+			sibling = index
+		}
+		proof = append(proof, level[sibling])
+		index /= 2
+	}
+	return proof
+}
@@ -0,0 +1,154 @@
+package p2s
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Reputation changes applied as validator performance is recorded
+const (
+	reputationProposalReward      = 1  // Per B1 or B2 block produced
+	reputationMEVReward           = 2  // Per B1 block, scaled by its MEV score
+	reputationLateRevealPenalty   = 2  // Per B2 block revealing after the deadline
+	reputationMissedRevealPenalty = 5  // Per MT not revealed by its deadline
+	reputationMissedSlotPenalty   = 10 // Per slot the validator failed to propose in
+)
+
+// ValidatorPerformance contains the blocks a validator produced and missed
+type ValidatorPerformance struct {
+	B1Proposals            uint64        `json:"b1Proposals"`
+	B2Proposals            uint64        `json:"b2Proposals"`
+	MissedSlots            uint64        `json:"missedSlots"`
+	ConsecutiveMissedSlots uint64        `json:"consecutiveMissedSlots"` // Since the validator last proposed
+	MissedReveals          uint64        `json:"missedReveals"`          // MTs due from the validator that expired
+	Reveals                uint64        `json:"reveals"`                // MTs revealed in its B2 blocks
+	AverageRevealLatency   time.Duration `json:"averageRevealLatency"`   // From a B1 block to the B2 block revealing it
+	AverageMEVScore        float64       `json:"averageMEVScore"`        // Of its B1 blocks
+	LastBlock              uint64        `json:"lastBlock"`
+
+	// Totals the averages are kept from
+	revealLatency time.Duration
+	mevScore      float64
+}
+
+// copy returns a copy of the performance
+func (p *ValidatorPerformance) copy() *ValidatorPerformance {
+	cpy := *p
+	return &cpy
+}
+
+// performanceOf returns the performance of a known validator, nil for others.
+// The caller must hold the lock.
+func (v *ValidatorManager) performanceOf(address common.Address) (*Validator, *ValidatorPerformance) {
+	validator, exists := v.validators[address]
+	if !exists {
+		return nil, nil
+	}
+	performance := v.performance[address]
+	if performance == nil {
+		performance = &ValidatorPerformance{}
+		v.performance[address] = performance
+	}
+	return validator, performance
+}
+
+// RecordB1Proposal records a B1 block produced by a validator, rewarding its
+// reputation by the MEV score of the block
+func (v *ValidatorManager) RecordB1Proposal(address common.Address, number uint64, mevScore float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, performance := v.performanceOf(address)
+	if validator == nil {
+		return
+	}
+	if mevScore < 0 {
+		mevScore = 0
+	}
+	if mevScore > 1 {
+		mevScore = 1
+	}
+	performance.B1Proposals++
+	performance.ConsecutiveMissedSlots = 0
+	performance.mevScore += mevScore
+	performance.AverageMEVScore = performance.mevScore / float64(performance.B1Proposals)
+	performance.LastBlock = number
+	validator.LastBlock = number
+
+	v.adjustReputation(validator, reputationProposalReward+int64(mevScore*reputationMEVReward))
+}
+
+// RecordB2Proposal records a B2 block produced by a validator, revealing the MTs
+// of a B1 block latency after it. Reveals later than the reveal deadline cost
+// reputation instead of earning it.
+func (v *ValidatorManager) RecordB2Proposal(address common.Address, number uint64, reveals int, latency time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	validator, performance := v.performanceOf(address)
+	if validator == nil {
+		return
+	}
+	if latency < 0 {
+		latency = 0
+	}
+	performance.B2Proposals++
+	performance.ConsecutiveMissedSlots = 0
+	performance.Reveals += uint64(reveals)
+	performance.revealLatency += latency
+	performance.AverageRevealLatency = performance.revealLatency / time.Duration(performance.B2Proposals)
+	performance.LastBlock = number
+	validator.LastBlock = number
+
+	score := int64(reputationProposalReward)
+	if v.config != nil {
+		if deadline := v.config.B2BlockTime + v.config.RevealGracePeriod; deadline > 0 && latency > deadline {
+			score = -reputationLateRevealPenalty
+		}
+	}
+	v.adjustReputation(validator, score)
+}
+
+// RecordMissedReveal records an MT due from a validator that missed its deadline
+func (v *ValidatorManager) RecordMissedReveal(address common.Address) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if validator, performance := v.performanceOf(address); validator != nil {
+		performance.MissedReveals++
+		v.adjustReputation(validator, -reputationMissedRevealPenalty)
+	}
+}
+
+// RecordMissedSlot records a slot a validator was selected for but did not
+// propose in, jailing it after the configured number of consecutive misses
+func (v *ValidatorManager) RecordMissedSlot(address common.Address) {
+	defer v.flushEvents()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if validator, performance := v.performanceOf(address); validator != nil {
+		performance.MissedSlots++
+		performance.ConsecutiveMissedSlots++
+		if v.config != nil && v.config.JailMissedSlots > 0 && performance.ConsecutiveMissedSlots >= v.config.JailMissedSlots {
+			v.jail(validator, "missed slots")
+			performance.ConsecutiveMissedSlots = 0
+		}
+		v.adjustReputation(validator, -reputationMissedSlotPenalty)
+	}
+}
+
+// GetPerformance returns the performance of a validator, nil if it is unknown
+func (v *ValidatorManager) GetPerformance(address common.Address) *ValidatorPerformance {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if _, exists := v.validators[address]; !exists {
+		return nil
+	}
+	if performance := v.performance[address]; performance != nil {
+		return performance.copy()
+	}
+	return &ValidatorPerformance{}
+}
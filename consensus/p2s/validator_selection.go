@@ -0,0 +1,307 @@
+package p2s
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Proposer selection algorithms
+const (
+	ProposerSelectionWeighted   = "weighted"    // Stake and reputation weighted draw seeded by the parent hash
+	ProposerSelectionRoundRobin = "round-robin" // Active validators in turn, by address
+	ProposerSelectionPriority   = "priority"    // Tendermint-style rotation by voting power
+)
+
+// ErrUnknownProposerSelection is returned for an unknown proposer selection algorithm
+var ErrUnknownProposerSelection = errors.New("unknown proposer selection")
+
+// NewValidatorSelection creates the proposer selection algorithm named by the
+// configuration. An empty name selects the weighted draw.
+func NewValidatorSelection(config *P2SConfig) (ValidatorSelection, error) {
+	if config == nil {
+		return NewWeightedRandomSelection(), nil
+	}
+	switch config.ProposerSelection {
+	case "", ProposerSelectionWeighted:
+		return NewWeightedRandomSelection(), nil
+	case ProposerSelectionRoundRobin:
+		return NewRoundRobinSelection(), nil
+	case ProposerSelectionPriority:
+		return NewPrioritySelection(config.MinStake), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProposerSelection, config.ProposerSelection)
+	}
+}
+
+// sortedActiveValidators returns the addresses of the active, unjailed validators
+// with stake, in address order
+func sortedActiveValidators(validators map[common.Address]*Validator) []common.Address {
+	addresses := make([]common.Address, 0, len(validators))
+	for address, validator := range validators {
+		if validator.selectable() && validator.EffectiveStake().Sign() > 0 {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	return addresses
+}
+
+// RoundRobinSelection lets the active validators propose in turn, in address
+// order, regardless of stake
+type RoundRobinSelection struct{}
+
+// NewRoundRobinSelection creates a new round-robin selection
+func NewRoundRobinSelection() *RoundRobinSelection {
+	return &RoundRobinSelection{}
+}
+
+// SelectProposer selects the validator whose turn the block number is
+func (r *RoundRobinSelection) SelectProposer(validators map[common.Address]*Validator, blockNumber uint64, seed common.Hash) (common.Address, error) {
+	addresses := sortedActiveValidators(validators)
+	if len(addresses) == 0 {
+		return common.Address{}, errors.New("no active validators")
+	}
+	return addresses[blockNumber%uint64(len(addresses))], nil
+}
+
+// SelectValidators selects the first active validators in address order
+func (r *RoundRobinSelection) SelectValidators(validators map[common.Address]*Validator, count int) []common.Address {
+	addresses := sortedActiveValidators(validators)
+	if count < 0 {
+		count = 0
+	}
+	if count > len(addresses) {
+		count = len(addresses)
+	}
+	return addresses[:count]
+}
+
+// Priority rotation bounds: the rotation restarts every priorityEpochLength
+// blocks, and the states of priorityRotationHistory blocks are kept
+const (
+	priorityEpochLength     = 1024
+	priorityRotationHistory = 1024
+)
+
+// PrioritySelection rotates proposers as Tendermint does: every block each
+// validator's priority grows by its voting power, the validator with the highest
+// priority proposes, ties going to the lowest address, and its priority drops by
+// the total voting power. Priorities are rescaled to within twice the total power
+// and centred on zero. The rotation is a pure function of the validator set and
+// the block number: it starts from zero priorities at the first block of every
+// epoch of priorityEpochLength blocks and is replayed from there, so restarted
+// and syncing nodes select the same proposers as nodes that followed the chain.
+// Voting power is effective stake in units of the minimum stake, ignoring
+// reputation, keeping schedules predictable.
+type PrioritySelection struct {
+	unit *big.Int // Stake per unit of voting power
+
+	// Rotation states by validator set and block number, caching the replay
+	rotations *lru.Cache[priorityKey, *priorityRound]
+}
+
+// priorityKey identifies the rotation state of a validator set after a block
+type priorityKey struct {
+	set    common.Hash
+	number uint64
+}
+
+// priorityRound is the rotation state after the proposer of a block is selected
+type priorityRound struct {
+	proposer   common.Address
+	priorities map[common.Address]int64
+}
+
+// NewPrioritySelection creates a new priority rotation counting one unit of voting
+// power per unit of stake, nil for one wei
+func NewPrioritySelection(unit *big.Int) *PrioritySelection {
+	if unit == nil || unit.Sign() <= 0 {
+		unit = big.NewInt(1)
+	}
+	return &PrioritySelection{
+		unit:      new(big.Int).Set(unit),
+		rotations: lru.NewCache[priorityKey, *priorityRound](priorityRotationHistory),
+	}
+}
+
+// votingPowers returns the active validators in address order and their voting
+// powers, reduced by their greatest common divisor
+func (p *PrioritySelection) votingPowers(validators map[common.Address]*Validator) ([]common.Address, []int64, int64, error) {
+	addresses := sortedActiveValidators(validators)
+	if len(addresses) == 0 {
+		return nil, nil, 0, errors.New("no active validators")
+	}
+
+	powers := make([]*big.Int, len(addresses))
+	divisor := new(big.Int)
+	for i, address := range addresses {
+		powers[i] = new(big.Int).Div(validators[address].EffectiveStake(), p.unit)
+		if powers[i].Sign() == 0 {
+			powers[i].SetInt64(1)
+		}
+		divisor.GCD(nil, nil, divisor, powers[i])
+	}
+
+	// Priorities stay within a few times the total power, bound it well inside int64
+	reduced := make([]int64, len(powers))
+	total := new(big.Int)
+	for i, power := range powers {
+		power.Div(power, divisor)
+		if total.Add(total, power); total.Cmp(big.NewInt(math.MaxInt64/8)) > 0 {
+			return nil, nil, 0, errors.New("total voting power too large")
+		}
+		reduced[i] = power.Int64()
+	}
+	return addresses, reduced, total.Int64(), nil
+}
+
+// prioritySetHash identifies a validator set with its voting powers
+func prioritySetHash(addresses []common.Address, powers []int64) common.Hash {
+	hasher := crypto.NewKeccakState()
+	for i, address := range addresses {
+		hasher.Write(address.Bytes())
+		hasher.Write(big.NewInt(powers[i]).Bytes())
+	}
+	var set common.Hash
+	hasher.Read(set[:])
+	return set
+}
+
+// round returns the rotation state of a validator set after the block number,
+// replaying the rounds since the start of its epoch or the latest cached state
+func (p *PrioritySelection) round(addresses []common.Address, powers []int64, total int64, number uint64) *priorityRound {
+	set := prioritySetHash(addresses, powers)
+	start := number - number%priorityEpochLength
+
+	// Resume from the latest cached state of the epoch
+	var priorities map[common.Address]int64
+	from := start
+	for height := number + 1; height > start; height-- {
+		if cached, exists := p.rotations.Get(priorityKey{set, height - 1}); exists {
+			if height-1 == number {
+				return cached
+			}
+			from, priorities = height, cached.priorities
+			break
+		}
+	}
+	var round *priorityRound
+	for height := from; height <= number; height++ {
+		round = advancePriorities(priorities, addresses, powers, total)
+		p.rotations.Add(priorityKey{set, height}, round)
+		priorities = round.priorities
+	}
+	return round
+}
+
+// SelectProposer selects the validator with the highest priority at the block
+// number
+func (p *PrioritySelection) SelectProposer(validators map[common.Address]*Validator, blockNumber uint64, seed common.Hash) (common.Address, error) {
+	addresses, powers, total, err := p.votingPowers(validators)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return p.round(addresses, powers, total, blockNumber).proposer, nil
+}
+
+// PreviewProposers returns the proposers of count blocks from a height if the
+// validator set stays as given
+func (p *PrioritySelection) PreviewProposers(validators map[common.Address]*Validator, from, count uint64) []common.Address {
+	addresses, powers, total, err := p.votingPowers(validators)
+	if err != nil {
+		return nil
+	}
+
+	proposers := make([]common.Address, 0, count)
+	for i := uint64(0); i < count; i++ {
+		proposers = append(proposers, p.round(addresses, powers, total, from+i).proposer)
+	}
+	return proposers
+}
+
+// advancePriorities runs one round from the priorities of the previous round,
+// nil at the start of an epoch. The previous priorities are not modified.
+func advancePriorities(previous map[common.Address]int64, addresses []common.Address, powers []int64, total int64) *priorityRound {
+	priorities := make([]int64, len(addresses))
+	for i, address := range addresses {
+		priorities[i] = previous[address]
+	}
+
+	// Keep priorities within twice the total power
+	low, high := priorities[0], priorities[0]
+	for _, priority := range priorities {
+		if priority < low {
+			low = priority
+		}
+		if priority > high {
+			high = priority
+		}
+	}
+	if spread, limit := high-low, 2*total; spread > limit {
+		ratio := (spread + limit - 1) / limit
+		for i := range priorities {
+			priorities[i] /= ratio
+		}
+	}
+
+	// Centre the priorities on zero
+	var sum big.Int
+	for _, priority := range priorities {
+		sum.Add(&sum, big.NewInt(priority))
+	}
+	average := sum.Div(&sum, big.NewInt(int64(len(priorities)))).Int64()
+	for i := range priorities {
+		priorities[i] -= average
+	}
+
+	proposer := nextPriorityProposer(priorities, powers)
+	priorities[proposer] -= total
+
+	round := &priorityRound{
+		proposer:   addresses[proposer],
+		priorities: make(map[common.Address]int64, len(addresses)),
+	}
+	for i, address := range addresses {
+		round.priorities[address] = priorities[i]
+	}
+	return round
+}
+
+// nextPriorityProposer raises the priorities by the voting powers and returns
+// the index of the highest, the lowest index on ties
+func nextPriorityProposer(priorities []int64, powers []int64) int {
+	proposer := 0
+	for i := range priorities {
+		priorities[i] += powers[i]
+		if priorities[i] > priorities[proposer] {
+			proposer = i
+		}
+	}
+	return proposer
+}
+
+// SelectValidators selects the validators with the most effective stake, ties going
+// to the lowest address
+func (p *PrioritySelection) SelectValidators(validators map[common.Address]*Validator, count int) []common.Address {
+	addresses := sortedActiveValidators(validators)
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return validators[addresses[i]].EffectiveStake().Cmp(validators[addresses[j]].EffectiveStake()) > 0
+	})
+	if count < 0 {
+		count = 0
+	}
+	if count > len(addresses) {
+		count = len(addresses)
+	}
+	return addresses[:count]
+}
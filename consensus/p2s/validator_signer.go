@@ -0,0 +1,589 @@
+package p2s
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// signerNamespace is the JSON-RPC namespace a SignerService is served under
+	signerNamespace = "p2s"
+
+	// defaultRemoteSignerTimeout bounds a single remote signing call
+	defaultRemoteSignerTimeout = 2 * time.Second
+
+	// blsKeystoreVersion is the version of the encrypted BLS keystore format
+	blsKeystoreVersion = 1
+)
+
+var (
+	// ErrNoSigner is returned when signing without a validator signer configured
+	ErrNoSigner = errors.New("no validator signer configured")
+
+	// ErrSignerMismatch is returned when a signer's signature is not made with the
+	// key it claims, or a keystore's key does not match its public key
+	ErrSignerMismatch = errors.New("signature not made with the signer's key")
+)
+
+// ValidatorSigner signs on behalf of a validator. The validator is identified by
+// the address of its ECDSA key, which signs attestations, seal proposals, proposer
+// seals and PHT handoffs; its registered BLS key signs blocks. Signers are handed the
+// payloads rather than their hashes, so they know what they sign and can refuse
+// slashable payloads. *LocalSigner and *RemoteSigner implement it.
+type ValidatorSigner interface {
+	// Address returns the validator address the signer signs for
+	Address() common.Address
+
+	// BLSPublicKey returns the compressed BLS public key, nil if the signer has none
+	BLSPublicKey() []byte
+
+	// SignAttestation signs an attestation of a B1 block with the ECDSA key
+	SignAttestation(attestation *B1Attestation) ([]byte, error)
+
+	// SignSealProposal co-signs a committee's seal proposal with the ECDSA key
+	SignSealProposal(proposal *SealProposal) ([]byte, error)
+
+	// SignProposerSeal seals a B1 or B2 block the validator produced with the ECDSA key
+	SignProposerSeal(blockType uint8, header *types.Header) ([]byte, error)
+
+	// SignPHTHandoff signs the handoff of a B1 block's PHT openings with the ECDSA key
+	SignPHTHandoff(handoff *PHTHandoff) ([]byte, error)
+
+	// SignBlock signs a B1 or B2 block with the BLS key, a share of its aggregate signature
+	SignBlock(blockType uint8, header *types.Header) ([]byte, error)
+
+	// ProveBLSPossession signs the BLS public key for its registration
+	ProveBLSPossession() ([]byte, error)
+}
+
+// signedBlockHeight returns the height of a block to sign
+func signedBlockHeight(blockType uint8, header *types.Header) (uint64, error) {
+	if blockType != 1 && blockType != 2 {
+		return 0, fmt.Errorf("unknown block type %d", blockType)
+	}
+	if header == nil || header.Number == nil {
+		return 0, errors.New("block header without number")
+	}
+	return header.Number.Uint64(), nil
+}
+
+// LocalSigner signs with keys held in process memory, refusing payloads that
+// conflict with ones it signed before
+type LocalSigner struct {
+	key        *ecdsa.PrivateKey
+	blsKey     *BLSSecretKey
+	protection *SlashingProtection
+}
+
+// NewLocalSigner creates a signer from a validator's keys. The BLS key may be nil
+// for a signer that only attests. Slashing protection is kept in memory until
+// SetSlashingProtection persists it.
+func NewLocalSigner(key *ecdsa.PrivateKey, blsKey *BLSSecretKey) *LocalSigner {
+	protection, _ := NewSlashingProtection(nil)
+	return &LocalSigner{key: key, blsKey: blsKey, protection: protection}
+}
+
+// SetSlashingProtection replaces the signer's slashing protection, typically by
+// one persisted in the signer's database so it survives restarts
+func (s *LocalSigner) SetSlashingProtection(protection *SlashingProtection) {
+	s.protection = protection
+}
+
+// Address returns the address of the ECDSA key
+func (s *LocalSigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+// BLSPublicKey returns the public key of the BLS key
+func (s *LocalSigner) BLSPublicKey() []byte {
+	if s.blsKey == nil {
+		return nil
+	}
+	return s.blsKey.PublicKey()
+}
+
+// SignAttestation signs an attestation with the ECDSA key
+func (s *LocalSigner) SignAttestation(attestation *B1Attestation) ([]byte, error) {
+	hash := attestation.SigningHash()
+	if err := s.protection.Check(signingKindAttestation, attestation.BlockNumber, hash); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash.Bytes(), s.key)
+}
+
+// SignSealProposal signs a seal proposal with the ECDSA key
+func (s *LocalSigner) SignSealProposal(proposal *SealProposal) ([]byte, error) {
+	hash := proposal.SigningHash()
+	if err := s.protection.Check(signingKindSealProposal, proposal.BlockNumber, hash); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash.Bytes(), s.key)
+}
+
+// SignProposerSeal seals a block with the ECDSA key
+func (s *LocalSigner) SignProposerSeal(blockType uint8, header *types.Header) ([]byte, error) {
+	height, err := signedBlockHeight(blockType, header)
+	if err != nil {
+		return nil, err
+	}
+	hash := proposerSigningHash(blockType, header.Hash())
+	if err := s.protection.Check(fmt.Sprintf("%s%d", signingKindProposerSeal, blockType), height, hash); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash.Bytes(), s.key)
+}
+
+// SignPHTHandoff signs a PHT handoff with the ECDSA key
+func (s *LocalSigner) SignPHTHandoff(handoff *PHTHandoff) ([]byte, error) {
+	hash := handoff.SigningHash()
+	if err := s.protection.Check(signingKindPHTHandoff, handoff.BlockNumber, hash); err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash.Bytes(), s.key)
+}
+
+// SignBlock signs a block with the BLS key
+func (s *LocalSigner) SignBlock(blockType uint8, header *types.Header) ([]byte, error) {
+	if s.blsKey == nil {
+		return nil, errors.New("signer has no BLS key")
+	}
+	height, err := signedBlockHeight(blockType, header)
+	if err != nil {
+		return nil, err
+	}
+	hash := blockSigningHash(blockType, header.Hash())
+	if err := s.protection.Check(fmt.Sprintf("%s%d", signingKindBlock, blockType), height, hash); err != nil {
+		return nil, err
+	}
+	return s.blsKey.Sign(hash)
+}
+
+// ProveBLSPossession signs the BLS public key
+func (s *LocalSigner) ProveBLSPossession() ([]byte, error) {
+	if s.blsKey == nil {
+		return nil, errors.New("signer has no BLS key")
+	}
+	return s.blsKey.ProvePossession()
+}
+
+// blsKeystore is the encrypted keystore file of a BLS key, encrypted the way
+// go-ethereum keystores encrypt ECDSA keys
+type blsKeystore struct {
+	PublicKey hexutil.Bytes       `json:"publicKey"`
+	Crypto    keystore.CryptoJSON `json:"crypto"`
+	Version   int                 `json:"version"`
+}
+
+// EncryptBLSKey encrypts a BLS key into a keystore file with a passphrase, using
+// the scrypt parameters of go-ethereum keystores
+func EncryptBLSKey(key *BLSSecretKey, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	cryptoJSON, err := keystore.EncryptDataV3(key.Bytes(), []byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&blsKeystore{
+		PublicKey: key.PublicKey(),
+		Crypto:    cryptoJSON,
+		Version:   blsKeystoreVersion,
+	})
+}
+
+// DecryptBLSKey decrypts a BLS keystore file encrypted by EncryptBLSKey
+func DecryptBLSKey(keyJSON []byte, passphrase string) (*BLSSecretKey, error) {
+	var file blsKeystore
+	if err := json.Unmarshal(keyJSON, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != blsKeystoreVersion {
+		return nil, fmt.Errorf("unsupported BLS keystore version %d", file.Version)
+	}
+	data, err := keystore.DecryptDataV3(file.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	key, err := BLSSecretKeyFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(key.PublicKey(), file.PublicKey) {
+		return nil, fmt.Errorf("%w: BLS keystore holds the key of another public key", ErrSignerMismatch)
+	}
+	return key, nil
+}
+
+// LoadKeystoreSigner creates a local signer from an encrypted go-ethereum keystore
+// file of the validator's ECDSA key and, unless blsKeyJSON is nil, a BLS keystore
+// file, both encrypted with the passphrase
+func LoadKeystoreSigner(keyJSON, blsKeyJSON []byte, passphrase string) (*LocalSigner, error) {
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	signer := NewLocalSigner(key.PrivateKey, nil)
+	if blsKeyJSON != nil {
+		if signer.blsKey, err = DecryptBLSKey(blsKeyJSON, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+// SignerIdentity is the validator a signer signs for and its BLS public key
+type SignerIdentity struct {
+	Address      common.Address `json:"address"`
+	BLSPublicKey hexutil.Bytes  `json:"blsPublicKey,omitempty"`
+}
+
+// SignerService serves a signer over JSON-RPC in the p2s namespace, so a separate
+// signer process can hold the validator's keys for a RemoteSigner. Its endpoint
+// must only be reachable by the validator's consensus node.
+type SignerService struct {
+	signer ValidatorSigner
+}
+
+// NewSignerService serves a signer
+func NewSignerService(signer ValidatorSigner) *SignerService {
+	return &SignerService{signer: signer}
+}
+
+// RegisterSignerService registers a signer service with a JSON-RPC server
+func RegisterSignerService(server *rpc.Server, service *SignerService) error {
+	return server.RegisterName(signerNamespace, service)
+}
+
+// SignerIdentity returns the validator signed for and its BLS public key
+func (s *SignerService) SignerIdentity() SignerIdentity {
+	return SignerIdentity{
+		Address:      s.signer.Address(),
+		BLSPublicKey: s.signer.BLSPublicKey(),
+	}
+}
+
+// SignAttestation signs an attestation with the validator's ECDSA key
+func (s *SignerService) SignAttestation(attestation *B1Attestation) (hexutil.Bytes, error) {
+	if attestation == nil {
+		return nil, errors.New("missing attestation")
+	}
+	return s.signer.SignAttestation(attestation)
+}
+
+// SignSealProposal signs a seal proposal with the validator's ECDSA key
+func (s *SignerService) SignSealProposal(proposal *SealProposal) (hexutil.Bytes, error) {
+	if proposal == nil {
+		return nil, errors.New("missing seal proposal")
+	}
+	return s.signer.SignSealProposal(proposal)
+}
+
+// SignProposerSeal seals an RLP encoded block header with the validator's ECDSA key
+func (s *SignerService) SignProposerSeal(blockType uint8, header hexutil.Bytes) (hexutil.Bytes, error) {
+	decoded := new(types.Header)
+	if err := rlp.DecodeBytes(header, decoded); err != nil {
+		return nil, err
+	}
+	return s.signer.SignProposerSeal(blockType, decoded)
+}
+
+// SignPHTHandoff signs a PHT handoff with the validator's ECDSA key
+func (s *SignerService) SignPHTHandoff(handoff *PHTHandoff) (hexutil.Bytes, error) {
+	if handoff == nil {
+		return nil, errors.New("missing PHT handoff")
+	}
+	return s.signer.SignPHTHandoff(handoff)
+}
+
+// SignBlock signs an RLP encoded block header with the validator's BLS key
+func (s *SignerService) SignBlock(blockType uint8, header hexutil.Bytes) (hexutil.Bytes, error) {
+	decoded := new(types.Header)
+	if err := rlp.DecodeBytes(header, decoded); err != nil {
+		return nil, err
+	}
+	return s.signer.SignBlock(blockType, decoded)
+}
+
+// ProveBLSPossession signs the validator's BLS public key
+func (s *SignerService) ProveBLSPossession() (hexutil.Bytes, error) {
+	return s.signer.ProveBLSPossession()
+}
+
+// RemoteSigner signs through a SignerService in an external signer process, so
+// the validator's keys never live on the consensus node. Every signature is
+// checked against the identity the signer announced when connecting.
+type RemoteSigner struct {
+	client   *rpc.Client
+	identity SignerIdentity
+	timeout  time.Duration
+}
+
+// NewRemoteSigner connects to a signer service and fetches the identity it signs
+// for. A timeout of zero uses the default.
+func NewRemoteSigner(ctx context.Context, client *rpc.Client, timeout time.Duration) (*RemoteSigner, error) {
+	if timeout <= 0 {
+		timeout = defaultRemoteSignerTimeout
+	}
+	signer := &RemoteSigner{client: client, timeout: timeout}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.CallContext(callCtx, &signer.identity, signerNamespace+"_signerIdentity"); err != nil {
+		return nil, err
+	}
+	if len(signer.identity.BLSPublicKey) > 0 {
+		if _, err := decodeBLSPublicKey(signer.identity.BLSPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+// call makes a signing call bounded by the signer timeout
+func (s *RemoteSigner) call(method string, args ...interface{}) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var sig hexutil.Bytes
+	err := s.client.CallContext(ctx, &sig, signerNamespace+"_"+method, args...)
+	return sig, err
+}
+
+// Address returns the validator address the remote signer signs for
+func (s *RemoteSigner) Address() common.Address {
+	return s.identity.Address
+}
+
+// BLSPublicKey returns the BLS public key the remote signer signs with
+func (s *RemoteSigner) BLSPublicKey() []byte {
+	return common.CopyBytes(s.identity.BLSPublicKey)
+}
+
+// SignAttestation signs an attestation with the remote ECDSA key
+func (s *RemoteSigner) SignAttestation(attestation *B1Attestation) ([]byte, error) {
+	sig, err := s.call("signAttestation", attestation)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkECDSA(attestation.SigningHash(), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignSealProposal signs a seal proposal with the remote ECDSA key
+func (s *RemoteSigner) SignSealProposal(proposal *SealProposal) ([]byte, error) {
+	sig, err := s.call("signSealProposal", proposal)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkECDSA(proposal.SigningHash(), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignProposerSeal seals a block with the remote ECDSA key
+func (s *RemoteSigner) SignProposerSeal(blockType uint8, header *types.Header) ([]byte, error) {
+	encoded, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := s.call("signProposerSeal", blockType, hexutil.Bytes(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkECDSA(proposerSigningHash(blockType, header.Hash()), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignPHTHandoff signs a PHT handoff with the remote ECDSA key
+func (s *RemoteSigner) SignPHTHandoff(handoff *PHTHandoff) ([]byte, error) {
+	sig, err := s.call("signPHTHandoff", handoff)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkECDSA(handoff.SigningHash(), sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// SignBlock signs a block with the remote BLS key
+func (s *RemoteSigner) SignBlock(blockType uint8, header *types.Header) ([]byte, error) {
+	encoded, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := s.call("signBlock", blockType, hexutil.Bytes(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBLS(blockSigningHash(blockType, header.Hash()).Bytes(), blsSignatureDST, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// ProveBLSPossession has the remote signer prove possession of its BLS key
+func (s *RemoteSigner) ProveBLSPossession() ([]byte, error) {
+	proof, err := s.call("proveBLSPossession")
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyBLSPossession(s.identity.BLSPublicKey, proof); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignerMismatch, err)
+	}
+	return proof, nil
+}
+
+// checkECDSA checks a remote ECDSA signature against the announced address
+func (s *RemoteSigner) checkECDSA(hash common.Hash, sig []byte) error {
+	if signer, err := recoverSealSigner(hash, sig); err != nil || signer != s.identity.Address {
+		return fmt.Errorf("%w: not signed by %s", ErrSignerMismatch, s.identity.Address.Hex())
+	}
+	return nil
+}
+
+// checkBLS checks a remote BLS signature against the announced public key
+func (s *RemoteSigner) checkBLS(msg, dst, data []byte) error {
+	pub, err := decodeBLSPublicKey(s.identity.BLSPublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignerMismatch, err)
+	}
+	sig, err := decodeBLSSignature(data)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignerMismatch, err)
+	}
+	if ok, err := verifyBLS(pub, msg, dst, sig); err != nil || !ok {
+		return fmt.Errorf("%w: BLS signature does not verify", ErrSignerMismatch)
+	}
+	return nil
+}
+
+// SetSigner sets the signer of the validator this node signs for, nil to stop
+// signing. Setting a signer with a BLS key other than the validator's registered
+// one rotates the validator's BLS key: the new key is registered with its proof
+// of possession, and blocks signed with the old key no longer aggregate. The
+// validator's ECDSA key is its address and cannot rotate.
+func (p *P2SConsensus) SetSigner(signer ValidatorSigner) error {
+	// Prove possession before locking, remote signers may take a while
+	var publicKey, proof []byte
+	if signer != nil {
+		publicKey = signer.BLSPublicKey()
+		validator := p.validatorMgr.GetValidator(signer.Address())
+		if validator != nil && len(publicKey) > 0 && !bytes.Equal(validator.BLSPublicKey, publicKey) {
+			var err error
+			if proof, err = signer.ProveBLSPossession(); err != nil {
+				return err
+			}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if proof != nil {
+		if err := p.validatorMgr.RegisterBLSKey(signer.Address(), publicKey, proof); err != nil {
+			return err
+		}
+		log.Info("Rotated validator BLS key", "validator", signer.Address(), "key", hexutil.Bytes(publicKey))
+	}
+	p.signer = signer
+	return nil
+}
+
+// SignBlockShare signs a cached B1 or B2 block with this node's validator BLS
+// key, a share of the aggregate signature carried in the block's ValidatorSig
+func (p *P2SConsensus) SignBlockShare(hash common.Hash) (*BlockSignature, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.signer == nil {
+		return nil, ErrNoSigner
+	}
+	signature := &BlockSignature{Validator: p.signer.Address()}
+	if b1Block, exists := p.cache.GetB1Block(hash); exists {
+		signature.BlockType, signature.Header = 1, b1Block.Header
+	} else if b2Block, exists := p.cache.GetB2Block(hash); exists {
+		signature.BlockType, signature.Header = 2, b2Block.Header
+	} else {
+		return nil, errors.New("block not found")
+	}
+	sig, err := p.signer.SignBlock(signature.BlockType, signature.Header)
+	if err != nil {
+		return nil, err
+	}
+	signature.Signature = sig
+	return signature, nil
+}
+
+// AggregateBlockShares aggregates validators' signature shares of a cached B1 or
+// B2 block into its ValidatorSig, which the shares must carry quorum for in the
+// key set of the block's height
+func (p *P2SConsensus) AggregateBlockShares(hash common.Hash, shares []*BlockSignature) error {
+	p.mu.RLock()
+	var keys *BLSKeySet
+	if b1Block, exists := p.cache.GetB1Block(hash); exists {
+		keys = p.blockKeySet(b1Block.Header.Number.Uint64())
+	} else if b2Block, exists := p.cache.GetB2Block(hash); exists {
+		keys = p.blockKeySet(b2Block.Header.Number.Uint64())
+	}
+	p.mu.RUnlock()
+	if keys == nil {
+		return errors.New("block not found")
+	}
+
+	signatures := make(map[common.Address][]byte, len(shares))
+	for _, share := range shares {
+		signatures[share.Validator] = share.Signature
+	}
+	aggregate, err := NewAggregateSignature(keys, signatures)
+	if err != nil {
+		return err
+	}
+	return p.SignBlock(hash, aggregate)
+}
+
+// Attest signs an attestation of a cached B1 block and its MEV score with this
+// node's validator key and adds it to the attestation pool. The attestation is
+// returned for broadcast.
+func (p *P2SConsensus) Attest(b1Hash common.Hash) (*B1Attestation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.signer == nil {
+		return nil, ErrNoSigner
+	}
+	b1Block, exists := p.cache.GetB1Block(b1Hash)
+	if !exists {
+		return nil, errors.New("B1 block not found")
+	}
+	attestation := &B1Attestation{
+		BlockNumber: b1Block.Header.Number.Uint64(),
+		B1Hash:      b1Hash,
+		MEVScore:    b1Block.MEVScore,
+		Attester:    p.signer.Address(),
+	}
+	sig, err := p.signer.SignAttestation(attestation)
+	if err != nil {
+		return nil, err
+	}
+	attestation.Signature = sig
+	if _, err := p.attestations.Add(attestation); err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
@@ -0,0 +1,306 @@
+package p2s
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// validatorSnapshotKey -> JSON encoded validatorSnapshot of the whole validator set
+	validatorSnapshotKey = []byte("p2s-validator-snapshot")
+
+	// validatorRecordPrefix + address -> JSON encoded validatorRecord written since the snapshot
+	validatorRecordPrefix = []byte("p2s-validator-record-")
+)
+
+// validatorRecord is the persisted state of one validator. Records of removed
+// validators are kept as tombstones until the next snapshot.
+type validatorRecord struct {
+	Address          common.Address        `json:"address"`
+	Removed          bool                  `json:"removed,omitempty"`
+	Validator        *Validator            `json:"validator,omitempty"`
+	ReputationBase   int64                 `json:"reputationBase"`
+	ReputationAnchor uint64                `json:"reputationAnchor"`
+	Performance      *ValidatorPerformance `json:"performance,omitempty"`
+	RevealLatency    time.Duration         `json:"revealLatency"` // Performance totals the averages are kept from
+	MEVScore         float64               `json:"mevScore"`
+	Delegations      []*Delegation         `json:"delegations,omitempty"`
+	Unbonding        []*UnbondingEntry     `json:"unbonding,omitempty"` // Kept for removed validators too
+}
+
+// validatorSnapshot is the persisted validator set at a block height
+type validatorSnapshot struct {
+	Height     uint64             `json:"height"`
+	Validators []*validatorRecord `json:"validators"`
+}
+
+// validatorRecordKey returns the database key of a validator's record
+func validatorRecordKey(address common.Address) []byte {
+	return append(common.CopyBytes(validatorRecordPrefix), address.Bytes()...)
+}
+
+// SetDatabase attaches a database. A validator set persisted in it replaces the
+// one in memory; otherwise the validator set in memory is snapshotted into it.
+// Every later change is written through.
+func (v *ValidatorManager) SetDatabase(db ethdb.KeyValueStore) error {
+	snapshot, err := readValidatorSnapshot(db)
+	if err != nil {
+		return err
+	}
+	records, err := readValidatorRecords(db)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.db = db
+	if snapshot == nil && len(records) == 0 {
+		return v.snapshot()
+	}
+
+	v.validators = make(map[common.Address]*Validator)
+	v.delegations = make(map[common.Address]map[common.Address]*Delegation)
+	v.performance = make(map[common.Address]*ValidatorPerformance)
+	v.unbonding = make(map[common.Address][]*UnbondingEntry)
+	if snapshot != nil {
+		if snapshot.Height > v.height {
+			v.height = snapshot.Height
+		}
+		records = append(snapshot.Validators, records...)
+	}
+	for _, record := range records {
+		v.restore(record)
+	}
+	for _, validator := range v.validators {
+		if validator.reputationAnchor > v.height {
+			v.height = validator.reputationAnchor
+		}
+	}
+	for _, validator := range v.validators {
+		validator.Reputation = v.curve.At(validator.reputationBase, v.height-validator.reputationAnchor)
+	}
+	log.Info("Loaded persisted validator set", "validators", len(v.validators), "height", v.height)
+	return nil
+}
+
+// restore replaces a validator's state by a persisted record. The caller must
+// hold the lock.
+func (v *ValidatorManager) restore(record *validatorRecord) {
+	delete(v.validators, record.Address)
+	delete(v.delegations, record.Address)
+	delete(v.performance, record.Address)
+	delete(v.unbonding, record.Address)
+	for _, entry := range record.Unbonding {
+		entry.Validator = record.Address
+		entry.Amount = bigOrZero(entry.Amount)
+		v.unbonding[record.Address] = append(v.unbonding[record.Address], entry)
+	}
+	if record.Removed || record.Validator == nil {
+		return
+	}
+
+	validator := record.Validator
+	validator.Address = record.Address
+	validator.Stake = bigOrZero(validator.Stake)
+	validator.Delegated = bigOrZero(validator.Delegated)
+	validator.reputationBase = record.ReputationBase
+	validator.reputationAnchor = record.ReputationAnchor
+	v.validators[record.Address] = validator
+
+	if record.Performance != nil {
+		performance := record.Performance
+		performance.revealLatency = record.RevealLatency
+		performance.mevScore = record.MEVScore
+		v.performance[record.Address] = performance
+	}
+	for _, delegation := range record.Delegations {
+		if v.delegations[record.Address] == nil {
+			v.delegations[record.Address] = make(map[common.Address]*Delegation)
+		}
+		delegation.Validator = record.Address
+		delegation.Amount = bigOrZero(delegation.Amount)
+		delegation.Rewards = bigOrZero(delegation.Rewards)
+		v.delegations[record.Address][delegation.Delegator] = delegation
+	}
+}
+
+// record returns the persisted form of a validator's state, a tombstone if it
+// was removed. The caller must hold the lock.
+func (v *ValidatorManager) record(address common.Address) *validatorRecord {
+	validator, exists := v.validators[address]
+	if !exists {
+		return &validatorRecord{Address: address, Removed: true, Unbonding: v.unbonding[address]}
+	}
+	record := &validatorRecord{
+		Address:          address,
+		Validator:        validator,
+		ReputationBase:   validator.reputationBase,
+		ReputationAnchor: validator.reputationAnchor,
+		Delegations:      v.sortedDelegations(address),
+		Unbonding:        v.unbonding[address],
+	}
+	if performance := v.performance[address]; performance != nil {
+		record.Performance = performance
+		record.RevealLatency = performance.revealLatency
+		record.MEVScore = performance.mevScore
+	}
+	return record
+}
+
+// persist writes a validator's state through to the attached database. The
+// caller must hold the lock.
+func (v *ValidatorManager) persist(address common.Address) {
+	if v.db == nil {
+		return
+	}
+	data, err := json.Marshal(v.record(address))
+	if err == nil {
+		err = v.db.Put(validatorRecordKey(address), data)
+	}
+	if err != nil {
+		log.Error("Failed to persist validator", "validator", address, "err", err)
+	}
+}
+
+// Snapshot writes the whole validator set to the attached database, replacing
+// the records written through since the last snapshot
+func (v *ValidatorManager) Snapshot() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.db == nil {
+		return nil
+	}
+	return v.snapshot()
+}
+
+// snapshot writes the validator set at the current height. The caller must hold
+// the lock.
+func (v *ValidatorManager) snapshot() error {
+	addresses := make([]common.Address, 0, len(v.validators))
+	for address := range v.validators {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i][:], addresses[j][:]) < 0
+	})
+	snapshot := &validatorSnapshot{
+		Height:     v.height,
+		Validators: make([]*validatorRecord, 0, len(addresses)),
+	}
+	for _, address := range addresses {
+		snapshot.Validators = append(snapshot.Validators, v.record(address))
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	batch := v.db.NewBatch()
+	if err := batch.Put(validatorSnapshotKey, data); err != nil {
+		return err
+	}
+	it := v.db.NewIterator(validatorRecordPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		if err := batch.Delete(common.CopyBytes(it.Key())); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// readValidatorSnapshot reads the persisted validator set snapshot, nil if none
+func readValidatorSnapshot(db ethdb.KeyValueReader) (*validatorSnapshot, error) {
+	has, err := db.Has(validatorSnapshotKey)
+	if err != nil || !has {
+		return nil, err
+	}
+	data, err := db.Get(validatorSnapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := new(validatorSnapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// readValidatorRecords reads the validator records written since the snapshot
+func readValidatorRecords(db ethdb.Iteratee) ([]*validatorRecord, error) {
+	it := db.NewIterator(validatorRecordPrefix, nil)
+	defer it.Release()
+
+	var records []*validatorRecord
+	for it.Next() {
+		record := new(validatorRecord)
+		if err := json.Unmarshal(it.Value(), record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, it.Error()
+}
+
+// checkpoint returns the persisted form of a validator's state for rollback
+func (v *ValidatorManager) checkpoint(address common.Address) json.RawMessage {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	data, err := json.Marshal(v.record(address))
+	if err != nil {
+		log.Error("Failed to checkpoint validator", "validator", address, "err", err)
+		return nil
+	}
+	return data
+}
+
+// rollback returns a validator's stake to a checkpoint. A validator present both
+// then and now only has its stake and unbonding stake restored, keeping what
+// happened to it since; one added or removed since is restored in full.
+func (v *ValidatorManager) rollback(data json.RawMessage) error {
+	record := new(validatorRecord)
+	if err := json.Unmarshal(data, record); err != nil {
+		return err
+	}
+	defer v.flushEvents()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	current, exists := v.validators[record.Address]
+	switch {
+	case exists && !record.Removed && record.Validator != nil:
+		current.Stake = bigOrZero(record.Validator.Stake)
+		v.setActive(current, current.Stake.Cmp(v.config.MinStake) >= 0)
+		delete(v.unbonding, record.Address)
+		for _, entry := range record.Unbonding {
+			entry.Validator = record.Address
+			entry.Amount = bigOrZero(entry.Amount)
+			v.unbonding[record.Address] = append(v.unbonding[record.Address], entry)
+		}
+	default:
+		v.restore(record)
+		if restored := v.validators[record.Address]; restored != nil {
+			if restored.reputationAnchor <= v.height {
+				restored.Reputation = v.curve.At(restored.reputationBase, v.height-restored.reputationAnchor)
+			}
+			v.emit(ValidatorEventAdded, restored)
+		} else if exists {
+			v.emit(ValidatorEventRemoved, current)
+		}
+	}
+	v.persist(record.Address)
+	return nil
+}
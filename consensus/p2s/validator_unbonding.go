@@ -0,0 +1,162 @@
+package p2s
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// UnbondingEntry is stake a validator removed that is withheld until an epoch.
+// It no longer counts towards selection but can still be slashed.
+type UnbondingEntry struct {
+	Validator       common.Address `json:"validator"`
+	Amount          *big.Int       `json:"amount"`
+	Height          uint64         `json:"height"`          // Block height the stake was removed at
+	CompletionEpoch uint64         `json:"completionEpoch"` // Epoch from which the stake is released
+}
+
+// copy returns a copy of the entry
+func (e *UnbondingEntry) copy() *UnbondingEntry {
+	return &UnbondingEntry{
+		Validator:       e.Validator,
+		Amount:          new(big.Int).Set(e.Amount),
+		Height:          e.Height,
+		CompletionEpoch: e.CompletionEpoch,
+	}
+}
+
+// epoch returns the epoch of the current height. The caller must hold the lock.
+func (v *ValidatorManager) epoch() uint64 {
+	if v.config == nil || v.config.EpochLength == 0 {
+		return v.height
+	}
+	return v.height / v.config.EpochLength
+}
+
+// unbond queues stake removed from a validator for the configured number of
+// epochs; without unbonding epochs it is released at once. The caller must hold
+// the lock.
+func (v *ValidatorManager) unbond(address common.Address, amount *big.Int) {
+	if v.config == nil || v.config.UnbondingEpochs == 0 || amount.Sign() <= 0 {
+		return
+	}
+	v.unbonding[address] = append(v.unbonding[address], &UnbondingEntry{
+		Validator:       address,
+		Amount:          new(big.Int).Set(amount),
+		Height:          v.height,
+		CompletionEpoch: v.epoch() + v.config.UnbondingEpochs,
+	})
+}
+
+// slashUnbonding removes up to amount from a validator's unbonding stake, most
+// recently removed first, and returns the amount removed. The caller must hold
+// the lock.
+func (v *ValidatorManager) slashUnbonding(address common.Address, amount *big.Int) *big.Int {
+	slashed := new(big.Int)
+	entries := v.unbonding[address]
+	for i := len(entries) - 1; i >= 0 && slashed.Cmp(amount) < 0; i-- {
+		take := new(big.Int).Sub(amount, slashed)
+		if take.Cmp(entries[i].Amount) > 0 {
+			take.Set(entries[i].Amount)
+		}
+		entries[i].Amount = new(big.Int).Sub(entries[i].Amount, take)
+		slashed.Add(slashed, take)
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Amount.Sign() > 0 {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == 0 {
+		delete(v.unbonding, address)
+	} else {
+		v.unbonding[address] = kept
+	}
+	return slashed
+}
+
+// SlashableStake returns a validator's own stake plus its unbonding stake
+func (v *ValidatorManager) SlashableStake(address common.Address) *big.Int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	stake := new(big.Int)
+	if validator, exists := v.validators[address]; exists {
+		stake.Set(validator.Stake)
+	}
+	for _, entry := range v.unbonding[address] {
+		stake.Add(stake, entry.Amount)
+	}
+	return stake
+}
+
+// GetUnbonding returns a validator's unbonding stake, oldest first
+func (v *ValidatorManager) GetUnbonding(address common.Address) []*UnbondingEntry {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	entries := make([]*UnbondingEntry, 0, len(v.unbonding[address]))
+	for _, entry := range v.unbonding[address] {
+		entries = append(entries, entry.copy())
+	}
+	return entries
+}
+
+// GetUnbondingQueue returns all unbonding stake by completion epoch, then
+// validator address
+func (v *ValidatorManager) GetUnbondingQueue() []*UnbondingEntry {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var queue []*UnbondingEntry
+	for _, entries := range v.unbonding {
+		for _, entry := range entries {
+			queue = append(queue, entry.copy())
+		}
+	}
+	sortUnbonding(queue)
+	return queue
+}
+
+// ProcessUnbonding releases the unbonding stake completing at or before an
+// epoch, to be called at epoch boundaries, and returns the released entries
+func (v *ValidatorManager) ProcessUnbonding(epoch uint64) []*UnbondingEntry {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var released []*UnbondingEntry
+	for address, entries := range v.unbonding {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.CompletionEpoch <= epoch {
+				released = append(released, entry)
+			} else {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == len(entries) {
+			continue
+		}
+		if len(kept) == 0 {
+			delete(v.unbonding, address)
+		} else {
+			v.unbonding[address] = kept
+		}
+		v.persist(address)
+	}
+	sortUnbonding(released)
+	return released
+}
+
+// sortUnbonding orders entries by completion epoch, then validator address
+func sortUnbonding(entries []*UnbondingEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].CompletionEpoch != entries[j].CompletionEpoch {
+			return entries[i].CompletionEpoch < entries[j].CompletionEpoch
+		}
+		return bytes.Compare(entries[i].Validator[:], entries[j].Validator[:]) < 0
+	})
+}
@@ -0,0 +1,192 @@
+package p2s
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Hidden field positions in the vector commitment
+const (
+	FieldRecipient = iota
+	FieldValue
+	FieldCallData
+	FieldTxType
+	FieldGasLimit
+
+	numHiddenFields
+)
+
+// vectorWidth is the number of leaves in the vector commitment tree
+const vectorWidth = 8
+
+// fieldSaltLength is the length of the per-field blinding salt
+const fieldSaltLength = 32
+
+// hiddenFieldNames maps field positions to names
+var hiddenFieldNames = [numHiddenFields]string{
+	FieldRecipient: "recipient",
+	FieldValue:     "value",
+	FieldCallData:  "callData",
+	FieldTxType:    "txType",
+	FieldGasLimit:  "gasLimit",
+}
+
+// FieldOpening opens a single position of a vector commitment
+type FieldOpening struct {
+	Index int      `json:"index"`
+	Value []byte   `json:"value"`
+	Salt  []byte   `json:"salt"`
+	Path  [][]byte `json:"path"` // Sibling hashes from leaf to root
+}
+
+// VectorCommitment commits to the hidden fields with one position per field, so
+// individual fields can be revealed and proven without opening the others
+type VectorCommitment struct{}
+
+// NewVectorCommitment creates a new vector commitment scheme
+func NewVectorCommitment() *VectorCommitment {
+	return &VectorCommitment{}
+}
+
+// CommitVector commits to the given fields, returning the commitment and per-field salts
+func (v *VectorCommitment) CommitVector(fields [][]byte) ([]byte, [][]byte, error) {
+	if len(fields) == 0 || len(fields) > vectorWidth {
+		return nil, nil, fmt.Errorf("invalid field count %d", len(fields))
+	}
+
+	salts := make([][]byte, len(fields))
+	for i := range fields {
+		salts[i] = make([]byte, fieldSaltLength)
+		if _, err := rand.Read(salts[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	root, err := v.Root(fields, salts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return root, salts, nil
+}
+
+// Root computes the vector commitment for the given fields and salts
+func (v *VectorCommitment) Root(fields [][]byte, salts [][]byte) ([]byte, error) {
+	levels, err := v.buildTree(fields, salts)
+	if err != nil {
+		return nil, err
+	}
+	return levels[len(levels)-1][0], nil
+}
+
+// OpenField creates an opening for a single field position
+func (v *VectorCommitment) OpenField(fields [][]byte, salts [][]byte, index int) (*FieldOpening, error) {
+	if index < 0 || index >= len(fields) {
+		return nil, fmt.Errorf("field index %d out of range", index)
+	}
+
+	levels, err := v.buildTree(fields, salts)
+	if err != nil {
+		return nil, err
+	}
+
+	path := make([][]byte, 0, len(levels)-1)
+	position := index
+	for _, level := range levels[:len(levels)-1] {
+		path = append(path, level[position^1])
+		position /= 2
+	}
+
+	return &FieldOpening{
+		Index: index,
+		Value: common.CopyBytes(fields[index]),
+		Salt:  common.CopyBytes(salts[index]),
+		Path:  path,
+	}, nil
+}
+
+// VerifyField verifies a single field opening against a vector commitment
+func (v *VectorCommitment) VerifyField(commitment []byte, opening *FieldOpening) bool {
+	if opening == nil || opening.Index < 0 || opening.Index >= vectorWidth {
+		return false
+	}
+
+	current := vectorLeaf(opening.Index, opening.Value, opening.Salt)
+	position := opening.Index
+	for _, sibling := range opening.Path {
+		if position%2 == 0 {
+			current = crypto.Keccak256(current, sibling)
+		} else {
+			current = crypto.Keccak256(sibling, current)
+		}
+		position /= 2
+	}
+
+	return position == 0 && constantTimeEqual(current, commitment)
+}
+
+// buildTree builds all levels of the vector commitment tree
+func (v *VectorCommitment) buildTree(fields [][]byte, salts [][]byte) ([][][]byte, error) {
+	if len(fields) != len(salts) {
+		return nil, errors.New("field and salt count mismatch")
+	}
+	if len(fields) == 0 || len(fields) > vectorWidth {
+		return nil, fmt.Errorf("invalid field count %d", len(fields))
+	}
+
+	leaves := make([][]byte, vectorWidth)
+	for i := 0; i < vectorWidth; i++ {
+		if i < len(fields) {
+			leaves[i] = vectorLeaf(i, fields[i], salts[i])
+		} else {
+			leaves[i] = make([]byte, 32)
+		}
+	}
+
+	levels := [][][]byte{leaves}
+	for len(levels[len(levels)-1]) > 1 {
+		prev := levels[len(levels)-1]
+		next := make([][]byte, len(prev)/2)
+		for i := range next {
+			next[i] = crypto.Keccak256(prev[2*i], prev[2*i+1])
+		}
+		levels = append(levels, next)
+	}
+
+	return levels, nil
+}
+
+// vectorLeaf computes the leaf hash for a field position
+func vectorLeaf(index int, value []byte, salt []byte) []byte {
+	return crypto.Keccak256([]byte("p2s-field"), []byte{byte(index)}, salt, value)
+}
+
+// hiddenFieldVector encodes the hidden fields as vector commitment positions, always
+// at fixed width. The recipient of a contract creation is empty.
+func hiddenFieldVector(to *common.Address, value *big.Int, callData []byte, txType uint8, gasLimit uint64) [][]byte {
+	var recipient []byte
+	if to != nil {
+		recipient = to.Bytes()
+	}
+
+	return [][]byte{
+		FieldRecipient: recipient,
+		FieldValue:     encodeBig(value),
+		FieldCallData:  callData,
+		FieldTxType:    encodeUint8(txType),
+		FieldGasLimit:  encodeUint64(gasLimit),
+	}
+}
+
+// HiddenFieldName returns the name of a hidden field position
+func HiddenFieldName(index int) string {
+	if index < 0 || index >= numHiddenFields {
+		return "unknown"
+	}
+	return hiddenFieldNames[index]
+}
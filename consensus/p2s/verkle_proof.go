@@ -0,0 +1,217 @@
+package p2s
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/bits"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/kzg4844"
+)
+
+// Verkle trees here have nodes of verkleWidth children, each node a KZG commitment
+// to an EIP-4844 blob holding the hashes of its children. A leaf is opened with a
+// KZG proof at its position in the blob's evaluation domain, so proofs grow by one
+// commitment and one opening per level, and a single level covers 4096 leaves.
+// Proofs encode the proven leaf index as 4 big-endian bytes, followed by the
+// commitment and opening proof of the node on each level from the leaf level up.
+const (
+	verkleWidth     = len(kzg4844.Blob{}) / 32
+	verkleWidthBits = 12
+	verkleLevelSize = len(kzg4844.Commitment{}) + len(kzg4844.Proof{})
+	verkleMaxDepth  = 3
+)
+
+var (
+	// blsModulus is the order of the BLS12-381 scalar field blobs are defined over
+	blsModulus, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+	// verkleDomain holds the evaluation point of each blob position: the powers of
+	// a primitive 4096th root of unity in bit-reversed order, as in EIP-4844
+	verkleDomain     []kzg4844.Point
+	verkleDomainOnce sync.Once
+)
+
+// verklePoint returns the evaluation point of a blob position
+func verklePoint(position int) kzg4844.Point {
+	verkleDomainOnce.Do(func() {
+		exponent := new(big.Int).Div(new(big.Int).Sub(blsModulus, big.NewInt(1)), big.NewInt(int64(verkleWidth)))
+		root := new(big.Int).Exp(big.NewInt(7), exponent, blsModulus)
+
+		verkleDomain = make([]kzg4844.Point, verkleWidth)
+		for i := range verkleDomain {
+			power := bits.Reverse32(uint32(i)) >> (32 - verkleWidthBits)
+			new(big.Int).Exp(root, big.NewInt(int64(power)), blsModulus).FillBytes(verkleDomain[i][:])
+		}
+	})
+	return verkleDomain[position]
+}
+
+// verkleElement maps bytes to a blob field element by hashing, the leading byte
+// zeroed so the element is below the modulus
+func verkleElement(data []byte) [32]byte {
+	var element [32]byte
+	copy(element[:], crypto.Keccak256(data))
+	element[0] = 0
+	return element
+}
+
+// verkleNode is a node of a Verkle tree
+type verkleNode struct {
+	blob       *kzg4844.Blob
+	commitment kzg4844.Commitment
+}
+
+// VerkleProofSystem implements proofs over a Verkle tree of the data leaves
+type VerkleProofSystem struct{}
+
+// NewVerkleProofSystem creates a new Verkle proof system
+func NewVerkleProofSystem() *VerkleProofSystem {
+	return &VerkleProofSystem{}
+}
+
+// ProofSystemID returns the identifier embedded in Verkle proofs
+func (v *VerkleProofSystem) ProofSystemID() uint8 {
+	return ProofSystemIDVerkle
+}
+
+// ProofVersion returns the version of the Verkle proof format
+func (v *VerkleProofSystem) ProofVersion() uint8 {
+	return 1
+}
+
+// Prove creates a proof that the commitment is one of the data leaves
+func (v *VerkleProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("no data to prove")
+	}
+	leafIndex := NewMerkleProofSystem().findLeafIndex(data, commitment)
+	if leafIndex == -1 {
+		return nil, errors.New("commitment not found in tree")
+	}
+	tree, err := v.buildTree(data)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := make([]byte, 4, 4+len(tree)*verkleLevelSize)
+	binary.BigEndian.PutUint32(proof, uint32(leafIndex))
+	index := leafIndex
+	for _, level := range tree {
+		node := level[index/verkleWidth]
+		opening, _, err := kzg4844.ComputeProof(node.blob, verklePoint(index%verkleWidth))
+		if err != nil {
+			return nil, err
+		}
+		proof = append(proof, node.commitment[:]...)
+		proof = append(proof, opening[:]...)
+		index /= verkleWidth
+	}
+	return proof, nil
+}
+
+// Verify verifies a proof that the commitment is one of the data leaves. Only
+// the root is derived from the data; the proof is checked against it alone.
+func (v *VerkleProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	root, err := VerkleRoot(data)
+	if err != nil {
+		return false
+	}
+	return VerifyVerkleInclusion(root, len(data), commitment, proof)
+}
+
+// VerkleRoot returns the root commitment of the Verkle tree over data
+func VerkleRoot(data [][]byte) (kzg4844.Commitment, error) {
+	if len(data) == 0 {
+		return kzg4844.Commitment{}, errors.New("no data")
+	}
+	tree, err := NewVerkleProofSystem().buildTree(data)
+	if err != nil {
+		return kzg4844.Commitment{}, err
+	}
+	return tree[len(tree)-1][0].commitment, nil
+}
+
+// verkleDepth returns the number of levels of a Verkle tree over n leaves
+func verkleDepth(n int) int {
+	depth := 1
+	for n > verkleWidth {
+		n = (n + verkleWidth - 1) / verkleWidth
+		depth++
+	}
+	return depth
+}
+
+// VerifyVerkleInclusion verifies a proof that the commitment is a leaf of the
+// Verkle tree with the given root and number of leaves. Each level's opening
+// shows that its node holds the element of the level below, starting from the
+// commitment, and the top node must be the root.
+func VerifyVerkleInclusion(root kzg4844.Commitment, leaves int, commitment []byte, proof []byte) bool {
+	depth := verkleDepth(leaves)
+	if leaves <= 0 || depth > verkleMaxDepth || len(proof) != 4+depth*verkleLevelSize {
+		return false
+	}
+	leafIndex := binary.BigEndian.Uint32(proof[:4])
+	if uint64(leafIndex) >= uint64(leaves) {
+		return false
+	}
+
+	index := int(leafIndex)
+	element := verkleElement(commitment)
+	var nodeCommitment kzg4844.Commitment
+	for level := 0; level < depth; level++ {
+		offset := 4 + level*verkleLevelSize
+		var opening kzg4844.Proof
+		copy(nodeCommitment[:], proof[offset:])
+		copy(opening[:], proof[offset+len(nodeCommitment):])
+
+		if kzg4844.VerifyProof(nodeCommitment, verklePoint(index%verkleWidth), kzg4844.Claim(element), opening) != nil {
+			return false
+		}
+		element = verkleElement(nodeCommitment[:])
+		index /= verkleWidth
+	}
+	return constantTimeEqual(nodeCommitment[:], root[:])
+}
+
+// buildTree builds a Verkle tree over data, returning its levels of nodes from
+// the leaf level up to the root
+func (v *VerkleProofSystem) buildTree(data [][]byte) ([][]verkleNode, error) {
+	elements := make([][32]byte, len(data))
+	for i, d := range data {
+		elements[i] = verkleElement(d)
+	}
+
+	var tree [][]verkleNode
+	for {
+		if len(tree) == verkleMaxDepth {
+			return nil, errors.New("too many leaves")
+		}
+		level := make([]verkleNode, (len(elements)+verkleWidth-1)/verkleWidth)
+		for i := range level {
+			blob := new(kzg4844.Blob)
+			for j := 0; j < verkleWidth && i*verkleWidth+j < len(elements); j++ {
+				copy(blob[j*32:], elements[i*verkleWidth+j][:])
+			}
+			commitment, err := kzg4844.BlobToCommitment(blob)
+			if err != nil {
+				return nil, err
+			}
+			level[i] = verkleNode{blob: blob, commitment: commitment}
+		}
+		tree = append(tree, level)
+		if len(level) == 1 {
+			return tree, nil
+		}
+
+		elements = make([][32]byte, len(level))
+		for i, node := range level {
+			elements[i] = verkleElement(node.commitment[:])
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package p2s
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidVRFProof is returned when a VRF proof does not verify
+var ErrInvalidVRFProof = errors.New("invalid VRF proof")
+
+// vrfProofLength is the size of an encoded VRF proof: the prover's compressed
+// public key, the compressed point Gamma and the scalars c and s
+const vrfProofLength = 33 + 33 + 32 + 32
+
+// VRFProve evaluates the verifiable random function of key on alpha. The output
+// is unique for a key and input, so the prover cannot grind it, and the proof
+// lets anyone check it against the prover's public key.
+//
+// The construction follows ECVRF over secp256k1: Gamma = sk·H(pk, alpha) with a
+// Chaum-Pedersen proof that log_G(pk) = log_H(Gamma).
+func VRFProve(key *ecdsa.PrivateKey, alpha []byte) (output []byte, proof []byte, err error) {
+	curve := crypto.S256()
+	n := curve.Params().N
+	pub := crypto.CompressPubkey(&key.PublicKey)
+
+	hx, hy, err := vrfHashToCurve(pub, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	sk := common.LeftPadBytes(key.D.Bytes(), 32)
+	gamma := compressPoint(curve.ScalarMult(hx, hy, sk))
+
+	k, err := randomScalar(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	u := scalarBasePoint(k)
+	v := compressPoint(curve.ScalarMult(hx, hy, common.LeftPadBytes(k.Bytes(), 32)))
+	c := vrfChallenge(n, pub, compressPoint(hx, hy), gamma, u, v)
+
+	// s = k - c·sk mod n
+	s := new(big.Int).Mul(c, key.D)
+	s.Sub(k, s)
+	s.Mod(s, n)
+
+	proof = make([]byte, 0, vrfProofLength)
+	proof = append(proof, pub...)
+	proof = append(proof, gamma...)
+	proof = append(proof, common.LeftPadBytes(c.Bytes(), 32)...)
+	proof = append(proof, common.LeftPadBytes(s.Bytes(), 32)...)
+	return vrfOutput(gamma), proof, nil
+}
+
+// VRFVerify checks a VRF proof on alpha and returns the output together with the
+// public key of the prover
+func VRFVerify(proof []byte, alpha []byte) ([]byte, *ecdsa.PublicKey, error) {
+	if len(proof) != vrfProofLength {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	curve := crypto.S256()
+	n := curve.Params().N
+	pub, gamma := proof[:33], proof[33:66]
+	c, s := new(big.Int).SetBytes(proof[66:98]), new(big.Int).SetBytes(proof[98:])
+	if c.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	key, err := crypto.DecompressPubkey(pub)
+	if err != nil {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	gx, gy, err := decompressPoint(gamma)
+	if err != nil {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	hx, hy, err := vrfHashToCurve(pub, alpha)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// U = s·G + c·pk and V = s·H + c·Gamma reproduce k·G and k·H for an honest proof
+	sBytes, cBytes := common.LeftPadBytes(s.Bytes(), 32), common.LeftPadBytes(c.Bytes(), 32)
+	sgx, sgy := curve.ScalarBaseMult(sBytes)
+	cpx, cpy := curve.ScalarMult(key.X, key.Y, cBytes)
+	shx, shy := curve.ScalarMult(hx, hy, sBytes)
+	cgx, cgy := curve.ScalarMult(gx, gy, cBytes)
+	ux, uy := addPoints(sgx, sgy, cpx, cpy)
+	vx, vy := addPoints(shx, shy, cgx, cgy)
+	if ux == nil || vx == nil {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	expected := vrfChallenge(n, pub, compressPoint(hx, hy), gamma, compressPoint(ux, uy), compressPoint(vx, vy))
+	if expected.Cmp(c) != 0 {
+		return nil, nil, ErrInvalidVRFProof
+	}
+	return vrfOutput(gamma), key, nil
+}
+
+// addPoints returns A + B, or nils when the result is the point at infinity
+func addPoints(ax, ay, bx, by *big.Int) (*big.Int, *big.Int) {
+	curve := crypto.S256()
+	if ax.Cmp(bx) == 0 {
+		if ay.Cmp(by) != 0 {
+			return nil, nil
+		}
+		return curve.Double(ax, ay)
+	}
+	return curve.Add(ax, ay, bx, by)
+}
+
+// vrfHashToCurve maps a public key and input to a curve point by try-and-increment
+func vrfHashToCurve(pub, alpha []byte) (*big.Int, *big.Int, error) {
+	p := crypto.S256().Params().P
+	for ctr := 0; ctr < 256; ctr++ {
+		x := new(big.Int).SetBytes(crypto.Keccak256([]byte("p2s-vrf-h2c"), pub, alpha, []byte{byte(ctr)}))
+		if x.Cmp(p) >= 0 {
+			continue
+		}
+		if hx, hy, err := decompressPoint(append([]byte{0x02}, common.LeftPadBytes(x.Bytes(), 32)...)); err == nil {
+			return hx, hy, nil
+		}
+	}
+	return nil, nil, errors.New("failed to hash VRF input to curve")
+}
+
+// vrfChallenge derives the Fiat-Shamir challenge of a VRF proof
+func vrfChallenge(n *big.Int, pub, h, gamma, u, v []byte) *big.Int {
+	c := new(big.Int).SetBytes(crypto.Keccak256([]byte("p2s-vrf-challenge"), pub, h, gamma, u, v))
+	return c.Mod(c, n)
+}
+
+// vrfOutput derives the VRF output from Gamma
+func vrfOutput(gamma []byte) []byte {
+	return crypto.Keccak256([]byte("p2s-vrf-output"), gamma)
+}
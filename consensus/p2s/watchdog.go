@@ -0,0 +1,307 @@
+package p2s
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maxWatchdogErrors is the number of recent errors retained for diagnostics
+const maxWatchdogErrors = 16
+
+// maxRecoveryBackoff caps the slots a recovery action waits before it is
+// attempted again while pairing stays stalled
+const maxRecoveryBackoff = 64
+
+// WatchdogHooks connects the watchdog to node subsystems for diagnostics and recovery
+type WatchdogHooks struct {
+	// Diagnostics
+	PoolSizes func() (phts int, mts int)
+	PeerCount func() int
+
+	// Recovery actions, attempted in order
+	RebuildFromPool func() error
+	RequestReveals  func() error
+	RotateProposer  func() error
+
+	// Alerting
+	Alert func(report *StallReport)
+}
+
+// StallReport contains diagnostics collected when pairing stalls
+type StallReport struct {
+	Slot        uint64            `json:"slot"`
+	LastB1Slot  uint64            `json:"lastB1Slot"` // Slot the B1 height last advanced at
+	LastB2Slot  uint64            `json:"lastB2Slot"` // Slot the B2 height last advanced at
+	B1Height    uint64            `json:"b1Height"`
+	B2Height    uint64            `json:"b2Height"`
+	StalledB1   bool              `json:"stalledB1"`
+	StalledB2   bool              `json:"stalledB2"`
+	PendingPHTs int               `json:"pendingPHTs"`
+	PendingMTs  int               `json:"pendingMTs"`
+	Peers       int               `json:"peers"`
+	LastErrors  []string          `json:"lastErrors"`
+	Recovery    map[string]string `json:"recovery"` // Recovery action to outcome, "backoff" if not yet due
+	Timestamp   uint64            `json:"timestamp"`
+}
+
+// recoveryBackoff tracks how often a recovery action ran during a stall and the
+// slot it is due again
+type recoveryBackoff struct {
+	attempts uint
+	next     uint64
+}
+
+// Watchdog monitors slot progression and recovers when B1 or B2 production stalls.
+// Blocks are recorded by height and progress is checked per slot: the slot a
+// height last advanced at is noted by the next check, so the two clocks never
+// have to agree. The first check after starting only sets this baseline.
+type Watchdog struct {
+	maxStalledSlots uint64
+	hooks           *WatchdogHooks
+
+	b1Height   uint64 // Highest B1 and B2 block heights recorded
+	b2Height   uint64
+	seenB1     uint64 // Heights the last check saw
+	seenB2     uint64
+	lastB1Slot uint64
+	lastB2Slot uint64
+	started    bool // Whether a check set the slot baseline yet
+	backoff    map[string]*recoveryBackoff
+	lastErrors []string
+	lastReport *StallReport
+
+	quit chan struct{}
+	mu   sync.Mutex
+}
+
+// NewWatchdog creates a watchdog that fires after maxStalledSlots slots without progress
+func NewWatchdog(maxStalledSlots uint64, hooks *WatchdogHooks) *Watchdog {
+	if hooks == nil {
+		hooks = &WatchdogHooks{}
+	}
+
+	return &Watchdog{
+		maxStalledSlots: maxStalledSlots,
+		hooks:           hooks,
+		backoff:         make(map[string]*recoveryBackoff),
+		lastErrors:      make([]string, 0, maxWatchdogErrors),
+	}
+}
+
+// SetHooks replaces the diagnostics and recovery hooks, keeping recorded progress
+func (w *Watchdog) SetHooks(hooks *WatchdogHooks) {
+	if hooks == nil {
+		hooks = &WatchdogHooks{}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.hooks = hooks
+}
+
+// RecordB1 records that a B1 block of the given height was produced
+func (w *Watchdog) RecordB1(number uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if number > w.b1Height {
+		w.b1Height = number
+	}
+}
+
+// RecordB2 records that a B2 block of the given height was produced
+func (w *Watchdog) RecordB2(number uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if number > w.b2Height {
+		w.b2Height = number
+	}
+}
+
+// RecordError records an error for inclusion in stall diagnostics
+func (w *Watchdog) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.lastErrors) == maxWatchdogErrors {
+		w.lastErrors = w.lastErrors[1:]
+	}
+	w.lastErrors = append(w.lastErrors, err.Error())
+}
+
+// Check evaluates progress at the given slot and runs recovery if pairing has stalled.
+// It returns the stall report, or nil if blocks are being produced.
+func (w *Watchdog) Check(slot uint64) *StallReport {
+	w.mu.Lock()
+	if !w.started || slot < w.lastB1Slot || slot < w.lastB2Slot {
+		w.started = true
+		w.lastB1Slot, w.lastB2Slot = slot, slot
+	}
+	if w.b1Height > w.seenB1 {
+		w.seenB1, w.lastB1Slot = w.b1Height, slot
+	}
+	if w.b2Height > w.seenB2 {
+		w.seenB2, w.lastB2Slot = w.b2Height, slot
+	}
+	stalledB1 := slot > w.lastB1Slot+w.maxStalledSlots
+	stalledB2 := slot > w.lastB2Slot+w.maxStalledSlots
+	if !stalledB1 && !stalledB2 {
+		w.backoff = make(map[string]*recoveryBackoff)
+		w.mu.Unlock()
+		return nil
+	}
+
+	report := &StallReport{
+		Slot:       slot,
+		LastB1Slot: w.lastB1Slot,
+		LastB2Slot: w.lastB2Slot,
+		B1Height:   w.b1Height,
+		B2Height:   w.b2Height,
+		StalledB1:  stalledB1,
+		StalledB2:  stalledB2,
+		LastErrors: append([]string(nil), w.lastErrors...),
+		Recovery:   make(map[string]string),
+		Timestamp:  uint64(time.Now().Unix()),
+	}
+	hooks := w.hooks
+
+	// Recovery actions repeated during a stall back off exponentially, and
+	// start over once the block type they recover progresses again
+	if !stalledB1 {
+		delete(w.backoff, "rebuild_from_pool")
+	}
+	if !stalledB2 {
+		delete(w.backoff, "request_reveals")
+	}
+	due := map[string]bool{
+		"rebuild_from_pool": stalledB1 && w.due("rebuild_from_pool", slot),
+		"request_reveals":   stalledB2 && w.due("request_reveals", slot),
+		"rotate_proposer":   w.due("rotate_proposer", slot),
+	}
+	w.mu.Unlock()
+
+	// Gather diagnostics
+	if hooks.PoolSizes != nil {
+		report.PendingPHTs, report.PendingMTs = hooks.PoolSizes()
+	}
+	if hooks.PeerCount != nil {
+		report.Peers = hooks.PeerCount()
+	}
+
+	// Attempt recovery
+	if stalledB1 {
+		w.recover(report, due["rebuild_from_pool"], "rebuild_from_pool", hooks.RebuildFromPool)
+	}
+	if stalledB2 {
+		w.recover(report, due["request_reveals"], "request_reveals", hooks.RequestReveals)
+	}
+	w.recover(report, due["rotate_proposer"], "rotate_proposer", hooks.RotateProposer)
+
+	log.Warn("P2S pairing stalled", "slot", slot, "lastB1", report.LastB1Slot, "lastB2", report.LastB2Slot,
+		"phts", report.PendingPHTs, "mts", report.PendingMTs, "peers", report.Peers)
+
+	if hooks.Alert != nil {
+		hooks.Alert(report)
+	}
+
+	w.mu.Lock()
+	w.lastReport = report
+	w.mu.Unlock()
+
+	return report
+}
+
+// due reports whether a recovery action may run at the given slot, scheduling
+// its next attempt 1, 2, 4, ... slots later up to maxRecoveryBackoff. The caller
+// must hold the lock.
+func (w *Watchdog) due(name string, slot uint64) bool {
+	backoff, exists := w.backoff[name]
+	if !exists {
+		backoff = new(recoveryBackoff)
+		w.backoff[name] = backoff
+	}
+	if slot < backoff.next {
+		return false
+	}
+	delay := uint64(maxRecoveryBackoff)
+	if backoff.attempts < 6 {
+		delay = 1 << backoff.attempts
+	}
+	backoff.attempts++
+	backoff.next = slot + delay
+	return true
+}
+
+// recover runs a single recovery action if it is due and records its outcome
+func (w *Watchdog) recover(report *StallReport, due bool, name string, action func() error) {
+	if action == nil {
+		report.Recovery[name] = "unavailable"
+		return
+	}
+	if !due {
+		report.Recovery[name] = "backoff"
+		return
+	}
+
+	if err := action(); err != nil {
+		report.Recovery[name] = "failed: " + err.Error()
+		return
+	}
+	report.Recovery[name] = "ok"
+}
+
+// LastReport returns the most recent stall report
+func (w *Watchdog) LastReport() *StallReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.lastReport
+}
+
+// Start runs the watchdog in the background, checking once per slot. The slot
+// baseline is set again by the first check.
+func (w *Watchdog) Start(slotDuration time.Duration, currentSlot func() uint64) {
+	w.mu.Lock()
+	if w.quit != nil {
+		w.mu.Unlock()
+		return
+	}
+	w.started = false
+	w.backoff = make(map[string]*recoveryBackoff)
+	w.quit = make(chan struct{})
+	quit := w.quit
+	w.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(slotDuration)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.Check(currentSlot())
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background watchdog
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.quit != nil {
+		close(w.quit)
+		w.quit = nil
+	}
+}
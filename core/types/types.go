@@ -26,17 +26,29 @@ type PHTTransaction struct {
 	Nonce      []byte        `json:"nonce"`
 	Timestamp  uint64        `json:"timestamp"`
 	
+	// Vector commitment with one position per hidden field for selective reveal
+	FieldCommitment []byte `json:"fieldCommitment"`
+	
 	// Hidden fields (committed but not revealed until B2)
 	Recipient common.Address `json:"recipient"`
 	Value     *big.Int      `json:"value"`
 	CallData  []byte        `json:"callData"`
 	TxType    uint8         `json:"txType"`
 	GasLimit  uint64        `json:"gasLimit"`
+	FieldSalts [][]byte     `json:"fieldSalts"` // Blinding salts for the vector commitment
 	
 	// Transaction hash
 	TxHash common.Hash `json:"txHash"`
 }
 
+// FieldOpening opens a single hidden field position of a PHT vector commitment
+type FieldOpening struct {
+	Index int      `json:"index"`
+	Value []byte   `json:"value"`
+	Salt  []byte   `json:"salt"`
+	Path  [][]byte `json:"path"`
+}
+
 // MTTransaction represents a Matching Transaction
 type MTTransaction struct {
 	// Revealed fields (included in B2 block)
@@ -51,6 +63,9 @@ type MTTransaction struct {
 	Proof     []byte      `json:"proof"`
 	Timestamp uint64      `json:"timestamp"`
 	
+	// Per-field openings of the PHT vector commitment
+	FieldOpenings []*FieldOpening `json:"fieldOpenings,omitempty"`
+	
 	// Transaction hash
 	TxHash common.Hash `json:"txHash"`
 }
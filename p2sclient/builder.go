@@ -0,0 +1,149 @@
+package p2sclient
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// ErrUnknownPHT is returned for PHTs the builder did not create or already forgot
+	ErrUnknownPHT = errors.New("unknown PHT")
+
+	// ErrMissingChainID is returned when the network configuration has no chain ID
+	ErrMissingChainID = errors.New("network configuration has no chain ID")
+)
+
+// Builder constructs PHTs for one account entirely on the client: it signs the
+// transaction, commits to its hidden fields, proves the anti-MEV nonce with the
+// account key and keeps the commitment opening until the reveal. Only the visible
+// fields leave the client before B2, so the node never sees the plaintext first.
+type Builder struct {
+	key     *ecdsa.PrivateKey
+	signer  types.Signer
+	phts    *p2s.PHTManager
+	mts     *p2s.MTManager
+	pending map[common.Hash]*p2s.PHTTransaction // Full PHTs by transaction hash
+	mu      sync.Mutex
+}
+
+// NewBuilder creates a builder for the owner of key. The configuration must match
+// the network's, in particular its chain ID, hidden field set, commitment scheme
+// and PHT value cap.
+func NewBuilder(key *ecdsa.PrivateKey, config *p2s.P2SConfig) (*Builder, error) {
+	if config == nil || config.ChainID == nil {
+		return nil, ErrMissingChainID
+	}
+
+	phts, err := p2s.NewPHTManager(config)
+	if err != nil {
+		return nil, err
+	}
+	mts, err := p2s.NewMTManager(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{
+		key:     key,
+		signer:  types.LatestSignerForChainID(config.ChainID),
+		phts:    phts,
+		mts:     mts,
+		pending: make(map[common.Hash]*p2s.PHTTransaction),
+	}, nil
+}
+
+// Address returns the account PHTs are built for
+func (b *Builder) Address() common.Address {
+	return crypto.PubkeyToAddress(b.key.PublicKey)
+}
+
+// SetBlockContext sets the parent of the B1 block the next PHTs target, which
+// their anti-MEV nonces are derived over
+func (b *Builder) SetBlockContext(parent common.Hash) {
+	b.phts.SetNonceContext(parent)
+}
+
+// Build signs a transaction and converts it to a PHT. It returns the PHT stripped
+// of its hidden fields together with its RLP encoding for submission; the full
+// PHT and its commitment opening stay with the builder for the reveal.
+func (b *Builder) Build(tx *types.Transaction) (*p2s.PHTTransaction, []byte, error) {
+	signed, err := types.SignTx(tx, b.signer, b.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	pht, err := b.phts.CreatePHTWithKey(signed, b.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	public := pht.WithoutHiddenFields()
+	encoded, err := public.Serialize()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b.mu.Lock()
+	b.pending[pht.TxHash] = pht
+	b.mu.Unlock()
+
+	return public, encoded, nil
+}
+
+// Opening returns the commitment opening of a built PHT
+func (b *Builder) Opening(txHash common.Hash) (*p2s.CommitmentOpening, error) {
+	pht, err := b.pendingPHT(txHash)
+	if err != nil {
+		return nil, err
+	}
+	return b.phts.Opening(pht.Commitment)
+}
+
+// Reveal creates the MT of a built PHT once it is included in a B1 block, carrying
+// the stored commitment opening
+func (b *Builder) Reveal(txHash common.Hash) (*p2s.MTTransaction, error) {
+	pht, err := b.pendingPHT(txHash)
+	if err != nil {
+		return nil, err
+	}
+	opening, err := b.phts.Opening(pht.Commitment)
+	if err != nil {
+		return nil, err
+	}
+	mt, err := b.mts.CreateMT(pht)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.mts.AttachOpening(mt, pht, opening); err != nil {
+		return nil, err
+	}
+	return mt, nil
+}
+
+// Forget drops a PHT and its opening after its MT was included in a B2 block
+func (b *Builder) Forget(txHash common.Hash) {
+	b.mu.Lock()
+	pht, exists := b.pending[txHash]
+	delete(b.pending, txHash)
+	b.mu.Unlock()
+
+	if exists {
+		b.phts.ForgetOpening(pht.Commitment)
+	}
+}
+
+// pendingPHT returns a full PHT built by this builder
+func (b *Builder) pendingPHT(txHash common.Hash) (*p2s.PHTTransaction, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pht, exists := b.pending[txHash]
+	if !exists {
+		return nil, ErrUnknownPHT
+	}
+	return pht, nil
+}
@@ -0,0 +1,223 @@
+// Package p2sclient provides client-side helpers for submitting P2S transactions
+package p2sclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+)
+
+var (
+	// ErrNoRevealTransport is returned when neither the strategy nor the revealer
+	// provides a transport
+	ErrNoRevealTransport = errors.New("no reveal transport configured")
+
+	// ErrRevealDeadline is returned when the reveal deadline passes before the MT
+	// could be submitted
+	ErrRevealDeadline = errors.New("reveal deadline passed")
+)
+
+// RevealTransport delivers an MT to the network
+type RevealTransport interface {
+	SendReveal(ctx context.Context, mt *p2s.MTTransaction, fee *big.Int) error
+}
+
+// RevealStrategy decides when and through which transport an MT is revealed
+type RevealStrategy interface {
+	Name() string
+	RevealAt(inclusion time.Time, deadline time.Time) time.Time
+	Transport(fallback RevealTransport) RevealTransport
+}
+
+// ImmediateReveal reveals the MT as soon as the PHT is included in a B1 block
+type ImmediateReveal struct{}
+
+// Name returns the strategy name
+func (ImmediateReveal) Name() string { return "immediate" }
+
+// RevealAt returns the B1 inclusion time
+func (ImmediateReveal) RevealAt(inclusion time.Time, deadline time.Time) time.Time {
+	return inclusion
+}
+
+// Transport returns the default transport
+func (ImmediateReveal) Transport(fallback RevealTransport) RevealTransport { return fallback }
+
+// DeadlineReveal reveals the MT a fixed delta before the reveal deadline
+type DeadlineReveal struct {
+	Delta time.Duration
+}
+
+// Name returns the strategy name
+func (d DeadlineReveal) Name() string { return "deadline" }
+
+// RevealAt returns the deadline minus delta, never earlier than the inclusion time
+func (d DeadlineReveal) RevealAt(inclusion time.Time, deadline time.Time) time.Time {
+	at := deadline.Add(-d.Delta)
+	if at.Before(inclusion) {
+		return inclusion
+	}
+	return at
+}
+
+// Transport returns the default transport
+func (d DeadlineReveal) Transport(fallback RevealTransport) RevealTransport { return fallback }
+
+// RelayReveal delegates the reveal to a relay immediately after inclusion
+type RelayReveal struct {
+	Relay RevealTransport
+}
+
+// Name returns the strategy name
+func (r RelayReveal) Name() string { return "relay" }
+
+// RevealAt returns the B1 inclusion time
+func (r RelayReveal) RevealAt(inclusion time.Time, deadline time.Time) time.Time {
+	return inclusion
+}
+
+// Transport returns the relay transport, falling back to the default one if unset
+func (r RelayReveal) Transport(fallback RevealTransport) RevealTransport {
+	if r.Relay == nil {
+		return fallback
+	}
+	return r.Relay
+}
+
+// RevealConfig contains reveal scheduling, retry and fee bumping parameters
+type RevealConfig struct {
+	Strategy RevealStrategy
+
+	// Retry configuration
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Fee bumping for the reveal path
+	InitialFee     *big.Int
+	FeeBumpPercent int64
+	MinFeeBump     *big.Int // Minimum increase per bump, so low fees make progress; nil bumps by at least 1 wei
+	MaxFee         *big.Int
+}
+
+// DefaultRevealConfig returns default reveal configuration
+func DefaultRevealConfig() *RevealConfig {
+	return &RevealConfig{
+		Strategy:       ImmediateReveal{},
+		MaxRetries:     5,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     4 * time.Second,
+		InitialFee:     big.NewInt(0),
+		FeeBumpPercent: 10,
+		MinFeeBump:     big.NewInt(100000000),   // 0.1 gwei
+		MaxFee:         big.NewInt(10000000000), // 10 gwei
+	}
+}
+
+// Revealer submits MTs according to a reveal strategy
+type Revealer struct {
+	transport RevealTransport
+	config    *RevealConfig
+	now       func() time.Time
+}
+
+// NewRevealer creates a new revealer using the given default transport
+func NewRevealer(transport RevealTransport, config *RevealConfig) *Revealer {
+	if config == nil {
+		config = DefaultRevealConfig()
+	}
+	// Copy the config so defaults never leak into the caller's
+	copied := *config
+	config = &copied
+	if config.Strategy == nil {
+		config.Strategy = ImmediateReveal{}
+	}
+
+	return &Revealer{
+		transport: transport,
+		config:    config,
+		now:       time.Now,
+	}
+}
+
+// Reveal waits until the strategy's reveal time and submits the MT, retrying
+// with exponential backoff and bumping the reveal fee on each attempt
+func (r *Revealer) Reveal(ctx context.Context, mt *p2s.MTTransaction, inclusion time.Time, deadline time.Time) error {
+	transport := r.config.Strategy.Transport(r.transport)
+	if transport == nil {
+		return ErrNoRevealTransport
+	}
+
+	// Wait for the scheduled reveal time
+	revealAt := r.config.Strategy.RevealAt(inclusion, deadline)
+	if wait := revealAt.Sub(r.now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	fee := new(big.Int)
+	if r.config.InitialFee != nil {
+		fee.Set(r.config.InitialFee)
+	}
+	backoff := r.config.InitialBackoff
+
+	var lastErr error
+	for attempt := 0; attempt <= r.config.MaxRetries; attempt++ {
+		if !deadline.IsZero() && r.now().After(deadline) {
+			return ErrRevealDeadline
+		}
+
+		if lastErr = transport.SendReveal(ctx, mt, new(big.Int).Set(fee)); lastErr == nil {
+			return nil
+		}
+		if attempt == r.config.MaxRetries {
+			break
+		}
+
+		// Back off before the next attempt
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if r.config.MaxBackoff > 0 && backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+		fee = r.bumpFee(fee)
+	}
+
+	return lastErr
+}
+
+// bumpFee increases the reveal fee by the configured percentage, at least by
+// MinFeeBump, capped at MaxFee
+func (r *Revealer) bumpFee(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+r.config.FeeBumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+
+	// Percentages of zero or low fees barely move them
+	minimum := big.NewInt(1)
+	if r.config.MinFeeBump != nil && r.config.MinFeeBump.Sign() > 0 {
+		minimum.Set(r.config.MinFeeBump)
+	}
+	minimum.Add(minimum, fee)
+	if bumped.Cmp(minimum) < 0 {
+		bumped.Set(minimum)
+	}
+
+	if r.config.MaxFee != nil && bumped.Cmp(r.config.MaxFee) > 0 {
+		bumped.Set(r.config.MaxFee)
+	}
+
+	return bumped
+}
@@ -0,0 +1,65 @@
+package p2stestnet
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// Bootnode describes a bootnode identity and its advertised endpoint
+type Bootnode struct {
+	Key  *ecdsa.PrivateKey
+	IP   net.IP
+	Port int
+}
+
+// GenerateBootnode generates a new bootnode key for the given endpoint
+func GenerateBootnode(ip net.IP, port int) (*Bootnode, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bootnode{
+		Key:  key,
+		IP:   ip,
+		Port: port,
+	}, nil
+}
+
+// LoadBootnode loads a bootnode key from a hex-encoded key file
+func LoadBootnode(path string, ip net.IP, port int) (*Bootnode, error) {
+	key, err := crypto.LoadECDSA(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bootnode key: %v", err)
+	}
+
+	return &Bootnode{
+		Key:  key,
+		IP:   ip,
+		Port: port,
+	}, nil
+}
+
+// SaveKey writes the bootnode key to a hex-encoded key file, as used by geth's --nodekey
+func (b *Bootnode) SaveKey(path string) error {
+	return crypto.SaveECDSA(path, b.Key)
+}
+
+// Node returns the bootnode's enode record
+func (b *Bootnode) Node() *enode.Node {
+	return enode.NewV4(&b.Key.PublicKey, b.IP, b.Port, b.Port)
+}
+
+// URL returns the bootnode's enode URL
+func (b *Bootnode) URL() string {
+	return b.Node().URLv4()
+}
+
+// ID returns the bootnode's node ID
+func (b *Bootnode) ID() enode.ID {
+	return b.Node().ID()
+}
@@ -0,0 +1,262 @@
+// Package p2stestnet provides utilities for standing up public P2S test networks
+package p2stestnet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// ErrRateLimited is returned when an address or client requests funds too often
+	ErrRateLimited = errors.New("faucet request rate limited")
+
+	// ErrUnauthorized is returned when a request fails the API key or captcha check
+	ErrUnauthorized = errors.New("faucet request not authorized")
+)
+
+// FaucetBackend sends funds from the faucet account
+type FaucetBackend interface {
+	SendFunds(ctx context.Context, to common.Address, amount *big.Int) (common.Hash, error)
+}
+
+// RequestVerifier gates faucet requests, e.g. by API key or captcha
+type RequestVerifier interface {
+	Verify(r *http.Request) error
+}
+
+// APIKeyVerifier accepts requests carrying one of a set of API keys in the X-API-Key header
+type APIKeyVerifier struct {
+	Keys []string
+}
+
+// Verify checks the request API key
+func (v *APIKeyVerifier) Verify(r *http.Request) error {
+	key := r.Header.Get("X-API-Key")
+	for _, valid := range v.Keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// CaptchaVerifier accepts requests whose captcha token is confirmed by an external provider
+type CaptchaVerifier struct {
+	Check func(ctx context.Context, token string, remoteIP string) (bool, error)
+}
+
+// Verify checks the request captcha token
+func (v *CaptchaVerifier) Verify(r *http.Request) error {
+	token := r.Header.Get("X-Captcha-Token")
+	if token == "" {
+		return ErrUnauthorized
+	}
+
+	ok, err := v.Check(r.Context(), token, clientIP(r))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// FaucetConfig contains faucet configuration
+type FaucetConfig struct {
+	Amount   *big.Int      // Wei sent per request
+	Cooldown time.Duration // Minimum time between requests per address and per client IP
+}
+
+// DefaultFaucetConfig returns default faucet configuration
+func DefaultFaucetConfig() *FaucetConfig {
+	return &FaucetConfig{
+		Amount:   new(big.Int).Mul(big.NewInt(10), big.NewInt(1000000000000000000)), // 10 ETH
+		Cooldown: 24 * time.Hour,
+	}
+}
+
+// Faucet is a rate-limited HTTP service that funds test accounts
+type Faucet struct {
+	backend  FaucetBackend
+	verifier RequestVerifier
+	config   *FaucetConfig
+
+	lastByAddress map[common.Address]time.Time
+	lastByIP      map[string]time.Time
+	mu            sync.Mutex
+}
+
+// NewFaucet creates a new faucet. A nil verifier accepts all requests.
+func NewFaucet(backend FaucetBackend, verifier RequestVerifier, config *FaucetConfig) *Faucet {
+	if config == nil {
+		config = DefaultFaucetConfig()
+	}
+
+	return &Faucet{
+		backend:       backend,
+		verifier:      verifier,
+		config:        config,
+		lastByAddress: make(map[common.Address]time.Time),
+		lastByIP:      make(map[string]time.Time),
+	}
+}
+
+// faucetRequest is the JSON body of a funding request
+type faucetRequest struct {
+	Address common.Address `json:"address"`
+}
+
+// faucetResponse is the JSON body of a funding response
+type faucetResponse struct {
+	TxHash common.Hash `json:"txHash,omitempty"`
+	Amount *big.Int    `json:"amount,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ServeHTTP handles POST requests of the form {"address": "0x..."}
+func (f *Faucet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeFaucetResponse(w, http.StatusMethodNotAllowed, &faucetResponse{Error: "POST required"})
+		return
+	}
+
+	if f.verifier != nil {
+		if err := f.verifier.Verify(r); err != nil {
+			writeFaucetResponse(w, http.StatusUnauthorized, &faucetResponse{Error: err.Error()})
+			return
+		}
+	}
+
+	var req faucetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		writeFaucetResponse(w, http.StatusBadRequest, &faucetResponse{Error: "invalid request body"})
+		return
+	}
+	if req.Address == (common.Address{}) {
+		writeFaucetResponse(w, http.StatusBadRequest, &faucetResponse{Error: "address required"})
+		return
+	}
+
+	hash, err := f.Fund(r.Context(), req.Address, clientIP(r))
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		writeFaucetResponse(w, http.StatusTooManyRequests, &faucetResponse{Error: err.Error()})
+	case err != nil:
+		writeFaucetResponse(w, http.StatusInternalServerError, &faucetResponse{Error: err.Error()})
+	default:
+		writeFaucetResponse(w, http.StatusOK, &faucetResponse{TxHash: hash, Amount: f.config.Amount})
+	}
+}
+
+// Fund sends the configured amount to an address, enforcing per-address and per-IP cooldowns
+func (f *Faucet) Fund(ctx context.Context, to common.Address, ip string) (common.Hash, error) {
+	f.mu.Lock()
+	now := time.Now()
+	if last, ok := f.lastByAddress[to]; ok && now.Sub(last) < f.config.Cooldown {
+		f.mu.Unlock()
+		return common.Hash{}, ErrRateLimited
+	}
+	if last, ok := f.lastByIP[ip]; ok && ip != "" && now.Sub(last) < f.config.Cooldown {
+		f.mu.Unlock()
+		return common.Hash{}, ErrRateLimited
+	}
+	// Reserve the slot before sending so concurrent requests cannot double-fund
+	f.lastByAddress[to] = now
+	if ip != "" {
+		f.lastByIP[ip] = now
+	}
+	f.mu.Unlock()
+
+	hash, err := f.backend.SendFunds(ctx, to, f.config.Amount)
+	if err != nil {
+		f.mu.Lock()
+		delete(f.lastByAddress, to)
+		delete(f.lastByIP, ip)
+		f.mu.Unlock()
+		return common.Hash{}, err
+	}
+
+	log.Info("Faucet funded account", "to", to, "amount", f.config.Amount, "tx", hash)
+	return hash, nil
+}
+
+// writeFaucetResponse writes a JSON response
+func writeFaucetResponse(w http.ResponseWriter, status int, resp *faucetResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// clientIP returns the remote IP of a request
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RPCFaucetBackend sends faucet funds through a node's JSON-RPC endpoint
+type RPCFaucetBackend struct {
+	client  *ethclient.Client
+	key     *ecdsa.PrivateKey
+	chainID *big.Int
+	mu      sync.Mutex // Serializes nonce assignment
+}
+
+// NewRPCFaucetBackend creates a faucet backend signing with key
+func NewRPCFaucetBackend(client *ethclient.Client, key *ecdsa.PrivateKey, chainID *big.Int) *RPCFaucetBackend {
+	return &RPCFaucetBackend{
+		client:  client,
+		key:     key,
+		chainID: chainID,
+	}
+}
+
+// SendFunds signs and submits a value transfer
+func (b *RPCFaucetBackend) SendFunds(ctx context.Context, to common.Address, amount *big.Int) (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	from := crypto.PubkeyToAddress(b.key.PublicKey)
+	nonce, err := b.client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	gasPrice, err := b.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	tx := types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    amount,
+		Gas:      21000,
+		GasPrice: gasPrice,
+	})
+	signed, err := types.SignTx(tx, types.LatestSignerForChainID(b.chainID), b.key)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.client.SendTransaction(ctx, signed); err != nil {
+		return common.Hash{}, err
+	}
+
+	return signed.Hash(), nil
+}
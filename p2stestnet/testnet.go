@@ -0,0 +1,188 @@
+package p2stestnet
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestnetConfig describes a P2S test network to generate
+type TestnetConfig struct {
+	ChainID        uint64
+	Validators     int
+	Bootnodes      int
+	BootnodeIP     net.IP
+	BootnodePort   int // Port of the first bootnode, incremented per bootnode
+	GasLimit       uint64
+	ValidatorStake *big.Int // Stake allocated to each validator in genesis
+	FaucetBalance  *big.Int // Balance allocated to the faucet account in genesis
+}
+
+// DefaultTestnetConfig returns default testnet configuration, matching scripts/deploy/deploy_testnet.sh
+func DefaultTestnetConfig() *TestnetConfig {
+	ether := big.NewInt(1000000000000000000)
+	return &TestnetConfig{
+		ChainID:        1337,
+		Validators:     4,
+		Bootnodes:      1,
+		BootnodeIP:     net.IPv4(127, 0, 0, 1),
+		BootnodePort:   30303,
+		GasLimit:       8000000,
+		ValidatorStake: new(big.Int).Mul(big.NewInt(32), ether),
+		FaucetBalance:  new(big.Int).Mul(big.NewInt(1000000), ether),
+	}
+}
+
+// Testnet holds the generated identities of a test network
+type Testnet struct {
+	Config     *TestnetConfig
+	Bootnodes  []*Bootnode
+	Validators []*ecdsa.PrivateKey
+	FaucetKey  *ecdsa.PrivateKey
+}
+
+// NewTestnet generates bootnode, validator and faucet keys for a test network
+func NewTestnet(config *TestnetConfig) (*Testnet, error) {
+	if config == nil {
+		config = DefaultTestnetConfig()
+	}
+	if config.Validators <= 0 {
+		return nil, errors.New("testnet requires at least one validator")
+	}
+
+	testnet := &Testnet{Config: config}
+	for i := 0; i < config.Bootnodes; i++ {
+		bootnode, err := GenerateBootnode(config.BootnodeIP, config.BootnodePort+i)
+		if err != nil {
+			return nil, err
+		}
+		testnet.Bootnodes = append(testnet.Bootnodes, bootnode)
+	}
+	for i := 0; i < config.Validators; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		testnet.Validators = append(testnet.Validators, key)
+	}
+
+	faucetKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	testnet.FaucetKey = faucetKey
+
+	return testnet, nil
+}
+
+// BootnodeURLs returns the enode URLs of all bootnodes
+func (t *Testnet) BootnodeURLs() []string {
+	urls := make([]string, len(t.Bootnodes))
+	for i, bootnode := range t.Bootnodes {
+		urls[i] = bootnode.URL()
+	}
+	return urls
+}
+
+// ValidatorAddresses returns the addresses of all validators
+func (t *Testnet) ValidatorAddresses() []common.Address {
+	addresses := make([]common.Address, len(t.Validators))
+	for i, key := range t.Validators {
+		addresses[i] = crypto.PubkeyToAddress(key.PublicKey)
+	}
+	return addresses
+}
+
+// FaucetAddress returns the address of the faucet account
+func (t *Testnet) FaucetAddress() common.Address {
+	return crypto.PubkeyToAddress(t.FaucetKey.PublicKey)
+}
+
+// genesisAccount is a genesis allocation
+type genesisAccount struct {
+	Balance *hexutil.Big `json:"balance"`
+}
+
+// Genesis returns the genesis specification with validator and faucet allocations
+func (t *Testnet) Genesis() map[string]interface{} {
+	alloc := make(map[common.Address]genesisAccount)
+	for _, address := range t.ValidatorAddresses() {
+		alloc[address] = genesisAccount{Balance: (*hexutil.Big)(t.Config.ValidatorStake)}
+	}
+	alloc[t.FaucetAddress()] = genesisAccount{Balance: (*hexutil.Big)(t.Config.FaucetBalance)}
+
+	chainConfig := map[string]interface{}{
+		"chainId": t.Config.ChainID,
+	}
+	for _, fork := range []string{
+		"homesteadBlock", "eip150Block", "eip155Block", "eip158Block", "byzantiumBlock",
+		"constantinopleBlock", "petersburgBlock", "istanbulBlock", "berlinBlock", "londonBlock",
+		"arrowGlacierBlock", "grayGlacierBlock", "shanghaiBlock", "cancunBlock", "p2sBlock",
+	} {
+		chainConfig[fork] = 0
+	}
+
+	return map[string]interface{}{
+		"config":     chainConfig,
+		"difficulty": "0x400",
+		"gasLimit":   hexutil.Uint64(t.Config.GasLimit),
+		"alloc":      alloc,
+		"validators": t.ValidatorAddresses(),
+	}
+}
+
+// WriteTo writes keys, genesis and bootnode list into dir:
+//
+//	genesis.json
+//	bootnodes.txt
+//	bootnode<i>/nodekey
+//	validator<i>/key
+//	faucet/key
+func (t *Testnet) WriteTo(dir string) error {
+	genesis, err := json.MarshalIndent(t.Genesis(), "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "genesis.json"), genesis, 0o644); err != nil {
+		return err
+	}
+
+	bootnodes := ""
+	for i, bootnode := range t.Bootnodes {
+		if err := writeKey(filepath.Join(dir, fmt.Sprintf("bootnode%d", i), "nodekey"), bootnode.Key); err != nil {
+			return err
+		}
+		bootnodes += bootnode.URL() + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bootnodes.txt"), []byte(bootnodes), 0o644); err != nil {
+		return err
+	}
+
+	for i, key := range t.Validators {
+		if err := writeKey(filepath.Join(dir, fmt.Sprintf("validator%d", i), "key"), key); err != nil {
+			return err
+		}
+	}
+
+	return writeKey(filepath.Join(dir, "faucet", "key"), t.FaucetKey)
+}
+
+// writeKey writes a hex-encoded private key, creating its directory
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return crypto.SaveECDSA(path, key)
+}
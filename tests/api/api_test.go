@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// assertPopulated fails for every zero field of a converted struct except the
+// exempt ones, so fields added to the stable types cannot be left unconverted
+func assertPopulated(t *testing.T, v interface{}, exempt ...string) {
+	t.Helper()
+	value := reflect.ValueOf(v).Elem()
+	skip := make(map[string]bool)
+	for _, name := range exempt {
+		skip[name] = true
+	}
+	for i := 0; i < value.NumField(); i++ {
+		name := value.Type().Field(i).Name
+		if !skip[name] && value.Field(i).IsZero() {
+			t.Errorf("%s.%s was not converted", value.Type().Name(), name)
+		}
+	}
+}
+
+// assertJSONRoundTrip checks that a stable type survives its wire encoding
+func assertJSONRoundTrip(t *testing.T, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, decoded) {
+		t.Fatalf("Expected %T to round trip through JSON, got %+v", v, decoded)
+	}
+}
+
+func testPHT(hash byte) *p2s.PHTTransaction {
+	return &p2s.PHTTransaction{
+		TxHash:       common.Hash{hash},
+		Sender:       common.Address{0x01},
+		GasPrice:     big.NewInt(1000000000),
+		Commitment:   []byte{0x02, 0x03},
+		Nonce:        []byte{0x04},
+		NonceProof:   []byte{0x05},
+		Timestamp:    1700000000,
+		AccountNonce: 7,
+	}
+}
+
+func testMT(hash byte) *p2s.MTTransaction {
+	return &p2s.MTTransaction{
+		TxHash:    common.Hash{hash},
+		PHTHash:   common.Hash{0x10, hash},
+		Recipient: common.Address{0x02},
+		Value:     big.NewInt(5),
+		CallData:  []byte{0xa9, 0x05, 0x9c, 0xbb},
+		TxType:    types.DynamicFeeTxType,
+		GasLimit:  21000,
+		Timestamp: 1700000001,
+	}
+}
+
+func TestConvertPHT(t *testing.T) {
+	if FromPHT(nil) != nil {
+		t.Fatal("Expected a nil PHT to convert to nil")
+	}
+	source := testPHT(0xaa)
+	pht := FromPHT(source)
+	assertPopulated(t, pht)
+	if pht.TxHash != source.TxHash || pht.Sender != source.Sender || pht.GasPrice.Cmp(source.GasPrice) != 0 || pht.AccountNonce != source.AccountNonce {
+		t.Fatalf("Expected the visible fields to be converted, got %+v", pht)
+	}
+	assertJSONRoundTrip(t, pht)
+
+	// Conversions do not alias the engine's PHT
+	source.Commitment[0] = 0xff
+	source.GasPrice.SetInt64(1)
+	if pht.Commitment[0] != 0x02 || pht.GasPrice.Int64() != 1000000000 {
+		t.Fatal("Expected the converted PHT to own its fields")
+	}
+
+	// Fields the network hides until B2 stay hidden
+	hidden := testPHT(0xab)
+	hidden.HiddenSet = p2s.DefaultHiddenFieldSet | 1<<p2s.FieldSender | 1<<p2s.FieldGasPrice
+	if pht := FromPHT(hidden); pht.Sender != (common.Address{}) || pht.GasPrice != nil {
+		t.Fatalf("Expected the hidden sender and gas price to be dropped, got %+v", pht)
+	}
+	assertJSONRoundTrip(t, FromPHT(hidden))
+}
+
+func TestConvertMT(t *testing.T) {
+	if FromMT(nil) != nil {
+		t.Fatal("Expected a nil MT to convert to nil")
+	}
+	source := testMT(0xaa)
+	mt := FromMT(source)
+	assertPopulated(t, mt, "IsContractCreation")
+	if mt.PHTHash != source.PHTHash || mt.Recipient != source.Recipient || mt.Value.Cmp(source.Value) != 0 || mt.GasLimit != source.GasLimit {
+		t.Fatalf("Expected the MT to be converted, got %+v", mt)
+	}
+	assertJSONRoundTrip(t, mt)
+
+	source.CallData[0] = 0
+	if mt.CallData[0] != 0xa9 {
+		t.Fatal("Expected the converted MT to own its call data")
+	}
+
+	creation := testMT(0xab)
+	creation.Recipient, creation.IsContractCreation = common.Address{}, true
+	if mt := FromMT(creation); !mt.IsContractCreation {
+		t.Fatal("Expected contract creations to be marked")
+	}
+	assertJSONRoundTrip(t, FromMT(creation))
+}
+
+func TestConvertBlocks(t *testing.T) {
+	if FromB1Block(nil) != nil || FromB2Block(nil) != nil {
+		t.Fatal("Expected nil blocks to convert to nil")
+	}
+	header := &types.Header{Number: big.NewInt(7)}
+	b1 := &p2s.B1Block{
+		Header:          header,
+		PHTs:            []*p2s.PHTTransaction{testPHT(0x01), testPHT(0x02)},
+		BlockType:       1,
+		MEVScore:        0.9,
+		DetectedAttacks: []string{"sandwich"},
+		Timestamp:       1700000000,
+		BlockHash:       common.Hash{0xb1},
+	}
+	block1 := FromB1Block(b1)
+	assertPopulated(t, block1)
+	if block1.Hash != b1.BlockHash || block1.Number != 7 || len(block1.PHTs) != 2 || block1.PHTs[1].TxHash != b1.PHTs[1].TxHash {
+		t.Fatalf("Expected the B1 block to be converted, got %+v", block1)
+	}
+	assertJSONRoundTrip(t, block1)
+	b1.DetectedAttacks[0] = "front_running"
+	if block1.DetectedAttacks[0] != "sandwich" {
+		t.Fatal("Expected the converted B1 block to own its attacks")
+	}
+
+	b2 := &p2s.B2Block{
+		Header:      &types.Header{Number: big.NewInt(8)},
+		MTs:         []*p2s.MTTransaction{testMT(0x01), testMT(0x02)},
+		BlockType:   2,
+		B1BlockHash: b1.BlockHash,
+		Timestamp:   1700000006,
+		BlockHash:   common.Hash{0xb2},
+	}
+	block2 := FromB2Block(b2)
+	assertPopulated(t, block2)
+	if block2.Hash != b2.BlockHash || block2.B1Hash != b1.BlockHash || block2.Number != 8 || len(block2.MTs) != 2 {
+		t.Fatalf("Expected the B2 block to be converted, got %+v", block2)
+	}
+	assertJSONRoundTrip(t, block2)
+
+	// Blocks without a header keep a zero number
+	if block := FromB2Block(&p2s.B2Block{BlockHash: common.Hash{0xb3}}); block.Number != 0 || len(block.MTs) != 0 {
+		t.Fatalf("Expected an empty headerless block, got %+v", block)
+	}
+}
+
+func TestConvertAnalysisAndValidator(t *testing.T) {
+	analysis := FromMEVAnalysis(&p2s.MEVAnalysis{
+		Score:           0.4,
+		RiskLevel:       "high",
+		DetectedAttacks: []string{"sandwich"},
+		Recommendations: []string{"delay"},
+		Factors:         []p2s.MEVFactor{{Rule: "sandwich", Attack: true, Penalty: 0.3, Evidence: "paired swaps"}},
+		EstimatedProfit: big.NewInt(100),
+	})
+	assertPopulated(t, analysis)
+	if analysis.Factors[0] != (MEVFactor{Rule: "sandwich", Attack: true, Penalty: 0.3, Evidence: "paired swaps"}) {
+		t.Fatalf("Expected the factors to be converted, got %+v", analysis.Factors)
+	}
+	assertJSONRoundTrip(t, analysis)
+
+	validator := FromValidator(&p2s.Validator{Address: common.Address{0x01}, Stake: big.NewInt(32), Reputation: 90, IsActive: true, LastBlock: 12})
+	assertPopulated(t, validator)
+	assertJSONRoundTrip(t, validator)
+	if FromMEVAnalysis(nil) != nil || FromValidator(nil) != nil {
+		t.Fatal("Expected nil values to convert to nil")
+	}
+}
+
+func TestRequireVersion(t *testing.T) {
+	if Version != fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch) {
+		t.Fatalf("Expected the version to follow its parts, got %s", Version)
+	}
+	tests := []struct {
+		required string
+		err      error
+	}{
+		{Version, nil},
+		{"v" + Version, nil},
+		{fmt.Sprintf("%d.%d", VersionMajor, VersionMinor), nil},
+		{fmt.Sprintf("%d.%d.%d", VersionMajor, VersionMinor, VersionPatch+1), ErrIncompatibleVersion},
+		{fmt.Sprintf("%d.%d", VersionMajor, VersionMinor+1), ErrIncompatibleVersion},
+		{fmt.Sprintf("%d.0", VersionMajor+1), ErrIncompatibleVersion},
+		{fmt.Sprintf("%d.%d", VersionMajor-1, VersionMinor+1), ErrIncompatibleVersion},
+		{"1", ErrInvalidVersion},
+		{"1.0.0.0", ErrInvalidVersion},
+		{"1.x", ErrInvalidVersion},
+		{"1.-1", ErrInvalidVersion},
+		{"", ErrInvalidVersion},
+	}
+	for _, test := range tests {
+		if err := RequireVersion(test.required); !errors.Is(err, test.err) || (test.err == nil && err != nil) {
+			t.Errorf("RequireVersion(%q): expected %v, got %v", test.required, test.err, err)
+		}
+	}
+	if VersionMinor > 0 {
+		if err := RequireVersion(fmt.Sprintf("%d.%d", VersionMajor, VersionMinor-1)); err != nil {
+			t.Fatalf("Expected older minor versions to be satisfied, got %v", err)
+		}
+	}
+}
+
+// testEngine is an engine serving fixed blocks and validators
+type testEngine struct {
+	b1Blocks   map[common.Hash]*p2s.B1Block
+	b2Blocks   map[common.Hash]*p2s.B2Block
+	attacks    []string
+	validators map[common.Address]*p2s.Validator
+}
+
+func (e *testEngine) GetB1Block(hash common.Hash) (*p2s.B1Block, bool) {
+	block, ok := e.b1Blocks[hash]
+	return block, ok
+}
+
+func (e *testEngine) GetB2Block(hash common.Hash) (*p2s.B2Block, bool) {
+	block, ok := e.b2Blocks[hash]
+	return block, ok
+}
+
+func (e *testEngine) GetMEVScore(block *types.Block) float64 { return 0.75 }
+
+func (e *testEngine) GetDetectedAttacks(block *types.Block) []string { return e.attacks }
+
+func (e *testEngine) GetValidatorInfo(validator common.Address) *p2s.Validator {
+	return e.validators[validator]
+}
+
+func TestReader(t *testing.T) {
+	b1 := &p2s.B1Block{Header: &types.Header{Number: big.NewInt(3)}, PHTs: []*p2s.PHTTransaction{testPHT(0x01)}, BlockHash: common.Hash{0xb1}}
+	b2 := &p2s.B2Block{Header: &types.Header{Number: big.NewInt(4)}, MTs: []*p2s.MTTransaction{testMT(0x01)}, B1BlockHash: b1.BlockHash, BlockHash: common.Hash{0xb2}}
+	engine := &testEngine{
+		b1Blocks:   map[common.Hash]*p2s.B1Block{b1.BlockHash: b1},
+		b2Blocks:   map[common.Hash]*p2s.B2Block{b2.BlockHash: b2},
+		attacks:    []string{"sandwich"},
+		validators: map[common.Address]*p2s.Validator{{0x01}: {Address: common.Address{0x01}, Stake: big.NewInt(32), IsActive: true}},
+	}
+	reader := NewReader(engine)
+
+	if block := reader.B1Block(b1.BlockHash); block == nil || block.Number != 3 || len(block.PHTs) != 1 {
+		t.Fatalf("Expected the B1 block to be served, got %+v", block)
+	}
+	if block := reader.B2Block(b2.BlockHash); block == nil || block.B1Hash != b1.BlockHash {
+		t.Fatalf("Expected the B2 block to be served, got %+v", block)
+	}
+	if reader.B1Block(common.Hash{0xff}) != nil || reader.B2Block(common.Hash{0xff}) != nil {
+		t.Fatal("Expected unknown blocks to be nil")
+	}
+	if reader.MEVScore(nil) != 0.75 {
+		t.Fatal("Expected the engine's MEV score")
+	}
+	attacks := reader.DetectedAttacks(nil)
+	attacks[0] = "front_running"
+	if engine.attacks[0] != "sandwich" {
+		t.Fatal("Expected detected attacks to be copied")
+	}
+	if validator := reader.Validator(common.Address{0x01}); validator == nil || !validator.Active || validator.Stake.Int64() != 32 {
+		t.Fatalf("Expected the validator to be served, got %+v", validator)
+	}
+	if reader.Validator(common.Address{0x02}) != nil {
+		t.Fatal("Expected an unknown validator to be nil")
+	}
+}
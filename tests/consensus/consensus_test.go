@@ -1,18 +1,52 @@
 package p2s
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang/snappy"
+	"github.com/holiman/uint256"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestConsensus(t *testing.T) {
 	// Create P2S consensus engine
 	config := DefaultConfig()
-	consensus := NewConsensus(nil, config)
+	consensus := newTestConsensus(t, config)
 	
 	// Test basic functionality
 	if consensus == nil {
@@ -39,7 +73,7 @@ func TestConsensus(t *testing.T) {
 func TestPHTManager(t *testing.T) {
 	// Create PHT manager
 	config := DefaultP2SConfig()
-	manager := NewPHTManager(config)
+	manager := newTestPHTManager(t, config)
 	
 	// Test basic functionality
 	if manager == nil {
@@ -76,7 +110,7 @@ func TestPHTManager(t *testing.T) {
 func TestMTManager(t *testing.T) {
 	// Create MT manager
 	config := DefaultP2SConfig()
-	manager := NewMTManager(config)
+	manager := newTestMTManager(t, config)
 	
 	// Test basic functionality
 	if manager == nil {
@@ -136,7 +170,11 @@ func TestValidatorManager(t *testing.T) {
 	}
 	
 	// Generate test validator address
-	address := GenerateValidatorAddress()
+	key, err := GenerateValidatorKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := key.Address()
 	
 	// Test validator address validation
 	if !ValidateValidatorAddress(address) {
@@ -144,7 +182,7 @@ func TestValidatorManager(t *testing.T) {
 	}
 	
 	// Test adding validator
-	err := manager.AddValidator(address, big.NewInt(1000000000000000000)) // 1 ETH
+	err = manager.AddValidator(address, big.NewInt(1000000000000000000)) // 1 ETH
 	if err != nil {
 		t.Fatalf("Failed to add validator: %v", err)
 	}
@@ -166,7 +204,7 @@ func TestValidatorManager(t *testing.T) {
 	}
 	
 	// Test validator selection
-	proposer, err := manager.SelectProposer(1)
+	proposer, err := manager.SelectProposer(1, common.Hash{})
 	if err != nil {
 		t.Fatalf("Failed to select proposer: %v", err)
 	}
@@ -493,3 +531,7326 @@ func TestB2BlockValidation(t *testing.T) {
 		t.Fatal("B1 block hash should match")
 	}
 }
+
+func TestDetectionMetrics(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	pht := &PHTTransaction{
+		Sender:    common.Address{},
+		GasPrice:  big.NewInt(60000000000), // 60 gwei triggers sandwich and front-running
+		Timestamp: uint64(time.Now().Unix()),
+		Recipient: common.Address{},
+		Value:     big.NewInt(1000),
+		CallData:  []byte{},
+		GasLimit:  21000,
+	}
+
+	detector.DetectMEV([]*PHTTransaction{pht, pht})
+
+	metrics := detector.GetDetectionMetrics()
+	sandwich, exists := metrics["sandwich_attack"]
+	if !exists {
+		t.Fatal("Sandwich attack metrics should exist")
+	}
+
+	if sandwich.Evaluations != 2 || sandwich.Matches != 2 {
+		t.Fatalf("Unexpected sandwich metrics: %+v", sandwich)
+	}
+
+	if metrics["liquidation"].Matches != 0 {
+		t.Fatal("Liquidation should not match")
+	}
+
+	detector.ResetDetectionMetrics()
+	if len(detector.GetDetectionMetrics()) != 0 {
+		t.Fatal("Metrics should be empty after reset")
+	}
+}
+
+func TestPrivacyGuardRedactsUnrevealedPHTs(t *testing.T) {
+	guard := NewPrivacyGuard(1, 10)
+
+	pht := &PHTTransaction{
+		Sender:     common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		GasPrice:   big.NewInt(1000000000),
+		Commitment: []byte("test commitment"),
+		Nonce:      []byte("test nonce"),
+		Timestamp:  uint64(time.Now().Unix()),
+		Recipient:  common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"),
+		Value:      big.NewInt(1000),
+		CallData:   common.Hex2Bytes("38ed1739deadbeef"),
+		GasLimit:   21000,
+		TxHash:     common.Hash{0x01},
+		Blinding:   common.Hex2Bytes("0badc0ffee0ddf00d5"),
+	}
+	phts := []*PHTTransaction{pht}
+
+	// Exporting the full PHT before reveal must be caught by the audit
+	if _, err := guard.MarshalAudited(pht, phts); err == nil {
+		t.Fatal("Exporting unrevealed PHT should fail audit")
+	}
+
+	// Quantities and commitment openings are audited, not only recipient and calldata
+	for name, leak := range map[string]interface{}{
+		"value":    map[string]interface{}{"amount": pht.Value},
+		"gasPrice": map[string]interface{}{"fee": hexutil.EncodeBig(pht.GasPrice)},
+		"gasLimit": []uint64{pht.GasLimit},
+		"blinding": map[string]interface{}{"opening": hexutil.Bytes(pht.Blinding)},
+	} {
+		if _, err := guard.MarshalAudited(leak, phts); !errors.Is(err, ErrHiddenFieldLeak) {
+			t.Fatalf("Exporting %s before reveal should fail audit, got %v", name, err)
+		}
+	}
+	if _, err := guard.MarshalAudited(map[string]uint64{"amount": 10000}, phts); err != nil {
+		t.Fatalf("Quantities should only match whole scalars: %v", err)
+	}
+
+	// Receipts and admission results are redacted before reveal
+	receipt := guard.ExportReceipt(&PHTReceipt{TxHash: pht.TxHash, GasUsed: 21000})
+	if receipt.GasUsed != 0 {
+		t.Fatal("Receipt should not expose gas used before reveal")
+	}
+	admission := guard.ExportAdmission(&AdmissionResult{Rejections: []AdmissionRejection{{
+		Code:    RejectFeeBelowFloor,
+		Message: "gas price " + pht.GasPrice.String() + " below floor",
+	}}}, pht)
+	if admission.Rejections[0].Code != RejectFeeBelowFloor || admission.Rejections[0].Message != "" {
+		t.Fatalf("Admission messages exposing the fee should be withheld, got %v", admission.Rejections)
+	}
+
+	// The redacted B1 export must not contain any hidden field
+	block := &B1Block{PHTs: phts, BlockType: 1, Timestamp: pht.Timestamp}
+	if _, err := guard.ExportB1Block(block); err != nil {
+		t.Fatalf("Redacted B1 export failed audit: %v", err)
+	}
+
+	// Logs must not echo fee or calldata size before reveal
+	if len(guard.LogContext(pht)) != 4 {
+		t.Fatal("Log context should only contain hash and sender before reveal")
+	}
+
+	// Aggregate disclosures are budgeted
+	if _, err := guard.Summarize(phts); err != nil {
+		t.Fatalf("First summary should succeed: %v", err)
+	}
+	if _, err := guard.Summarize(phts); err == nil {
+		t.Fatal("Summary should fail once budget is exhausted")
+	}
+
+	// Budget is restored at the start of the next slot
+	guard.ResetBudget()
+	if _, err := guard.Summarize(phts); err != nil {
+		t.Fatalf("Summary should succeed after budget reset: %v", err)
+	}
+
+	// After reveal the full PHT may be exported
+	guard.MarkRevealed(pht.TxHash, 1)
+	if _, err := guard.MarshalAudited(pht, phts); err != nil {
+		t.Fatalf("Exporting revealed PHT should pass audit: %v", err)
+	}
+	if receipt := guard.ExportReceipt(&PHTReceipt{TxHash: pht.TxHash, GasUsed: 21000}); receipt.GasUsed != 21000 {
+		t.Fatal("Receipt should expose gas used after reveal")
+	}
+	if reveals := guard.ExportReveals([]IndexedMT{{TxHash: pht.TxHash}, {TxHash: common.Hash{0x02}}}); len(reveals) != 1 {
+		t.Fatalf("Expected only the revealed MT, got %d", len(reveals))
+	}
+
+	// Reveals are remembered for the retention window only
+	guard.MarkRevealed(common.Hash{0x03}, 11)
+	if guard.IsRevealed(pht.TxHash) || !guard.IsRevealed(common.Hash{0x03}) {
+		t.Fatal("Reveals past the window should be forgotten")
+	}
+}
+
+func TestDetectMEVParallelMatchesSequential(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	detector.SetConcurrency(4)
+
+	phts := make([]*PHTTransaction, 0, 200)
+	for i := 0; i < 200; i++ {
+		phts = append(phts, &PHTTransaction{
+			GasPrice:  big.NewInt(int64(i) * 500000000),
+			Timestamp: uint64(time.Now().Unix()),
+			Value:     big.NewInt(int64(i) * 100000000000000000),
+			CallData:  []byte{},
+			GasLimit:  21000,
+		})
+	}
+
+	score, attacks := detector.DetectMEV(phts)
+	parallelScore, parallelAttacks, err := detector.DetectMEVParallel(context.Background(), phts)
+	if err != nil {
+		t.Fatalf("Parallel detection failed: %v", err)
+	}
+
+	if score != parallelScore {
+		t.Fatalf("Score mismatch: sequential %f, parallel %f", score, parallelScore)
+	}
+
+	if len(attacks) != len(parallelAttacks) {
+		t.Fatalf("Attack mismatch: sequential %v, parallel %v", attacks, parallelAttacks)
+	}
+
+	// Cancelled contexts must abort analysis
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := detector.DetectMEVParallel(ctx, phts); err == nil {
+		t.Fatal("Cancelled analysis should return an error")
+	}
+}
+
+// Contracts backing the simulator test. poolCode returns its reserves from slots
+// 0 and 1 to any 4 byte call and stores two new reserves otherwise. tokenCode
+// returns the balance in the slot of the address given to a 36 byte call and
+// stores a balance at a slot otherwise.
+var (
+	poolCode  = common.FromHex("0x36600414601457600035600055602035600155005b60005460005260015460205260606000f3")
+	tokenCode = common.FromHex("0x36602414600f5760203560003555005b6004355460005260206000f3")
+)
+
+func TestEVMSimulatorPriceImpact(t *testing.T) {
+	statedb, err := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, pool := common.Address{0x01}, common.Address{0x02}
+	tokenA, tokenB := common.Address{0x03}, common.Address{0x04}
+	word := func(v int64) common.Hash { return common.BigToHash(big.NewInt(v)) }
+
+	statedb.SetCode(pair, poolCode)
+	statedb.SetState(pair, word(0), word(1000))
+	statedb.SetState(pair, word(1), word(1000))
+	for _, token := range []common.Address{tokenA, tokenB} {
+		statedb.SetCode(token, tokenCode)
+		statedb.SetState(token, common.BytesToHash(pool.Bytes()), word(1000))
+	}
+	header := &types.Header{Number: big.NewInt(1), GasLimit: 30000000, BaseFee: big.NewInt(1)}
+	simulator := NewEVMSimulator(statedb, header, params.TestChainConfig, []WatchedPool{
+		{Address: pair},
+		{Address: pool, Tokens: []common.Address{tokenA, tokenB}},
+	})
+	sender := common.Address{0xaa}
+
+	// Reserves read through getReserves price the pair
+	swap := append(word(1250).Bytes(), word(800).Bytes()...)
+	result, err := simulator.Simulate(sender, &MTTransaction{Recipient: pair, Value: new(big.Int), CallData: swap, GasLimit: 100000})
+	if err != nil || result.Failed {
+		t.Fatalf("Expected the swap to execute, got %v %+v", err, result)
+	}
+	if impact := result.PriceImpacts[pair]; math.Abs(impact-0.36) > 1e-9 {
+		t.Fatalf("Expected a price impact of 0.36, got %f", impact)
+	}
+	if _, moved := result.PriceImpacts[pool]; moved || result.PriceImpact != result.PriceImpacts[pair] {
+		t.Fatalf("Expected only the pair to move, got %v", result.PriceImpacts)
+	}
+	if statedb.GetState(pair, word(0)) != word(1000) {
+		t.Fatal("Expected simulation to leave the state untouched")
+	}
+
+	// Pools without a price function are priced by their token balances
+	deposit := append(common.BytesToHash(pool.Bytes()).Bytes(), word(2000).Bytes()...)
+	result, err = simulator.Simulate(sender, &MTTransaction{Recipient: tokenA, Value: new(big.Int), CallData: deposit, GasLimit: 100000})
+	if err != nil || result.Failed {
+		t.Fatalf("Expected the deposit to execute, got %v %+v", err, result)
+	}
+	if impact := result.PriceImpacts[pool]; math.Abs(impact-0.5) > 1e-9 {
+		t.Fatalf("Expected a price impact of 0.5, got %f", impact)
+	}
+
+	// Calls leaving reserves and token balances alone do not move prices
+	result, err = simulator.Simulate(sender, &MTTransaction{Recipient: pool, Value: new(big.Int), CallData: swap, GasLimit: 100000})
+	if err != nil || result.Failed {
+		t.Fatalf("Expected the call to execute, got %v %+v", err, result)
+	}
+	if result.PriceImpact != 0 || len(result.PriceImpacts) != 0 {
+		t.Fatalf("Expected no price impact, got %v", result.PriceImpacts)
+	}
+
+	// The detector flags PHTs whose simulated impact exceeds the threshold
+	config := DefaultP2SConfig()
+	config.EnableMEVSimulation = true
+	detector := NewMEVDetector(config)
+	detector.SetSimulationBackend(simulator)
+	if flagged, _ := detector.isSimulatedPriceImpactPattern(nil, &PHTTransaction{Sender: sender, Recipient: pair, Value: new(big.Int), CallData: swap, GasLimit: 100000}); !flagged {
+		t.Fatal("Expected the swap to be flagged")
+	}
+	if flagged, _ := detector.isSimulatedPriceImpactPattern(nil, &PHTTransaction{Sender: sender, Recipient: pool, Value: new(big.Int), CallData: swap, GasLimit: 100000}); flagged {
+		t.Fatal("Expected the call not to be flagged")
+	}
+}
+
+func TestMEVAnalysisBreakdown(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	pht := &PHTTransaction{
+		GasPrice:  big.NewInt(60000000000), // 60 gwei
+		Timestamp: uint64(time.Now().Unix()),
+		Recipient: common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"),
+		Value:     big.NewInt(1000),
+		CallData:  []byte{},
+		GasLimit:  21000,
+	}
+
+	analysis := detector.AnalyzeMEVRisk(pht)
+
+	// Factor penalties must account for the whole score reduction
+	total := 0.0
+	rules := make(map[string]bool)
+	for _, factor := range analysis.Factors {
+		if factor.Evidence == "" {
+			t.Fatalf("Factor %s has no evidence", factor.Rule)
+		}
+		total += factor.Penalty
+		rules[factor.Rule] = true
+	}
+
+	if diff := 1.0 - total - analysis.Score; diff > 1e-9 || diff < -1e-9 {
+		t.Fatalf("Factor penalties %f do not explain score %f", total, analysis.Score)
+	}
+
+	for _, rule := range []string{"sandwich_attack", "front_running", "arbitrage"} {
+		if !rules[rule] {
+			t.Fatalf("Expected rule %s to fire", rule)
+		}
+	}
+}
+
+func TestSelectiveFieldReveal(t *testing.T) {
+	phtManager := newTestPHTManager(t, DefaultP2SConfig())
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+
+	recipient := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	fields := hiddenFieldVector(recipient, big.NewInt(1000), []byte("secret call data"), 0, 21000)
+	commitment, salts, err := NewVectorCommitment().CommitVector(fields)
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	pht := &PHTTransaction{
+		FieldCommitment: commitment,
+		Recipient:       recipient,
+		Value:           big.NewInt(1000),
+		CallData:        []byte("secret call data"),
+		GasLimit:        21000,
+		FieldSalts:      salts,
+	}
+
+	// Reveal only the recipient
+	openings, err := mtManager.RevealFields(pht, FieldRecipient)
+	if err != nil {
+		t.Fatalf("Failed to reveal recipient: %v", err)
+	}
+
+	if err := phtManager.VerifyFieldOpening(pht, openings[0]); err != nil {
+		t.Fatalf("Recipient opening should verify: %v", err)
+	}
+
+	if common.BytesToAddress(openings[0].Value) != recipient {
+		t.Fatal("Opened recipient mismatch")
+	}
+
+	// A tampered opening must be rejected
+	openings[0].Value = common.HexToAddress("0x3333333333333333333333333333333333333333").Bytes()
+	if err := phtManager.VerifyFieldOpening(pht, openings[0]); err == nil {
+		t.Fatal("Tampered opening should not verify")
+	}
+}
+
+type staticMarketData struct {
+	reserve   *big.Int
+	spreadBps uint64
+}
+
+func (s *staticMarketData) PoolReserves(common.Address, []byte) (*big.Int, *big.Int, bool) {
+	return s.reserve, s.reserve, true
+}
+
+func (s *staticMarketData) ArbitrageSpread(common.Address, []byte) (uint64, bool) {
+	return s.spreadBps, true
+}
+
+func (s *staticMarketData) LiquidationBonus(common.Address) (uint64, bool) {
+	return 0, false
+}
+
+func TestEstimateMEVProfit(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	pht := &PHTTransaction{
+		GasPrice:  big.NewInt(60000000000), // 60 gwei
+		Timestamp: uint64(time.Now().Unix()),
+		Recipient: common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"),
+		Value:     big.NewInt(1000),
+		CallData:  []byte{},
+		GasLimit:  21000,
+	}
+
+	// Without market data no profit can be estimated
+	if profit := detector.EstimateMEVProfit([]*PHTTransaction{pht}); profit.Sign() != 0 {
+		t.Fatalf("Expected zero profit without market data, got %v", profit)
+	}
+
+	detector.SetMarketData(&staticMarketData{reserve: big.NewInt(1000), spreadBps: 50})
+
+	// Sandwich: 1000²/(2·2000) - 0.6% fees = 244; arbitrage: 0.5% of 1000 = 5
+	expected := big.NewInt(249)
+	if profit := detector.EstimateMEVProfit([]*PHTTransaction{pht}); profit.Cmp(expected) != 0 {
+		t.Fatalf("Expected profit %v, got %v", expected, profit)
+	}
+
+	analysis := detector.AnalyzeMEVRisk(pht)
+	if analysis.EstimatedProfit == nil || analysis.EstimatedProfit.Cmp(expected) != 0 {
+		t.Fatalf("Expected analysis profit %v, got %v", expected, analysis.EstimatedProfit)
+	}
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	db := memorydb.New()
+
+	applied := []uint64{}
+	migrations := []Migration{
+		{Version: 2, Description: "second", Apply: func(ethdb.KeyValueStore) error { applied = append(applied, 2); return nil }},
+		{Version: 1, Description: "first", Apply: func(ethdb.KeyValueStore) error { applied = append(applied, 1); return nil }},
+	}
+
+	// Dry run must not touch the database
+	version, err := NewMigrator(migrations, &MigrationConfig{DryRun: true}).Run(db)
+	if err != nil || version != 0 || len(applied) != 0 {
+		t.Fatalf("Dry run modified database: version %d, applied %v, err %v", version, applied, err)
+	}
+
+	backups := 0
+	config := &MigrationConfig{Backup: func(ethdb.KeyValueStore, uint64) error { backups++; return nil }}
+	version, err = NewMigrator(migrations, config).Run(db)
+	if err != nil {
+		t.Fatalf("Migration failed: %v", err)
+	}
+	if version != 2 || len(applied) != 2 || applied[0] != 1 || backups != 1 {
+		t.Fatalf("Unexpected migration result: version %d, applied %v, backups %d", version, applied, backups)
+	}
+
+	// A binary that only knows version 1 must refuse the database
+	if _, err := NewMigrator(migrations[1:], nil).Run(db); !errors.Is(err, ErrDatabaseTooNew) {
+		t.Fatalf("Expected ErrDatabaseTooNew, got %v", err)
+	}
+}
+
+func TestCompositeAttackPatterns(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	err := detector.AddCompositePattern("aggressive_sandwich", &AttackPattern{
+		Name:     "Aggressive Sandwich",
+		Severity: "critical",
+		Rule:     "sandwich_attack AND (front_running OR liquidation)",
+		Penalty:  0.1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to add composite pattern: %v", err)
+	}
+	copied := detector.GetAllAttackPatterns()["aggressive_sandwich"]
+	if copied == nil || copied.Rule != "sandwich_attack AND (front_running OR liquidation)" || copied.Penalty != 0.1 {
+		t.Fatalf("Expected copied patterns to keep the composite rule and penalty, got %+v", copied)
+	}
+	copied.Rule = "front_running"
+	if detector.GetAttackPattern("aggressive_sandwich").Rule == copied.Rule {
+		t.Fatal("Expected copied patterns not to alias the detector's")
+	}
+
+	// Composite patterns may build on each other but not cyclically
+	if err := detector.AddCompositePattern("cyclic", &AttackPattern{Rule: "aggressive_sandwich AND cyclic"}); err == nil {
+		t.Fatal("Expected cyclic rule to be rejected")
+	}
+	if err := detector.AddCompositePattern("unknown", &AttackPattern{Rule: "front_running AND missing"}); err == nil {
+		t.Fatal("Expected rule with unknown reference to be rejected")
+	}
+	if err := detector.AddCompositePattern("malformed", &AttackPattern{Rule: "front_running AND"}); err == nil {
+		t.Fatal("Expected malformed rule to be rejected")
+	}
+
+	pht := &PHTTransaction{
+		GasPrice:  big.NewInt(60000000000), // 60 gwei: sandwich and front-running
+		Timestamp: uint64(time.Now().Unix()),
+		Recipient: common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D"),
+		Value:     big.NewInt(1000),
+		CallData:  []byte{},
+		GasLimit:  21000,
+	}
+	analysis := detector.AnalyzeMEVRisk(pht)
+
+	found := false
+	for _, attack := range analysis.DetectedAttacks {
+		if attack == "aggressive_sandwich" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected composite pattern to fire, got %v", analysis.DetectedAttacks)
+	}
+
+	pht.GasPrice = big.NewInt(20000000000) // 20 gwei: sandwich only
+	for _, attack := range detector.AnalyzeMEVRisk(pht).DetectedAttacks {
+		if attack == "aggressive_sandwich" {
+			t.Fatal("Composite pattern fired without front-running or liquidation")
+		}
+	}
+}
+
+func TestMEVReportExport(t *testing.T) {
+	history := NewMEVHistory(0)
+	detector := NewMEVDetector(DefaultP2SConfig())
+	score := func(pht *PHTTransaction) (float64, []string) {
+		analysis := detector.AnalyzeMEVRisk(pht)
+		return analysis.Score, analysis.DetectedAttacks
+	}
+
+	attacker := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	honest := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	for number := uint64(1); number <= 3; number++ {
+		b1Block := &B1Block{
+			PHTs: []*PHTTransaction{
+				{Sender: attacker, GasPrice: big.NewInt(60000000000), Value: big.NewInt(1000), CallData: []byte{}},
+				{Sender: honest, GasPrice: big.NewInt(1000000000), Value: big.NewInt(1000), CallData: []byte{}},
+			},
+			Timestamp: uint64(time.Now().Unix()),
+		}
+		history.RecordBlock(b1Block, number, common.BigToHash(new(big.Int).SetUint64(number)), score)
+	}
+
+	key, _ := crypto.GenerateKey()
+	report, err := NewMEVReportExporter(history, key).Generate(2, 3, 10)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+
+	if report.Blocks != 2 || report.Transactions != 4 {
+		t.Fatalf("Expected 2 blocks and 4 transactions, got %d and %d", report.Blocks, report.Transactions)
+	}
+	if len(report.TopSenders) != 1 || report.TopSenders[0].Sender != attacker {
+		t.Fatalf("Expected attacker as only top sender, got %v", report.TopSenders)
+	}
+	if report.AttackCounts["front_running"] != 2 {
+		t.Fatalf("Expected 2 front-running detections, got %d", report.AttackCounts["front_running"])
+	}
+
+	if err := VerifyMEVReport(report); err != nil {
+		t.Fatalf("Report signature invalid: %v", err)
+	}
+	report.AttackCounts["front_running"] = 0
+	if err := VerifyMEVReport(report); !errors.Is(err, ErrInvalidReportSignature) {
+		t.Fatal("Expected tampered report to fail verification")
+	}
+
+	var csv bytes.Buffer
+	if err := report.WriteCSV(&csv); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if !bytes.Contains(csv.Bytes(), []byte("top_senders,"+attacker.Hex())) {
+		t.Fatal("CSV report missing top sender row")
+	}
+}
+
+func TestValidatorPaymentStatements(t *testing.T) {
+	ledger := NewPaymentLedger(32)
+
+	validators := []common.Address{
+		common.HexToAddress("0x1111111111111111111111111111111111111111"),
+		common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		common.HexToAddress("0x3333333333333333333333333333333333333333"),
+	}
+	for i, validator := range validators {
+		block := uint64(32 + i)
+		ledger.RecordDuty(block, validator, DutyB1Proposal)
+		ledger.RecordDuty(block, validator, DutyB2Proposal)
+		ledger.RecordReward(block, validator, big.NewInt(int64(1000*(i+1))))
+	}
+	ledger.RecordPenalty(40, validators[2], big.NewInt(500))
+
+	if _, err := ledger.Statement(1, validators[0]); !errors.Is(err, ErrEpochNotSealed) {
+		t.Fatalf("Expected ErrEpochNotSealed, got %v", err)
+	}
+
+	key, _ := crypto.GenerateKey()
+	root, err := ledger.SealEpoch(1, key)
+	if err != nil {
+		t.Fatalf("Failed to seal epoch: %v", err)
+	}
+	if err := ledger.RecordDuty(33, validators[0], DutySeal); !errors.Is(err, ErrEpochSealed) {
+		t.Fatalf("Expected ErrEpochSealed, got %v", err)
+	}
+
+	for _, validator := range validators {
+		statement, err := ledger.Statement(1, validator)
+		if err != nil {
+			t.Fatalf("Failed to get statement: %v", err)
+		}
+		if statement.EpochRoot != root {
+			t.Fatal("Statement root does not match sealed root")
+		}
+		if err := VerifyPaymentStatement(statement); err != nil {
+			t.Fatalf("Statement for %s failed verification: %v", validator.Hex(), err)
+		}
+	}
+
+	statement, _ := ledger.Statement(1, validators[2])
+	if statement.Net().Cmp(big.NewInt(2500)) != 0 {
+		t.Fatalf("Expected net payment 2500, got %v", statement.Net())
+	}
+
+	// Inflating rewards must break the proof
+	statement.Rewards = big.NewInt(1000000)
+	if err := VerifyPaymentStatement(statement); !errors.Is(err, ErrInvalidPaymentProof) {
+		t.Fatal("Expected tampered statement to fail verification")
+	}
+}
+
+type staticAdmissionState struct {
+	pending int
+	bond    *big.Int
+}
+
+func (s *staticAdmissionState) PendingPHTs(common.Address) int { return s.pending }
+
+func (s *staticAdmissionState) Bond(common.Address) *big.Int { return s.bond }
+
+func TestSimulateAdmission(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.PHTBond = big.NewInt(1000)
+	policy := NewAdmissionPolicy(config)
+	policy.SetState(&staticAdmissionState{pending: 0, bond: big.NewInt(1000)})
+
+	pht := &PHTTransaction{
+		GasPrice:   big.NewInt(2000000000), // 2 gwei
+		Commitment: []byte{0x01},
+		CallData:   []byte{0x01, 0x02},
+	}
+	if result := policy.Check(pht); !result.Admitted {
+		t.Fatalf("Expected PHT to be admitted, got %v", result.Rejections)
+	}
+
+	pht.GasPrice = big.NewInt(1)
+	pht.CallData = make([]byte, config.MaxPHTCallDataSize+1)
+	policy.SetState(&staticAdmissionState{pending: config.MaxPendingPHTsPerSender, bond: nil})
+
+	result := policy.Check(pht)
+	if result.Admitted {
+		t.Fatal("Expected PHT to be rejected")
+	}
+
+	codes := make(map[string]bool)
+	for _, rejection := range result.Rejections {
+		codes[rejection.Code] = true
+	}
+	for _, code := range []string{RejectFeeBelowFloor, RejectCallDataTooLarge, RejectSenderCapReached, RejectBondMissing} {
+		if !codes[code] {
+			t.Fatalf("Expected rejection %s, got %v", code, result.Rejections)
+		}
+	}
+}
+
+// newTestConsensus creates an engine, failing the test if the configuration is
+// rejected
+func newTestConsensus(tb testing.TB, config *Config) *Consensus {
+	tb.Helper()
+	engine, err := NewConsensus(nil, config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return engine
+}
+
+// newTestPHTManager creates a PHT manager, failing the test if the configuration
+// is rejected
+func newTestPHTManager(tb testing.TB, config *P2SConfig) *PHTManager {
+	tb.Helper()
+	manager, err := NewPHTManager(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return manager
+}
+
+// newTestMTManager creates an MT manager, failing the test if the configuration
+// is rejected
+func newTestMTManager(tb testing.TB, config *P2SConfig) *MTManager {
+	tb.Helper()
+	manager, err := NewMTManager(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return manager
+}
+
+func erc20Transfer(token, from, to common.Address, nonce int64) *PHTTransaction {
+	callData := append([]byte{0xa9, 0x05, 0x9c, 0xbb}, common.LeftPadBytes(to.Bytes(), 32)...)
+	callData = append(callData, common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)...)
+
+	return &PHTTransaction{
+		Sender:    from,
+		GasPrice:  big.NewInt(1000000000),
+		Recipient: token,
+		Value:     big.NewInt(0),
+		CallData:  callData,
+		TxHash:    common.BigToHash(big.NewInt(nonce)),
+	}
+}
+
+func TestWashTradingDetection(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	token := common.HexToAddress("0x6b175474e89094c44da98b954eedeac495271d0f")
+	otherToken := common.HexToAddress("0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	a := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	b := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	c := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	hasWashTrading := func(pht *PHTTransaction) bool {
+		for _, attack := range detector.AnalyzeMEVRisk(pht).DetectedAttacks {
+			if attack == "wash_trading" {
+				return true
+			}
+		}
+		return false
+	}
+
+	open := []*PHTTransaction{erc20Transfer(token, a, b, 1), erc20Transfer(token, b, c, 2)}
+	if hasWashTrading(open[0]) || hasWashTrading(open[1]) {
+		t.Fatal("Open transfer chain flagged as wash trading")
+	}
+	detector.RecordTransfers(open)
+
+	// Closing the loop through a different token is not a circular flow
+	crossToken := erc20Transfer(otherToken, c, a, 3)
+	if hasWashTrading(crossToken) {
+		t.Fatal("Cross-token flow flagged as wash trading")
+	}
+	detector.RecordTransfers([]*PHTTransaction{crossToken})
+
+	closing := erc20Transfer(token, c, a, 4)
+	if !hasWashTrading(closing) {
+		t.Fatal("Expected circular flow to be flagged as wash trading")
+	}
+
+	// Analysis leaves the graph alone, so repeated analysis stays flagged
+	if !hasWashTrading(closing) {
+		t.Fatal("Expected repeated analysis to stay flagged")
+	}
+	if len(detector.transfers.edges) != 3 {
+		t.Fatalf("Expected analysis not to record transfers, got %d edges", len(detector.transfers.edges))
+	}
+
+	// Only included transfers with a transaction hash are recorded, once each
+	unhashed := erc20Transfer(token, a, c, 0)
+	unhashed.TxHash = common.Hash{}
+	detector.RecordTransfers([]*PHTTransaction{closing, closing, unhashed})
+	if len(detector.transfers.edges) != 4 {
+		t.Fatalf("Expected 4 recorded transfers, got %d", len(detector.transfers.edges))
+	}
+}
+
+func TestProofCompaction(t *testing.T) {
+	b1Block := &B1Block{BlockHash: common.Hash{0x01}}
+	newB2 := func() *B2Block {
+		return &B2Block{
+			Header: &types.Header{Number: big.NewInt(100)},
+			MTs: []*MTTransaction{{
+				Recipient: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+				Value:     big.NewInt(1000),
+				CallData:  []byte{0x01},
+				GasLimit:  21000,
+				PHTHash:   common.Hash{0xaa},
+				Proof:     []byte{0x01, 0x02, 0x03},
+			}},
+			B1BlockHash: b1Block.BlockHash,
+			BlockHash:   common.Hash{0x02},
+		}
+	}
+	key, _ := crypto.GenerateKey()
+
+	// Pruned node: proofs are dropped and only the attestation is kept
+	db := memorydb.New()
+	compactor := NewProofCompactor(db, 64, false, key)
+	b2Block := newB2()
+	if _, err := compactor.Compact(b1Block, b2Block, 150); !errors.Is(err, ErrChallengeWindowOpen) {
+		t.Fatalf("Expected ErrChallengeWindowOpen, got %v", err)
+	}
+
+	attestation, err := compactor.Compact(b1Block, b2Block, 164)
+	if err != nil {
+		t.Fatalf("Compaction failed: %v", err)
+	}
+	if !b2Block.ProofsCompacted || b2Block.MTs[0].Proof != nil {
+		t.Fatal("Expected per-MT proofs to be stripped")
+	}
+	if err := VerifyPairAttestation(attestation, b2Block); err != nil {
+		t.Fatalf("Attestation failed verification: %v", err)
+	}
+	stored, err := ReadPairAttestation(db, b2Block.BlockHash)
+	if err != nil || stored.PairRoot != attestation.PairRoot {
+		t.Fatalf("Failed to read attestation from cold storage: %v", err)
+	}
+	if has, _ := db.Has(append([]byte("p2s-mt-proofs-"), b2Block.BlockHash.Bytes()...)); has {
+		t.Fatal("Pruned node retained full proofs")
+	}
+
+	b2Block.MTs[0].Value = big.NewInt(2000)
+	if err := VerifyPairAttestation(attestation, b2Block); !errors.Is(err, ErrInvalidPairAttestation) {
+		t.Fatal("Expected modified pair to fail attestation")
+	}
+
+	// Archive node: full proofs are kept alongside the attestation
+	archive := memorydb.New()
+	if _, err := NewProofCompactor(archive, 64, true, key).Compact(b1Block, newB2(), 164); err != nil {
+		t.Fatalf("Archive compaction failed: %v", err)
+	}
+	if has, _ := archive.Has(append([]byte("p2s-mt-proofs-"), b2Block.BlockHash.Bytes()...)); !has {
+		t.Fatal("Archive node did not retain full proofs")
+	}
+}
+
+func TestPatternSetMigration(t *testing.T) {
+	// A set written before versioning, with free-form severity and C-style rule operators
+	legacy := []byte(`{
+		"patterns": {
+			"front_running": {"name": "Front Running", "threshold": 0.5, "description": "tuned", "severity": "MAJOR"},
+			"layered": {"name": "Layered", "severity": "critical", "rule": "aggressive && !liquidation", "penalty": 0.05},
+			"aggressive": {"name": "Aggressive", "severity": "high", "rule": "sandwich_attack&&front_running", "penalty": 0.1},
+			"broken": {"name": "Broken", "severity": "low", "rule": "missing_pattern || arbitrage"}
+		}
+	}`)
+
+	detector := NewMEVDetector(DefaultP2SConfig())
+	report, err := detector.LoadPatterns(legacy)
+	if err != nil {
+		t.Fatalf("Failed to load legacy patterns: %v", err)
+	}
+
+	if report.FromVersion != 0 || report.ToVersion != PatternSchemaVersion {
+		t.Fatalf("Unexpected versions %d -> %d", report.FromVersion, report.ToVersion)
+	}
+	if len(report.Loaded) != 3 {
+		t.Fatalf("Expected 3 loaded patterns, got %v (skipped %v)", report.Loaded, report.Skipped)
+	}
+	if _, skipped := report.Skipped["broken"]; !skipped {
+		t.Fatal("Expected pattern with unknown reference to be skipped")
+	}
+	if report.Migrated["front_running"] == "" || report.Migrated["aggressive"] == "" {
+		t.Fatalf("Expected severity and rule migrations, got %v", report.Migrated)
+	}
+
+	// Round trip through the current schema must load without migrations
+	data, err := detector.ExportPatterns()
+	if err != nil {
+		t.Fatalf("Failed to export patterns: %v", err)
+	}
+	report, err = NewMEVDetector(DefaultP2SConfig()).LoadPatterns(data)
+	if err != nil {
+		t.Fatalf("Failed to reload patterns: %v", err)
+	}
+	if len(report.Migrated) != 0 || len(report.Skipped) != 0 {
+		t.Fatalf("Current schema required migration: %v, skipped %v", report.Migrated, report.Skipped)
+	}
+
+	if _, err := detector.LoadPatterns([]byte(`{"version": 99, "patterns": {}}`)); err == nil {
+		t.Fatal("Expected newer pattern set to be refused")
+	}
+}
+
+func TestMempoolGasSpike(t *testing.T) {
+	monitor := NewMempoolMonitor(3)
+	start := time.Unix(1700000000, 0)
+
+	// Steady flow of one 10 gwei PHT per second
+	for i := 0; i < 30; i++ {
+		monitor.Observe(big.NewInt(10000000000), start.Add(time.Duration(i)*time.Second))
+	}
+	if stress := monitor.Stress(start.Add(29 * time.Second)); stress.Anomaly || stress.Stress != 0 {
+		t.Fatalf("Steady flow reported as stress: %+v", stress)
+	}
+
+	// Burst of high-fee PHTs in a single second
+	burst := start.Add(30 * time.Second)
+	for i := 0; i < 10; i++ {
+		monitor.Observe(big.NewInt(50000000000), burst)
+	}
+	stress := monitor.Stress(burst)
+	if !stress.Anomaly || stress.Stress != 1 {
+		t.Fatalf("Expected spike to be flagged, got %+v", stress)
+	}
+	if stress.GasRatio < 3 || stress.VolumeRatio < 3 {
+		t.Fatalf("Expected gas and volume ratios above spike factor, got %+v", stress)
+	}
+}
+
+func TestGovernanceRateLimits(t *testing.T) {
+	guard := NewGovernanceGuard(nil)
+	current := DefaultP2SConfig()
+
+	proposed := *current
+	proposed.MinMEVScore = current.MinMEVScore + 0.2
+	if err := guard.Apply(10, current, &proposed); !errors.Is(err, ErrParameterChangeTooFast) {
+		t.Fatalf("Expected abrupt MinMEVScore change to be rejected, got %v", err)
+	}
+
+	proposed.MinMEVScore = current.MinMEVScore + 0.05
+	if err := guard.Apply(10, current, &proposed); err != nil {
+		t.Fatalf("Expected small MinMEVScore change to be accepted: %v", err)
+	}
+
+	// A second change in the same epoch is rejected, allowances accumulate afterwards
+	next := proposed
+	next.MinMEVScore += 0.01
+	if err := guard.Validate(10, &proposed, &next); !errors.Is(err, ErrParameterChangeTooFast) {
+		t.Fatalf("Expected repeated change within an epoch to be rejected, got %v", err)
+	}
+	next.MinMEVScore = proposed.MinMEVScore + 0.15
+	if err := guard.Validate(13, &proposed, &next); err != nil {
+		t.Fatalf("Expected change within accumulated allowance to be accepted: %v", err)
+	}
+
+	// Reveal window may move at most 10% per epoch
+	reveal := *current
+	reveal.B2BlockTime = current.B2BlockTime * 2
+	if err := guard.Validate(10, current, &reveal); !errors.Is(err, ErrParameterChangeTooFast) {
+		t.Fatalf("Expected doubling the reveal window to be rejected, got %v", err)
+	}
+}
+
+func TestDetectionCoverage(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	pht := &PHTTransaction{
+		GasPrice: big.NewInt(1000000000),
+		Value:    big.NewInt(1000),
+		CallData: []byte{},
+	}
+
+	coverage := detector.AnalyzeMEVRisk(pht).Coverage
+	if coverage == nil || coverage.InputCompleteness != 1 {
+		t.Fatalf("Expected complete inputs, got %+v", coverage)
+	}
+	for _, module := range []string{ModuleSimulation, ModuleProfit, ModuleCompositeRules, ModuleMempoolStress} {
+		if coverage.ModulesSkipped[module] == "" {
+			t.Fatalf("Expected module %s to be skipped, got %+v", module, coverage)
+		}
+	}
+
+	detector.SetMarketData(&staticMarketData{reserve: big.NewInt(1000)})
+	blockCoverage := detector.Coverage([]*PHTTransaction{pht, {GasPrice: big.NewInt(1)}})
+	if _, skipped := blockCoverage.ModulesSkipped[ModuleProfit]; skipped {
+		t.Fatal("Expected profit estimation to run with market data")
+	}
+	if blockCoverage.ModulesSkipped[ModuleMempoolStress] != "baseline warming up" {
+		t.Fatalf("Expected mempool stress to be warming up, got %+v", blockCoverage.ModulesSkipped)
+	}
+	if blockCoverage.InputCompleteness != 0.5 {
+		t.Fatalf("Expected input completeness 0.5, got %f", blockCoverage.InputCompleteness)
+	}
+
+	// Coverage is carried into MEV reports per block
+	history := NewMEVHistory(0)
+	history.RecordBlock(&B1Block{PHTs: []*PHTTransaction{pht}, Coverage: blockCoverage}, 7, common.Hash{0x07},
+		func(*PHTTransaction) (float64, []string) { return 1, nil })
+	key, _ := crypto.GenerateKey()
+	report, err := NewMEVReportExporter(history, key).Generate(0, 10, 10)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+	if len(report.Coverage) != 1 || report.Coverage[0].BlockNumber != 7 || report.SkippedModules[ModuleMempoolStress] != 1 {
+		t.Fatalf("Expected block coverage in report, got %+v", report.Coverage)
+	}
+}
+
+func TestLiquidationDecoding(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	pool := common.HexToAddress("0x87870Bca3F3fD6335C3F4ce8392D69350B4fA4E2")
+	collateral := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	debt := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	borrower := common.HexToAddress("0x00000000000000000000000000000000000b0b0b")
+
+	callData := append([]byte{0x00, 0xa7, 0x18, 0xa9}, common.LeftPadBytes(collateral.Bytes(), 32)...)
+	callData = append(callData, common.LeftPadBytes(debt.Bytes(), 32)...)
+	callData = append(callData, common.LeftPadBytes(borrower.Bytes(), 32)...)
+	callData = append(callData, common.LeftPadBytes(big.NewInt(5000).Bytes(), 32)...)
+	callData = append(callData, make([]byte, 32)...)
+
+	analysis := detector.AnalyzeMEVRisk(&PHTTransaction{
+		Recipient: pool,
+		GasPrice:  big.NewInt(1000000000),
+		Value:     big.NewInt(0),
+		CallData:  callData,
+	})
+	liquidation := analysis.Liquidation
+	if liquidation == nil || liquidation.Protocol != ProtocolAave {
+		t.Fatalf("Expected Aave liquidation, got %+v", liquidation)
+	}
+	if liquidation.Borrower != borrower || liquidation.CollateralAsset != collateral || liquidation.DebtAsset != debt {
+		t.Fatalf("Unexpected liquidated position %+v", liquidation)
+	}
+	if liquidation.RepayAmount.Cmp(big.NewInt(5000)) != 0 {
+		t.Fatalf("Expected repay amount 5000, got %v", liquidation.RepayAmount)
+	}
+	found := false
+	for _, attack := range analysis.DetectedAttacks {
+		found = found || attack == "liquidation"
+	}
+	if !found {
+		t.Fatalf("Expected liquidation to be detected, got %v", analysis.DetectedAttacks)
+	}
+
+	// Maker bark identifies the vault by ilk and urn
+	ilk := make([]byte, 32)
+	copy(ilk, "ETH-A")
+	bark := append([]byte{0xed, 0x99, 0x89, 0x08}, ilk...)
+	bark = append(bark, common.LeftPadBytes(borrower.Bytes(), 32)...)
+	bark = append(bark, make([]byte, 32)...)
+	liquidation = detector.AnalyzeMEVRisk(&PHTTransaction{CallData: bark}).Liquidation
+	if liquidation == nil || liquidation.Protocol != ProtocolMaker || liquidation.Ilk != "ETH-A" || liquidation.Borrower != borrower {
+		t.Fatalf("Expected Maker liquidation of ETH-A vault, got %+v", liquidation)
+	}
+	if liquidation.RepayAmount != nil {
+		t.Fatal("Maker liquidations seize the whole vault")
+	}
+
+	// Plain token transfers are no longer mistaken for liquidations
+	if detector.AnalyzeMEVRisk(erc20Transfer(collateral, borrower, pool, 1)).Liquidation != nil {
+		t.Fatal("Transfer decoded as liquidation")
+	}
+}
+
+func TestSenderReputation(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	attacker := common.HexToAddress("0x0000000000000000000000000000000000000a11")
+	fresh := common.HexToAddress("0x0000000000000000000000000000000000000b22")
+
+	// High gas price triggers the sandwich heuristic
+	attack := func(sender common.Address) *PHTTransaction {
+		return &PHTTransaction{
+			Sender:   sender,
+			GasPrice: big.NewInt(50000000000),
+			Value:    big.NewInt(0),
+			CallData: []byte{},
+		}
+	}
+
+	for block := uint64(1); block <= 3; block++ {
+		detector.RecordSenders(block, []*PHTTransaction{attack(attacker)})
+	}
+	reputation := detector.GetSenderReputation(attacker)
+	if reputation.Detections != 3 || !reputation.RepeatOffender || reputation.LastDetection != 3 {
+		t.Fatalf("Expected repeat offender with 3 detections, got %+v", reputation)
+	}
+
+	// Funding a fresh address links it to the attacker's cluster
+	detector.RecordSenders(4, []*PHTTransaction{{
+		Sender:    attacker,
+		Recipient: fresh,
+		GasPrice:  big.NewInt(1000000000),
+		Value:     big.NewInt(1),
+		CallData:  []byte{},
+	}})
+	reputation = detector.GetSenderReputation(fresh)
+	if !reputation.RepeatOffender || len(reputation.Cluster) != 2 {
+		t.Fatalf("Expected funded address to inherit reputation, got %+v", reputation)
+	}
+
+	// Merged clusters add up their offenses, ordered by address
+	other := common.HexToAddress("0x0000000000000000000000000000000000000c33")
+	detector.RecordSenders(4, []*PHTTransaction{attack(other), {
+		Sender:    other,
+		Recipient: fresh,
+		GasPrice:  big.NewInt(1000000000),
+		Value:     big.NewInt(1),
+		CallData:  []byte{},
+	}})
+	merged := detector.GetSenderReputation(other)
+	if len(merged.Cluster) != 3 || merged.Cluster[0] != attacker || merged.Cluster[2] != other {
+		t.Fatalf("Expected the funder to join the cluster, got %v", merged.Cluster)
+	}
+	if math.Abs(merged.Offenses-reputation.Offenses-1) > 1e-9 || detector.GetSenderReputation(attacker).Offenses != merged.Offenses {
+		t.Fatalf("Expected the cluster to add the funder's offense to %f, got %f", reputation.Offenses, merged.Offenses)
+	}
+
+	clean := &PHTTransaction{Sender: fresh, GasPrice: big.NewInt(1000000000), Value: big.NewInt(0), CallData: []byte{}}
+	found := false
+	for _, factor := range detector.AnalyzeMEVRisk(clean).Factors {
+		found = found || factor.Rule == "repeat_offender"
+	}
+	if !found {
+		t.Fatal("Expected repeat offender escalation")
+	}
+
+	// Offenses decay with a half-life in blocks
+	detector.RecordSenders(4+7200, []*PHTTransaction{clean})
+	if detector.GetSenderReputation(attacker).RepeatOffender {
+		t.Fatal("Expected reputation to decay")
+	}
+	if detector.GetSenderReputation(common.Address{0x01}).Detections != 0 {
+		t.Fatal("Unknown sender should have a clean reputation")
+	}
+}
+
+// cryptoPathFiles matches the package sources, any of which may compare
+// secret-derived bytes and so must do it in constant time
+const cryptoPathFiles = "*.go"
+
+// nonSecretComparisons allowlists comparisons of public data by file, matched
+// against the comparing line. Every entry must still match a line.
+var nonSecretComparisons = map[string][]string{
+	// Registered and keystore public keys
+	"bls_signature.go":    {"bytes.Equal(registered.BLSPublicKey, publicKey)"},
+	"validator_key.go":    {"!bytes.Equal(metadata.BLSPublicKey, blsKey.PublicKey())"},
+	"validator_signer.go": {"!bytes.Equal(key.PublicKey(), file.PublicKey)", "!bytes.Equal(validator.BLSPublicKey, publicKey)"},
+	// Function selectors of public call data
+	"mev_liquidation.go": {
+		"bytes.Equal(selector, aaveLiquidationSelector)",
+		"bytes.Equal(selector, compoundLiquidateSelector)",
+		"bytes.Equal(selector, compoundLiquidateEtherSelector)",
+		"bytes.Equal(selector, makerBarkSelector)",
+		"bytes.Equal(selector, makerBiteSelector)",
+	},
+	"mev_wash_trading.go": {"bytes.Equal(data[:4], transferSelector)", "bytes.Equal(data[:4], transferFromSelector)"},
+	"reveal_index.go":     {"return bytes.Equal(selector, transferSelector) || bytes.Equal(selector, transferFromSelector) || bytes.Equal(selector, approveSelector)"},
+}
+
+var nonConstantTimeComparison = regexp.MustCompile(`string\([^)]*\)\s*[!=]=\s*string\(|bytes\.Equal\(|reflect\.DeepEqual\(`)
+
+func TestCryptoPathsUseConstantTimeComparison(t *testing.T) {
+	// The suite may run from this directory or alongside the package sources
+	var dir string
+	for _, candidate := range []string{".", filepath.Join("..", "..", "consensus", "p2s")} {
+		if _, err := os.Stat(filepath.Join(candidate, "constant_time.go")); err == nil {
+			dir = candidate
+			break
+		}
+	}
+	if dir == "" {
+		t.Skip("P2S consensus sources not found")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, cryptoPathFiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	used := make(map[string]bool)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		for i, line := range bytes.Split(source, []byte("\n")) {
+			if !nonConstantTimeComparison.Match(line) {
+				continue
+			}
+			allowed := false
+			for _, entry := range nonSecretComparisons[name] {
+				if bytes.Contains(line, []byte(entry)) {
+					used[name+": "+entry], allowed = true, true
+				}
+			}
+			if !allowed {
+				t.Errorf("%s:%d: non-constant-time comparison: %s", name, i+1, bytes.TrimSpace(line))
+			}
+		}
+	}
+	for name, entries := range nonSecretComparisons {
+		for _, entry := range entries {
+			if !used[name+": "+entry] {
+				t.Errorf("%s: stale non-secret comparison allowlist entry: %s", name, entry)
+			}
+		}
+	}
+
+	if !constantTimeEqual([]byte{1, 2, 3}, []byte{1, 2, 3}) || constantTimeEqual([]byte{1, 2, 3}, []byte{1, 2, 4}) {
+		t.Fatal("constantTimeEqual returned wrong result")
+	}
+	if constantTimeEqual([]byte{1, 2}, []byte{1, 2, 3}) {
+		t.Fatal("Commitments of different length must not match")
+	}
+}
+
+func TestCrossDomainMEV(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	searcher := common.HexToAddress("0x0000000000000000000000000000000000000c01")
+	beneficiary := common.HexToAddress("0x0000000000000000000000000000000000000c02")
+	bridge := common.HexToAddress("0x99C9fc46f92E8a1c0deC1b1747d010903E884bE1")
+
+	swap := func(sender common.Address, hash byte) *PHTTransaction {
+		return &PHTTransaction{
+			Sender:   sender,
+			GasPrice: big.NewInt(1000000000),
+			Value:    big.NewInt(0),
+			CallData: append([]byte{0x38, 0xed, 0x17, 0x39}, make([]byte, 32)...),
+			TxHash:   common.Hash{hash},
+		}
+	}
+	// depositETHTo(address,uint32,bytes) sends the bridged funds to beneficiary
+	deposit := &PHTTransaction{
+		Sender:    searcher,
+		Recipient: bridge,
+		GasPrice:  big.NewInt(1000000000),
+		Value:     big.NewInt(1000),
+		CallData:  append([]byte{0x9a, 0x2a, 0xc6, 0xd5}, common.LeftPadBytes(beneficiary.Bytes(), 32)...),
+		TxHash:    common.Hash{0x01},
+	}
+
+	findings := detector.DetectCrossDomainMEV([]*PHTTransaction{deposit, swap(beneficiary, 0x02), swap(common.Address{0x09}, 0x03)})
+	if len(findings) != 1 {
+		t.Fatalf("Expected one cross-domain finding, got %d", len(findings))
+	}
+	finding := findings[0]
+	if finding.Attack != "cross_domain_mev" || finding.Bridge != deposit.TxHash || finding.Swap != (common.Hash{0x02}) ||
+		finding.Account != beneficiary || finding.Call.Protocol != "optimism" || finding.Call.Operation != BridgeDeposit {
+		t.Fatalf("Unexpected finding %+v", finding)
+	}
+
+	// The finding lowers the block score and is reported as an attack
+	baseline, _ := detector.DetectMEV([]*PHTTransaction{deposit, swap(common.Address{0x09}, 0x03)})
+	score, attacks := detector.DetectMEV([]*PHTTransaction{deposit, swap(beneficiary, 0x02)})
+	found := false
+	for _, attack := range attacks {
+		found = found || attack == "cross_domain_mev"
+	}
+	if !found || score >= baseline {
+		t.Fatalf("Expected cross-domain MEV to lower score below %f, got %f %v", baseline, score, attacks)
+	}
+	if detector.GetAttackPattern("cross_domain_mev") == nil {
+		t.Fatal("Expected cross-domain pattern to be registered")
+	}
+
+	// Bridge calls without a related swap are not flagged
+	if findings := detector.DetectCrossDomainMEV([]*PHTTransaction{deposit}); len(findings) != 0 {
+		t.Fatalf("Expected no findings without swaps, got %d", len(findings))
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	calls := make(map[string]int)
+	alerts := 0
+	hooks := &WatchdogHooks{
+		RebuildFromPool: func() error { calls["rebuild_from_pool"]++; return nil },
+		RequestReveals:  func() error { calls["request_reveals"]++; return errors.New("no peers") },
+		RotateProposer:  func() error { calls["rotate_proposer"]++; return nil },
+		Alert:           func(*StallReport) { alerts++ },
+	}
+	watchdog := NewWatchdog(2, hooks)
+
+	// A fresh watchdog only sets its baseline, however late the first slot
+	if report := watchdog.Check(100); report != nil {
+		t.Fatalf("Expected no stall on the first check, got %+v", report)
+	}
+
+	// Blocks are recorded by height, which need not match the slot
+	watchdog.RecordB1(5)
+	watchdog.RecordB2(5)
+	if report := watchdog.Check(101); report != nil {
+		t.Fatalf("Expected no stall after progress, got %+v", report)
+	}
+	if report := watchdog.Check(103); report != nil {
+		t.Fatalf("Expected no stall within the allowed slots, got %+v", report)
+	}
+	report := watchdog.Check(104)
+	if report == nil || !report.StalledB1 || !report.StalledB2 || report.LastB1Slot != 101 || report.B1Height != 5 {
+		t.Fatalf("Expected both block types to stall, got %+v", report)
+	}
+	if report.Recovery["rebuild_from_pool"] != "ok" || !strings.HasPrefix(report.Recovery["request_reveals"], "failed") {
+		t.Fatalf("Expected recovery outcomes, got %v", report.Recovery)
+	}
+
+	// Recovery actions back off while the stall lasts
+	expected := []struct {
+		slot  uint64
+		due   bool
+		calls int
+	}{{105, true, 2}, {106, false, 2}, {107, true, 3}, {110, false, 3}, {111, true, 4}}
+	for _, step := range expected {
+		report := watchdog.Check(step.slot)
+		if calls["rotate_proposer"] != step.calls {
+			t.Fatalf("Expected %d proposer rotations at slot %d, got %d", step.calls, step.slot, calls["rotate_proposer"])
+		}
+		if due := report.Recovery["rotate_proposer"] != "backoff"; due != step.due {
+			t.Fatalf("Expected recovery due %v at slot %d, got %v", step.due, step.slot, report.Recovery)
+		}
+	}
+	if alerts != 6 {
+		t.Fatalf("Expected an alert per stalled check, got %d", alerts)
+	}
+
+	// Progress of one block type ends its stall and resets its recovery
+	watchdog.RecordB1(6)
+	report = watchdog.Check(112)
+	if report == nil || report.StalledB1 || !report.StalledB2 {
+		t.Fatalf("Expected only B2 to stall, got %+v", report)
+	}
+	if _, attempted := report.Recovery["rebuild_from_pool"]; attempted {
+		t.Fatal("Expected no pool rebuild once B1 blocks progress")
+	}
+	watchdog.RecordB2(6)
+	if report := watchdog.Check(113); report != nil {
+		t.Fatalf("Expected the stall to end, got %+v", report)
+	}
+	rotations := calls["rotate_proposer"]
+	if report := watchdog.Check(116); report == nil || report.Recovery["rotate_proposer"] != "ok" || calls["rotate_proposer"] != rotations+1 {
+		t.Fatalf("Expected a new stall to recover without backoff, got %+v", report)
+	}
+
+	// A slot clock running behind the baseline starts it over
+	if report := watchdog.Check(10); report != nil {
+		t.Fatalf("Expected a rewound slot clock to reset the baseline, got %+v", report)
+	}
+	if watchdog.LastReport() == nil || watchdog.LastReport().Slot != 116 {
+		t.Fatal("Expected the last stall report to be retained")
+	}
+}
+
+func TestMaintenanceModeHandoff(t *testing.T) {
+	config := DefaultP2SConfig()
+	validators := NewValidatorManager(config)
+	operator := common.HexToAddress("0x0000000000000000000000000000000000000d01")
+	for _, address := range []common.Address{operator, {0xd2}, {0xd3}, {0xd4}} {
+		if err := validators.AddValidator(address, config.MinStake); err != nil {
+			t.Fatalf("Failed to add validator: %v", err)
+		}
+	}
+
+	// The ladder is deterministic and never includes the operator
+	ladder := validators.FallbackLadder(12, operator, 0)
+	if len(ladder) != 3 {
+		t.Fatalf("Expected 3 fallback proposers, got %d", len(ladder))
+	}
+	for i, address := range validators.FallbackLadder(12, operator, 0) {
+		if address == operator || address != ladder[i] {
+			t.Fatalf("Unexpected ladder %v", ladder)
+		}
+	}
+
+	// The first rung refuses slot 12, so the duty falls to the second
+	inFlight := 2
+	hooks := &MaintenanceHooks{
+		ProposalDuties: func(validator common.Address, from, to uint64) []uint64 {
+			return []uint64{12, 14, to + 1}
+		},
+		HandOff: func(slot uint64, fallback common.Address) error {
+			if slot == 12 && fallback == ladder[0] {
+				return errors.New("offline")
+			}
+			return nil
+		},
+		InFlightReveals: func() int { return inFlight },
+	}
+	mode := NewMaintenanceMode(validators, 8, hooks)
+	if !mode.AcceptingDuties() || mode.Status().State != MaintenanceOff {
+		t.Fatal("Expected node to accept duties before maintenance")
+	}
+
+	status, err := mode.Enter(operator, 10)
+	if err != nil {
+		t.Fatalf("Failed to enter maintenance: %v", err)
+	}
+	if mode.AcceptingDuties() {
+		t.Fatal("Node in maintenance must not accept new duties")
+	}
+	if len(status.HandedOff) != 3 || status.HandedOff[12] != ladder[1] || len(status.Unhandled) != 0 {
+		t.Fatalf("Expected all duties handed off, got %+v", status)
+	}
+	if status.State != MaintenanceDraining || status.SafeToShutdown || status.InFlightReveals != 2 {
+		t.Fatalf("Expected node to drain in-flight reveals, got %+v", status)
+	}
+	if _, err := mode.Enter(operator, 11); err == nil {
+		t.Fatal("Expected error entering maintenance twice")
+	}
+
+	// Once reveals drain the node is safe to shut down
+	inFlight = 0
+	if status := mode.Status(); status.State != MaintenanceReady || !status.SafeToShutdown {
+		t.Fatalf("Expected node to be safe to shut down, got %+v", status)
+	}
+
+	mode.Exit()
+	if !mode.AcceptingDuties() {
+		t.Fatal("Expected node to accept duties after maintenance")
+	}
+
+	// Duties that no fallback accepts are reported as unhandled
+	hooks.HandOff = func(uint64, common.Address) error { return errors.New("offline") }
+	status, _ = mode.Enter(operator, 20)
+	if len(status.HandedOff) != 0 || len(status.Unhandled) != 3 {
+		t.Fatalf("Expected unhandled duties, got %+v", status)
+	}
+}
+
+type staticStateReader struct {
+	code map[common.Address][]byte
+}
+
+func (s *staticStateReader) GetBalance(common.Address) *uint256.Int { return uint256.NewInt(0) }
+
+func (s *staticStateReader) GetNonce(common.Address) uint64 { return 0 }
+
+func (s *staticStateReader) GetCode(address common.Address) []byte { return s.code[address] }
+
+func TestAnalysisContext(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	contract := common.HexToAddress("0x0000000000000000000000000000000000000e01")
+	eoa := common.HexToAddress("0x0000000000000000000000000000000000000e02")
+	pht := &PHTTransaction{
+		Recipient: eoa,
+		GasPrice:  big.NewInt(50000000000), // 50 gwei
+		Value:     big.NewInt(0),
+		CallData:  []byte{0x01, 0x02, 0x03, 0x04},
+	}
+
+	fired := func(analysis *MEVAnalysis, rule string) bool {
+		for _, factor := range analysis.Factors {
+			if factor.Rule == rule {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without chain state the raw gas price looks like a sandwich
+	withoutContext := detector.AnalyzeMEVRisk(pht)
+	if !fired(withoutContext, "sandwich_attack") || !fired(withoutContext, "contract_interaction") {
+		t.Fatalf("Expected stateless rules to fire, got %+v", withoutContext.Factors)
+	}
+
+	// A 45 gwei base fee leaves only a 5 gwei tip, and the recipient has no code
+	header := &types.Header{Number: big.NewInt(100), BaseFee: big.NewInt(45000000000)}
+	actx := NewAnalysisContext(nil, header, &staticStateReader{code: map[common.Address][]byte{contract: {0x60}}})
+	if actx.BlockNumber != 100 || actx.BaseFee.Cmp(header.BaseFee) != 0 || len(actx.RecentBlocks) != 0 {
+		t.Fatalf("Unexpected analysis context %+v", actx)
+	}
+	withContext := detector.AnalyzeMEVRiskWithContext(actx, pht)
+	if fired(withContext, "sandwich_attack") || fired(withContext, "contract_interaction") {
+		t.Fatalf("Expected state-aware rules not to fire, got %+v", withContext.Factors)
+	}
+	if withContext.Score <= withoutContext.Score {
+		t.Fatalf("Expected higher score with context, got %f <= %f", withContext.Score, withoutContext.Score)
+	}
+
+	pht.Recipient = contract
+	if !fired(detector.AnalyzeMEVRiskWithContext(actx, pht), "contract_interaction") {
+		t.Fatal("Expected call to contract to be flagged")
+	}
+
+	score, _ := detector.DetectMEVWithContext(actx, []*PHTTransaction{pht})
+	parallelScore, _, err := detector.DetectMEVParallelWithContext(context.Background(), actx, []*PHTTransaction{pht})
+	if err != nil || score != parallelScore {
+		t.Fatalf("Expected parallel detection to match, got %f and %f (%v)", score, parallelScore, err)
+	}
+}
+
+func TestMEVHistoryReplication(t *testing.T) {
+	leader := NewMEVHistory(0)
+	record := func(number uint64) {
+		block := &B1Block{PHTs: []*PHTTransaction{{TxHash: common.Hash{byte(number)}}}, Coverage: &DetectionCoverage{Transactions: 1}}
+		leader.RecordBlock(block, number, common.Hash{byte(number)}, func(*PHTTransaction) (float64, []string) {
+			return 0.5, []string{"front_running"}
+		})
+	}
+	for number := uint64(1); number <= 3; number++ {
+		record(number)
+	}
+
+	// In-process replica
+	replica := NewMEVHistoryReplica(leader, 0)
+	applied, err := replica.Sync()
+	if err != nil || applied != 3 || replica.Seq() != 3 {
+		t.Fatalf("Expected 3 entries applied, got %d (%v)", applied, err)
+	}
+	if len(replica.Records(0, 10)) != 3 || len(replica.Coverage(0, 10)) != 3 {
+		t.Fatal("Replica does not match leader")
+	}
+	if applied, _ := replica.Sync(); applied != 0 {
+		t.Fatalf("Expected no new entries, got %d", applied)
+	}
+
+	// Remote replica over HTTP log shipping; report generation reads only the replica
+	server := httptest.NewServer(NewReplicationHandler(leader))
+	defer server.Close()
+	remote := NewMEVHistoryReplica(&HTTPReplicationSource{Endpoint: server.URL}, 0)
+	record(4)
+	if applied, err := remote.Sync(); err != nil || applied != 4 {
+		t.Fatalf("Expected 4 entries shipped over HTTP, got %d (%v)", applied, err)
+	}
+	key, _ := crypto.GenerateKey()
+	report, err := NewMEVReportExporter(remote, key).Generate(0, 10, 10)
+	if err != nil || report.Transactions != 4 {
+		t.Fatalf("Expected report from replica, got %+v (%v)", report, err)
+	}
+
+	// A replica behind the retained log restores from a snapshot
+	for number := uint64(5); number <= 1100; number++ {
+		record(number)
+	}
+	if _, err := leader.Entries(4, 10); !errors.Is(err, ErrReplicationGap) {
+		t.Fatalf("Expected replication gap, got %v", err)
+	}
+	if _, err := remote.Sync(); err != nil || remote.Seq() != leader.Seq() {
+		t.Fatalf("Expected replica to catch up from snapshot, got seq %d (%v)", remote.Seq(), err)
+	}
+	if len(remote.Records(0, 2000)) != len(leader.Records(0, 2000)) {
+		t.Fatal("Replica records differ from leader after snapshot restore")
+	}
+}
+
+// recordingTreasury records bounty payouts
+type recordingTreasury struct {
+	paid map[common.Address]*big.Int
+}
+
+func (r *recordingTreasury) PayBounty(reporter common.Address, amount *big.Int, claim common.Hash) error {
+	r.paid[reporter] = new(big.Int).Set(amount)
+	return nil
+}
+
+func TestBountyClaims(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	corpus := NewCalibrationCorpus()
+	treasury := &recordingTreasury{paid: make(map[common.Address]*big.Int)}
+	board := NewBountyBoard(detector, corpus, treasury, big.NewInt(1000))
+
+	b1Block := &B1Block{Header: &types.Header{Number: big.NewInt(42)}, DetectedAttacks: []string{"front_running"}}
+	b2Block := &B2Block{}
+	for i := 1; i <= 3; i++ {
+		pht := &PHTTransaction{TxHash: common.Hash{byte(i)}, Sender: common.Address{byte(i)}, GasPrice: big.NewInt(int64(i)), Commitment: []byte{byte(i)}}
+		b1Block.PHTs = append(b1Block.PHTs, pht)
+		b2Block.MTs = append(b2Block.MTs, &MTTransaction{PHTHash: pht.Hash()})
+	}
+	verify := func(mt *MTTransaction, pht *PHTTransaction) error { return nil }
+
+	key, _ := crypto.GenerateKey()
+	packet := &EvidencePacket{
+		B1Hash:       common.Hash{0xb1},
+		Attack:       "sandwich_attack",
+		Transactions: []common.Hash{{1}, {2}, {3}},
+		Description:  "victim swap sandwiched by the first and last transactions",
+	}
+	if err := packet.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+
+	claim, err := board.Submit(packet, b1Block, b2Block, verify)
+	if err != nil {
+		t.Fatalf("Expected claim to be accepted, got %v", err)
+	}
+	if !claim.Paid || claim.BlockNumber != 42 || treasury.paid[packet.Reporter].Int64() != 1000 {
+		t.Fatalf("Expected reporter to be paid, got %+v", claim)
+	}
+	if detector.GetDetectionMetrics()["sandwich_attack"].Misses != 1 {
+		t.Fatal("Expected a recorded detection miss")
+	}
+	blocks, labels := corpus.Data()
+	if len(blocks) != 1 || len(labels) != 3 || !labels[common.Hash{2}] {
+		t.Fatalf("Expected labelled case in calibration corpus, got %d blocks and %d labels", len(blocks), len(labels))
+	}
+
+	// The same attack cannot be claimed twice, even by another reporter
+	other, _ := crypto.GenerateKey()
+	duplicate := &EvidencePacket{B1Hash: packet.B1Hash, Attack: packet.Attack, Transactions: []common.Hash{{3}, {2}, {1}}}
+	duplicate.Sign(other)
+	if _, err := board.Submit(duplicate, b1Block, b2Block, verify); !errors.Is(err, ErrDuplicateClaim) {
+		t.Fatalf("Expected duplicate claim, got %v", err)
+	}
+
+	// Attacks flagged when the block was built are not eligible
+	detected := &EvidencePacket{B1Hash: packet.B1Hash, Attack: "front_running", Transactions: []common.Hash{{1}}}
+	detected.Sign(key)
+	if _, err := board.Submit(detected, b1Block, b2Block, verify); !errors.Is(err, ErrAttackAlreadyDetected) {
+		t.Fatalf("Expected already detected attack, got %v", err)
+	}
+
+	// Evidence must hold up against the stored reveals
+	unknown := &EvidencePacket{B1Hash: packet.B1Hash, Attack: "back_running", Transactions: []common.Hash{{9}}}
+	unknown.Sign(key)
+	if _, err := board.Submit(unknown, b1Block, b2Block, verify); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected invalid evidence for unknown transaction, got %v", err)
+	}
+	unknown.Transactions = []common.Hash{{1}}
+	unknown.Signature[0] ^= 0xff
+	if _, err := board.Submit(unknown, b1Block, b2Block, verify); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected invalid evidence for bad signature, got %v", err)
+	}
+}
+
+func TestConfigurableRiskBands(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+	pht := &PHTTransaction{GasPrice: big.NewInt(1000000000), Timestamp: uint64(time.Now().Unix())}
+	score := detector.AnalyzeMEVRisk(pht).Score
+
+	for _, bands := range []RiskBands{
+		{Low: 0.6, Medium: 0.8, High: 0.4},
+		{Low: 1.2, Medium: 0.6, High: 0.4},
+		{Low: 0.8, Medium: 0.6, High: 0.6},
+	} {
+		if err := detector.SetRiskBands(bands); !errors.Is(err, ErrInvalidRiskBands) {
+			t.Fatalf("Expected invalid bands %+v to be rejected, got %v", bands, err)
+		}
+	}
+	if detector.GetRiskBands() != DefaultRiskBands() {
+		t.Fatal("Rejected bands must not be applied")
+	}
+
+	// Bands above the score move it into the critical level
+	if err := detector.SetRiskBands(RiskBands{Low: 1, Medium: score + (1-score)/2, High: score + (1-score)/4}); err != nil {
+		t.Fatal(err)
+	}
+	if score < 1 && detector.AnalyzeMEVRisk(pht).RiskLevel != "critical" {
+		t.Fatalf("Expected critical risk for score %f", score)
+	}
+
+	// Runtime updates emit a config change event
+	consensus := newTestConsensus(t, DefaultConfig())
+	events := make(chan ConfigChangeEvent, 1)
+	sub := consensus.SubscribeConfigChanges(events)
+	defer sub.Unsubscribe()
+
+	updated := RiskBands{Low: 0.9, Medium: 0.7, High: 0.5}
+	if err := consensus.SetRiskBands(updated); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Parameter != "RiskBands" || ev.Old != DefaultRiskBands() || ev.New != updated {
+			t.Fatalf("Unexpected config change event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a config change event")
+	}
+	if consensus.GetRiskBands() != updated || consensus.GetConfig().RiskBands != updated {
+		t.Fatal("Expected updated risk bands")
+	}
+	if err := consensus.SetRiskBands(RiskBands{}); !errors.Is(err, ErrInvalidRiskBands) {
+		t.Fatalf("Expected invalid bands to be rejected, got %v", err)
+	}
+}
+
+func TestMEVTestVectors(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	// Built-in corpus run in CI
+	report, err := detector.RunVectors(filepath.Join("testdata", "mev_vectors"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total == 0 {
+		t.Fatal("Expected test vectors in corpus")
+	}
+	for _, result := range report.Results {
+		if !result.Passed {
+			t.Errorf("Vector %s/%s failed: %v", result.File, result.Name, result.Failures)
+		}
+	}
+
+	// Vectors generated from live detections replay against the same rule set
+	block := &B1Block{
+		Header: &types.Header{Number: big.NewInt(7)},
+		PHTs: []*PHTTransaction{
+			{TxHash: common.Hash{1}, GasPrice: big.NewInt(60000000000), Value: big.NewInt(0)},
+			{TxHash: common.Hash{2}, GasPrice: big.NewInt(1000000000), Value: big.NewInt(0)},
+		},
+	}
+	block.MEVScore, block.DetectedAttacks = detector.DetectMEV(block.PHTs)
+	vector := VectorFromBlock("live", block)
+
+	dir := t.TempDir()
+	if err := WriteVectorFile(filepath.Join(dir, "live.json"), vector); err != nil {
+		t.Fatal(err)
+	}
+	report, err = detector.RunVectors(dir)
+	if err != nil || !report.OK() || report.Total != 1 {
+		t.Fatalf("Expected generated vector to pass, got %+v (%v)", report, err)
+	}
+
+	// A wrong expectation is reported as a failure
+	vector.Expect.Attacks = append(vector.Expect.Attacks, "liquidation")
+	if err := WriteVectorFile(filepath.Join(dir, "live.json"), vector); err != nil {
+		t.Fatal(err)
+	}
+	report, err = detector.RunVectors(dir)
+	if err != nil || report.OK() || len(report.Results[0].Failures) != 1 {
+		t.Fatalf("Expected a single failure, got %+v (%v)", report, err)
+	}
+}
+
+func TestEmergencyHalt(t *testing.T) {
+	validators := NewValidatorManager(DefaultP2SConfig())
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		if err := validators.AddValidator(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000000000000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vote := func(key *ecdsa.PrivateKey, action string, height uint64) *HaltMessage {
+		msg := &HaltMessage{Action: action, Height: height, Reason: "incident", Signer: crypto.PubkeyToAddress(key.PublicKey)}
+		msg.Signature, _ = crypto.Sign(msg.SigningHash().Bytes(), key)
+		return msg
+	}
+
+	db := memorydb.New()
+	halt := NewEmergencyHalt(validators, nil, 0)
+	if err := halt.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two thirds of stake is not a supermajority
+	for _, key := range keys[:2] {
+		if done, err := halt.Submit(vote(key, HaltActionHalt, 100)); err != nil || done {
+			t.Fatalf("Expected vote to be pending, got %v (%v)", done, err)
+		}
+	}
+	outsider, _ := crypto.GenerateKey()
+	if _, err := halt.Submit(vote(outsider, HaltActionHalt, 100)); !errors.Is(err, ErrInvalidHaltMessage) {
+		t.Fatalf("Expected non-validator vote to be rejected, got %v", err)
+	}
+	forged := vote(keys[2], HaltActionHalt, 100)
+	forged.Height = 101
+	if _, err := halt.Submit(forged); !errors.Is(err, ErrInvalidHaltMessage) {
+		t.Fatalf("Expected forged vote to be rejected, got %v", err)
+	}
+	if done, err := halt.Submit(vote(keys[2], HaltActionHalt, 100)); err != nil || !done {
+		t.Fatalf("Expected halt to reach quorum, got %v (%v)", done, err)
+	}
+	if halt.Halted(99) || !halt.Halted(100) || !halt.Halted(150) {
+		t.Fatal("Expected pairs to stop at the halt height")
+	}
+
+	// A restarted node stays halted
+	restarted := NewEmergencyHalt(validators, nil, 0)
+	if err := restarted.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.Halted(100) || restarted.Status().Halt.Height != 100 {
+		t.Fatal("Expected persisted halt record to be restored")
+	}
+
+	// Resuming requires its own quorum for the recorded height
+	if _, err := restarted.Submit(vote(keys[0], HaltActionResume, 99)); !errors.Is(err, ErrInvalidHaltMessage) {
+		t.Fatalf("Expected resume for another height to be rejected, got %v", err)
+	}
+	for i, key := range keys {
+		done, err := restarted.Submit(vote(key, HaltActionResume, 100))
+		if err != nil || done != (i == len(keys)-1) {
+			t.Fatalf("Unexpected resume vote result %v (%v)", done, err)
+		}
+	}
+	if restarted.Halted(100) {
+		t.Fatal("Expected chain to resume")
+	}
+	if record, err := ReadHaltRecord(db); err != nil || record != nil {
+		t.Fatalf("Expected halt record to be removed, got %+v (%v)", record, err)
+	}
+
+	// Permissioned networks use an operator quorum
+	operators := NewEmergencyHalt(validators, []common.Address{crypto.PubkeyToAddress(outsider.PublicKey), crypto.PubkeyToAddress(keys[0].PublicKey)}, 2)
+	if _, err := operators.Submit(vote(keys[1], HaltActionHalt, 5)); !errors.Is(err, ErrInvalidHaltMessage) {
+		t.Fatalf("Expected non-operator vote to be rejected, got %v", err)
+	}
+	operators.Submit(vote(outsider, HaltActionHalt, 5))
+	if done, err := operators.Submit(vote(keys[0], HaltActionHalt, 5)); err != nil || !done || !operators.Halted(5) {
+		t.Fatalf("Expected operator quorum to halt, got %v (%v)", done, err)
+	}
+}
+
+// countingScoringService counts scoring calls and optionally stalls them
+type countingScoringService struct {
+	ScoringService
+	batches int32
+	stall   int64 // Nanoseconds each call stalls for
+}
+
+func (s *countingScoringService) Score(ctx context.Context, req *ScoringRequest) (*ScoringResponse, error) {
+	atomic.AddInt32(&s.batches, 1)
+	if stall := time.Duration(atomic.LoadInt64(&s.stall)); stall > 0 {
+		select {
+		case <-time.After(stall):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return s.ScoringService.Score(ctx, req)
+}
+
+func TestRemoteScoring(t *testing.T) {
+	local := NewMEVDetector(DefaultP2SConfig())
+	local.SetStressWeight(0)
+	service := &countingScoringService{ScoringService: NewDetectorScoringService(local)}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterScoringService(server, service)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	phts := make([]*PHTTransaction, 5)
+	for i := range phts {
+		phts[i] = &PHTTransaction{TxHash: common.Hash{byte(i)}, GasPrice: big.NewInt(int64(i+1) * 20000000000), Value: big.NewInt(0)}
+	}
+
+	// Batched remote scoring
+	remote := NewRemoteDetector(conn, local, time.Second, 2)
+	score, attacks, err := remote.DetectMEVParallelWithContext(context.Background(), nil, phts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&service.batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %d", service.batches)
+	}
+	localScore, localAttacks := local.DetectMEV(phts)
+	if math.Abs(score-localScore) > 1e-9 || len(attacks) != len(localAttacks) {
+		t.Fatalf("Expected remote score %f %v to match local %f %v", score, attacks, localScore, localAttacks)
+	}
+	if _, fallbacks := remote.Stats(); fallbacks != 0 {
+		t.Fatalf("Expected no fallbacks, got %d", fallbacks)
+	}
+
+	// A stalled service falls back to the local detector
+	atomic.StoreInt64(&service.stall, int64(time.Second))
+	remote = NewRemoteDetector(conn, local, 50*time.Millisecond, 0)
+	score, _, err = remote.DetectMEVParallelWithContext(context.Background(), nil, phts)
+	if err != nil || math.Abs(score-localScore) > 1e-9 {
+		t.Fatalf("Expected local fallback score %f, got %f (%v)", localScore, score, err)
+	}
+	if calls, fallbacks := remote.Stats(); calls != 1 || fallbacks != 1 {
+		t.Fatalf("Expected a single fallback, got %d calls and %d fallbacks", calls, fallbacks)
+	}
+}
+
+func TestPedersenCommitment(t *testing.T) {
+	scheme := NewPedersenCommitment()
+	data := [][]byte{common.Address{1}.Bytes(), big.NewInt(5).Bytes(), {0xa9, 0x05, 0x9c, 0xbb}, {2}, {8}}
+
+	commitment, blinding, err := scheme.Commit(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commitment) != 33 || len(blinding) != 32 {
+		t.Fatalf("Unexpected commitment or blinding length %d, %d", len(commitment), len(blinding))
+	}
+	if !scheme.Verify(commitment, blinding, data...) {
+		t.Fatal("Expected commitment to open with its blinding factor")
+	}
+
+	// Hiding: the same data commits to a different point under a fresh blinding factor
+	other, otherBlinding, _ := scheme.Commit(data...)
+	if bytes.Equal(commitment, other) || bytes.Equal(blinding, otherBlinding) {
+		t.Fatal("Expected fresh blinding factors to hide the committed data")
+	}
+	if scheme.Verify(commitment, otherBlinding, data...) {
+		t.Fatal("Expected commitment not to open with another blinding factor")
+	}
+	if scheme.Verify(commitment, nil, data...) {
+		t.Fatal("Expected commitment not to open without a blinding factor")
+	}
+
+	// Binding, including against moving bytes between items
+	if scheme.Verify(commitment, blinding, append([][]byte{}, data[0], big.NewInt(6).Bytes(), data[2], data[3], data[4])...) {
+		t.Fatal("Expected commitment not to open to different data")
+	}
+	if scheme.Verify(commitment, blinding, append(append([]byte{}, data[0]...), data[1]...), data[2], data[3], data[4]) {
+		t.Fatal("Expected commitment not to open to re-split data")
+	}
+
+	// MTManager verifies the opening of a revealed PHT
+	pht := &PHTTransaction{Recipient: common.Address{1}, Value: big.NewInt(5), CallData: data[2], TxType: 2, GasLimit: 8, Commitment: commitment, Blinding: blinding}
+	mt := &MTTransaction{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: 2, GasLimit: 8, Blinding: blinding}
+	manager := newTestMTManager(t, DefaultP2SConfig())
+	if err := manager.VerifyOpening(mt, pht); err != nil {
+		t.Fatal(err)
+	}
+	if !newTestPHTManager(t, DefaultP2SConfig()).VerifyCommitment(pht, pht.Recipient, pht.Value, pht.CallData, 2, 8) {
+		t.Fatal("Expected PHT commitment to verify")
+	}
+	mt.Value = big.NewInt(6)
+	if err := manager.VerifyOpening(mt, pht); err == nil {
+		t.Fatal("Expected opening of altered value to fail")
+	}
+}
+
+func TestRevealIndex(t *testing.T) {
+	index := NewRevealIndex(100)
+	router := common.HexToAddress("0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D")
+	token := common.Address{0x70}
+	swap := []byte{0x38, 0xed, 0x17, 0x39}
+	transfer := append([]byte{0xa9, 0x05, 0x9c, 0xbb}, make([]byte, 64)...)
+
+	for number := uint64(1); number <= 200; number++ {
+		b1Block := &B1Block{PHTs: []*PHTTransaction{
+			{TxHash: common.Hash{byte(number), 1}, Sender: common.Address{0x5e}},
+			{TxHash: common.Hash{byte(number), 2}, Sender: common.Address{0x5f}},
+		}}
+		b2Block := &B2Block{
+			BlockHash: common.BigToHash(new(big.Int).SetUint64(number)),
+			MTs: []*MTTransaction{
+				{TxHash: common.Hash{byte(number), 1}, Recipient: router, CallData: swap},
+				{TxHash: common.Hash{byte(number), 2}, Recipient: token, CallData: transfer},
+			},
+		}
+		index.IndexB2Block(number, b2Block, b1Block)
+		index.IndexB2Block(number, b2Block, b1Block) // Re-imports are ignored
+	}
+	if index.Head() != 200 {
+		t.Fatalf("Expected head 200, got %d", index.Head())
+	}
+
+	calls := index.ByRecipient(router, 10)
+	if len(calls) != 10 || calls[0].BlockNumber != 191 || calls[9].BlockNumber != 200 {
+		t.Fatalf("Expected 10 router calls in blocks 191-200, got %d", len(calls))
+	}
+	if calls[0].Sender != (common.Address{0x5e}) || calls[0].Selector != "0x38ed1739" || calls[0].Token != nil {
+		t.Fatalf("Unexpected indexed call %+v", calls[0])
+	}
+	if got := len(index.BySelector([4]byte{0x38, 0xed, 0x17, 0x39}, 0)); got != 100 {
+		t.Fatalf("Expected selector index limited to the 100 block window, got %d", got)
+	}
+	transfers := index.ByToken(token, 5)
+	if len(transfers) != 5 || transfers[0].Token == nil || *transfers[0].Token != token {
+		t.Fatalf("Expected 5 token transfers, got %+v", transfers)
+	}
+	if len(index.ByToken(router, 0)) != 0 {
+		t.Fatal("Expected no token entries for swap calls")
+	}
+}
+
+func TestKZGCommitment(t *testing.T) {
+	scheme := NewKZGCommitment()
+	data := [][]byte{common.Address{1}.Bytes(), big.NewInt(5).Bytes(), bytes.Repeat([]byte{0xab}, 1000), {2}, {8}}
+
+	commitment, blinding, err := scheme.Commit(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commitment) != 48 {
+		t.Fatalf("Expected a 48-byte commitment, got %d bytes", len(commitment))
+	}
+	if !scheme.Verify(commitment, blinding, data...) {
+		t.Fatal("Expected commitment to open with its blinding element")
+	}
+	other, otherBlinding, _ := scheme.Commit(data...)
+	if bytes.Equal(commitment, other) || scheme.Verify(commitment, otherBlinding, data...) {
+		t.Fatal("Expected fresh blinding elements to hide the committed data")
+	}
+	data[2] = bytes.Repeat([]byte{0xac}, 1000)
+	if scheme.Verify(commitment, blinding, data...) {
+		t.Fatal("Expected commitment not to open to different data")
+	}
+	if _, _, err := scheme.Commit(make([]byte, 200*1024)); !errors.Is(err, ErrKZGCapacity) {
+		t.Fatalf("Expected capacity error, got %v", err)
+	}
+
+	// Single items open with constant-size element proofs, bound to their position
+	data[2] = bytes.Repeat([]byte{0xab}, 1000)
+	opening, err := scheme.OpenField(blinding, 1, data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opening.Elements) != 2 {
+		t.Fatalf("Expected the header and one value element, got %d", len(opening.Elements))
+	}
+	if !scheme.VerifyField(commitment, opening, data[1]) {
+		t.Fatal("Expected the value to open on its own")
+	}
+	if scheme.VerifyField(commitment, opening, big.NewInt(6).Bytes()) || scheme.VerifyField(other, opening, data[1]) {
+		t.Fatal("Expected the opening to bind the value and the commitment")
+	}
+	moved := &KZGFieldOpening{Item: 3, Elements: opening.Elements}
+	if scheme.VerifyField(commitment, moved, data[1]) {
+		t.Fatal("Expected an opening not to prove another item")
+	}
+	callData, err := scheme.OpenField(blinding, 2, data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheme.VerifyField(commitment, callData, data[2]) || len(callData.Elements) != 1+(1000+30)/31 {
+		t.Fatalf("Expected call data to open with one proof per element, got %d", len(callData.Elements))
+	}
+	tampered := *callData.Elements[1]
+	tampered.Value[5] ^= 1
+	forged := &KZGFieldOpening{Item: 2, Elements: append([]*KZGElementOpening{callData.Elements[0], &tampered}, callData.Elements[2:]...)}
+	if scheme.VerifyField(commitment, forged, data[2]) {
+		t.Fatal("Expected a tampered element to fail its proof")
+	}
+
+	// The configured scheme is used for PHT commitments
+	config := DefaultP2SConfig()
+	config.CommitmentScheme = CommitmentSchemeKZG
+	pht := &PHTTransaction{Recipient: common.Address{1}, Value: big.NewInt(5), CallData: []byte{1, 2, 3}, TxType: 2, GasLimit: 8}
+	pht.Commitment, pht.Blinding, _ = scheme.Commit(pht.Recipient.Bytes(), pht.Value.Bytes(), pht.CallData, []byte{pht.TxType}, []byte{byte(pht.GasLimit)})
+	if !newTestPHTManager(t, config).VerifyCommitment(pht, pht.Recipient, pht.Value, pht.CallData, 2, 8) {
+		t.Fatal("Expected KZG-configured PHT manager to verify KZG commitment")
+	}
+	if newTestPHTManager(t, DefaultP2SConfig()).VerifyCommitment(pht, pht.Recipient, pht.Value, pht.CallData, 2, 8) {
+		t.Fatal("Expected Pedersen-configured PHT manager to reject KZG commitment")
+	}
+}
+
+// hashCommitment is a toy commitment scheme used to test scheme registration
+type hashCommitment struct{}
+
+func (hashCommitment) Commit(data ...[]byte) ([]byte, []byte, error) {
+	return crypto.Keccak256(data...), nil, nil
+}
+
+func (hashCommitment) Verify(commitment []byte, blinding []byte, data ...[]byte) bool {
+	return bytes.Equal(commitment, crypto.Keccak256(data...))
+}
+
+func TestCommitmentSchemeRegistry(t *testing.T) {
+	constructor := func(*P2SConfig) (CommitmentScheme, error) { return hashCommitment{}, nil }
+	if err := RegisterCommitmentScheme("test-hash", constructor); err != nil && !errors.Is(err, ErrCommitmentSchemeRegistered) {
+		t.Fatal(err)
+	}
+	if err := RegisterCommitmentScheme("test-hash", constructor); !errors.Is(err, ErrCommitmentSchemeRegistered) {
+		t.Fatalf("Expected duplicate registration to fail, got %v", err)
+	}
+	names := CommitmentSchemes()
+	for _, name := range []string{CommitmentSchemeKZG, CommitmentSchemePedersen, "test-hash"} {
+		if sort.SearchStrings(names, name) == len(names) || names[sort.SearchStrings(names, name)] != name {
+			t.Fatalf("Expected %s to be registered, got %v", name, names)
+		}
+	}
+
+	config := DefaultP2SConfig()
+	config.CommitmentScheme = "test-hash"
+	if scheme, err := NewCommitmentScheme(config); err != nil {
+		t.Fatal(err)
+	} else if _, ok := scheme.(hashCommitment); !ok {
+		t.Fatalf("Expected configured scheme, got %T", scheme)
+	}
+
+	// The PHT manager honors the configured scheme
+	pht := &PHTTransaction{Recipient: common.Address{1}, Value: big.NewInt(5), CallData: []byte{1}, TxType: 2, GasLimit: 8}
+	pht.Commitment, _, _ = hashCommitment{}.Commit(pht.Recipient.Bytes(), pht.Value.Bytes(), pht.CallData, []byte{2}, []byte{8})
+	if !newTestPHTManager(t, config).VerifyCommitment(pht, pht.Recipient, pht.Value, pht.CallData, 2, 8) {
+		t.Fatal("Expected PHT manager to use the registered scheme")
+	}
+
+	config.CommitmentScheme = "unknown"
+	if _, err := NewCommitmentScheme(config); !errors.Is(err, ErrUnknownCommitmentScheme) {
+		t.Fatalf("Expected unknown scheme error, got %v", err)
+	}
+
+	// An unknown scheme fails startup instead of falling back to Pedersen
+	if _, err := NewPHTManager(config); !errors.Is(err, ErrUnknownCommitmentScheme) {
+		t.Fatalf("Expected the PHT manager to reject the unknown scheme, got %v", err)
+	}
+	if _, err := NewMTManager(config); !errors.Is(err, ErrUnknownCommitmentScheme) {
+		t.Fatalf("Expected the MT manager to reject the unknown scheme, got %v", err)
+	}
+	if engine, err := NewConsensus(nil, config); !errors.Is(err, ErrUnknownCommitmentScheme) || engine != nil {
+		t.Fatalf("Expected the engine to reject the unknown scheme, got %v", err)
+	}
+}
+
+// nextEvent receives an event or fails after a timeout
+func nextEvent(t *testing.T, sub *EventSubscription) *BusEvent {
+	t.Helper()
+	select {
+	case event := <-sub.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for event")
+		return nil
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	db := memorydb.New()
+	bus := NewEventBus()
+	if err := bus.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	for block := uint64(1); block <= 3; block++ {
+		bus.Publish(&BusEvent{Block: block, Kind: EventPairFinalized, PairFinalized: &PairFinalized{Reveals: int(block)}})
+	}
+	bus.Publish(&BusEvent{Block: 3, Kind: EventValidatorSlashed, ValidatorSlashed: &ValidatorSlashed{Validator: common.Address{1}, Amount: big.NewInt(1)}})
+
+	// Replay from block 2, then live events, in sequence order
+	sub, err := bus.Subscribe("indexer", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	bus.Publish(&BusEvent{Block: 4, Kind: EventRevealExpired, RevealExpired: &RevealExpired{TxHash: common.Hash{4}}})
+	for _, seq := range []uint64{2, 3, 4, 5} {
+		if event := nextEvent(t, sub); event.Seq != seq {
+			t.Fatalf("Expected event %d, got %d", seq, event.Seq)
+		}
+	}
+
+	// Kind filtering
+	slashed, err := bus.Subscribe("alerting", 0, EventValidatorSlashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slashed.Unsubscribe()
+	if event := nextEvent(t, slashed); event.Kind != EventValidatorSlashed || event.ValidatorSlashed.Validator != (common.Address{1}) {
+		t.Fatalf("Expected slashing event, got %+v", event)
+	}
+
+	// Acknowledged cursors and events survive a restart
+	if err := sub.Ack(3); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewEventBus()
+	if err := restarted.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	if cursor, _ := restarted.Cursor("indexer"); cursor != 3 {
+		t.Fatalf("Expected cursor 3, got %d", cursor)
+	}
+	resumed, err := restarted.Resume("indexer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Unsubscribe()
+	if event := nextEvent(t, resumed); event.Seq != 4 || event.ValidatorSlashed == nil {
+		t.Fatalf("Expected to resume at restored event 4, got %+v", event)
+	}
+	if event := nextEvent(t, resumed); event.Seq != 5 || event.RevealExpired.TxHash != (common.Hash{4}) {
+		t.Fatalf("Expected restored event 5, got %+v", event)
+	}
+	restarted.Publish(&BusEvent{Block: 5, Kind: EventPairFinalized, PairFinalized: &PairFinalized{}})
+	if event := nextEvent(t, resumed); event.Seq != 6 {
+		t.Fatalf("Expected live event 6 after restart, got %d", event.Seq)
+	}
+
+	// Slashing through the engine publishes an event
+	engine := newTestConsensus(t, DefaultConfig())
+	validator := common.Address{7}
+	if err := engine.validatorMgr.AddValidator(validator, new(big.Int).Mul(DefaultConfig().MinStake, big.NewInt(2))); err != nil {
+		t.Fatal(err)
+	}
+	events, err := engine.SubscribeEvents("test", 0, EventValidatorSlashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Unsubscribe()
+	if _, err := engine.SlashValidator(10, validator, big.NewInt(1), "double sign"); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextEvent(t, events); event.Block != 10 || event.ValidatorSlashed.Reason != "double sign" {
+		t.Fatalf("Unexpected slashing event %+v", event)
+	}
+}
+
+func TestThresholdEncryption(t *testing.T) {
+	committee := []common.Address{{1}, {2}, {3}, {4}, {5}}
+	key, keyShares, err := GenerateThresholdKey(committee, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, value, callData := common.Address{9}, big.NewInt(42), []byte{0xa9, 0x05, 0x9c, 0xbb}
+	pht := &PHTTransaction{Sender: common.Address{8}, TxHash: common.Hash{7}, Recipient: recipient, Value: value, CallData: callData, TxType: 2, GasLimit: 21}
+	pht.Commitment, pht.Blinding, err = NewPedersenCommitment().Commit(recipient.Bytes(), value.Bytes(), callData, []byte{2}, []byte{21})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SealHiddenFields(key, pht); err != nil {
+		t.Fatal(err)
+	}
+	if pht.Recipient != (common.Address{}) || pht.CallData != nil || pht.Blinding != nil {
+		t.Fatal("Expected hidden fields to be stripped from sealed PHT")
+	}
+
+	shares := make([]*DecryptionShare, len(keyShares))
+	for i, keyShare := range keyShares {
+		if shares[i], err = CreateDecryptionShare(keyShare, pht.TxHash, pht.EncryptedFields); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyDecryptionShare(key, pht.EncryptedFields, shares[i]); err != nil {
+			t.Fatalf("Expected share %d to verify: %v", i+1, err)
+		}
+	}
+
+	// Below the threshold nothing is recovered
+	if _, err := CombineDecryptionShares(key, pht.TxHash, pht.EncryptedFields, shares[:2]); !errors.Is(err, ErrInsufficientShares) {
+		t.Fatalf("Expected insufficient shares error, got %v", err)
+	}
+	// Any quorum recovers the fields
+	fields, err := CombineDecryptionShares(key, pht.TxHash, pht.EncryptedFields, []*DecryptionShare{shares[4], shares[1], shares[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields.Recipient != recipient || fields.Value.Cmp(value) != 0 || !bytes.Equal(fields.CallData, callData) {
+		t.Fatalf("Unexpected decrypted fields %+v", fields)
+	}
+
+	// Shares with a wrong secret or claimed by another member are rejected
+	forged, _ := CreateDecryptionShare(&ThresholdKeyShare{Index: 1, Validator: committee[0], Secret: keyShares[1].Secret}, pht.TxHash, pht.EncryptedFields)
+	if err := VerifyDecryptionShare(key, pht.EncryptedFields, forged); !errors.Is(err, ErrInvalidDecryptionShare) {
+		t.Fatalf("Expected forged share to be rejected, got %v", err)
+	}
+	stolen := *shares[0]
+	stolen.Validator = committee[1]
+	if err := VerifyDecryptionShare(key, pht.EncryptedFields, &stolen); !errors.Is(err, ErrInvalidDecryptionShare) {
+		t.Fatalf("Expected share with wrong member to be rejected, got %v", err)
+	}
+
+	// The engine only accepts shares after B1 finalization and restores the PHT at quorum
+	engine := newTestConsensus(t, DefaultConfig())
+	engine.SetThresholdKey(key)
+	if _, err := engine.SubmitDecryptionShare(shares[0]); !errors.Is(err, ErrNotFinalized) {
+		t.Fatalf("Expected share before finalization to be rejected, got %v", err)
+	}
+	engine.decryptor.Finalize([]*PHTTransaction{pht})
+	for i := 0; i < 2; i++ {
+		if done, err := engine.SubmitDecryptionShare(shares[i]); err != nil || done {
+			t.Fatalf("Expected share %d to be pending, got %v %v", i+1, done, err)
+		}
+	}
+	if done, err := engine.SubmitDecryptionShare(shares[2]); err != nil || !done {
+		t.Fatalf("Expected quorum to decrypt, got %v %v", done, err)
+	}
+	if pht.Recipient != recipient || pht.GasLimit != 21 || pht.EncryptedFields != nil {
+		t.Fatalf("Expected hidden fields to be restored, got %+v", pht)
+	}
+
+	// With sealing configured, PHTs leave the manager encrypted to the committee
+	config := DefaultP2SConfig()
+	config.SealHiddenFields = true
+	sealing := newTestPHTManager(t, config)
+	sender, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1337)
+	tx, err := types.SignNewTx(sender, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(50000000000),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     value,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sealing.CreatePHT(tx); !errors.Is(err, ErrMissingThresholdKey) {
+		t.Fatalf("Expected sealing without a committee key to fail, got %v", err)
+	}
+	sealing.SetThresholdKey(key)
+	sealed, err := sealing.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.EncryptedFields == nil || sealed.Recipient != (common.Address{}) || sealed.Value.Sign() != 0 {
+		t.Fatalf("Expected created PHT to be sealed, got %+v", sealed)
+	}
+	sealedShares := make([]*DecryptionShare, 3)
+	for i := range sealedShares {
+		if sealedShares[i], err = CreateDecryptionShare(keyShares[i], sealed.TxHash, sealed.EncryptedFields); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opened, err := CombineDecryptionShares(key, sealed.TxHash, sealed.EncryptedFields, sealedShares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Recipient != recipient || opened.Value.Cmp(value) != 0 || opened.GasLimit != 21000 {
+		t.Fatalf("Unexpected fields sealed at creation %+v", opened)
+	}
+
+	// The decryptor retains a bounded number of encrypted PHTs
+	decryptor := newThresholdDecryptor(key, 2)
+	for i := byte(1); i <= 3; i++ {
+		decryptor.Finalize([]*PHTTransaction{{TxHash: common.Hash{i}, EncryptedFields: sealed.EncryptedFields}})
+	}
+	if _, exists := decryptor.PHT(common.Hash{1}); exists {
+		t.Fatal("Expected the oldest PHT to be evicted")
+	}
+	if _, exists := decryptor.PHT(common.Hash{3}); !exists {
+		t.Fatal("Expected the newest PHT to be retained")
+	}
+}
+
+func TestTimelockCommitment(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.CommitmentScheme = CommitmentSchemeTimelock
+	config.TimelockSquaringsPerSecond = 1000
+	scheme, err := NewCommitmentScheme(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timelock, ok := scheme.(*TimelockCommitment)
+	if !ok {
+		t.Fatalf("Expected timelock scheme, got %T", scheme)
+	}
+	if want := uint64(config.B1BlockTime.Seconds() * 1000); timelock.Squarings() != want {
+		t.Fatalf("Expected puzzles sized to the B1 interval (%d squarings), got %d", want, timelock.Squarings())
+	}
+
+	recipient, value, callData := common.Address{3}, big.NewInt(11), []byte{1, 2}
+	commitment, blinding, err := timelock.Commit(recipient.Bytes(), value.Bytes(), callData, []byte{2}, []byte{30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txHash := common.Hash{5}
+	fields := &HiddenFields{Recipient: recipient, Value: value, CallData: callData, TxType: 2, GasLimit: 30, Blinding: blinding}
+	puzzle, err := timelock.Lock(txHash, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening is bounded by the context and bound to the PHT hash
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SolveTimelockPuzzle(ctx, txHash, puzzle); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancelled solve, got %v", err)
+	}
+	if _, err := SolveTimelockPuzzle(context.Background(), common.Hash{6}, puzzle); err == nil {
+		t.Fatal("Expected puzzle to be bound to its PHT")
+	}
+
+	// A withheld MT is recovered by the engine from the puzzle
+	pht := &PHTTransaction{Sender: common.Address{4}, TxHash: txHash, Commitment: commitment, Value: new(big.Int), TimelockPuzzle: puzzle}
+	engine := newTestConsensus(t, DefaultConfig())
+	b1Hash := common.Hash{0xb1}
+	engine.cache.SetB1Block(b1Hash, &B1Block{Header: &types.Header{Number: big.NewInt(1)}, PHTs: []*PHTTransaction{pht}, BlockType: 1})
+	mt, err := engine.ForceReveal(context.Background(), b1Hash, txHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.Recipient != recipient || mt.Value.Cmp(value) != 0 || !bytes.Equal(pht.Blinding, blinding) {
+		t.Fatalf("Expected forced reveal of hidden fields, got %+v", mt)
+	}
+	if _, err := engine.ForceReveal(context.Background(), b1Hash, common.Hash{6}); err == nil {
+		t.Fatal("Expected unknown PHT to fail")
+	}
+
+	// Puzzles share the sender's modulus but not their solution
+	other, err := timelock.Lock(common.Hash{6}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(other.Modulus, puzzle.Modulus) || bytes.Equal(other.Base, puzzle.Base) {
+		t.Fatal("Expected puzzles to reuse the modulus with a fresh base")
+	}
+	if opened, err := SolveTimelockPuzzle(context.Background(), common.Hash{6}, other); err != nil || opened.Recipient != recipient {
+		t.Fatalf("Expected second puzzle to open, got %v", err)
+	}
+
+	// Puzzles are checked against the network's interval and modulus size
+	if err := timelock.Verify(puzzle); err != nil {
+		t.Fatalf("Expected puzzle to verify, got %v", err)
+	}
+	short := *puzzle
+	short.Squarings = 1
+	if err := timelock.Verify(&short); !errors.Is(err, ErrInvalidTimelockPuzzle) {
+		t.Fatalf("Expected too few squarings to be rejected, got %v", err)
+	}
+	weak := *puzzle
+	weak.Modulus = puzzle.Modulus[len(puzzle.Modulus)/2:]
+	if err := timelock.Verify(&weak); !errors.Is(err, ErrInvalidTimelockPuzzle) {
+		t.Fatalf("Expected small modulus to be rejected, got %v", err)
+	}
+	if err := timelock.Verify(nil); !errors.Is(err, ErrInvalidTimelockPuzzle) {
+		t.Fatal("Expected missing puzzle to be rejected")
+	}
+
+	// Validation of PHTs enforces the puzzle parameters
+	manager := newTestPHTManager(t, config)
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1337)
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(50000000000),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     value,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ValidatePHT(locked); err != nil {
+		t.Fatalf("Expected PHT with network puzzle to validate, got %v", err)
+	}
+	locked.TimelockPuzzle.Squarings /= 2
+	if err := manager.ValidatePHT(locked); !errors.Is(err, ErrInvalidTimelockPuzzle) {
+		t.Fatalf("Expected PHT with short puzzle to be rejected, got %v", err)
+	}
+}
+
+func TestPHTRLPEncoding(t *testing.T) {
+	pht := &PHTTransaction{
+		Sender:          common.Address{1},
+		GasPrice:        big.NewInt(2000000000),
+		Commitment:      []byte{1, 2, 3},
+		Nonce:           []byte{4, 5},
+		Timestamp:       1700000000,
+		FieldCommitment: []byte{6},
+		Recipient:       common.Address{2},
+		Value:           big.NewInt(7),
+		CallData:        []byte{0xa9, 0x05, 0x9c, 0xbb},
+		TxType:          2,
+		GasLimit:        21000,
+		FieldSalts:      [][]byte{{8}, {9}},
+		Blinding:        []byte{10},
+		TxHash:          common.Hash{11},
+		EncryptedFields: &EncryptedFields{Ephemeral: []byte{12}, Nonce: []byte{13}, Ciphertext: []byte{14}},
+
+		MaxFeePerGas:         big.NewInt(3000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+
+		// The last field is set so every known field is encoded before the future one
+		NonceContext: common.Hash{15},
+		NonceProof:   []byte{16},
+		HiddenSet:    DefaultHiddenFieldSet,
+
+		ValueCommitment: []byte{17},
+		ValueRangeProof: &RangeProof{Cap: big.NewInt(100)},
+		ChainID:         big.NewInt(1337),
+		FieldEncoding:   CurrentFieldEncoding,
+
+		IsContractCreation: true,
+		AccountNonce:       3,
+	}
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Recipient != pht.Recipient || decoded.Value.Cmp(pht.Value) != 0 || !bytes.Equal(decoded.CallData, pht.CallData) ||
+		decoded.GasLimit != pht.GasLimit || decoded.TxHash != pht.TxHash || len(decoded.FieldSalts) != 2 || !bytes.Equal(decoded.Blinding, pht.Blinding) {
+		t.Fatalf("Hidden fields or hash lost in round trip: %+v", decoded)
+	}
+	if decoded.Hash() != pht.Hash() || decoded.MaxFeePerGas.Cmp(pht.MaxFeePerGas) != 0 || decoded.TimelockPuzzle != nil || !bytes.Equal(decoded.EncryptedFields.Ciphertext, []byte{14}) ||
+		decoded.NonceContext != pht.NonceContext || !bytes.Equal(decoded.NonceProof, pht.NonceProof) || decoded.ValueRangeProof.Cap.Cmp(pht.ValueRangeProof.Cap) != 0 ||
+		decoded.FieldEncoding != pht.FieldEncoding || !decoded.IsContractCreation || decoded.AccountNonce != pht.AccountNonce {
+		t.Fatalf("Visible fields lost in round trip: %+v", decoded)
+	}
+
+	// PHTs embedded in other RLP structures use the same encoding
+	list, err := rlp.EncodeToBytes([]*PHTTransaction{pht, pht})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phts []*PHTTransaction
+	if err := rlp.DecodeBytes(list, &phts); err != nil || len(phts) != 2 || phts[1].TxHash != pht.TxHash {
+		t.Fatalf("Unexpected list round trip: %v", err)
+	}
+
+	// Payloads of later versions with appended fields still decode
+	var raw []rlp.RawValue
+	if err := rlp.DecodeBytes(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	raw[0], _ = rlp.EncodeToBytes(uint64(PHTEncodingVersion + 1))
+	extra, _ := rlp.EncodeToBytes([]byte("future field"))
+	future, _ := rlp.EncodeToBytes(append(raw, extra))
+	if err := new(PHTTransaction).Deserialize(future); err != nil {
+		t.Fatalf("Expected newer payload to decode, got %v", err)
+	}
+	raw[0], _ = rlp.EncodeToBytes(uint64(0))
+	invalid, _ := rlp.EncodeToBytes(raw)
+	if err := new(PHTTransaction).Deserialize(invalid); err == nil {
+		t.Fatal("Expected version 0 to be rejected")
+	}
+}
+
+func TestDynamicFeePHT(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1337)
+	recipient := common.Address{0xd}
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		GasTipCap: big.NewInt(2000000000),
+		GasFeeCap: big.NewInt(50000000000),
+		Gas:       21000,
+		To:        &recipient,
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pht, err := newTestPHTManager(t, DefaultP2SConfig()).CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pht.Sender != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Expected sender %x, got %x", crypto.PubkeyToAddress(key.PublicKey), pht.Sender)
+	}
+	if pht.MaxFeePerGas.Cmp(tx.GasFeeCap()) != 0 || pht.MaxPriorityFeePerGas.Cmp(tx.GasTipCap()) != 0 {
+		t.Fatalf("Expected fee caps to be kept, got %v %v", pht.MaxFeePerGas, pht.MaxPriorityFeePerGas)
+	}
+
+	// The tip is capped by the priority fee and by the fee cap minus the base fee
+	if tip := pht.EffectiveGasTip(big.NewInt(10000000000)); tip.Cmp(big.NewInt(2000000000)) != 0 {
+		t.Fatalf("Expected tip capped by priority fee, got %v", tip)
+	}
+	if tip := pht.EffectiveGasTip(big.NewInt(49000000000)); tip.Cmp(big.NewInt(1000000000)) != 0 {
+		t.Fatalf("Expected tip capped by fee cap, got %v", tip)
+	}
+
+	// Fee caps survive encoding and are part of the PHT hash
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.MaxPriorityFeePerGas.Cmp(pht.MaxPriorityFeePerGas) != 0 || decoded.Hash() != pht.Hash() {
+		t.Fatal("Expected fee caps to round trip")
+	}
+	legacy := *pht
+	legacy.MaxFeePerGas, legacy.MaxPriorityFeePerGas = nil, nil
+	if legacy.Hash() == pht.Hash() {
+		t.Fatal("Expected fee caps to change the PHT hash")
+	}
+	if rebuilt := pht.ToTransaction(); rebuilt.Type() != types.DynamicFeeTxType || rebuilt.GasTipCap().Cmp(tx.GasTipCap()) != 0 {
+		t.Fatalf("Expected dynamic-fee transaction, got type %d", rebuilt.Type())
+	}
+}
+
+func TestBlobTransactionPHT(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1337)
+	blobHashes := []common.Hash{{0x01, 0xaa}, {0x01, 0xbb}}
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.BlobTx{
+		ChainID:    uint256.MustFromBig(chainID),
+		GasTipCap:  uint256.NewInt(1000000000),
+		GasFeeCap:  uint256.NewInt(30000000000),
+		Gas:        50000,
+		To:         common.Address{0xe},
+		Value:      uint256.NewInt(0),
+		BlobFeeCap: uint256.NewInt(7),
+		BlobHashes: blobHashes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	pht, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pht.BlobHashes) != 2 || pht.MaxFeePerBlobGas.Uint64() != 7 || pht.MaxFeePerGas == nil {
+		t.Fatalf("Expected blob fields to be kept, got %v %v", pht.BlobHashes, pht.MaxFeePerBlobGas)
+	}
+	if err := manager.ValidatePHT(pht); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blob fields are committed as hidden data
+	fields := &HiddenFields{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding, BlobHashes: blobHashes, MaxFeePerBlobGas: big.NewInt(7)}
+	if !manager.VerifyHiddenFields(pht, fields) {
+		t.Fatal("Expected blob fields to open the commitment")
+	}
+	fields.BlobHashes = []common.Hash{blobHashes[1], blobHashes[0]}
+	if manager.VerifyHiddenFields(pht, fields) {
+		t.Fatal("Expected reordered blob hashes to fail the commitment")
+	}
+
+	// MTs reveal the blob fields and are checked against the PHT in B2
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+	mt := &MTTransaction{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding, BlobHashes: blobHashes, MaxFeePerBlobGas: big.NewInt(7)}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatal(err)
+	}
+	mt.MaxFeePerBlobGas = big.NewInt(8)
+	if err := mtManager.VerifyOpening(mt, pht); err == nil {
+		t.Fatal("Expected altered blob fee cap to fail the opening")
+	}
+
+	// Blob fields survive encoding and rebuild a blob transaction
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.BlobHashes) != 2 || decoded.BlobHashes[1] != blobHashes[1] || decoded.MaxFeePerBlobGas.Uint64() != 7 {
+		t.Fatalf("Expected blob fields to round trip, got %v", decoded.BlobHashes)
+	}
+	if rebuilt := pht.ToTransaction(); rebuilt.Type() != types.BlobTxType || len(rebuilt.BlobHashes()) != 2 {
+		t.Fatalf("Expected blob transaction, got type %d", rebuilt.Type())
+	}
+}
+
+func TestAccessListPHT(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(1337)
+	recipient := common.Address{0xf}
+	accessList := types.AccessList{{Address: common.Address{0xaa}, StorageKeys: []common.Hash{{1}, {2}}}}
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(chainID), &types.AccessListTx{
+		ChainID:    chainID,
+		GasPrice:   big.NewInt(2000000000),
+		Gas:        60000,
+		To:         &recipient,
+		Value:      big.NewInt(3),
+		Data:       []byte{0x12, 0x34, 0x56, 0x78},
+		AccessList: accessList,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	pht, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pht.AccessList) != 1 || len(pht.AccessList[0].StorageKeys) != 2 {
+		t.Fatalf("Expected access list to be kept, got %v", pht.AccessList)
+	}
+	if err := manager.ValidatePHT(pht); err != nil {
+		t.Fatal(err)
+	}
+
+	// The access list is committed: an MT dropping it does not open the PHT
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+	mt := &MTTransaction{Recipient: recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding, AccessList: accessList}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatal(err)
+	}
+	mt.AccessList = nil
+	if err := mtManager.VerifyOpening(mt, pht); err == nil {
+		t.Fatal("Expected MT without access list to fail the opening")
+	}
+
+	// The access list survives encoding and the transaction keeps its type
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.AccessList) != 1 || decoded.AccessList[0].StorageKeys[1] != (common.Hash{2}) {
+		t.Fatalf("Expected access list to round trip, got %v", decoded.AccessList)
+	}
+	rebuilt := pht.ToTransaction()
+	if rebuilt.Type() != types.AccessListTxType || len(rebuilt.AccessList()) != 1 || rebuilt.GasPrice().Cmp(tx.GasPrice()) != 0 {
+		t.Fatalf("Expected access list transaction, got type %d", rebuilt.Type())
+	}
+}
+
+func TestCreatePHTsParallel(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	txs := make([]*types.Transaction, 32)
+	for i := range txs {
+		tx, err := types.SignTx(types.NewTransaction(uint64(i), common.Address{byte(i)}, big.NewInt(int64(i)), 21000, big.NewInt(1000000000), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs[i] = tx
+	}
+
+	config := DefaultP2SConfig()
+	config.PHTWorkers = 4
+	manager := newTestPHTManager(t, config)
+	phts, err := manager.CreatePHTs(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonces := make(map[string]bool)
+	for i, pht := range phts {
+		if pht.TxHash != txs[i].Hash() {
+			t.Fatalf("Expected PHT %d in transaction order", i)
+		}
+		if nonces[string(pht.Nonce)] {
+			t.Fatalf("Duplicate anti-MEV nonce at PHT %d", i)
+		}
+		nonces[string(pht.Nonce)] = true
+	}
+
+	// An invalid transaction fails the batch and is identified
+	unsigned := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	if _, err := manager.CreatePHTs(append(txs[:3:3], unsigned)); err == nil || !regexp.MustCompile(`transaction 3 `).MatchString(err.Error()) {
+		t.Fatalf("Expected failing transaction to be reported, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := manager.CreatePHTsWithContext(ctx, txs); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation, got %v", err)
+	}
+
+	// A transaction too large for a KZG blob is left out, not the batch
+	config.CommitmentScheme = CommitmentSchemeKZG
+	kzgManager := newTestPHTManager(t, config)
+	oversized, err := types.SignTx(types.NewTransaction(32, common.Address{0xff}, big.NewInt(0), 10000000, big.NewInt(1000000000), make([]byte, 127*1024)), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []*types.Transaction{txs[0], oversized, txs[1]}
+	if phts, err = kzgManager.CreatePHTs(batch); err != nil {
+		t.Fatalf("Expected the oversized transaction to be skipped, got %v", err)
+	}
+	if len(phts) != 2 || phts[0].TxHash != txs[0].Hash() || phts[1].TxHash != txs[1].Hash() {
+		t.Fatalf("Expected the other transactions in order, got %d PHTs", len(phts))
+	}
+}
+
+func TestVRFAntiMEVNonce(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(1000000000), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	manager.SetNonceContext(common.Hash{1})
+	pht, err := manager.CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.VerifyNonce(pht); err != nil {
+		t.Fatalf("Expected nonce to verify, got %v", err)
+	}
+	if err := manager.ValidatePHT(pht); err != nil {
+		t.Fatalf("Expected PHT to validate, got %v", err)
+	}
+
+	// The nonce is unique for the key and context, so the sender cannot grind it
+	again, err := manager.CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Nonce, pht.Nonce) {
+		t.Fatal("Expected the same nonce for the same transaction and context")
+	}
+	manager.SetNonceContext(common.Hash{2})
+	next, _ := manager.CreatePHTWithKey(tx, key)
+	if bytes.Equal(next.Nonce, pht.Nonce) {
+		t.Fatal("Expected a new nonce in a new block context")
+	}
+
+	// Tampering with the nonce, context or sender is detected
+	tampered := *pht
+	tampered.Nonce = append([]byte{}, pht.Nonce...)
+	tampered.Nonce[0] ^= 1
+	if err := manager.VerifyNonce(&tampered); !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("Expected tampered nonce to be rejected, got %v", err)
+	}
+	tampered = *pht
+	tampered.NonceContext = common.Hash{2}
+	if err := manager.VerifyNonce(&tampered); !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("Expected wrong context to be rejected, got %v", err)
+	}
+	tampered = *pht
+	tampered.Sender = common.Address{0xb}
+	if err := manager.VerifyNonce(&tampered); !errors.Is(err, ErrInvalidNonce) {
+		t.Fatalf("Expected foreign sender to be rejected, got %v", err)
+	}
+
+	other, _ := crypto.GenerateKey()
+	if _, err := manager.CreatePHTWithKey(tx, other); !errors.Is(err, ErrNonceKeyMismatch) {
+		t.Fatalf("Expected key mismatch, got %v", err)
+	}
+	unproven, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.VerifyNonce(unproven); !errors.Is(err, ErrMissingNonceProof) {
+		t.Fatalf("Expected missing proof, got %v", err)
+	}
+
+	// Networks requiring nonce proofs reject unproven PHTs and cannot create them
+	strict := DefaultP2SConfig()
+	strict.RequireNonceProofs = true
+	required := newTestPHTManager(t, strict)
+	if err := required.ValidatePHT(unproven); !errors.Is(err, ErrMissingNonceProof) {
+		t.Fatalf("Expected an unproven PHT to be rejected, got %v", err)
+	}
+	if _, err := required.CreatePHT(tx); !errors.Is(err, ErrMissingNonceProof) {
+		t.Fatalf("Expected converting without the sender key to fail, got %v", err)
+	}
+	proven, err := required.CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := required.ValidatePHT(proven); err != nil {
+		t.Fatalf("Expected a proven PHT to validate, got %v", err)
+	}
+}
+
+func TestPHTReplacement(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	newPHT := func(manager *PHTManager, gasPrice int64) *PHTTransaction {
+		tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(gasPrice), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pht, err := manager.CreatePHT(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pht
+	}
+
+	config := DefaultP2SConfig()
+	config.PHTPriceBump = 10
+	manager := newTestPHTManager(t, config)
+	original := newPHT(manager, 1000)
+
+	if err := manager.ReplacePHT(original, newPHT(manager, 1099)); !errors.Is(err, ErrReplacementUnderpriced) {
+		t.Fatalf("Expected underpriced replacement to be rejected, got %v", err)
+	}
+	replacement := newPHT(manager, 1100)
+	if err := manager.ReplacePHT(original, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if by, ok := manager.SupersededBy(original.TxHash); !ok || by != replacement.TxHash {
+		t.Fatal("Expected original to be superseded by the replacement")
+	}
+	if err := manager.ReplacePHT(original, newPHT(manager, 2000)); !errors.Is(err, ErrAlreadySuperseded) {
+		t.Fatalf("Expected superseded PHT not to be replaced again, got %v", err)
+	}
+	if err := manager.CheckReveal(original.TxHash); !errors.Is(err, ErrStaleReveal) {
+		t.Fatalf("Expected stale reveal, got %v", err)
+	}
+	if err := manager.CheckReveal(replacement.TxHash); err != nil {
+		t.Fatalf("Expected replacement reveal to be accepted, got %v", err)
+	}
+
+	other, _ := crypto.GenerateKey()
+	foreignTx, _ := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(5000), nil), signer, other)
+	foreign, _ := manager.CreatePHT(foreignTx)
+	if err := manager.ReplacePHT(replacement, foreign); !errors.Is(err, ErrReplacementSender) {
+		t.Fatalf("Expected replacement from another sender to be rejected, got %v", err)
+	}
+
+	// Superseded PHTs are left out of blocks and included ones are locked
+	included := manager.IncludePHTs([]*PHTTransaction{original, replacement})
+	if len(included) != 1 || included[0] != replacement {
+		t.Fatalf("Expected only the replacement to be included, got %d", len(included))
+	}
+	if err := manager.ReplacePHT(replacement, newPHT(manager, 5000)); !errors.Is(err, ErrPHTIncluded) {
+		t.Fatalf("Expected included PHT not to be replaced, got %v", err)
+	}
+	manager.ForgetPHTs([]common.Hash{replacement.TxHash})
+	if _, ok := manager.SupersededBy(original.TxHash); ok {
+		t.Fatal("Expected replacement chain to be forgotten after reveal")
+	}
+}
+
+func TestHiddenFieldSets(t *testing.T) {
+	if _, err := ParseHiddenFieldSet([]string{"nonce"}); !errors.Is(err, ErrInvalidHiddenFieldSet) {
+		t.Fatalf("Expected unknown field to be rejected, got %v", err)
+	}
+	set, err := ParseHiddenFieldSet([]string{"gasPrice", "sender"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.Has(FieldSender) || !set.Has(FieldGasPrice) || !set.Has(FieldRecipient) || len(set.Names()) != 7 {
+		t.Fatalf("Unexpected field set %v", set.Names())
+	}
+	if HiddenFieldSet(0).Has(FieldSender) || !HiddenFieldSet(0).Has(FieldCallData) {
+		t.Fatal("Expected the zero set to stand for the default set")
+	}
+
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(key, types.LatestSignerForChainID(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Gas:       21000,
+		GasFeeCap: big.NewInt(3000000000),
+		GasTipCap: big.NewInt(1000000000),
+		To:        &common.Address{0xa},
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultP2SConfig()
+	config.HiddenFields = []string{"gasPrice", "sender"}
+	phtManager := newTestPHTManager(t, config)
+	mtManager := newTestMTManager(t, config)
+	pht, err := phtManager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pht.HiddenSet != set {
+		t.Fatalf("Expected PHT to record the network field set, got %v", pht.HiddenSet.Names())
+	}
+	if err := phtManager.ValidatePHT(pht); err != nil {
+		t.Fatal(err)
+	}
+
+	// The visible hash does not depend on hidden fields
+	hash := pht.Hash()
+	masked := *pht
+	masked.Sender, masked.GasPrice, masked.MaxFeePerGas, masked.MaxPriorityFeePerGas = common.Address{}, new(big.Int), nil, nil
+	if masked.Hash() != hash {
+		t.Fatal("Expected hidden sender and gas price to be left out of the PHT hash")
+	}
+
+	// The MT reveals the extra fields, which must open the commitment
+	mt, err := mtManager.CreateMT(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.Sender != pht.Sender || mt.GasPrice.Cmp(tx.GasPrice()) != 0 {
+		t.Fatal("Expected MT to reveal sender and gas price")
+	}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatalf("Expected MT to open the commitment, got %v", err)
+	}
+	forged := *mt
+	forged.GasPrice = big.NewInt(1)
+	if err := mtManager.VerifyOpening(&forged, pht); err == nil {
+		t.Fatal("Expected forged gas price to fail the opening")
+	}
+
+	// PHTs of other field sets are rejected, and the set survives encoding
+	if err := newTestPHTManager(t, DefaultP2SConfig()).ValidatePHT(pht); !errors.Is(err, ErrInvalidHiddenFieldSet) {
+		t.Fatalf("Expected field set mismatch, got %v", err)
+	}
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil || decoded.HiddenSet != set || decoded.Hash() != hash {
+		t.Fatalf("Expected field set to survive encoding, got %v", err)
+	}
+}
+
+func TestCommitmentOpenings(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	newTx := func(nonce uint64) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(1000000000), []byte{1, 2}), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	config := DefaultP2SConfig()
+	phtManager := newTestPHTManager(t, config)
+	mtManager := newTestMTManager(t, config)
+	pht, err := phtManager.CreatePHT(newTx(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := phtManager.CreatePHT(newTx(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opening, err := phtManager.Opening(pht.Commitment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opening.Verify(NewPedersenCommitment()); err != nil {
+		t.Fatalf("Expected stored opening to open the commitment, got %v", err)
+	}
+
+	// A PHT published without its randomness is revealed with the stored opening
+	published := *pht
+	published.Blinding = nil
+	mt, err := mtManager.CreateMT(&published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyOpening(mt, &published); !errors.Is(err, ErrMissingOpening) {
+		t.Fatalf("Expected missing opening, got %v", err)
+	}
+	otherOpening, _ := phtManager.Opening(other.Commitment)
+	if err := mtManager.AttachOpening(mt, &published, otherOpening); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected opening of another commitment to be rejected, got %v", err)
+	}
+	if err := mtManager.AttachOpening(mt, &published, opening); err != nil {
+		t.Fatalf("Expected opening to be accepted, got %v", err)
+	}
+	mt.Blinding = otherOpening.Blinding
+	if err := mtManager.VerifyOpening(mt, &published); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected wrong randomness to be rejected, got %v", err)
+	}
+
+	phtManager.ForgetOpening(pht.Commitment)
+	if _, err := phtManager.Opening(pht.Commitment); !errors.Is(err, ErrOpeningNotFound) {
+		t.Fatalf("Expected forgotten opening, got %v", err)
+	}
+}
+
+func TestValueRangeProof(t *testing.T) {
+	limit := big.NewInt(1000)
+	value := big.NewInt(999)
+	commitment, blinding, err := NewValueCommitment(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := ProveValueRange(value, blinding, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyValueRange(commitment, proof, limit); err != nil {
+		t.Fatalf("Expected range proof to verify, got %v", err)
+	}
+	if err := VerifyValueRange(commitment, proof, big.NewInt(2000)); !errors.Is(err, ErrInvalidRangeProof) {
+		t.Fatalf("Expected proof for another cap to be rejected, got %v", err)
+	}
+	if _, err := ProveValueRange(big.NewInt(1000), blinding, limit); !errors.Is(err, ErrValueAboveCap) {
+		t.Fatalf("Expected value at the cap to be rejected, got %v", err)
+	}
+
+	// The proof is bound to its commitment and bits cannot be altered
+	other, _, _ := NewValueCommitment(value)
+	if err := VerifyValueRange(other, proof, limit); !errors.Is(err, ErrInvalidRangeProof) {
+		t.Fatalf("Expected proof for another commitment to be rejected, got %v", err)
+	}
+	proof.Slack[0].S0[31] ^= 1
+	if err := VerifyValueRange(commitment, proof, limit); !errors.Is(err, ErrInvalidRangeProof) {
+		t.Fatalf("Expected tampered proof to be rejected, got %v", err)
+	}
+	if !VerifyValueCommitment(commitment, value, blinding) || VerifyValueCommitment(commitment, big.NewInt(998), blinding) {
+		t.Fatal("Expected value commitment to open to the committed value only")
+	}
+
+	// Validators enforce the cap on PHTs before reveal
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	newTx := func(value int64) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(value), 21000, big.NewInt(1000000000), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	config := DefaultP2SConfig()
+	config.MaxPHTValue = big.NewInt(1000000)
+	manager := newTestPHTManager(t, config)
+	pht, err := manager.CreatePHT(newTx(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ValidatePHT(pht); err != nil {
+		t.Fatalf("Expected capped PHT to validate, got %v", err)
+	}
+	unproven := *pht
+	unproven.ValueRangeProof = nil
+	if err := manager.ValidatePHT(&unproven); !errors.Is(err, ErrMissingRangeProof) {
+		t.Fatalf("Expected PHT without range proof to be rejected, got %v", err)
+	}
+	if _, err := manager.CreatePHT(newTx(2000000)); !errors.Is(err, ErrValueAboveCap) {
+		t.Fatalf("Expected value above cap to be rejected, got %v", err)
+	}
+
+	// The MT must open the value commitment
+	mtManager := newTestMTManager(t, config)
+	mt, err := mtManager.CreateMT(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyValueCommitment(pht.ValueCommitment, mt.Value, mt.ValueBlinding) {
+		t.Fatal("Expected MT to carry the value commitment opening")
+	}
+}
+
+func TestCanonicalPHTHash(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(1000000000), nil), types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultP2SConfig()
+	config.ChainID = big.NewInt(1337)
+	manager := newTestPHTManager(t, config)
+	pht, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pht.ChainID.Cmp(config.ChainID) != 0 {
+		t.Fatalf("Expected PHT bound to chain 1337, got %v", pht.ChainID)
+	}
+	hash := pht.Hash()
+
+	// The creation timestamp is not part of the identity of a PHT
+	restamped := *pht
+	restamped.Timestamp++
+	if restamped.Hash() != hash {
+		t.Fatal("Expected timestamp not to change the PHT hash")
+	}
+
+	// The same PHT on another network has another hash and is rejected there
+	replayed := *pht
+	replayed.ChainID = big.NewInt(1)
+	if replayed.Hash() == hash {
+		t.Fatal("Expected chain ID to be bound into the PHT hash")
+	}
+	if err := manager.ValidatePHT(&replayed); !errors.Is(err, ErrChainIDMismatch) {
+		t.Fatalf("Expected cross-network PHT to be rejected, got %v", err)
+	}
+
+	// Visible fields are covered
+	renonced := *pht
+	renonced.Nonce = append([]byte{0}, pht.Nonce...)
+	if renonced.Hash() == hash {
+		t.Fatal("Expected nonce to be covered by the PHT hash")
+	}
+}
+
+func TestPHTReceipts(t *testing.T) {
+	sender := common.Address{0x1}
+	first := &PHTTransaction{TxHash: common.Hash{0x1}, Sender: sender}
+	second := &PHTTransaction{TxHash: common.Hash{0x2}, Sender: sender}
+	replacement := &PHTTransaction{TxHash: common.Hash{0x3}, Sender: sender}
+
+	store := NewPHTReceiptStore(2)
+	store.Submitted([]*PHTTransaction{first, second})
+	if receipt, ok := store.Receipt(first.TxHash); !ok || receipt.Status != PHTStatusSubmitted {
+		t.Fatalf("Expected submitted receipt, got %+v", receipt)
+	}
+
+	// A replaced PHT points wallets at its replacement
+	store.Superseded(second, replacement)
+	receipt, ok := store.Receipt(second.TxHash)
+	if !ok || receipt.Status != PHTStatusSuperseded || *receipt.SupersededBy != replacement.TxHash {
+		t.Fatalf("Expected superseded receipt, got %+v", receipt)
+	}
+
+	// The oldest receipt beyond the limit is dropped
+	if _, ok := store.Receipt(first.TxHash); ok {
+		t.Fatal("Expected oldest receipt to be pruned")
+	}
+
+	b1Hash, b2Hash := common.Hash{0xb1}, common.Hash{0xb2}
+	store.Included(10, b1Hash, []*PHTTransaction{replacement})
+	store.Revealed(11, b2Hash, []*MTTransaction{{TxHash: replacement.TxHash}}, []*types.Receipt{{TxHash: replacement.TxHash, Status: types.ReceiptStatusFailed, GasUsed: 21000}})
+	receipt, _ = store.Receipt(replacement.TxHash)
+	if receipt.Status != PHTStatusFailed || receipt.B1Hash != b1Hash || receipt.B2Number != 11 || receipt.GasUsed != 21000 {
+		t.Fatalf("Expected failed receipt with both blocks, got %+v", receipt)
+	}
+
+	// Late updates do not move a receipt backwards
+	store.Included(12, common.Hash{0xb3}, []*PHTTransaction{replacement})
+	if receipt, _ := store.Receipt(replacement.TxHash); receipt.Status != PHTStatusFailed || receipt.B1Number != 10 {
+		t.Fatalf("Expected receipt to stay failed, got %+v", receipt)
+	}
+
+	receipts := store.BySender(sender)
+	if len(receipts) != 2 || receipts[0].TxHash != second.TxHash || receipts[1].TxHash != replacement.TxHash {
+		t.Fatalf("Expected sender's two retained receipts in order, got %+v", receipts)
+	}
+}
+
+func TestPHTPipeline(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	txs := make([]*types.Transaction, 16)
+	for i := range txs {
+		tx, err := types.SignTx(types.NewTransaction(uint64(i), common.Address{byte(i)}, big.NewInt(int64(i)), 21000, big.NewInt(1000000000), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		txs[i] = tx
+	}
+
+	config := DefaultP2SConfig()
+	config.PHTWorkers = 4
+	manager := newTestPHTManager(t, config)
+	phts, err := manager.CreatePHTs(txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline := NewPHTPipeline(manager)
+	if err := pipeline.ValidatePHTs(context.Background(), phts); err != nil {
+		t.Fatalf("Expected valid batch, got %v", err)
+	}
+
+	// A PHT without a nonce is rejected, and results keep input order
+	tampered := *phts[5]
+	tampered.Nonce = nil
+	phts[5] = &tampered
+
+	in := make(chan *PHTTransaction)
+	go func() {
+		defer close(in)
+		for _, pht := range phts {
+			in <- pht
+		}
+	}()
+	i := 0
+	for result := range pipeline.Run(context.Background(), in) {
+		if result.PHT != phts[i] {
+			t.Fatalf("Expected result %d in input order", i)
+		}
+		if result.Accepted() != (i != 5) {
+			t.Fatalf("Unexpected outcome for PHT %d: %v", i, result.Err)
+		}
+		i++
+	}
+	if i != len(phts) {
+		t.Fatalf("Expected %d results, got %d", len(phts), i)
+	}
+	if err := pipeline.ValidatePHTs(context.Background(), phts); err == nil || !regexp.MustCompile(`PHT 5 `).MatchString(err.Error()) {
+		t.Fatalf("Expected rejected PHT to be reported, got %v", err)
+	}
+
+	// Cancellation closes the result stream
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pipeline.ValidatePHTs(ctx, phts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation, got %v", err)
+	}
+}
+
+func TestFieldEncoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(1000000000), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pht, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pht.FieldEncoding != CurrentFieldEncoding {
+		t.Fatalf("Expected new PHTs to use the current encoding, got %d", pht.FieldEncoding)
+	}
+
+	// Gas limits sharing their low byte no longer share a commitment
+	fields := &HiddenFields{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding}
+	shifted := *fields
+	shifted.GasLimit += 256
+	if manager.VerifyHiddenFields(pht, &shifted) {
+		t.Fatal("Expected gas limit above 255 to be bound by the commitment")
+	}
+	if !manager.VerifyHiddenFields(pht, fields) {
+		t.Fatal("Expected original fields to open the commitment")
+	}
+
+	// MTs carry the encoding of their PHT
+	mt := &MTTransaction{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding, FieldEncoding: pht.FieldEncoding}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatalf("Expected MT to open its PHT, got %v", err)
+	}
+	mt.GasLimit += 256
+	if err := mtManager.VerifyOpening(mt, pht); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected shifted gas limit to be rejected, got %v", err)
+	}
+
+	// PHTs committed under the legacy encoding still verify
+	scheme := NewPedersenCommitment()
+	legacy := &PHTTransaction{Recipient: common.Address{1}, Value: big.NewInt(5), CallData: []byte{1}, TxType: 2, GasLimit: 8}
+	legacy.Commitment, legacy.Blinding, _ = scheme.Commit(legacy.Recipient.Bytes(), legacy.Value.Bytes(), legacy.CallData, []byte{legacy.TxType}, []byte{byte(legacy.GasLimit)})
+	if !manager.VerifyHiddenFields(legacy, &HiddenFields{Recipient: legacy.Recipient, Value: legacy.Value, CallData: legacy.CallData, TxType: 2, GasLimit: 8, Blinding: legacy.Blinding}) {
+		t.Fatal("Expected legacy-encoded commitment to verify")
+	}
+
+	// Unknown encodings are rejected
+	pht.FieldEncoding = 0xff
+	if manager.VerifyHiddenFields(pht, fields) {
+		t.Fatal("Expected unknown field encoding to fail verification")
+	}
+	data, err := pht.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := new(PHTTransaction).Deserialize(data); !errors.Is(err, ErrUnknownFieldEncoding) {
+		t.Fatalf("Expected unknown field encoding to be rejected on decode, got %v", err)
+	}
+}
+
+func TestContractCreationPHT(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+
+	tx, err := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1000000000), []byte{0x60, 0x00}), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pht, err := manager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pht.IsContractCreation || pht.To() != nil {
+		t.Fatal("Expected PHT to be marked as a contract creation")
+	}
+	if pht.ToTransaction().To() != nil {
+		t.Fatal("Expected contract creation to convert back without a recipient")
+	}
+
+	// The flag is committed, so the reveal cannot turn into a zero-address transfer
+	mt := &MTTransaction{Recipient: pht.Recipient, Value: pht.Value, CallData: pht.CallData, TxType: pht.TxType, GasLimit: pht.GasLimit, Blinding: pht.Blinding, FieldEncoding: pht.FieldEncoding, IsContractCreation: true}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatalf("Expected creation MT to open its PHT, got %v", err)
+	}
+	if mt.ToTransaction().To() != nil {
+		t.Fatal("Expected creation MT to convert back without a recipient")
+	}
+	transfer := *mt
+	transfer.IsContractCreation = false
+	if err := mtManager.VerifyOpening(&transfer, pht); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected zero-address transfer reveal to be rejected, got %v", err)
+	}
+	if transfer.Hash() == mt.Hash() {
+		t.Fatal("Expected creation flag to be covered by the MT hash")
+	}
+
+	// The receipt reports the deployed contract
+	deployed := crypto.CreateAddress(pht.Sender, tx.Nonce())
+	store := NewPHTReceiptStore(0)
+	store.Included(1, common.Hash{1}, []*PHTTransaction{pht})
+	mt.TxHash = pht.TxHash
+	store.Revealed(2, common.Hash{2}, []*MTTransaction{mt}, []*types.Receipt{{TxHash: pht.TxHash, Status: types.ReceiptStatusSuccessful, ContractAddress: deployed}})
+	receipt, _ := store.Receipt(pht.TxHash)
+	if receipt.ContractAddress == nil || *receipt.ContractAddress != deployed {
+		t.Fatalf("Expected receipt to report contract %s, got %v", deployed.Hex(), receipt.ContractAddress)
+	}
+}
+
+// nonceState is a state reader reporting fixed account nonces
+type nonceState map[common.Address]uint64
+
+func (s nonceState) GetBalance(common.Address) *uint256.Int { return uint256.NewInt(0) }
+
+func (s nonceState) GetNonce(address common.Address) uint64 { return s[address] }
+
+func (s nonceState) GetCode(common.Address) []byte { return nil }
+
+func TestPHTNonceOrdering(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+	manager := newTestPHTManager(t, DefaultP2SConfig())
+	mtManager := newTestMTManager(t, DefaultP2SConfig())
+	newPHT := func(nonce uint64, gasPrice int64) *PHTTransaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(gasPrice), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pht, err := manager.CreatePHT(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pht
+	}
+
+	pht := newPHT(7, 1000)
+	if pht.AccountNonce != 7 || pht.ToTransaction().Nonce() != 7 {
+		t.Fatalf("Expected account nonce 7 to be carried, got %d", pht.AccountNonce)
+	}
+
+	// The nonce is bound into the commitment, so the reveal cannot change it
+	mt, err := mtManager.CreateMT(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyOpening(mt, pht); err != nil {
+		t.Fatalf("Expected MT to open its PHT, got %v", err)
+	}
+	mt.AccountNonce = 8
+	if err := mtManager.VerifyOpening(mt, pht); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected changed account nonce to be rejected, got %v", err)
+	}
+	renonced := *pht
+	renonced.AccountNonce = 8
+	if renonced.Hash() == pht.Hash() {
+		t.Fatal("Expected account nonce to be covered by the PHT hash")
+	}
+
+	// B1 assembly orders each sender's PHTs and drops stale nonces and gaps
+	other := &PHTTransaction{Sender: common.Address{0xb}, AccountNonce: 0, GasPrice: big.NewInt(1)}
+	two, one, stale, cheap, gap := newPHT(2, 1000), newPHT(1, 1000), newPHT(0, 1000), newPHT(1, 500), newPHT(4, 1000)
+	ordered, dropped := OrderPHTs([]*PHTTransaction{two, other, cheap, one, stale, gap}, nonceState{pht.Sender: 1})
+	if len(ordered) != 3 || ordered[0] != one || ordered[1] != other || ordered[2] != two {
+		t.Fatalf("Unexpected order: %+v", ordered)
+	}
+	if len(dropped) != 3 {
+		t.Fatalf("Expected stale, duplicate and gapped PHTs to be dropped, got %d", len(dropped))
+	}
+	if err := CheckNonceOrder(ordered); err != nil {
+		t.Fatalf("Expected ordered PHTs to pass, got %v", err)
+	}
+	if err := CheckNonceOrder([]*PHTTransaction{two, one}); !errors.Is(err, ErrNonceOrder) {
+		t.Fatalf("Expected descending nonces to be rejected, got %v", err)
+	}
+
+	// Replacements keep the nonce of the PHT they replace
+	if err := manager.ReplacePHT(one, newPHT(2, 2000)); !errors.Is(err, ErrReplacementNonce) {
+		t.Fatalf("Expected replacement with another nonce to be rejected, got %v", err)
+	}
+
+	// The pool rejects nonces already used
+	config := DefaultP2SConfig()
+	config.MinPHTGasPrice = nil
+	policy := NewAdmissionPolicy(config)
+	policy.SetState(&nonceAdmissionState{nonce: 2})
+	result := policy.Check(one)
+	if result.Admitted || result.Rejections[0].Code != RejectNonceTooLow {
+		t.Fatalf("Expected used nonce to be rejected, got %+v", result)
+	}
+	if result := policy.Check(two); !result.Admitted {
+		t.Fatalf("Expected next nonce to be admitted, got %+v", result)
+	}
+}
+
+// nonceAdmissionState is an admission state reporting a fixed account nonce
+type nonceAdmissionState struct {
+	staticAdmissionState
+	nonce uint64
+}
+
+func (s *nonceAdmissionState) AccountNonce(common.Address) uint64 { return s.nonce }
+
+func TestWithoutHiddenFields(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignTx(types.NewTransaction(3, common.Address{0xa}, big.NewInt(5), 21000, big.NewInt(1000000000), []byte{1, 2}), types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultP2SConfig()
+	config.HiddenFields = []string{"gasPrice"}
+	pht, err := newTestPHTManager(t, config).CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public := pht.WithoutHiddenFields()
+	if public.Recipient != (common.Address{}) || public.Value.Sign() != 0 || public.CallData != nil || public.GasLimit != 0 || public.Blinding != nil || public.GasPrice.Sign() != 0 {
+		t.Fatalf("Expected hidden fields to be stripped, got %+v", public)
+	}
+	if public.Sender != pht.Sender || public.AccountNonce != 3 || !bytes.Equal(public.NonceProof, pht.NonceProof) {
+		t.Fatal("Expected visible fields to be kept")
+	}
+	if pht.Recipient != (common.Address{0xa}) || len(pht.CallData) != 2 {
+		t.Fatal("Expected the original PHT to be left intact")
+	}
+
+	// The stripped PHT is the same PHT to the network
+	if public.Hash() != pht.Hash() {
+		t.Fatal("Expected stripping not to change the PHT hash")
+	}
+	data, err := public.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(PHTTransaction)
+	if err := decoded.Deserialize(data); err != nil || decoded.Hash() != pht.Hash() {
+		t.Fatalf("Expected stripped PHT to round trip, got %v", err)
+	}
+}
+
+func TestPHTJSON(t *testing.T) {
+	pht := &PHTTransaction{
+		ChainID:       big.NewInt(1337),
+		Sender:        common.Address{0x1},
+		AccountNonce:  7,
+		GasPrice:      big.NewInt(1000000000),
+		Commitment:    []byte{0xaa, 0xbb},
+		Nonce:         []byte{0x01},
+		Timestamp:     1700000000,
+		HiddenSet:     HiddenFieldSet(1 << FieldValue),
+		FieldEncoding: CurrentFieldEncoding,
+		Recipient:     common.Address{0x2},
+		Value:         big.NewInt(255),
+		CallData:      []byte{0xde, 0xad},
+		GasLimit:      21000,
+		FieldSalts:    [][]byte{{0x05}},
+		TxHash:        common.Hash{0x3},
+	}
+	data, err := json.Marshal(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"gasPrice":"0x3b9aca00"`, `"callData":"0xdead"`, `"accountNonce":"0x7"`, `"gasLimit":"0x5208"`, `"fieldSalts":["0x05"]`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("Expected %s in %s", want, data)
+		}
+	}
+
+	decoded := new(PHTTransaction)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != pht.Hash() || decoded.Value.Cmp(pht.Value) != 0 || !bytes.Equal(decoded.CallData, pht.CallData) || decoded.GasLimit != 21000 {
+		t.Fatalf("Expected PHT to round trip, got %+v", decoded)
+	}
+
+	mt := &MTTransaction{Recipient: common.Address{0x2}, Value: big.NewInt(255), CallData: []byte{0xbe, 0xef}, GasLimit: 21000, AccountNonce: 7, PHTHash: pht.Hash(), FieldEncoding: CurrentFieldEncoding}
+	data, err = json.Marshal(mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"value":"0xff"`) {
+		t.Fatalf("Expected hex value in %s", data)
+	}
+	decodedMT := new(MTTransaction)
+	if err := json.Unmarshal(data, decodedMT); err != nil || decodedMT.Hash() != mt.Hash() {
+		t.Fatalf("Expected MT to round trip, got %v", err)
+	}
+
+	// PHTs marshalled before hex encoding still decode
+	legacy := new(PHTTransaction)
+	if err := json.Unmarshal([]byte(`{"gasPrice": 1000000000, "value": 100, "callData": "3q0=", "txHash": "0x0300000000000000000000000000000000000000000000000000000000000000"}`), legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.GasPrice.Int64() != 1000000000 || legacy.Value.Int64() != 100 || !bytes.Equal(legacy.CallData, []byte{0xde, 0xad}) {
+		t.Fatalf("Expected legacy PHT to decode, got %+v", legacy)
+	}
+
+	// Out of range quantities are rejected
+	if err := json.Unmarshal([]byte(`{"fieldEncoding": "0x100"}`), new(PHTTransaction)); !errors.Is(err, ErrUnknownFieldEncoding) {
+		t.Fatalf("Expected ErrUnknownFieldEncoding, got %v", err)
+	}
+}
+
+func TestMerkleProofRoundTrip(t *testing.T) {
+	proofSystem := NewMerkleProofSystem()
+	for n := 1; n <= 33; n++ {
+		data := make([][]byte, n)
+		for i := range data {
+			data[i] = bytes.Repeat([]byte{byte(i + 1)}, i%5+1)
+		}
+		for index := 0; index < n; index++ {
+			proof, err := proofSystem.Prove(data[index], data...)
+			if err != nil {
+				t.Fatalf("n=%d index=%d: %v", n, index, err)
+			}
+			if !proofSystem.Verify(proof, data[index], data...) {
+				t.Fatalf("n=%d index=%d: expected proof to verify", n, index)
+			}
+
+			// Proofs do not verify for another leaf
+			other := data[(index+1)%n]
+			if n > 1 && proofSystem.Verify(proof, other, data...) {
+				t.Fatalf("n=%d index=%d: expected proof not to verify for another leaf", n, index)
+			}
+
+			// Tampering with the index, directions or siblings breaks the proof
+			for bit := 0; bit < 64; bit++ {
+				tampered := common.CopyBytes(proof)
+				tampered[bit/8] ^= 1 << (bit % 8)
+				if proofSystem.Verify(tampered, data[index], data...) {
+					t.Fatalf("n=%d index=%d: expected header bit %d to be checked", n, index, bit)
+				}
+			}
+			for offset := 8; offset < len(proof); offset += 32 {
+				tampered := common.CopyBytes(proof)
+				tampered[offset] ^= 0xff
+				if proofSystem.Verify(tampered, data[index], data...) {
+					t.Fatalf("n=%d index=%d: expected sibling at %d to be checked", n, index, offset)
+				}
+			}
+			if proofSystem.Verify(proof[:len(proof)-1], data[index], data...) || proofSystem.Verify(append(common.CopyBytes(proof), 0), data[index], data...) {
+				t.Fatalf("n=%d index=%d: expected proof length to be checked", n, index)
+			}
+		}
+	}
+
+	// Proof vector: the second of two leaves is proven by the hash of the first,
+	// which is its left sibling
+	leaf := sha256.Sum256([]byte{0x00, 0xaa})
+	want := append([]byte{0, 0, 0, 1, 0, 0, 0, 1}, leaf[:]...)
+	proof, err := proofSystem.Prove([]byte{0xbb}, []byte{0xaa}, []byte{0xbb})
+	if err != nil || !bytes.Equal(proof, want) {
+		t.Fatalf("Expected proof %x, got %x (%v)", want, proof, err)
+	}
+
+	// Internal nodes and padding are not leaves
+	second := sha256.Sum256([]byte{0x00, 0xbb})
+	root := sha256.Sum256(append(append([]byte{0x01}, leaf[:]...), second[:]...))
+	if _, err := proofSystem.Prove(root[:], []byte{0xaa}, []byte{0xbb}); err == nil {
+		t.Fatal("Expected internal node not to be provable")
+	}
+	if _, err := proofSystem.Prove(make([]byte, 32), []byte{0xaa}, []byte{0xbb}, []byte{0xcc}); err == nil {
+		t.Fatal("Expected padding not to be provable")
+	}
+}
+
+func TestSNARKProofSystem(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.ProofSystem = "unknown"
+	if _, err := NewProofSystem(config); !errors.Is(err, ErrUnknownProofSystem) {
+		t.Fatalf("Expected ErrUnknownProofSystem, got %v", err)
+	}
+	config.ProofSystem = ProofSystemGroth16
+	if _, err := NewProofSystem(config); !errors.Is(err, ErrSNARKCommitmentScheme) {
+		t.Fatalf("Expected SNARK proofs to need MiMC commitments, got %v", err)
+	}
+	config.CommitmentScheme = CommitmentSchemeMiMC
+	if _, err := NewProofSystem(config); !errors.Is(err, ErrMissingSNARKSetup) {
+		t.Fatalf("Expected ErrMissingSNARKSetup, got %v", err)
+	}
+
+	dir := t.TempDir()
+	vkHash, err := GenerateSNARKSetup(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.SNARKSetupDir = dir
+	config.SNARKVerifyingKeyHash = common.Hash{0x1}
+	if _, err := NewProofSystem(config); !errors.Is(err, ErrSNARKVerifyingKey) {
+		t.Fatalf("Expected pinned verifying key to be checked, got %v", err)
+	}
+	config.SNARKVerifyingKeyHash = vkHash
+	proofSystem, err := NewProofSystem(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prover := proofSystem.(OpeningProver)
+
+	// Proofs show the fields open the commitment, which needs the blinding element
+	scheme := NewMiMCCommitment()
+	items := [][]byte{{0x1}, {0x2, 0x3}}
+	commitment, blinding, err := scheme.Commit(items...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheme.Verify(commitment, blinding, items...) || scheme.Verify(commitment, blinding, []byte{0x1}, []byte{0x2, 0x4}) {
+		t.Fatal("Expected the MiMC commitment to open to its items only")
+	}
+	data := proofLeaves(commitment, items)
+	if _, err := proofSystem.Prove(commitment, data...); !errors.Is(err, ErrOpeningRequired) {
+		t.Fatalf("Expected ErrOpeningRequired, got %v", err)
+	}
+	proof, err := prover.ProveOpening(commitment, blinding, data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proofSystem.Verify(proof, commitment, data...) {
+		t.Fatal("Expected SNARK proof to verify")
+	}
+	if proofSystem.Verify(proof, commitment, proofLeaves(commitment, [][]byte{{0x1}, {0x2, 0x4}})...) {
+		t.Fatal("Expected SNARK proof not to verify for other fields")
+	}
+	other, otherBlinding, err := scheme.Commit(items...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proofSystem.Verify(proof, other, proofLeaves(other, items)...) {
+		t.Fatal("Expected SNARK proof not to verify for another commitment to the same fields")
+	}
+	if _, err := prover.ProveOpening(commitment, otherBlinding, data...); !errors.Is(err, ErrInvalidOpening) {
+		t.Fatalf("Expected a foreign blinding element to be rejected, got %v", err)
+	}
+	if proofSystem.Verify(append(common.CopyBytes(proof), 0), commitment, data...) {
+		t.Fatal("Expected trailing proof bytes to be rejected")
+	}
+	if _, _, err := scheme.Commit(make([][]byte, 17)...); !errors.Is(err, ErrMiMCCapacity) {
+		t.Fatalf("Expected ErrMiMCCapacity, got %v", err)
+	}
+
+	// MT managers configured for SNARKs prove and verify reveals with them
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{0xa}, big.NewInt(5), 21000, big.NewInt(1000000000), nil), types.LatestSignerForChainID(big.NewInt(1337)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pht, err := newTestPHTManager(t, config).CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtManager := newTestMTManager(t, config)
+	mt, err := mtManager.CreateMT(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyMT(mt, pht); err != nil {
+		t.Fatalf("Expected SNARK-proven MT to verify, got %v", err)
+	}
+	merkleConfig := DefaultP2SConfig()
+	merkleConfig.CommitmentScheme = CommitmentSchemeMiMC
+	if newTestMTManager(t, merkleConfig).VerifyMT(mt, pht) == nil {
+		t.Fatal("Expected SNARK proof not to pass as a Merkle proof")
+	}
+
+	// Opening proofs do not aggregate, so blocks keep the per-MT proofs
+	if proof, err := mtManager.ProveBatch([]*PHTTransaction{pht}, []*MTTransaction{mt}); err != nil || proof != nil {
+		t.Fatalf("Expected no batch proof, got %x, %v", proof, err)
+	}
+}
+
+func TestMTBatchProof(t *testing.T) {
+	config := DefaultP2SConfig()
+	phtManager := newTestPHTManager(t, config)
+	mtManager := newTestMTManager(t, config)
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+
+	var phts []*PHTTransaction
+	var mts []*MTTransaction
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0xa}, big.NewInt(int64(nonce+1)), 21000, big.NewInt(1000000000), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pht, err := phtManager.CreatePHTWithKey(tx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mt, err := mtManager.CreateMT(pht)
+		if err != nil {
+			t.Fatal(err)
+		}
+		phts = append(phts, pht)
+		mts = append(mts, mt)
+	}
+
+	proof, err := mtManager.ProveBatch(phts, mts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyBatch(proof, phts, mts); err != nil {
+		t.Fatalf("Expected batch proof to verify, got %v", err)
+	}
+
+	// The proof covers exactly these pairs in this order
+	if err := mtManager.VerifyBatch(proof, phts[:3], mts[:3]); !errors.Is(err, ErrInvalidBatchProof) {
+		t.Fatalf("Expected proof not to cover a subset, got %v", err)
+	}
+	swapped := []*MTTransaction{mts[1], mts[0], mts[2], mts[3]}
+	if err := mtManager.VerifyBatch(proof, phts, swapped); err == nil {
+		t.Fatal("Expected swapped MTs to be rejected")
+	}
+	forged := *mts[2]
+	forged.Value = big.NewInt(1000)
+	if err := mtManager.VerifyBatch(proof, phts, []*MTTransaction{mts[0], mts[1], &forged, mts[3]}); err == nil {
+		t.Fatal("Expected changed reveal to be rejected")
+	}
+	if _, err := mtManager.ProveBatch(phts, mts[:3]); err == nil {
+		t.Fatal("Expected mismatched PHT and MT counts to be rejected")
+	}
+}
+
+func TestMTRLP(t *testing.T) {
+	mt := &MTTransaction{
+		Recipient:          common.Address{0x1},
+		Value:              big.NewInt(1000),
+		CallData:           []byte{0xde, 0xad},
+		TxType:             types.DynamicFeeTxType,
+		GasLimit:           50000,
+		AccountNonce:       4,
+		PHTHash:            common.Hash{0x2},
+		Proof:              []byte{0x3, 0x4},
+		Timestamp:          1700000000,
+		Blinding:           []byte{0x5},
+		AccessList:         types.AccessList{{Address: common.Address{0x6}, StorageKeys: []common.Hash{{0x7}}}},
+		HiddenSet:          HiddenFieldSet(1<<FieldRecipient | 1<<FieldValue | 1<<FieldCallData | 1<<FieldTxType | 1<<FieldGasLimit | 1<<FieldGasPrice),
+		FieldEncoding:      CurrentFieldEncoding,
+		GasPrice:           big.NewInt(2000000000),
+		MaxFeePerGas:       big.NewInt(3000000000),
+		MaxPriorityFeePerGas: big.NewInt(1000000000),
+		ValueBlinding:      []byte{0x8},
+		FieldOpenings:      []*FieldOpening{{Index: 1, Value: []byte{0x9}, Salt: []byte{0xa}, Path: [][]byte{{0xb}}}},
+		TxHash:             common.Hash{0xc},
+	}
+	data, err := mt.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(MTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != mt.Hash() || decoded.TxHash != mt.TxHash || !bytes.Equal(decoded.Proof, mt.Proof) || decoded.AccountNonce != 4 {
+		t.Fatalf("Expected MT to round trip, got %+v", decoded)
+	}
+	if decoded.MaxFeePerGas.Cmp(mt.MaxFeePerGas) != 0 || len(decoded.AccessList) != 1 || len(decoded.FieldOpenings) != 1 || decoded.FieldOpenings[0].Index != 1 {
+		t.Fatal("Expected optional MT fields to round trip")
+	}
+
+	// Malformed input is rejected rather than panicking
+	for i := 0; i < len(data); i++ {
+		if err := new(MTTransaction).Deserialize(data[:i]); err == nil {
+			t.Fatalf("Expected truncated MT of %d bytes to be rejected", i)
+		}
+	}
+	invalid, _ := rlp.EncodeToBytes([]interface{}{uint64(0)})
+	if err := new(MTTransaction).Deserialize(invalid); err == nil {
+		t.Fatal("Expected version 0 to be rejected")
+	}
+}
+
+func FuzzMTRLP(f *testing.F) {
+	seed, err := (&MTTransaction{Value: big.NewInt(1), Proof: []byte{0x1}, TxHash: common.Hash{0x2}}).Serialize()
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	f.Add([]byte{0xc0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		mt := new(MTTransaction)
+		if err := mt.Deserialize(data); err != nil {
+			return
+		}
+		encoded, err := mt.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(MTTransaction)
+		if err := decoded.Deserialize(encoded); err != nil {
+			t.Fatalf("Expected re-encoded MT to decode, got %v", err)
+		}
+		again, err := decoded.Serialize()
+		if err != nil || !bytes.Equal(again, encoded) {
+			t.Fatal("Expected MT encoding to be stable")
+		}
+	})
+}
+
+type bondCustodyState struct {
+	staticAdmissionState
+}
+
+func (s *bondCustodyState) SlashBond(sender common.Address, amount *big.Int) *big.Int {
+	slashed := new(big.Int).Set(amount)
+	if slashed.Cmp(s.bond) > 0 {
+		slashed.Set(s.bond)
+	}
+	s.bond = new(big.Int).Sub(s.bond, slashed)
+	return slashed
+}
+
+func TestRevealDeadline(t *testing.T) {
+	tracker := NewRevealTracker(8 * time.Second)
+	start := time.Unix(1700000000, 0)
+	phts := []*PHTTransaction{
+		{TxHash: common.Hash{0x1}, Sender: common.Address{0xa}},
+		{TxHash: common.Hash{0x2}, Sender: common.Address{0xb}},
+	}
+	tracker.Track(5, common.Hash{0xf}, common.Address{0xc}, phts, start)
+
+	// Nothing is due within the window, and revealed PHTs are never due
+	if missed := tracker.Expire(start.Add(8 * time.Second)); len(missed) != 0 {
+		t.Fatalf("Expected no missed reveals within the window, got %d", len(missed))
+	}
+	tracker.Revealed([]*MTTransaction{{TxHash: common.Hash{0x1}}})
+
+	missed := tracker.Expire(start.Add(9 * time.Second))
+	if len(missed) != 1 || missed[0].TxHash != (common.Hash{0x2}) || missed[0].Proposer != (common.Address{0xc}) || missed[0].B1Number != 5 {
+		t.Fatalf("Expected the unrevealed PHT to miss its deadline, got %+v", missed)
+	}
+	if tracker.Pending() != 0 || len(tracker.Expire(start.Add(time.Hour))) != 0 {
+		t.Fatal("Expected missed reveals to be reported once")
+	}
+
+	// Defaulted PHTs are recorded in their receipts
+	receipts := NewPHTReceiptStore(0)
+	receipts.Submitted(phts)
+	receipts.Included(5, common.Hash{0xf}, phts)
+	receipts.Defaulted(missed)
+	if receipt, _ := receipts.Receipt(common.Hash{0x2}); receipt.Status != PHTStatusDefaulted {
+		t.Fatalf("Expected defaulted receipt, got %s", receipt.Status)
+	}
+
+	// Senders are charged through bonds held by the admission state
+	policy := NewAdmissionPolicy(DefaultP2SConfig())
+	if policy.SlashBond(common.Address{0xb}, big.NewInt(10)).Sign() != 0 {
+		t.Fatal("Expected no slashing without bond custody")
+	}
+	state := &bondCustodyState{staticAdmissionState{bond: big.NewInt(15)}}
+	policy.SetState(state)
+	if slashed := policy.SlashBond(common.Address{0xb}, big.NewInt(10)); slashed.Int64() != 10 || state.bond.Int64() != 5 {
+		t.Fatalf("Expected bond to be slashed by 10, got %v", slashed)
+	}
+	if slashed := policy.SlashBond(common.Address{0xb}, big.NewInt(10)); slashed.Int64() != 5 {
+		t.Fatalf("Expected slashing to be capped at the bond, got %v", slashed)
+	}
+}
+
+func TestPartialReveal(t *testing.T) {
+	config := DefaultP2SConfig()
+	phtManager := newTestPHTManager(t, config)
+	mtManager := newTestMTManager(t, config)
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+
+	var phts []*PHTTransaction
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0xa}, big.NewInt(1), 21000, big.NewInt(1000000000), nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pht, err := phtManager.CreatePHTWithKey(tx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		phts = append(phts, pht)
+	}
+	mt, err := mtManager.CreateMT(phts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeholder := NewUnrevealedMT(phts[1])
+
+	if err := mtManager.ValidateMT(placeholder); err != nil {
+		t.Fatalf("Expected placeholder to be well-formed, got %v", err)
+	}
+	if err := mtManager.VerifyPlaceholder(placeholder, phts[1]); err != nil {
+		t.Fatalf("Expected placeholder to match its PHT, got %v", err)
+	}
+	if err := mtManager.VerifyPlaceholder(placeholder, phts[0]); err == nil {
+		t.Fatal("Expected placeholder not to match another PHT")
+	}
+	if err := mtManager.VerifyMT(placeholder, phts[1]); !errors.Is(err, ErrUnrevealed) {
+		t.Fatalf("Expected ErrUnrevealed, got %v", err)
+	}
+	leaky := *placeholder
+	leaky.CallData = []byte{0x1}
+	if err := mtManager.VerifyPlaceholder(&leaky, phts[1]); err == nil {
+		t.Fatal("Expected placeholder revealing fields to be rejected")
+	}
+	if placeholder.Hash() == (&MTTransaction{Value: new(big.Int), PHTHash: placeholder.PHTHash, Timestamp: placeholder.Timestamp, TxHash: placeholder.TxHash}).Hash() {
+		t.Fatal("Expected placeholder marker to be covered by the MT hash")
+	}
+
+	// Placeholders survive both encodings
+	data, err := placeholder.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(MTTransaction)
+	if err := decoded.Deserialize(data); err != nil || !decoded.Unrevealed {
+		t.Fatalf("Expected placeholder to round trip through RLP, got %v", err)
+	}
+	data, err = json.Marshal(placeholder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = new(MTTransaction)
+	if err := json.Unmarshal(data, decoded); err != nil || !decoded.Unrevealed {
+		t.Fatalf("Expected placeholder to round trip through JSON, got %v", err)
+	}
+
+	// Batch proofs cover blocks mixing reveals and placeholders
+	proof, err := mtManager.ProveBatch(phts, []*MTTransaction{mt, placeholder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyBatch(proof, phts, []*MTTransaction{mt, placeholder}); err != nil {
+		t.Fatalf("Expected partial batch to verify, got %v", err)
+	}
+	if err := mtManager.VerifyBatch(proof, phts, []*MTTransaction{mt, &leaky}); err == nil {
+		t.Fatal("Expected leaky placeholder to be rejected in a batch")
+	}
+}
+
+func TestCarryUnrevealedFollowsHead(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.PartialReveals = true
+	engine := newTestConsensus(t, config)
+	phts, _ := mtPairs(t, config, 3)
+	headA, headB := common.Hash{0xa}, common.Hash{0xb}
+
+	carry := func(head common.Hash, unrevealed []*PHTTransaction) {
+		t.Helper()
+		carried, expired := engine.carryUnrevealed(head, unrevealed)
+		if len(carried) != len(unrevealed) || len(expired) != 0 {
+			t.Fatalf("Expected %d PHTs to be carried, got %d carried and %d expired", len(unrevealed), len(carried), len(expired))
+		}
+	}
+	convert := func(parent common.Hash) []*PHTTransaction {
+		t.Helper()
+		converted, err := engine.convertToPHTs(context.Background(), parent, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return converted
+	}
+
+	// Carried PHTs are only included in a B1 block built on their B2 block
+	carry(headA, phts[:2])
+	if converted := convert(headA); len(converted) != 2 || converted[0] != phts[0] || converted[1] != phts[1] {
+		t.Fatalf("Expected both carried PHTs on their head, got %d", len(converted))
+	}
+	carry(headA, phts[:2])
+	if converted := convert(headB); len(converted) != 0 {
+		t.Fatalf("Expected PHTs carried from another head to be dropped, got %d", len(converted))
+	}
+	if len(engine.carries) != 0 {
+		t.Fatalf("Expected dropped PHTs to leave no carry count, got %d", len(engine.carries))
+	}
+
+	// An imported B2 block replaces what the previous head left unrevealed
+	carry(headA, phts[:2])
+	carry(headB, phts[2:])
+	if converted := convert(headB); len(converted) != 1 || converted[0] != phts[2] {
+		t.Fatalf("Expected only the PHT left by the imported head, got %d", len(converted))
+	}
+	if _, exists := engine.carries[phts[0].TxHash]; exists {
+		t.Fatal("Expected replaced carried PHTs to be forgotten")
+	}
+	if engine.carries[phts[2].TxHash] != 1 {
+		t.Fatalf("Expected the imported head's PHT to be carried once, got %d", engine.carries[phts[2].TxHash])
+	}
+}
+
+// mtPairs creates n PHTs and their MTs from transactions of a single sender
+func mtPairs(tb testing.TB, config *P2SConfig, n int) ([]*PHTTransaction, []*MTTransaction) {
+	tb.Helper()
+	phtManager := newTestPHTManager(tb, config)
+	mtManager := newTestMTManager(tb, config)
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+
+	phts := make([]*PHTTransaction, 0, n)
+	mts := make([]*MTTransaction, 0, n)
+	for nonce := uint64(0); nonce < uint64(n); nonce++ {
+		tx, err := types.SignTx(types.NewTransaction(nonce, common.Address{0xa}, big.NewInt(int64(nonce+1)), 21000, big.NewInt(1000000000), []byte{byte(nonce)}), signer, key)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		pht, err := phtManager.CreatePHTWithKey(tx, key)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		mt, err := mtManager.CreateMT(pht)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		phts = append(phts, pht)
+		mts = append(mts, mt)
+	}
+	return phts, mts
+}
+
+func TestParallelMTVerification(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.MTWorkers = 4
+	mtManager := newTestMTManager(t, config)
+	phts, mts := mtPairs(t, config, 16)
+
+	if err := mtManager.VerifyMTs(context.Background(), phts, mts); err != nil {
+		t.Fatalf("Expected MTs to verify, got %v", err)
+	}
+
+	// Placeholders are checked against their PHT
+	mixed := append([]*MTTransaction(nil), mts...)
+	mixed[3] = NewUnrevealedMT(phts[3])
+	if err := mtManager.VerifyMTs(context.Background(), phts, mixed); err != nil {
+		t.Fatalf("Expected MTs with a placeholder to verify, got %v", err)
+	}
+
+	// The first mismatch in block order is reported
+	mixed[5], mixed[9] = mts[9], mts[5]
+	err := mtManager.VerifyMTs(context.Background(), phts, mixed)
+	if err == nil || !strings.HasPrefix(err.Error(), "MT 5 ") {
+		t.Fatalf("Expected MT 5 to be rejected, got %v", err)
+	}
+	if err := mtManager.VerifyMTs(context.Background(), phts[:2], mts[:3]); err == nil {
+		t.Fatal("Expected more MTs than PHTs to be rejected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := mtManager.VerifyMTs(ctx, phts, mts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancelled verification, got %v", err)
+	}
+}
+
+// BenchmarkMTVerification compares sequential and parallel verification of a
+// full B2 block of MaxMTsPerBlock MTs
+func BenchmarkMTVerification(b *testing.B) {
+	const maxMTsPerBlock = 100
+
+	config := DefaultP2SConfig()
+	mtManager := newTestMTManager(b, config)
+	phts, mts := mtPairs(b, config, maxMTsPerBlock)
+
+	b.Run("sequential", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i, mt := range mts {
+				if err := mtManager.VerifyMT(mt, phts[i]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if err := mtManager.VerifyMTs(context.Background(), phts, mts); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// countingProofSystem counts the proofs a wrapped proof system creates and checks
+type countingProofSystem struct {
+	ProofSystem
+	proved, verified atomic.Int32
+}
+
+func (c *countingProofSystem) Prove(commitment []byte, data ...[]byte) ([]byte, error) {
+	c.proved.Add(1)
+	return c.ProofSystem.Prove(commitment, data...)
+}
+
+func (c *countingProofSystem) Verify(proof []byte, commitment []byte, data ...[]byte) bool {
+	c.verified.Add(1)
+	return c.ProofSystem.Verify(proof, commitment, data...)
+}
+
+func TestProofCache(t *testing.T) {
+	counting := &countingProofSystem{ProofSystem: NewMerkleProofSystem()}
+	if err := RegisterProofSystem("counting-cache", func(*P2SConfig) (ProofSystem, error) { return counting, nil }); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultP2SConfig()
+	phts, _ := mtPairs(t, config, 3)
+	config.ProofSystem = "counting-cache"
+	mtManager := newTestMTManager(t, config)
+
+	mt, err := mtManager.CreateMT(phts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := mtManager.CreateMT(phts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counting.proved.Load() != 1 || !bytes.Equal(again.Proof, mt.Proof) {
+		t.Fatalf("Expected proof to be generated once, got %d proofs", counting.proved.Load())
+	}
+
+	// Proofs generated locally validate without re-verification
+	for i := 0; i < 3; i++ {
+		if err := mtManager.VerifyMT(mt, phts[0]); err != nil {
+			t.Fatalf("Expected MT to verify, got %v", err)
+		}
+	}
+	if counting.verified.Load() != 0 {
+		t.Fatalf("Expected cached proof to skip verification, got %d checks", counting.verified.Load())
+	}
+
+	// Proofs received from another node are verified once
+	remote, err := newTestMTManager(t, DefaultP2SConfig()).CreateMT(phts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := mtManager.VerifyMT(remote, phts[1]); err != nil {
+			t.Fatalf("Expected remote MT to verify, got %v", err)
+		}
+	}
+	if counting.verified.Load() != 1 {
+		t.Fatalf("Expected remote proof to be verified once, got %d checks", counting.verified.Load())
+	}
+
+	// A cached PHT does not vouch for other proofs or revealed fields
+	forged := *remote
+	forged.Proof = append(common.CopyBytes(remote.Proof[:len(remote.Proof)-1]), remote.Proof[len(remote.Proof)-1]^1)
+	if err := mtManager.VerifyMT(&forged, phts[1]); err == nil {
+		t.Fatal("Expected tampered proof to be rejected")
+	}
+	forged = *remote
+	forged.GasLimit++
+	if err := mtManager.VerifyMT(&forged, phts[1]); err == nil {
+		t.Fatal("Expected MT with other revealed fields to be rejected")
+	}
+}
+
+func TestSignedTransactionReveal(t *testing.T) {
+	config := DefaultP2SConfig()
+	phtManager := newTestPHTManager(t, config)
+	mtManager := newTestMTManager(t, config)
+	key, _ := crypto.GenerateKey()
+	signer := types.LatestSignerForChainID(big.NewInt(1337))
+
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     9,
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(3000000000),
+		Gas:       50000,
+		To:        &common.Address{0xa},
+		Value:     big.NewInt(5),
+		Data:      []byte{0x1, 0x2},
+	}), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := tx.MarshalBinary()
+
+	pht, err := phtManager.CreatePHTWithKey(tx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pht.WithoutHiddenFields().SignedTx != nil {
+		t.Fatal("Expected signed transaction to stay hidden in B1")
+	}
+	mt, err := mtManager.CreateMT(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mtManager.VerifyMT(mt, pht); err != nil {
+		t.Fatalf("Expected MT to verify, got %v", err)
+	}
+
+	// The revealed transaction is the one the sender signed
+	for _, revealed := range []*types.Transaction{mt.ToTransaction(), pht.ToTransaction()} {
+		got, _ := revealed.MarshalBinary()
+		if !bytes.Equal(got, want) || revealed.Hash() != tx.Hash() {
+			t.Fatal("Expected revealed transaction to be byte-identical to the signed one")
+		}
+		if sender, err := types.Sender(signer, revealed); err != nil || sender != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Fatalf("Expected revealed transaction to be attributable, got %v", err)
+		}
+	}
+
+	// The payload survives both encodings
+	data, err := mt.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(MTTransaction)
+	if err := decoded.Deserialize(data); err != nil || !bytes.Equal(decoded.SignedTx, want) {
+		t.Fatalf("Expected signed transaction to round trip through RLP, got %v", err)
+	}
+	data, err = json.Marshal(pht)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodedPHT := new(PHTTransaction)
+	if err := json.Unmarshal(data, decodedPHT); err != nil || !bytes.Equal(decodedPHT.SignedTx, want) {
+		t.Fatalf("Expected signed transaction to round trip through JSON, got %v", err)
+	}
+
+	// A payload other than the committed transaction is rejected
+	other, err := types.SignTx(types.NewTransaction(9, common.Address{0xb}, big.NewInt(5), 50000, big.NewInt(3000000000), nil), signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := *mt
+	forged.SignedTx, _ = other.MarshalBinary()
+	if err := mtManager.VerifyMT(&forged, pht); !errors.Is(err, ErrSignedTxMismatch) {
+		t.Fatalf("Expected ErrSignedTxMismatch, got %v", err)
+	}
+}
+
+func TestMTInclusionProof(t *testing.T) {
+	config := DefaultP2SConfig()
+	phts, mts := mtPairs(t, config, 5)
+	mts[2] = NewUnrevealedMT(phts[2])
+
+	// Headers carry the root ahead of the block type byte
+	header := &types.Header{Number: big.NewInt(2), Extra: []byte{0xaa, 2}}
+	setTxRoot(header, MTRoot(mts))
+	if root, ok := TxRoot(header); !ok || root != MTRoot(mts) || header.Extra[0] != 0xaa || header.Extra[len(header.Extra)-1] != 2 {
+		t.Fatalf("Expected MT root in header extra data, got %x", header.Extra)
+	}
+	if PHTRoot(phts) == MTRoot(mts) || MTRoot(nil) != (common.Hash{}) {
+		t.Fatal("Expected distinct roots and a zero root for empty blocks")
+	}
+	engine := newTestConsensus(t, DefaultConfig())
+	engine.cache.SetB2Block(header.Hash(), &B2Block{Header: header, MTs: mts, BlockType: 2})
+
+	for i, mt := range mts {
+		proof, err := engine.GetMTInclusionProof(header.Hash(), mt.TxHash)
+		if i == 2 {
+			if !errors.Is(err, ErrNotInBlock) {
+				t.Fatalf("Expected placeholder not to be provable, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Light clients need only the header and the proof
+		data, err := json.Marshal(proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := new(MTInclusionProof)
+		if err := json.Unmarshal(data, received); err != nil {
+			t.Fatal(err)
+		}
+		if err := received.Verify(header.Hash(), mt.TxHash); err != nil {
+			t.Fatalf("Expected MT %d inclusion to verify, got %v", i, err)
+		}
+		if err := received.Verify(header.Hash(), phts[(i+1)%len(phts)].TxHash); err == nil {
+			t.Fatalf("Expected proof of MT %d not to cover another transaction", i)
+		}
+	}
+
+	proof, err := engine.GetMTInclusionProof(header.Hash(), mts[0].TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := *proof
+	tampered.MT = mts[1]
+	if err := tampered.Verify(header.Hash(), mts[1].TxHash); err == nil {
+		t.Fatal("Expected proof for another leaf to be rejected")
+	}
+	tampered = *proof
+	tampered.Proof = append(hexutil.Bytes{}, proof.Proof...)
+	tampered.Proof[len(tampered.Proof)-1] ^= 1
+	if err := tampered.Verify(header.Hash(), mts[0].TxHash); err == nil {
+		t.Fatal("Expected tampered path to be rejected")
+	}
+	other := types.CopyHeader(header)
+	setTxRoot(other, MTRoot(mts[1:]))
+	tampered = *proof
+	tampered.Header = other
+	if err := tampered.Verify(other.Hash(), mts[0].TxHash); err == nil {
+		t.Fatal("Expected proof against another root to be rejected")
+	}
+	if _, err := engine.GetMTInclusionProof(header.Hash(), common.Hash{0xff}); !errors.Is(err, ErrNotInBlock) {
+		t.Fatalf("Expected ErrNotInBlock, got %v", err)
+	}
+	if _, err := engine.GetMTInclusionProof(common.Hash{0xff}, mts[0].TxHash); err == nil {
+		t.Fatal("Expected unknown block to be rejected")
+	}
+}
+
+func TestMTMismatchErrors(t *testing.T) {
+	config := DefaultP2SConfig()
+	mtManager := newTestMTManager(t, config)
+	phts, mts := mtPairs(t, config, 4)
+
+	tampered := *mts[2]
+	tampered.GasLimit++
+	var gasLimit *ErrGasLimitMismatch
+	if err := mtManager.VerifyMT(&tampered, phts[2]); !errors.As(err, &gasLimit) || gasLimit.Expected != phts[2].GasLimit || gasLimit.Got != phts[2].GasLimit+1 {
+		t.Fatalf("Expected gas limit mismatch, got %v", err)
+	}
+
+	// Block verification records the position of the diverging MT
+	tampered = *mts[2]
+	tampered.Recipient = common.Address{0xee}
+	block := []*MTTransaction{mts[0], mts[1], &tampered, mts[3]}
+	var recipient *ErrRecipientMismatch
+	if err := mtManager.VerifyMTs(context.Background(), phts, block); !errors.As(err, &recipient) || recipient.Index != 2 || *recipient.Got != (common.Address{0xee}) || *recipient.Expected != phts[2].Recipient {
+		t.Fatalf("Expected recipient mismatch at MT 2, got %v", err)
+	}
+
+	tampered = *mts[1]
+	tampered.Value = big.NewInt(1000)
+	var value *ErrValueMismatch
+	if err := mtManager.VerifyMT(&tampered, phts[1]); !errors.As(err, &value) || value.Got.Int64() != 1000 {
+		t.Fatalf("Expected value mismatch, got %v", err)
+	}
+	tampered = *mts[1]
+	tampered.PHTHash = common.Hash{0x1}
+	var phtHash *ErrPHTHashMismatch
+	if err := mtManager.VerifyMT(&tampered, phts[1]); !errors.As(err, &phtHash) || phtHash.Expected != phts[1].Hash() {
+		t.Fatalf("Expected PHT hash mismatch, got %v", err)
+	}
+	tampered = *mts[1]
+	tampered.Proof = []byte{0x1}
+	if err := mtManager.VerifyMT(&tampered, phts[1]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("Expected ErrInvalidProof, got %v", err)
+	}
+
+	tampered = *mts[0]
+	tampered.Proof = nil
+	var missing *ErrMissingField
+	if err := mtManager.ValidateMT(&tampered); !errors.As(err, &missing) || missing.Field != "proof" {
+		t.Fatalf("Expected missing proof, got %v", err)
+	}
+	tampered = *mts[0]
+	tampered.GasLimit = 0
+	var invalid *ErrInvalidField
+	if err := mtManager.ValidateMT(&tampered); !errors.As(err, &invalid) || invalid.Field != "gas limit" {
+		t.Fatalf("Expected invalid gas limit, got %v", err)
+	}
+}
+
+func TestFraudProof(t *testing.T) {
+	config := DefaultP2SConfig()
+	manager := newTestMTManager(t, config)
+	phts, mts := mtPairs(t, config, 4)
+
+	if _, err := manager.GenerateFraudProof(mts[0], phts[0]); !errors.Is(err, ErrNoFraud) {
+		t.Fatalf("Expected ErrNoFraud for a valid MT, got %v", err)
+	}
+
+	// A proposer revealing other call data than the PHT committed to
+	forged := *mts[1]
+	forged.CallData = []byte{0xff}
+	mts[1] = &forged
+
+	b1Header := &types.Header{Number: big.NewInt(1), Extra: []byte{1}}
+	setTxRoot(b1Header, PHTRoot(phts))
+	b2Header := &types.Header{Number: big.NewInt(2), ParentHash: b1Header.Hash(), Coinbase: common.Address{0xb2}, Extra: []byte{2}}
+	setTxRoot(b2Header, MTRoot(mts))
+	b1Block := &B1Block{Header: b1Header, PHTs: phts, BlockType: 1}
+	b2Block := &B2Block{Header: b2Header, MTs: mts, BlockType: 2}
+
+	proof, err := manager.GenerateBlockFraudProof(b1Block, b2Block, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Reason != FraudOpening {
+		t.Fatalf("Expected %s fraud, got %s", FraudOpening, proof.Reason)
+	}
+	if len(proof.PHT.CallData) > 0 || len(proof.MT.Proof) > 0 {
+		t.Fatal("Expected proof without hidden PHT fields or MT proofs")
+	}
+
+	// Other validators check the proof from its JSON encoding alone
+	data, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := new(FraudProof)
+	if err := json.Unmarshal(data, received); err != nil {
+		t.Fatal(err)
+	}
+	if err := newTestMTManager(t, config).VerifyFraudProof(received); err != nil {
+		t.Fatalf("Expected fraud proof to verify, got %v", err)
+	}
+	if proposer, ok := received.Proposer(); !ok || proposer != b2Header.Coinbase {
+		t.Fatalf("Expected proposer %s, got %s", b2Header.Coinbase.Hex(), proposer.Hex())
+	}
+
+	// Tampered proofs must not slash an honest proposer
+	tampered := *proof
+	tampered.MT = mts[0]
+	if err := manager.VerifyFraudProof(&tampered); !errors.Is(err, ErrInvalidFraudProof) {
+		t.Fatalf("Expected proof for a valid MT to be rejected, got %v", err)
+	}
+	tampered = *proof
+	tampered.Reason = FraudValueCommitment
+	if err := manager.VerifyFraudProof(&tampered); !errors.Is(err, ErrInvalidFraudProof) {
+		t.Fatalf("Expected wrong reason to be rejected, got %v", err)
+	}
+	other := forged
+	other.Blinding = []byte{1}
+	tampered = *proof
+	tampered.MT = &other
+	if err := manager.VerifyFraudProof(&tampered); !errors.Is(err, ErrInvalidFraudProof) {
+		t.Fatalf("Expected MT not in the B2 block to be rejected, got %v", err)
+	}
+	tampered = *proof
+	tampered.PHTProof = NewMerkleProofSystem().generateMerkleProof(txTree([]common.Hash{phts[0].Hash(), phts[1].Hash()}), 1)
+	if err := manager.VerifyFraudProof(&tampered); !errors.Is(err, ErrInvalidFraudProof) {
+		t.Fatalf("Expected PHT path under another root to be rejected, got %v", err)
+	}
+	tampered = *proof
+	tampered.B2Header = types.CopyHeader(b2Header)
+	tampered.B2Header.ParentHash = common.Hash{1}
+	if err := manager.VerifyFraudProof(&tampered); !errors.Is(err, ErrInvalidFraudProof) {
+		t.Fatalf("Expected unrelated B1 block to be rejected, got %v", err)
+	}
+
+	// Without block context the proof still shows the mismatch
+	bare, err := manager.GenerateFraudProof(mts[1], phts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.VerifyFraudProof(bare); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bare.Proposer(); ok {
+		t.Fatal("Expected no proposer without block context")
+	}
+}
+
+func TestSparseMerkleProofSystem(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.ProofSystem = ProofSystemSMT
+	system, err := NewProofSystem(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MTs prove their PHT commitment like with Merkle proofs
+	phts, mts := mtPairs(t, config, 3)
+	manager := newTestMTManager(t, config)
+	for i := range mts {
+		if err := manager.VerifyMT(mts[i], phts[i]); err != nil {
+			t.Fatalf("Expected SMT-proven MT %d to verify, got %v", i, err)
+		}
+	}
+	data := [][]byte{[]byte("commitment"), []byte("recipient"), []byte("value")}
+	proof, err := system.Prove(data[0], data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !system.Verify(proof, data[0], data...) || system.Verify(proof, data[1], data...) || system.Verify(proof, data[0], data[1:]...) {
+		t.Fatal("Expected SMT proof to verify only for its commitment and leaves")
+	}
+	if _, err := system.Prove([]byte("other"), data...); err == nil {
+		t.Fatal("Expected proof for a commitment not among the leaves to fail")
+	}
+
+	// A B2 block tree keyed by PHT hash proves both reveals and omissions
+	tree := MTTree(mts[:2])
+	root := tree.Root()
+	for _, mt := range mts[:2] {
+		proof := tree.Prove(mt.PHTHash)
+		if !VerifySMTInclusion(root, mt.PHTHash, mtLeaf(mt).Bytes(), proof) || VerifySMTNonInclusion(root, mt.PHTHash, proof) {
+			t.Fatal("Expected inclusion proof for a revealed PHT")
+		}
+	}
+	omitted := phts[2].Hash()
+	proof = tree.Prove(omitted)
+	if !VerifySMTNonInclusion(root, omitted, proof) {
+		t.Fatal("Expected non-inclusion proof for an omitted PHT")
+	}
+	if VerifySMTInclusion(root, omitted, mtLeaf(mts[2]).Bytes(), proof) || VerifySMTNonInclusion(root, phts[0].Hash(), proof) {
+		t.Fatal("Expected non-inclusion proof not to cover other keys")
+	}
+	tampered := append([]byte{}, proof...)
+	tampered[len(tampered)-1] ^= 1
+	if VerifySMTNonInclusion(root, omitted, tampered) || VerifySMTNonInclusion(root, omitted, proof[:len(proof)-1]) {
+		t.Fatal("Expected tampered proof to be rejected")
+	}
+	if NewSparseMerkleTree().Root() != (common.Hash{}) || MTTree(mts).Root() == root {
+		t.Fatal("Expected zero root for an empty tree and roots binding their keys")
+	}
+}
+
+func TestMTCompression(t *testing.T) {
+	config := DefaultP2SConfig()
+	phts, mts := mtPairs(t, config, 100)
+	mts[3] = NewUnrevealedMT(phts[3])
+
+	for _, codec := range []MTCompression{MTCompressionNone, MTCompressionSnappy, MTCompressionZstd} {
+		data, err := CompressMTs(mts, codec)
+		if err != nil {
+			t.Fatalf("%v: %v", codec, err)
+		}
+		if MTCompression(data[0]) != codec {
+			t.Fatalf("Expected %v codec byte, got %d", codec, data[0])
+		}
+		decoded, err := DecompressMTs(data)
+		if err != nil {
+			t.Fatalf("%v: %v", codec, err)
+		}
+		if len(decoded) != len(mts) {
+			t.Fatalf("%v: expected %d MTs, got %d", codec, len(mts), len(decoded))
+		}
+		for i := range mts {
+			if decoded[i].Hash() != mts[i].Hash() || !bytes.Equal(decoded[i].Proof, mts[i].Proof) {
+				t.Fatalf("%v: MT %d changed in transfer", codec, i)
+			}
+		}
+		if err := newTestMTManager(t, config).VerifyMTs(context.Background(), phts, decoded); err != nil {
+			t.Fatalf("%v: expected decompressed MTs to verify, got %v", codec, err)
+		}
+	}
+
+	if _, err := CompressMTs(mts, MTCompression(9)); !errors.Is(err, ErrUnknownMTCompression) {
+		t.Fatalf("Expected ErrUnknownMTCompression, got %v", err)
+	}
+	if _, err := DecompressMTs([]byte{9, 0}); !errors.Is(err, ErrUnknownMTCompression) {
+		t.Fatalf("Expected ErrUnknownMTCompression, got %v", err)
+	}
+	if _, err := DecompressMTs(nil); err == nil {
+		t.Fatal("Expected empty batch to be rejected")
+	}
+
+	// A small payload must not expand beyond the batch limit
+	bomb := append([]byte{byte(MTCompressionSnappy)}, snappy.Encode(nil, make([]byte, 17*1024*1024))...)
+	if _, err := DecompressMTs(bomb); !errors.Is(err, ErrMTBatchTooLarge) {
+		t.Fatalf("Expected ErrMTBatchTooLarge, got %v", err)
+	}
+}
+
+func TestProofSystemVersioning(t *testing.T) {
+	merkleConfig := DefaultP2SConfig()
+	phts, legacy := mtPairs(t, merkleConfig, 2)
+	if id, version := ProofVersionOf(legacy[0].Proof); id != 0 || version != 0 {
+		t.Fatalf("Expected Merkle proofs without envelope, got system %d version %d", id, version)
+	}
+
+	// After the fork new MTs carry SMT proofs
+	forkConfig := DefaultP2SConfig()
+	forkConfig.ProofSystem = ProofSystemSMT
+	forkConfig.ProofSystemHistory = []string{ProofSystemMerkle, ProofSystemGroth16}
+	forked := newTestMTManager(t, forkConfig)
+	mt, err := forked.CreateMT(phts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, version := ProofVersionOf(mt.Proof); id != ProofSystemIDSMT || version != 1 {
+		t.Fatalf("Expected SMT version 1 proof, got system %d version %d", id, version)
+	}
+
+	// Historical blocks keep verifying alongside new ones, while an unavailable
+	// historical system is left out
+	if err := forked.VerifyMT(legacy[0], phts[0]); err != nil {
+		t.Fatalf("Expected historical Merkle proof to verify, got %v", err)
+	}
+	if err := forked.VerifyMT(mt, phts[1]); err != nil {
+		t.Fatalf("Expected SMT proof to verify, got %v", err)
+	}
+
+	// Nodes not accepting a proof system reject its proofs
+	if err := newTestMTManager(t, merkleConfig).VerifyMT(mt, phts[1]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("Expected SMT proof to be rejected before the fork, got %v", err)
+	}
+	smtOnly := DefaultP2SConfig()
+	smtOnly.ProofSystem = ProofSystemSMT
+	if err := newTestMTManager(t, smtOnly).VerifyMT(legacy[0], phts[0]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("Expected Merkle proof to be rejected without history, got %v", err)
+	}
+
+	// Proofs claiming an unknown format version are rejected
+	unknown := *mt
+	unknown.Proof = common.CopyBytes(mt.Proof)
+	unknown.Proof[2]++
+	if err := newTestMTManager(t, forkConfig).VerifyMT(&unknown, phts[1]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("Expected unknown proof version to be rejected, got %v", err)
+	}
+}
+
+func TestRevealSignature(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.RequireRevealSignatures = true
+	phts, mts := mtPairs(t, config, 2)
+	manager := newTestMTManager(t, config)
+
+	if len(phts[0].RevealSignature) != crypto.SignatureLength || !bytes.Equal(mts[0].RevealSignature, phts[0].RevealSignature) {
+		t.Fatal("Expected the sender's reveal signature to be carried into the MT")
+	}
+	if phts[0].WithoutHiddenFields().RevealSignature != nil {
+		t.Fatal("Expected reveal signature to stay hidden in B1")
+	}
+	if err := manager.VerifyMT(mts[0], phts[0]); err != nil {
+		t.Fatalf("Expected signed reveal to verify, got %v", err)
+	}
+
+	// The signature survives transfer
+	data, err := mts[0].Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(MTTransaction)
+	if err := decoded.Deserialize(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.RevealSignature, mts[0].RevealSignature) || decoded.Hash() != mts[0].Hash() {
+		t.Fatal("Expected reveal signature to round-trip through RLP")
+	}
+
+	// A proposer cannot sign a reveal for someone else's commitment
+	proposer, _ := crypto.GenerateKey()
+	forged := *mts[0]
+	forged.RevealSignature, err = crypto.Sign(RevealDigest(forged.PHTHash, nil).Bytes(), proposer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.VerifyMT(&forged, phts[0]); !errors.Is(err, ErrInvalidRevealSignature) {
+		t.Fatalf("Expected ErrInvalidRevealSignature, got %v", err)
+	}
+	forged.RevealSignature = mts[1].RevealSignature
+	if err := manager.VerifyMT(&forged, phts[0]); !errors.Is(err, ErrInvalidRevealSignature) {
+		t.Fatalf("Expected signature over another PHT to be rejected, got %v", err)
+	}
+	forged.RevealSignature = nil
+	if err := manager.VerifyMT(&forged, phts[0]); !errors.Is(err, ErrMissingRevealSignature) {
+		t.Fatalf("Expected ErrMissingRevealSignature, got %v", err)
+	}
+	if err := newTestMTManager(t, DefaultP2SConfig()).VerifyMT(&forged, phts[0]); err != nil {
+		t.Fatalf("Expected unsigned reveal to verify where not required, got %v", err)
+	}
+
+	// Placeholders carry no reveal signature
+	placeholder := NewUnrevealedMT(phts[1])
+	placeholder.RevealSignature = mts[1].RevealSignature
+	if err := manager.VerifyPlaceholder(placeholder, phts[1]); err == nil {
+		t.Fatal("Expected placeholder with reveal signature to be rejected")
+	}
+}
+
+func TestStreamingB2Assembly(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.MTWorkers = 2
+	phts, mts := mtPairs(t, config, 6)
+	header := &types.Header{Number: big.NewInt(1), Extra: []byte{1}}
+	b1Block := &B1Block{Header: header, PHTs: phts, BlockType: 1}
+
+	engine := newTestConsensus(t, config)
+	engine.cache.SetB1Block(header.Hash(), b1Block)
+	if _, _, err := engine.AssembleB2(context.Background(), common.Hash{0xff}, nil); err == nil {
+		t.Fatal("Expected assembly for an unknown B1 block to fail")
+	}
+
+	in := make(chan *MTTransaction)
+	assembler, results, err := engine.AssembleB2(context.Background(), header.Hash(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reveals arrive out of order, with a forged and a repeated one
+	forged := *mts[2]
+	forged.CallData = []byte{0xff}
+	stream := []*MTTransaction{mts[5], &forged, mts[3], mts[0], mts[3], NewUnrevealedMT(&PHTTransaction{Commitment: []byte{1}})}
+	go func() {
+		for _, mt := range stream {
+			in <- mt
+		}
+	}()
+	rejected := make(map[*MTTransaction]error)
+	for i := 0; i < len(stream); i++ {
+		result := <-results
+		if !result.Accepted() {
+			rejected[result.MT] = result.Err
+		}
+	}
+	if len(rejected) != 3 || rejected[&forged] == nil {
+		t.Fatalf("Expected the forged, repeated and unknown reveals to be rejected, got %v", rejected)
+	}
+	var mismatch *ErrCallDataMismatch
+	if !errors.As(rejected[&forged], &mismatch) || mismatch.Index != 2 {
+		t.Fatalf("Expected call data mismatch of MT 2, got %v", rejected[&forged])
+	}
+	if missing := assembler.Missing(); len(missing) != 3 || missing[0] != phts[1].Hash() {
+		t.Fatalf("Expected PHTs 1, 2 and 4 to be missing, got %d", len(missing))
+	}
+	if _, err := assembler.MTs(); !errors.Is(err, ErrB2Incomplete) {
+		t.Fatalf("Expected ErrB2Incomplete, got %v", err)
+	}
+	select {
+	case <-assembler.Ready():
+		t.Fatal("Expected assembler not to be ready with PHTs missing")
+	default:
+	}
+
+	// The block is ready once the last PHT is revealed
+	go func() {
+		for _, i := range []int{1, 2, 4} {
+			in <- mts[i]
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		if result := <-results; !result.Accepted() {
+			t.Fatalf("Expected reveal to be accepted, got %v", result.Err)
+		}
+	}
+	select {
+	case <-assembler.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("Expected assembler to be ready")
+	}
+	if _, ok := <-results; ok {
+		t.Fatal("Expected results to close once ready")
+	}
+	assembled, err := assembler.MTs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range mts {
+		if assembled[i] != mts[i] {
+			t.Fatalf("Expected MT %d in block position", i)
+		}
+	}
+}
+
+func TestVerkleProofSystem(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.ProofSystem = ProofSystemVerkle
+	verkle, err := NewProofSystem(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proofs stay one commitment and opening per level however many leaves
+	small, err := verkle.Prove([]byte{0xaa}, []byte{0xaa}, []byte{0xbb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := make([][]byte, 1000)
+	for i := range leaves {
+		leaves[i] = []byte{byte(i), byte(i >> 8)}
+	}
+	large, err := verkle.Prove(leaves[999], leaves...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(small) != len(large) || len(large) != 4+96 {
+		t.Fatalf("Expected constant size Verkle proofs, got %d and %d bytes", len(small), len(large))
+	}
+	if !verkle.Verify(large, leaves[999], leaves...) {
+		t.Fatal("Expected Verkle proof over a large leaf set to verify")
+	}
+	tampered := common.CopyBytes(large)
+	tampered[len(tampered)-1] ^= 1
+	if verkle.Verify(tampered, leaves[999], leaves...) {
+		t.Fatal("Expected tampered opening to be rejected")
+	}
+
+	// Proofs verify against the root alone; a valid proof over another tree does not
+	root, err := VerkleRoot(leaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyVerkleInclusion(root, len(leaves), leaves[999], large) {
+		t.Fatal("Expected Verkle proof to verify against the root")
+	}
+	if VerifyVerkleInclusion(root, len(leaves), leaves[998], large) {
+		t.Fatal("Expected Verkle proof not to cover another leaf")
+	}
+	if VerifyVerkleInclusion(root, 4097, leaves[999], large) {
+		t.Fatal("Expected Verkle proof of the wrong depth to be rejected")
+	}
+	foreign, err := verkle.Prove(leaves[999], leaves[999], []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyVerkleInclusion(root, len(leaves), leaves[999], foreign) || verkle.Verify(foreign, leaves[999], leaves...) {
+		t.Fatal("Expected a proof over another tree to be rejected")
+	}
+
+	// MTs proven with Verkle proofs verify on nodes accepting them
+	phts, mts := mtPairs(t, config, 2)
+	if id, _ := ProofVersionOf(mts[0].Proof); id != ProofSystemIDVerkle {
+		t.Fatalf("Expected Verkle proof envelope, got system %d", id)
+	}
+	if err := newTestMTManager(t, config).VerifyMT(mts[0], phts[0]); err != nil {
+		t.Fatalf("Expected Verkle-proven MT to verify, got %v", err)
+	}
+	if err := newTestMTManager(t, DefaultP2SConfig()).VerifyMT(mts[1], phts[1]); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("Expected Verkle proof to be rejected by a Merkle-only node, got %v", err)
+	}
+}
+
+// TestProofSystemDifferential checks that every built-in proof backend accepts and
+// rejects the same statements
+func TestProofSystemDifferential(t *testing.T) {
+	var systems []ProofSystem
+	for _, name := range []string{ProofSystemMerkle, ProofSystemSMT, ProofSystemVerkle} {
+		config := DefaultP2SConfig()
+		config.ProofSystem = name
+		system, err := NewProofSystem(config)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		systems = append(systems, system)
+	}
+	names := []string{ProofSystemMerkle, ProofSystemSMT, ProofSystemVerkle}
+
+	sets := [][][]byte{
+		{[]byte("commitment")},
+		{[]byte("commitment"), []byte("recipient"), []byte("value")},
+		{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")},
+		{{}, {0}, {0, 0}, bytes.Repeat([]byte{0xff}, 300)},
+	}
+	for n, data := range sets {
+		for i, commitment := range data {
+			for s, system := range systems {
+				proof, err := system.Prove(commitment, data...)
+				if err != nil {
+					t.Fatalf("%s set %d leaf %d: %v", names[s], n, i, err)
+				}
+				if !system.Verify(proof, commitment, data...) {
+					t.Fatalf("%s set %d leaf %d: expected proof to verify", names[s], n, i)
+				}
+				if system.Verify(proof, []byte("absent"), data...) {
+					t.Fatalf("%s set %d leaf %d: expected proof not to cover an absent commitment", names[s], n, i)
+				}
+				altered := append(append([][]byte{}, data...), []byte("extra"))
+				if system.Verify(proof, commitment, altered...) {
+					t.Fatalf("%s set %d leaf %d: expected proof not to cover other leaves", names[s], n, i)
+				}
+				if system.Verify(nil, commitment, data...) {
+					t.Fatalf("%s set %d leaf %d: expected empty proof to be rejected", names[s], n, i)
+				}
+				if system.Verify(proof[:len(proof)-1], commitment, data...) {
+					t.Fatalf("%s set %d leaf %d: expected truncated proof to be rejected", names[s], n, i)
+				}
+				for j, leaf := range data {
+					if !bytes.Equal(leaf, commitment) && system.Verify(proof, leaf, data...) {
+						t.Fatalf("%s set %d leaf %d: expected proof not to cover leaf %d", names[s], n, i, j)
+					}
+				}
+
+				// A proof over another leaf set holding the commitment does not carry over
+				foreign, err := system.Prove(commitment, commitment, []byte("foreign"))
+				if err != nil {
+					t.Fatalf("%s set %d leaf %d: %v", names[s], n, i, err)
+				}
+				if system.Verify(foreign, commitment, data...) {
+					t.Fatalf("%s set %d leaf %d: expected proof over another leaf set to be rejected", names[s], n, i)
+				}
+
+				// No backend accepts another's proof
+				for o, other := range systems {
+					if o != s && other.Verify(proof, commitment, data...) {
+						t.Fatalf("%s proof accepted by %s", names[s], names[o])
+					}
+				}
+			}
+		}
+		for s, system := range systems {
+			if _, err := system.Prove([]byte("absent"), data...); err == nil {
+				t.Fatalf("%s set %d: expected proof of an absent commitment to fail", names[s], n)
+			}
+		}
+	}
+}
+
+func TestB2Body(t *testing.T) {
+	config := DefaultP2SConfig()
+	phts, mts := mtPairs(t, config, 50)
+	mts[3] = NewUnrevealedMT(phts[3])
+
+	data, err := (&B2Body{MTs: mts}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := rlp.EncodeToBytes(mts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) >= len(plain) {
+		t.Fatalf("Expected shared addresses to shrink the batch, got %d bytes against %d in RLP", len(data), len(plain))
+	}
+
+	var body B2Body
+	if err := body.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.MTs) != len(mts) {
+		t.Fatalf("Expected %d MTs, got %d", len(mts), len(body.MTs))
+	}
+	for i := range mts {
+		if body.MTs[i].Hash() != mts[i].Hash() || !bytes.Equal(body.MTs[i].Proof, mts[i].Proof) || body.MTs[i].Sender != mts[i].Sender {
+			t.Fatalf("MT %d changed in encoding", i)
+		}
+	}
+	if err := newTestMTManager(t, config).VerifyMTs(context.Background(), phts, body.MTs); err != nil {
+		t.Fatalf("Expected decoded MTs to verify, got %v", err)
+	}
+
+	// Repeated selectors are stored once and restored into the call data
+	token := common.HexToAddress("0x6b175474e89094c44da98b954eedeac495271d0f")
+	transfers := make([]*MTTransaction, 0, 10)
+	for i := 0; i < 10; i++ {
+		mt := *mts[i+10]
+		mt.Recipient = token
+		mt.CallData = erc20Transfer(token, mt.Sender, common.Address{byte(i + 1)}, int64(i)).CallData
+		transfers = append(transfers, &mt)
+	}
+	transfers[9].CallData = []byte{0xa9, 0x05}
+	data, err = (&B2Body{MTs: transfers}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte{0xa9, 0x05, 0x9c, 0xbb}); n != 1 {
+		t.Fatalf("Expected the shared selector once, found %d times", n)
+	}
+
+	// The streaming decoder yields MTs as their frames arrive
+	dec, err := NewB2BodyDecoder(iotest.OneByteReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec.Len() != len(transfers) {
+		t.Fatalf("Expected %d MTs to decode, got %d", len(transfers), dec.Len())
+	}
+	for i := range transfers {
+		mt, err := dec.Next()
+		if err != nil {
+			t.Fatalf("MT %d: %v", i, err)
+		}
+		if !bytes.Equal(mt.CallData, transfers[i].CallData) || mt.Recipient != token || mt.Hash() != transfers[i].Hash() {
+			t.Fatalf("MT %d changed in encoding", i)
+		}
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Fatalf("Expected io.EOF after the last MT, got %v", err)
+	}
+
+	// Malformed bodies are rejected
+	if err := new(B2Body).UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrInvalidB2Body) {
+		t.Fatalf("Expected truncated body to be rejected, got %v", err)
+	}
+	if err := new(B2Body).UnmarshalBinary(append(data, 0)); !errors.Is(err, ErrInvalidB2Body) {
+		t.Fatalf("Expected trailing data to be rejected, got %v", err)
+	}
+	if err := new(B2Body).UnmarshalBinary([]byte{2, 0, 0, 0}); !errors.Is(err, ErrInvalidB2Body) {
+		t.Fatalf("Expected unknown version to be rejected, got %v", err)
+	}
+	if err := new(B2Body).UnmarshalBinary([]byte{1, 0xff, 0xff, 0xff, 0xff, 0x0f}); !errors.Is(err, ErrMTBatchTooLarge) {
+		t.Fatalf("Expected oversized dictionary to be rejected, got %v", err)
+	}
+	if err := new(B2Body).UnmarshalBinary([]byte{1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 3, 0xc2, 0x01, 0x05}); !errors.Is(err, ErrInvalidB2Body) {
+		t.Fatalf("Expected malformed frame to be rejected, got %v", err)
+	}
+
+	// Bodies are stored by B2 hash
+	db := memorydb.New()
+	hash := common.HexToHash("0xb2")
+	if err := WriteB2Body(db, hash, &B2Body{MTs: mts}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := ReadB2Body(db, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.MTs) != len(mts) || stored.MTs[0].Hash() != mts[0].Hash() {
+		t.Fatal("Expected the stored body to load back")
+	}
+}
+
+func TestDeterministicProposerSelection(t *testing.T) {
+	config := DefaultP2SConfig()
+	stakes := make(map[common.Address]*big.Int)
+	addresses := make([]common.Address, 0, 6)
+	for i := 0; i < 6; i++ {
+		address := common.Address{byte(i + 1)}
+		stakes[address] = new(big.Int).Mul(config.MinStake, big.NewInt(int64(i+1)))
+		addresses = append(addresses, address)
+	}
+	heavy := addresses[5]
+
+	// Nodes learn the validators in different orders
+	nodes := make([]*ValidatorManager, 4)
+	for n := range nodes {
+		nodes[n] = NewValidatorManager(config)
+		for i := range addresses {
+			address := addresses[(i+n)%len(addresses)]
+			if err := nodes[n].AddValidator(address, stakes[address]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	picks := make(map[common.Address]int)
+	for height := uint64(1); height <= 600; height++ {
+		parentHash := crypto.Keccak256Hash(new(big.Int).SetUint64(height - 1).Bytes())
+		proposer, err := nodes[0].SelectProposer(height, parentHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n, node := range nodes[1:] {
+			if other, _ := node.SelectProposer(height, parentHash); other != proposer {
+				t.Fatalf("Node %d selected %s at height %d, node 0 %s", n+1, other.Hex(), height, proposer.Hex())
+			}
+		}
+		if again, _ := nodes[0].SelectProposer(height, parentHash); again != proposer {
+			t.Fatalf("Expected repeated selection at height %d to agree", height)
+		}
+		picks[proposer]++
+	}
+
+	// Every validator proposes, the heaviest most often
+	if len(picks) != len(addresses) {
+		t.Fatalf("Expected all %d validators to propose, got %d", len(addresses), len(picks))
+	}
+	if picks[heavy] <= picks[addresses[0]] {
+		t.Fatalf("Expected the heaviest validator to propose more often, got %d against %d", picks[heavy], picks[addresses[0]])
+	}
+
+	// The seed changes the draw
+	changed := false
+	for height := uint64(1); height <= 50 && !changed; height++ {
+		a, _ := nodes[0].SelectProposer(height, common.Hash{0x01})
+		b, _ := nodes[0].SelectProposer(height, common.Hash{0x02})
+		changed = a != b
+	}
+	if !changed {
+		t.Fatal("Expected the parent hash seed to change the selection")
+	}
+
+	// Inactive validators are never selected
+	if err := nodes[0].UpdateStake(heavy, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	for height := uint64(1); height <= 100; height++ {
+		if proposer, _ := nodes[0].SelectProposer(height, common.Hash{}); proposer == heavy {
+			t.Fatalf("Expected inactive validator not to propose at height %d", height)
+		}
+	}
+}
+
+func TestProposerSelectionAlgorithms(t *testing.T) {
+	config := DefaultP2SConfig()
+	a, b, c := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+	stakes := map[common.Address]int64{a: 1, b: 2, c: 3}
+	newManager := func(selection string) *ValidatorManager {
+		config := *config
+		config.ProposerSelection = selection
+		manager := NewValidatorManager(&config)
+		for _, address := range []common.Address{c, a, b} {
+			if err := manager.AddValidator(address, new(big.Int).Mul(config.MinStake, big.NewInt(stakes[address]))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return manager
+	}
+	schedule := func(manager *ValidatorManager, from, to uint64) []common.Address {
+		var proposers []common.Address
+		for height := from; height < to; height++ {
+			proposer, err := manager.SelectProposer(height, common.Hash{byte(height)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			proposers = append(proposers, proposer)
+		}
+		return proposers
+	}
+
+	// Round-robin visits validators in address order, ignoring stake and seed
+	if got, want := schedule(newManager(ProposerSelectionRoundRobin), 0, 6), []common.Address{a, b, c, a, b, c}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected round-robin schedule %v, got %v", want, got)
+	}
+
+	// Priority rotation gives each validator one block per unit of stake per period
+	priority := newManager(ProposerSelectionPriority)
+	want := []common.Address{c, b, a, c, b, c}
+	if got := schedule(priority, 0, 6); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected priority schedule %v, got %v", want, got)
+	}
+	if got := schedule(priority, 6, 12); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the priority schedule to repeat, got %v", got)
+	}
+	if got := schedule(priority, 0, 6); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected selections to be repeatable, got %v", got)
+	}
+
+	// Schedules preview the rotation
+	var previewed []common.Address
+	for _, assignment := range priority.ProposerSchedule(12, 6, common.Hash{}) {
+		previewed = append(previewed, assignment.B1Proposer)
+	}
+	if !reflect.DeepEqual(previewed, want) {
+		t.Fatalf("Expected previewed schedule %v, got %v", want, previewed)
+	}
+	if got := schedule(priority, 12, 18); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the preview to match the selections, got %v", got)
+	}
+
+	// The rotation is replayed for the current validator set, so nodes that start
+	// late select the same proposers as nodes that followed the chain
+	d := common.Address{0x04}
+	if err := priority.AddValidator(d, new(big.Int).Mul(config.MinStake, big.NewInt(6))); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := schedule(priority, 18, 24), []common.Address{c, d, b, d, c, d}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the rotation to follow the new validator set, got %v", got)
+	}
+	if err := priority.UpdateStake(c, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := schedule(priority, 24, 30), []common.Address{d, b, d, d, b, d}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the schedule to follow the validator set, got %v", got)
+	}
+	restarted := newManager(ProposerSelectionPriority)
+	if err := restarted.AddValidator(d, new(big.Int).Mul(config.MinStake, big.NewInt(6))); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.UpdateStake(c, big.NewInt(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := schedule(restarted, 27, 30), []common.Address{d, b, d}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected a restarted node to select the same proposers, got %v", got)
+	}
+
+	// Unknown algorithms are rejected, the manager falling back to the weighted draw
+	config.ProposerSelection = "lottery"
+	if _, err := NewValidatorSelection(config); !errors.Is(err, ErrUnknownProposerSelection) {
+		t.Fatalf("Expected ErrUnknownProposerSelection, got %v", err)
+	}
+	if _, err := newManager("lottery").SelectProposer(1, common.Hash{}); err != nil {
+		t.Fatalf("Expected fallback selection to work, got %v", err)
+	}
+}
+
+func TestStakeDelegation(t *testing.T) {
+	config := DefaultP2SConfig()
+	manager := NewValidatorManager(config)
+	validator, other := common.Address{0x01}, common.Address{0x02}
+	alice, bob := common.Address{0xa1}, common.Address{0xb0}
+	for _, address := range []common.Address{validator, other} {
+		if err := manager.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var passed []*big.Int
+	manager.SetDelegationHooks(&DelegationHooks{
+		DelegatorReward: func(from, delegator common.Address, amount *big.Int) {
+			if from != validator {
+				t.Errorf("Expected rewards of %s, got %s", validator.Hex(), from.Hex())
+			}
+			passed = append(passed, amount)
+		},
+	})
+
+	// Delegated stake adds to the effective stake
+	if err := manager.Delegate(alice, validator, new(big.Int).Mul(config.MinStake, big.NewInt(2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Delegate(bob, validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Delegate(alice, validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if stake := manager.GetValidator(validator).EffectiveStake(); stake.Cmp(new(big.Int).Mul(config.MinStake, big.NewInt(5))) != 0 {
+		t.Fatalf("Expected effective stake of 5 minimum stakes, got %v", stake)
+	}
+	if total := manager.GetTotalStake(); total.Cmp(new(big.Int).Mul(config.MinStake, big.NewInt(6))) != 0 {
+		t.Fatalf("Expected total stake to include delegations, got %v", total)
+	}
+	if top := manager.GetTopValidators(1); top[0].Address != validator {
+		t.Fatalf("Expected the delegated validator on top, got %s", top[0].Address.Hex())
+	}
+	if delegation := manager.GetDelegation(alice, validator); delegation == nil || delegation.Amount.Cmp(new(big.Int).Mul(config.MinStake, big.NewInt(3))) != 0 {
+		t.Fatalf("Expected alice to have 3 minimum stakes bonded, got %+v", delegation)
+	}
+	if delegations := manager.GetDelegations(validator); len(delegations) != 2 || delegations[0].Delegator != alice || delegations[1].Delegator != bob {
+		t.Fatalf("Expected delegations of alice and bob, got %+v", delegations)
+	}
+
+	// Rewards pass through to delegators by stake, the validator keeping the rest
+	own, err := manager.DistributeReward(validator, big.NewInt(1003))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if own.Int64() != 1003-601-200 {
+		t.Fatalf("Expected the validator to keep 202, got %v", own)
+	}
+	if len(passed) != 2 || passed[0].Int64() != 601 || passed[1].Int64() != 200 {
+		t.Fatalf("Expected 601 and 200 passed through, got %v", passed)
+	}
+	if rewards, err := manager.ClaimDelegatorRewards(bob, validator); err != nil || rewards.Int64() != 200 {
+		t.Fatalf("Expected bob to claim 200, got %v, %v", rewards, err)
+	}
+	if rewards, _ := manager.ClaimDelegatorRewards(bob, validator); rewards.Sign() != 0 {
+		t.Fatalf("Expected claimed rewards to reset, got %v", rewards)
+	}
+
+	// Undelegating reduces the effective stake and cannot exceed the bond
+	if err := manager.Undelegate(bob, validator, new(big.Int).Mul(config.MinStake, big.NewInt(2))); !errors.Is(err, ErrInsufficientDelegation) {
+		t.Fatalf("Expected ErrInsufficientDelegation, got %v", err)
+	}
+	if err := manager.Undelegate(bob, validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if manager.GetDelegation(bob, validator) != nil {
+		t.Fatal("Expected an empty delegation to be dropped")
+	}
+	if stake := manager.GetValidator(validator).EffectiveStake(); stake.Cmp(new(big.Int).Mul(config.MinStake, big.NewInt(4))) != 0 {
+		t.Fatalf("Expected effective stake of 4 minimum stakes, got %v", stake)
+	}
+	if delegations := manager.GetDelegatorDelegations(alice); len(delegations) != 1 || delegations[0].Validator != validator {
+		t.Fatalf("Expected alice's delegation to be listed, got %+v", delegations)
+	}
+
+	if err := manager.Delegate(alice, common.Address{0xff}, config.MinStake); !errors.Is(err, ErrInvalidDelegation) {
+		t.Fatalf("Expected delegation to an unknown validator to be rejected, got %v", err)
+	}
+	if err := manager.Delegate(alice, other, big.NewInt(0)); !errors.Is(err, ErrInvalidDelegation) {
+		t.Fatalf("Expected an empty delegation to be rejected, got %v", err)
+	}
+}
+
+func TestSlashing(t *testing.T) {
+	config := DefaultConfig()
+	engine := newTestConsensus(t, config)
+	leaderKey, _ := crypto.GenerateKey()
+	memberKey, _ := crypto.GenerateKey()
+	proposerKey, _ := crypto.GenerateKey()
+	leader, member := crypto.PubkeyToAddress(leaderKey.PublicKey), crypto.PubkeyToAddress(memberKey.PublicKey)
+	proposer, honest := crypto.PubkeyToAddress(proposerKey.PublicKey), common.Address{0x0e}
+	for address, units := range map[common.Address]int64{leader: 10, member: 1, proposer: 10, honest: 2} {
+		if err := engine.validatorMgr.AddValidator(address, new(big.Int).Mul(config.MinStake, big.NewInt(units))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign := func(proposal *SealProposal, key *ecdsa.PrivateKey) []byte {
+		sig, err := SignSealProposal(proposal, crypto.FromECDSA(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	first := &SealProposal{BlockNumber: 5, Leader: leader, PHTHashes: []common.Hash{{1}}}
+	second := &SealProposal{BlockNumber: 5, Leader: leader, PHTHashes: []common.Hash{{2}}}
+	credited := new(big.Int)
+	checkRecord := func(record *SlashingRecord, offender common.Address, amount *big.Int) {
+		t.Helper()
+		if record.Validator != offender || record.Amount.Cmp(amount) != 0 {
+			t.Fatalf("Expected %v slashed from %s, got %v from %s", amount, offender.Hex(), record.Amount, record.Validator.Hex())
+		}
+		sum := new(big.Int).Set(record.Burned)
+		for _, share := range record.Redistributed {
+			sum.Add(sum, share)
+		}
+		if sum.Cmp(record.Amount) != 0 || record.Burned.Cmp(new(big.Int).Div(record.Amount, big.NewInt(2))) < 0 {
+			t.Fatalf("Expected half the slashed stake burned and the rest redistributed, got %+v", record)
+		}
+		if _, ok := record.Redistributed[offender]; ok {
+			t.Fatal("Expected the offender not to receive its own slashed stake")
+		}
+		credited.Add(credited, bigOrZero(record.Redistributed[honest]))
+	}
+
+	// A leader signing two orderings for one block loses 10% of its stake
+	double := &DoubleProposalEvidence{First: first, Second: second, FirstSignature: sign(first, leaderKey), SecondSignature: sign(second, leaderKey)}
+	record, err := engine.SubmitSlashingEvidence(5, double)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecord(record, leader, config.MinStake)
+	if stake := engine.GetValidatorInfo(leader).Stake; stake.Cmp(new(big.Int).Mul(config.MinStake, big.NewInt(9))) != 0 {
+		t.Fatalf("Expected 9 minimum stakes left, got %v", stake)
+	}
+	if _, err := engine.SubmitSlashingEvidence(5, double); !errors.Is(err, ErrDuplicateEvidence) {
+		t.Fatalf("Expected ErrDuplicateEvidence, got %v", err)
+	}
+	if _, err := engine.SubmitSlashingEvidence(5, &DoubleProposalEvidence{First: first, Second: second, FirstSignature: double.FirstSignature, SecondSignature: sign(second, memberKey)}); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected proposal signed by another key to be rejected, got %v", err)
+	}
+	if _, err := engine.SubmitSlashingEvidence(5, &DoubleProposalEvidence{First: first, Second: first, FirstSignature: double.FirstSignature, SecondSignature: double.FirstSignature}); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected identical proposals to be rejected, got %v", err)
+	}
+
+	// A member co-signing proposals of two leaders for one block equivocates
+	rival := &SealProposal{BlockNumber: 5, Leader: honest, PHTHashes: []common.Hash{{1}}}
+	record, err = engine.SubmitSlashingEvidence(5, &EquivocationEvidence{Signer: member, First: first, Second: rival, FirstSignature: sign(first, memberKey), SecondSignature: sign(rival, memberKey)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecord(record, member, new(big.Int).Div(config.MinStake, big.NewInt(10)))
+	if engine.validatorMgr.IsActiveValidator(member) {
+		t.Fatal("Expected a member slashed below the minimum stake to be deactivated")
+	}
+
+	// A B2 proposer publishing a fraudulent MT is slashed on its fraud proof
+	phts, mts := mtPairs(t, DefaultP2SConfig(), 2)
+	forged := *mts[1]
+	forged.CallData = []byte{0xff}
+	mts[1] = &forged
+	b1Header := &types.Header{Number: big.NewInt(5), Extra: []byte{1}}
+	setTxRoot(b1Header, PHTRoot(phts))
+	b2Header := &types.Header{Number: big.NewInt(6), ParentHash: b1Header.Hash(), Coinbase: proposer, Extra: []byte{2}}
+	setTxRoot(b2Header, MTRoot(mts))
+	proof, err := engine.mtManager.GenerateBlockFraudProof(&B1Block{Header: b1Header, PHTs: phts, BlockType: 1}, &B2Block{Header: b2Header, MTs: mts, BlockType: 2}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evidence := &InvalidRevealEvidence{Proof: proof}
+	if err := engine.SubmitRevealEvidence(evidence); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected evidence about unknown blocks to be rejected, got %v", err)
+	}
+	b2Block := &B2Block{Header: b2Header, MTs: mts, BlockType: 2, B1BlockHash: b1Header.Hash()}
+	engine.cache.SetB1Block(b1Header.Hash(), &B1Block{Header: b1Header, PHTs: phts, BlockType: 1})
+	engine.cache.SetB2Block(b2Header.Hash(), b2Block)
+	if err := engine.SubmitRevealEvidence(evidence); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected evidence against an unsealed B2 block to be rejected, got %v", err)
+	}
+	forgedSeal, _ := crypto.Sign(proposerSigningHash(2, b2Header.Hash()).Bytes(), memberKey)
+	b2Block.ProposerSig = forgedSeal
+	if err := engine.SubmitRevealEvidence(evidence); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected evidence against a B2 block sealed by another validator to be rejected, got %v", err)
+	}
+	b2Block.ProposerSig, _ = crypto.Sign(proposerSigningHash(2, b2Header.Hash()).Bytes(), proposerKey)
+	if _, err := engine.SubmitSlashingEvidence(6, evidence); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected reveal evidence to be slashed through blocks only, got %v", err)
+	}
+	if err := engine.SubmitRevealEvidence(evidence); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SubmitRevealEvidence(evidence); !errors.Is(err, ErrDuplicateEvidence) {
+		t.Fatalf("Expected pending evidence not to be queued twice, got %v", err)
+	}
+	bare, _ := engine.mtManager.GenerateFraudProof(mts[1], phts[1])
+	if err := engine.SubmitRevealEvidence(&InvalidRevealEvidence{Proof: bare}); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected fraud proof without block context to be rejected, got %v", err)
+	}
+
+	// The next B1 block includes the evidence and slashes the proposer when processed
+	included := &B1Block{Header: &types.Header{Number: big.NewInt(7)}, BlockType: 1, RevealEvidence: engine.takeRevealEvidence()}
+	if len(included.RevealEvidence) != 1 || len(engine.takeRevealEvidence()) != 0 {
+		t.Fatal("Expected the pending evidence to be included once")
+	}
+	records := engine.applyBlockEvidence(7, included)
+	if len(records) != 1 {
+		t.Fatalf("Expected one slashing from the included evidence, got %d", len(records))
+	}
+	record = records[0]
+	checkRecord(record, proposer, config.MinStake)
+	if record.Offense != OffenseInvalidReveal || record.Height != 6 {
+		t.Fatalf("Expected invalid reveal at 6, got %s at %d", record.Offense, record.Height)
+	}
+
+	// History is kept per validator and redistributed stake is paid as rewards
+	if history := engine.GetSlashingHistory(leader); len(history) != 1 || history[0].Offense != OffenseDoubleProposal {
+		t.Fatalf("Expected one double proposal in the leader's history, got %+v", history)
+	}
+	if len(engine.GetSlashingHistory(honest)) != 0 {
+		t.Fatal("Expected no slashings of the honest validator")
+	}
+	if _, err := engine.SealPaymentEpoch(0, leaderKey); err != nil {
+		t.Fatal(err)
+	}
+	statement, err := engine.GetPaymentStatement(0, honest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credited.Sign() == 0 || statement.Rewards.Cmp(credited) != 0 {
+		t.Fatalf("Expected %v credited to the honest validator, got %v", credited, statement.Rewards)
+	}
+}
+
+func TestReputationDecay(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.ReputationDecayHalfLife = 100
+	config.ReputationRecoveryHalfLife = 400
+	manager := NewValidatorManager(config)
+	good, penalized := common.Address{0x01}, common.Address{0x02}
+	for _, address := range []common.Address{good, penalized} {
+		if err := manager.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager.UpdateReputation(good, 400)
+	manager.UpdateReputation(penalized, -600)
+
+	expect := func(height uint64, goodWant, penalizedWant int64) {
+		t.Helper()
+		manager.AdvanceHeight(height)
+		if got := manager.GetValidator(good).Reputation; got != goodWant {
+			t.Fatalf("Height %d: expected good reputation %d, got %d", height, goodWant, got)
+		}
+		if got := manager.GetValidator(penalized).Reputation; got != penalizedWant {
+			t.Fatalf("Height %d: expected penalized reputation %d, got %d", height, penalizedWant, got)
+		}
+	}
+	// Good reputation decays within a half-life, penalties recover along the slower curve
+	expect(100, 300, -425)
+	expect(400, 125, -200)
+	expect(50, 125, -200)
+
+	// Updates restart the curve from the current reputation
+	manager.UpdateReputation(penalized, 10)
+	expect(800, 101, -45)
+	expect(100000, 100, 100)
+
+	if got := (ReputationCurve{}).At(500, 1000000); got != 500 {
+		t.Fatalf("Expected reputation without half-lives to stay, got %d", got)
+	}
+}
+
+func TestValidatorPerformance(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.B2BlockTime = 6 * time.Second
+	config.RevealGracePeriod = 2 * time.Second
+	manager := NewValidatorManager(config)
+	validator, unknown := common.Address{0x01}, common.Address{0x02}
+	if err := manager.AddValidator(validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+
+	// Produced blocks earn reputation, B1 blocks by their MEV score
+	manager.RecordB1Proposal(validator, 1, 0.9)
+	manager.RecordB2Proposal(validator, 2, 3, 4*time.Second)
+	manager.RecordB1Proposal(validator, 3, 0.7)
+	if got := manager.GetValidator(validator).Reputation; got != 105 {
+		t.Fatalf("Expected reputation 105 after producing blocks, got %d", got)
+	}
+
+	// Late reveals, missed reveals and missed slots cost reputation
+	manager.RecordB2Proposal(validator, 4, 1, 12*time.Second)
+	manager.RecordMissedReveal(validator)
+	manager.RecordMissedSlot(validator)
+	if got := manager.GetValidator(validator).Reputation; got != 88 {
+		t.Fatalf("Expected reputation 88 after misses, got %d", got)
+	}
+
+	performance := manager.GetPerformance(validator)
+	if performance.B1Proposals != 2 || performance.B2Proposals != 2 || performance.MissedSlots != 1 || performance.MissedReveals != 1 || performance.Reveals != 4 {
+		t.Fatalf("Unexpected performance counts %+v", performance)
+	}
+	if performance.AverageRevealLatency != 8*time.Second {
+		t.Fatalf("Expected average reveal latency 8s, got %v", performance.AverageRevealLatency)
+	}
+	if math.Abs(performance.AverageMEVScore-0.8) > 1e-9 {
+		t.Fatalf("Expected average MEV score 0.8, got %v", performance.AverageMEVScore)
+	}
+	if performance.LastBlock != 4 || manager.GetValidator(validator).LastBlock != 4 {
+		t.Fatalf("Expected last block 4, got %d", performance.LastBlock)
+	}
+
+	// Returned performance is a copy
+	performance.B1Proposals = 100
+	if manager.GetPerformance(validator).B1Proposals != 2 {
+		t.Fatal("Expected performance to be returned as a copy")
+	}
+
+	// Unknown validators are not tracked, removed ones start over
+	manager.RecordMissedSlot(unknown)
+	if manager.GetPerformance(unknown) != nil {
+		t.Fatal("Expected no performance for an unknown validator")
+	}
+	if err := manager.RemoveValidator(validator); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.AddValidator(validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if performance := manager.GetPerformance(validator); performance.B1Proposals != 0 || performance.MissedSlots != 0 {
+		t.Fatalf("Expected performance reset after removal, got %+v", performance)
+	}
+}
+
+func TestValidatorPersistence(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.ValidatorSnapshotInterval = 10
+	db := memorydb.New()
+	if version, err := MigrateDatabase(db, nil); err != nil || version != SchemaVersion {
+		t.Fatalf("Expected database migrated to version %d, got %d: %v", SchemaVersion, version, err)
+	}
+
+	// A validator set in memory is snapshotted into an empty database
+	manager := NewValidatorManager(config)
+	a, b, c, delegator := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}, common.Address{0x04}
+	for _, address := range []common.Address{a, b} {
+		if err := manager.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := db.Has([]byte("p2s-validator-snapshot")); !has {
+		t.Fatal("Expected the validator set to be snapshotted")
+	}
+
+	// Changes are written through and survive a restart
+	manager.UpdateReputation(a, 50)
+	if err := manager.Delegate(delegator, a, big.NewInt(1000)); err != nil {
+		t.Fatal(err)
+	}
+	manager.RecordB1Proposal(b, 3, 0.5)
+	if err := manager.AddValidator(c, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.RemoveValidator(c); err != nil {
+		t.Fatal(err)
+	}
+	countRecords := func() int {
+		it := db.NewIterator([]byte("p2s-validator-record-"), nil)
+		defer it.Release()
+		count := 0
+		for it.Next() {
+			count++
+		}
+		return count
+	}
+	if count := countRecords(); count != 3 {
+		t.Fatalf("Expected 3 written through records, got %d", count)
+	}
+
+	check := func(loaded *ValidatorManager) {
+		t.Helper()
+		if loaded.GetValidatorCount() != 2 || loaded.IsValidator(c) {
+			t.Fatalf("Expected validators a and b, got %d validators", loaded.GetValidatorCount())
+		}
+		if got := loaded.GetValidator(a).Reputation; got != 150 {
+			t.Fatalf("Expected reputation 150, got %d", got)
+		}
+		if delegation := loaded.GetDelegation(delegator, a); delegation == nil || delegation.Amount.Cmp(big.NewInt(1000)) != 0 {
+			t.Fatalf("Expected delegation restored, got %+v", delegation)
+		}
+		if got := loaded.GetValidator(a).Delegated; got.Cmp(big.NewInt(1000)) != 0 {
+			t.Fatalf("Expected delegated stake restored, got %v", got)
+		}
+		if performance := loaded.GetPerformance(b); performance.B1Proposals != 1 || performance.AverageMEVScore != 0.5 {
+			t.Fatalf("Expected performance restored, got %+v", performance)
+		}
+	}
+	restarted := NewValidatorManager(config)
+	if err := restarted.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	check(restarted)
+
+	// Periodic snapshots replace the written through records
+	restarted.AdvanceHeight(10)
+	if count := countRecords(); count != 0 {
+		t.Fatalf("Expected records replaced by the snapshot, got %d", count)
+	}
+	restarted = NewValidatorManager(config)
+	if err := restarted.SetDatabase(db); err != nil {
+		t.Fatal(err)
+	}
+	check(restarted)
+	restarted.RecordB1Proposal(b, 11, 0.5)
+	if got := restarted.GetPerformance(b).AverageMEVScore; got != 0.5 {
+		t.Fatalf("Expected performance totals restored, got average %v", got)
+	}
+}
+
+func TestValidatorJailing(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.JailMissedSlots = 3
+	config.JailDuration = 100
+	manager := NewValidatorManager(config)
+	a, b := common.Address{0x01}, common.Address{0x02}
+	for _, address := range []common.Address{a, b} {
+		if err := manager.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only consecutive misses jail
+	manager.RecordMissedSlot(a)
+	manager.RecordMissedSlot(a)
+	manager.RecordB1Proposal(a, 1, 0.8)
+	manager.RecordMissedSlot(a)
+	manager.RecordMissedSlot(a)
+	if manager.GetValidator(a).Jailed {
+		t.Fatal("Expected misses interrupted by a proposal not to jail")
+	}
+	manager.RecordMissedSlot(a)
+	validator := manager.GetValidator(a)
+	if !validator.Jailed || validator.JailedUntil != 100 || !validator.IsActive {
+		t.Fatalf("Expected an active validator jailed until 100, got %+v", validator)
+	}
+
+	// Jailed validators are excluded from selection
+	for number := uint64(0); number < 20; number++ {
+		if proposer, err := manager.SelectProposer(number, common.Hash{byte(number)}); err != nil || proposer != b {
+			t.Fatalf("Block %d: expected proposer %s, got %s (%v)", number, b.Hex(), proposer.Hex(), err)
+		}
+	}
+	if selected := manager.SelectValidators(2); len(selected) != 1 || selected[0] != b {
+		t.Fatalf("Expected only the unjailed validator selected, got %v", selected)
+	}
+
+	// Unjailing requires the jail period to be over and the minimum stake
+	manager.AdvanceHeight(50)
+	if err := manager.Unjail(a); !errors.Is(err, ErrJailPeriod) {
+		t.Fatalf("Expected ErrJailPeriod, got %v", err)
+	}
+	manager.AdvanceHeight(100)
+	if err := manager.UpdateStake(a, new(big.Int).Sub(config.MinStake, big.NewInt(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Unjail(a); !errors.Is(err, ErrUnjailStake) {
+		t.Fatalf("Expected ErrUnjailStake, got %v", err)
+	}
+	if err := manager.UpdateStake(a, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Unjail(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Unjail(a); !errors.Is(err, ErrNotJailed) {
+		t.Fatalf("Expected ErrNotJailed, got %v", err)
+	}
+	if manager.GetValidator(a).Jailed || len(manager.SelectValidators(2)) != 2 {
+		t.Fatal("Expected the unjailed validator back in selection")
+	}
+
+	// Slashing jails the offender
+	if _, err := manager.Slash(b, big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	if validator := manager.GetValidator(b); !validator.Jailed || validator.JailedUntil != 200 {
+		t.Fatalf("Expected the slashed validator jailed until 200, got %+v", validator)
+	}
+}
+
+func TestUnbondingQueue(t *testing.T) {
+	config := DefaultP2SConfig()
+	config.EpochLength = 10
+	config.UnbondingEpochs = 2
+	manager := NewValidatorManager(config)
+	validator := common.Address{0x01}
+	stake := func(units int64) *big.Int {
+		return new(big.Int).Mul(config.MinStake, big.NewInt(units))
+	}
+	if err := manager.AddValidator(validator, stake(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Decreases enter the queue, increases bond at once
+	manager.AdvanceHeight(15)
+	if err := manager.UpdateStake(validator, stake(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.UpdateStake(validator, stake(3)); err != nil {
+		t.Fatal(err)
+	}
+	manager.AdvanceHeight(25)
+	if err := manager.UpdateStake(validator, stake(2)); err != nil {
+		t.Fatal(err)
+	}
+	if got := manager.GetValidator(validator).Stake; got.Cmp(stake(2)) != 0 {
+		t.Fatalf("Expected bonded stake %v, got %v", stake(2), got)
+	}
+	if got := manager.SlashableStake(validator); got.Cmp(stake(4)) != 0 {
+		t.Fatalf("Expected slashable stake %v, got %v", stake(4), got)
+	}
+	queue := manager.GetUnbondingQueue()
+	if len(queue) != 2 || queue[0].CompletionEpoch != 3 || queue[0].Height != 15 || queue[1].CompletionEpoch != 4 {
+		t.Fatalf("Unexpected unbonding queue %+v", queue)
+	}
+
+	// Unbonding stake stays slashable, most recently removed first
+	half := new(big.Int).Div(config.MinStake, big.NewInt(2))
+	slashed, err := manager.Slash(validator, new(big.Int).Add(stake(2), half))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slashed.Cmp(new(big.Int).Add(stake(2), half)) != 0 {
+		t.Fatalf("Expected bonded and unbonding stake slashed, got %v", slashed)
+	}
+	entries := manager.GetUnbonding(validator)
+	if len(entries) != 2 || entries[0].Amount.Cmp(stake(1)) != 0 || entries[1].Amount.Cmp(half) != 0 {
+		t.Fatalf("Unexpected unbonding after slashing %+v", entries)
+	}
+
+	// Entries are released at their completion epoch
+	if released := manager.ProcessUnbonding(2); len(released) != 0 {
+		t.Fatalf("Expected nothing released before completion, got %+v", released)
+	}
+	if released := manager.ProcessUnbonding(3); len(released) != 1 || released[0].Amount.Cmp(stake(1)) != 0 {
+		t.Fatalf("Expected the first entry released, got %+v", released)
+	}
+	if released := manager.ProcessUnbonding(4); len(released) != 1 || released[0].Amount.Cmp(half) != 0 {
+		t.Fatalf("Expected the second entry released, got %+v", released)
+	}
+	if queue := manager.GetUnbondingQueue(); len(queue) != 0 {
+		t.Fatalf("Expected an empty queue, got %+v", queue)
+	}
+
+	// Without unbonding epochs stake is released at once
+	config.UnbondingEpochs = 0
+	instant := NewValidatorManager(config)
+	if err := instant.AddValidator(validator, stake(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := instant.UpdateStake(validator, stake(1)); err != nil {
+		t.Fatal(err)
+	}
+	if len(instant.GetUnbondingQueue()) != 0 || instant.SlashableStake(validator).Cmp(stake(1)) != 0 {
+		t.Fatal("Expected removed stake released at once")
+	}
+}
+
+func TestAggregateBlockSignatures(t *testing.T) {
+	engine := newTestConsensus(t, DefaultConfig())
+	validators := []common.Address{{0x01}, {0x02}, {0x03}, {0x04}}
+	keys := make(map[common.Address]*BLSSecretKey)
+	for _, validator := range validators {
+		if err := engine.validatorMgr.AddValidator(validator, DefaultConfig().MinStake); err != nil {
+			t.Fatal(err)
+		}
+		key, err := GenerateBLSKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[validator] = key
+	}
+
+	// Keys are registered with a proof of possession and cannot be shared
+	proofs := make(map[common.Address][]byte)
+	for _, validator := range validators {
+		proof, err := keys[validator].ProvePossession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs[validator] = proof
+	}
+	if err := engine.RegisterBLSKey(validators[0], keys[validators[0]].PublicKey(), proofs[validators[1]]); !errors.Is(err, ErrInvalidBLSKey) {
+		t.Fatalf("Expected ErrInvalidBLSKey for a foreign proof of possession, got %v", err)
+	}
+	for _, validator := range validators {
+		if err := engine.RegisterBLSKey(validator, keys[validator].PublicKey(), proofs[validator]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := engine.RegisterBLSKey(validators[1], keys[validators[0]].PublicKey(), proofs[validators[0]]); !errors.Is(err, ErrInvalidBLSKey) {
+		t.Fatalf("Expected ErrInvalidBLSKey for a shared key, got %v", err)
+	}
+	keySet := engine.validatorMgr.BLSKeySet()
+	if len(keySet.Keys) != len(validators) {
+		t.Fatalf("Expected %d keys, got %d", len(validators), len(keySet.Keys))
+	}
+
+	b1Block := &B1Block{Header: &types.Header{Number: big.NewInt(1)}, BlockType: 1}
+	sign := func(hash common.Hash, signers ...common.Address) *AggregateSignature {
+		t.Helper()
+		signatures := make(map[common.Address][]byte)
+		for _, signer := range signers {
+			sig, err := keys[signer].Sign(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			signatures[signer] = sig
+		}
+		aggregate, err := NewAggregateSignature(keySet, signatures)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return aggregate
+	}
+
+	// Three of four validators hold a quorum
+	aggregate := sign(b1Block.SigningHash(), validators[0], validators[2], validators[3])
+	if !aggregate.Signed(0) || aggregate.Signed(1) || !aggregate.Signed(3) {
+		t.Fatalf("Unexpected participation bitmap %x", aggregate.Bitmap)
+	}
+	encoded, err := aggregate.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(AggregateSignature)
+	if err := decoded.UnmarshalBinary(encoded); err != nil || !bytes.Equal(decoded.Bitmap, aggregate.Bitmap) || !bytes.Equal(decoded.Signature, aggregate.Signature) {
+		t.Fatalf("Aggregate signature did not round trip: %v", err)
+	}
+	if err := decoded.UnmarshalBinary(encoded[:len(encoded)-1]); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected truncated encoding to be rejected, got %v", err)
+	}
+	b1Block.ValidatorSig = encoded
+	if err := b1Block.ValidateSignature(keySet); err != nil {
+		t.Fatalf("Expected aggregate signature to verify: %v", err)
+	}
+
+	// Signatures of another block, claimed signers that did not sign and missing quorum fail
+	b2Block := &B2Block{Header: b1Block.Header, BlockType: 2, ValidatorSig: encoded}
+	if err := b2Block.ValidateSignature(keySet); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected a B1 signature to fail for the B2 block, got %v", err)
+	}
+	claimed := &AggregateSignature{Bitmap: []byte{0x0f}, Signature: aggregate.Signature}
+	if err := keySet.Verify(b1Block.SigningHash(), claimed); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected an overstated bitmap to fail, got %v", err)
+	}
+	if err := keySet.Verify(b1Block.SigningHash(), sign(b1Block.SigningHash(), validators[0], validators[1])); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected half the stake to miss quorum, got %v", err)
+	}
+
+	// The engine verifies signatures before attaching them to cached blocks
+	hash := b1Block.Header.Hash()
+	engine.cache.SetB1Block(hash, &B1Block{Header: b1Block.Header, BlockType: 1})
+	if err := engine.SignBlock(hash, claimed); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected SignBlock to reject an invalid aggregate, got %v", err)
+	}
+	if err := engine.SignBlock(hash, aggregate); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := engine.cache.GetB1Block(hash); !bytes.Equal(cached.ValidatorSig, encoded) {
+		t.Fatal("Expected the aggregate signature attached to the block")
+	}
+
+	// Imported blocks with a bad bitmap or signature are rejected
+	header := &types.Header{Number: big.NewInt(2), Extra: []byte{1}}
+	setTxRoot(header, PHTRoot(nil))
+	imported := &B1Block{Header: header, BlockType: 1}
+	engine.cache.SetB1Block(header.Hash(), imported)
+	valid, err := sign(imported.SigningHash(), validators[0], validators[1], validators[2]).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overstated, err := (&AggregateSignature{Bitmap: []byte{0x0f}, Signature: aggregate.Signature}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ValidateBlock(nil, types.NewBlockWithHeader(header)); errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected an unsigned block to pass unless signatures are required, got %v", err)
+	}
+	engine.config.RequireBlockSignatures = true
+	for name, sig := range map[string][]byte{"missing": nil, "bitmap": overstated, "signature": encoded} {
+		imported.ValidatorSig = sig
+		if err := engine.ValidateBlock(nil, types.NewBlockWithHeader(header)); !errors.Is(err, ErrInvalidAggregateSignature) {
+			t.Fatalf("Expected a block with a bad %s to be rejected, got %v", name, err)
+		}
+	}
+	imported.ValidatorSig = valid
+	if err := engine.ValidateBlock(nil, types.NewBlockWithHeader(header)); errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected the quorum signature to verify, got %v", err)
+	}
+}
+
+// testChain serves the headers of a chain built in a test
+type testChain struct {
+	consensus.ChainReader
+	headers map[common.Hash]*types.Header
+}
+
+func (c *testChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.headers[hash]
+}
+
+func TestValidateBlockRoundTrip(t *testing.T) {
+	config := DefaultConfig()
+	config.RequireBlockSignatures = true
+	engine := newTestConsensus(t, config)
+	key, _ := crypto.GenerateKey()
+	blsKey, err := GenerateBLSKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewLocalSigner(key, blsKey)
+	if err := engine.validatorMgr.AddValidator(signer.Address(), config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetSigner(signer); err != nil {
+		t.Fatal(err)
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Time: 1000}
+	chain := &testChain{headers: map[common.Hash]*types.Header{genesis.Hash(): genesis}}
+
+	// The producer seals each block and the validators sign it
+	sign := func(hash common.Hash) {
+		t.Helper()
+		if err := engine.SignProposal(hash); err != nil {
+			t.Fatal(err)
+		}
+		share, err := engine.SignBlockShare(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.AggregateBlockShares(hash, []*BlockSignature{share}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	phts, mts := mtPairs(t, config, 2)
+	b1Header := &types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Coinbase: signer.Address(), Time: genesis.Time + 1, Extra: []byte{1}}
+	setTxRoot(b1Header, PHTRoot(phts))
+	b1Hash := b1Header.Hash()
+	engine.cache.SetB1Block(b1Hash, &B1Block{Header: b1Header, PHTs: phts, BlockType: 1, MEVScore: 1})
+	chain.headers[b1Hash] = b1Header
+	b1 := types.NewBlockWithHeader(b1Header)
+	if err := engine.ValidateBlock(chain, b1); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected an unsigned B1 block to be rejected, got %v", err)
+	}
+	sign(b1Hash)
+	if err := engine.ValidateBlock(chain, b1); err != nil {
+		t.Fatalf("Expected the signed B1 block to validate, got %v", err)
+	}
+
+	b2Header := &types.Header{Number: big.NewInt(2), ParentHash: b1Hash, Coinbase: signer.Address(), Time: b1Header.Time + 1, Extra: []byte{2}}
+	setTxRoot(b2Header, MTRoot(mts))
+	b2Hash := b2Header.Hash()
+	engine.cache.SetB2Block(b2Hash, &B2Block{Header: b2Header, MTs: mts, BlockType: 2, B1BlockHash: b1Hash})
+	b2 := types.NewBlockWithHeader(b2Header)
+	if err := engine.SignProposal(b2Hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ValidateBlock(chain, b2); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected a B2 block without validator signature to be rejected, got %v", err)
+	}
+	sign(b2Hash)
+	if err := engine.ValidateBlock(chain, b2); err != nil {
+		t.Fatalf("Expected the signed B2 block to validate, got %v", err)
+	}
+
+	// Rotating the BLS key does not invalidate blocks signed before
+	rotated, err := GenerateBLSKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetSigner(NewLocalSigner(key, rotated)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := engine.validatorMgr.BLSKeySet(); !bytes.Equal(keys.Keys[0].PublicKey, rotated.PublicKey()) {
+		t.Fatal("Expected the rotated key to be registered")
+	}
+	for _, block := range []*types.Block{b1, b2} {
+		if err := engine.ValidateBlock(chain, block); err != nil {
+			t.Fatalf("Expected block %d to stay valid after the key rotation, got %v", block.NumberU64(), err)
+		}
+	}
+}
+
+func TestImportedBlocksCloseSlots(t *testing.T) {
+	config := DefaultConfig()
+	config.ProposerSelection = ProposerSelectionRoundRobin
+	config.ProposerFallbacks = 2
+	producer, follower := newTestConsensus(t, config), newTestConsensus(t, config)
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		for _, engine := range []*Consensus{producer, follower} {
+			if err := engine.validatorMgr.AddValidator(address, config.MinStake); err != nil {
+				t.Fatal(err)
+			}
+		}
+		keys[address] = key
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Time: 1000}
+	chain := &testChain{headers: map[common.Hash]*types.Header{genesis.Hash(): genesis}}
+	order, err := follower.GetFallbackProposers(1, 1, genesis.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last backup produces the block once the other proposers' turns passed
+	header := &types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Coinbase: order[2], Time: genesis.Time + 2*uint64(config.B1BlockTime/time.Second), Extra: []byte{1}}
+	setTxRoot(header, PHTRoot(nil))
+	seal, err := crypto.Sign(proposerSigningHash(1, header.Hash()).Bytes(), keys[order[2]])
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := types.NewBlockWithHeader(header)
+	for _, engine := range []*Consensus{producer, follower} {
+		engine.cache.SetB1Block(header.Hash(), &B1Block{Header: header, BlockType: 1, MEVScore: 1, ProposerSig: seal})
+	}
+	producer.closeSlot(1, genesis.Hash(), genesis.Time, header)
+
+	// Importing nodes record the same missed slots as the producer, once
+	for i := 0; i < 2; i++ {
+		if err := follower.ImportBlock(chain, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, validator := range order {
+		want := uint64(0)
+		if i < 2 {
+			want = 1
+		}
+		for name, engine := range map[string]*Consensus{"producer": producer, "follower": follower} {
+			if missed := engine.GetValidatorPerformance(validator).MissedSlots; missed != want {
+				t.Fatalf("Proposer %d on the %s: expected %d missed slots, got %d", i, name, want, missed)
+			}
+		}
+	}
+
+	// Blocks failing validation close no slot
+	forged := &types.Header{Number: big.NewInt(1), ParentHash: genesis.Hash(), Coinbase: order[2], Time: header.Time + 1, Extra: []byte{1}}
+	setTxRoot(forged, PHTRoot(nil))
+	follower.cache.SetB1Block(forged.Hash(), &B1Block{Header: forged, BlockType: 1, MEVScore: 1, ProposerSig: seal})
+	if err := follower.ImportBlock(chain, types.NewBlockWithHeader(forged)); !errors.Is(err, ErrWrongProposer) {
+		t.Fatalf("Expected a block sealed for another header to be rejected, got %v", err)
+	}
+	if missed := follower.GetValidatorPerformance(order[0]).MissedSlots; missed != 1 {
+		t.Fatalf("Expected the rejected block to record no missed slot, got %d", missed)
+	}
+}
+
+func TestAttestationCommittees(t *testing.T) {
+	config := DefaultConfig()
+	config.AttestationCommitteeSize = 4
+	engine := newTestConsensus(t, config)
+	keys := make(map[common.Address][]byte)
+	for i := 0; i < 6; i++ {
+		key, _ := crypto.GenerateKey()
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		if err := engine.validatorMgr.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+		keys[address] = crypto.FromECDSA(key)
+	}
+
+	// Committees are deterministic per slot and limited to the configured size
+	parent := common.Hash{0xaa}
+	committee := engine.GetAttestationCommittee(1, parent)
+	if len(committee) != 4 {
+		t.Fatalf("Expected a committee of 4, got %d", len(committee))
+	}
+	if again := engine.GetAttestationCommittee(1, parent); !reflect.DeepEqual(committee, again) {
+		t.Fatal("Expected the same committee for the same slot")
+	}
+	var outsider common.Address
+	for address := range keys {
+		member := false
+		for _, m := range committee {
+			member = member || m == address
+		}
+		if !member {
+			outsider = address
+		}
+	}
+
+	b1Hash := common.Hash{0x01}
+	engine.attestations.Open(1, b1Hash, 0.5, committee)
+	attest := func(attester common.Address, mevScore float64) *B1Attestation {
+		t.Helper()
+		attestation := &B1Attestation{BlockNumber: 1, B1Hash: b1Hash, MEVScore: mevScore}
+		if err := SignB1Attestation(attestation, keys[attester]); err != nil {
+			t.Fatal(err)
+		}
+		return attestation
+	}
+
+	// Attestations from outside the committee, about another MEV score or with a forged signer are rejected
+	if _, err := engine.SubmitAttestation(attest(outsider, 0.5)); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected an outsider's attestation to be rejected, got %v", err)
+	}
+	if _, err := engine.SubmitAttestation(attest(committee[0], 0.9)); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected a wrong MEV score to be rejected, got %v", err)
+	}
+	forged := attest(outsider, 0.5)
+	forged.Attester = committee[0]
+	if _, err := engine.SubmitAttestation(forged); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected a forged attester to be rejected, got %v", err)
+	}
+
+	// More than two thirds of the committee reach quorum
+	for i, member := range committee[:3] {
+		reached, err := engine.SubmitAttestation(attest(member, 0.5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reached != (i == 2) {
+			t.Fatalf("Attestation %d: expected quorum reached %v", i, i == 2)
+		}
+		if engine.attestations.Attested(b1Hash) != (i == 2) {
+			t.Fatalf("Attestation %d: unexpected attested state", i)
+		}
+	}
+	if got := engine.GetAttestations(b1Hash); len(got) != 3 {
+		t.Fatalf("Expected 3 attestations, got %d", len(got))
+	}
+	if engine.attestations.Attested(common.Hash{0x02}) {
+		t.Fatal("Expected an unknown B1 block not to be attested")
+	}
+
+	// B2 blocks carry the quorum, which validators check against the committee
+	b1Block := &B1Block{Header: &types.Header{Number: big.NewInt(1), ParentHash: parent}, BlockType: 1, MEVScore: 0.5}
+	carried := engine.GetAttestations(b1Hash)
+	if err := VerifyAttestations(carried, b1Hash, b1Block, committee, config.AttestationQuorum); err != nil {
+		t.Fatalf("Expected the carried quorum to verify: %v", err)
+	}
+	if err := VerifyAttestations(carried[:2], b1Hash, b1Block, committee, config.AttestationQuorum); !errors.Is(err, ErrAttestationQuorum) {
+		t.Fatalf("Expected two attestations to miss quorum, got %v", err)
+	}
+	repeated := []*B1Attestation{carried[0], carried[0], carried[0]}
+	if err := VerifyAttestations(repeated, b1Hash, b1Block, committee, config.AttestationQuorum); !errors.Is(err, ErrAttestationQuorum) {
+		t.Fatalf("Expected a repeated attestation to count once, got %v", err)
+	}
+	if err := VerifyAttestations(append(carried[:2:2], attest(outsider, 0.5)), b1Hash, b1Block, committee, config.AttestationQuorum); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected an outsider's attestation to be rejected, got %v", err)
+	}
+	if err := VerifyAttestations(carried, common.Hash{0x02}, b1Block, committee, config.AttestationQuorum); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected attestations of another block to be rejected, got %v", err)
+	}
+	engine.attestations.Forget(b1Hash)
+	if engine.attestations.Attested(b1Hash) {
+		t.Fatal("Expected a forgotten B1 block not to be attested")
+	}
+}
+
+func TestDoubleSignDetection(t *testing.T) {
+	engine := newTestConsensus(t, DefaultConfig())
+	validators := []common.Address{{0x01}, {0x02}, {0x03}}
+	keys := make(map[common.Address]*BLSSecretKey)
+	for _, validator := range validators {
+		if err := engine.validatorMgr.AddValidator(validator, DefaultConfig().MinStake); err != nil {
+			t.Fatal(err)
+		}
+		key, err := GenerateBLSKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := key.ProvePossession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.RegisterBLSKey(validator, key.PublicKey(), proof); err != nil {
+			t.Fatal(err)
+		}
+		keys[validator] = key
+	}
+	sign := func(signer common.Address, blockType uint8, header *types.Header) *BlockSignature {
+		t.Helper()
+		signature := &BlockSignature{Validator: signer, BlockType: blockType, Header: header}
+		sig, err := keys[signer].Sign(signature.SigningHash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature.Signature = sig
+		return signature
+	}
+	first := &types.Header{Number: big.NewInt(5), Extra: []byte("first")}
+	second := &types.Header{Number: big.NewInt(5), Extra: []byte("second")}
+	offender := validators[0]
+
+	// Signatures that do not verify are rejected
+	forged := sign(validators[1], 1, first)
+	forged.Validator = offender
+	if _, err := engine.ObserveBlockSignature(5, forged); !errors.Is(err, ErrInvalidBlockSignature) {
+		t.Fatalf("Expected a forged signature to be rejected, got %v", err)
+	}
+
+	// Signing the same block twice, or a B1 and a B2 block at one height, is no offense
+	for _, signature := range []*BlockSignature{sign(offender, 1, first), sign(offender, 1, first), sign(offender, 2, second)} {
+		if record, err := engine.ObserveBlockSignature(5, signature); err != nil || record != nil {
+			t.Fatalf("Expected no slashing, got %v, %v", record, err)
+		}
+	}
+
+	// A second B1 block at the same height is a double sign and slashes the signer
+	stake := engine.validatorMgr.GetValidator(offender).Stake
+	record, err := engine.ObserveBlockSignature(5, sign(offender, 1, second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Validator != offender || record.Offense != OffenseDoubleSign || record.Height != 5 {
+		t.Fatalf("Unexpected slashing record %+v", record)
+	}
+	if engine.validatorMgr.GetValidator(offender).Stake.Cmp(stake) >= 0 {
+		t.Fatal("Expected the double signer's stake to be slashed")
+	}
+	if record, err := engine.ObserveBlockSignature(5, sign(offender, 1, &types.Header{Number: big.NewInt(5), Extra: []byte("third")})); err != nil || record != nil {
+		t.Fatalf("Expected a double sign to be reported once, got %v, %v", record, err)
+	}
+	evidence := engine.GetDoubleSignEvidence()
+	if len(evidence) != 1 || evidence[0].First.Validator != offender {
+		t.Fatalf("Expected one piece of evidence, got %d", len(evidence))
+	}
+
+	// Signatures far beyond the head cannot push the evidence out of the window
+	future := sign(offender, 1, &types.Header{Number: big.NewInt(5 + evidenceWindow + 1)})
+	if _, err := engine.ObserveBlockSignature(5, future); !errors.Is(err, ErrInvalidBlockSignature) {
+		t.Fatalf("Expected a far-future signature to be rejected, got %v", err)
+	}
+	if len(engine.GetDoubleSignEvidence()) != 1 {
+		t.Fatal("Expected the evidence to be kept")
+	}
+	if _, err := engine.ObserveBlockSignature(5, sign(offender, 2, &types.Header{Number: big.NewInt(5 + evidenceFutureMargin)})); err != nil {
+		t.Fatalf("Expected a signature just ahead of the head to be accepted, got %v", err)
+	}
+
+	// Evidence of blocks at different heights does not verify
+	bogus := &DoubleSignEvidence{First: sign(validators[1], 1, first), Second: sign(validators[1], 1, &types.Header{Number: big.NewInt(6)})}
+	if _, err := engine.SubmitSlashingEvidence(6, bogus); !errors.Is(err, ErrInvalidEvidence) {
+		t.Fatalf("Expected evidence across heights to be rejected, got %v", err)
+	}
+}
+
+func TestStakingContractWatcher(t *testing.T) {
+	config := DefaultConfig()
+	config.StakingContract = common.Address{0xee}
+	config.UnbondingEpochs = 0
+	engine := newTestConsensus(t, config)
+	stakingLog := func(contract common.Address, topic common.Hash, validator common.Address, amount *big.Int) *types.Log {
+		return &types.Log{
+			Address: contract,
+			Topics:  []common.Hash{topic, common.BytesToHash(validator.Bytes())},
+			Data:    common.BigToHash(amount).Bytes(),
+		}
+	}
+	process := func(watcher *StakingWatcher, number uint64, hash, parent common.Hash, logs ...*types.Log) []*StakingEvent {
+		return watcher.ProcessReceipts(number, hash, parent, []*types.Receipt{{Logs: logs}})
+	}
+	deposit := crypto.Keccak256Hash([]byte("Deposit(address,uint256)"))
+	withdrawal := crypto.Keccak256Hash([]byte("Withdrawal(address,uint256)"))
+	half := new(big.Int).Div(config.MinStake, big.NewInt(2))
+	validator := common.Address{0x01}
+
+	// Deposits below the minimum stake stay pending; events of other contracts are ignored
+	events := process(engine.staking, 1, common.Hash{1}, common.Hash{},
+		stakingLog(config.StakingContract, deposit, validator, half),
+		stakingLog(common.Address{0xff}, deposit, validator, config.MinStake),
+	)
+	if len(events) != 1 || engine.validatorMgr.IsValidator(validator) {
+		t.Fatalf("Expected one pending deposit, got %d events", len(events))
+	}
+	if engine.GetPendingDeposit(validator).Cmp(half) != 0 {
+		t.Fatalf("Expected pending deposit %v, got %v", half, engine.GetPendingDeposit(validator))
+	}
+
+	// Reaching the minimum stake adds the validator
+	db := memorydb.New()
+	if err := engine.OpenDatabase(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	process(engine.staking, 2, common.Hash{2}, common.Hash{1}, stakingLog(config.StakingContract, deposit, validator, half))
+	if info := engine.GetValidatorInfo(validator); info == nil || info.Stake.Cmp(config.MinStake) != 0 {
+		t.Fatal("Expected the validator to be added with the minimum stake")
+	}
+	if engine.GetPendingDeposit(validator).Sign() != 0 {
+		t.Fatal("Expected no pending deposit once the validator is added")
+	}
+
+	// Blocks are processed once
+	if events := process(engine.staking, 2, common.Hash{2}, common.Hash{1}, stakingLog(config.StakingContract, deposit, validator, half)); len(events) != 0 {
+		t.Fatal("Expected a processed block to be ignored")
+	}
+
+	// Withdrawals lower the stake, removing the validator once nothing is left
+	process(engine.staking, 3, common.Hash{3}, common.Hash{2}, stakingLog(config.StakingContract, withdrawal, validator, half))
+	if info := engine.GetValidatorInfo(validator); info == nil || info.IsActive || info.Stake.Cmp(half) != 0 {
+		t.Fatal("Expected the validator deactivated with half the minimum stake")
+	}
+	process(engine.staking, 4, common.Hash{4}, common.Hash{3}, stakingLog(config.StakingContract, withdrawal, validator, config.MinStake))
+	if engine.validatorMgr.IsValidator(validator) {
+		t.Fatal("Expected the validator removed after withdrawing all stake")
+	}
+
+	// The watcher state survives a restart
+	other := common.Address{0x02}
+	process(engine.staking, 5, common.Hash{5}, common.Hash{4}, stakingLog(config.StakingContract, deposit, other, half))
+	restarted := newTestConsensus(t, config)
+	if err := restarted.OpenDatabase(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	if restarted.GetPendingDeposit(other).Cmp(half) != 0 {
+		t.Fatal("Expected the pending deposit restored")
+	}
+	if events := process(restarted.staking, 5, common.Hash{5}, common.Hash{4}); events != nil {
+		t.Fatal("Expected the processed block restored")
+	}
+
+	// A sibling block reverts the one it replaces, which is still revertible
+	// after the restart
+	process(restarted.staking, 5, common.Hash{0x55}, common.Hash{4}, stakingLog(config.StakingContract, deposit, validator, config.MinStake))
+	if restarted.GetPendingDeposit(other).Sign() != 0 {
+		t.Fatal("Expected the deposit of the replaced block reverted")
+	}
+	if info := restarted.GetValidatorInfo(validator); info == nil || info.Stake.Cmp(config.MinStake) != 0 {
+		t.Fatal("Expected the deposit of the sibling block applied")
+	}
+}
+
+func TestStakingWatcherReorg(t *testing.T) {
+	config := DefaultConfig()
+	config.StakingContract = common.Address{0xee}
+	config.UnbondingEpochs = 4
+	engine := newTestConsensus(t, config)
+	stakingLog := func(topic common.Hash, validator common.Address, amount *big.Int) *types.Receipt {
+		return &types.Receipt{Logs: []*types.Log{{
+			Address: config.StakingContract,
+			Topics:  []common.Hash{topic, common.BytesToHash(validator.Bytes())},
+			Data:    common.BigToHash(amount).Bytes(),
+		}}}
+	}
+	deposit := crypto.Keccak256Hash([]byte("Deposit(address,uint256)"))
+	withdrawal := crypto.Keccak256Hash([]byte("Withdrawal(address,uint256)"))
+	existing, joining := common.Address{0x01}, common.Address{0x02}
+	if err := engine.validatorMgr.AddValidator(existing, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	stake := func(address common.Address) *big.Int {
+		if info := engine.GetValidatorInfo(address); info != nil {
+			return info.Stake
+		}
+		return nil
+	}
+	twice := new(big.Int).Mul(config.MinStake, big.NewInt(2))
+
+	// Branch A: the existing validator withdraws everything, another joins
+	genesis := common.Hash{0xff}
+	engine.staking.ProcessReceipts(1, common.Hash{0xa1}, genesis, []*types.Receipt{stakingLog(withdrawal, existing, config.MinStake)})
+	engine.staking.ProcessReceipts(2, common.Hash{0xa2}, common.Hash{0xa1}, []*types.Receipt{stakingLog(deposit, joining, config.MinStake)})
+	if engine.validatorMgr.IsValidator(existing) || !engine.validatorMgr.IsValidator(joining) {
+		t.Fatal("Expected branch A applied")
+	}
+	if len(engine.validatorMgr.GetUnbonding(existing)) != 1 {
+		t.Fatal("Expected the withdrawal to unbond")
+	}
+
+	// Branch B forks from the genesis: branch A is reverted without unbonding
+	events := engine.staking.ProcessReceipts(1, common.Hash{0xb1}, genesis, []*types.Receipt{stakingLog(deposit, existing, config.MinStake)})
+	if len(events) != 1 || events[0].Stake.Cmp(twice) != 0 {
+		t.Fatalf("Expected the deposit applied on the restored stake, got %v", events)
+	}
+	if stake(existing).Cmp(twice) != 0 || engine.validatorMgr.IsValidator(joining) {
+		t.Fatal("Expected branch A reverted before branch B applied")
+	}
+	if len(engine.validatorMgr.GetUnbonding(existing)) != 0 {
+		t.Fatal("Expected the reverted withdrawal to leave no unbonding stake")
+	}
+
+	// Extending branch A again replays it on top of the common ancestor
+	engine.staking.ProcessReceipts(3, common.Hash{0xa3}, common.Hash{0xa2}, nil)
+	if engine.validatorMgr.IsValidator(existing) || stake(joining).Cmp(config.MinStake) != 0 {
+		t.Fatal("Expected branch A replayed")
+	}
+
+	// Switching to a processed block of branch B only switches branches
+	if events := engine.staking.ProcessReceipts(1, common.Hash{0xb1}, genesis, nil); events != nil {
+		t.Fatal("Expected a processed block not to report its events again")
+	}
+	if stake(existing).Cmp(twice) != 0 || engine.validatorMgr.IsValidator(joining) {
+		t.Fatal("Expected branch B applied again")
+	}
+}
+
+func TestProposerSchedule(t *testing.T) {
+	config := DefaultConfig()
+	config.ProposerSelection = ProposerSelectionRoundRobin
+	engine := newTestConsensus(t, config)
+	validators := []common.Address{{0x01}, {0x02}, {0x03}}
+	for _, validator := range validators {
+		if err := engine.validatorMgr.AddValidator(validator, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rotations are confirmed and match the proposer selected for each height
+	head := &types.Header{Number: big.NewInt(9)}
+	schedule := engine.GetProposerSchedule(head, 4)
+	if len(schedule) != 4 {
+		t.Fatalf("Expected 4 assignments, got %d", len(schedule))
+	}
+	pair := config.B1BlockTime + config.B2BlockTime
+	for i, assignment := range schedule {
+		proposer, err := engine.validatorMgr.SelectProposer(assignment.BlockNumber, common.Hash{0xff})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assignment.BlockNumber != 10+uint64(i) || assignment.B1Proposer != proposer || assignment.B2Proposer != proposer || !assignment.Confirmed {
+			t.Fatalf("Unexpected assignment %d: %+v", i, assignment)
+		}
+		if assignment.B1Offset != time.Duration(i)*pair || assignment.B2Offset != assignment.B1Offset+config.B2BlockTime {
+			t.Fatalf("Unexpected offsets for assignment %d: %v, %v", i, assignment.B1Offset, assignment.B2Offset)
+		}
+	}
+	if again := engine.GetProposerSchedule(head, 4); !reflect.DeepEqual(schedule, again) {
+		t.Fatal("Expected the schedule to be deterministic")
+	}
+
+	// Weighted draws are only known for the block after the head
+	weighted := newTestConsensus(t, DefaultConfig())
+	for _, validator := range validators {
+		if err := weighted.validatorMgr.AddValidator(validator, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head = &types.Header{Number: big.NewInt(9), Extra: []byte("head")}
+	schedule = weighted.GetProposerSchedule(head, 3)
+	if len(schedule) != 3 {
+		t.Fatalf("Expected 3 assignments, got %d", len(schedule))
+	}
+	proposer, err := weighted.validatorMgr.SelectProposer(10, head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !schedule[0].Confirmed || schedule[0].B1Proposer != proposer {
+		t.Fatalf("Expected the next proposer to be drawn from the head hash, got %+v", schedule[0])
+	}
+	for _, assignment := range schedule[1:] {
+		if assignment.Confirmed || assignment.B1Proposer != (common.Address{}) || assignment.B2Proposer != (common.Address{}) {
+			t.Fatalf("Expected later weighted assignments to be unconfirmed, got %+v", assignment)
+		}
+	}
+	if schedule[2].BlockNumber != 12 || schedule[2].B1Offset != 2*pair {
+		t.Fatalf("Expected later assignments to keep their timing, got %+v", schedule[2])
+	}
+	if schedule := newTestConsensus(t, config).GetProposerSchedule(head, 2); len(schedule) != 0 {
+		t.Fatal("Expected an empty schedule without validators")
+	}
+	if schedule := engine.GetProposerSchedule(head, 1<<20); len(schedule) != 1024 {
+		t.Fatalf("Expected the lookahead to be bounded, got %d", len(schedule))
+	}
+}
+
+func TestValidatorEventFeed(t *testing.T) {
+	config := DefaultConfig()
+	config.UnbondingEpochs = 0
+	engine := newTestConsensus(t, config)
+	events := make(chan ValidatorEvent, 16)
+	sub := engine.SubscribeValidatorEvents(events)
+	defer sub.Unsubscribe()
+
+	validator := common.Address{0x01}
+	half := new(big.Int).Div(config.MinStake, big.NewInt(2))
+	if err := engine.validatorMgr.AddValidator(validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.validatorMgr.UpdateStake(validator, half); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.validatorMgr.UpdateStake(validator, config.MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.validatorMgr.Slash(validator, half); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.validatorMgr.RemoveValidator(validator); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ValidatorEventKind{
+		ValidatorEventAdded,
+		ValidatorEventDeactivated,
+		ValidatorEventActivated,
+		ValidatorEventSlashed,
+		ValidatorEventDeactivated,
+		ValidatorEventJailed,
+		ValidatorEventRemoved,
+	}
+	for i, kind := range want {
+		select {
+		case event := <-events:
+			if event.Kind != kind || event.Validator != validator {
+				t.Fatalf("Event %d: expected %s, got %s for %s", i, kind, event.Kind, event.Validator.Hex())
+			}
+			switch event.Kind {
+			case ValidatorEventSlashed:
+				if event.Amount.Cmp(half) != 0 || event.Stake.Cmp(half) != 0 {
+					t.Fatalf("Unexpected slashing event %+v", event)
+				}
+			case ValidatorEventJailed:
+				if event.Reason != "slashed" || event.JailedUntil != config.JailDuration {
+					t.Fatalf("Unexpected jailing event %+v", event)
+				}
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Missing event %d: %s", i, kind)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Unexpected event %s", event.Kind)
+	default:
+	}
+}
+
+func TestOperatorStakeCap(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxOperatorStakeBps = 4000
+	engine := newTestConsensus(t, config)
+	operator := common.Address{0xaa}
+	first, second := common.Address{0x01}, common.Address{0x02}
+	independents := []common.Address{{0x03}, {0x04}, {0x05}}
+	large := new(big.Int).Mul(config.MinStake, big.NewInt(3))
+	for _, validator := range []common.Address{first, second} {
+		if err := engine.validatorMgr.AddValidator(validator, large); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, validator := range independents {
+		if err := engine.validatorMgr.AddValidator(validator, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	independent := independents[0]
+
+	// Without an operator every validator is weighted with its full stake
+	if stake := engine.validatorMgr.WeightedStake(first); stake.Cmp(large) != 0 {
+		t.Fatalf("Expected full stake, got %v", stake)
+	}
+
+	// Grouped validators holding two thirds of the stake are capped at 40% of the capped total
+	for _, validator := range []common.Address{first, second} {
+		if err := engine.SetValidatorOperator(validator, operator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := engine.validatorMgr.OperatorValidators(operator); !reflect.DeepEqual(got, []common.Address{first, second}) {
+		t.Fatalf("Unexpected operator validators %v", got)
+	}
+	if info := engine.GetValidatorInfo(first); info.Operator != operator {
+		t.Fatalf("Expected operator %s, got %s", operator.Hex(), info.Operator.Hex())
+	}
+	for _, validator := range []common.Address{first, second} {
+		if stake := engine.validatorMgr.WeightedStake(validator); stake.Cmp(config.MinStake) != 0 {
+			t.Fatalf("Expected capped stake %v, got %v", config.MinStake, stake)
+		}
+	}
+	groupedStake, totalStake := new(big.Int), new(big.Int)
+	for _, validator := range append([]common.Address{first, second}, independents...) {
+		stake := engine.validatorMgr.WeightedStake(validator)
+		totalStake.Add(totalStake, stake)
+		if validator == first || validator == second {
+			groupedStake.Add(groupedStake, stake)
+		}
+	}
+	if share := new(big.Int).Mul(groupedStake, big.NewInt(basisPoints)); share.Cmp(new(big.Int).Mul(totalStake, big.NewInt(4000))) > 0 {
+		t.Fatalf("Expected the operator to hold at most 40%% of the weighted stake, got %v of %v", groupedStake, totalStake)
+	}
+	if stake := engine.validatorMgr.WeightedStake(independent); stake.Cmp(config.MinStake) != 0 {
+		t.Fatalf("Expected the independent validator uncapped, got %v", stake)
+	}
+	if stake := engine.GetValidatorInfo(first).Stake; stake.Cmp(large) != 0 {
+		t.Fatal("Expected the cap to leave the actual stake untouched")
+	}
+
+	// The cap lowers the operator's share of proposals from two thirds to 40%
+	grouped := 0
+	for number := uint64(0); number < 2000; number++ {
+		proposer, err := engine.validatorMgr.SelectProposer(number, common.Hash{0x01})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proposer == first || proposer == second {
+			grouped++
+		}
+	}
+	if share := float64(grouped) / 2000; share > 0.44 {
+		t.Fatalf("Expected the operator's proposer share at most 0.40, got %.3f", share)
+	}
+
+	// With several operators above the cap, each is capped against the capped total
+	limit := operatorStakeLimit(map[common.Address]*big.Int{
+		{0x01}: big.NewInt(600),
+		{0x02}: big.NewInt(300),
+		{0x03}: big.NewInt(50),
+		{0x04}: big.NewInt(50),
+	}, 4000)
+	// Two capped operators and a rest of 100 give c = 0.4·100/(1-0.8) = 200
+	if limit == nil || limit.Int64() != 200 {
+		t.Fatalf("Expected both large operators capped at 200, got %v", limit)
+	}
+
+	// Ungrouping restores full weight
+	if err := engine.SetValidatorOperator(second, second); err != nil {
+		t.Fatal(err)
+	}
+	if stake := engine.validatorMgr.WeightedStake(second); stake.Cmp(large) != 0 {
+		t.Fatalf("Expected full stake after ungrouping, got %v", stake)
+	}
+}
+
+func TestBackupProposerFallback(t *testing.T) {
+	config := DefaultConfig()
+	config.ProposerSelection = ProposerSelectionRoundRobin
+	engine := newTestConsensus(t, config)
+	for _, validator := range []common.Address{{0x01}, {0x02}, {0x03}, {0x04}, {0x05}} {
+		if err := engine.validatorMgr.AddValidator(validator, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent := common.Hash{0x01}
+
+	// The selected proposer leads a deterministic order of backups
+	order, err := engine.GetFallbackProposers(7, 1, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != config.ProposerFallbacks+1 {
+		t.Fatalf("Expected %d proposers, got %d", config.ProposerFallbacks+1, len(order))
+	}
+	selected, _ := engine.validatorMgr.SelectProposer(7, parent)
+	if order[0] != selected {
+		t.Fatal("Expected the selected proposer first")
+	}
+	if again, _ := engine.GetFallbackProposers(7, 1, parent); !reflect.DeepEqual(order, again) {
+		t.Fatal("Expected a deterministic proposer order")
+	}
+
+	// Each backup takes over after a further slot time, the last one staying on
+	for elapsed, rank := range map[time.Duration]int{
+		0:                                    0,
+		config.B1BlockTime / 2:               0,
+		config.B1BlockTime + time.Second:     1,
+		2*config.B1BlockTime + time.Second:   2,
+		100*config.B1BlockTime + time.Second: len(order) - 1,
+	} {
+		engine.slots.Done(7, 1)
+		engine.slots.Start(7, 1, time.Now().Add(-elapsed))
+		proposer, got, err := engine.GetSlotProposer(7, 1, parent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != rank || proposer != order[rank] {
+			t.Fatalf("After %v: expected rank %d, got %d", elapsed, rank, got)
+		}
+	}
+
+	// A backup producing the block before its turn costs no one a missed slot
+	parentTime := uint64(1000)
+	slotSeconds := uint64(config.B1BlockTime / time.Second)
+	early := &types.Header{Number: big.NewInt(7), ParentHash: parent, Coinbase: order[2], Time: parentTime + slotSeconds}
+	engine.closeSlot(1, parent, parentTime, early)
+	for i, validator := range order {
+		if missed := engine.GetValidatorPerformance(validator).MissedSlots; missed != 0 {
+			t.Fatalf("Proposer %d: expected no missed slots after an early backup block, got %d", i, missed)
+		}
+	}
+
+	// A backup producing the block costs the proposers it took over from a missed slot
+	reputation := engine.GetValidatorInfo(order[0]).Reputation
+	late := &types.Header{Number: big.NewInt(7), ParentHash: parent, Coinbase: order[2], Time: parentTime + 2*slotSeconds}
+	engine.closeSlot(1, parent, parentTime, late)
+	engine.closeSlot(1, parent, parentTime, late)
+	for i, validator := range order {
+		want := uint64(0)
+		if i < 2 {
+			want = 1
+		}
+		if missed := engine.GetValidatorPerformance(validator).MissedSlots; missed != want {
+			t.Fatalf("Proposer %d: expected %d missed slots once, got %d", i, want, missed)
+		}
+	}
+	if engine.GetValidatorInfo(order[0]).Reputation >= reputation {
+		t.Fatal("Expected the missed proposer's reputation to drop")
+	}
+
+	// B2 slots fall back from the proposer of the revealed B1 block
+	b1Header := &types.Header{Number: big.NewInt(7), Coinbase: order[1]}
+	engine.cache.SetB1Block(b1Header.Hash(), &B1Block{Header: b1Header, BlockType: 1})
+	b2Order, err := engine.GetFallbackProposers(8, 2, b1Header.Hash())
+	if err != nil || b2Order[0] != order[1] {
+		t.Fatalf("Expected the B1 proposer to lead the B2 slot, got %v, %v", b2Order, err)
+	}
+	if _, err := engine.GetFallbackProposers(8, 2, common.Hash{0xff}); err == nil {
+		t.Fatal("Expected an unknown B1 block to fail")
+	}
+}
+
+func TestSeparateB2Proposer(t *testing.T) {
+	config := DefaultConfig()
+	config.ProposerSelection = ProposerSelectionRoundRobin
+	config.SeparateB2Proposer = true
+	b1Node, b2Node := newTestConsensus(t, config), newTestConsensus(t, config)
+	keys := make(map[common.Address][]byte)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		keys[address] = crypto.FromECDSA(key)
+		for _, engine := range []*Consensus{b1Node, b2Node} {
+			if err := engine.validatorMgr.AddValidator(address, config.MinStake); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// The B1 proposer hands its PHT openings to an independently selected B2 proposer
+	parent := common.Hash{0x01}
+	b1Proposer, err := b1Node.validatorMgr.SelectProposer(3, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, _ := crypto.GenerateKey()
+	tx, err := types.SignNewTx(sender, types.LatestSignerForChainID(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Gas:       21000,
+		GasFeeCap: big.NewInt(3000000000),
+		GasTipCap: big.NewInt(1000000000),
+		To:        &common.Address{0xa},
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pht, err := b1Node.phtManager.CreatePHT(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(3), ParentHash: parent, Coinbase: b1Proposer}
+	b1Hash := header.Hash()
+	for _, engine := range []*Consensus{b1Node, b2Node} {
+		engine.cache.SetB1Block(b1Hash, &B1Block{Header: header, PHTs: []*PHTTransaction{pht}, BlockType: 1})
+	}
+	b2Proposer, err := b1Node.validatorMgr.SelectB2Proposer(3, b1Hash, b1Proposer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b2Proposer == b1Proposer {
+		t.Fatal("Expected a B2 proposer other than the B1 proposer")
+	}
+	order, err := b1Node.GetFallbackProposers(4, 2, b1Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order[0] != b2Proposer {
+		t.Fatal("Expected the B2 proposer to lead the B2 slot")
+	}
+	for _, proposer := range order {
+		if proposer == b1Proposer {
+			t.Fatal("Expected the B1 proposer to be left out of the B2 slot")
+		}
+	}
+
+	signerKey := func(address common.Address) *ecdsa.PrivateKey {
+		key, err := crypto.ToECDSA(keys[address])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	var bystander common.Address
+	for address := range keys {
+		if address != b1Proposer && address != b2Proposer {
+			bystander = address
+		}
+	}
+	if _, err := b1Node.CreatePHTHandoff(b1Hash); !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("Expected a node without signer to be refused the handoff, got %v", err)
+	}
+	if err := b1Node.SetSigner(NewLocalSigner(signerKey(bystander), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b1Node.CreatePHTHandoff(b1Hash); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("Expected only the B1 proposer to hand off, got %v", err)
+	}
+	if err := b1Node.SetSigner(NewLocalSigner(signerKey(b1Proposer), nil)); err != nil {
+		t.Fatal(err)
+	}
+	handoff, err := b1Node.CreatePHTHandoff(b1Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handoff.To != b2Proposer || len(handoff.Openings) != 1 {
+		t.Fatalf("Unexpected handoff to %s with %d openings", handoff.To.Hex(), len(handoff.Openings))
+	}
+
+	// Handoffs are only accepted by the B2 proposer they are addressed to
+	if err := b2Node.AcceptPHTHandoff(handoff); !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("Expected a node without signer to refuse the handoff, got %v", err)
+	}
+	if err := b2Node.SetSigner(NewLocalSigner(signerKey(bystander), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b2Node.AcceptPHTHandoff(handoff); !errors.Is(err, ErrInvalidHandoff) {
+		t.Fatalf("Expected a handoff to another validator to be refused, got %v", err)
+	}
+	if err := b2Node.SetSigner(NewLocalSigner(signerKey(b2Proposer), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Misaddressed or tampered handoffs are rejected
+	misaddressed := *handoff
+	misaddressed.To = b1Proposer
+	if err := b2Node.AcceptPHTHandoff(&misaddressed); !errors.Is(err, ErrInvalidHandoff) {
+		t.Fatalf("Expected a misaddressed handoff to be rejected, got %v", err)
+	}
+	tampered := *handoff
+	tampered.Openings = []*CommitmentOpening{{Commitment: []byte{0x01}, Blinding: []byte{0x02}}}
+	if err := b2Node.AcceptPHTHandoff(&tampered); !errors.Is(err, ErrInvalidHandoff) {
+		t.Fatalf("Expected a tampered handoff to be rejected, got %v", err)
+	}
+	if _, err := b2Node.phtManager.Opening(pht.Commitment); !errors.Is(err, ErrOpeningNotFound) {
+		t.Fatal("Expected no opening before the handoff")
+	}
+	if err := b2Node.AcceptPHTHandoff(handoff); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b2Node.phtManager.Opening(pht.Commitment); err != nil {
+		t.Fatalf("Expected the handed off opening, got %v", err)
+	}
+
+	// Each block type must come from a validator scheduled for it
+	b1Order, err := b1Node.proposerOrder(3, 1, parent)
+	if err != nil || proposerRank(b1Order, b1Proposer) < 0 {
+		t.Fatalf("Expected the B1 proposer in the B1 slot, got %v, %v", b1Order, err)
+	}
+	b2Order, err := b1Node.proposerOrder(4, 2, b1Hash)
+	if err != nil || proposerRank(b2Order, b2Proposer) < 0 {
+		t.Fatalf("Expected the B2 proposer in the B2 slot, got %v, %v", b2Order, err)
+	}
+	if proposerRank(b2Order, b1Proposer) >= 0 {
+		t.Fatal("Expected the B1 proposer to be refused the B2 block")
+	}
+
+	// The producer is recovered from the proposer seal, the coinbase is not trusted
+	b2Header := &types.Header{Number: big.NewInt(4), ParentHash: b1Hash, Coinbase: b2Proposer, Extra: []byte{2}}
+	b2Hash := b2Header.Hash()
+	b2Node.cache.SetB2Block(b2Hash, &B2Block{Header: b2Header, BlockType: 2, B1BlockHash: b1Hash})
+	if err := b2Node.AcceptPHTHandoff(handoff); !errors.Is(err, ErrInvalidHandoff) {
+		t.Fatalf("Expected a handoff after the B2 block to be refused, got %v", err)
+	}
+	if err := b2Node.SetSigner(NewLocalSigner(signerKey(b1Proposer), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b2Node.SignProposal(b2Hash); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("Expected a node to seal only blocks it produced, got %v", err)
+	}
+	if err := b2Node.SetSigner(NewLocalSigner(signerKey(b2Proposer), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b2Node.SignProposal(b2Hash); err != nil {
+		t.Fatal(err)
+	}
+	b2Block, _ := b2Node.cache.GetB2Block(b2Hash)
+	if err := b1Node.verifyProposer(2, b1Hash, header.Time, b2Header, b2Block.ProposerSig); err != nil {
+		t.Fatal(err)
+	}
+	forged, err := crypto.Sign(proposerSigningHash(2, b2Hash).Bytes(), signerKey(b1Proposer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b1Node.verifyProposer(2, b1Hash, header.Time, b2Header, forged); !errors.Is(err, ErrWrongProposer) {
+		t.Fatalf("Expected a seal by another validator than the coinbase to be rejected, got %v", err)
+	}
+	if err := b1Node.verifyProposer(2, b1Hash, header.Time, b2Header, nil); !errors.Is(err, ErrWrongProposer) {
+		t.Fatalf("Expected an unsealed block to be rejected, got %v", err)
+	}
+
+	// Seals are checked without separate roles too
+	single := newTestConsensus(t, DefaultConfig())
+	for address := range keys {
+		if err := single.validatorMgr.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proposer, err := single.validatorMgr.SelectProposer(3, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1Header := &types.Header{Number: big.NewInt(3), ParentHash: parent, Coinbase: proposer, Extra: []byte{1}}
+	sealed, err := crypto.Sign(proposerSigningHash(1, b1Header.Hash()).Bytes(), signerKey(proposer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := single.verifyProposer(1, parent, 0, b1Header, sealed); err != nil {
+		t.Fatal(err)
+	}
+	for address := range keys {
+		if address == proposer {
+			continue
+		}
+		forged, err := crypto.Sign(proposerSigningHash(1, b1Header.Hash()).Bytes(), signerKey(address))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := single.verifyProposer(1, parent, 0, b1Header, forged); !errors.Is(err, ErrWrongProposer) {
+			t.Fatalf("Expected a B1 block claiming another coinbase to be rejected, got %v", err)
+		}
+	}
+
+	// A backup may only produce the block once the proposers ahead of it had their slot time
+	order, err = single.GetFallbackProposers(3, 1, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentTime := uint64(1000)
+	early := &types.Header{Number: big.NewInt(3), ParentHash: parent, Coinbase: order[1], Time: parentTime + 1, Extra: []byte{1}}
+	sealed, err = crypto.Sign(proposerSigningHash(1, early.Hash()).Bytes(), signerKey(order[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := single.verifyProposer(1, parent, parentTime, early, sealed); !errors.Is(err, ErrWrongProposer) {
+		t.Fatalf("Expected an early backup block to be rejected, got %v", err)
+	}
+	late := &types.Header{Number: big.NewInt(3), ParentHash: parent, Coinbase: order[1], Time: parentTime + uint64(config.B1BlockTime/time.Second), Extra: []byte{1}}
+	sealed, err = crypto.Sign(proposerSigningHash(1, late.Hash()).Bytes(), signerKey(order[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := single.verifyProposer(1, parent, parentTime, late, sealed); err != nil {
+		t.Fatalf("Expected the backup block after the proposer's slot time to be accepted, got %v", err)
+	}
+}
+
+func TestValidatorSigners(t *testing.T) {
+	engine := newTestConsensus(t, DefaultConfig())
+	var signers []*LocalSigner
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		blsKey, err := GenerateBLSKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer := NewLocalSigner(key, blsKey)
+		if err := engine.validatorMgr.AddValidator(signer.Address(), DefaultConfig().MinStake); err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, signer)
+	}
+
+	// Keys load from encrypted keystores
+	store := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := store.ImportECDSA(signers[0].key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyJSON, err := os.ReadFile(account.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blsKeyJSON, err := EncryptBLSKey(signers[0].blsKey, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeystoreSigner(keyJSON, blsKeyJSON, "wrong"); err == nil {
+		t.Fatal("Expected a wrong passphrase to fail")
+	}
+	loaded, err := LoadKeystoreSigner(keyJSON, blsKeyJSON, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Address() != signers[0].Address() || !bytes.Equal(loaded.BLSPublicKey(), signers[0].BLSPublicKey()) {
+		t.Fatal("Expected the keystore signer to sign for the same keys")
+	}
+
+	// Signers register their BLS keys and sign shares of the block signature
+	header := &types.Header{Number: big.NewInt(1)}
+	b1Block := &B1Block{Header: header, BlockType: 1, MEVScore: 0.5}
+	b1Hash := header.Hash()
+	engine.cache.SetB1Block(b1Hash, b1Block)
+	if _, err := engine.SignBlockShare(b1Hash); !errors.Is(err, ErrNoSigner) {
+		t.Fatalf("Expected ErrNoSigner, got %v", err)
+	}
+	for _, signer := range signers {
+		if err := engine.SetSigner(signer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A remote signer signs through a signer service
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := RegisterSignerService(server, NewSignerService(loaded)); err != nil {
+		t.Fatal(err)
+	}
+	remote, err := NewRemoteSigner(context.Background(), rpc.DialInProc(server), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remote.Address() != signers[0].Address() {
+		t.Fatal("Expected the remote signer to sign for the served validator")
+	}
+
+	var shares []*BlockSignature
+	for _, signer := range []ValidatorSigner{remote, signers[1], signers[2]} {
+		if err := engine.SetSigner(signer); err != nil {
+			t.Fatal(err)
+		}
+		share, err := engine.SignBlockShare(b1Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares = append(shares, share)
+	}
+	if err := engine.AggregateBlockShares(b1Hash, shares[:2]); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected two of four shares to lack quorum, got %v", err)
+	}
+	if err := engine.AggregateBlockShares(b1Hash, shares); err != nil {
+		t.Fatal(err)
+	}
+	if err := b1Block.ValidateSignature(engine.validatorMgr.BLSKeySet()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Remote signatures not made with the announced keys are rejected
+	impostor := *remote
+	impostor.identity.Address = signers[1].Address()
+	if _, err := impostor.SignAttestation(&B1Attestation{BlockNumber: 1, B1Hash: b1Hash}); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("Expected ErrSignerMismatch, got %v", err)
+	}
+	impostor.identity = SignerIdentity{Address: signers[1].Address(), BLSPublicKey: signers[1].BLSPublicKey()}
+	if _, err := impostor.SignBlock(1, header); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("Expected ErrSignerMismatch, got %v", err)
+	}
+
+	// Signers refuse to sign conflicting payloads, remotely too
+	conflicting := &types.Header{Number: big.NewInt(1), Extra: []byte{1}}
+	if _, err := remote.SignBlock(1, conflicting); err == nil || !strings.Contains(err.Error(), ErrSlashableSigning.Error()) {
+		t.Fatalf("Expected the remote signer to refuse a second B1 block at height 1, got %v", err)
+	}
+	if _, err := remote.SignBlock(1, header); err != nil {
+		t.Fatalf("Expected signing the same block again to be allowed, got %v", err)
+	}
+	if _, err := signers[1].SignBlock(2, conflicting); err != nil {
+		t.Fatalf("Expected block types to be protected separately, got %v", err)
+	}
+	older := &types.Header{Number: big.NewInt(0)}
+	if _, err := signers[1].SignBlock(1, older); !errors.Is(err, ErrSlashableSigning) {
+		t.Fatalf("Expected a block below the signed height to be refused, got %v", err)
+	}
+	proposal := &SealProposal{BlockNumber: 5, Leader: signers[0].Address(), PHTHashes: []common.Hash{{0x01}}}
+	sig, err := remote.SignSealProposal(proposal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := recoverSealSigner(proposal.SigningHash(), sig); err != nil || signer != signers[0].Address() {
+		t.Fatalf("Expected the seal proposal to be signed by the validator, got %v", err)
+	}
+	reordered := &SealProposal{BlockNumber: 5, Leader: signers[0].Address(), PHTHashes: []common.Hash{{0x02}}}
+	if _, err := remote.SignSealProposal(reordered); err == nil {
+		t.Fatal("Expected a second seal proposal at the same height to be refused")
+	}
+
+	// Slashing protection persists across restarts
+	db := memorydb.New()
+	protection, err := NewSlashingProtection(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := protection.Check(signingKindAttestation, 7, common.Hash{0x07}); err != nil {
+		t.Fatal(err)
+	}
+	if protection, err = NewSlashingProtection(db); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewLocalSigner(signers[3].key, signers[3].blsKey)
+	restarted.SetSlashingProtection(protection)
+	if _, err := restarted.SignAttestation(&B1Attestation{BlockNumber: 7, B1Hash: common.Hash{0x08}}); !errors.Is(err, ErrSlashableSigning) {
+		t.Fatalf("Expected the persisted record to refuse a conflicting attestation, got %v", err)
+	}
+
+	// Setting a signer with a new BLS key rotates the registered key
+	rotatedKey, err := GenerateBLSKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetSigner(NewLocalSigner(signers[1].key, rotatedKey)); err != nil {
+		t.Fatal(err)
+	}
+	if registered := engine.validatorMgr.GetValidator(signers[1].Address()).BLSPublicKey; !bytes.Equal(registered, rotatedKey.PublicKey()) {
+		t.Fatal("Expected the rotated BLS key to be registered")
+	}
+	if err := engine.AggregateBlockShares(b1Hash, shares); !errors.Is(err, ErrInvalidAggregateSignature) {
+		t.Fatalf("Expected shares of the old key to stop verifying, got %v", err)
+	}
+
+	// Attestations are signed with the validator key
+	engine.attestations.Open(1, b1Hash, b1Block.MEVScore, []common.Address{signers[1].Address()})
+	attestation, err := engine.Attest(b1Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestation.Attester != signers[1].Address() || len(engine.GetAttestations(b1Hash)) != 1 {
+		t.Fatal("Expected the attestation to be pooled")
+	}
+	if err := engine.SetSigner(signers[2]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Attest(b1Hash); !errors.Is(err, ErrInvalidAttestation) {
+		t.Fatalf("Expected a non-member's attestation to be rejected, got %v", err)
+	}
+}
+
+func TestValidatorKeyLifecycle(t *testing.T) {
+	key, err := GenerateValidatorKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved keys load back with the passphrase only
+	dir := filepath.Join(t.TempDir(), "validator")
+	if err := key.Save(dir, "secret", keystore.LightScryptN, keystore.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{validatorKeyFile, validatorBLSKeyFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("Expected %s to be owner only, got %v", name, info.Mode().Perm())
+		}
+	}
+	if _, err := LoadValidatorKey(dir, "wrong"); err == nil {
+		t.Fatal("Expected a wrong passphrase to fail")
+	}
+	loaded, err := LoadValidatorKey(dir, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Address() != key.Address() || !bytes.Equal(loaded.BLSPublicKey(), key.BLSPublicKey()) {
+		t.Fatal("Expected the loaded key to match the saved one")
+	}
+
+	// Signatures recover to the validator address
+	hash := common.Hash{0x01}
+	sig, err := loaded.Sign(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer, err := recoverSealSigner(hash, sig); err != nil || signer != key.Address() {
+		t.Fatalf("Expected the signature to recover to %s", key.Address().Hex())
+	}
+
+	// A rotated BLS key keeps the address and replaces the registered key
+	engine := newTestConsensus(t, DefaultConfig())
+	if err := engine.validatorMgr.AddValidator(key.Address(), DefaultConfig().MinStake); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetSigner(loaded.Signer()); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := loaded.RotateBLSKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Address() != key.Address() || bytes.Equal(rotated.BLSPublicKey(), key.BLSPublicKey()) {
+		t.Fatal("Expected only the BLS key to rotate")
+	}
+	if err := rotated.Save(dir, "secret", keystore.LightScryptN, keystore.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := LoadValidatorKey(dir, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetSigner(reloaded.Signer()); err != nil {
+		t.Fatal(err)
+	}
+	if registered := engine.validatorMgr.GetValidator(key.Address()).BLSPublicKey; !bytes.Equal(registered, rotated.BLSPublicKey()) {
+		t.Fatal("Expected the rotated BLS key to be registered")
+	}
+
+	// A rotation interrupted after the BLS key was replaced leaves a pair the
+	// ECDSA key file does not record, which fails to load
+	staged := filepath.Join(t.TempDir(), "staged")
+	if err := loaded.Save(staged, "secret", keystore.LightScryptN, keystore.LightScryptP); err != nil {
+		t.Fatal(err)
+	}
+	blsKeyJSON, err := os.ReadFile(filepath.Join(staged, validatorBLSKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, validatorBLSKeyFile), blsKeyJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadValidatorKey(dir, "secret"); !errors.Is(err, ErrSignerMismatch) {
+		t.Fatalf("Expected a mismatched BLS key to be rejected, got %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestApplyCalibration(t *testing.T) {
+	detector := NewMEVDetector(DefaultP2SConfig())
+
+	// Front-runners pay above 50 gwei, benign users above 10 gwei: both look like sandwiches
+	phtWithGas := func(i byte, gwei int64) *PHTTransaction {
+		return &PHTTransaction{
+			TxHash:    common.Hash{i},
+			Sender:    common.Address{i},
+			Recipient: common.Address{0xee},
+			GasPrice:  new(big.Int).Mul(big.NewInt(gwei), big.NewInt(1000000000)),
+			Value:     new(big.Int),
+		}
+	}
+	block := &B1Block{Header: &types.Header{Number: big.NewInt(1)}}
+	labels := make(map[common.Hash]bool)
+	for i := byte(1); i <= 6; i++ {
+		if i%2 == 0 {
+			block.PHTs = append(block.PHTs, phtWithGas(i, 60))
+			labels[common.Hash{i}] = true
+		} else {
+			block.PHTs = append(block.PHTs, phtWithGas(i, 20))
+		}
+	}
+	benign := phtWithGas(0x10, 20)
+	if !reflect.DeepEqual(detector.AnalyzeMEVRisk(benign).DetectedAttacks, []string{"sandwich_attack"}) {
+		t.Fatal("Expected the uncalibrated detector to flag the benign transaction")
+	}
+
+	// Calibrating leaves the production metrics alone
+	detector.ResetDetectionMetrics()
+	report := detector.Calibrate([]*B1Block{block}, labels)
+	if metrics := detector.GetDetectionMetrics(); metrics["sandwich_attack"].Evaluations != 0 {
+		t.Fatalf("Expected calibration not to record detections, got %+v", metrics["sandwich_attack"])
+	}
+	if report.Patterns["sandwich_attack"].Precision != 1 || report.Patterns["sandwich_attack"].Recall != 1 {
+		t.Fatalf("Expected a threshold separating the labelled transactions, got %+v", report.Patterns["sandwich_attack"])
+	}
+
+	// Applied thresholds change what is detected
+	if err := detector.ApplyCalibration(report); err != nil {
+		t.Fatal(err)
+	}
+	if attacks := detector.AnalyzeMEVRisk(benign).DetectedAttacks; len(attacks) != 0 {
+		t.Fatalf("Expected the calibrated detector to clear the benign transaction, got %v", attacks)
+	}
+	if attacks := detector.AnalyzeMEVRisk(phtWithGas(0x11, 60)).DetectedAttacks; len(attacks) == 0 {
+		t.Fatal("Expected the calibrated detector to still flag front-running")
+	}
+	if _, attacks := detector.DetectMEV([]*PHTTransaction{benign}); len(attacks) != 0 {
+		t.Fatalf("Expected block detection to use the calibrated thresholds, got %v", attacks)
+	}
+}
+
+func TestVerifyThresholdSeal(t *testing.T) {
+	config := DefaultConfig()
+	config.B1SealingMode = SealingModeCommittee
+	config.SealCommitteeSize = 3
+	config.SealThreshold = 2
+	engine := newTestConsensus(t, config)
+	keys := make(map[common.Address]*ecdsa.PrivateKey)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		address := crypto.PubkeyToAddress(key.PublicKey)
+		keys[address] = key
+		if err := engine.validatorMgr.AddValidator(address, config.MinStake); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	phts := []*PHTTransaction{{TxHash: common.Hash{0x01}}, {TxHash: common.Hash{0x02}}}
+	header := &types.Header{Number: big.NewInt(3), ParentHash: common.Hash{0xaa}}
+	b1Hash := header.Hash()
+	engine.cache.SetB1Block(b1Hash, &B1Block{Header: header, PHTs: phts, BlockType: 1})
+
+	// seal collects co-signatures of the given keys over a proposal of a committee
+	seal := func(committee *SealingCommittee, number uint64, ordering []*PHTTransaction, signers []*ecdsa.PrivateKey) *ThresholdSeal {
+		t.Helper()
+		proposal := committee.Propose(number, ordering)
+		for _, key := range signers {
+			sig, err := SignSealProposal(proposal, crypto.FromECDSA(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := committee.AddSignature(sig); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sealed, err := committee.Seal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+	expected := func() *SealingCommittee {
+		committee, err := engine.NewSealingCommittee(3, header.ParentHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return committee
+	}
+	committee := expected()
+	var signers []*ecdsa.PrivateKey
+	for _, member := range committee.ordered[:2] {
+		signers = append(signers, keys[member])
+	}
+
+	// A self-appointed committee of one cannot seal the block
+	outsider, _ := crypto.GenerateKey()
+	self, err := NewSealingCommittee([]common.Address{crypto.PubkeyToAddress(outsider.PublicKey)}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SealB1Block(b1Hash, seal(self, 3, phts, []*ecdsa.PrivateKey{outsider})); err == nil {
+		t.Fatal("Expected a self-appointed committee to be rejected")
+	}
+
+	// A seal claiming threshold 0 needs no fewer signatures
+	zero := seal(expected(), 3, phts, signers)
+	zero.Threshold, zero.Signatures = 0, nil
+	if err := engine.SealB1Block(b1Hash, zero); err == nil {
+		t.Fatal("Expected a zero threshold seal to be rejected")
+	}
+	short := seal(expected(), 3, phts, signers)
+	short.Signatures = short.Signatures[:1]
+	if err := engine.SealB1Block(b1Hash, short); err == nil {
+		t.Fatal("Expected a seal below the threshold to be rejected")
+	}
+
+	// The proposal must be for this block and its PHT ordering
+	if err := engine.SealB1Block(b1Hash, seal(expected(), 4, phts, signers)); err == nil {
+		t.Fatal("Expected a seal for another block number to be rejected")
+	}
+	if err := engine.SealB1Block(b1Hash, seal(expected(), 3, []*PHTTransaction{phts[1], phts[0]}, signers)); err == nil {
+		t.Fatal("Expected a seal over another PHT ordering to be rejected")
+	}
+	if b1Block, _ := engine.cache.GetB1Block(b1Hash); b1Block.CommitteeSeal != nil {
+		t.Fatal("Expected rejected seals not to be attached")
+	}
+
+	if err := engine.SealB1Block(b1Hash, seal(expected(), 3, phts, signers)); err != nil {
+		t.Fatalf("Expected the expected committee's seal to be accepted: %v", err)
+	}
+
+	// A committee needs a positive threshold to seal at all
+	config.SealThreshold = 0
+	if _, err := engine.NewSealingCommittee(3, header.ParentHash); err == nil {
+		t.Fatal("Expected a zero seal threshold to be rejected")
+	}
+}
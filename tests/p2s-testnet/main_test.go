@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2stestnet"
+)
+
+func TestRunInit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "testnet")
+	if err := runInit([]string{"-dir", dir, "-chainid", "42", "-validators", "2", "-bootnodes", "2", "-ip", "203.0.113.1", "-port", "30400"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "genesis.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var genesis struct {
+		Config struct {
+			ChainID uint64 `json:"chainId"`
+		} `json:"config"`
+		Validators []string `json:"validators"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		t.Fatal(err)
+	}
+	if genesis.Config.ChainID != 42 || len(genesis.Validators) != 2 {
+		t.Fatalf("Expected chain 42 with 2 validators, got %d with %d", genesis.Config.ChainID, len(genesis.Validators))
+	}
+	for _, name := range []string{"validator0/key", "validator1/key", "faucet/key", "bootnode0/nodekey", "bootnode1/nodekey"} {
+		if _, err := crypto.LoadECDSA(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Expected %s to hold a key: %v", name, err)
+		}
+	}
+
+	// Bootnodes advertise the given endpoint, one port each
+	bootnodes, err := os.ReadFile(filepath.Join(dir, "bootnodes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := strings.Fields(string(bootnodes))
+	if len(urls) != 2 || !strings.HasSuffix(urls[0], "@203.0.113.1:30400") || !strings.HasSuffix(urls[1], "@203.0.113.1:30401") {
+		t.Fatalf("Expected bootnodes on 203.0.113.1:30400 and 30401, got %v", urls)
+	}
+
+	if err := runInit([]string{"-dir", dir, "-ip", "not-an-ip"}); err == nil {
+		t.Fatal("Expected an invalid bootnode IP to be rejected")
+	}
+	if err := runInit([]string{"-dir", dir, "-validators", "0"}); err == nil {
+		t.Fatal("Expected a testnet without validators to be rejected")
+	}
+}
+
+func TestRunBootnode(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "bootnode.key")
+
+	// A missing key is generated and saved, then reused
+	if err := runBootnode([]string{"-key", keyFile, "-ip", "127.0.0.1", "-port", "30303"}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := p2stestnet.LoadBootnode(keyFile, net.IPv4(127, 0, 0, 1), 30303)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runBootnode([]string{"-key", keyFile, "-ip", "127.0.0.1", "-port", "30303"}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := p2stestnet.LoadBootnode(keyFile, net.IPv4(127, 0, 0, 1), 30303)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID() != second.ID() {
+		t.Fatal("Expected an existing bootnode key to be reused")
+	}
+
+	if err := runBootnode([]string{"-key", keyFile, "-ip", "not-an-ip"}); err == nil {
+		t.Fatal("Expected an invalid IP to be rejected")
+	}
+}
+
+func TestRunFaucetRequiresAuthorization(t *testing.T) {
+	if err := runFaucet([]string{"-key", filepath.Join(t.TempDir(), "missing")}); err == nil || !strings.Contains(err.Error(), "-apikeys or -open") {
+		t.Fatalf("Expected the faucet to require API keys or -open, got %v", err)
+	}
+	if err := runFaucet([]string{"-open", "-key", filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("Expected a missing faucet key to fail")
+	}
+}
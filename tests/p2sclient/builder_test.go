@@ -0,0 +1,147 @@
+package p2sclient
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newTestBuilder creates a builder for a fresh account on chain 1337
+func newTestBuilder(t *testing.T) (*Builder, *p2s.P2SConfig) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := p2s.DefaultP2SConfig()
+	config.ChainID = big.NewInt(1337)
+	builder, err := NewBuilder(key, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return builder, config
+}
+
+// transferTx is an unsigned transfer with the given account nonce
+func transferTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     nonce,
+		Gas:       21000,
+		GasFeeCap: big.NewInt(3000000000),
+		GasTipCap: big.NewInt(1000000000),
+		To:        &common.Address{0xa},
+		Value:     big.NewInt(1),
+	})
+}
+
+func TestBuilderRoundTrip(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	if _, err := NewBuilder(key, &p2s.P2SConfig{}); !errors.Is(err, ErrMissingChainID) {
+		t.Fatalf("Expected a configuration without chain ID to be rejected, got %v", err)
+	}
+
+	builder, config := newTestBuilder(t)
+	public, encoded, err := builder.Build(transferTx(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded) == 0 || public.Recipient != (common.Address{}) || public.Value.Sign() != 0 {
+		t.Fatalf("Expected a submission stripped of hidden fields, got %+v", public)
+	}
+
+	// The kept opening opens the submitted commitment
+	opening, err := builder.Opening(public.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheme, err := p2s.NewCommitmentScheme(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opening.Commitment, public.Commitment) {
+		t.Fatal("Expected the opening of the submitted commitment")
+	}
+	if err := opening.Verify(scheme); err != nil {
+		t.Fatalf("Expected the opening to verify, got %v", err)
+	}
+
+	// The reveal carries the hidden fields and the opening's blinding
+	mt, err := builder.Reveal(public.TxHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt.TxHash != public.TxHash || mt.Recipient != (common.Address{0xa}) || mt.Value.Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("Expected the MT to reveal the transfer, got %+v", mt)
+	}
+	if !bytes.Equal(mt.Blinding, opening.Blinding) {
+		t.Fatal("Expected the MT to carry the opening's blinding")
+	}
+	revealed := &p2s.CommitmentOpening{Commitment: public.Commitment, Data: opening.Data, Blinding: mt.Blinding}
+	if err := revealed.Verify(scheme); err != nil {
+		t.Fatalf("Expected the revealed blinding to open the commitment, got %v", err)
+	}
+
+	// Forgotten PHTs can no longer be opened or revealed
+	builder.Forget(public.TxHash)
+	if _, err := builder.Opening(public.TxHash); !errors.Is(err, ErrUnknownPHT) {
+		t.Fatalf("Expected a forgotten PHT to have no opening, got %v", err)
+	}
+	if _, err := builder.Reveal(public.TxHash); !errors.Is(err, ErrUnknownPHT) {
+		t.Fatalf("Expected a forgotten PHT not to be revealed, got %v", err)
+	}
+	builder.Forget(public.TxHash)
+	if _, err := builder.Reveal(common.Hash{0x01}); !errors.Is(err, ErrUnknownPHT) {
+		t.Fatalf("Expected an unknown PHT not to be revealed, got %v", err)
+	}
+}
+
+func TestBuilderConcurrentBuilds(t *testing.T) {
+	builder, _ := newTestBuilder(t)
+
+	const builds = 16
+	var (
+		wg     sync.WaitGroup
+		hashes = make([]common.Hash, builds)
+		errs   = make([]error, builds)
+	)
+	for i := 0; i < builds; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			public, _, err := builder.Build(transferTx(uint64(i)))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			hashes[i] = public.TxHash
+		}(i)
+	}
+	wg.Wait()
+
+	// Every build is kept and revealed on its own
+	seen := make(map[common.Hash]bool)
+	for i, hash := range hashes {
+		if errs[i] != nil {
+			t.Fatalf("Build %d failed: %v", i, errs[i])
+		}
+		if seen[hash] {
+			t.Fatalf("Build %d duplicated transaction %s", i, hash.Hex())
+		}
+		seen[hash] = true
+		mt, err := builder.Reveal(hash)
+		if err != nil {
+			t.Fatalf("Expected build %d to be revealed, got %v", i, err)
+		}
+		if mt.TxHash != hash {
+			t.Fatalf("Build %d revealed transaction %s", i, mt.TxHash.Hex())
+		}
+	}
+}
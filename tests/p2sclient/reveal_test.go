@@ -0,0 +1,113 @@
+package p2sclient
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/p2s"
+)
+
+// recordingTransport fails a number of reveals before accepting one, recording
+// the fee of every attempt
+type recordingTransport struct {
+	failures int
+	fees     []*big.Int
+}
+
+func (t *recordingTransport) SendReveal(ctx context.Context, mt *p2s.MTTransaction, fee *big.Int) error {
+	t.fees = append(t.fees, fee)
+	if len(t.fees) <= t.failures {
+		return errors.New("reveal rejected")
+	}
+	return nil
+}
+
+func TestRevealStrategies(t *testing.T) {
+	inclusion := time.Unix(1000, 0)
+	deadline := inclusion.Add(12 * time.Second)
+
+	if at := (ImmediateReveal{}).RevealAt(inclusion, deadline); !at.Equal(inclusion) {
+		t.Fatalf("Expected an immediate reveal at inclusion, got %v", at)
+	}
+	if at := (DeadlineReveal{Delta: 2 * time.Second}).RevealAt(inclusion, deadline); !at.Equal(deadline.Add(-2 * time.Second)) {
+		t.Fatalf("Expected a reveal 2s before the deadline, got %v", at)
+	}
+	if at := (DeadlineReveal{Delta: time.Minute}).RevealAt(inclusion, deadline); !at.Equal(inclusion) {
+		t.Fatalf("Expected a reveal never before inclusion, got %v", at)
+	}
+
+	fallback, relay := &recordingTransport{}, &recordingTransport{}
+	if (RelayReveal{Relay: relay}).Transport(fallback) != relay {
+		t.Fatal("Expected the relay strategy to use the relay")
+	}
+	if (RelayReveal{}).Transport(fallback) != fallback {
+		t.Fatal("Expected the relay strategy to fall back without a relay")
+	}
+	if err := NewRevealer(nil, nil).Reveal(context.Background(), &p2s.MTTransaction{}, time.Now(), time.Time{}); !errors.Is(err, ErrNoRevealTransport) {
+		t.Fatalf("Expected a revealer without transport to fail, got %v", err)
+	}
+}
+
+func TestRevealRetries(t *testing.T) {
+	config := &RevealConfig{
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		InitialFee:     big.NewInt(100),
+		FeeBumpPercent: 10,
+		MaxFee:         big.NewInt(115),
+	}
+	transport := &recordingTransport{failures: 2}
+	revealer := NewRevealer(transport, config)
+	if config.Strategy != nil {
+		t.Fatal("Expected the caller's config to be left unchanged")
+	}
+
+	// Fees are bumped on every retry and capped
+	if err := revealer.Reveal(context.Background(), &p2s.MTTransaction{}, time.Now(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{100, 110, 115}
+	if len(transport.fees) != len(want) {
+		t.Fatalf("Expected %d attempts, got %d", len(want), len(transport.fees))
+	}
+	for i, fee := range transport.fees {
+		if fee.Int64() != want[i] {
+			t.Fatalf("Attempt %d: expected fee %d, got %v", i, want[i], fee)
+		}
+	}
+
+	// Low fees are bumped by at least the minimum
+	config.InitialFee, config.MinFeeBump, config.MaxFee = big.NewInt(0), big.NewInt(50), nil
+	transport = &recordingTransport{failures: 2}
+	if err := NewRevealer(transport, config).Reveal(context.Background(), &p2s.MTTransaction{}, time.Now(), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	want = []int64{0, 50, 100}
+	for i, fee := range transport.fees {
+		if fee.Int64() != want[i] {
+			t.Fatalf("Attempt %d: expected fee %d, got %v", i, want[i], fee)
+		}
+	}
+
+	// No backoff follows the final failed attempt
+	config.MaxRetries, config.InitialBackoff = 0, time.Hour
+	failing := &recordingTransport{failures: 1}
+	start := time.Now()
+	if err := NewRevealer(failing, config).Reveal(context.Background(), &p2s.MTTransaction{}, time.Now(), time.Time{}); err == nil {
+		t.Fatal("Expected the failed reveal to be reported")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected no backoff after the last attempt, took %v", elapsed)
+	}
+
+	// Reveals past the deadline are refused
+	revealer = NewRevealer(&recordingTransport{}, config)
+	revealer.now = func() time.Time { return time.Unix(2000, 0) }
+	if err := revealer.Reveal(context.Background(), &p2s.MTTransaction{}, time.Unix(1000, 0), time.Unix(1500, 0)); !errors.Is(err, ErrRevealDeadline) {
+		t.Fatalf("Expected a reveal past the deadline to fail, got %v", err)
+	}
+}
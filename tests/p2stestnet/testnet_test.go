@@ -0,0 +1,244 @@
+package p2stestnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTestnetGeneration(t *testing.T) {
+	if _, err := NewTestnet(&TestnetConfig{}); err == nil {
+		t.Fatal("Expected a testnet without validators to be rejected")
+	}
+
+	config := DefaultTestnetConfig()
+	config.ChainID = 99
+	config.Validators = 3
+	config.Bootnodes = 2
+	testnet, err := NewTestnet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(testnet.Validators) != 3 || len(testnet.Bootnodes) != 2 || testnet.FaucetKey == nil {
+		t.Fatalf("Expected 3 validators, 2 bootnodes and a faucet, got %d and %d", len(testnet.Validators), len(testnet.Bootnodes))
+	}
+	if testnet.Bootnodes[1].Port != config.BootnodePort+1 {
+		t.Fatalf("Expected bootnode ports to be incremented, got %d", testnet.Bootnodes[1].Port)
+	}
+
+	dir := filepath.Join(t.TempDir(), "testnet")
+	if err := testnet.WriteTo(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Genesis allocates the validator stake and the faucet balance
+	data, err := os.ReadFile(filepath.Join(dir, "genesis.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var genesis struct {
+		Config struct {
+			ChainID uint64 `json:"chainId"`
+		} `json:"config"`
+		GasLimit   hexutil.Uint64                                    `json:"gasLimit"`
+		Alloc      map[common.Address]struct{ Balance *hexutil.Big } `json:"alloc"`
+		Validators []common.Address                                  `json:"validators"`
+	}
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		t.Fatal(err)
+	}
+	if genesis.Config.ChainID != 99 || uint64(genesis.GasLimit) != config.GasLimit {
+		t.Fatalf("Expected chain 99 with gas limit %d, got %d and %d", config.GasLimit, genesis.Config.ChainID, genesis.GasLimit)
+	}
+	if len(genesis.Alloc) != 4 || len(genesis.Validators) != 3 {
+		t.Fatalf("Expected 4 allocations and 3 validators, got %d and %d", len(genesis.Alloc), len(genesis.Validators))
+	}
+	for i, address := range testnet.ValidatorAddresses() {
+		if genesis.Validators[i] != address || genesis.Alloc[address].Balance.ToInt().Cmp(config.ValidatorStake) != 0 {
+			t.Fatalf("Expected validator %d to be staked in genesis", i)
+		}
+	}
+	if genesis.Alloc[testnet.FaucetAddress()].Balance.ToInt().Cmp(config.FaucetBalance) != 0 {
+		t.Fatal("Expected the faucet to be funded in genesis")
+	}
+
+	// Keys written load back to the generated identities
+	for i, address := range testnet.ValidatorAddresses() {
+		key, err := crypto.LoadECDSA(filepath.Join(dir, fmt.Sprintf("validator%d", i), "key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if crypto.PubkeyToAddress(key.PublicKey) != address {
+			t.Fatalf("Expected validator %d key to match its address", i)
+		}
+	}
+	faucetKey, err := crypto.LoadECDSA(filepath.Join(dir, "faucet", "key"))
+	if err != nil || crypto.PubkeyToAddress(faucetKey.PublicKey) != testnet.FaucetAddress() {
+		t.Fatalf("Expected the faucet key to load back, got %v", err)
+	}
+	bootnodes, err := os.ReadFile(filepath.Join(dir, "bootnodes.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := strings.Fields(string(bootnodes)); len(urls) != 2 || urls[0] != testnet.BootnodeURLs()[0] || urls[1] != testnet.BootnodeURLs()[1] {
+		t.Fatalf("Expected the bootnode list to hold both enode URLs, got %v", urls)
+	}
+	bootnode, err := LoadBootnode(filepath.Join(dir, "bootnode1", "nodekey"), config.BootnodeIP, config.BootnodePort+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bootnode.URL() != testnet.BootnodeURLs()[1] || bootnode.ID() != testnet.Bootnodes[1].ID() {
+		t.Fatal("Expected the bootnode key to load back to the same enode")
+	}
+	if _, err := LoadBootnode(filepath.Join(dir, "missing"), config.BootnodeIP, config.BootnodePort); err == nil {
+		t.Fatal("Expected a missing bootnode key to fail")
+	}
+}
+
+// recordingBackend records the funds sent, failing while fail is set
+type recordingBackend struct {
+	mu   sync.Mutex
+	sent []common.Address
+	fail bool
+}
+
+func (b *recordingBackend) SendFunds(ctx context.Context, to common.Address, amount *big.Int) (common.Hash, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fail {
+		return common.Hash{}, errors.New("node unavailable")
+	}
+	b.sent = append(b.sent, to)
+	return common.Hash{byte(len(b.sent))}, nil
+}
+
+func TestFaucetRateLimit(t *testing.T) {
+	backend := &recordingBackend{}
+	faucet := NewFaucet(backend, nil, &FaucetConfig{Amount: big.NewInt(1), Cooldown: 50 * time.Millisecond})
+	alice, bob, carol := common.Address{0x01}, common.Address{0x02}, common.Address{0x03}
+
+	// Addresses and client IPs are each limited to one request per cooldown
+	if _, err := faucet.Fund(context.Background(), alice, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := faucet.Fund(context.Background(), alice, "10.0.0.2"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected a repeated address to be rate limited, got %v", err)
+	}
+	if _, err := faucet.Fund(context.Background(), bob, "10.0.0.1"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected a repeated IP to be rate limited, got %v", err)
+	}
+	if _, err := faucet.Fund(context.Background(), bob, "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed sends release their reservation
+	backend.fail = true
+	if _, err := faucet.Fund(context.Background(), carol, "10.0.0.3"); err == nil {
+		t.Fatal("Expected the backend failure to be reported")
+	}
+	backend.fail = false
+	if _, err := faucet.Fund(context.Background(), carol, "10.0.0.3"); err != nil {
+		t.Fatalf("Expected a failed request to be retryable, got %v", err)
+	}
+
+	// Requests are accepted again once the cooldown passed
+	time.Sleep(60 * time.Millisecond)
+	if _, err := faucet.Fund(context.Background(), alice, "10.0.0.1"); err != nil {
+		t.Fatalf("Expected a request after the cooldown to be funded, got %v", err)
+	}
+	if len(backend.sent) != 4 {
+		t.Fatalf("Expected 4 funding transfers, got %d", len(backend.sent))
+	}
+
+	// Concurrent requests for one address are funded once
+	faucet = NewFaucet(backend, nil, &FaucetConfig{Amount: big.NewInt(1), Cooldown: time.Hour})
+	var (
+		wg     sync.WaitGroup
+		funded int
+		mu     sync.Mutex
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := faucet.Fund(context.Background(), common.Address{0x04}, net.IPv4(10, 1, 0, byte(i)).String()); err == nil {
+				mu.Lock()
+				funded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if funded != 1 {
+		t.Fatalf("Expected a single concurrent request to be funded, got %d", funded)
+	}
+}
+
+func TestFaucetHTTP(t *testing.T) {
+	backend := &recordingBackend{}
+	faucet := NewFaucet(backend, &APIKeyVerifier{Keys: []string{"k1", "k2"}}, &FaucetConfig{Amount: big.NewInt(5), Cooldown: time.Hour})
+	server := httptest.NewServer(faucet)
+	defer server.Close()
+
+	request := func(method, key, body string) (int, map[string]interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, decoded
+	}
+
+	if status, _ := request(http.MethodGet, "k1", ""); status != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected GET to be refused, got %d", status)
+	}
+	if status, _ := request(http.MethodPost, "wrong", `{"address":"0x0000000000000000000000000000000000000001"}`); status != http.StatusUnauthorized {
+		t.Fatalf("Expected an unknown API key to be refused, got %d", status)
+	}
+	if status, _ := request(http.MethodPost, "k1", `{"address":`); status != http.StatusBadRequest {
+		t.Fatalf("Expected a malformed body to be refused, got %d", status)
+	}
+	if status, _ := request(http.MethodPost, "k1", `{}`); status != http.StatusBadRequest {
+		t.Fatalf("Expected a request without address to be refused, got %d", status)
+	}
+	status, resp := request(http.MethodPost, "k2", `{"address":"0x0000000000000000000000000000000000000001"}`)
+	if status != http.StatusOK || resp["txHash"] == nil {
+		t.Fatalf("Expected the request to be funded, got %d %v", status, resp)
+	}
+
+	// All requests of the test server come from one client IP
+	if status, _ := request(http.MethodPost, "k1", `{"address":"0x0000000000000000000000000000000000000002"}`); status != http.StatusTooManyRequests {
+		t.Fatalf("Expected the client to be rate limited, got %d", status)
+	}
+	if len(backend.sent) != 1 {
+		t.Fatalf("Expected a single funding transfer, got %d", len(backend.sent))
+	}
+}